@@ -0,0 +1,200 @@
+package mailgun
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Metrics an A/B test is decided by.
+const (
+	ABOpenRate  = "open_rate"
+	ABClickRate = "click_rate"
+)
+
+// ABVariant is one of the variants of the message of an A/B test, such as a different subject or
+// template.
+type ABVariant struct {
+	// Name identifies the variant; its messages are tagged with the name of the test and variant.
+	Name string
+	// NewMessage returns a new message of the variant, without recipients. It is called for each
+	// batch of up to MaxNumberOfRecipients recipients.
+	NewMessage func() *Message
+}
+
+// ABRecipient is a recipient of an A/B test, with its recipient variables.
+type ABRecipient struct {
+	Address   string
+	Variables map[string]interface{}
+}
+
+// ABTest is the result of `SendABTest()`, which `GetABTestReport()` reports on.
+type ABTest struct {
+	Name     string
+	SentAt   time.Time
+	Variants []ABTestVariant
+}
+
+// ABTestVariant is a variant of a sent A/B test.
+type ABTestVariant struct {
+	Name string
+	// Tag is the tag of the messages of the variant.
+	Tag string
+	// Recipients is the number of recipients the variant was sent to.
+	Recipients int
+	// Batches are the results of the batch sends of the variant.
+	Batches []*BatchResult
+}
+
+// SendABTest splits the recipients across the variants in turn, so that each variant is sent to
+// every len(variants)-th recipient, and sends each variant to its recipients with `SendBatch()`,
+// tagged "<name>-<variant>". Shuffle the recipients first if their order is meaningful, such as
+// when they are sorted by sign-up date. The returned test records the tags to pass to
+// `GetABTestReport()` once recipients have had time to engage. The tags of all variants are
+// checked before any is sent. If a send fails, the test is returned as far as it was sent, with
+// the error.
+//
+//  test, err := mg.SendABTest(ctx, "spring-sale", []mailgun.ABVariant{
+//    {Name: "a", NewMessage: func() *mailgun.Message { return mg.NewMessage(from, "Spring sale", text) }},
+//    {Name: "b", NewMessage: func() *mailgun.Message { return mg.NewMessage(from, "20% off this week", text) }},
+//  }, recipients)
+func (mg *MailgunImpl) SendABTest(ctx context.Context, name string, variants []ABVariant, recipients []ABRecipient) (*ABTest, error) {
+	if len(variants) == 0 {
+		return nil, errors.New("an A/B test needs at least one variant")
+	}
+	// Every tag is checked before anything is sent, so a bad variant name sends nothing
+	for _, v := range variants {
+		if err := validateTag(name + "-" + v.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	test := &ABTest{Name: name, SentAt: time.Now()}
+	split := make([][]ABRecipient, len(variants))
+	for i, r := range recipients {
+		split[i%len(variants)] = append(split[i%len(variants)], r)
+	}
+
+	for i, v := range variants {
+		tv := ABTestVariant{Name: v.Name, Tag: name + "-" + v.Name, Recipients: len(split[i])}
+		err := mg.sendABVariant(ctx, v, &tv, split[i])
+		test.Variants = append(test.Variants, tv)
+		if err != nil {
+			return test, errors.Wrapf(err, "while sending variant '%s'", v.Name)
+		}
+	}
+	return test, nil
+}
+
+// sendABVariant sends the variant v to its recipients in batches, recording them in tv.
+func (mg *MailgunImpl) sendABVariant(ctx context.Context, v ABVariant, tv *ABTestVariant, recipients []ABRecipient) error {
+	for start := 0; start < len(recipients); start += MaxNumberOfRecipients {
+		end := start + MaxNumberOfRecipients
+		if end > len(recipients) {
+			end = len(recipients)
+		}
+		m := v.NewMessage()
+		if err := m.AddTag(tv.Tag); err != nil {
+			return err
+		}
+		for _, r := range recipients[start:end] {
+			if err := m.AddRecipientAndVariables(r.Address, r.Variables); err != nil {
+				return err
+			}
+		}
+		result, err := mg.SendBatch(ctx, m)
+		if err != nil {
+			return err
+		}
+		tv.Batches = append(tv.Batches, result)
+	}
+	return nil
+}
+
+// ABTestReport compares the variants of an A/B test.
+type ABTestReport struct {
+	Name string
+	// Metric is ABOpenRate or ABClickRate.
+	Metric   string
+	Variants []ABVariantReport
+	// Winner is the name of the variant with the highest rate, or "" if no variant has been
+	// delivered yet, or the best variants are tied.
+	Winner string
+}
+
+// ABVariantReport is the engagement with a variant of an A/B test.
+type ABVariantReport struct {
+	Name  string
+	Stats Stats
+	// Rate is the rate of the metric of the report: opens or clicks per delivered message.
+	Rate float64
+}
+
+// GetABTestReport fetches the stats of the tags of the variants of the test since it was sent, and
+// returns the variant with the highest rate of metric, ABOpenRate or ABClickRate, as the winner.
+// Rates count every open or click, so a recipient opening a message twice counts twice.
+func (mg *MailgunImpl) GetABTestReport(ctx context.Context, test *ABTest, metric string) (*ABTestReport, error) {
+	if metric != ABOpenRate && metric != ABClickRate {
+		return nil, errors.Errorf("unknown A/B test metric '%s'", metric)
+	}
+	report := &ABTestReport{Name: test.Name, Metric: metric}
+	// Daily buckets start at midnight, so start a day early to include the day the test was sent
+	opts := &GetStatOptions{Resolution: ResolutionDay, Start: test.SentAt.Add(-24 * time.Hour), End: time.Now()}
+
+	var winner string
+	var best float64
+	var leaders int
+	for _, v := range test.Variants {
+		stats, err := mg.getTagStats(ctx, v.Tag, []string{"delivered", "opened", "clicked"}, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while fetching stats of variant '%s'", v.Name)
+		}
+		vr := ABVariantReport{Name: v.Name}
+		for _, s := range stats {
+			vr.Stats.add(s)
+		}
+		engaged := vr.Stats.Opened.Total
+		if metric == ABClickRate {
+			engaged = vr.Stats.Clicked.Total
+		}
+		vr.Rate = rate(engaged, vr.Stats.Delivered.Total)
+		report.Variants = append(report.Variants, vr)
+
+		switch {
+		case vr.Stats.Delivered.Total == 0:
+		case leaders == 0 || vr.Rate > best:
+			winner, best, leaders = v.Name, vr.Rate, 1
+		case vr.Rate == best:
+			leaders++
+		}
+	}
+	if leaders == 1 {
+		report.Winner = winner
+	}
+	return report, nil
+}
+
+// getTagStats returns the stats of the messages with the tag, as `GetStats()` does for the domain.
+func (mg *MailgunImpl) getTagStats(ctx context.Context, tag string, events []string, opts *GetStatOptions) ([]Stats, error) {
+	r := newAPIRequest(mg, generateApiUrl(mg, tagsEndpoint)+"/"+pathEscape(tag)+"/stats")
+	if !opts.Start.IsZero() {
+		r.addParameter("start", strconv.Itoa(int(opts.Start.Unix())))
+	}
+	if !opts.End.IsZero() {
+		r.addParameter("end", strconv.Itoa(int(opts.End.Unix())))
+	}
+	if opts.Resolution != "" {
+		r.addParameter("resolution", string(opts.Resolution))
+	}
+	for _, e := range events {
+		r.addParameter("event", e)
+	}
+
+	var res statsTotalResponse
+	if err := getResponseFromJSON(ctx, r, &res); err != nil {
+		return nil, err
+	}
+	return res.Stats, nil
+}
@@ -0,0 +1,78 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestABTest(t *testing.T) {
+	sent := map[string][]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/messages"):
+			ensure.Nil(t, r.ParseMultipartForm(1<<20))
+			tag := r.FormValue("o:tag")
+			sent[tag] = append(sent[tag], r.FormValue("subject")+":"+strings.Join(r.MultipartForm.Value["to"], ","))
+			fmt.Fprint(w, `{"id": "<id@example.com>", "message": "Queued. Thank you."}`)
+		case r.URL.Path == "/v3/"+testDomain+"/tags/sale-a/stats":
+			ensure.DeepEqual(t, r.URL.Query()["event"], []string{"delivered", "opened", "clicked"})
+			fmt.Fprint(w, `{"stats": [{"delivered": {"total": 10}, "opened": {"total": 2}, "clicked": {"total": 1}},
+				{"delivered": {"total": 10}, "opened": {"total": 2}}]}`)
+		case r.URL.Path == "/v3/"+testDomain+"/tags/sale-b/stats":
+			fmt.Fprint(w, `{"stats": [{"delivered": {"total": 20}, "opened": {"total": 8}, "clicked": {"total": 1}}]}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	variant := func(name, subject string) mailgun.ABVariant {
+		return mailgun.ABVariant{Name: name, NewMessage: func() *mailgun.Message {
+			return mg.NewMessage("root@"+testDomain, subject, "Text Body")
+		}}
+	}
+	var recipients []mailgun.ABRecipient
+	for i := 1; i <= 5; i++ {
+		recipients = append(recipients, mailgun.ABRecipient{Address: fmt.Sprintf("user%d@example.com", i)})
+	}
+	test, err := mg.SendABTest(ctx, "sale", []mailgun.ABVariant{variant("a", "Spring sale"), variant("b", "20% off")}, recipients)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, sent, map[string][]string{
+		"sale-a": {"Spring sale:user1@example.com,user3@example.com,user5@example.com"},
+		"sale-b": {"20% off:user2@example.com,user4@example.com"},
+	})
+	ensure.DeepEqual(t, len(test.Variants), 2)
+	ensure.DeepEqual(t, test.Variants[0].Recipients, 3)
+	ensure.DeepEqual(t, test.Variants[1].Tag, "sale-b")
+
+	report, err := mg.GetABTestReport(ctx, test, mailgun.ABOpenRate)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, report.Winner, "b")
+	ensure.DeepEqual(t, report.Variants[0].Rate, 0.2)
+	ensure.DeepEqual(t, report.Variants[1].Rate, 0.4)
+
+	// Variants with the same rate are tied
+	report, err = mg.GetABTestReport(ctx, test, mailgun.ABClickRate)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, report.Variants[0].Rate, 0.05)
+	ensure.DeepEqual(t, report.Winner, "")
+
+	// A bad tag of any variant sends nothing
+	sent = map[string][]string{}
+	long := variant(strings.Repeat("c", mailgun.MaxTagLength), "Too long")
+	test, err = mg.SendABTest(ctx, "sale", []mailgun.ABVariant{variant("a", "Spring sale"), long}, recipients)
+	ensure.NotNil(t, err)
+	ensure.True(t, test == nil)
+	ensure.DeepEqual(t, len(sent), 0)
+}
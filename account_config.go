@@ -0,0 +1,568 @@
+package mailgun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AccountConfigVersion is the version of the documents written by `ExportAccountConfig()`.
+const AccountConfigVersion = 1
+
+// AccountConfig is a snapshot of the configuration of an account, written by
+// `ExportAccountConfig()` and applied by `ApplyAccountConfig()`. It holds no secrets: SMTP
+// credentials, API keys and the webhook signing key are left out, as are the members of mailing
+// lists, which `ArchiveMailingList()` and `ExportAllMembers()` save.
+type AccountConfig struct {
+	Version      int            `json:"version"`
+	ExportedAt   time.Time      `json:"exported_at"`
+	Domains      []DomainConfig `json:"domains"`
+	Routes       []RouteConfig  `json:"routes"`
+	MailingLists []ListConfig   `json:"mailing_lists"`
+}
+
+// DomainConfig is the configuration of a domain, with its webhooks and stored templates.
+type DomainConfig struct {
+	Name       string           `json:"name"`
+	SpamAction SpamAction       `json:"spam_action,omitempty"`
+	Wildcard   bool             `json:"wildcard"`
+	Connection DomainConnection `json:"connection"`
+	Tracking   DomainTracking   `json:"tracking"`
+	// Webhooks maps the kinds of webhooks, such as "delivered", to their URLs.
+	Webhooks  map[string][]string `json:"webhooks,omitempty"`
+	Templates []TemplateConfig    `json:"templates,omitempty"`
+}
+
+// TemplateConfig is a stored template with the content of each of its versions.
+type TemplateConfig struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Versions    []TemplateVersion `json:"versions"`
+}
+
+// RouteConfig is a route. Routes are identified by their expression, as their IDs differ from
+// one account to another.
+type RouteConfig struct {
+	Priority    int      `json:"priority"`
+	Description string   `json:"description,omitempty"`
+	Expression  string   `json:"expression"`
+	Actions     []string `json:"actions"`
+}
+
+// ListConfig is a mailing list, without its members.
+type ListConfig struct {
+	Address         string          `json:"address"`
+	Name            string          `json:"name,omitempty"`
+	Description     string          `json:"description,omitempty"`
+	AccessLevel     AccessLevel     `json:"access_level,omitempty"`
+	ReplyPreference ReplyPreference `json:"reply_preference,omitempty"`
+}
+
+// ConfigAction is a change `ApplyAccountConfig()` makes to an account.
+type ConfigAction string
+
+const (
+	// ConfigCreateDomain creates a domain.
+	ConfigCreateDomain ConfigAction = "create-domain"
+	// ConfigUpdateConnection updates the connection settings of a domain.
+	ConfigUpdateConnection ConfigAction = "update-connection"
+	// ConfigUpdateTracking updates the click, open or unsubscribe tracking of a domain.
+	ConfigUpdateTracking ConfigAction = "update-tracking"
+	// ConfigCreateWebhook creates a webhook of a domain.
+	ConfigCreateWebhook ConfigAction = "create-webhook"
+	// ConfigUpdateWebhook updates the urls of a webhook of a domain.
+	ConfigUpdateWebhook ConfigAction = "update-webhook"
+	// ConfigSyncTemplate changes a template or one of its versions; the TemplateSyncAction taken
+	// is in the Detail of the change, with the tag of the version.
+	ConfigSyncTemplate ConfigAction = "sync-template"
+	// ConfigCreateRoute creates a route.
+	ConfigCreateRoute ConfigAction = "create-route"
+	// ConfigUpdateRoute updates the priority, description or actions of a route.
+	ConfigUpdateRoute ConfigAction = "update-route"
+	// ConfigCreateList creates a mailing list.
+	ConfigCreateList ConfigAction = "create-list"
+	// ConfigUpdateList updates the settings of a mailing list.
+	ConfigUpdateList ConfigAction = "update-list"
+)
+
+// ConfigChange is a change made, or to be made in a dry run, by `ApplyAccountConfig()`.
+type ConfigChange struct {
+	Action ConfigAction
+	// Domain is the domain changed, or the domain of the webhook or template changed; it is empty
+	// for routes and mailing lists, which belong to the account.
+	Domain string
+	// Target is the kind of the webhook, the name of the template, the expression of the route
+	// or the address of the mailing list changed.
+	Target string
+	// Detail describes the change, such as the urls of a webhook or the ID of a route.
+	Detail string
+}
+
+// String describes the change, for printing a plan.
+func (c ConfigChange) String() string {
+	s := string(c.Action)
+	if c.Domain != "" {
+		s += " " + c.Domain
+	}
+	if c.Target != "" {
+		s += " " + c.Target
+	}
+	if c.Detail != "" {
+		s += " (" + c.Detail + ")"
+	}
+	return s
+}
+
+// ApplyConfigOptions modifies how `ApplyAccountConfig()` applies a configuration.
+type ApplyConfigOptions struct {
+	// DryRun returns the changes which would be made without making them.
+	DryRun bool
+}
+
+// ExportAccountConfig snapshots the configuration of the account: its domains with their
+// connection and tracking settings, webhooks and stored templates, its routes, and its mailing
+// lists without their members. The snapshot is written to w as an indented JSON document, which
+// YAML tools read as well, and returned; if w is nil, nothing is written. Restore it, or clone it
+// into another account, with `ApplyAccountConfig()`.
+//
+//  f, err := os.Create("mailgun-config.json")
+//  _, err = mg.ExportAccountConfig(ctx, f)
+func (mg *MailgunImpl) ExportAccountConfig(ctx context.Context, w io.Writer) (*AccountConfig, error) {
+	cfg := &AccountConfig{Version: AccountConfigVersion, ExportedAt: time.Now().UTC()}
+
+	var domains, page []Domain
+	it := mg.ListDomains(nil)
+	err := walkPages(ctx, mg.bulkLimiter(), func(ctx context.Context) bool {
+		if !it.Next(ctx, &page) {
+			return false
+		}
+		domains = append(domains, page...)
+		return true
+	}, &it.err)
+	if err != nil {
+		return nil, errors.Wrap(err, "while listing domains")
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Name < domains[j].Name })
+	for _, d := range domains {
+		dc, err := mg.exportDomainConfig(ctx, d)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while exporting domain '%s'", d.Name)
+		}
+		cfg.Domains = append(cfg.Domains, dc)
+	}
+
+	routes, err := mg.ListRoutesAll(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "while listing routes")
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Priority < routes[j].Priority })
+	for _, r := range routes {
+		cfg.Routes = append(cfg.Routes, RouteConfig{
+			Priority:    r.Priority,
+			Description: r.Description,
+			Expression:  r.Expression,
+			Actions:     r.Actions,
+		})
+	}
+
+	lists, err := mg.ListMailingListsAll(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "while listing mailing lists")
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].Address < lists[j].Address })
+	for _, l := range lists {
+		cfg.MailingLists = append(cfg.MailingLists, ListConfig{
+			Address:         l.Address,
+			Name:            l.Name,
+			Description:     l.Description,
+			AccessLevel:     l.AccessLevel,
+			ReplyPreference: l.ReplyPreference,
+		})
+	}
+
+	if w != nil {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(cfg); err != nil {
+			return nil, errors.Wrap(err, "while writing the account configuration")
+		}
+	}
+	return cfg, nil
+}
+
+func (mg *MailgunImpl) exportDomainConfig(ctx context.Context, d Domain) (DomainConfig, error) {
+	dc := DomainConfig{Name: d.Name, SpamAction: d.SpamAction, Wildcard: d.Wildcard}
+	var err error
+	if dc.Connection, err = mg.GetDomainConnection(ctx, d.Name); err != nil {
+		return dc, err
+	}
+	if dc.Tracking, err = mg.GetDomainTracking(ctx, d.Name); err != nil {
+		return dc, err
+	}
+	if dc.Webhooks, err = mg.listWebhooks(ctx, d.Name); err != nil {
+		return dc, err
+	}
+	if len(dc.Webhooks) == 0 {
+		dc.Webhooks = nil
+	}
+
+	dm := mg.forDomain(d.Name)
+	var templates, page []Template
+	it := dm.ListTemplates(nil)
+	err = walkPages(ctx, mg.bulkLimiter(), func(ctx context.Context) bool {
+		if !it.Next(ctx, &page) {
+			return false
+		}
+		templates = append(templates, page...)
+		return true
+	}, &it.err)
+	if err != nil {
+		return dc, err
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	for _, t := range templates {
+		tc, err := dm.exportTemplateConfig(ctx, t)
+		if err != nil {
+			return dc, errors.Wrapf(err, "while exporting template '%s'", t.Name)
+		}
+		dc.Templates = append(dc.Templates, tc)
+	}
+	return dc, nil
+}
+
+// exportTemplateConfig fetches the content of every version of the template.
+func (mg *MailgunImpl) exportTemplateConfig(ctx context.Context, t Template) (TemplateConfig, error) {
+	tc := TemplateConfig{Name: t.Name, Description: t.Description}
+	var tags []string
+	var page []TemplateVersion
+	it := mg.ListTemplateVersions(t.Name, &ListOptions{Limit: 100})
+	err := walkPages(ctx, mg.bulkLimiter(), func(ctx context.Context) bool {
+		if !it.Next(ctx, &page) {
+			return false
+		}
+		for _, v := range page {
+			tags = append(tags, v.Tag)
+		}
+		return true
+	}, &it.err)
+	if err != nil {
+		return tc, err
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		v, err := mg.GetTemplateVersion(ctx, t.Name, tag)
+		if err != nil {
+			return tc, err
+		}
+		v.CreatedAt = RFC2822Time{}
+		tc.Versions = append(tc.Versions, v)
+	}
+	return tc, nil
+}
+
+// ReadAccountConfig reads a configuration written by `ExportAccountConfig()`.
+func ReadAccountConfig(r io.Reader) (*AccountConfig, error) {
+	var cfg AccountConfig
+	if err := json.NewDecoder(r).Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Version > AccountConfigVersion {
+		return nil, fmt.Errorf("account configuration version %d is newer than the supported version %d", cfg.Version, AccountConfigVersion)
+	}
+	return &cfg, nil
+}
+
+// ApplyAccountConfig makes the account match a configuration exported with
+// `ExportAccountConfig()`, to restore an account after a mistake, or to clone the setup of one
+// account or environment into another. Only what differs is changed, so applying the same
+// configuration again changes nothing, and an apply which failed part way may be retried:
+//
+//   - Domains which do not exist are created; the spam action and wildcard setting of an existing
+//     domain cannot be changed through the API and are left alone. Connection and tracking settings
+//     which differ are updated.
+//   - Webhooks are created or updated to the URLs of the configuration.
+//   - Templates are synced as by `SyncTemplates()`: missing templates and versions are added,
+//     versions which differ are updated, and versions missing from the configuration are deleted.
+//   - Routes are matched by expression; those with no match are created, and those whose
+//     priority, description or actions differ are updated.
+//   - Mailing lists are created, or updated if their name, description, access level or reply
+//     preference differ.
+//
+// Nothing missing from the configuration is deleted, other than template versions: domains,
+// webhooks, templates, routes and lists the account has besides are left alone. New domains need
+// their DNS records set up and verified before they send. opts may be nil.
+//
+// The changes made are returned, in order, including when an error stops the apply part way.
+// With DryRun, nothing is changed and the changes returned are the plan:
+//
+//  cfg, err := mailgun.ReadAccountConfig(f)
+//  changes, err := staging.ApplyAccountConfig(ctx, cfg, &mailgun.ApplyConfigOptions{DryRun: true})
+//  for _, c := range changes {
+//    fmt.Println(c)
+//  }
+func (mg *MailgunImpl) ApplyAccountConfig(ctx context.Context, cfg *AccountConfig, opts *ApplyConfigOptions) ([]ConfigChange, error) {
+	if cfg == nil {
+		return nil, ErrEmptyParam
+	}
+	a := &configApplier{mg: mg, limiter: mg.bulkLimiter()}
+	if opts != nil {
+		a.dryRun = opts.DryRun
+	}
+	for _, dc := range cfg.Domains {
+		if err := a.applyDomain(ctx, dc); err != nil {
+			return a.changes, err
+		}
+	}
+	if err := a.applyRoutes(ctx, cfg.Routes); err != nil {
+		return a.changes, err
+	}
+	for _, lc := range cfg.MailingLists {
+		if err := a.applyList(ctx, lc); err != nil {
+			return a.changes, err
+		}
+	}
+	return a.changes, nil
+}
+
+// configApplier records the changes `ApplyAccountConfig()` makes.
+type configApplier struct {
+	mg      *MailgunImpl
+	limiter *bulkLimiter
+	dryRun  bool
+	changes []ConfigChange
+}
+
+// run makes the change, unless this is a dry run.
+func (a *configApplier) run(ctx context.Context, c ConfigChange, f func() error) error {
+	if !a.dryRun {
+		if err := a.limiter.do(ctx, f); err != nil {
+			return errors.Wrap(err, c.String())
+		}
+	}
+	a.changes = append(a.changes, c)
+	return nil
+}
+
+func (a *configApplier) applyDomain(ctx context.Context, dc DomainConfig) error {
+	mg, domain := a.mg, dc.Name
+	_, err := mg.GetDomain(ctx, domain)
+	exists := err == nil
+	if GetStatusFromErr(err) == http.StatusNotFound {
+		c := ConfigChange{Action: ConfigCreateDomain, Domain: domain}
+		err = a.run(ctx, c, func() error {
+			_, err := mg.CreateDomain(ctx, domain, &CreateDomainOptions{SpamAction: dc.SpamAction, Wildcard: dc.Wildcard})
+			return err
+		})
+	}
+	if err != nil {
+		return errors.Wrapf(err, "while fetching domain '%s'", domain)
+	}
+
+	// A domain created in a dry run has nothing to fetch; its settings are compared with zero
+	// settings instead, which updates those the configuration sets
+	var connection DomainConnection
+	var tracking DomainTracking
+	hooks := map[string][]string{}
+	if exists || !a.dryRun {
+		if connection, err = mg.GetDomainConnection(ctx, domain); err != nil {
+			return errors.Wrapf(err, "while fetching the connection settings of '%s'", domain)
+		}
+		if tracking, err = mg.GetDomainTracking(ctx, domain); err != nil {
+			return errors.Wrapf(err, "while fetching the tracking settings of '%s'", domain)
+		}
+		if hooks, err = mg.listWebhooks(ctx, domain); err != nil {
+			return errors.Wrapf(err, "while listing the webhooks of '%s'", domain)
+		}
+	}
+
+	if connection != dc.Connection {
+		c := ConfigChange{Action: ConfigUpdateConnection, Domain: domain}
+		if err := a.run(ctx, c, func() error { return mg.UpdateDomainConnection(ctx, domain, dc.Connection) }); err != nil {
+			return err
+		}
+	}
+	if tracking.Click.Active != dc.Tracking.Click.Active {
+		c := ConfigChange{Action: ConfigUpdateTracking, Domain: domain, Target: "click"}
+		if err := a.run(ctx, c, func() error {
+			return mg.UpdateClickTracking(ctx, domain, boolToString(dc.Tracking.Click.Active))
+		}); err != nil {
+			return err
+		}
+	}
+	if tracking.Open.Active != dc.Tracking.Open.Active {
+		c := ConfigChange{Action: ConfigUpdateTracking, Domain: domain, Target: "open"}
+		if err := a.run(ctx, c, func() error {
+			return mg.UpdateOpenTracking(ctx, domain, boolToString(dc.Tracking.Open.Active))
+		}); err != nil {
+			return err
+		}
+	}
+	if tracking.Unsubscribe != dc.Tracking.Unsubscribe {
+		u := dc.Tracking.Unsubscribe
+		c := ConfigChange{Action: ConfigUpdateTracking, Domain: domain, Target: "unsubscribe"}
+		if err := a.run(ctx, c, func() error {
+			return mg.UpdateUnsubscribeTracking(ctx, domain, boolToString(u.Active), u.HTMLFooter, u.TextFooter)
+		}); err != nil {
+			return err
+		}
+	}
+
+	dm := mg.forDomain(domain)
+	kinds := make([]string, 0, len(dc.Webhooks))
+	for kind := range dc.Webhooks {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		kind, urls := kind, dc.Webhooks[kind]
+		current, ok := hooks[kind]
+		if ok && reflect.DeepEqual(current, urls) {
+			continue
+		}
+		c := ConfigChange{Action: ConfigCreateWebhook, Domain: domain, Target: kind, Detail: strings.Join(urls, ", ")}
+		f := func() error { return dm.CreateWebhook(ctx, kind, urls) }
+		if ok {
+			c.Action = ConfigUpdateWebhook
+			f = func() error { return dm.UpdateWebhook(ctx, kind, urls) }
+		}
+		if err := a.run(ctx, c, f); err != nil {
+			return err
+		}
+	}
+
+	for _, tc := range dc.Templates {
+		if len(tc.Versions) == 0 {
+			continue
+		}
+		var templateChanges []TemplateChange
+		var err error
+		if !exists && a.dryRun {
+			// Every template of a domain which does not exist yet is created
+			templateChanges = []TemplateChange{{Action: TemplateSyncCreate, Template: tc.Name}}
+		} else {
+			local := make([]localTemplateVersion, len(tc.Versions))
+			for i, v := range tc.Versions {
+				local[i] = localTemplateVersion{name: tc.Name, description: tc.Description, version: v}
+			}
+			templateChanges, err = dm.syncTemplate(ctx, local, a.dryRun, nil)
+		}
+		for _, tch := range templateChanges {
+			detail := string(tch.Action)
+			if tch.Tag != "" {
+				detail += " " + tch.Tag
+			}
+			a.changes = append(a.changes, ConfigChange{Action: ConfigSyncTemplate, Domain: domain, Target: tc.Name, Detail: detail})
+		}
+		if err != nil {
+			return errors.Wrapf(err, "while syncing template '%s' of '%s'", tc.Name, domain)
+		}
+	}
+	return nil
+}
+
+// applyRoutes creates or updates the routes, matching them to those of the account by expression.
+func (a *configApplier) applyRoutes(ctx context.Context, routes []RouteConfig) error {
+	if len(routes) == 0 {
+		return nil
+	}
+	mg := a.mg
+	current, err := mg.ListRoutesAll(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "while listing routes")
+	}
+	same := func(r Route, rc RouteConfig) bool {
+		return r.Priority == rc.Priority && r.Description == rc.Description && reflect.DeepEqual(r.Actions, rc.Actions)
+	}
+	matched := make([]bool, len(current))
+	for _, rc := range routes {
+		route := Route{Priority: rc.Priority, Description: rc.Description, Expression: rc.Expression, Actions: rc.Actions}
+		// Several routes may share an expression; each is matched once, preferring an identical route
+		match := -1
+		for i, r := range current {
+			if matched[i] || r.Expression != rc.Expression {
+				continue
+			}
+			if same(r, rc) {
+				match = i
+				break
+			}
+			if match == -1 {
+				match = i
+			}
+		}
+		if match == -1 {
+			c := ConfigChange{Action: ConfigCreateRoute, Target: rc.Expression}
+			if err := a.run(ctx, c, func() error { _, err := mg.CreateRoute(ctx, route); return err }); err != nil {
+				return err
+			}
+			continue
+		}
+		matched[match] = true
+		if same(current[match], rc) {
+			continue
+		}
+		id := current[match].Id
+		c := ConfigChange{Action: ConfigUpdateRoute, Target: rc.Expression, Detail: id}
+		if err := a.run(ctx, c, func() error { return mg.replaceRoute(ctx, id, route) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *configApplier) applyList(ctx context.Context, lc ListConfig) error {
+	mg := a.mg
+	list := MailingList{
+		Address:         lc.Address,
+		Name:            lc.Name,
+		Description:     lc.Description,
+		AccessLevel:     lc.AccessLevel,
+		ReplyPreference: lc.ReplyPreference,
+	}
+	current, err := mg.GetMailingList(ctx, lc.Address)
+	if GetStatusFromErr(err) == http.StatusNotFound {
+		c := ConfigChange{Action: ConfigCreateList, Target: lc.Address}
+		return a.run(ctx, c, func() error { _, err := mg.CreateMailingList(ctx, list); return err })
+	}
+	if err != nil {
+		return errors.Wrapf(err, "while fetching mailing list '%s'", lc.Address)
+	}
+	if current.Name == lc.Name && current.Description == lc.Description &&
+		(lc.AccessLevel == "" || current.AccessLevel == lc.AccessLevel) &&
+		(lc.ReplyPreference == "" || current.ReplyPreference == lc.ReplyPreference) {
+		return nil
+	}
+	c := ConfigChange{Action: ConfigUpdateList, Target: lc.Address}
+	return a.run(ctx, c, func() error { _, err := mg.UpdateMailingList(ctx, lc.Address, list); return err })
+}
+
+// replaceRoute sets every field of the route. UpdateRoute() leaves zero fields as they are, so a
+// route moved to priority 0 is updated on its own.
+func (mg *MailgunImpl) replaceRoute(ctx context.Context, id string, route Route) error {
+	if _, err := mg.UpdateRoute(ctx, id, route); err != nil {
+		return err
+	}
+	if route.Priority == 0 {
+		return mg.setRoutePriority(ctx, id, 0)
+	}
+	return nil
+}
+
+// forDomain returns a client for domain with the settings of mg, as `clone()` copies them, for
+// the calls which act on the domain of the client, such as those of templates.
+func (mg *MailgunImpl) forDomain(domain string) *MailgunImpl {
+	if domain == mg.Domain() {
+		return mg
+	}
+	c := mg.clone()
+	c.domain = domain
+	return c
+}
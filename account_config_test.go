@@ -0,0 +1,475 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/go-chi/chi"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+// fakeAccount serves the domains, webhooks, templates, routes and mailing lists of an account.
+type fakeAccount struct {
+	domains   map[string]*fakeDomain
+	routes    []mailgun.Route
+	lists     map[string]mailgun.MailingList
+	mutations int
+}
+
+type fakeDomain struct {
+	domain     mailgun.Domain
+	connection mailgun.DomainConnection
+	tracking   mailgun.DomainTracking
+	webhooks   map[string][]string
+	templates  map[string]*fakeTemplate
+}
+
+type fakeTemplate struct {
+	description string
+	versions    []mailgun.TemplateVersion
+}
+
+func newFakeAccount() *fakeAccount {
+	return &fakeAccount{domains: map[string]*fakeDomain{}, lists: map[string]mailgun.MailingList{}}
+}
+
+func (a *fakeAccount) addDomain(name string) *fakeDomain {
+	d := &fakeDomain{
+		domain:    mailgun.Domain{Name: name, State: mailgun.DomainStateActive, SpamAction: mailgun.SpamActionDisabled},
+		webhooks:  map[string][]string{},
+		templates: map[string]*fakeTemplate{},
+	}
+	a.domains[name] = d
+	return d
+}
+
+func (t *fakeTemplate) setActive(tag string) {
+	for i := range t.versions {
+		t.versions[i].Active = t.versions[i].Tag == tag
+	}
+}
+
+func (a *fakeAccount) server() *httptest.Server {
+	write := func(w http.ResponseWriter, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	notFound := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusNotFound)
+		write(w, map[string]string{"message": "not found"})
+	}
+	domain := func(w http.ResponseWriter, r *http.Request) *fakeDomain {
+		d, ok := a.domains[chi.URLParam(r, "domain")]
+		if !ok {
+			notFound(w)
+		}
+		return d
+	}
+	template := func(w http.ResponseWriter, r *http.Request) (*fakeDomain, *fakeTemplate) {
+		d := domain(w, r)
+		if d == nil {
+			return nil, nil
+		}
+		t, ok := d.templates[chi.URLParam(r, "name")]
+		if !ok {
+			notFound(w)
+		}
+		return d, t
+	}
+	mutate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				a.mutations++
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	r := chi.NewRouter()
+	r.Use(mutate)
+	r.Route("/v3", func(r chi.Router) {
+		r.Get("/domains", func(w http.ResponseWriter, r *http.Request) {
+			var names []string
+			for name := range a.domains {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			skip, _ := strconv.Atoi(r.FormValue("skip"))
+			items := []mailgun.Domain{}
+			for _, name := range names[minInt(skip, len(names)):] {
+				items = append(items, a.domains[name].domain)
+			}
+			write(w, map[string]interface{}{"total_count": len(names), "items": items})
+		})
+		r.Post("/domains", func(w http.ResponseWriter, r *http.Request) {
+			d := a.addDomain(r.FormValue("name"))
+			d.domain.SpamAction = mailgun.SpamAction(r.FormValue("spam_action"))
+			d.domain.Wildcard = r.FormValue("wildcard") == "true"
+			write(w, map[string]interface{}{"domain": d.domain})
+		})
+		r.Get("/domains/{domain}", func(w http.ResponseWriter, r *http.Request) {
+			if d := domain(w, r); d != nil {
+				write(w, map[string]interface{}{"domain": d.domain})
+			}
+		})
+		r.Get("/domains/{domain}/connection", func(w http.ResponseWriter, r *http.Request) {
+			if d := domain(w, r); d != nil {
+				write(w, map[string]interface{}{"connection": d.connection})
+			}
+		})
+		r.Put("/domains/{domain}/connection", func(w http.ResponseWriter, r *http.Request) {
+			if d := domain(w, r); d != nil {
+				d.connection.RequireTLS = r.FormValue("require_tls") == "true"
+				d.connection.SkipVerification = r.FormValue("skip_verification") == "true"
+				write(w, map[string]string{"message": "updated"})
+			}
+		})
+		r.Get("/domains/{domain}/tracking", func(w http.ResponseWriter, r *http.Request) {
+			if d := domain(w, r); d != nil {
+				write(w, map[string]interface{}{"tracking": d.tracking})
+			}
+		})
+		r.Put("/domains/{domain}/tracking/{kind}", func(w http.ResponseWriter, r *http.Request) {
+			d := domain(w, r)
+			if d == nil {
+				return
+			}
+			active := r.FormValue("active") == "true"
+			switch chi.URLParam(r, "kind") {
+			case "click":
+				d.tracking.Click.Active = active
+			case "open":
+				d.tracking.Open.Active = active
+			case "unsubscribe":
+				d.tracking.Unsubscribe = mailgun.TrackingStatus{
+					Active:     active,
+					HTMLFooter: r.FormValue("html_footer"),
+					TextFooter: r.FormValue("text_footer"),
+				}
+			}
+			write(w, map[string]string{"message": "updated"})
+		})
+		r.Get("/domains/{domain}/webhooks", func(w http.ResponseWriter, r *http.Request) {
+			if d := domain(w, r); d != nil {
+				hooks := map[string]mailgun.UrlOrUrls{}
+				for kind, urls := range d.webhooks {
+					hooks[kind] = mailgun.UrlOrUrls{Urls: urls}
+				}
+				write(w, mailgun.WebHooksListResponse{Webhooks: hooks})
+			}
+		})
+		setWebhook := func(w http.ResponseWriter, r *http.Request, kind string) {
+			if d := domain(w, r); d != nil {
+				d.webhooks[kind] = r.Form["url"]
+				write(w, map[string]string{"message": "ok"})
+			}
+		}
+		r.Post("/domains/{domain}/webhooks", func(w http.ResponseWriter, r *http.Request) {
+			setWebhook(w, r, r.FormValue("id"))
+		})
+		r.Put("/domains/{domain}/webhooks/{kind}", func(w http.ResponseWriter, r *http.Request) {
+			r.ParseMultipartForm(1 << 20)
+			setWebhook(w, r, chi.URLParam(r, "kind"))
+		})
+
+		r.Get("/{domain}/templates", func(w http.ResponseWriter, r *http.Request) {
+			d := domain(w, r)
+			if d == nil {
+				return
+			}
+			items := []mailgun.Template{}
+			if r.FormValue("page") == "" {
+				for name, t := range d.templates {
+					items = append(items, mailgun.Template{Name: name, Description: t.description})
+				}
+			}
+			write(w, map[string]interface{}{"items": items, "paging": mailgun.Paging{Next: "http://" + r.Host + r.URL.Path + "?page=next"}})
+		})
+		r.Post("/{domain}/templates", func(w http.ResponseWriter, r *http.Request) {
+			d := domain(w, r)
+			if d == nil {
+				return
+			}
+			t := &fakeTemplate{description: r.FormValue("description")}
+			if tag := r.FormValue("tag"); tag != "" {
+				t.versions = append(t.versions, mailgun.TemplateVersion{
+					Tag:      tag,
+					Template: r.FormValue("template"),
+					Engine:   mailgun.TemplateEngine(r.FormValue("engine")),
+					Comment:  r.FormValue("comment"),
+					Active:   true,
+				})
+			}
+			d.templates[r.FormValue("name")] = t
+			write(w, map[string]interface{}{"template": map[string]interface{}{"name": r.FormValue("name")}})
+		})
+		r.Get("/{domain}/templates/{name}", func(w http.ResponseWriter, r *http.Request) {
+			if _, t := template(w, r); t != nil {
+				write(w, map[string]interface{}{"template": mailgun.Template{Name: chi.URLParam(r, "name"), Description: t.description}})
+			}
+		})
+		r.Put("/{domain}/templates/{name}", func(w http.ResponseWriter, r *http.Request) {
+			if _, t := template(w, r); t != nil {
+				t.description = r.FormValue("description")
+				write(w, map[string]string{"message": "updated"})
+			}
+		})
+		r.Get("/{domain}/templates/{name}/versions", func(w http.ResponseWriter, r *http.Request) {
+			_, t := template(w, r)
+			if t == nil {
+				return
+			}
+			versions := []mailgun.TemplateVersion{}
+			if r.FormValue("page") == "" {
+				for _, v := range t.versions {
+					v.Template = ""
+					versions = append(versions, v)
+				}
+			}
+			write(w, map[string]interface{}{
+				"template": map[string]interface{}{"versions": versions},
+				"paging":   mailgun.Paging{Next: "http://" + r.Host + r.URL.Path + "?page=next"},
+			})
+		})
+		r.Post("/{domain}/templates/{name}/versions", func(w http.ResponseWriter, r *http.Request) {
+			if _, t := template(w, r); t != nil {
+				v := mailgun.TemplateVersion{
+					Tag:      r.FormValue("tag"),
+					Template: r.FormValue("template"),
+					Engine:   mailgun.TemplateEngine(r.FormValue("engine")),
+					Comment:  r.FormValue("comment"),
+				}
+				t.versions = append(t.versions, v)
+				if r.FormValue("active") == "true" {
+					t.setActive(v.Tag)
+				}
+				write(w, map[string]interface{}{"template": map[string]interface{}{"version": v}})
+			}
+		})
+		version := func(w http.ResponseWriter, r *http.Request) (*fakeTemplate, int) {
+			_, t := template(w, r)
+			if t == nil {
+				return nil, 0
+			}
+			for i, v := range t.versions {
+				if v.Tag == chi.URLParam(r, "tag") {
+					return t, i
+				}
+			}
+			notFound(w)
+			return nil, 0
+		}
+		r.Get("/{domain}/templates/{name}/versions/{tag}", func(w http.ResponseWriter, r *http.Request) {
+			if t, i := version(w, r); t != nil {
+				write(w, map[string]interface{}{"template": map[string]interface{}{"version": t.versions[i]}})
+			}
+		})
+		r.Put("/{domain}/templates/{name}/versions/{tag}", func(w http.ResponseWriter, r *http.Request) {
+			t, i := version(w, r)
+			if t == nil {
+				return
+			}
+			if c := r.FormValue("comment"); c != "" {
+				t.versions[i].Comment = c
+			}
+			if content := r.FormValue("template"); content != "" {
+				t.versions[i].Template = content
+			}
+			if r.FormValue("active") == "true" {
+				t.setActive(t.versions[i].Tag)
+			}
+			write(w, map[string]interface{}{"template": map[string]interface{}{"version": t.versions[i]}})
+		})
+		r.Delete("/{domain}/templates/{name}/versions/{tag}", func(w http.ResponseWriter, r *http.Request) {
+			if t, i := version(w, r); t != nil {
+				t.versions = append(t.versions[:i], t.versions[i+1:]...)
+				write(w, map[string]string{"message": "deleted"})
+			}
+		})
+
+		r.Get("/routes", func(w http.ResponseWriter, r *http.Request) {
+			skip, _ := strconv.Atoi(r.FormValue("skip"))
+			items := append([]mailgun.Route{}, a.routes[minInt(skip, len(a.routes)):]...)
+			write(w, map[string]interface{}{"total_count": len(a.routes), "items": items})
+		})
+		r.Post("/routes", func(w http.ResponseWriter, r *http.Request) {
+			priority, _ := strconv.Atoi(r.FormValue("priority"))
+			route := mailgun.Route{
+				Id:          fmt.Sprintf("route-%d", len(a.routes)+1),
+				Priority:    priority,
+				Description: r.FormValue("description"),
+				Expression:  r.FormValue("expression"),
+				Actions:     r.Form["action"],
+			}
+			a.routes = append(a.routes, route)
+			write(w, map[string]interface{}{"message": "created", "route": route})
+		})
+		r.Put("/routes/{id}", func(w http.ResponseWriter, r *http.Request) {
+			for i, route := range a.routes {
+				if route.Id != chi.URLParam(r, "id") {
+					continue
+				}
+				r.ParseMultipartForm(1 << 20)
+				if p := r.FormValue("priority"); p != "" {
+					a.routes[i].Priority, _ = strconv.Atoi(p)
+				}
+				if d := r.FormValue("description"); d != "" {
+					a.routes[i].Description = d
+				}
+				if actions := r.Form["action"]; len(actions) != 0 {
+					a.routes[i].Actions = actions
+				}
+				write(w, a.routes[i])
+				return
+			}
+			notFound(w)
+		})
+
+		r.Get("/lists/pages", func(w http.ResponseWriter, r *http.Request) {
+			items := []mailgun.MailingList{}
+			if r.FormValue("page") == "" {
+				for _, l := range a.lists {
+					items = append(items, l)
+				}
+			}
+			write(w, map[string]interface{}{"items": items, "paging": mailgun.Paging{Next: "http://" + r.Host + r.URL.Path + "?page=next"}})
+		})
+		r.Get("/lists/{address}", func(w http.ResponseWriter, r *http.Request) {
+			l, ok := a.lists[chi.URLParam(r, "address")]
+			if !ok {
+				notFound(w)
+				return
+			}
+			write(w, map[string]interface{}{"member": l})
+		})
+		setList := func(w http.ResponseWriter, r *http.Request, address string) {
+			a.lists[address] = mailgun.MailingList{
+				Address:         address,
+				Name:            r.FormValue("name"),
+				Description:     r.FormValue("description"),
+				AccessLevel:     mailgun.AccessLevel(r.FormValue("access_level")),
+				ReplyPreference: mailgun.ReplyPreference(r.FormValue("reply_preference")),
+			}
+			write(w, map[string]interface{}{"list": a.lists[address]})
+		}
+		r.Post("/lists", func(w http.ResponseWriter, r *http.Request) {
+			setList(w, r, r.FormValue("address"))
+		})
+		r.Put("/lists/{address}", func(w http.ResponseWriter, r *http.Request) {
+			setList(w, r, chi.URLParam(r, "address"))
+		})
+	})
+	return httptest.NewServer(r)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func TestAccountConfig(t *testing.T) {
+	source := newFakeAccount()
+	d := source.addDomain("mg.example.com")
+	d.domain.Wildcard = true
+	d.connection = mailgun.DomainConnection{RequireTLS: true}
+	d.tracking.Open.Active = true
+	d.tracking.Unsubscribe = mailgun.TrackingStatus{Active: true, TextFooter: "Unsubscribe: %unsubscribe_url%"}
+	d.webhooks["delivered"] = []string{"https://example.com/hooks/delivered"}
+	d.templates["welcome"] = &fakeTemplate{
+		description: "Sent on sign up",
+		versions: []mailgun.TemplateVersion{
+			{Tag: "v1", Template: "<p>Hi {{name}}</p>", Engine: mailgun.TemplateEngineHandlebars},
+			{Tag: "v2", Template: "<p>Welcome {{name}}</p>", Engine: mailgun.TemplateEngineHandlebars, Comment: "Warmer", Active: true},
+		},
+	}
+	source.routes = []mailgun.Route{
+		{Id: "a1", Priority: 0, Expression: `match_recipient("support@mg.example.com")`, Actions: []string{`forward("https://example.com/support")`}},
+		{Id: "a2", Priority: 1, Description: "Catch all", Expression: "catch_all()", Actions: []string{"stop()"}},
+	}
+	source.lists["news@mg.example.com"] = mailgun.MailingList{
+		Address:     "news@mg.example.com",
+		Name:        "News",
+		AccessLevel: mailgun.AccessLevelReadOnly,
+	}
+	srcSrv := source.server()
+	defer srcSrv.Close()
+	src := mailgun.NewMailgun("mg.example.com", testKey)
+	src.SetAPIBase(srcSrv.URL + "/v3")
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	exported, err := src.ExportAccountConfig(ctx, &buf)
+	ensure.Nil(t, err)
+	cfg, err := mailgun.ReadAccountConfig(&buf)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, cfg.Version, mailgun.AccountConfigVersion)
+	ensure.DeepEqual(t, len(cfg.Domains), 1)
+	ensure.DeepEqual(t, cfg.Domains[0].Webhooks, d.webhooks)
+	ensure.DeepEqual(t, len(cfg.Domains[0].Templates), 1)
+	ensure.DeepEqual(t, cfg.Domains[0].Templates[0].Versions[1].Template, "<p>Welcome {{name}}</p>")
+	ensure.DeepEqual(t, len(cfg.Routes), 2)
+	ensure.DeepEqual(t, cfg.MailingLists, []mailgun.ListConfig{{Address: "news@mg.example.com", Name: "News", AccessLevel: mailgun.AccessLevelReadOnly}})
+
+	// Clone the configuration into an empty account
+	target := newFakeAccount()
+	dstSrv := target.server()
+	defer dstSrv.Close()
+	dst := mailgun.NewMailgun("other.example.com", testKey)
+	dst.SetAPIBase(dstSrv.URL + "/v3")
+
+	plan, err := dst.ApplyAccountConfig(ctx, cfg, &mailgun.ApplyConfigOptions{DryRun: true})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, target.mutations, 0)
+	var actions []string
+	for _, c := range plan {
+		actions = append(actions, string(c.Action)+" "+c.Target)
+	}
+	ensure.DeepEqual(t, actions, []string{
+		"create-domain ",
+		"update-connection ",
+		"update-tracking open",
+		"update-tracking unsubscribe",
+		"create-webhook delivered",
+		"sync-template welcome",
+		"create-route " + `match_recipient("support@mg.example.com")`,
+		"create-route catch_all()",
+		"create-list news@mg.example.com",
+	})
+
+	changes, err := dst.ApplyAccountConfig(ctx, cfg, nil)
+	ensure.Nil(t, err)
+	ensure.True(t, len(changes) >= len(plan))
+
+	cloned, err := dst.ExportAccountConfig(ctx, nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, cloned.Domains, exported.Domains)
+	ensure.DeepEqual(t, cloned.Routes, exported.Routes)
+	ensure.DeepEqual(t, cloned.MailingLists, exported.MailingLists)
+
+	// Applying the configuration again changes nothing
+	mutations := target.mutations
+	changes, err = dst.ApplyAccountConfig(ctx, cfg, nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(changes), 0)
+	ensure.DeepEqual(t, target.mutations, mutations)
+
+	// Drift is put back
+	target.domains["mg.example.com"].webhooks["delivered"] = []string{"https://old.example.com"}
+	target.routes[1].Priority = 5
+	changes, err = dst.ApplyAccountConfig(ctx, cfg, nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(changes), 2)
+	ensure.DeepEqual(t, changes[0].Action, mailgun.ConfigUpdateWebhook)
+	ensure.DeepEqual(t, changes[1].Action, mailgun.ConfigUpdateRoute)
+	ensure.DeepEqual(t, target.routes[1].Priority, 1)
+}
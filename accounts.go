@@ -0,0 +1,128 @@
+package mailgun
+
+import (
+	"context"
+	"strconv"
+)
+
+// Account holds the settings of the Mailgun account which owns the API key.
+type Account struct {
+	ID                     string `json:"id"`
+	Name                   string `json:"name"`
+	InactiveSessionTimeout int    `json:"inactive_session_timeout"`
+	AbsoluteSessionTimeout int    `json:"absolute_session_timeout"`
+	LogoutRedirectURL      string `json:"logout_redirect_url"`
+}
+
+type accountResponse struct {
+	Account Account `json:"account"`
+}
+
+// UpdateAccountOptions lists the account settings to change with UpdateAccount().
+// Fields left at their zero value are not changed.
+type UpdateAccountOptions struct {
+	Name string
+	// Session timeouts in seconds for users logged into the control panel
+	InactiveSessionTimeout int
+	AbsoluteSessionTimeout int
+	LogoutRedirectURL      string
+}
+
+type signingKeyResponse struct {
+	Message    string `json:"message"`
+	SigningKey string `json:"http_signing_key"`
+}
+
+// AuthorizedRecipient is an address a sandbox domain may send to.
+type AuthorizedRecipient struct {
+	Email     string      `json:"email"`
+	Activated bool        `json:"activated"`
+	CreatedAt RFC2822Time `json:"created_at"`
+}
+
+type authorizedRecipientResponse struct {
+	Recipient AuthorizedRecipient `json:"recipient"`
+}
+
+type authorizedRecipientListResponse struct {
+	Recipients []AuthorizedRecipient `json:"recipients"`
+}
+
+// GetAccount returns the settings of the account.
+func (mg *MailgunImpl) GetAccount(ctx context.Context) (Account, error) {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, accountsEndpoint))
+
+	var resp accountResponse
+	err := getResponseFromJSON(ctx, r, &resp)
+	return resp.Account, err
+}
+
+// UpdateAccount changes the settings of the account.
+func (mg *MailgunImpl) UpdateAccount(ctx context.Context, opts UpdateAccountOptions) error {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, accountsEndpoint))
+
+	p := newPayload()
+	if opts.Name != "" {
+		p.addValue("name", opts.Name)
+	}
+	if opts.InactiveSessionTimeout != 0 {
+		p.addValue("inactive_session_timeout", strconv.Itoa(opts.InactiveSessionTimeout))
+	}
+	if opts.AbsoluteSessionTimeout != 0 {
+		p.addValue("absolute_session_timeout", strconv.Itoa(opts.AbsoluteSessionTimeout))
+	}
+	if opts.LogoutRedirectURL != "" {
+		p.addValue("logout_redirect_url", opts.LogoutRedirectURL)
+	}
+	_, err := makePutRequest(ctx, r, p)
+	return err
+}
+
+// GetWebhookSigningKey returns the HTTP webhook signing key of the account, which is used
+// to verify webhook requests with NewWebhookDispatcher() or VerifyWebhookSignature().
+func (mg *MailgunImpl) GetWebhookSigningKey(ctx context.Context) (string, error) {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, accountsEndpoint)+"/http_signing_key")
+
+	var resp signingKeyResponse
+	err := getResponseFromJSON(ctx, r, &resp)
+	return resp.SigningKey, err
+}
+
+// RegenerateWebhookSigningKey replaces the HTTP webhook signing key of the account and returns
+// the new key. Webhook requests signed with the old key no longer verify.
+func (mg *MailgunImpl) RegenerateWebhookSigningKey(ctx context.Context) (string, error) {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, accountsEndpoint)+"/http_signing_key")
+
+	var resp signingKeyResponse
+	err := postResponseFromJSON(ctx, r, newPayload(), &resp)
+	return resp.SigningKey, err
+}
+
+// ListAuthorizedRecipients returns the addresses sandbox domains of the account may send to.
+func (mg *MailgunImpl) ListAuthorizedRecipients(ctx context.Context) ([]AuthorizedRecipient, error) {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, sandboxEndpoint)+"/auth_recipients")
+
+	var resp authorizedRecipientListResponse
+	if err := getResponseFromJSON(ctx, r, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Recipients, nil
+}
+
+// AddAuthorizedRecipient authorizes sandbox domains to send to the address. Mailgun emails the
+// address asking for consent; the recipient is not Activated until it is given.
+func (mg *MailgunImpl) AddAuthorizedRecipient(ctx context.Context, email string) (AuthorizedRecipient, error) {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, sandboxEndpoint)+"/auth_recipients")
+	r.addParameter("email", email)
+
+	var resp authorizedRecipientResponse
+	err := postResponseFromJSON(ctx, r, newPayload(), &resp)
+	return resp.Recipient, err
+}
+
+// DeleteAuthorizedRecipient removes the address from the authorized recipients of sandbox domains.
+func (mg *MailgunImpl) DeleteAuthorizedRecipient(ctx context.Context, email string) error {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, sandboxEndpoint)+"/auth_recipients/"+pathEscape(email))
+	_, err := makeDeleteRequest(ctx, r)
+	return err
+}
@@ -0,0 +1,70 @@
+package mailgun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestAccount(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	err := mg.UpdateAccount(ctx, mailgun.UpdateAccountOptions{
+		Name:                   "Renamed",
+		InactiveSessionTimeout: 600,
+	})
+	ensure.Nil(t, err)
+
+	account, err := mg.GetAccount(ctx)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, account.Name, "Renamed")
+	ensure.DeepEqual(t, account.InactiveSessionTimeout, 600)
+	ensure.DeepEqual(t, account.AbsoluteSessionTimeout, 86400)
+}
+
+func TestWebhookSigningKey(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	key, err := mg.GetWebhookSigningKey(ctx)
+	ensure.Nil(t, err)
+	ensure.True(t, key != "")
+
+	newKey, err := mg.RegenerateWebhookSigningKey(ctx)
+	ensure.Nil(t, err)
+	ensure.True(t, newKey != key)
+
+	key, err = mg.GetWebhookSigningKey(ctx)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, key, newKey)
+}
+
+func TestAuthorizedRecipients(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	email := randomEmail("recipient", testDomain)
+	recipient, err := mg.AddAuthorizedRecipient(ctx, email)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, recipient.Email, email)
+	ensure.False(t, recipient.Activated)
+
+	recipients, err := mg.ListAuthorizedRecipients(ctx)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(recipients), 1)
+	ensure.DeepEqual(t, recipients[0].Email, email)
+
+	ensure.Nil(t, mg.DeleteAuthorizedRecipient(ctx, email))
+	recipients, err = mg.ListAuthorizedRecipients(ctx)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(recipients), 0)
+
+	err = mg.DeleteAuthorizedRecipient(ctx, email)
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), 404)
+}
@@ -0,0 +1,85 @@
+// Package addresses parses and normalizes email addresses, so that the different ways of writing
+// the same mailbox compare equal. It is used by the mailgun package when adding list members,
+// storing suppressions and matching webhook recipients.
+//
+//  a, _ := addresses.Normalize("Bob <Bob.Smith+news@GoogleMail.com>", &addresses.Options{GmailDots: true, PlusTags: true})
+//  // a == "bobsmith@gmail.com"
+package addresses
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// Options enable optional normalizations, which map addresses only some providers treat as the
+// same mailbox onto one another.
+type Options struct {
+	// GmailDots removes dots from the local part of gmail.com addresses, and rewrites googlemail.com to gmail.com.
+	GmailDots bool
+	// PlusTags removes a +tag suffix from the local part, so bob+news@example.com becomes bob@example.com.
+	PlusTags bool
+	// Unicode returns internationalized domains in Unicode rather than punycode.
+	Unicode bool
+}
+
+// Split parses an address, with or without a display name, into its local part and domain.
+func Split(address string) (local, domain string, err error) {
+	address = strings.TrimSpace(address)
+	if a, err := mail.ParseAddress(address); err == nil {
+		address = a.Address
+	} else if strings.ContainsAny(address, "<>") {
+		return "", "", fmt.Errorf("invalid address '%s': %s", address, err)
+	}
+
+	at := strings.LastIndex(address, "@")
+	if at <= 0 || at == len(address)-1 {
+		return "", "", fmt.Errorf("invalid address '%s'", address)
+	}
+	return address[:at], address[at+1:], nil
+}
+
+// Normalize returns the bare address of the mailbox with the display name removed, lower-cased,
+// and its domain in punycode, such as "bob@xn--bcher-kva.example" for "Bob <Bob@Bücher.example>".
+// Opts may be nil.
+func Normalize(address string, opts *Options) (string, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	local, domain, err := Split(address)
+	if err != nil {
+		return "", err
+	}
+
+	local = strings.ToLower(local)
+	if domain, err = ToASCII(strings.TrimSuffix(domain, ".")); err != nil {
+		return "", err
+	}
+
+	if opts.PlusTags {
+		if i := strings.Index(local, "+"); i > 0 {
+			local = local[:i]
+		}
+	}
+	if opts.GmailDots && (domain == "gmail.com" || domain == "googlemail.com") {
+		local = strings.Replace(local, ".", "", -1)
+		domain = "gmail.com"
+	}
+	if opts.Unicode {
+		if domain, err = ToUnicode(domain); err != nil {
+			return "", err
+		}
+	}
+	return local + "@" + domain, nil
+}
+
+// Equal reports whether the addresses refer to the same mailbox once normalized.
+// Invalid addresses are only equal to themselves.
+func Equal(a, b string, opts *Options) bool {
+	na, errA := Normalize(a, opts)
+	nb, errB := Normalize(b, opts)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return na == nb
+}
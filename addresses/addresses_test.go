@@ -0,0 +1,66 @@
+package addresses_test
+
+import (
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4/addresses"
+)
+
+func TestNormalize(t *testing.T) {
+	all := &addresses.Options{GmailDots: true, PlusTags: true}
+	for _, tt := range []struct {
+		in   string
+		opts *addresses.Options
+		want string
+	}{
+		{in: "  Bob@Example.COM ", want: "bob@example.com"},
+		{in: `"Bob Smith" <Bob@Example.com>`, want: "bob@example.com"},
+		{in: "bob+news@example.com", want: "bob+news@example.com"},
+		{in: "bob+news@example.com", opts: all, want: "bob@example.com"},
+		{in: "Bob.Smith@gmail.com", want: "bob.smith@gmail.com"},
+		{in: "Bob.Smith+x@GoogleMail.com", opts: all, want: "bobsmith@gmail.com"},
+		{in: "bob.smith@example.com", opts: all, want: "bob.smith@example.com"},
+		{in: "bob@example.com.", want: "bob@example.com"},
+		{in: "bob@Bücher.example", want: "bob@xn--bcher-kva.example"},
+		{in: "bob@xn--bcher-kva.example", opts: &addresses.Options{Unicode: true}, want: "bob@bücher.example"},
+		{in: "user@例え.jp", want: "user@xn--r8jz45g.jp"},
+	} {
+		got, err := addresses.Normalize(tt.in, tt.opts)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, got, tt.want)
+	}
+
+	for _, in := range []string{"", "bob", "@example.com", "bob@", "Bob <bob@example.com"} {
+		_, err := addresses.Normalize(in, nil)
+		ensure.NotNil(t, err)
+	}
+}
+
+func TestPunycode(t *testing.T) {
+	// Samples from RFC 3492 section 7.1
+	for unicode, ascii := range map[string]string{
+		"ليهمابتكلموشعربي؟":      "xn--egbpdaj6bu4bxfgehfvwxn",
+		"他们为什么不说中文":              "xn--ihqwcrb4cv8a8dqg056pqjye",
+		"pročprostěnemluvíčesky": "xn--proprostnemluvesky-uyb24dma41a",
+		"münchen":                "xn--mnchen-3ya",
+	} {
+		got, err := addresses.ToASCII(unicode)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, got, ascii)
+
+		got, err = addresses.ToUnicode(ascii)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, got, unicode)
+	}
+
+	_, err := addresses.ToUnicode("xn--a-!")
+	ensure.NotNil(t, err)
+}
+
+func TestEqual(t *testing.T) {
+	ensure.True(t, addresses.Equal("Bob <BOB@example.com>", "bob@Example.com", nil))
+	ensure.False(t, addresses.Equal("bob+a@example.com", "bob@example.com", nil))
+	ensure.True(t, addresses.Equal("bob+a@example.com", "bob@example.com", &addresses.Options{PlusTags: true}))
+	ensure.False(t, addresses.Equal("not an address", "bob@example.com", nil))
+}
@@ -0,0 +1,110 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PolicyAttachment is an attachment or inline of a message, as inspected by an AttachmentPolicy.
+type PolicyAttachment struct {
+	Filename    string
+	ContentType string
+	Inline      bool
+	Data        []byte
+}
+
+// AttachmentPolicy inspects an attachment before the message is sent, and returns an error to
+// veto the send, such as when the attachment is of a type your application does not send, or a
+// virus scanner flags it.
+type AttachmentPolicy func(ctx context.Context, a PolicyAttachment) error
+
+// PolicyViolation is returned by `Send()` when an AttachmentPolicy vetoes the message; nothing is
+// sent. Err is the error of the policy.
+type PolicyViolation struct {
+	Filename string
+	Err      error
+}
+
+func (e *PolicyViolation) Error() string {
+	return fmt.Sprintf("attachment '%s' violates the attachment policy: %s", e.Filename, e.Err)
+}
+
+// Unwrap returns the error of the policy.
+func (e *PolicyViolation) Unwrap() error {
+	return e.Err
+}
+
+// SetAttachmentPolicies sets the policies `Send()` checks each attachment and inline of a message
+// against, in order, before sending it. Attachments added from readers are read to be inspected.
+// Pass no policies to remove them. MIME messages are sent as they are, without being inspected.
+//
+//  mg.SetAttachmentPolicies(
+//    mailgun.AllowAttachmentExtensions(".pdf", ".png", ".jpg"),
+//    mailgun.MaxAttachmentSize(10<<20),
+//    func(ctx context.Context, a mailgun.PolicyAttachment) error {
+//      return scanner.Scan(ctx, a.Data)
+//    },
+//  )
+func (mg *MailgunImpl) SetAttachmentPolicies(policies ...AttachmentPolicy) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.attachmentPolicies = append([]AttachmentPolicy(nil), policies...)
+}
+
+// AllowAttachmentExtensions returns a policy which vetoes attachments whose file extension, ignoring
+// case, is not one of exts, such as ".pdf".
+func AllowAttachmentExtensions(exts ...string) AttachmentPolicy {
+	return func(ctx context.Context, a PolicyAttachment) error {
+		ext := path.Ext(a.Filename)
+		for _, e := range exts {
+			if strings.EqualFold(ext, e) {
+				return nil
+			}
+		}
+		return fmt.Errorf("extension '%s' is not allowed", ext)
+	}
+}
+
+// MaxAttachmentSize returns a policy which vetoes attachments larger than size bytes.
+func MaxAttachmentSize(size int) AttachmentPolicy {
+	return func(ctx context.Context, a PolicyAttachment) error {
+		if len(a.Data) > size {
+			return fmt.Errorf("size %d exceeds the limit of %d bytes", len(a.Data), size)
+		}
+		return nil
+	}
+}
+
+// checkAttachments returns a *PolicyViolation if a policy of the client vetoes an attachment or
+// inline of the payload.
+func (mg *MailgunImpl) checkAttachments(ctx context.Context, p *formDataPayload) error {
+	mg.mu.RLock()
+	policies := mg.attachmentPolicies
+	mg.mu.RUnlock()
+	if len(policies) == 0 {
+		return nil
+	}
+
+	if err := p.bufferFiles(); err != nil {
+		return err
+	}
+	for _, b := range p.Buffers {
+		if b.key != "attachment" && b.key != "inline" {
+			continue
+		}
+		a := PolicyAttachment{
+			Filename:    b.name,
+			ContentType: p.contentType(b.name, b.value),
+			Inline:      b.key == "inline",
+			Data:        b.value,
+		}
+		for _, policy := range policies {
+			if err := policy(ctx, a); err != nil {
+				return &PolicyViolation{Filename: b.name, Err: err}
+			}
+		}
+	}
+	return nil
+}
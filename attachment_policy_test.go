@@ -0,0 +1,70 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestAttachmentPolicies(t *testing.T) {
+	var sent int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		fmt.Fprint(w, `{"message":"Queued. Thank you.", "id":"<id@example.com>"}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	var scanned []string
+	infected := errors.New("EICAR test signature found")
+	mg.SetAttachmentPolicies(
+		mailgun.AllowAttachmentExtensions(".pdf", ".txt"),
+		mailgun.MaxAttachmentSize(1024),
+		func(ctx context.Context, a mailgun.PolicyAttachment) error {
+			scanned = append(scanned, a.Filename+" "+a.ContentType)
+			if bytes.Contains(a.Data, []byte("EICAR")) {
+				return infected
+			}
+			return nil
+		},
+	)
+	send := func(filename string, data []byte) error {
+		m := mg.NewMessage("from@example.com", "Report", "Attached", "joe@example.com")
+		m.AddReaderAttachment(filename, ioutil.NopCloser(bytes.NewReader(data)))
+		_, _, err := mg.Send(ctx, m)
+		return err
+	}
+
+	ensure.Nil(t, send("report.PDF", []byte("%PDF-1.4")))
+	ensure.DeepEqual(t, scanned, []string{"report.PDF application/pdf"})
+	ensure.DeepEqual(t, sent, 1)
+
+	var violation *mailgun.PolicyViolation
+	err := send("setup.exe", []byte("MZ"))
+	ensure.True(t, errors.As(err, &violation))
+	ensure.DeepEqual(t, violation.Filename, "setup.exe")
+	ensure.StringContains(t, err.Error(), "extension '.exe' is not allowed")
+
+	err = send("big.txt", make([]byte, 2048))
+	ensure.True(t, errors.As(err, &violation))
+	ensure.StringContains(t, err.Error(), "exceeds the limit of 1024 bytes")
+
+	err = send("notes.txt", []byte("X5O!P%@AP EICAR"))
+	ensure.True(t, errors.Is(err, infected))
+	ensure.DeepEqual(t, sent, 1)
+
+	mg.SetAttachmentPolicies()
+	ensure.Nil(t, send("setup.exe", []byte("MZ")))
+	ensure.DeepEqual(t, sent, 2)
+}
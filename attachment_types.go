@@ -0,0 +1,67 @@
+package mailgun
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strings"
+)
+
+// sniffLen is the number of bytes http.DetectContentType looks at.
+const sniffLen = 512
+
+// SetAttachmentContentType sets the content type an attachment or inline of the message is sent
+// with, by the filename it is sent as; for images added with `AddInlineImage()` that is the
+// Content-ID it returns. Attachments without a content type of their own are sent with the type
+// their extension maps to, or else the type detected from their first bytes by
+// http.DetectContentType(), rather than as application/octet-stream.
+//
+//  m.AddBufferAttachment("invoice", pdf)
+//  m.SetAttachmentContentType("invoice", "application/pdf")
+func (m *Message) SetAttachmentContentType(filename, contentType string) {
+	if m.contentTypes == nil {
+		m.contentTypes = make(map[string]string)
+	}
+	m.contentTypes[filename] = contentType
+}
+
+// setContentType sets the content type of the files of the payload with the name.
+func (f *formDataPayload) setContentType(name, contentType string) {
+	if f.contentTypes == nil {
+		f.contentTypes = make(map[string]string)
+	}
+	f.contentTypes[name] = contentType
+}
+
+// contentType returns the content type of the file of the payload with the name, whose content
+// starts with head: the one set for it, or else one guessed from its name or head.
+func (f *formDataPayload) contentType(name string, head []byte) string {
+	if t, ok := f.contentTypes[name]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	if len(head) != 0 {
+		return http.DetectContentType(head)
+	}
+	return "application/octet-stream"
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// createFormFile works as multipart.Writer.CreateFormFile, but sets the content type of the part.
+func createFormFile(w *multipart.Writer, key, name, contentType string) (io.Writer, error) {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition",
+		fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(key), quoteEscaper.Replace(name)))
+	h.Set("Content-Type", contentType)
+	return w.CreatePart(h)
+}
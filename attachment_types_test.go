@@ -0,0 +1,54 @@
+package mailgun
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestAttachmentContentTypes(t *testing.T) {
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com")
+	m.AddBufferAttachment("report.pdf", []byte("%PDF-1.4"))
+	m.AddBufferAttachment("scan", []byte("\x89PNG\r\n\x1a\n"))
+	m.AddReaderAttachment("notes", ioutil.NopCloser(strings.NewReader("plain text notes")))
+	m.AddBufferAttachment("invoice", []byte("<xml/>"))
+	m.SetAttachmentContentType("invoice", "application/vnd.example+xml")
+	m.AddBufferAttachment("empty", nil)
+
+	payload := newFormDataPayload()
+	ensure.Nil(t, m.addValues(payload))
+	body, err := payload.getPayloadBuffer()
+	ensure.Nil(t, err)
+
+	_, params, err := mime.ParseMediaType(payload.getContentType())
+	ensure.Nil(t, err)
+	types := map[string]string{}
+	r := multipart.NewReader(bytes.NewReader(body.Bytes()), params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		if p.FileName() != "" {
+			types[p.FileName()] = p.Header.Get("Content-Type")
+		}
+	}
+	ensure.DeepEqual(t, types, map[string]string{
+		"report.pdf": "application/pdf",
+		"scan":       "image/png",
+		"notes":      "text/plain; charset=utf-8",
+		"invoice":    "application/vnd.example+xml",
+		"empty":      "application/octet-stream",
+	})
+
+	// Overrides are kept by FormFiles(), so messages sent from them get the same types
+	files, err := m.FormFiles()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, files[len(files)-2].ContentType, "application/vnd.example+xml")
+}
@@ -0,0 +1,128 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// An AuditEvent records a request of the client which changes something at Mailgun, such as
+// creating a mailing list, updating a member, deleting a domain, or sending a message.
+type AuditEvent struct {
+	// Operation is "create", "update" or "delete", from the method of the request.
+	Operation string
+	// Method and URL are those of the request.
+	Method string
+	URL    string
+	// Endpoint is the kind of resource changed, such as "lists", "routes" or "domains".
+	Endpoint string
+	// Resource is the path of the resource after the API version, such as
+	// "lists/dev@example.com/members/joe@example.com".
+	Resource string
+	// Actor and Metadata are those attached to the context with `WithAuditActor()`.
+	Actor    string
+	Metadata map[string]string
+	// Status is the status code of the response; it is zero if no response was received.
+	Status int
+	// Err is the error which kept the request from completing, if any. Responses with an
+	// error status code have a nil Err; see Succeeded().
+	Err  error
+	Time time.Time
+}
+
+// Succeeded reports whether Mailgun accepted the change.
+func (e AuditEvent) Succeeded() bool {
+	return e.Err == nil && e.Status >= 200 && e.Status < 300
+}
+
+// AuditHook is called with the event of each request which changes something at Mailgun, once
+// the request completes. The context is the one of the request.
+type AuditHook func(ctx context.Context, e AuditEvent)
+
+// SetAuditHook sets a hook called for every POST, PUT and DELETE request the client makes, with
+// what was changed, who changed it and whether it succeeded, so platform teams can keep a change
+// log of what was done through the client. Messages sent are included; filter them out by their
+// Endpoint of "messages" or "messages.mime" if they are not wanted. The hook is called in the
+// goroutine of the request, so hand events to a queue if recording them is slow. Pass nil to
+// remove the hook.
+//
+//  mg.SetAuditHook(func(ctx context.Context, e mailgun.AuditEvent) {
+//    log.Printf("%s %s %s by %s: %d %v", e.Operation, e.Endpoint, e.Resource, e.Actor, e.Status, e.Err)
+//  })
+//  ctx = mailgun.WithAuditActor(ctx, "alice@example.com", map[string]string{"ticket": "OPS-42"})
+//  err := mg.DeleteMember(ctx, "joe@example.com", "dev@example.com")
+func (mg *MailgunImpl) SetAuditHook(h AuditHook) {
+	mg.mu.Lock()
+	mg.auditHook = h
+	mg.mu.Unlock()
+}
+
+type auditKey struct{}
+
+type auditActor struct {
+	actor    string
+	metadata map[string]string
+}
+
+// WithAuditActor returns a context which attributes the requests made with it to actor, such as
+// the user or service on whose behalf they are made, along with metadata such as a ticket or
+// request id, in the events passed to the audit hook.
+func WithAuditActor(ctx context.Context, actor string, metadata map[string]string) context.Context {
+	return context.WithValue(ctx, auditKey{}, auditActor{actor: actor, metadata: metadata})
+}
+
+// auditOperation returns the operation of a request made with method; ok is false for methods
+// which change nothing.
+func auditOperation(method string) (op string, ok bool) {
+	switch method {
+	case http.MethodPost:
+		return "create", true
+	case http.MethodPut, http.MethodPatch:
+		return "update", true
+	case http.MethodDelete:
+		return "delete", true
+	}
+	return "", false
+}
+
+// auditResource returns the path of the resource at address after the API version.
+func auditResource(address string) string {
+	u, err := url.Parse(address)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, p := range parts {
+		if apiVersionSuffix.MatchString("/" + p) {
+			return strings.Join(parts[i+1:], "/")
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// audit passes the outcome of the request to the audit hook, if the request changes something.
+func (r *httpRequest) audit(ctx context.Context, method string, status int, err error) {
+	h := r.options.onAudit
+	if h == nil {
+		return
+	}
+	op, ok := auditOperation(method)
+	if !ok {
+		return
+	}
+	actor, _ := ctx.Value(auditKey{}).(auditActor)
+	h(ctx, AuditEvent{
+		Operation: op,
+		Method:    method,
+		URL:       r.URL,
+		Endpoint:  cacheEndpoint(r.URL),
+		Resource:  auditResource(r.URL),
+		Actor:     actor.actor,
+		Metadata:  actor.metadata,
+		Status:    status,
+		Err:       err,
+		Time:      time.Now(),
+	})
+}
@@ -0,0 +1,60 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestAuditHook(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Member not found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"message": "ok", "list": {"address": "dev@example.com"}, "items": [], "paging": {}}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	var audited []mailgun.AuditEvent
+	mg.SetAuditHook(func(ctx context.Context, e mailgun.AuditEvent) {
+		audited = append(audited, e)
+	})
+
+	ctx := mailgun.WithAuditActor(context.Background(), "alice", map[string]string{"ticket": "OPS-42"})
+	_, err := mg.CreateMailingList(ctx, mailgun.MailingList{Address: "dev@example.com"})
+	ensure.Nil(t, err)
+	var lists []mailgun.MailingList
+	mg.ListMailingLists(nil).Next(ctx, &lists)
+	err = mg.DeleteMember(context.Background(), "joe@example.com", "dev@example.com")
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusNotFound)
+
+	ensure.DeepEqual(t, len(audited), 2)
+	ensure.DeepEqual(t, audited[0].Operation, "create")
+	ensure.DeepEqual(t, audited[0].Endpoint, "lists")
+	ensure.DeepEqual(t, audited[0].Resource, "lists")
+	ensure.DeepEqual(t, audited[0].Actor, "alice")
+	ensure.DeepEqual(t, audited[0].Metadata["ticket"], "OPS-42")
+	ensure.DeepEqual(t, audited[0].Status, http.StatusOK)
+	ensure.True(t, audited[0].Succeeded())
+
+	ensure.DeepEqual(t, audited[1].Operation, "delete")
+	ensure.DeepEqual(t, audited[1].Resource, "lists/dev@example.com/members/joe@example.com")
+	ensure.DeepEqual(t, audited[1].Actor, "")
+	ensure.DeepEqual(t, audited[1].Status, http.StatusNotFound)
+	ensure.False(t, audited[1].Succeeded())
+
+	audited = nil
+	mg.SetAuditHook(nil)
+	_, err = mg.CreateMailingList(ctx, mailgun.MailingList{Address: "dev@example.com"})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(audited), 0)
+}
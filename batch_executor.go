@@ -0,0 +1,257 @@
+package mailgun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BatchMode selects what a BatchExecutor does when an item fails.
+type BatchMode int
+
+const (
+	// ContinueOnError runs every item, however many fail.
+	ContinueOnError BatchMode = iota
+	// FailFast stops the batch at the first failure: the context passed to the items still
+	// running is cancelled, and items not yet started are not run.
+	FailFast
+)
+
+// ErrBatchAborted is the error of the items a batch did not run after it stopped early, because
+// an item of a FailFast batch failed or the batch exceeded its MaxErrorRate.
+var ErrBatchAborted = errors.New("batch aborted after an earlier item failed")
+
+// BatchExecutor runs many items of work, such as API requests, with a fixed number of workers.
+// The bulk helpers of the client, such as `CreateDomainsBatch()`, are built on it; use it to run
+// batches of your own.
+//
+//  exec := mailgun.BatchExecutor{Concurrency: 8, Mode: mailgun.ContinueOnError}
+//  err := exec.Run(ctx, len(addresses), func(ctx context.Context, i int) error {
+//    return mg.DeleteMember(ctx, addresses[i], "list@example.com")
+//  })
+//  if merr, ok := err.(*mailgun.MultiError); ok {
+//    for _, e := range merr.Errors {
+//      log.Printf("%s: %s", addresses[e.Index], e.Err)
+//    }
+//  }
+type BatchExecutor struct {
+	// Concurrency is the number of items run at once; defaults to 1.
+	Concurrency int
+	Mode        BatchMode
+	// MaxErrorRate, if set, stops a ContinueOnError batch as FailFast would once the ratio of the
+	// items run which failed exceeds it, such as 0.2 for a fifth. The ratio is only checked once
+	// MinErrorSamples items have run; defaults to DefaultErrorRateSamples.
+	MaxErrorRate    float64
+	MinErrorSamples int
+
+	// outcome, if set, is shared by the runs of a helper which processes a stream a page at a
+	// time, so the error rate spans the pages.
+	outcome *batchOutcome
+}
+
+// Run calls fn for each index from 0 to n-1, with up to Concurrency calls at once, and waits for
+// them to return. It returns nil if every item succeeds, or a *MultiError holding the error of
+// each item which failed. If ctx is cancelled, items not yet started are not run, and fail with
+// the context's error. A negative n is an error.
+func (b BatchExecutor) Run(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	_, err := b.RunSummary(ctx, n, fn)
+	return err
+}
+
+// RunSummary runs the batch as `Run()` does, and also returns the number of items which
+// succeeded, failed and were not run, with the reason the batch stopped early: the error of the
+// failed item of a FailFast batch, an *ErrorRateError if MaxErrorRate was exceeded, or the error
+// of ctx.
+func (b BatchExecutor) RunSummary(ctx context.Context, n int, fn func(ctx context.Context, i int) error) (BulkSummary, error) {
+	if n < 0 {
+		return BulkSummary{}, fmt.Errorf("invalid batch size %d", n)
+	}
+	outcome := b.outcome
+	if outcome == nil {
+		outcome = b.newOutcome()
+	}
+	workers := b.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, n)
+	started := make([]bool, n)
+	var mu sync.Mutex
+	var next int
+	var failed bool
+	var reason error
+	take := func() (int, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if next >= n || failed || ctx.Err() != nil {
+			return 0, false
+		}
+		started[next] = true
+		next++
+		return next - 1, true
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i, ok := take()
+				if !ok {
+					return
+				}
+				errs[i] = fn(ctx, i)
+				mu.Lock()
+				if !failed {
+					if err := outcome.record(1, errs[i]); err != nil {
+						failed, reason = true, err
+						cancel()
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var summary BulkSummary
+	var merr MultiError
+	for i, err := range errs {
+		switch {
+		case !started[i]:
+			summary.Skipped++
+		case err != nil:
+			summary.Failed++
+		default:
+			summary.Succeeded++
+		}
+		switch {
+		case err != nil:
+		case started[i]:
+			continue
+		case failed:
+			err = ErrBatchAborted
+		default:
+			err = ctx.Err()
+			reason = err
+		}
+		merr.Errors = append(merr.Errors, ItemError{Index: i, Err: err})
+	}
+	summary.AbortReason = reason
+	if len(merr.Errors) == 0 {
+		return summary, nil
+	}
+	return summary, &merr
+}
+
+// batchOutcome counts the items of a batch as they complete, and decides when the batch stops:
+// at the first failure of a FailFast batch, or once the ratio of failures exceeds MaxErrorRate.
+// The helpers which process streams, whose items are not known in advance, such as
+// `ImportMembersCSV()`, count their items with it as `RunSummary()` does.
+type batchOutcome struct {
+	mode  BatchMode
+	limit *errorRateLimit
+}
+
+func (b BatchExecutor) newOutcome() *batchOutcome {
+	return &batchOutcome{mode: b.Mode, limit: newErrorRateLimit(b.MaxErrorRate, b.MinErrorSamples)}
+}
+
+// record counts n items which completed with err, and returns the reason the batch must stop, if
+// it must: err for a FailFast batch, or an *ErrorRateError.
+func (o *batchOutcome) record(n int, err error) error {
+	if err != nil && o.mode == FailFast {
+		return err
+	}
+	return o.limit.record(n, err != nil)
+}
+
+// tolerate counts n items which failed in a way the batch carries on past in either mode, such
+// as rows of a file which cannot be parsed, and returns an *ErrorRateError if the ratio of
+// failures now exceeds MaxErrorRate.
+func (o *batchOutcome) tolerate(n int) error {
+	return o.limit.record(n, true)
+}
+
+// revise counts n items recorded as succeeded as failed with err instead, for items whose failure
+// is only known later, such as rows of a batch rejected once sent, and returns the reason the
+// batch must stop, as `record()` does.
+func (o *batchOutcome) revise(n int, err error) error {
+	if o.mode == FailFast {
+		return err
+	}
+	return o.limit.revise(n)
+}
+
+// ItemError is the error of one item of a batch.
+type ItemError struct {
+	// Index is the index of the item passed to the batch function.
+	Index int
+	Err   error
+}
+
+func (e ItemError) Error() string {
+	return fmt.Sprintf("item %d: %s", e.Index, e.Err)
+}
+
+// Unwrap returns the error of the item.
+func (e ItemError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is returned by `BatchExecutor.Run()` when items of the batch fail. Errors holds the
+// error of each item which failed, ordered by index.
+type MultiError struct {
+	Errors []ItemError
+}
+
+func (m *MultiError) Error() string {
+	if len(m.Errors) == 1 {
+		return m.Errors[0].Error()
+	}
+	msgs := make([]string, len(m.Errors))
+	for i, e := range m.Errors {
+		msgs[i] = e.Error()
+	}
+	return fmt.Sprintf("%d items failed: %s", len(m.Errors), strings.Join(msgs, "; "))
+}
+
+// Is reports whether the error of any item matches target, so `errors.Is()` matches any of them.
+func (m *MultiError) Is(target error) bool {
+	for _, e := range m.Errors {
+		if errors.Is(e.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of an item which matches target, so `errors.As()` matches any of them.
+func (m *MultiError) As(target interface{}) bool {
+	for _, e := range m.Errors {
+		if errors.As(e.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the error of the item at index i, or nil if it succeeded.
+func (m *MultiError) Err(i int) error {
+	j := sort.Search(len(m.Errors), func(j int) bool { return m.Errors[j].Index >= i })
+	if j < len(m.Errors) && m.Errors[j].Index == i {
+		return m.Errors[j].Err
+	}
+	return nil
+}
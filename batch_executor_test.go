@@ -0,0 +1,128 @@
+package mailgun_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestBatchExecutorContinueOnError(t *testing.T) {
+	var mu sync.Mutex
+	var active, maxActive int
+	ran := make([]bool, 10)
+
+	exec := mailgun.BatchExecutor{Concurrency: 3}
+	err := exec.Run(context.Background(), len(ran), func(ctx context.Context, i int) error {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		ran[i] = true
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+
+		if i%4 == 1 {
+			return fmt.Errorf("failed %d", i)
+		}
+		return nil
+	})
+	ensure.True(t, maxActive <= 3)
+	for i := range ran {
+		ensure.True(t, ran[i])
+	}
+
+	merr, ok := err.(*mailgun.MultiError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, len(merr.Errors), 3)
+	ensure.DeepEqual(t, merr.Errors[0].Index, 1)
+	ensure.DeepEqual(t, merr.Errors[2].Index, 9)
+	ensure.DeepEqual(t, merr.Err(5).Error(), "failed 5")
+	ensure.Nil(t, merr.Err(4))
+	ensure.DeepEqual(t, merr.Error(), "3 items failed: item 1: failed 1; item 5: failed 5; item 9: failed 9")
+
+	ensure.Nil(t, exec.Run(context.Background(), 5, func(ctx context.Context, i int) error { return nil }))
+	ensure.Nil(t, exec.Run(context.Background(), 0, nil))
+	ensure.NotNil(t, exec.Run(context.Background(), -1, nil))
+}
+
+func TestBatchExecutorFailFast(t *testing.T) {
+	errBoom := errors.New("boom")
+	started := make(chan struct{})
+	exec := mailgun.BatchExecutor{Concurrency: 2, Mode: mailgun.FailFast}
+	err := exec.Run(context.Background(), 10, func(ctx context.Context, i int) error {
+		switch i {
+		case 0:
+			<-started
+			return errBoom
+		case 1:
+			// Still running when item 0 fails; its context is cancelled
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	merr, ok := err.(*mailgun.MultiError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, merr.Err(0), errBoom)
+	ensure.DeepEqual(t, merr.Err(1), context.Canceled)
+	for i := 2; i < 10; i++ {
+		ensure.DeepEqual(t, merr.Err(i), mailgun.ErrBatchAborted)
+	}
+	// The errors of all the items are matched
+	ensure.True(t, errors.Is(err, errBoom))
+	ensure.True(t, errors.Is(err, mailgun.ErrBatchAborted))
+	ensure.False(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestBatchExecutorCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	exec := mailgun.BatchExecutor{Concurrency: 1}
+	err := exec.Run(ctx, 3, func(ctx context.Context, i int) error {
+		cancel()
+		return nil
+	})
+
+	merr, ok := err.(*mailgun.MultiError)
+	ensure.True(t, ok)
+	ensure.Nil(t, merr.Err(0))
+	ensure.DeepEqual(t, merr.Err(1), context.Canceled)
+	ensure.DeepEqual(t, merr.Err(2), context.Canceled)
+}
+
+func TestBatchExecutorMaxErrorRate(t *testing.T) {
+	exec := mailgun.BatchExecutor{Concurrency: 1, MaxErrorRate: 0.5, MinErrorSamples: 4}
+	summary, err := exec.RunSummary(context.Background(), 20, func(ctx context.Context, i int) error {
+		if i < 5 {
+			return nil
+		}
+		return fmt.Errorf("failed %d", i)
+	})
+
+	// Item 10 is the sixth failure in eleven items, more than half
+	ensure.DeepEqual(t, summary.Succeeded, 5)
+	ensure.DeepEqual(t, summary.Failed, 6)
+	ensure.DeepEqual(t, summary.Skipped, 9)
+	ensure.DeepEqual(t, summary.AbortReason, &mailgun.ErrorRateError{Failed: 6, Processed: 11, MaxErrorRate: 0.5})
+	ensure.DeepEqual(t, summary.ErrorRate(), 6.0/11)
+
+	merr, ok := err.(*mailgun.MultiError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, merr.Err(10).Error(), "failed 10")
+	ensure.DeepEqual(t, merr.Err(11), mailgun.ErrBatchAborted)
+
+	summary, err = exec.RunSummary(context.Background(), 6, func(ctx context.Context, i int) error { return nil })
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, summary, mailgun.BulkSummary{Succeeded: 6})
+}
@@ -0,0 +1,183 @@
+package mailgun
+
+import (
+	"context"
+	"sort"
+
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// Statuses of the recipients of a batch send, as reported by RecipientResult.
+const (
+	// RecipientQueued recipients were accepted by the messages API, but no event has been seen for them yet.
+	RecipientQueued = "queued"
+	// RecipientAccepted recipients have an accepted event.
+	RecipientAccepted = "accepted"
+	// RecipientDeferred recipients have a temporary failure, and Mailgun will try delivering again.
+	RecipientDeferred = "deferred"
+	// RecipientDelivered recipients have a delivered event.
+	RecipientDelivered = "delivered"
+	// RecipientFailed recipients have a permanent failure, or were rejected.
+	RecipientFailed = "failed"
+)
+
+// BatchResult is the outcome of a batch send made with `SendBatch()`, with the status of each recipient.
+type BatchResult struct {
+	// Message and ID are the response of the messages API, as returned by `Send()`. Batches sent
+	// as several messages, because of `SetRecipientTracking()`, have the ID of the first.
+	Message string
+	ID      string
+	// Recipients are the To: recipients of the message, in the order they were added.
+	Recipients []RecipientResult
+}
+
+// RecipientResult is the status of a single recipient of a batch send.
+type RecipientResult struct {
+	// Address is the normalized address of the recipient.
+	Address string
+	// MessageID is the ID of the message sent to the recipient, if it differs from the ID of the batch.
+	MessageID string
+	// Status is one of RecipientQueued, RecipientAccepted, RecipientDeferred, RecipientDelivered
+	// or RecipientFailed.
+	Status string
+	// Reason describes a failure, if any.
+	Reason string
+	// Event is the latest event which set the status, or nil.
+	Event Event
+}
+
+// recipientStatusRanks orders the statuses, so an event never moves a recipient back to an earlier one.
+var recipientStatusRanks = map[string]int{
+	RecipientQueued:    0,
+	RecipientAccepted:  1,
+	RecipientDeferred:  2,
+	RecipientDelivered: 3,
+	RecipientFailed:    3,
+}
+
+// SendBatch sends a message as `Send()` does, and returns the status of each of its recipients.
+// Mailgun queues all recipients of a message or none of them, so every recipient starts out as
+// RecipientQueued; use `UpdateBatchResult()` to follow their progress from the events API.
+// Messages with tracking overrides set by `SetRecipientTracking()` are sent as several messages;
+// if one fails, the result holds the recipients of those which were sent, with the error.
+func (mg *MailgunImpl) SendBatch(ctx context.Context, m *Message) (*BatchResult, error) {
+	parts := []*Message{m}
+	if len(m.recipientTracking) != 0 {
+		var err error
+		if parts, err = m.splitByTracking(); err != nil {
+			return nil, err
+		}
+	}
+
+	var result *BatchResult
+	seen := make(map[string]bool, len(m.to))
+	for _, part := range parts {
+		mes, id, err := mg.Send(ctx, part)
+		if err != nil {
+			return result, err
+		}
+		var messageID string
+		if result == nil {
+			result = &BatchResult{Message: mes, ID: id}
+		} else {
+			messageID = id
+		}
+		for _, to := range part.to {
+			address := normalizeAddress(to)
+			if seen[address] {
+				continue
+			}
+			seen[address] = true
+			result.Recipients = append(result.Recipients, RecipientResult{Address: address, MessageID: messageID, Status: RecipientQueued})
+		}
+	}
+	return result, nil
+}
+
+// UpdateBatchResult fetches the events of the message sent with `SendBatch()`, and applies them
+// to the status of its recipients with `BatchResult.Apply()`.
+func (mg *MailgunImpl) UpdateBatchResult(ctx context.Context, result *BatchResult) error {
+	ids := []string{result.ID}
+	for _, r := range result.Recipients {
+		if r.MessageID != "" && !containsFold(ids, r.MessageID) {
+			ids = append(ids, r.MessageID)
+		}
+	}
+	for _, id := range ids {
+		it := mg.ListEvents(&ListEventOptions{
+			Filter: map[string]string{"message-id": MessageID(id)},
+		})
+		var page []Event
+		for it.Next(ctx, &page) {
+			result.Apply(page)
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply updates the status of the recipients from the accepted, delivered, failed and rejected
+// events among evs, correlated by message ID and recipient. Other events, and events of other
+// messages, are ignored.
+func (b *BatchResult) Apply(evs []Event) {
+	sorted := append([]Event(nil), evs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].GetTimestamp().Before(sorted[j].GetTimestamp())
+	})
+
+	for _, e := range sorted {
+		switch e := e.(type) {
+		case *events.Accepted:
+			b.update(e.Message.Headers.MessageID, e.Recipient, RecipientAccepted, "", e)
+		case *events.Delivered:
+			b.update(e.Message.Headers.MessageID, e.Recipient, RecipientDelivered, "", e)
+		case *events.Failed:
+			status := RecipientFailed
+			if e.Severity == "temporary" {
+				status = RecipientDeferred
+			}
+			reason := e.Reason
+			if e.DeliveryStatus.Description != "" {
+				reason = e.DeliveryStatus.Description
+			} else if e.DeliveryStatus.Message != "" {
+				reason = e.DeliveryStatus.Message
+			}
+			b.update(e.Message.Headers.MessageID, e.Recipient, status, reason, e)
+		case *events.Rejected:
+			for i := range b.Recipients {
+				b.update(e.Message.Headers.MessageID, b.Recipients[i].Address, RecipientFailed, e.Reject.Reason, e)
+			}
+		}
+	}
+}
+
+// update sets the status of the recipient of the message, unless it already has a later one.
+func (b *BatchResult) update(messageID, recipient, status, reason string, e Event) {
+	address := normalizeAddress(recipient)
+	for i := range b.Recipients {
+		r := &b.Recipients[i]
+		if r.Address != address || b.recipientMessageID(r) != messageID || recipientStatusRanks[status] < recipientStatusRanks[r.Status] {
+			continue
+		}
+		if recipientStatusRanks[r.Status] == recipientStatusRanks[RecipientFailed] && r.Status != status {
+			// Delivered and failed are both final; keep the first seen
+			continue
+		}
+		r.Status, r.Reason, r.Event = status, reason, e
+	}
+}
+
+// recipientMessageID returns the ID of the message sent to the recipient as events report it.
+func (b *BatchResult) recipientMessageID(r *RecipientResult) string {
+	if r.MessageID != "" {
+		return MessageID(r.MessageID)
+	}
+	return b.messageID()
+}
+
+// messageID returns the ID of the message as events report it, without angle brackets.
+func (b *BatchResult) messageID() string {
+	return MessageID(b.ID)
+}
@@ -0,0 +1,138 @@
+package mailgun_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+func TestSendBatch(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	m := mg.NewMessage("root@"+testDomain, "Subject", "Text Body", "Batch <Batch@Mailgun.Test>")
+	result, err := mg.SendBatch(ctx, m)
+	ensure.Nil(t, err)
+	ensure.True(t, result.ID != "")
+	ensure.DeepEqual(t, len(result.Recipients), 1)
+	ensure.DeepEqual(t, result.Recipients[0].Address, "batch@mailgun.test")
+	ensure.DeepEqual(t, result.Recipients[0].Status, mailgun.RecipientQueued)
+
+	ensure.Nil(t, mg.UpdateBatchResult(ctx, result))
+	ensure.DeepEqual(t, result.Recipients[0].Status, mailgun.RecipientAccepted)
+}
+
+func TestSendBatchRecipientTracking(t *testing.T) {
+	type request struct {
+		to, cc, clicks, opens, attachment string
+		vars                              map[string]interface{}
+	}
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ensure.Nil(t, r.ParseMultipartForm(1<<20))
+		f, err := r.MultipartForm.File["attachment"][0].Open()
+		ensure.Nil(t, err)
+		data, err := ioutil.ReadAll(f)
+		ensure.Nil(t, err)
+		req := request{
+			to:         strings.Join(r.MultipartForm.Value["to"], ","),
+			cc:         r.FormValue("cc"),
+			clicks:     r.FormValue("o:tracking-clicks"),
+			opens:      r.FormValue("o:tracking-opens"),
+			attachment: string(data),
+		}
+		ensure.Nil(t, json.Unmarshal([]byte(r.FormValue("recipient-variables")), &req.vars))
+		requests = append(requests, req)
+		fmt.Fprintf(w, `{"id": "<batch-%d@example.com>", "message": "Queued. Thank you."}`, len(requests))
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	m := mg.NewMessage("root@"+testDomain, "Subject", "Text Body")
+	m.SetTrackingClicks(true)
+	m.AddCC("cc@example.com")
+	m.AddReaderAttachment("report.txt", ioutil.NopCloser(strings.NewReader("report")))
+	for _, to := range []string{"one@example.com", "private@example.com", "two@example.com", "clicks@example.com"} {
+		ensure.Nil(t, m.AddRecipientAndVariables(to, map[string]interface{}{"name": to}))
+	}
+	m.SetRecipientTracking("Private@Example.com", false, false)
+	m.SetRecipientTracking("clicks@example.com", true, false)
+
+	_, _, err := mg.Send(context.Background(), m)
+	ensure.NotNil(t, err)
+
+	result, err := mg.SendBatch(context.Background(), m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, requests, []request{
+		{to: "one@example.com,two@example.com", cc: "cc@example.com", clicks: "yes", attachment: "report",
+			vars: map[string]interface{}{"one@example.com": map[string]interface{}{"name": "one@example.com"}, "two@example.com": map[string]interface{}{"name": "two@example.com"}}},
+		{to: "private@example.com", clicks: "no", opens: "no", attachment: "report",
+			vars: map[string]interface{}{"private@example.com": map[string]interface{}{"name": "private@example.com"}}},
+		{to: "clicks@example.com", clicks: "yes", opens: "no", attachment: "report",
+			vars: map[string]interface{}{"clicks@example.com": map[string]interface{}{"name": "clicks@example.com"}}},
+	})
+	ensure.DeepEqual(t, result.ID, "<batch-1@example.com>")
+	ensure.DeepEqual(t, result.Recipients, []mailgun.RecipientResult{
+		{Address: "one@example.com", Status: mailgun.RecipientQueued},
+		{Address: "two@example.com", Status: mailgun.RecipientQueued},
+		{Address: "private@example.com", MessageID: "<batch-2@example.com>", Status: mailgun.RecipientQueued},
+		{Address: "clicks@example.com", MessageID: "<batch-3@example.com>", Status: mailgun.RecipientQueued},
+	})
+
+	delivered := &events.Delivered{Recipient: "private@example.com"}
+	delivered.Message.Headers.MessageID = "batch-2@example.com"
+	result.Apply([]mailgun.Event{delivered})
+	ensure.DeepEqual(t, result.Recipients[2].Status, mailgun.RecipientDelivered)
+}
+
+func TestBatchResultApply(t *testing.T) {
+	result := &mailgun.BatchResult{
+		ID: "<batch-id@example.com>",
+		Recipients: []mailgun.RecipientResult{
+			{Address: "one@example.com", Status: mailgun.RecipientQueued},
+			{Address: "two@example.com", Status: mailgun.RecipientQueued},
+			{Address: "three@example.com", Status: mailgun.RecipientQueued},
+		},
+	}
+
+	now := time.Now()
+	event := func(e mailgun.Event, recipient string, at time.Duration) mailgun.Event {
+		e.SetTimestamp(now.Add(at))
+		switch e := e.(type) {
+		case *events.Delivered:
+			e.Recipient, e.Message.Headers.MessageID = recipient, "batch-id@example.com"
+		case *events.Failed:
+			e.Recipient, e.Message.Headers.MessageID = recipient, "batch-id@example.com"
+		}
+		return e
+	}
+	temporary := &events.Failed{Severity: "temporary", Reason: "greylisted"}
+	permanent := &events.Failed{Severity: "permanent", DeliveryStatus: events.DeliveryStatus{Description: "No such mailbox"}}
+	other := &events.Delivered{Recipient: "three@example.com"}
+	other.Message.Headers.MessageID = "other-id@example.com"
+
+	// Events arrive newest first from the events API
+	result.Apply([]mailgun.Event{
+		event(&events.Delivered{}, "ONE@example.com", 2*time.Second),
+		event(temporary, "one@example.com", time.Second),
+		event(permanent, "two@example.com", time.Second),
+		other,
+	})
+
+	ensure.DeepEqual(t, result.Recipients[0].Status, mailgun.RecipientDelivered)
+	ensure.DeepEqual(t, result.Recipients[1].Status, mailgun.RecipientFailed)
+	ensure.DeepEqual(t, result.Recipients[1].Reason, "No such mailbox")
+	ensure.DeepEqual(t, result.Recipients[2].Status, mailgun.RecipientQueued)
+}
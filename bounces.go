@@ -2,7 +2,6 @@ package mailgun
 
 import (
 	"context"
-	"strconv"
 )
 
 // Bounce aggregates data relating to undeliverable messages to a specific intended recipient,
@@ -35,14 +34,13 @@ type bouncesListResponse struct {
 // and the slice of bounces specified, if successful.
 // Note that the length of the slice may be smaller than the total number of bounces.
 func (mg *MailgunImpl) ListBounces(opts *ListOptions) *BouncesIterator {
-	r := newHTTPRequest(generateApiUrl(mg, bouncesEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, bouncesEndpoint))
+	var limit int
 	if opts != nil {
-		if opts.Limit != 0 {
-			r.addParameter("limit", strconv.Itoa(opts.Limit))
-		}
+		r.addParameters(opts.Params)
+		limit = opts.Limit
 	}
+	mg.addPageLimit(r, limit, 0)
 	url, err := r.generateUrlWithParameters()
 	return &BouncesIterator{
 		mg:                  mg,
@@ -141,18 +139,12 @@ func (ci *BouncesIterator) Previous(ctx context.Context, items *[]Bounce) bool {
 }
 
 func (ci *BouncesIterator) fetch(ctx context.Context, url string) error {
-	r := newHTTPRequest(url)
-	r.setClient(ci.mg.Client())
-	r.setBasicAuth(basicAuthUser, ci.mg.APIKey())
-
-	return getResponseFromJSON(ctx, r, &ci.bouncesListResponse)
+	return fetchPage(ctx, ci.mg, url, &ci.bouncesListResponse)
 }
 
 // GetBounce retrieves a single bounce record, if any exist, for the given recipient address.
 func (mg *MailgunImpl) GetBounce(ctx context.Context, address string) (Bounce, error) {
-	r := newHTTPRequest(generateApiUrl(mg, bouncesEndpoint) + "/" + address)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, bouncesEndpoint)+"/"+pathEscape(address))
 
 	var response Bounce
 	err := getResponseFromJSON(ctx, r, &response)
@@ -176,11 +168,9 @@ func (mg *MailgunImpl) GetBounce(ctx context.Context, address string) (Bounce, e
 // Note that both code and error exist as strings, even though
 // code will report as a number.
 func (mg *MailgunImpl) AddBounce(ctx context.Context, address, code, error string) error {
-	r := newHTTPRequest(generateApiUrl(mg, bouncesEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, bouncesEndpoint))
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 	payload.addValue("address", address)
 	if code != "" {
 		payload.addValue("code", code)
@@ -194,18 +184,14 @@ func (mg *MailgunImpl) AddBounce(ctx context.Context, address, code, error strin
 
 // DeleteBounce removes all bounces associted with the provided e-mail address.
 func (mg *MailgunImpl) DeleteBounce(ctx context.Context, address string) error {
-	r := newHTTPRequest(generateApiUrl(mg, bouncesEndpoint) + "/" + address)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, bouncesEndpoint)+"/"+pathEscape(address))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
 
 // DeleteBounceList removes all bounces in the bounce list
 func (mg *MailgunImpl) DeleteBounceList(ctx context.Context) error {
-	r := newHTTPRequest(generateApiUrl(mg, bouncesEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, bouncesEndpoint))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
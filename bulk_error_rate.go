@@ -0,0 +1,98 @@
+package mailgun
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultErrorRateSamples is the number of items a bulk helper processes before its MaxErrorRate
+// is enforced, unless its options set another, so a failure among the first items does not abort
+// the operation on its own.
+const DefaultErrorRateSamples = 10
+
+// BulkSummary is the outcome of a bulk operation, such as `CreateDomainsBatchWithOptions()` or
+// `SyncSuppressionsWithOptions()`.
+type BulkSummary struct {
+	// Succeeded and Failed are the number of items processed which succeeded and failed.
+	Succeeded int
+	Failed    int
+	// Skipped is the number of items not processed because the operation stopped early, where
+	// known; helpers reading a stream, such as `ImportMembersCSV()`, do not know how many remain.
+	Skipped int
+	// AbortReason is why the operation stopped early, such as an *ErrorRateError, or nil if it
+	// processed every item.
+	AbortReason error
+}
+
+// Aborted reports whether the operation stopped before processing every item.
+func (s BulkSummary) Aborted() bool {
+	return s.AbortReason != nil
+}
+
+// ErrorRate returns the ratio of the items processed which failed.
+func (s BulkSummary) ErrorRate() float64 {
+	return rate(s.Failed, s.Succeeded+s.Failed)
+}
+
+// ErrorRateError is the reason a bulk operation stopped when the ratio of its items which failed
+// exceeded its MaxErrorRate.
+type ErrorRateError struct {
+	Failed    int
+	Processed int
+	// MaxErrorRate is the ratio of failures the operation allowed.
+	MaxErrorRate float64
+}
+
+func (e *ErrorRateError) Error() string {
+	return fmt.Sprintf("aborted after %d of %d items failed, exceeding the maximum error rate of %g",
+		e.Failed, e.Processed, e.MaxErrorRate)
+}
+
+// errorRateLimit counts the outcome of the items of a bulk operation, and reports when the
+// ratio of failures exceeds max. A max of zero disables the limit.
+type errorRateLimit struct {
+	max        float64
+	minSamples int
+
+	mu        sync.Mutex
+	succeeded int
+	failed    int
+}
+
+func newErrorRateLimit(max float64, minSamples int) *errorRateLimit {
+	if minSamples <= 0 {
+		minSamples = DefaultErrorRateSamples
+	}
+	return &errorRateLimit{max: max, minSamples: minSamples}
+}
+
+// record counts n items which succeeded or failed, and returns an *ErrorRateError if the ratio
+// of failures now exceeds the limit.
+func (l *errorRateLimit) record(n int, failed bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if failed {
+		l.failed += n
+	} else {
+		l.succeeded += n
+	}
+	return l.check()
+}
+
+// revise counts n items recorded as succeeded as failed instead, for items whose failure is only
+// known later, such as rows of a batch rejected once sent.
+func (l *errorRateLimit) revise(n int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.succeeded -= n
+	l.failed += n
+	return l.check()
+}
+
+func (l *errorRateLimit) check() error {
+	processed := l.succeeded + l.failed
+	if l.max <= 0 || processed < l.minSamples || rate(l.failed, processed) <= l.max {
+		return nil
+	}
+	return &ErrorRateError{Failed: l.failed, Processed: processed, MaxErrorRate: l.max}
+}
@@ -0,0 +1,178 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// bulkMaxConcurrency is the most requests the bulk helpers of a client make at once.
+	bulkMaxConcurrency = 16
+	// bulkMaxInterval is the longest delay 429 responses may push between bulk requests.
+	bulkMaxInterval = 30 * time.Second
+)
+
+// bulkInterval is the minimum delay between the requests the bulk helpers of a client start.
+var bulkInterval = listAllInterval
+
+// BulkStats describes the pace of the bulk helpers of a client, such as `ImportMembersCSV()`,
+// `CreateDomainsBatch()`, `SyncSuppressions()` and the ListAll methods. The pace is shared by all
+// the helpers of the client, and adapts to 429 Too Many Requests responses: each one halves the
+// number of requests made at once and doubles the delay between them, and the pace recovers
+// gradually as requests succeed.
+type BulkStats struct {
+	// Concurrency is the most requests the helpers currently make at once.
+	Concurrency int
+	// Interval is the current minimum delay between the requests they start.
+	Interval time.Duration
+	// Rate is the effective number of requests started per second allowed by Interval.
+	Rate float64
+	// Requests is the number of requests made, and Throttled the number rejected with 429.
+	Requests  int
+	Throttled int
+}
+
+// BulkStats returns the current pace of the bulk helpers of the client.
+func (mg *MailgunImpl) BulkStats() BulkStats {
+	return mg.bulkLimiter().stats()
+}
+
+// bulkLimiter returns the limiter shared by the bulk helpers of the client.
+func (mg *MailgunImpl) bulkLimiter() *bulkLimiter {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	if mg.bulk == nil {
+		mg.bulk = newBulkLimiter()
+		mg.bulk.clock = mg.clock()
+	}
+	return mg.bulk
+}
+
+// limiterFor returns the bulk limiter of mg, or a new one if mg is not a `*MailgunImpl`.
+func limiterFor(mg Mailgun) *bulkLimiter {
+	if impl, ok := mg.(*MailgunImpl); ok {
+		return impl.bulkLimiter()
+	}
+	return newBulkLimiter()
+}
+
+// bulkLimiter paces bulk requests, decreasing concurrency and increasing the interval between
+// requests multiplicatively on 429 responses, and recovering additively on success.
+type bulkLimiter struct {
+	clock       clock
+	mu          sync.Mutex
+	changed     chan struct{}
+	concurrency int
+	interval    time.Duration
+	active      int
+	last        time.Time
+	successes   int
+	requests    int
+	throttled   int
+}
+
+func newBulkLimiter() *bulkLimiter {
+	return &bulkLimiter{
+		clock:       systemClock{},
+		changed:     make(chan struct{}),
+		concurrency: bulkMaxConcurrency,
+		interval:    bulkInterval,
+	}
+}
+
+// acquire waits until a request may be started, and must be followed by a call to release.
+func (l *bulkLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.active >= l.concurrency {
+			changed := l.changed
+			l.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-changed:
+			}
+			continue
+		}
+		wait := l.interval - l.clock.Now().Sub(l.last)
+		if wait <= 0 {
+			l.active++
+			l.requests++
+			l.last = l.clock.Now()
+			l.mu.Unlock()
+			return ctx.Err()
+		}
+		l.mu.Unlock()
+		if err := l.clock.Sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// release records the outcome of a request started with acquire, and adjusts the pace.
+func (l *bulkLimiter) release(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+
+	minInterval := bulkInterval
+	if err != nil && GetStatusFromErr(err) == http.StatusTooManyRequests {
+		l.throttled++
+		l.successes = 0
+		if l.concurrency /= 2; l.concurrency < 1 {
+			l.concurrency = 1
+		}
+		if l.interval *= 2; l.interval > bulkMaxInterval {
+			l.interval = bulkMaxInterval
+		}
+		if l.interval < minInterval {
+			l.interval = minInterval
+		}
+	} else if err == nil {
+		// Recover one step once a round of requests at the current concurrency has succeeded
+		if l.successes++; l.successes >= l.concurrency {
+			l.successes = 0
+			if l.concurrency < bulkMaxConcurrency {
+				l.concurrency++
+			}
+			if l.interval -= l.interval / 4; l.interval < minInterval {
+				l.interval = minInterval
+			}
+		}
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// do calls f once the limiter allows, retrying up to listAllMaxRetries times while f fails with
+// 429 Too Many Requests.
+func (l *bulkLimiter) do(ctx context.Context, f func() error) error {
+	for retries := 0; ; retries++ {
+		if err := l.acquire(ctx); err != nil {
+			return err
+		}
+		err := f()
+		l.release(err)
+		if err == nil || GetStatusFromErr(err) != http.StatusTooManyRequests || retries >= listAllMaxRetries {
+			return err
+		}
+	}
+}
+
+func (l *bulkLimiter) stats() BulkStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := BulkStats{
+		Concurrency: l.concurrency,
+		Interval:    l.interval,
+		Requests:    l.requests,
+		Throttled:   l.throttled,
+	}
+	if l.interval > 0 {
+		s.Rate = float64(time.Second) / float64(l.interval)
+	}
+	return s
+}
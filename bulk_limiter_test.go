@@ -0,0 +1,125 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestBulkLimiterAdapts(t *testing.T) {
+	defer func(d time.Duration) { bulkInterval = d }(bulkInterval)
+	bulkInterval = time.Millisecond
+
+	l := newBulkLimiter()
+	tooMany := &UnexpectedResponseError{Actual: http.StatusTooManyRequests}
+
+	ctx := context.Background()
+	ensure.Nil(t, l.acquire(ctx))
+	l.release(tooMany)
+	ensure.Nil(t, l.acquire(ctx))
+	l.release(tooMany)
+
+	stats := l.stats()
+	ensure.DeepEqual(t, stats.Concurrency, bulkMaxConcurrency/4)
+	ensure.DeepEqual(t, stats.Interval, 4*time.Millisecond)
+	ensure.DeepEqual(t, stats.Rate, 250.0)
+	ensure.DeepEqual(t, stats.Requests, 2)
+	ensure.DeepEqual(t, stats.Throttled, 2)
+
+	// A round of successes at the current concurrency recovers one step
+	for i := 0; i < bulkMaxConcurrency/4; i++ {
+		ensure.Nil(t, l.acquire(ctx))
+		l.release(nil)
+	}
+	stats = l.stats()
+	ensure.DeepEqual(t, stats.Concurrency, bulkMaxConcurrency/4+1)
+	ensure.DeepEqual(t, stats.Interval, 3*time.Millisecond)
+
+	// Errors other than 429 leave the pace alone
+	l.release(&UnexpectedResponseError{Actual: http.StatusBadRequest})
+	ensure.DeepEqual(t, l.stats().Concurrency, bulkMaxConcurrency/4+1)
+
+	for i := 0; i < 20; i++ {
+		l.release(tooMany)
+	}
+	stats = l.stats()
+	ensure.DeepEqual(t, stats.Concurrency, 1)
+	ensure.DeepEqual(t, stats.Interval, bulkMaxInterval)
+}
+
+func TestBulkLimiterConcurrency(t *testing.T) {
+	defer func(d time.Duration) { bulkInterval = d }(bulkInterval)
+	bulkInterval = 0
+
+	l := newBulkLimiter()
+	l.concurrency = 2
+
+	var mu sync.Mutex
+	var active, maxActive int
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ensure.Nil(t, l.do(context.Background(), func() error {
+				mu.Lock()
+				active++
+				if active > maxActive {
+					maxActive = active
+				}
+				mu.Unlock()
+				time.Sleep(2 * time.Millisecond)
+				mu.Lock()
+				active--
+				mu.Unlock()
+				return nil
+			}))
+		}()
+	}
+	wg.Wait()
+	// Concurrency recovers by one after each round of successes
+	ensure.True(t, maxActive <= 4)
+
+	l.concurrency, l.active = 1, 1
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ensure.DeepEqual(t, l.acquire(ctx), context.DeadlineExceeded)
+}
+
+func TestImportMembersCSVRateLimited(t *testing.T) {
+	defer func(d time.Duration) { bulkInterval = d }(bulkInterval)
+	bulkInterval = time.Millisecond
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"message": "Too many requests"}`)
+			return
+		}
+		fmt.Fprint(w, `{"message": "Mailing list has been updated"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	csv := "address,name\njoe@example.com,Joe\njane@example.com,Jane\n"
+	result, err := mg.ImportMembersCSV(context.Background(), "list@example.com", strings.NewReader(csv), ImportOptions{})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, result.Imported, 2)
+	ensure.DeepEqual(t, calls, 2)
+
+	stats := mg.BulkStats()
+	ensure.DeepEqual(t, stats.Requests, 2)
+	ensure.DeepEqual(t, stats.Throttled, 1)
+	ensure.DeepEqual(t, stats.Concurrency, bulkMaxConcurrency/2)
+}
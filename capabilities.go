@@ -0,0 +1,75 @@
+package mailgun
+
+// EndpointFamily is a family of endpoints of the Mailgun API a client supports, as reported by
+// `Capabilities()`.
+type EndpointFamily string
+
+const (
+	FamilyMessages     EndpointFamily = "messages"
+	FamilyDomains      EndpointFamily = "domains"
+	FamilyLists        EndpointFamily = "lists"
+	FamilyEvents       EndpointFamily = "events"
+	FamilyValidation   EndpointFamily = "validation"
+	FamilyAnalytics    EndpointFamily = "analytics"
+	FamilySuppressions EndpointFamily = "suppressions"
+	FamilyRoutes       EndpointFamily = "routes"
+	FamilyWebhooks     EndpointFamily = "webhooks"
+	FamilyTemplates    EndpointFamily = "templates"
+	FamilyCredentials  EndpointFamily = "credentials"
+	FamilyIPs          EndpointFamily = "ips"
+	FamilyExports      EndpointFamily = "exports"
+	FamilyAccounts     EndpointFamily = "accounts"
+)
+
+// mailgunFamilies are the endpoint families of `MailgunImpl`. Validation has a client of its own,
+// `EmailValidatorImpl`.
+var mailgunFamilies = []EndpointFamily{
+	FamilyAccounts, FamilyAnalytics, FamilyCredentials, FamilyDomains, FamilyEvents, FamilyExports,
+	FamilyIPs, FamilyLists, FamilyMessages, FamilyRoutes, FamilySuppressions, FamilyTemplates,
+	FamilyWebhooks,
+}
+
+// Capabilities describes what a client of this build of the library supports, so frameworks which
+// embed it can detect features at run time rather than assume them at compile time.
+type Capabilities struct {
+	// Version is the version of the library, Version.
+	Version string
+	// APIBase is the API base the client makes requests to.
+	APIBase string
+	// Families are the endpoint families the client supports, sorted by name. Analytics covers
+	// stats and tags, and suppressions covers bounces, unsubscribes and complaints.
+	Families []EndpointFamily
+}
+
+// Supports reports whether the client supports the endpoint family.
+func (c Capabilities) Supports(family EndpointFamily) bool {
+	for _, f := range c.Families {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+// CapabilityReporter is implemented by the clients of the library: every Mailgun, and
+// `EmailValidatorImpl`.
+//
+//  if r, ok := client.(mailgun.CapabilityReporter); ok && r.Capabilities().Supports(mailgun.FamilyTemplates) {
+//    // render with stored templates
+//  }
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// Capabilities returns the library version and endpoint families of the client. Validation is
+// not among them; it is supported by `EmailValidatorImpl`.
+func (mg *MailgunImpl) Capabilities() Capabilities {
+	families := make([]EndpointFamily, len(mailgunFamilies))
+	copy(families, mailgunFamilies)
+	return Capabilities{Version: Version, APIBase: mg.APIBase(), Families: families}
+}
+
+// Capabilities returns the library version and endpoint families of the validator.
+func (m *EmailValidatorImpl) Capabilities() Capabilities {
+	return Capabilities{Version: Version, APIBase: m.APIBase(), Families: []EndpointFamily{FamilyValidation}}
+}
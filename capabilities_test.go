@@ -0,0 +1,31 @@
+package mailgun_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestCapabilities(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(mailgun.APIBaseEU)
+
+	var r mailgun.CapabilityReporter = mg
+	c := r.Capabilities()
+	ensure.DeepEqual(t, c.Version, mailgun.Version)
+	ensure.DeepEqual(t, c.APIBase, mailgun.APIBaseEU)
+	ensure.True(t, c.Supports(mailgun.FamilyLists))
+	ensure.True(t, c.Supports(mailgun.FamilyAnalytics))
+	ensure.False(t, c.Supports(mailgun.FamilyValidation))
+	ensure.True(t, sort.SliceIsSorted(c.Families, func(i, j int) bool { return c.Families[i] < c.Families[j] }))
+
+	// The families returned are a copy
+	c.Families[0] = mailgun.FamilyValidation
+	ensure.False(t, mg.Capabilities().Supports(mailgun.FamilyValidation))
+
+	v := mailgun.NewEmailValidator(testKey)
+	ensure.True(t, v.Capabilities().Supports(mailgun.FamilyValidation))
+	ensure.False(t, v.Capabilities().Supports(mailgun.FamilyMessages))
+}
@@ -0,0 +1,195 @@
+package mailgun
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of the circuit breaker set with `SetCircuitBreaker()`.
+type BreakerState int
+
+const (
+	// BreakerClosed lets requests through; this is the normal state.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails requests with ErrCircuitOpen without making them.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial request through once the cool-down has passed. The
+	// breaker closes if it succeeds and opens again if it fails.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// ErrCircuitOpen is returned, without making the request, while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open; mailgun requests are failing")
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCoolDown  = 30 * time.Second
+)
+
+// CircuitBreakerOptions configures the circuit breaker set with `SetCircuitBreaker()`.
+type CircuitBreakerOptions struct {
+	// Threshold is the number of consecutive failed requests which opens the breaker; defaults to 5.
+	Threshold int
+	// CoolDown is how long the breaker stays open before a trial request is let through; defaults to 30s.
+	CoolDown time.Duration
+	// OnStateChange, if set, is called each time the breaker changes state. It is called from
+	// the goroutine whose request caused the change, after the request completes.
+	OnStateChange func(from, to BreakerState)
+}
+
+// SetCircuitBreaker enables a circuit breaker around every request the client makes, so callers
+// fail fast while Mailgun is unavailable rather than piling up behind requests which time out.
+// Requests which fail to reach Mailgun, or receive a 5xx response, count as failures; once
+// Threshold of them fail in a row, requests fail with ErrCircuitOpen until CoolDown has passed.
+// Requests cancelled by their context are not counted. Pass nil to disable the breaker, which is
+// disabled by default.
+//
+//  mg.SetCircuitBreaker(&mailgun.CircuitBreakerOptions{
+//    Threshold: 10,
+//    CoolDown:  time.Minute,
+//    OnStateChange: func(from, to mailgun.BreakerState) {
+//      log.Printf("mailgun circuit breaker %s -> %s", from, to)
+//    },
+//  })
+func (mg *MailgunImpl) SetCircuitBreaker(opts *CircuitBreakerOptions) {
+	var b *circuitBreaker
+	if opts != nil {
+		b = &circuitBreaker{opts: *opts, clock: mg.clock()}
+		if b.opts.Threshold <= 0 {
+			b.opts.Threshold = defaultBreakerThreshold
+		}
+		if b.opts.CoolDown <= 0 {
+			b.opts.CoolDown = defaultBreakerCoolDown
+		}
+	}
+	mg.mu.Lock()
+	mg.breaker = b
+	mg.mu.Unlock()
+}
+
+// CircuitBreakerState returns the state of the client's circuit breaker; BreakerClosed if there is none.
+func (mg *MailgunImpl) CircuitBreakerState() BreakerState {
+	mg.mu.RLock()
+	b := mg.breaker
+	mg.mu.RUnlock()
+	if b == nil {
+		return BreakerClosed
+	}
+	return b.currentState()
+}
+
+type circuitBreaker struct {
+	opts  CircuitBreakerOptions
+	clock clock
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+	// generation counts the changes of state, so the outcome of a request admitted before the
+	// last change, such as a success of one made before the breaker opened, is ignored.
+	generation uint64
+}
+
+// allow reports whether a request may be made, and must be followed by a call to done with the
+// generation it returns if it is.
+func (b *circuitBreaker) allow() (uint64, error) {
+	b.mu.Lock()
+	from := b.state
+	if b.state == BreakerOpen && b.clock.Now().Sub(b.openedAt) >= b.opts.CoolDown {
+		b.setState(BreakerHalfOpen)
+	}
+	var err error
+	switch {
+	case b.state == BreakerOpen:
+		err = ErrCircuitOpen
+	case b.state == BreakerHalfOpen && b.trial:
+		// Only one trial request at a time
+		err = ErrCircuitOpen
+	case b.state == BreakerHalfOpen:
+		b.trial = true
+	}
+	to, generation := b.state, b.generation
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return generation, err
+}
+
+// done records the outcome of a request let through by allow in generation. A request which was
+// abandoned, rather than failed, must not be recorded as either; counted is false for those.
+// Outcomes of requests admitted in an earlier generation are ignored.
+func (b *circuitBreaker) done(generation uint64, failed, counted bool) {
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	from := b.state
+	if from == BreakerHalfOpen {
+		b.trial = false
+	}
+	switch {
+	case !counted:
+	case !failed:
+		b.failures = 0
+		b.setState(BreakerClosed)
+	case from == BreakerHalfOpen:
+		b.setState(BreakerOpen)
+	default:
+		if b.failures++; b.failures >= b.opts.Threshold && from == BreakerClosed {
+			b.setState(BreakerOpen)
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// setState changes the state of the breaker, starting a new generation if it differs.
+func (b *circuitBreaker) setState(state BreakerState) {
+	if state == b.state {
+		return
+	}
+	b.state = state
+	b.generation++
+	if state == BreakerOpen {
+		b.openedAt = b.clock.Now()
+	}
+}
+
+func (b *circuitBreaker) notify(from, to BreakerState) {
+	if from != to && b.opts.OnStateChange != nil {
+		b.opts.OnStateChange(from, to)
+	}
+}
+
+func (b *circuitBreaker) currentState() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// breakerFailure reports whether the outcome of a request counts as a failure of Mailgun.
+func breakerFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,115 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls int
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"message": "unavailable"}`)
+			return
+		}
+		fmt.Fprint(w, `{"domain": {"name": "example.com"}}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	var changes []string
+	mg.SetCircuitBreaker(&mailgun.CircuitBreakerOptions{
+		Threshold: 2,
+		CoolDown:  20 * time.Millisecond,
+		OnStateChange: func(from, to mailgun.BreakerState) {
+			changes = append(changes, fmt.Sprintf("%s->%s", from, to))
+		},
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := mg.GetDomain(ctx, "example.com")
+		ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusServiceUnavailable)
+	}
+	ensure.DeepEqual(t, mg.CircuitBreakerState(), mailgun.BreakerOpen)
+
+	// Open: requests fail without reaching the server
+	_, err := mg.GetDomain(ctx, "example.com")
+	ensure.DeepEqual(t, err, mailgun.ErrCircuitOpen)
+	ensure.DeepEqual(t, calls, 2)
+
+	// The trial request after the cool-down fails, so the breaker opens again
+	time.Sleep(30 * time.Millisecond)
+	_, err = mg.GetDomain(ctx, "example.com")
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusServiceUnavailable)
+	ensure.DeepEqual(t, mg.CircuitBreakerState(), mailgun.BreakerOpen)
+
+	status = http.StatusOK
+	time.Sleep(30 * time.Millisecond)
+	_, err = mg.GetDomain(ctx, "example.com")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, mg.CircuitBreakerState(), mailgun.BreakerClosed)
+	ensure.DeepEqual(t, calls, 4)
+	ensure.DeepEqual(t, changes, []string{
+		"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed",
+	})
+
+	// Client errors are not failures of Mailgun
+	mg.SetCircuitBreaker(&mailgun.CircuitBreakerOptions{Threshold: 1})
+	status = http.StatusNotFound
+	_, err = mg.GetDomain(ctx, "example.com")
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusNotFound)
+	ensure.DeepEqual(t, mg.CircuitBreakerState(), mailgun.BreakerClosed)
+
+	mg.SetCircuitBreaker(nil)
+	ensure.DeepEqual(t, mg.CircuitBreakerState(), mailgun.BreakerClosed)
+}
+
+func TestCircuitBreakerStaleSuccess(t *testing.T) {
+	arrived, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3/domains/slow.example.com" {
+			close(arrived)
+			<-release
+			fmt.Fprint(w, `{"domain": {"name": "slow.example.com"}}`)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetCircuitBreaker(&mailgun.CircuitBreakerOptions{Threshold: 2, CoolDown: time.Hour})
+	ctx := context.Background()
+
+	// A request admitted while the breaker was closed...
+	slow := make(chan error)
+	go func() {
+		_, err := mg.GetDomain(ctx, "slow.example.com")
+		slow <- err
+	}()
+	<-arrived
+	for i := 0; i < 2; i++ {
+		_, err := mg.GetDomain(ctx, "example.com")
+		ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusServiceUnavailable)
+	}
+	ensure.DeepEqual(t, mg.CircuitBreakerState(), mailgun.BreakerOpen)
+
+	// ...does not close it by succeeding after it opened
+	close(release)
+	ensure.Nil(t, <-slow)
+	ensure.DeepEqual(t, mg.CircuitBreakerState(), mailgun.BreakerOpen)
+}
@@ -0,0 +1,43 @@
+package mailgun
+
+import (
+	"context"
+	"time"
+)
+
+// clock tells the time, and waits, for the parts of the client which depend on time, such as
+// retries with backoff, the scheduler and the TTLs of caches, so that tests can replace it with a
+// fake clock and check their behavior without waiting.
+type clock interface {
+	Now() time.Time
+	// Sleep pauses for d, or until the context is cancelled.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// systemClock is the clock of the system.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	return sleepContext(ctx, d)
+}
+
+// clock returns the clock of the client. A fake clock is set before the client is used, so it
+// needs no lock.
+func (mg *MailgunImpl) clock() clock {
+	if mg.clk == nil {
+		return systemClock{}
+	}
+	return mg.clk
+}
+
+// clockFor returns the clock of mg, or the system clock if mg is not a `*MailgunImpl`.
+func clockFor(mg Mailgun) clock {
+	if impl, ok := mg.(*MailgunImpl); ok {
+		return impl.clock()
+	}
+	return systemClock{}
+}
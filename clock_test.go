@@ -0,0 +1,220 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+)
+
+// fakeClock is a clock whose time only moves when it sleeps or is advanced.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestFakeClockSendRetries(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts < 4 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"message":"Queued. Thank you.", "id":"<id@example.com>"}`)
+	}))
+	defer srv.Close()
+
+	clock := newFakeClock()
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.clk = clock
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetSendRetries(3)
+
+	start := time.Now()
+	_, _, err := mg.Send(context.Background(), mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, clock.sleeps, []time.Duration{sendBackoff(1), sendBackoff(2), sendBackoff(3)})
+	ensure.True(t, time.Since(start) < sendBackoff(1))
+}
+
+func TestFakeClockCircuitBreaker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	clock := newFakeClock()
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.clk = clock
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetCircuitBreaker(&CircuitBreakerOptions{Threshold: 1, CoolDown: time.Minute})
+	ctx := context.Background()
+
+	_, err := mg.GetDomain(ctx, "example.com")
+	ensure.NotNil(t, err)
+	ensure.DeepEqual(t, mg.CircuitBreakerState(), BreakerOpen)
+	clock.Advance(59 * time.Second)
+	_, err = mg.GetDomain(ctx, "example.com")
+	ensure.DeepEqual(t, err, ErrCircuitOpen)
+	clock.Advance(time.Second)
+	_, err = mg.GetDomain(ctx, "example.com")
+	ensure.NotNil(t, err)
+	ensure.True(t, err != ErrCircuitOpen)
+}
+
+func TestFakeClockResponseCache(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"list": {"address": "list@example.com"}}`)
+	}))
+	defer srv.Close()
+
+	clock := newFakeClock()
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.clk = clock
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetResponseCache(&ResponseCacheOptions{TTL: map[string]time.Duration{listsEndpoint: time.Minute}})
+	ctx := context.Background()
+
+	for _, d := range []time.Duration{0, 30 * time.Second, 31 * time.Second, 0} {
+		clock.Advance(d)
+		_, err := mg.GetMailingList(ctx, "list@example.com")
+		ensure.Nil(t, err)
+	}
+	ensure.DeepEqual(t, requests, 2)
+}
+
+func TestFakeClockScheduler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	clock := newFakeClock()
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.clk = clock
+	mg.SetAPIBase(srv.URL + "/v3")
+	store := NewMemoryScheduleStore()
+	s := NewSendScheduler(mg, store)
+	ctx := context.Background()
+
+	ensure.Nil(t, s.Schedule(ctx, ScheduledMessage{
+		ID:        "reminder",
+		DeliverAt: clock.Now().Add(30 * time.Minute),
+		From:      fromUser,
+		To:        []string{"joe@example.com"},
+		Text:      exampleText,
+	}))
+	_, err := s.SendDue(ctx)
+	ensure.Nil(t, err)
+
+	// The failed submission is retried a minute later, by the clock of the client
+	due, err := store.Due(ctx, clock.Now().Add(59*time.Second), 10)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(due), 0)
+	due, err = store.Due(ctx, clock.Now().Add(time.Minute), 10)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(due), 1)
+	ensure.DeepEqual(t, due[0].Attempts, 1)
+}
+
+func TestFakeClockSchedulerRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Run stops at its next poll
+		cancel()
+		fmt.Fprint(w, `{"message":"Queued. Thank you.", "id":"<id@example.com>"}`)
+	}))
+	defer srv.Close()
+
+	clock := newFakeClock()
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.clk = clock
+	mg.SetAPIBase(srv.URL + "/v3")
+	s := NewSendScheduler(mg, NewMemoryScheduleStore())
+	s.SetLeadTime(0)
+	s.SetPollInterval(time.Minute)
+
+	ensure.Nil(t, s.Schedule(ctx, ScheduledMessage{
+		ID:        "reminder",
+		DeliverAt: clock.Now().Add(30 * time.Minute),
+		From:      fromUser,
+		To:        []string{"joe@example.com"},
+		Text:      exampleText,
+	}))
+	start := time.Now()
+	ensure.DeepEqual(t, s.Run(ctx), context.Canceled)
+	ensure.DeepEqual(t, len(clock.sleeps), 31)
+	ensure.True(t, time.Since(start) < time.Minute)
+}
+
+func TestFakeClockEventsNextWait(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		next := fmt.Sprintf("http://%s/v3/%s/events/page-%d", r.Host, exampleDomain, requests)
+		var items string
+		switch requests {
+		case 1, 2:
+		case 3:
+			// Ten minutes old, so held back until it is half an hour old
+			items = fmt.Sprintf(`{"event": "accepted", "id": "a", "timestamp": %f}`, TimeToFloat(start.Add(-10*time.Minute)))
+		default:
+			items = fmt.Sprintf(`{"event": "accepted", "id": "a", "timestamp": %f}, {"event": "delivered", "id": "b", "timestamp": %f}`,
+				TimeToFloat(start.Add(-40*time.Minute)), TimeToFloat(start.Add(-10*time.Minute)))
+		}
+		fmt.Fprintf(w, `{"items": [%s], "paging": {"next": "%s"}}`, items, next)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.clk = clock
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	it := mg.ListEvents(&ListEventOptions{PollInterval: 10 * time.Second, MaxPollInterval: 15 * time.Second, ThresholdAge: 30 * time.Minute})
+	var page []Event
+	ensure.True(t, it.NextWait(context.Background(), &page))
+	ensure.DeepEqual(t, len(page), 2)
+	ensure.DeepEqual(t, requests, 4)
+	ensure.DeepEqual(t, clock.sleeps, []time.Duration{10 * time.Second, 15 * time.Second, 19*time.Minute + 35*time.Second})
+
+	// Waiting stops once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ensure.False(t, it.NextWait(ctx, &page))
+	ensure.DeepEqual(t, requests, 4)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+// cli runs commands with a client, encoding their results as JSON lines.
+type cli struct {
+	mg  *mailgun.MailgunImpl
+	out *json.Encoder
+}
+
+// stringsFlag is a flag which may be given more than once.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// newFlags returns the flag set of a command.
+func newFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+// parse parses the flags of a command, which may come before, between or after its want
+// positional arguments, and returns the positional arguments.
+func parse(fs *flag.FlagSet, args []string, want int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != want {
+		return nil, errUsage
+	}
+	return positional, nil
+}
+
+// requireDomain returns an error if the client has no domain, for commands on a domain.
+func (c *cli) requireDomain() error {
+	if c.mg.Domain() == "" {
+		return errors.New("no domain; set -domain or MG_DOMAIN")
+	}
+	return nil
+}
+
+// pages calls next until it returns false, writing each item of the page it fetches into the
+// slice page points to, then returns the error of the iterator.
+func (c *cli) pages(next func() bool, page interface{}, err func() error) error {
+	for next() {
+		if e := c.encodeAll(page); e != nil {
+			return e
+		}
+	}
+	return err()
+}
+
+// encodeAll writes each element of the slice items, or the slice it points to.
+func (c *cli) encodeAll(items interface{}) error {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err := c.out.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *cli) send(ctx context.Context, args []string) error {
+	fs := newFlags("send")
+	var to, tags stringsFlag
+	from := fs.String("from", "", "sender address")
+	fs.Var(&to, "to", "recipient address")
+	subject := fs.String("subject", "", "subject")
+	text := fs.String("text", "", "plain text body")
+	html := fs.String("html", "", "HTML body")
+	fs.Var(&tags, "tag", "tag")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *from == "" || len(to) == 0 {
+		return errUsage
+	}
+	if err := c.requireDomain(); err != nil {
+		return err
+	}
+
+	m := c.mg.NewMessage(*from, *subject, *text, to...)
+	if *html != "" {
+		m.SetHtml(*html)
+	}
+	if err := m.AddTag(tags...); err != nil {
+		return err
+	}
+	message, id, err := c.mg.Send(ctx, m)
+	if err != nil {
+		return err
+	}
+	return c.out.Encode(map[string]string{"id": id, "message": message})
+}
+
+func (c *cli) lists(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	fs := newFlags("lists " + args[0])
+	switch args[0] {
+	case "list":
+		if _, err := parse(fs, args[1:], 0); err != nil {
+			return err
+		}
+		it := c.mg.ListMailingLists(nil)
+		var page []mailgun.MailingList
+		return c.pages(func() bool { return it.Next(ctx, &page) }, &page, it.Err)
+	case "get":
+		pos, err := parse(fs, args[1:], 1)
+		if err != nil {
+			return err
+		}
+		list, err := c.mg.GetMailingList(ctx, pos[0])
+		if err != nil {
+			return err
+		}
+		return c.out.Encode(list)
+	case "create":
+		name := fs.String("name", "", "name of the list")
+		description := fs.String("description", "", "description of the list")
+		access := fs.String("access-level", "", "readonly, members or everyone")
+		pos, err := parse(fs, args[1:], 1)
+		if err != nil {
+			return err
+		}
+		resp, err := c.mg.CreateMailingList(ctx, mailgun.MailingList{
+			Address:     pos[0],
+			Name:        *name,
+			Description: *description,
+			AccessLevel: mailgun.AccessLevel(*access),
+		})
+		if err != nil {
+			return err
+		}
+		return c.out.Encode(resp.List)
+	case "delete":
+		pos, err := parse(fs, args[1:], 1)
+		if err != nil {
+			return err
+		}
+		return c.mg.DeleteMailingList(ctx, pos[0])
+	}
+	return errUsage
+}
+
+func (c *cli) members(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	fs := newFlags("members " + args[0])
+	switch args[0] {
+	case "list":
+		pos, err := parse(fs, args[1:], 1)
+		if err != nil {
+			return err
+		}
+		it := c.mg.ListMembers(pos[0], nil)
+		var page []mailgun.Member
+		return c.pages(func() bool { return it.Next(ctx, &page) }, &page, it.Err)
+	case "add":
+		name := fs.String("name", "", "name of the member")
+		unsubscribed := fs.Bool("unsubscribed", false, "add the member unsubscribed")
+		pos, err := parse(fs, args[1:], 2)
+		if err != nil {
+			return err
+		}
+		subscribed := mailgun.Subscribed
+		if *unsubscribed {
+			subscribed = mailgun.Unsubscribed
+		}
+		return c.mg.CreateMember(ctx, true, pos[0], mailgun.Member{Address: pos[1], Name: *name, Subscribed: subscribed})
+	case "remove":
+		pos, err := parse(fs, args[1:], 2)
+		if err != nil {
+			return err
+		}
+		return c.mg.DeleteMember(ctx, pos[1], pos[0])
+	}
+	return errUsage
+}
+
+func (c *cli) domains(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	fs := newFlags("domains " + args[0])
+	switch args[0] {
+	case "list":
+		if _, err := parse(fs, args[1:], 0); err != nil {
+			return err
+		}
+		it := c.mg.ListDomains(nil)
+		var page []mailgun.Domain
+		return c.pages(func() bool { return it.Next(ctx, &page) }, &page, it.Err)
+	case "get":
+		pos, err := parse(fs, args[1:], 1)
+		if err != nil {
+			return err
+		}
+		domain, err := c.mg.GetDomain(ctx, pos[0])
+		if err != nil {
+			return err
+		}
+		return c.out.Encode(domain)
+	}
+	return errUsage
+}
+
+func (c *cli) events(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "tail" {
+		return errUsage
+	}
+	fs := newFlags("events tail")
+	var filters stringsFlag
+	limit := fs.Int("limit", 0, "page size")
+	fs.Var(&filters, "filter", "filter of the events api, as name=value")
+	follow := fs.Bool("follow", false, "keep polling for new events until interrupted")
+	if _, err := parse(fs, args[1:], 0); err != nil {
+		return err
+	}
+	if err := c.requireDomain(); err != nil {
+		return err
+	}
+
+	opts := &mailgun.ListEventOptions{Limit: *limit, Filter: map[string]string{}}
+	for _, f := range filters {
+		parts := strings.SplitN(f, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("filter '%s' is not of the form name=value", f)
+		}
+		opts.Filter[parts[0]] = parts[1]
+	}
+	var page []mailgun.Event
+	if *follow {
+		p := c.mg.PollEvents(opts)
+		err := c.pages(func() bool { return p.Poll(ctx, &page) }, &page, p.Err)
+		if ctx.Err() != nil {
+			// Interrupted
+			return nil
+		}
+		return err
+	}
+	it := c.mg.ListEvents(opts)
+	return c.pages(func() bool { return it.Next(ctx, &page) }, &page, it.Err)
+}
+
+func (c *cli) suppressions(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	if err := c.requireDomain(); err != nil {
+		return err
+	}
+	fs := newFlags("suppressions " + args[0] + " " + args[1])
+	kind, command := args[0], args[1]
+	switch command {
+	case "list":
+		if _, err := parse(fs, args[2:], 0); err != nil {
+			return err
+		}
+		switch kind {
+		case "bounces":
+			it := c.mg.ListBounces(nil)
+			var page []mailgun.Bounce
+			return c.pages(func() bool { return it.Next(ctx, &page) }, &page, it.Err)
+		case "unsubscribes":
+			it := c.mg.ListUnsubscribes(nil)
+			var page []mailgun.Unsubscribe
+			return c.pages(func() bool { return it.Next(ctx, &page) }, &page, it.Err)
+		case "complaints":
+			it := c.mg.ListComplaints(nil)
+			var page []mailgun.Complaint
+			return c.pages(func() bool { return it.Next(ctx, &page) }, &page, it.Err)
+		}
+	case "remove":
+		pos, err := parse(fs, args[2:], 1)
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case "bounces":
+			return c.mg.DeleteBounce(ctx, pos[0])
+		case "unsubscribes":
+			return c.mg.DeleteUnsubscribe(ctx, pos[0])
+		case "complaints":
+			return c.mg.DeleteComplaint(ctx, pos[0])
+		}
+	}
+	return errUsage
+}
@@ -0,0 +1,101 @@
+// Command mailgun scripts common Mailgun tasks from the shell with the mailgun-go client: sending
+// messages, managing mailing lists, members, domains and suppressions, and tailing events.
+//
+// The client is configured from the -domain, -key and -api-base flags, which default to the
+// MG_DOMAIN, MG_API_KEY and MG_URL environment variables. Results are written to stdout as one
+// JSON object per line, so they can be piped to jq.
+//
+//  mailgun send -from ops@example.com -to joe@example.com -subject Hi -text "Hello"
+//  mailgun lists list
+//  mailgun members add dev@example.com joe@example.com -name Joe
+//  mailgun events tail -follow -filter event=failed
+//  mailgun suppressions bounces list
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+const usage = `usage: mailgun [-domain d] [-key k] [-api-base url] <command> [arguments]
+
+commands:
+  send -from addr -to addr [-to addr...] -subject s [-text t] [-html h] [-tag t...]
+  lists list | get <list> | create <list> [-name n] [-description d] [-access-level l] | delete <list>
+  members list <list> | add <list> <addr> [-name n] [-unsubscribed] | remove <list> <addr>
+  domains list | get <domain>
+  events tail [-limit n] [-filter name=value...] [-follow]
+  suppressions bounces|unsubscribes|complaints list | remove <addr>
+`
+
+// errUsage is returned for arguments which do not form a command; the usage is printed.
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	err := run(ctx, os.Args[1:], os.Stdout, os.Getenv)
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mailgun:", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command named by args, writing its results to out. Settings missing from the flags
+// are looked up with getenv.
+func run(ctx context.Context, args []string, out io.Writer, getenv func(string) string) error {
+	fs := flag.NewFlagSet("mailgun", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	domain := fs.String("domain", getenv("MG_DOMAIN"), "sending domain")
+	key := fs.String("key", getenv("MG_API_KEY"), "private API key")
+	apiBase := fs.String("api-base", getenv("MG_URL"), "API base URL, such as "+mailgun.APIBaseEU)
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		return errUsage
+	}
+	if *key == "" {
+		return errors.New("no API key; set -key or MG_API_KEY")
+	}
+
+	mg := mailgun.NewMailgun(*domain, *key)
+	if *apiBase != "" {
+		mg.SetAPIBase(*apiBase)
+	}
+	c := &cli{mg: mg, out: json.NewEncoder(out)}
+
+	command, args := fs.Arg(0), fs.Args()[1:]
+	switch command {
+	case "send":
+		return c.send(ctx, args)
+	case "lists":
+		return c.lists(ctx, args)
+	case "members":
+		return c.members(ctx, args)
+	case "domains":
+		return c.domains(ctx, args)
+	case "events":
+		return c.events(ctx, args)
+	case "suppressions":
+		return c.suppressions(ctx, args)
+	}
+	return errUsage
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestCommands(t *testing.T) {
+	server := mailgun.NewMockServer()
+	defer server.Stop()
+	env := map[string]string{"MG_DOMAIN": "mailgun.test", "MG_API_KEY": "api-key", "MG_URL": server.URL()}
+	ctx := context.Background()
+
+	mailgunCLI := func(args ...string) ([]map[string]interface{}, error) {
+		var out bytes.Buffer
+		err := run(ctx, args, &out, func(name string) string { return env[name] })
+		var lines []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var v map[string]interface{}
+			ensure.Nil(t, json.Unmarshal([]byte(line), &v))
+			lines = append(lines, v)
+		}
+		return lines, err
+	}
+
+	out, err := mailgunCLI("send", "-from", "ops@mailgun.test", "-to", "joe@example.com", "-subject", "Hi", "-text", "Hello")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(out), 1)
+	ensure.True(t, out[0]["id"] != "")
+
+	out, err = mailgunCLI("lists", "create", "cli@mailgun.test", "-name", "CLI", "-access-level", "members")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, out[0]["address"], "cli@mailgun.test")
+	_, err = mailgunCLI("members", "add", "cli@mailgun.test", "joe@example.com", "-name", "Joe")
+	ensure.Nil(t, err)
+	out, err = mailgunCLI("members", "list", "cli@mailgun.test")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(out), 1)
+	ensure.DeepEqual(t, out[0]["name"], "Joe")
+	_, err = mailgunCLI("members", "remove", "cli@mailgun.test", "joe@example.com")
+	ensure.Nil(t, err)
+	_, err = mailgunCLI("lists", "delete", "cli@mailgun.test")
+	ensure.Nil(t, err)
+	_, err = mailgunCLI("lists", "get", "cli@mailgun.test")
+	ensure.NotNil(t, err)
+
+	out, err = mailgunCLI("domains", "list")
+	ensure.Nil(t, err)
+	ensure.True(t, len(out) != 0)
+
+	out, err = mailgunCLI("events", "tail", "-limit", "10")
+	ensure.Nil(t, err)
+	ensure.True(t, len(out) != 0)
+	ensure.True(t, out[0]["event"] != "")
+
+	_, err = mailgunCLI("lists", "frobnicate")
+	ensure.DeepEqual(t, err, errUsage)
+	_, err = mailgunCLI("members", "add", "cli@mailgun.test")
+	ensure.DeepEqual(t, err, errUsage)
+	delete(env, "MG_API_KEY")
+	_, err = mailgunCLI("domains", "list")
+	ensure.StringContains(t, err.Error(), "no API key")
+}
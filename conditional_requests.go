@@ -0,0 +1,136 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// DefaultRevalidationEntries is the number of responses kept for conditional requests, unless
+// ConditionalRequestOptions sets another.
+const DefaultRevalidationEntries = 1000
+
+// ConditionalRequestOptions configures the conditional requests enabled with
+// `SetConditionalRequests()`.
+type ConditionalRequestOptions struct {
+	// Endpoints are the names of the endpoints whose responses are revalidated, such as "stats"
+	// or "domains". Defaults to those two.
+	Endpoints []string
+	// MaxEntries is the number of responses kept; defaults to DefaultRevalidationEntries. Once
+	// full, a response is dropped for each new one.
+	MaxEntries int
+}
+
+// SetConditionalRequests enables revalidating the responses of GET requests to the endpoints of
+// the options, such as `GetStats()` and `GetDomain()`. When Mailgun returned an ETag or a
+// Last-Modified header with a response, the next request for the same URL sends If-None-Match or
+// If-Modified-Since, and a 304 Not Modified answer is decoded from the response kept by the
+// client, so dashboards polling resources which rarely change transfer little. Unlike
+// `SetResponseCache()`, every call makes a request, so changes are seen at once. Responses
+// without validators are not kept. Pass nil to disable conditional requests, which are disabled
+// by default.
+func (mg *MailgunImpl) SetConditionalRequests(opts *ConditionalRequestOptions) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.revalidation = nil
+	if opts == nil {
+		return
+	}
+	c := &revalidationCache{
+		endpoints:  make(map[string]bool),
+		maxEntries: opts.MaxEntries,
+		entries:    make(map[string]revalidationEntry),
+	}
+	endpoints := opts.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = []string{"stats", domainsEndpoint}
+	}
+	for _, e := range endpoints {
+		c.endpoints[e] = true
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = DefaultRevalidationEntries
+	}
+	mg.revalidation = c
+}
+
+// revalidationCache keeps the last response with validators of each URL.
+type revalidationCache struct {
+	endpoints  map[string]bool
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]revalidationEntry
+}
+
+type revalidationEntry struct {
+	etag         string
+	lastModified string
+	data         []byte
+}
+
+func (c *revalidationCache) get(address string) (revalidationEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[address]
+	return e, ok
+}
+
+// set keeps the response to address if it carries validators, and forgets it otherwise.
+func (c *revalidationCache) set(address string, response *httpResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := revalidationEntry{
+		etag:         response.Header.Get("ETag"),
+		lastModified: response.Header.Get("Last-Modified"),
+		data:         response.Data,
+	}
+	if e.etag == "" && e.lastModified == "" {
+		delete(c.entries, address)
+		return
+	}
+	if _, ok := c.entries[address]; !ok && len(c.entries) >= c.maxEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[address] = e
+}
+
+// getRevalidatedJSON works as getResponseFromJSON, making a conditional request if it can.
+func getRevalidatedJSON(ctx context.Context, r *httpRequest, v interface{}) (bool, error) {
+	c := r.options.revalidation
+	if c == nil {
+		return false, nil
+	}
+	address, err := r.generateUrlWithParameters()
+	if err != nil || !c.endpoints[cacheEndpoint(address)] {
+		return false, nil
+	}
+
+	kept, ok := c.get(address)
+	if ok {
+		if kept.etag != "" {
+			r.addHeader("If-None-Match", kept.etag)
+		}
+		if kept.lastModified != "" {
+			r.addHeader("If-Modified-Since", kept.lastModified)
+		}
+	}
+	response, err := r.makeRequest(ctx, "GET", nil)
+	if err != nil {
+		return true, err
+	}
+	if ok && response.Code == http.StatusNotModified {
+		return true, (&httpResponse{Data: kept.data, codec: r.options.codec}).parseFromJSON(v)
+	}
+	if notGood(response.Code, expected) {
+		return true, newError(r.URL, expected, response)
+	}
+	if err := response.parseFromJSON(v); err != nil {
+		return true, err
+	}
+	c.set(address, response)
+	return true, nil
+}
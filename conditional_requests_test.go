@@ -0,0 +1,71 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	var conditions []string
+	var notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/mailgun.test/stats/total":
+			conditions = append(conditions, "stats "+r.Header.Get("If-None-Match"))
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			fmt.Fprint(w, `{"stats": [{"accepted": {"outgoing": 10}}]}`)
+		case "/v3/domains/mailgun.test":
+			conditions = append(conditions, "domain "+r.Header.Get("If-Modified-Since"))
+			if r.Header.Get("If-Modified-Since") != "" {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Last-Modified", "Mon, 01 Mar 2021 12:00:00 GMT")
+			fmt.Fprint(w, `{"domain": {"name": "mailgun.test", "state": "active"}}`)
+		case "/v3/lists/dev@mailgun.test":
+			conditions = append(conditions, "list "+r.Header.Get("If-None-Match"))
+			w.Header().Set("ETag", `"v1"`)
+			fmt.Fprint(w, `{"member": {"address": "dev@mailgun.test"}}`)
+		}
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetConditionalRequests(&mailgun.ConditionalRequestOptions{})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		stats, err := mg.GetStats(ctx, []string{"accepted"}, nil)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, stats[0].Accepted.Outgoing, 10)
+		domain, err := mg.GetDomain(ctx, testDomain)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, domain.Domain.State, "active")
+		_, err = mg.GetMailingList(ctx, "dev@mailgun.test")
+		ensure.Nil(t, err)
+	}
+	ensure.DeepEqual(t, notModified, 2)
+	ensure.DeepEqual(t, conditions, []string{
+		"stats ", "domain ", "list ",
+		`stats "v1"`, "domain Mon, 01 Mar 2021 12:00:00 GMT", "list ",
+	})
+
+	conditions = nil
+	mg.SetConditionalRequests(nil)
+	_, err := mg.GetStats(ctx, []string{"accepted"}, nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, conditions, []string{"stats "})
+}
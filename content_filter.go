@@ -0,0 +1,341 @@
+package mailgun
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// MessageContent is the body of a message, as given to a ContentFilter. Fields the message has no
+// body for are empty.
+type MessageContent struct {
+	// Domain is the domain the message is sent from.
+	Domain string
+	Text   string
+	HTML   string
+}
+
+// ContentFilter inspects the body of a message before it is sent, and rewrites it in place, such
+// as to add a footer, or returns an error to reject the message, such as when it leaks a secret.
+type ContentFilter func(ctx context.Context, c *MessageContent) error
+
+// ContentRejectedError is returned by `Send()` when a ContentFilter rejects the message; nothing
+// is sent. Err is the error of the filter.
+type ContentRejectedError struct {
+	Err error
+}
+
+func (e *ContentRejectedError) Error() string {
+	return fmt.Sprintf("message content rejected: %s", e.Err)
+}
+
+// Unwrap returns the error of the filter.
+func (e *ContentRejectedError) Unwrap() error {
+	return e.Err
+}
+
+// SetContentFilters sets the filters `Send()` passes the text and HTML bodies of each message
+// through, in order, before sending it. They apply to messages built from their parts, to MIME
+// messages, whose first text/plain and text/html parts which are not attachments are filtered and
+// re-encoded, to other SendableMessages, and to messages sent from an Outbox. Bodies which Mailgun
+// renders from a stored template are not seen. Filters run before MIME transformers, so a message
+// is signed as it is sent. Pass no filters to remove them.
+//
+//  mg.SetContentFilters(
+//    mailgun.RedactContent(regexp.MustCompile(`sk_live_[0-9a-zA-Z]+`), "[redacted]"),
+//    mailgun.RequireFooter("Example Inc, 1 Main St", "<p>Example Inc, 1 Main St</p>"),
+//  )
+func (mg *MailgunImpl) SetContentFilters(filters ...ContentFilter) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.contentFilters = append([]ContentFilter(nil), filters...)
+}
+
+// RequireFooter returns a filter which appends the text footer to text bodies, and the HTML footer
+// to HTML bodies, before their closing body tag if they have one, unless the body already holds it.
+func RequireFooter(text, html string) ContentFilter {
+	return func(ctx context.Context, c *MessageContent) error {
+		if c.Text != "" && text != "" && !strings.Contains(c.Text, text) {
+			c.Text = strings.TrimRight(c.Text, "\r\n") + "\n\n" + text + "\n"
+		}
+		if c.HTML != "" && html != "" && !strings.Contains(c.HTML, html) {
+			if i := strings.LastIndex(strings.ToLower(c.HTML), "</body>"); i >= 0 {
+				c.HTML = c.HTML[:i] + html + c.HTML[i:]
+			} else {
+				c.HTML += html
+			}
+		}
+		return nil
+	}
+}
+
+// RedactContent returns a filter which replaces the matches of re in the bodies with replacement,
+// which may refer to submatches as regexp.ReplaceAllString does.
+func RedactContent(re *regexp.Regexp, replacement string) ContentFilter {
+	return func(ctx context.Context, c *MessageContent) error {
+		c.Text = re.ReplaceAllString(c.Text, replacement)
+		c.HTML = re.ReplaceAllString(c.HTML, replacement)
+		return nil
+	}
+}
+
+// RejectContent returns a filter which rejects messages whose bodies match re, such as those
+// holding credentials.
+func RejectContent(re *regexp.Regexp) ContentFilter {
+	return func(ctx context.Context, c *MessageContent) error {
+		if re.MatchString(c.Text) || re.MatchString(c.HTML) {
+			return fmt.Errorf("body matches %s", re)
+		}
+		return nil
+	}
+}
+
+// filterContent passes the bodies of the payload through the content filters of the client, and
+// writes back those they rewrote.
+func (mg *MailgunImpl) filterContent(ctx context.Context, p *formDataPayload, domain, endpoint string) error {
+	mg.mu.RLock()
+	filters := mg.contentFilters
+	mg.mu.RUnlock()
+	if len(filters) == 0 {
+		return nil
+	}
+	if endpoint == mimeMessagesEndpoint {
+		return filterMIMEContent(ctx, p, filters, domain)
+	}
+
+	c := MessageContent{Domain: domain}
+	for _, v := range p.Values {
+		switch v.key {
+		case "text":
+			c.Text = v.value
+		case "html":
+			c.HTML = v.value
+		}
+	}
+	original := c
+	if err := applyContentFilters(ctx, filters, &c); err != nil {
+		return err
+	}
+	if c.Text != original.Text {
+		p.setValue("text", c.Text)
+	}
+	if c.HTML != original.HTML {
+		p.setValue("html", c.HTML)
+	}
+	return nil
+}
+
+func applyContentFilters(ctx context.Context, filters []ContentFilter, c *MessageContent) error {
+	for _, filter := range filters {
+		if err := filter(ctx, c); err != nil {
+			return &ContentRejectedError{Err: err}
+		}
+	}
+	return nil
+}
+
+// setValue replaces the values of the key with value, or adds it if the payload has none.
+func (f *formDataPayload) setValue(key, value string) {
+	values := f.Values[:0]
+	set := false
+	for _, v := range f.Values {
+		if v.key == key {
+			if set {
+				continue
+			}
+			v.value, set = value, true
+		}
+		values = append(values, v)
+	}
+	f.Values = values
+	if !set {
+		f.addValue(key, value)
+	}
+}
+
+// filterMIMEContent filters the bodies of the MIME message of the payload. The message is only
+// rewritten if a filter changed a body; the parts which change are re-encoded as quoted-printable
+// UTF-8, and the headers of the message and its parts are written in sorted order.
+func filterMIMEContent(ctx context.Context, p *formDataPayload, filters []ContentFilter, domain string) error {
+	if err := p.bufferFiles(); err != nil {
+		return err
+	}
+	index := -1
+	for i, b := range p.Buffers {
+		if b.key == "message" {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil
+	}
+
+	root, err := parseMIMENode(p.Buffers[index].value)
+	if err != nil {
+		return err
+	}
+	var text, html *mimeNode
+	root.walk(func(n *mimeNode) {
+		switch {
+		case n.mediaType == "text/plain" && text == nil:
+			text = n
+		case n.mediaType == "text/html" && html == nil:
+			html = n
+		}
+	})
+
+	c := MessageContent{Domain: domain}
+	if text != nil {
+		if c.Text, err = text.content(); err != nil {
+			return err
+		}
+	}
+	if html != nil {
+		if c.HTML, err = html.content(); err != nil {
+			return err
+		}
+	}
+	original := c
+	if err := applyContentFilters(ctx, filters, &c); err != nil {
+		return err
+	}
+	if c.Text == original.Text && c.HTML == original.HTML {
+		return nil
+	}
+	if text != nil && c.Text != original.Text {
+		text.setContent(c.Text)
+	}
+	if html != nil && c.HTML != original.HTML {
+		html.setContent(c.HTML)
+	}
+
+	var buf bytes.Buffer
+	if err := root.eml().write(&buf); err != nil {
+		return err
+	}
+	p.Buffers[index].value = buf.Bytes()
+	return nil
+}
+
+// mimeNode is a part of a MIME message, whose leaves hold their body as it is encoded.
+type mimeNode struct {
+	header    textproto.MIMEHeader
+	mediaType string
+	// attachment is set for leaves with a file name or an attachment disposition.
+	attachment bool
+	body       []byte
+	parts      []*mimeNode
+	boundary   string
+}
+
+// parseMIMENode parses a message into its parts, without decoding their bodies.
+func parseMIMENode(raw []byte) (*mimeNode, error) {
+	tr := textproto.NewReader(bufio.NewReader(bytes.NewReader(raw)))
+	header, err := tr.ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("while reading message: %w", err)
+	}
+	body, err := ioutil.ReadAll(tr.R)
+	if err != nil {
+		return nil, fmt.Errorf("while reading message: %w", err)
+	}
+	return newMIMENode(header, body, 0)
+}
+
+func newMIMENode(header textproto.MIMEHeader, body []byte, depth int) (*mimeNode, error) {
+	n := &mimeNode{header: header}
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+	n.mediaType = mediaType
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+		n.attachment = disposition == "attachment" || partFilename(dispositionParams, params) != ""
+		n.body = body
+		return n, nil
+	}
+
+	if depth == maxMIMEDepth {
+		return nil, fmt.Errorf("message has more than %d levels of multipart parts", maxMIMEDepth)
+	}
+	n.boundary = params["boundary"]
+	mr := multipart.NewReader(bytes.NewReader(body), n.boundary)
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("while reading %s part: %w", mediaType, err)
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, fmt.Errorf("while reading %s part: %w", mediaType, err)
+		}
+		child, err := newMIMENode(part.Header, data, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		n.parts = append(n.parts, child)
+	}
+}
+
+// walk calls f with each leaf which is not an attachment, in the order of the message.
+func (n *mimeNode) walk(f func(*mimeNode)) {
+	if n.parts == nil && n.boundary == "" {
+		if !n.attachment {
+			f(n)
+		}
+		return
+	}
+	for _, child := range n.parts {
+		child.walk(f)
+	}
+}
+
+// content returns the decoded body of a leaf.
+func (n *mimeNode) content() (string, error) {
+	data, err := ioutil.ReadAll(decodeTransfer(n.header, bytes.NewReader(n.body)))
+	if err != nil {
+		return "", fmt.Errorf("while decoding %s part: %w", n.mediaType, err)
+	}
+	return string(data), nil
+}
+
+// setContent replaces the body of a leaf with content, encoded as quoted-printable UTF-8.
+func (n *mimeNode) setContent(content string) {
+	var buf bytes.Buffer
+	qp := quotedprintable.NewWriter(&buf)
+	io.WriteString(qp, content)
+	qp.Close()
+	n.body = buf.Bytes()
+	n.header.Set("Content-Type", n.mediaType+"; charset=utf-8")
+	n.header.Set("Content-Transfer-Encoding", "quoted-printable")
+}
+
+// eml returns the part to write the node with.
+func (n *mimeNode) eml() *emlPart {
+	p := &emlPart{header: n.header, boundary: n.boundary}
+	if n.boundary == "" {
+		body := n.body
+		p.body = func(w io.Writer) error {
+			_, err := w.Write(body)
+			return err
+		}
+		return p
+	}
+	for _, child := range n.parts {
+		p.parts = append(p.parts, child.eml())
+	}
+	return p
+}
@@ -0,0 +1,95 @@
+package mailgun_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+const filteredMIME = "From: sender@example.com\r\n" +
+	"Subject: Report\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Your key is sk_live_abc123=\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"PGh0bWw+PGJvZHk+SGVsbG88L2JvZHk+PC9odG1sPg==\r\n" +
+	"--inner--\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/plain; name=keys.txt\r\n" +
+	"Content-Disposition: attachment; filename=keys.txt\r\n" +
+	"\r\n" +
+	"sk_live_abc123\r\n" +
+	"--outer--\r\n"
+
+func TestContentFilters(t *testing.T) {
+	var requests int
+	var text, html string
+	var mimeMsg []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		text, html = req.FormValue("text"), req.FormValue("html")
+		mimeMsg = nil
+		if f, _, err := req.FormFile("message"); err == nil {
+			mimeMsg, _ = ioutil.ReadAll(f)
+		}
+		fmt.Fprint(w, `{"id": "<id@example.com>", "message": "Queued"}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetContentFilters(
+		mailgun.RedactContent(regexp.MustCompile(`sk_live_[0-9a-z]+`), "[redacted]"),
+		mailgun.RequireFooter("Example Inc", "<p>Example Inc</p>"),
+	)
+	ctx := context.Background()
+
+	m := mg.NewMessage("sender@example.com", "Report", "Your key is sk_live_abc123", "user@example.com")
+	m.SetHtml("<html><body>Key sk_live_abc123</body></html>")
+	_, _, err := mg.Send(ctx, m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, text, "Your key is [redacted]\n\nExample Inc\n")
+	ensure.DeepEqual(t, html, "<html><body>Key [redacted]<p>Example Inc</p></body></html>")
+
+	// The bodies of MIME messages are filtered, and their attachments left alone
+	m = mg.NewMIMEMessage(ioutil.NopCloser(strings.NewReader(filteredMIME)), "user@example.com")
+	_, _, err = mg.Send(ctx, m)
+	ensure.Nil(t, err)
+	parsed, err := mailgun.ParseMIMEMessage(mimeMsg)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, parsed.Subject, "Report")
+	ensure.DeepEqual(t, parsed.Text, "Your key is [redacted]\r\n\r\nExample Inc\r\n")
+	ensure.DeepEqual(t, parsed.HTML, "<html><body>Hello<p>Example Inc</p></body></html>")
+	ensure.DeepEqual(t, len(parsed.Parts), 1)
+	ensure.DeepEqual(t, string(parsed.Parts[0].Data), "sk_live_abc123")
+
+	mg.SetContentFilters(mailgun.RejectContent(regexp.MustCompile(`sk_live_`)))
+	sent := requests
+	m = mg.NewMessage("sender@example.com", "Report", "Your key is sk_live_abc123", "user@example.com")
+	_, _, err = mg.Send(ctx, m)
+	var rejected *mailgun.ContentRejectedError
+	ensure.True(t, errors.As(err, &rejected))
+	ensure.DeepEqual(t, requests, sent)
+}
@@ -24,17 +24,14 @@ var ErrEmptyParam = fmt.Errorf("empty or illegal parameter")
 
 // ListCredentials returns the (possibly zero-length) list of credentials associated with your domain.
 func (mg *MailgunImpl) ListCredentials(opts *ListOptions) *CredentialsIterator {
-	var limit int
-	if opts != nil {
-		limit = opts.Limit
-	}
+	return mg.listCredentials(mg.Domain(), opts)
+}
 
-	if limit == 0 {
-		limit = 100
-	}
+func (mg *MailgunImpl) listCredentials(domain string, opts *ListOptions) *CredentialsIterator {
+	limit, params := offsetPageOptions(opts)
 	return &CredentialsIterator{
 		mg:                      mg,
-		url:                     generateCredentialsUrl(mg, ""),
+		url:                     withParameters(generateCredentialsUrlWithDomain(mg, "", domain), params),
 		credentialsListResponse: credentialsListResponse{TotalCount: -1},
 		limit:                   limit,
 	}
@@ -160,9 +157,7 @@ func (ri *CredentialsIterator) Previous(ctx context.Context, items *[]Credential
 }
 
 func (ri *CredentialsIterator) fetch(ctx context.Context, skip, limit int) error {
-	r := newHTTPRequest(ri.url)
-	r.setBasicAuth(basicAuthUser, ri.mg.APIKey())
-	r.setClient(ri.mg.Client())
+	r := newAPIRequest(ri.mg, ri.url)
 
 	if skip != 0 {
 		r.addParameter("skip", strconv.Itoa(skip))
@@ -179,10 +174,8 @@ func (mg *MailgunImpl) CreateCredential(ctx context.Context, login, password str
 	if (login == "") || (password == "") {
 		return ErrEmptyParam
 	}
-	r := newHTTPRequest(generateCredentialsUrl(mg, ""))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	p := newUrlEncodedPayload()
+	r := newAPIRequest(mg, generateCredentialsUrl(mg, ""))
+	p := newPayload()
 	p.addValue("login", login)
 	p.addValue("password", password)
 	_, err := makePostRequest(ctx, r, p)
@@ -194,10 +187,8 @@ func (mg *MailgunImpl) ChangeCredentialPassword(ctx context.Context, login, pass
 	if (login == "") || (password == "") {
 		return ErrEmptyParam
 	}
-	r := newHTTPRequest(generateCredentialsUrl(mg, login))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	p := newUrlEncodedPayload()
+	r := newAPIRequest(mg, generateCredentialsUrl(mg, login))
+	p := newPayload()
 	p.addValue("password", password)
 	_, err := makePutRequest(ctx, r, p)
 	return err
@@ -205,12 +196,14 @@ func (mg *MailgunImpl) ChangeCredentialPassword(ctx context.Context, login, pass
 
 // DeleteCredential attempts to remove the indicated principle from the domain.
 func (mg *MailgunImpl) DeleteCredential(ctx context.Context, login string) error {
+	return mg.deleteCredential(ctx, mg.Domain(), login)
+}
+
+func (mg *MailgunImpl) deleteCredential(ctx context.Context, domain, login string) error {
 	if login == "" {
 		return ErrEmptyParam
 	}
-	r := newHTTPRequest(generateCredentialsUrl(mg, login))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateCredentialsUrlWithDomain(mg, login, domain))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
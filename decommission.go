@@ -0,0 +1,213 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DecommissionAction is a deletion `DecommissionDomain()` makes.
+type DecommissionAction string
+
+const (
+	// DecommissionDeleteRoute deletes a route whose expression matches mail to the domain.
+	DecommissionDeleteRoute DecommissionAction = "delete-route"
+	// DecommissionSkipRoute reports a route whose expression matches mail to the domain as well as
+	// other mail, such as that of other domains, which is left for you to edit or delete.
+	DecommissionSkipRoute DecommissionAction = "skip-route"
+	// DecommissionDeleteWebhook deletes a webhook of the domain.
+	DecommissionDeleteWebhook DecommissionAction = "delete-webhook"
+	// DecommissionDeleteCredential deletes an SMTP credential of the domain.
+	DecommissionDeleteCredential DecommissionAction = "delete-credential"
+	// DecommissionDeleteDomain deletes the domain itself.
+	DecommissionDeleteDomain DecommissionAction = "delete-domain"
+)
+
+// DecommissionStep is a deletion made, or to be made in a dry run, by `DecommissionDomain()`.
+type DecommissionStep struct {
+	Action DecommissionAction
+	// Target is the ID of the route, the kind of the webhook, the login of the credential or the
+	// name of the domain.
+	Target string
+	// Detail describes the target, such as the expression of a route or the urls of a webhook.
+	Detail string
+}
+
+// String describes the step, for printing a plan.
+func (s DecommissionStep) String() string {
+	if s.Detail == "" {
+		return fmt.Sprintf("%s %s", s.Action, s.Target)
+	}
+	return fmt.Sprintf("%s %s (%s)", s.Action, s.Target, s.Detail)
+}
+
+// DecommissionOptions modifies how `DecommissionDomain()` tears a domain down.
+type DecommissionOptions struct {
+	// DryRun returns the steps which would be taken without taking them.
+	DryRun bool
+}
+
+// DecommissionDomain tears down a domain the account no longer sends from, such as that of a
+// customer who left: the routes matching mail to the domain are deleted, then its webhooks and
+// its SMTP credentials, and finally the domain itself. Routes belong to the account rather than
+// the domain, so a route is only deleted if its expression names an address at the domain, such
+// as match_recipient(".*@example.com"), and nothing else; routes of subdomains are left alone.
+// Routes which also match other mail, such as match_recipient(".*@(example.com|example.org)")
+// or expressions joined with "or", are never deleted, and are returned as DecommissionSkipRoute
+// steps instead. opts may be nil.
+//
+// The steps taken are returned, in order, including when an error stops the teardown part way,
+// so the domain is only deleted once everything else was. With DryRun, nothing is deleted and the
+// steps returned are the plan:
+//
+//  steps, err := mg.DecommissionDomain(ctx, "customer.example.com", &mailgun.DecommissionOptions{DryRun: true})
+//  for _, s := range steps {
+//    fmt.Println(s)
+//  }
+//
+// A client with deletion protection requires a context from `ConfirmDeletion()` with the name of
+// the domain; it is checked before anything is deleted.
+func (mg *MailgunImpl) DecommissionDomain(ctx context.Context, domain string, opts *DecommissionOptions) ([]DecommissionStep, error) {
+	var dryRun bool
+	if opts != nil {
+		dryRun = opts.DryRun
+	}
+	if domain == "" {
+		return nil, ErrEmptyParam
+	}
+	if !dryRun {
+		if err := mg.checkDeletion(ctx, domain); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := mg.GetDomain(ctx, domain); err != nil {
+		return nil, err
+	}
+
+	var steps []DecommissionStep
+	l := mg.bulkLimiter()
+	// run takes the step, unless this is a dry run
+	run := func(step DecommissionStep, f func() error) error {
+		if !dryRun {
+			if err := l.do(ctx, f); err != nil {
+				return errors.Wrap(err, step.String())
+			}
+		}
+		steps = append(steps, step)
+		return nil
+	}
+
+	routes, err := mg.ListRoutesAll(ctx, nil)
+	if err != nil {
+		return steps, errors.Wrap(err, "while listing routes")
+	}
+	for _, route := range routes {
+		switch routeScopeOf(route.Expression, domain) {
+		case routeOtherMail:
+			continue
+		case routeSharedMail:
+			steps = append(steps, DecommissionStep{Action: DecommissionSkipRoute, Target: route.Id, Detail: route.Expression})
+			continue
+		}
+		id := route.Id
+		step := DecommissionStep{Action: DecommissionDeleteRoute, Target: id, Detail: route.Expression}
+		if err := run(step, func() error { return mg.DeleteRoute(ctx, id) }); err != nil {
+			return steps, err
+		}
+	}
+
+	hooks, err := mg.listWebhooks(ctx, domain)
+	if err != nil {
+		return steps, errors.Wrap(err, "while listing webhooks")
+	}
+	kinds := make([]string, 0, len(hooks))
+	for kind := range hooks {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		kind := kind
+		step := DecommissionStep{Action: DecommissionDeleteWebhook, Target: kind, Detail: strings.Join(hooks[kind], ", ")}
+		if err := run(step, func() error { return mg.deleteWebhook(ctx, domain, kind) }); err != nil {
+			return steps, err
+		}
+	}
+
+	// Credentials are all listed before any is deleted, as deletions shift the pages
+	var credentials, page []Credential
+	it := mg.listCredentials(domain, nil)
+	for it.Next(ctx, &page) {
+		credentials = append(credentials, page...)
+	}
+	if err := it.Err(); err != nil {
+		return steps, errors.Wrap(err, "while listing credentials")
+	}
+	for _, c := range credentials {
+		login := c.Login
+		step := DecommissionStep{Action: DecommissionDeleteCredential, Target: login}
+		if err := run(step, func() error { return mg.deleteCredential(ctx, domain, login) }); err != nil {
+			return steps, err
+		}
+	}
+
+	step := DecommissionStep{Action: DecommissionDeleteDomain, Target: domain}
+	err = run(step, func() error { return mg.DeleteDomain(ctx, domain) })
+	return steps, err
+}
+
+// routeScope is the mail a route matches, relative to a domain.
+type routeScope int
+
+const (
+	// routeOtherMail matches no address at the domain.
+	routeOtherMail routeScope = iota
+	// routeDomainMail matches addresses at the domain, and nothing else.
+	routeDomainMail
+	// routeSharedMail matches addresses at the domain, and other mail too.
+	routeSharedMail
+)
+
+// routeScopeOf returns the mail the expression of a route matches, relative to the domain. The
+// expression matches the domain if it names an address at it, and other mail too if it names an
+// address at another domain, or has alternatives, joined with "or" or within a regular expression.
+// Dots escaped in regular expressions are unescaped first.
+func routeScopeOf(expression, domain string) routeScope {
+	expression = strings.ToLower(strings.Replace(expression, `\.`, ".", -1))
+	domain = strings.ToLower(domain)
+
+	var named, other bool
+	for _, after := range strings.Split(expression, "@")[1:] {
+		// An address may name alternative domains, as in .*@(example.com|example.org)
+		names := []string{after}
+		if strings.HasPrefix(after, "(") {
+			if end := strings.Index(after, ")"); end > 0 {
+				names = strings.Split(after[1:end], "|")
+			}
+		}
+		for _, name := range names {
+			end := 0
+			for end < len(name) && isDomainChar(name[end]) {
+				end++
+			}
+			if name[:end] == domain {
+				named = true
+			} else {
+				other = true
+			}
+		}
+	}
+	switch {
+	case !named:
+		return routeOtherMail
+	case other || strings.Contains(expression, "|") || strings.Contains(expression, " or "):
+		return routeSharedMail
+	}
+	return routeDomainMail
+}
+
+func isDomainChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.'
+}
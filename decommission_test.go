@@ -0,0 +1,99 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestDecommissionDomain(t *testing.T) {
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.URL.Path)
+			fmt.Fprint(w, `{"message": "deleted"}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/v3/domains/customer.example.com":
+			fmt.Fprint(w, `{"domain": {"name": "customer.example.com", "state": "active"}}`)
+		case "/v3/routes":
+			if r.URL.Query().Get("skip") != "" {
+				fmt.Fprint(w, `{"total_count": 6, "items": []}`)
+				return
+			}
+			fmt.Fprint(w, `{"total_count": 6, "items": [
+				{"id": "r1", "expression": "match_recipient(\".*@customer\\.example\\.com\")"},
+				{"id": "r2", "expression": "match_recipient(\".*@mail.customer.example.com\")"},
+				{"id": "r3", "expression": "match_header(\"to\", \"support@Customer.example.com\")"},
+				{"id": "r4", "expression": "match_recipient(\".*@(other.example.com|customer.example.com)\")"},
+				{"id": "r5", "expression": "match_recipient(\"sales@customer.example.com\") or match_header(\"subject\", \"sales\")"},
+				{"id": "r6", "expression": "match_recipient(\".*@customer.example.com|.*@other.example.com\")"}
+			]}`)
+		case "/v3/domains/customer.example.com/webhooks":
+			fmt.Fprint(w, `{"webhooks": {"opened": {"urls": ["https://hooks.example.com/opened"]},
+				"delivered": {"urls": ["https://hooks.example.com/delivered"]}}}`)
+		case "/v3/domains/customer.example.com/credentials":
+			if r.URL.Query().Get("skip") != "" {
+				fmt.Fprint(w, `{"total_count": 1, "items": []}`)
+				return
+			}
+			fmt.Fprint(w, `{"total_count": 1, "items": [{"login": "postmaster@customer.example.com"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "not found"}`)
+		}
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	steps, err := mg.DecommissionDomain(ctx, "customer.example.com", &mailgun.DecommissionOptions{DryRun: true})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(deleted), 0)
+	plan := make([]string, len(steps))
+	for i, s := range steps {
+		plan[i] = s.String()
+	}
+	ensure.DeepEqual(t, plan, []string{
+		`delete-route r1 (match_recipient(".*@customer\.example\.com"))`,
+		`delete-route r3 (match_header("to", "support@Customer.example.com"))`,
+		// Routes which also match other mail are left alone
+		`skip-route r4 (match_recipient(".*@(other.example.com|customer.example.com)"))`,
+		`skip-route r5 (match_recipient("sales@customer.example.com") or match_header("subject", "sales"))`,
+		`skip-route r6 (match_recipient(".*@customer.example.com|.*@other.example.com"))`,
+		"delete-webhook delivered (https://hooks.example.com/delivered)",
+		"delete-webhook opened (https://hooks.example.com/opened)",
+		"delete-credential postmaster@customer.example.com",
+		"delete-domain customer.example.com",
+	})
+
+	// With deletion protection, nothing is deleted unless the deletion is confirmed
+	mg.SetDeletionProtection(true)
+	_, err = mg.DecommissionDomain(ctx, "customer.example.com", nil)
+	_, ok := err.(*mailgun.DeletionNotConfirmedError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, len(deleted), 0)
+
+	done, err := mg.DecommissionDomain(mailgun.ConfirmDeletion(ctx, "customer.example.com"), "customer.example.com", nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, done, steps)
+	ensure.DeepEqual(t, deleted, []string{
+		"/v3/routes/r1",
+		"/v3/routes/r3",
+		"/v3/domains/customer.example.com/webhooks/delivered",
+		"/v3/domains/customer.example.com/webhooks/opened",
+		"/v3/domains/customer.example.com/credentials/postmaster@customer.example.com",
+		"/v3/domains/customer.example.com",
+	})
+
+	_, err = mg.DecommissionDomain(ctx, "unknown.example.com", &mailgun.DecommissionOptions{DryRun: true})
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusNotFound)
+}
@@ -0,0 +1,56 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DeletionNotConfirmedError is returned by `DeleteMailingList()` and `DeleteDomain()` of a client
+// with deletion protection when the context does not confirm the deletion of the resource.
+// Nothing is deleted.
+type DeletionNotConfirmedError struct {
+	// Resource is the address of the mailing list, or the name of the domain.
+	Resource string
+}
+
+func (e *DeletionNotConfirmedError) Error() string {
+	return fmt.Sprintf("deletion of '%s' is not confirmed; pass a context from mailgun.ConfirmDeletion()", e.Resource)
+}
+
+type deletionKey struct{}
+
+// SetDeletionProtection sets whether `DeleteMailingList()` and `DeleteDomain()` require the
+// deletion to be confirmed, as a guard against automation deleting the wrong list or domain. Once
+// enabled, they return a *DeletionNotConfirmedError unless the context was returned by
+// `ConfirmDeletion()` with the address of the list or the name of the domain, ignoring case. This
+// includes the deletion made by `ArchiveMailingList()`. Deletion protection is off by default.
+//
+//  mg.SetDeletionProtection(true)
+//  err := mg.DeleteMailingList(mailgun.ConfirmDeletion(ctx, addr), addr)
+func (mg *MailgunImpl) SetDeletionProtection(enabled bool) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.deletionProtection = enabled
+}
+
+// ConfirmDeletion returns a context which confirms the deletion of the mailing list or domain
+// named resource to a client with deletion protection.
+func ConfirmDeletion(ctx context.Context, resource string) context.Context {
+	return context.WithValue(ctx, deletionKey{}, resource)
+}
+
+// checkDeletion returns a *DeletionNotConfirmedError if the client has deletion protection and
+// ctx does not confirm the deletion of resource.
+func (mg *MailgunImpl) checkDeletion(ctx context.Context, resource string) error {
+	mg.mu.RLock()
+	enabled := mg.deletionProtection
+	mg.mu.RUnlock()
+	if !enabled {
+		return nil
+	}
+	if confirmed, _ := ctx.Value(deletionKey{}).(string); confirmed == "" || !strings.EqualFold(strings.TrimSpace(confirmed), strings.TrimSpace(resource)) {
+		return &DeletionNotConfirmedError{Resource: resource}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package mailgun_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestDeletionProtection(t *testing.T) {
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deleted = append(deleted, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{"message": "deleted"}`))
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	// Deletions need no confirmation by default
+	ensure.Nil(t, mg.DeleteMailingList(ctx, "staging@example.com"))
+
+	mg.SetDeletionProtection(true)
+	err := mg.DeleteMailingList(ctx, "news@example.com")
+	ensure.NotNil(t, err)
+	notConfirmed, ok := err.(*mailgun.DeletionNotConfirmedError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, notConfirmed.Resource, "news@example.com")
+
+	// The confirmation must name the resource deleted
+	err = mg.DeleteMailingList(mailgun.ConfirmDeletion(ctx, "other@example.com"), "news@example.com")
+	ensure.NotNil(t, err)
+	err = mg.DeleteDomain(mailgun.ConfirmDeletion(ctx, "news@example.com"), "example.com")
+	ensure.NotNil(t, err)
+
+	ensure.Nil(t, mg.DeleteMailingList(mailgun.ConfirmDeletion(ctx, "News@Example.com"), "news@example.com"))
+	ensure.Nil(t, mg.DeleteDomain(mailgun.ConfirmDeletion(ctx, "example.com"), "example.com"))
+	ensure.DeepEqual(t, deleted, []string{
+		"DELETE /v3/lists/staging@example.com",
+		"DELETE /v3/lists/news@example.com",
+		"DELETE /v3/domains/example.com",
+	})
+}
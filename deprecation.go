@@ -0,0 +1,110 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A DeprecationNotice is what a response said about the retirement of its endpoint, from its
+// Deprecation (RFC 9745), Sunset (RFC 8594) and Warning headers. Times which were not given are zero.
+type DeprecationNotice struct {
+	// Method and URL are those of the request.
+	Method string
+	URL    string
+	// Deprecated is set if the endpoint is deprecated; DeprecatedAt is when it was, or will be.
+	Deprecated   bool
+	DeprecatedAt time.Time
+	// Sunset is when the endpoint is expected to stop responding.
+	Sunset time.Time
+	// Links are the links of the response with the deprecation or sunset relation, which
+	// describe the retirement of the endpoint.
+	Links []string
+	// Warnings are the texts of the Warning headers of the response.
+	Warnings []string
+}
+
+// DeprecationHandler is called with the notice of each response which says its endpoint is being
+// retired. The context is the one of the request.
+type DeprecationHandler func(ctx context.Context, notice DeprecationNotice)
+
+// SetDeprecationHandler sets a handler called whenever a response says its endpoint is deprecated
+// or will be retired, so applications get notice before an endpoint they rely on disappears. The
+// handler is called for every such response, so log or alert on notices at a rate which suits the
+// application. Pass nil to remove the handler.
+//
+//  mg.SetDeprecationHandler(func(ctx context.Context, n mailgun.DeprecationNotice) {
+//    log.Printf("%s %s is deprecated; sunset %s", n.Method, n.URL, n.Sunset)
+//  })
+func (mg *MailgunImpl) SetDeprecationHandler(h DeprecationHandler) {
+	mg.mu.Lock()
+	mg.onDeprecation = h
+	mg.mu.Unlock()
+}
+
+// parseDeprecation returns the deprecation notice of the response to req; ok is false if the
+// response has none.
+func parseDeprecation(req *http.Request, resp *http.Response) (n DeprecationNotice, ok bool) {
+	h := resp.Header
+	n.Method, n.URL = req.Method, req.URL.String()
+
+	if v := strings.TrimSpace(h.Get("Deprecation")); v != "" {
+		n.Deprecated = true
+		if strings.HasPrefix(v, "@") {
+			if secs, err := strconv.ParseInt(v[1:], 10, 64); err == nil {
+				n.DeprecatedAt = time.Unix(secs, 0).UTC()
+			}
+		} else if t, err := http.ParseTime(v); err == nil {
+			// Drafts of RFC 9745 used HTTP dates
+			n.DeprecatedAt = t
+		}
+	}
+	if t, err := http.ParseTime(strings.TrimSpace(h.Get("Sunset"))); err == nil {
+		n.Sunset = t
+	}
+	for _, v := range h["Warning"] {
+		if text := warningText(v); text != "" {
+			n.Warnings = append(n.Warnings, text)
+		}
+	}
+	if !n.Deprecated && n.Sunset.IsZero() && len(n.Warnings) == 0 {
+		return n, false
+	}
+
+	for _, v := range h["Link"] {
+		for _, link := range strings.Split(v, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			for _, param := range parts[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(kv) != 2 || !strings.EqualFold(kv[0], "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(kv[1], `"`)) {
+					if strings.EqualFold(rel, "deprecation") || strings.EqualFold(rel, "sunset") {
+						n.Links = append(n.Links, target)
+						break
+					}
+				}
+			}
+		}
+	}
+	return n, true
+}
+
+// warningText returns the text of a Warning header (RFC 7234), such as `299 - "Deprecated API"`,
+// or the whole header if it is not in that form.
+func warningText(v string) string {
+	v = strings.TrimSpace(v)
+	start := strings.IndexByte(v, '"')
+	if start < 0 {
+		return v
+	}
+	end := strings.IndexByte(v[start+1:], '"')
+	if end < 0 {
+		return v
+	}
+	return v[start+1 : start+1+end]
+}
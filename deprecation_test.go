@@ -0,0 +1,50 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestDeprecationHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3/domains/old.example.com" {
+			w.Header().Set("Deprecation", "@1688169599")
+			w.Header().Set("Sunset", "Wed, 01 Jan 2031 00:00:00 GMT")
+			w.Header().Add("Link", `<https://example.com/changelog>; rel="deprecation"; type="text/html", <https://example.com/other>; rel="next"`)
+			w.Header().Add("Warning", `299 - "Use the v4 domains API"`)
+		}
+		fmt.Fprint(w, `{"domain": {"name": "example.com"}}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	var notices []mailgun.DeprecationNotice
+	mg.SetDeprecationHandler(func(ctx context.Context, n mailgun.DeprecationNotice) {
+		notices = append(notices, n)
+	})
+	ctx := context.Background()
+
+	_, err := mg.GetDomain(ctx, "example.com")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(notices), 0)
+
+	_, err = mg.GetDomain(ctx, "old.example.com")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, notices, []mailgun.DeprecationNotice{{
+		Method:       "GET",
+		URL:          srv.URL + "/v3/domains/old.example.com",
+		Deprecated:   true,
+		DeprecatedAt: time.Unix(1688169599, 0).UTC(),
+		Sunset:       time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC),
+		Links:        []string{"https://example.com/changelog"},
+		Warnings:     []string{"Use the v4 domains API"},
+	}})
+}
@@ -0,0 +1,204 @@
+package mailgun
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Statuses of a DNS record checked by `CheckDNSRecords()`.
+const (
+	// DNSRecordOK records resolve to the value Mailgun expects.
+	DNSRecordOK = "ok"
+	// DNSRecordMissing records do not exist.
+	DNSRecordMissing = "missing"
+	// DNSRecordIncorrect records exist with a different value.
+	DNSRecordIncorrect = "incorrect"
+	// DNSRecordError records could not be looked up; see DNSRecordCheck.Err.
+	DNSRecordError = "error"
+)
+
+// DNSResolver looks up the records checked by `CheckDNSRecords()`. *net.Resolver implements it.
+type DNSResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+}
+
+// DNSRecordCheck is the result of checking a single record Mailgun expects.
+type DNSRecordCheck struct {
+	Record DNSRecord
+	// Status is one of DNSRecordOK, DNSRecordMissing, DNSRecordIncorrect or DNSRecordError.
+	Status string
+	// Found are the values resolved for the name and type of the record.
+	Found []string
+	// Err is the lookup error, if Status is DNSRecordError.
+	Err error
+}
+
+// DNSReport is the result of checking the DNS records of a domain.
+type DNSReport struct {
+	Domain    string
+	Sending   []DNSRecordCheck
+	Receiving []DNSRecordCheck
+}
+
+// OK reports whether every record resolved as expected.
+func (r DNSReport) OK() bool {
+	for _, checks := range [][]DNSRecordCheck{r.Sending, r.Receiving} {
+		for _, c := range checks {
+			if c.Status != DNSRecordOK {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Problems returns the checks of the records which did not resolve as expected.
+func (r DNSReport) Problems() []DNSRecordCheck {
+	var problems []DNSRecordCheck
+	for _, checks := range [][]DNSRecordCheck{r.Sending, r.Receiving} {
+		for _, c := range checks {
+			if c.Status != DNSRecordOK {
+				problems = append(problems, c)
+			}
+		}
+	}
+	return problems
+}
+
+// CheckDomainDNS fetches the DNS records Mailgun expects for the domain with `GetDomain()` and
+// checks them with `CheckDNSRecords()`, so they can be fixed before calling `VerifyDomain()`.
+// If resolver is nil, net.DefaultResolver is used.
+func (mg *MailgunImpl) CheckDomainDNS(ctx context.Context, domain string, resolver DNSResolver) (DNSReport, error) {
+	resp, err := mg.GetDomain(ctx, domain)
+	if err != nil {
+		return DNSReport{Domain: domain}, err
+	}
+	return CheckDNSRecords(ctx, resolver, resp), nil
+}
+
+// CheckDNSRecords looks up the sending and receiving records of a domain, as returned by
+// `GetDomain()` or `CreateDomain()`, and reports whether each resolves to the expected value.
+// The lookups are made locally, and may see different results than Mailgun while DNS changes
+// propagate. An SPF record counts as correct if it includes the expected mechanism alongside
+// others. If resolver is nil, net.DefaultResolver is used.
+func CheckDNSRecords(ctx context.Context, resolver DNSResolver, domain DomainResponse) DNSReport {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	report := DNSReport{Domain: domain.Domain.Name}
+	for _, record := range domain.SendingDNSRecords {
+		report.Sending = append(report.Sending, checkDNSRecord(ctx, resolver, domain.Domain.Name, record))
+	}
+	for _, record := range domain.ReceivingDNSRecords {
+		report.Receiving = append(report.Receiving, checkDNSRecord(ctx, resolver, domain.Domain.Name, record))
+	}
+	return report
+}
+
+func checkDNSRecord(ctx context.Context, resolver DNSResolver, domain string, record DNSRecord) DNSRecordCheck {
+	check := DNSRecordCheck{Record: record}
+	name := record.Name
+	if name == "" {
+		name = domain
+	}
+
+	var err error
+	switch strings.ToUpper(record.RecordType) {
+	case "TXT":
+		check.Found, err = resolver.LookupTXT(ctx, name)
+		check.Status = matchTXTRecord(record.Value, check.Found)
+	case "MX":
+		var mxs []*net.MX
+		mxs, err = resolver.LookupMX(ctx, name)
+		for _, mx := range mxs {
+			check.Found = append(check.Found, mx.Host)
+		}
+		check.Status = matchHostRecord(record.Value, check.Found)
+	case "CNAME":
+		var target string
+		target, err = resolver.LookupCNAME(ctx, name)
+		// Names without a CNAME resolve to themselves
+		if err == nil && !sameHost(target, name) {
+			check.Found = []string{target}
+		}
+		check.Status = matchHostRecord(record.Value, check.Found)
+	default:
+		check.Status = DNSRecordError
+		check.Err = errors.Errorf("unsupported record type '%s'", record.RecordType)
+		return check
+	}
+
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			check.Status = DNSRecordMissing
+			return check
+		}
+		check.Status, check.Err = DNSRecordError, err
+	}
+	return check
+}
+
+// matchTXTRecord compares the TXT records found with the expected value. Other TXT records at
+// the name of an SPF record, such as site verifications, are ignored.
+func matchTXTRecord(expected string, found []string) string {
+	want := compactTXT(expected)
+	spf := strings.HasPrefix(want, "v=spf1 ")
+	status := DNSRecordMissing
+	for _, txt := range found {
+		txt = compactTXT(txt)
+		switch {
+		case txt == want:
+			return DNSRecordOK
+		case !spf:
+			status = DNSRecordIncorrect
+		case strings.HasPrefix(txt, "v=spf1 "):
+			if includesSPFMechanisms(txt, want) {
+				return DNSRecordOK
+			}
+			status = DNSRecordIncorrect
+		}
+	}
+	return status
+}
+
+// includesSPFMechanisms reports whether the SPF record has the include mechanisms of the expected one.
+func includesSPFMechanisms(record, expected string) bool {
+	have := make(map[string]bool)
+	for _, term := range strings.Fields(record) {
+		have[term] = true
+	}
+	for _, term := range strings.Fields(expected) {
+		if strings.HasPrefix(term, "include:") && !have[term] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchHostRecord compares the hosts found with the expected host.
+func matchHostRecord(expected string, found []string) string {
+	if len(found) == 0 {
+		return DNSRecordMissing
+	}
+	for _, host := range found {
+		if sameHost(host, expected) {
+			return DNSRecordOK
+		}
+	}
+	return DNSRecordIncorrect
+}
+
+func sameHost(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
+
+// compactTXT returns a TXT value with quotes removed and whitespace collapsed.
+func compactTXT(value string) string {
+	value = strings.Replace(value, `"`, "", -1)
+	return strings.Join(strings.Fields(value), " ")
+}
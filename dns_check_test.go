@@ -0,0 +1,112 @@
+package mailgun_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+type fakeResolver struct {
+	txt   map[string][]string
+	mx    map[string][]*net.MX
+	cname map[string]string
+}
+
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (f fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if name == "broken.example.com" {
+		return nil, errors.New("server misbehaving")
+	}
+	if txt, ok := f.txt[name]; ok {
+		return txt, nil
+	}
+	return nil, notFound(name)
+}
+
+func (f fakeResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	if mx, ok := f.mx[name]; ok {
+		return mx, nil
+	}
+	return nil, notFound(name)
+}
+
+func (f fakeResolver) LookupCNAME(_ context.Context, host string) (string, error) {
+	if cname, ok := f.cname[host]; ok {
+		return cname, nil
+	}
+	return host + ".", nil
+}
+
+func TestCheckDNSRecords(t *testing.T) {
+	resolver := fakeResolver{
+		txt: map[string][]string{
+			"example.com":                 {"google-site-verification=abc", "v=spf1 include:_spf.google.com include:mailgun.org ~all"},
+			"mx._domainkey.example.com":   {"k=rsa; p=OLDKEY"},
+			"spf.example.com":             {"google-site-verification=abc"},
+			"smtp._domainkey.example.com": {`"k=rsa; " "p=MIGfMA0"`},
+		},
+		mx: map[string][]*net.MX{
+			"example.com": {{Host: "MXA.mailgun.org.", Pref: 10}, {Host: "mx.other.example.", Pref: 20}},
+		},
+		cname: map[string]string{"email.example.com": "mailgun.org."},
+	}
+
+	domain := mailgun.DomainResponse{
+		Domain: mailgun.Domain{Name: "example.com"},
+		SendingDNSRecords: []mailgun.DNSRecord{
+			{RecordType: "TXT", Name: "example.com", Value: "v=spf1 include:mailgun.org ~all"},
+			{RecordType: "TXT", Name: "smtp._domainkey.example.com", Value: "k=rsa; p=MIGfMA0"},
+			{RecordType: "TXT", Name: "mx._domainkey.example.com", Value: "k=rsa; p=NEWKEY"},
+			{RecordType: "TXT", Name: "spf.example.com", Value: "v=spf1 include:mailgun.org ~all"},
+			{RecordType: "TXT", Name: "broken.example.com", Value: "v=spf1 include:mailgun.org ~all"},
+			{RecordType: "CNAME", Name: "email.example.com", Value: "mailgun.org"},
+			{RecordType: "CNAME", Name: "track.example.com", Value: "mailgun.org"},
+		},
+		ReceivingDNSRecords: []mailgun.DNSRecord{
+			{RecordType: "MX", Priority: "10", Value: "mxa.mailgun.org"},
+			{RecordType: "MX", Priority: "10", Value: "mxb.mailgun.org"},
+		},
+	}
+
+	report := mailgun.CheckDNSRecords(context.Background(), resolver, domain)
+	var statuses []string
+	for _, c := range report.Sending {
+		statuses = append(statuses, c.Status)
+	}
+	ensure.DeepEqual(t, statuses, []string{
+		mailgun.DNSRecordOK,
+		mailgun.DNSRecordOK,
+		mailgun.DNSRecordIncorrect,
+		mailgun.DNSRecordMissing,
+		mailgun.DNSRecordError,
+		mailgun.DNSRecordOK,
+		mailgun.DNSRecordMissing,
+	})
+	ensure.NotNil(t, report.Sending[4].Err)
+	ensure.DeepEqual(t, report.Receiving[0].Status, mailgun.DNSRecordOK)
+	ensure.DeepEqual(t, report.Receiving[1].Status, mailgun.DNSRecordIncorrect)
+	ensure.DeepEqual(t, report.Receiving[1].Found, []string{"MXA.mailgun.org.", "mx.other.example."})
+	ensure.False(t, report.OK())
+	ensure.DeepEqual(t, len(report.Problems()), 5)
+}
+
+func TestCheckDomainDNS(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+
+	_, err := mg.CheckDomainDNS(context.Background(), "unknown.example.com", fakeResolver{})
+	ensure.NotNil(t, err)
+
+	report, err := mg.CheckDomainDNS(context.Background(), testDomain, fakeResolver{})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, report.Domain, testDomain)
+	ensure.DeepEqual(t, len(report.Receiving), 2)
+	ensure.DeepEqual(t, report.Receiving[0].Status, mailgun.DNSRecordMissing)
+}
@@ -2,6 +2,7 @@ package mailgun
 
 import (
 	"context"
+	"encoding/json"
 	"strconv"
 	"strings"
 )
@@ -27,6 +28,18 @@ type Domain struct {
 	Wildcard     bool        `json:"wildcard"`
 	SpamAction   SpamAction  `json:"spam_action"`
 	State        string      `json:"state"`
+	// Extra holds the fields of the domain returned by Mailgun which have no field of their own.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (d *Domain) UnmarshalJSON(data []byte) error {
+	type plain Domain
+	if err := json.Unmarshal(data, (*plain)(d)); err != nil {
+		return err
+	}
+	var err error
+	d.Extra, err = extraFields(data, plain{})
+	return err
 }
 
 // DNSRecord structures describe intended records to properly configure your domain for use with Mailgun.
@@ -79,12 +92,40 @@ type domainTrackingResponse struct {
 	Tracking DomainTracking `json:"tracking"`
 }
 
+// States of a domain, as reported by Domain.State and used to filter ListDomainsWithOptions().
+const (
+	DomainStateActive     = "active"
+	DomainStateUnverified = "unverified"
+	DomainStateDisabled   = "disabled"
+)
+
+// ListDomainOptions specifies the domains ListDomainsWithOptions() iterates over.
+type ListDomainOptions struct {
+	// Limit is the page size; Mailgun assumes 100 if left unspecified.
+	Limit int
+	// Skip is the number of domains to skip before the first page.
+	Skip int
+	// State restricts the results to domains in one state, such as DomainStateUnverified.
+	State string
+}
+
 // ListDomains retrieves a set of domains from Mailgun.
 func (mg *MailgunImpl) ListDomains(opts *ListOptions) *DomainsIterator {
 	var limit int
 	if opts != nil {
 		limit = opts.Limit
 	}
+	return mg.ListDomainsWithOptions(&ListDomainOptions{Limit: limit})
+}
+
+// ListDomainsWithOptions retrieves a set of domains from Mailgun, skipping domains or filtering
+// them by state as the options specify.
+func (mg *MailgunImpl) ListDomainsWithOptions(opts *ListDomainOptions) *DomainsIterator {
+	var limit, skip int
+	var state string
+	if opts != nil {
+		limit, skip, state = opts.Limit, opts.Skip, opts.State
+	}
 
 	if limit == 0 {
 		limit = 100
@@ -94,6 +135,8 @@ func (mg *MailgunImpl) ListDomains(opts *ListOptions) *DomainsIterator {
 		url:                 generatePublicApiUrl(mg, domainsEndpoint),
 		domainsListResponse: domainsListResponse{TotalCount: -1},
 		limit:               limit,
+		offset:              skip,
+		state:               state,
 	}
 }
 
@@ -103,6 +146,7 @@ type DomainsIterator struct {
 	limit  int
 	mg     Mailgun
 	offset int
+	state  string
 	url    string
 	err    error
 }
@@ -217,9 +261,7 @@ func (ri *DomainsIterator) Previous(ctx context.Context, items *[]Domain) bool {
 }
 
 func (ri *DomainsIterator) fetch(ctx context.Context, skip, limit int) error {
-	r := newHTTPRequest(ri.url)
-	r.setBasicAuth(basicAuthUser, ri.mg.APIKey())
-	r.setClient(ri.mg.Client())
+	r := newAPIRequest(ri.mg, ri.url)
 
 	if skip != 0 {
 		r.addParameter("skip", strconv.Itoa(skip))
@@ -227,26 +269,25 @@ func (ri *DomainsIterator) fetch(ctx context.Context, skip, limit int) error {
 	if limit != 0 {
 		r.addParameter("limit", strconv.Itoa(limit))
 	}
+	if ri.state != "" {
+		r.addParameter("state", ri.state)
+	}
 
 	return getResponseFromJSON(ctx, r, &ri.domainsListResponse)
 }
 
 // GetDomain retrieves detailed information about the named domain.
 func (mg *MailgunImpl) GetDomain(ctx context.Context, domain string) (DomainResponse, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + domain)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(domain))
 	var resp DomainResponse
 	err := getResponseFromJSON(ctx, r, &resp)
 	return resp, err
 }
 
 func (mg *MailgunImpl) VerifyDomain(ctx context.Context, domain string) (string, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + domain + "/verify")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(domain)+"/verify")
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 	var resp DomainResponse
 	err := putResponseFromJSON(ctx, r, payload, &resp)
 	return resp.Domain.State, err
@@ -269,11 +310,9 @@ type CreateDomainOptions struct {
 // The wildcard parameter instructs Mailgun to treat all subdomains of this domain uniformly if true,
 // and as different domains if false.
 func (mg *MailgunImpl) CreateDomain(ctx context.Context, name string, opts *CreateDomainOptions) (DomainResponse, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint))
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 	payload.addValue("name", name)
 
 	if opts != nil {
@@ -303,9 +342,7 @@ func (mg *MailgunImpl) CreateDomain(ctx context.Context, name string, opts *Crea
 
 // GetDomainConnection returns delivery connection settings for the defined domain
 func (mg *MailgunImpl) GetDomainConnection(ctx context.Context, domain string) (DomainConnection, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + domain + "/connection")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(domain)+"/connection")
 	var resp domainConnectionResponse
 	err := getResponseFromJSON(ctx, r, &resp)
 	return resp.Connection, err
@@ -313,53 +350,47 @@ func (mg *MailgunImpl) GetDomainConnection(ctx context.Context, domain string) (
 
 // Updates the specified delivery connection settings for the defined domain
 func (mg *MailgunImpl) UpdateDomainConnection(ctx context.Context, domain string, settings DomainConnection) error {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + domain + "/connection")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(domain)+"/connection")
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 	payload.addValue("require_tls", boolToString(settings.RequireTLS))
 	payload.addValue("skip_verification", boolToString(settings.SkipVerification))
 	_, err := makePutRequest(ctx, r, payload)
 	return err
 }
 
-// DeleteDomain instructs Mailgun to dispose of the named domain name
+// DeleteDomain instructs Mailgun to dispose of the named domain name.
+// Clients with deletion protection require the deletion to be confirmed (see `SetDeletionProtection()`).
 func (mg *MailgunImpl) DeleteDomain(ctx context.Context, name string) error {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + name)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	if err := mg.checkDeletion(ctx, name); err != nil {
+		return err
+	}
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(name))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
 
 // GetDomainTracking returns tracking settings for a domain
 func (mg *MailgunImpl) GetDomainTracking(ctx context.Context, domain string) (DomainTracking, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + domain + "/tracking")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(domain)+"/tracking")
 	var resp domainTrackingResponse
 	err := getResponseFromJSON(ctx, r, &resp)
 	return resp.Tracking, err
 }
 
 func (mg *MailgunImpl) UpdateClickTracking(ctx context.Context, domain, active string) error {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + domain + "/tracking/click")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(domain)+"/tracking/click")
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 	payload.addValue("active", active)
 	_, err := makePutRequest(ctx, r, payload)
 	return err
 }
 
 func (mg *MailgunImpl) UpdateUnsubscribeTracking(ctx context.Context, domain, active, htmlFooter, textFooter string) error {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + domain + "/tracking/unsubscribe")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(domain)+"/tracking/unsubscribe")
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 	payload.addValue("active", active)
 	payload.addValue("html_footer", htmlFooter)
 	payload.addValue("text_footer", textFooter)
@@ -368,11 +399,9 @@ func (mg *MailgunImpl) UpdateUnsubscribeTracking(ctx context.Context, domain, ac
 }
 
 func (mg *MailgunImpl) UpdateOpenTracking(ctx context.Context, domain, active string) error {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + domain + "/tracking/open")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(domain)+"/tracking/open")
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 	payload.addValue("active", active)
 	_, err := makePutRequest(ctx, r, payload)
 	return err
@@ -380,23 +409,41 @@ func (mg *MailgunImpl) UpdateOpenTracking(ctx context.Context, domain, active st
 
 // Update the DKIM selector for a domain
 func (mg *MailgunImpl) UpdateDomainDkimSelector(ctx context.Context, domain, dkimSelector string) error {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + domain + "/dkim_selector")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(domain)+"/dkim_selector")
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 	payload.addValue("dkim_selector", dkimSelector)
 	_, err := makePutRequest(ctx, r, payload)
 	return err
 }
 
+// DKIMAuthorityResponse is returned by `UpdateDomainDKIMAuthority()`.
+type DKIMAuthorityResponse struct {
+	// Changed reports whether the authority of the domain changed.
+	Changed bool   `json:"changed"`
+	Message string `json:"message"`
+	// SendingDNSRecords are the records to install for the new DKIM key.
+	SendingDNSRecords []DNSRecord `json:"sending_dns_records"`
+}
+
+// UpdateDomainDKIMAuthority sets which domain's DKIM key signs the mail of a subdomain: its own key
+// if self is true, or the key of its parent domain otherwise. A delegated subdomain with its own
+// authority signs with a key aligned with itself; install the returned DNS records before sending.
+func (mg *MailgunImpl) UpdateDomainDKIMAuthority(ctx context.Context, domain string, self bool) (DKIMAuthorityResponse, error) {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(domain)+"/dkim_authority")
+
+	payload := newPayload()
+	payload.addValue("self", boolToString(self))
+	var resp DKIMAuthorityResponse
+	err := putResponseFromJSON(ctx, r, payload, &resp)
+	return resp, err
+}
+
 // Update the CNAME used for tracking opens and clicks
 func (mg *MailgunImpl) UpdateDomainTrackingWebPrefix(ctx context.Context, domain, webPrefix string) error {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + domain + "/web_prefix")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(domain)+"/web_prefix")
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 	payload.addValue("web_prefix", webPrefix)
 	_, err := makePutRequest(ctx, r, payload)
 	return err
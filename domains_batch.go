@@ -0,0 +1,113 @@
+package mailgun
+
+import (
+	"context"
+	"errors"
+)
+
+// DomainSpec describes a domain to create with `CreateDomainsBatch()`.
+type DomainSpec struct {
+	Name    string
+	Options *CreateDomainOptions
+}
+
+// DomainResult is the outcome of creating one domain with `CreateDomainsBatch()`.
+type DomainResult struct {
+	Name string
+	// Domain and the DNS records to install are those returned by `CreateDomain()`.
+	Domain              Domain
+	ReceivingDNSRecords []DNSRecord
+	SendingDNSRecords   []DNSRecord
+	// Err is the error creating the domain, if any.
+	Err error
+}
+
+// CreateDomainsBatch creates many domains, making up to concurrency requests at once, and returns
+// the result for each spec in the same order. Requests are started no more than ten a second, and
+// a request which receives 429 Too Many Requests is retried as the client slows down and makes
+// fewer requests at once (see `BulkStats()`), so platforms onboarding many customer domains stay
+// within Mailgun's rate limits. A failure to create one
+// domain does not stop the others; check the Err of each result. If ctx is cancelled, domains
+// not yet created fail with the context's error.
+//
+//  results := mg.CreateDomainsBatch(ctx, specs, 4)
+//  for _, r := range results {
+//    if r.Err != nil {
+//      log.Printf("%s: %s", r.Name, r.Err)
+//      continue
+//    }
+//    dns.Install(r.Name, r.SendingDNSRecords, r.ReceivingDNSRecords)
+//  }
+func (mg *MailgunImpl) CreateDomainsBatch(ctx context.Context, specs []DomainSpec, concurrency int) []DomainResult {
+	results, _ := mg.CreateDomainsBatchWithOptions(ctx, specs, DomainsBatchOptions{Concurrency: concurrency})
+	return results
+}
+
+// DomainsBatchOptions controls `CreateDomainsBatchWithOptions()`.
+type DomainsBatchOptions struct {
+	// Concurrency is the most domains created at once; defaults to 1.
+	Concurrency int
+	// MaxErrorRate, if set, stops the batch once the ratio of the domains which failed to be
+	// created exceeds it, such as 0.2 for a fifth, so a misconfigured batch does not fail
+	// thousands of requests. The ratio is only checked once MinErrorSamples domains were
+	// attempted; defaults to DefaultErrorRateSamples.
+	MaxErrorRate    float64
+	MinErrorSamples int
+}
+
+// CreateDomainsBatchWithOptions creates many domains as `CreateDomainsBatch()` does, and also
+// returns the number of domains created, failed and not attempted, with the reason the batch
+// stopped early, if it did. Domains not attempted fail with ErrBatchAborted.
+//
+//  results, summary := mg.CreateDomainsBatchWithOptions(ctx, specs, mailgun.DomainsBatchOptions{
+//    Concurrency:  4,
+//    MaxErrorRate: 0.1,
+//  })
+//  if summary.Aborted() {
+//    log.Printf("stopped after %d failures: %s", summary.Failed, summary.AbortReason)
+//  }
+func (mg *MailgunImpl) CreateDomainsBatchWithOptions(ctx context.Context, specs []DomainSpec, opts DomainsBatchOptions) ([]DomainResult, BulkSummary) {
+	results := make([]DomainResult, len(specs))
+	limiter := mg.bulkLimiter()
+	exec := BatchExecutor{
+		Concurrency:     opts.Concurrency,
+		Mode:            ContinueOnError,
+		MaxErrorRate:    opts.MaxErrorRate,
+		MinErrorSamples: opts.MinErrorSamples,
+	}
+	summary, err := exec.RunSummary(ctx, len(specs), func(ctx context.Context, i int) error {
+		results[i] = mg.createBatchDomain(ctx, limiter, specs[i])
+		return results[i].Err
+	})
+
+	// Domains the batch did not get to fail with the error the executor reports for them
+	if merr, ok := err.(*MultiError); ok {
+		for _, e := range merr.Errors {
+			if results[e.Index].Err == nil {
+				results[e.Index] = DomainResult{Name: specs[e.Index].Name, Err: e.Err}
+			}
+		}
+	}
+	return results, summary
+}
+
+// createBatchDomain creates a single domain of a batch, retrying when rate limited.
+func (mg *MailgunImpl) createBatchDomain(ctx context.Context, l *bulkLimiter, spec DomainSpec) DomainResult {
+	result := DomainResult{Name: spec.Name}
+	if spec.Name == "" {
+		result.Err = errors.New("domain name is required")
+		return result
+	}
+
+	var resp DomainResponse
+	result.Err = l.do(ctx, func() (err error) {
+		resp, err = mg.CreateDomain(ctx, spec.Name, spec.Options)
+		return err
+	})
+	if result.Err == nil {
+		result.Domain = resp.Domain
+		result.ReceivingDNSRecords = resp.ReceivingDNSRecords
+		result.SendingDNSRecords = resp.SendingDNSRecords
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestCreateDomainsBatch(t *testing.T) {
+	defer func(d time.Duration) { bulkInterval = d }(bulkInterval)
+	bulkInterval = time.Millisecond
+
+	var mu sync.Mutex
+	var active, maxActive int
+	limited := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		name := r.FormValue("name")
+		retry := name == "limited.example.com" && !limited[name]
+		limited[name] = true
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+		time.Sleep(5 * time.Millisecond)
+
+		switch {
+		case retry:
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"message": "Too many requests"}`)
+		case name == "taken.example.com":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message": "This domain name is already taken"}`)
+		default:
+			fmt.Fprintf(w, `{"domain": {"name": %q, "state": "unverified"},
+				"sending_dns_records": [{"record_type": "TXT", "name": %[1]q, "value": "v=spf1 include:mailgun.org ~all"}],
+				"receiving_dns_records": [{"record_type": "MX", "priority": "10", "value": "mxa.mailgun.org"}]}`, name)
+		}
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	specs := []DomainSpec{
+		{Name: "one.example.com"},
+		{Name: "taken.example.com"},
+		{Name: "limited.example.com", Options: &CreateDomainOptions{SpamAction: SpamActionTag}},
+		{Name: ""},
+		{Name: "two.example.com"},
+		{Name: "three.example.com"},
+	}
+	results := mg.CreateDomainsBatch(context.Background(), specs, 2)
+	ensure.DeepEqual(t, len(results), len(specs))
+	ensure.True(t, maxActive <= 2)
+
+	for i, r := range results {
+		ensure.DeepEqual(t, r.Name, specs[i].Name)
+	}
+	ensure.Nil(t, results[0].Err)
+	ensure.DeepEqual(t, results[0].Domain.State, "unverified")
+	ensure.DeepEqual(t, results[0].SendingDNSRecords[0].Value, "v=spf1 include:mailgun.org ~all")
+	ensure.DeepEqual(t, results[0].ReceivingDNSRecords[0].Value, "mxa.mailgun.org")
+	ensure.DeepEqual(t, GetStatusFromErr(results[1].Err), http.StatusBadRequest)
+	ensure.Nil(t, results[2].Err)
+	ensure.NotNil(t, results[3].Err)
+	ensure.Nil(t, results[5].Err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = mg.CreateDomainsBatch(ctx, specs[:1], 1)
+	ensure.DeepEqual(t, results[0].Err, context.Canceled)
+
+	taken := make([]DomainSpec, 5)
+	for i := range taken {
+		taken[i] = DomainSpec{Name: "taken.example.com"}
+	}
+	results, summary := mg.CreateDomainsBatchWithOptions(context.Background(), taken, DomainsBatchOptions{
+		Concurrency:     1,
+		MaxErrorRate:    0.5,
+		MinErrorSamples: 2,
+	})
+	ensure.DeepEqual(t, summary.Failed, 2)
+	ensure.DeepEqual(t, summary.Skipped, 3)
+	ensure.DeepEqual(t, summary.AbortReason, &ErrorRateError{Failed: 2, Processed: 2, MaxErrorRate: 0.5})
+	ensure.DeepEqual(t, GetStatusFromErr(results[1].Err), http.StatusBadRequest)
+	ensure.DeepEqual(t, results[4], DomainResult{Name: "taken.example.com", Err: ErrBatchAborted})
+}
@@ -31,6 +31,38 @@ func TestListDomains(t *testing.T) {
 	ensure.True(t, it.TotalCount != 0)
 }
 
+func TestListDomainsOptions(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	var page []mailgun.Domain
+	it := mg.ListDomainsWithOptions(&mailgun.ListDomainOptions{State: mailgun.DomainStateActive})
+	ensure.True(t, it.Next(ctx, &page))
+	ensure.Nil(t, it.Err())
+	ensure.True(t, len(page) != 0)
+	for _, d := range page {
+		ensure.DeepEqual(t, d.State, mailgun.DomainStateActive)
+	}
+
+	it = mg.ListDomainsWithOptions(&mailgun.ListDomainOptions{State: mailgun.DomainStateDisabled})
+	ensure.False(t, it.Next(ctx, &page))
+	ensure.Nil(t, it.Err())
+	ensure.DeepEqual(t, it.TotalCount, 0)
+
+	// ListDomains() takes the ListOptions it always has
+	all := mg.ListDomains(&mailgun.ListOptions{Limit: 100})
+	ensure.True(t, all.Next(ctx, &page))
+	skipped := mg.ListDomainsWithOptions(&mailgun.ListDomainOptions{Skip: 1, Limit: 1})
+	var rest []mailgun.Domain
+	if skipped.Next(ctx, &rest) {
+		ensure.DeepEqual(t, rest[0].Name, page[1].Name)
+	} else {
+		ensure.DeepEqual(t, len(page), 1)
+	}
+	ensure.Nil(t, skipped.Err())
+}
+
 func TestGetSingleDomain(t *testing.T) {
 	mg := mailgun.NewMailgun(testDomain, testKey)
 	mg.SetAPIBase(server.URL())
@@ -173,3 +205,18 @@ func TestDomainTrackingWebPrefix(t *testing.T) {
 	err := mg.UpdateDomainTrackingWebPrefix(ctx, testDomain, "gotest")
 	ensure.Nil(t, err)
 }
+
+func TestDomainDKIMAuthority(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	resp, err := mg.UpdateDomainDKIMAuthority(ctx, testDomain, true)
+	ensure.Nil(t, err)
+	ensure.True(t, resp.Changed)
+	ensure.DeepEqual(t, len(resp.SendingDNSRecords), 1)
+	ensure.DeepEqual(t, resp.SendingDNSRecords[0].RecordType, "TXT")
+
+	_, err = mg.UpdateDomainDKIMAuthority(ctx, "unknown.domain", false)
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusNotFound)
+}
@@ -159,13 +159,11 @@ func (m *EmailValidatorImpl) ValidateEmail(ctx context.Context, email string, ma
 }
 
 func (m *EmailValidatorImpl) validateV3(ctx context.Context, email string, mailBoxVerify bool) (EmailVerification, error) {
-	r := newHTTPRequest(m.getAddressURL("validate"))
-	r.setClient(m.Client())
+	r := newAPIRequest(m, m.getAddressURL("validate"))
 	r.addParameter("address", email)
 	if mailBoxVerify {
 		r.addParameter("mailbox_verification", "true")
 	}
-	r.setBasicAuth(basicAuthUser, m.APIKey())
 
 	var res EmailVerification
 	err := getResponseFromJSON(ctx, r, &res)
@@ -176,13 +174,11 @@ func (m *EmailValidatorImpl) validateV3(ctx context.Context, email string, mailB
 }
 
 func (m *EmailValidatorImpl) validateV4(ctx context.Context, email string, mailBoxVerify bool) (EmailVerification, error) {
-	r := newHTTPRequest(fmt.Sprintf("%s/address/validate", m.APIBase()))
-	r.setClient(m.Client())
+	r := newAPIRequest(m, fmt.Sprintf("%s/address/validate", m.APIBase()))
 	r.addParameter("address", email)
 	if mailBoxVerify {
 		r.addParameter("mailbox_verification", "true")
 	}
-	r.setBasicAuth(basicAuthUser, m.APIKey())
 
 	var res v4EmailValidationResp
 	err := getResponseFromJSON(ctx, r, &res)
@@ -204,10 +200,8 @@ func (m *EmailValidatorImpl) validateV4(ctx context.Context, email string, mailB
 // ParseAddresses takes a list of addresses and sorts them into valid and invalid address categories.
 // NOTE: Use of this function requires a proper public API key.  The private API key will not work.
 func (m *EmailValidatorImpl) ParseAddresses(ctx context.Context, addresses ...string) ([]string, []string, error) {
-	r := newHTTPRequest(m.getAddressURL("parse"))
-	r.setClient(m.Client())
+	r := newAPIRequest(m, m.getAddressURL("parse"))
 	r.addParameter("addresses", strings.Join(addresses, ","))
-	r.setBasicAuth(basicAuthUser, m.APIKey())
 
 	var response addressParseResult
 	err := getResponseFromJSON(ctx, r, &response)
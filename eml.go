@@ -0,0 +1,247 @@
+package mailgun
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WriteEML renders the message as a .eml file, as Mailgun would assemble it from what `Send()`
+// posts: its headers, text, HTML and AMP bodies, inlines and attachments. Use it to inspect a
+// message without sending it, or to archive outgoing mail. Mailgun adds headers of its own when it
+// sends the message, such as its Message-Id, DKIM signature and tracking headers, and renders
+// templates, so the file is a preview rather than a byte for byte copy. MIME messages are written
+// as they are. Attachments and inlines added from readers are read, so write the message only
+// once, and do not send it afterwards.
+//
+//  f, err := os.Create("preview.eml")
+//  if err != nil {
+//    return err
+//  }
+//  defer f.Close()
+//  err = m.WriteEML(f)
+func (m *Message) WriteEML(w io.Writer) error {
+	payload := newFormDataPayload()
+	if err := m.addValues(payload); err != nil {
+		return err
+	}
+	if m.mailingList != nil {
+		m.addListHeaders(payload, m.mailingList)
+	}
+
+	for _, rc := range payload.ReadClosers {
+		if rc.key == "message" {
+			defer rc.value.Close()
+			_, err := io.Copy(w, rc.value)
+			return err
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeEML(bw, payload); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// emlPart is a part of a MIME message; either body or parts is set.
+type emlPart struct {
+	header textproto.MIMEHeader
+	body   func(io.Writer) error
+	// parts are the parts of a multipart part; boundary separates them.
+	parts    []*emlPart
+	boundary string
+}
+
+func newMultipart(subtype string, parts ...*emlPart) *emlPart {
+	p := &emlPart{header: make(textproto.MIMEHeader), parts: parts, boundary: multipart.NewWriter(nil).Boundary()}
+	p.header.Set("Content-Type", fmt.Sprintf("multipart/%s; boundary=%s", subtype, p.boundary))
+	return p
+}
+
+// writeEML writes the message the payload of `Send()` describes.
+func writeEML(w io.Writer, payload *formDataPayload) error {
+	var to, cc, headers []string
+	var from, subject, text, html, ampHtml string
+	for _, v := range payload.Values {
+		switch {
+		case v.key == "from":
+			from = v.value
+		case v.key == "to":
+			to = append(to, v.value)
+		case v.key == "cc":
+			cc = append(cc, v.value)
+		case v.key == "subject":
+			subject = v.value
+		case v.key == "text":
+			text = v.value
+		case v.key == "html":
+			html = v.value
+		case v.key == "amp-html":
+			ampHtml = v.value
+		case strings.HasPrefix(v.key, "h:"):
+			headers = append(headers, strings.TrimPrefix(v.key, "h:")+": "+v.value)
+		}
+	}
+
+	var bodies []*emlPart
+	if text != "" || (html == "" && ampHtml == "") {
+		bodies = append(bodies, textPart("text/plain", text))
+	}
+	if ampHtml != "" {
+		bodies = append(bodies, textPart("text/x-amp-html", ampHtml))
+	}
+	if html != "" {
+		bodies = append(bodies, textPart("text/html", html))
+	}
+	root := bodies[0]
+	if len(bodies) > 1 {
+		root = newMultipart("alternative", bodies...)
+	}
+
+	if err := payload.bufferFiles(); err != nil {
+		return err
+	}
+	var inlines, attachments []*emlPart
+	for _, b := range payload.Buffers {
+		p := filePart(b.key, b.name, payload.contentType(b.name, b.value), b.value)
+		if b.key == "inline" {
+			inlines = append(inlines, p)
+		} else {
+			attachments = append(attachments, p)
+		}
+	}
+	if len(inlines) != 0 {
+		root = newMultipart("related", append([]*emlPart{root}, inlines...)...)
+	}
+	if len(attachments) != 0 {
+		root = newMultipart("mixed", append([]*emlPart{root}, attachments...)...)
+	}
+
+	fmt.Fprintf(w, "From: %s\r\n", from)
+	if len(to) != 0 {
+		fmt.Fprintf(w, "To: %s\r\n", strings.Join(to, ", "))
+	}
+	if len(cc) != 0 {
+		fmt.Fprintf(w, "Cc: %s\r\n", strings.Join(cc, ", "))
+	}
+	fmt.Fprintf(w, "Subject: %s\r\n", EncodeHeader(subject))
+	fmt.Fprintf(w, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(w, "MIME-Version: 1.0\r\n")
+	for _, h := range headers {
+		fmt.Fprintf(w, "%s\r\n", h)
+	}
+	return root.write(w)
+}
+
+// write writes the headers and body of the part.
+func (p *emlPart) write(w io.Writer) error {
+	keys := make([]string, 0, len(p.header))
+	for k := range p.header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range p.header[k] {
+			fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+	if _, err := io.WriteString(w, "\r\n"); err != nil {
+		return err
+	}
+	if p.body != nil {
+		return p.body(w)
+	}
+
+	for _, child := range p.parts {
+		if _, err := fmt.Fprintf(w, "\r\n--%s\r\n", p.boundary); err != nil {
+			return err
+		}
+		if err := child.write(w); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "\r\n--%s--\r\n", p.boundary)
+	return err
+}
+
+// textPart returns a part holding text of the content type, encoded as quoted-printable.
+func textPart(contentType, text string) *emlPart {
+	p := &emlPart{header: make(textproto.MIMEHeader)}
+	p.header.Set("Content-Type", contentType+"; charset=utf-8")
+	p.header.Set("Content-Transfer-Encoding", "quoted-printable")
+	p.body = func(w io.Writer) error {
+		qp := quotedprintable.NewWriter(w)
+		if _, err := io.WriteString(qp, text); err != nil {
+			return err
+		}
+		return qp.Close()
+	}
+	return p
+}
+
+// filePart returns an attachment or inline part holding the file, encoded as base64.
+func filePart(key, name, contentType string, data []byte) *emlPart {
+	p := &emlPart{header: make(textproto.MIMEHeader)}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = name
+	p.header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+	p.header.Set("Content-Transfer-Encoding", "base64")
+	disposition := "attachment"
+	if key == "inline" {
+		disposition = "inline"
+		// Mailgun uses the filename of an inline as its Content-ID
+		p.header.Set("Content-Id", "<"+name+">")
+	}
+	p.header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+	p.body = func(w io.Writer) error {
+		enc := base64.NewEncoder(base64.StdEncoding, &lineWriter{w: w, max: 76})
+		if _, err := enc.Write(data); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return p
+}
+
+// lineWriter breaks what is written to it into lines of max bytes.
+type lineWriter struct {
+	w   io.Writer
+	max int
+	n   int
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if l.n == l.max {
+			if _, err := io.WriteString(l.w, "\r\n"); err != nil {
+				return written, err
+			}
+			l.n = 0
+		}
+		chunk := p
+		if len(chunk) > l.max-l.n {
+			chunk = chunk[:l.max-l.n]
+		}
+		n, err := l.w.Write(chunk)
+		written += n
+		l.n += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,94 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestWriteEML(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	m := mg.NewMessage("Zoë <root@"+testDomain+">", "Grüße", "Hello, world", "joe@example.com", "ann@example.com")
+	m.AddCC("cc@example.com")
+	m.AddBCC("hidden@example.com")
+	m.SetHtml(`<p>Hello, <img src="logo.png"></p>`)
+	m.AddHeader("X-Campaign", "spring")
+	m.AddBufferAttachment("report.pdf", []byte("%PDF-1.4 report"))
+	m.SetInlineImageRewrite(true)
+	cid := m.AddInlineImage(strings.NewReader("png data"), "logo.png")
+
+	var buf bytes.Buffer
+	ensure.Nil(t, m.WriteEML(&buf))
+
+	msg, err := mail.ReadMessage(&buf)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, msg.Header.Get("To"), "joe@example.com, ann@example.com")
+	ensure.DeepEqual(t, msg.Header.Get("Cc"), "cc@example.com")
+	ensure.DeepEqual(t, msg.Header.Get("Bcc"), "")
+	ensure.DeepEqual(t, msg.Header.Get("X-Campaign"), "spring")
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, subject, "Grüße")
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, from.Name, "Zoë")
+
+	// mixed(related(alternative(text, html), inline), attachment)
+	mixed := readParts(t, msg.Header.Get("Content-Type"), msg.Body, "multipart/mixed")
+	ensure.DeepEqual(t, len(mixed), 2)
+	related := readParts(t, mixed[0].Header.Get("Content-Type"), mixed[0], "multipart/related")
+	ensure.DeepEqual(t, len(related), 2)
+	alternative := readParts(t, related[0].Header.Get("Content-Type"), related[0], "multipart/alternative")
+	ensure.DeepEqual(t, len(alternative), 2)
+
+	text, err := ioutil.ReadAll(alternative[0])
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, string(text), "Hello, world")
+	html, err := ioutil.ReadAll(alternative[1])
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, string(html), `<p>Hello, <img src="cid:`+cid+`"></p>`)
+
+	ensure.DeepEqual(t, related[1].Header.Get("Content-Id"), "<"+cid+">")
+	ensure.DeepEqual(t, related[1].Header.Get("Content-Type"), `image/png; name=logo.png`)
+	ensure.DeepEqual(t, mixed[1].FileName(), "report.pdf")
+	ensure.DeepEqual(t, mixed[1].Header.Get("Content-Type"), "application/pdf; name=report.pdf")
+}
+
+// emlPart is a part of a multipart body, read into memory.
+type emlPart struct {
+	*bytes.Reader
+	Header   textproto.MIMEHeader
+	filename string
+}
+
+func (p *emlPart) FileName() string { return p.filename }
+
+// readParts reads the parts of a multipart body of the wanted type. Quoted-printable parts are decoded.
+func readParts(t *testing.T, contentType string, body io.Reader, want string) []*emlPart {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, mediaType, want)
+
+	var parts []*emlPart
+	r := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		ensure.Nil(t, err)
+		data, err := ioutil.ReadAll(p)
+		ensure.Nil(t, err)
+		parts = append(parts, &emlPart{Reader: bytes.NewReader(data), Header: p.Header, filename: p.FileName()})
+	}
+	return parts
+}
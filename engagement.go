@@ -0,0 +1,302 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+const (
+	// DefaultEngagementFlushInterval is how often an EngagementTracker updates members, unless its
+	// options set another interval.
+	DefaultEngagementFlushInterval = time.Minute
+	// DefaultEngagementBatchSize is the number of members with pending updates which make an
+	// EngagementTracker update members before its interval elapses, unless its options set another.
+	DefaultEngagementBatchSize = 100
+)
+
+// The vars of list members an EngagementTracker maintains.
+const (
+	// LastOpenedVar holds the time the member last opened a message, in RFC 3339 format.
+	LastOpenedVar = "last_opened_at"
+	// ClickCountVar holds the number of clicks of the member on links of messages.
+	ClickCountVar = "click_count"
+)
+
+// EngagementOptions controls an EngagementTracker.
+type EngagementOptions struct {
+	// FlushInterval is how often pending updates are applied; defaults to
+	// DefaultEngagementFlushInterval.
+	FlushInterval time.Duration
+	// BatchSize is the number of members with pending updates which has them applied at once,
+	// without waiting for FlushInterval; defaults to DefaultEngagementBatchSize.
+	BatchSize int
+}
+
+// EngagementTracker records opened and clicked events in the vars of the mailing list members
+// they concern, so lists can be segmented by engagement right in Mailgun: LastOpenedVar holds the
+// time of the last open of a member, and ClickCountVar the number of their clicks. Events are
+// coalesced per member, and applied in the background with a `GetMember()` and an
+// `UpdateMember()` for each member, at the pace of the client's other bulk helpers.
+//
+//  t := mg.TrackEngagement(ctx, nil)
+//  defer t.Close(context.Background())
+//
+//  d := mailgun.NewWebhookDispatcher(signingKey)
+//  d.On(events.EventOpened, t.Handler())
+//  d.On(events.EventClicked, t.Handler())
+//
+// Webhooks are acknowledged once their event is recorded, before members are updated, so updates
+// pending when the process exits are lost; call Close to apply them. Updates which fail are kept,
+// and tried again at the next flush. As vars are read and written back, changes made to the vars
+// of a member by others between the two requests are lost.
+type EngagementTracker struct {
+	mg        *MailgunImpl
+	interval  time.Duration
+	batchSize int
+	full      chan struct{}
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	mu      sync.Mutex
+	pending map[engagementKey]engagementUpdate
+	err     error
+}
+
+// engagementKey is a member of a mailing list, by their normalized addresses.
+type engagementKey struct {
+	list    string
+	address string
+}
+
+// engagementUpdate is the engagement of a member not yet recorded in their vars.
+type engagementUpdate struct {
+	lastOpened time.Time
+	clicks     int
+}
+
+func (u engagementUpdate) merge(o engagementUpdate) engagementUpdate {
+	if o.lastOpened.After(u.lastOpened) {
+		u.lastOpened = o.lastOpened
+	}
+	u.clicks += o.clicks
+	return u
+}
+
+// TrackEngagement starts an EngagementTracker, which applies the updates it records until the
+// context is cancelled or `EngagementTracker.Close()` is called. opts may be nil.
+func (mg *MailgunImpl) TrackEngagement(ctx context.Context, opts *EngagementOptions) *EngagementTracker {
+	var o EngagementOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = DefaultEngagementFlushInterval
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultEngagementBatchSize
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	t := &EngagementTracker{
+		mg:        mg,
+		interval:  o.FlushInterval,
+		batchSize: o.BatchSize,
+		full:      make(chan struct{}, 1),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		pending:   make(map[engagementKey]engagementUpdate),
+	}
+	go t.run(ctx)
+	return t
+}
+
+// Handler returns a handler for the opened and clicked events of a WebhookDispatcher, which
+// records the engagement of the recipient with the mailing lists the event concerns: the list
+// named by the event, or else each address of the To header of the message. Events of other
+// names are ignored.
+func (t *EngagementTracker) Handler() WebhookHandler {
+	return func(ctx context.Context, event Event) error {
+		t.Record(event)
+		return nil
+	}
+}
+
+// Record records the engagement of an opened or clicked event, as the handler returned by
+// `Handler()` does, and reports whether the event was recorded. Use it to record events from the
+// events api.
+func (t *EngagementTracker) Record(event Event) bool {
+	var lists []string
+	var recipient string
+	var u engagementUpdate
+	switch e := event.(type) {
+	case *events.Opened:
+		lists = eventLists(e.MailingList.Address, e.Message.Headers.To, e.Recipient)
+		recipient = e.Recipient
+		u.lastOpened = e.GetTimestamp().UTC()
+	case *events.Clicked:
+		lists = eventLists(e.MailingList.Address, e.Message.Headers.To, e.Recipient)
+		recipient = e.Recipient
+		u.clicks = 1
+	default:
+		return false
+	}
+	if recipient == "" || len(lists) == 0 {
+		return false
+	}
+
+	t.mu.Lock()
+	for _, list := range lists {
+		key := engagementKey{list: normalizeAddress(list), address: normalizeAddress(recipient)}
+		t.pending[key] = t.pending[key].merge(u)
+	}
+	full := len(t.pending) >= t.batchSize
+	t.mu.Unlock()
+
+	if full {
+		select {
+		case t.full <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// Pending returns the number of members with updates not yet applied.
+func (t *EngagementTracker) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+// Err returns the error of the last flush, or nil if it succeeded.
+func (t *EngagementTracker) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// Close stops the background flushes, and applies the pending updates.
+func (t *EngagementTracker) Close(ctx context.Context) error {
+	t.cancel()
+	<-t.done
+	return t.Flush(ctx)
+}
+
+// Flush applies the pending updates now. Members which are not on a list are skipped. It returns
+// a *MultiError if the update of some members failed; those updates are kept, and tried again at
+// the next flush.
+func (t *EngagementTracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	batch := t.pending
+	t.pending = make(map[engagementKey]engagementUpdate)
+	t.mu.Unlock()
+
+	keys := make([]engagementKey, 0, len(batch))
+	for key := range batch {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].list != keys[j].list {
+			return keys[i].list < keys[j].list
+		}
+		return keys[i].address < keys[j].address
+	})
+
+	limiter := t.mg.bulkLimiter()
+	exec := BatchExecutor{Concurrency: bulkMaxConcurrency, Mode: ContinueOnError}
+	err := exec.Run(ctx, len(keys), func(ctx context.Context, i int) error {
+		return t.apply(ctx, limiter, keys[i], batch[keys[i]])
+	})
+	if merr, ok := err.(*MultiError); ok {
+		t.mu.Lock()
+		for _, e := range merr.Errors {
+			key := keys[e.Index]
+			t.pending[key] = batch[key].merge(t.pending[key])
+		}
+		t.mu.Unlock()
+	}
+
+	t.mu.Lock()
+	t.err = err
+	t.mu.Unlock()
+	return err
+}
+
+// apply records the update in the vars of the member.
+func (t *EngagementTracker) apply(ctx context.Context, l *bulkLimiter, key engagementKey, u engagementUpdate) error {
+	var m Member
+	err := l.do(ctx, func() (err error) {
+		m, err = t.mg.GetMember(ctx, key.address, key.list)
+		return err
+	})
+	if GetStatusFromErr(err) == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	vars := make(map[string]interface{}, len(m.Vars)+2)
+	for k, v := range m.Vars {
+		vars[k] = v
+	}
+	if !u.lastOpened.IsZero() {
+		s, _ := vars[LastOpenedVar].(string)
+		if last, err := time.Parse(time.RFC3339, s); err != nil || u.lastOpened.After(last) {
+			vars[LastOpenedVar] = u.lastOpened.Format(time.RFC3339)
+		}
+	}
+	if u.clicks != 0 {
+		vars[ClickCountVar] = varCount(vars[ClickCountVar]) + u.clicks
+	}
+
+	return l.do(ctx, func() error {
+		_, err := t.mg.UpdateMember(ctx, key.address, key.list, Member{Vars: vars})
+		return err
+	})
+}
+
+// varCount returns the count held by a var, as decoded from JSON, or zero if it holds none.
+func varCount(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	case string:
+		i, _ := strconv.Atoi(n)
+		return i
+	}
+	return 0
+}
+
+func (t *EngagementTracker) run(ctx context.Context) {
+	defer close(t.done)
+	clk := t.mg.clock()
+	for {
+		if err := t.wait(ctx, clk); err != nil {
+			return
+		}
+		t.Flush(ctx)
+	}
+}
+
+// wait waits for the flush interval, or until the batch is full.
+func (t *EngagementTracker) wait(ctx context.Context, clk clock) error {
+	sleepCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-t.full:
+			cancel()
+		case <-sleepCtx.Done():
+		}
+	}()
+	clk.Sleep(sleepCtx, t.interval)
+	return ctx.Err()
+}
@@ -0,0 +1,63 @@
+package mailgun_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+func TestEngagementTracker(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+
+	ctx := context.Background()
+	address := randomEmail("list", testDomain)
+	_, err := mg.CreateMailingList(ctx, mailgun.MailingList{Address: address, Name: address})
+	ensure.Nil(t, err)
+	defer func() {
+		ensure.Nil(t, mg.DeleteMailingList(ctx, address))
+	}()
+	ensure.Nil(t, mg.CreateMember(ctx, true, address, mailgun.Member{
+		Address: "joe@example.com",
+		Vars:    map[string]interface{}{"plan": "pro", mailgun.ClickCountVar: 2},
+	}))
+
+	opened := func(recipient string, ts time.Time) *events.Opened {
+		e := &events.Opened{Recipient: recipient}
+		e.MailingList.Address = address
+		e.SetTimestamp(ts)
+		return e
+	}
+	clicked := func(recipient, to string) *events.Clicked {
+		e := &events.Clicked{Recipient: recipient}
+		e.Message.Headers.To = to
+		return e
+	}
+
+	tracker := mg.TrackEngagement(ctx, &mailgun.EngagementOptions{FlushInterval: time.Hour})
+	h := tracker.Handler()
+	last := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	ensure.Nil(t, h(ctx, opened("joe@example.com", last)))
+	ensure.Nil(t, h(ctx, opened("Joe@Example.com", last.Add(-time.Hour))))
+	ensure.Nil(t, h(ctx, clicked("joe@example.com", address)))
+	ensure.Nil(t, h(ctx, clicked("joe@example.com", "Joe <joe@example.com>, "+address)))
+	// Members not on the list are skipped
+	ensure.Nil(t, h(ctx, clicked("nobody@example.com", address)))
+	ensure.False(t, tracker.Record(&events.Delivered{Recipient: "joe@example.com"}))
+	ensure.False(t, tracker.Record(clicked("joe@example.com", "")))
+	ensure.DeepEqual(t, tracker.Pending(), 2)
+
+	ensure.Nil(t, tracker.Close(ctx))
+	ensure.DeepEqual(t, tracker.Pending(), 0)
+	ensure.Nil(t, tracker.Err())
+
+	joe, err := mg.GetMember(ctx, "joe@example.com", address)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, joe.Vars["plan"], "pro")
+	ensure.DeepEqual(t, joe.Vars[mailgun.LastOpenedVar], "2021-03-01T12:00:00Z")
+	ensure.DeepEqual(t, joe.Vars[mailgun.ClickCountVar], 4.0)
+}
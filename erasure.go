@@ -0,0 +1,146 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// Suppression lists an address is removed from by `EraseRecipient()`.
+const (
+	ErasedBounces      = "bounces"
+	ErasedUnsubscribes = "unsubscribes"
+	ErasedComplaints   = "complaints"
+)
+
+// ErasureReport records what `EraseRecipient()` removed.
+type ErasureReport struct {
+	// Address is the erased address.
+	Address string
+	// Lists are the addresses of the mailing lists the address was a member of.
+	Lists []string
+	// Suppressions are the suppression lists the address was on: ErasedBounces, ErasedUnsubscribes
+	// or ErasedComplaints.
+	Suppressions []string
+	// StoredMessages are the storage URLs of the messages deleted.
+	StoredMessages []string
+	// Errors are the steps which failed. The erasure carries on past them, so erase the address
+	// again to retry them.
+	Errors []error
+}
+
+// EraseRecipient removes every trace of the address Mailgun keeps which the API can remove, as
+// data protection laws such as the GDPR may require: it is removed from all mailing lists of the
+// account, and from the bounce, unsubscribe and complaint lists of the domain, and the stored
+// messages sent to or from it are deleted. Stored messages are found through the events of the
+// domain, so only messages within its event retention period are deleted; logs and events
+// themselves cannot be deleted through the API. Steps which fail are recorded in the report and
+// the erasure carries on; the error returned is the first of them.
+//
+//  report, err := mg.EraseRecipient(ctx, "user@example.com")
+//  if err != nil {
+//    return err
+//  }
+//  log.Printf("removed from %d lists", len(report.Lists))
+func (mg *MailgunImpl) EraseRecipient(ctx context.Context, address string) (*ErasureReport, error) {
+	report := &ErasureReport{Address: address}
+	l := mg.bulkLimiter()
+	// erase calls f, and reports whether it removed something; not found errors mean there was
+	// nothing to remove
+	erase := func(step string, f func() error) bool {
+		err := l.do(ctx, f)
+		if err == nil {
+			return true
+		}
+		if GetStatusFromErr(err) != http.StatusNotFound {
+			report.Errors = append(report.Errors, errors.Wrapf(err, "while %s", step))
+		}
+		return false
+	}
+
+	lists, err := mg.ListMailingListsAll(ctx, nil)
+	if err != nil {
+		report.Errors = append(report.Errors, errors.Wrap(err, "while listing mailing lists"))
+	}
+	for _, list := range lists {
+		if ctx.Err() != nil {
+			break
+		}
+		if erase("removing from mailing list '"+list.Address+"'", func() error {
+			return mg.DeleteMember(ctx, address, list.Address)
+		}) {
+			report.Lists = append(report.Lists, list.Address)
+		}
+	}
+
+	suppressions := []struct {
+		name   string
+		delete func(context.Context, string) error
+	}{
+		{ErasedBounces, mg.DeleteBounce},
+		{ErasedUnsubscribes, mg.DeleteUnsubscribe},
+		{ErasedComplaints, mg.DeleteComplaint},
+	}
+	for _, s := range suppressions {
+		if ctx.Err() != nil {
+			break
+		}
+		del := s.delete
+		if erase("removing from "+s.name, func() error { return del(ctx, address) }) {
+			report.Suppressions = append(report.Suppressions, s.name)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, filter := range []string{"recipient", "from"} {
+		if ctx.Err() != nil {
+			break
+		}
+		it := mg.ListEvents(&ListEventOptions{Filter: map[string]string{filter: address}})
+		var page []Event
+		for it.Next(ctx, &page) {
+			for _, e := range page {
+				url := eventStorage(e).URL
+				if url == "" || seen[url] {
+					continue
+				}
+				seen[url] = true
+				if erase("deleting stored message '"+url+"'", func() error {
+					return mg.DeleteStoredMessage(ctx, url)
+				}) {
+					report.StoredMessages = append(report.StoredMessages, url)
+				}
+			}
+		}
+		if err := it.Err(); err != nil {
+			report.Errors = append(report.Errors, errors.Wrapf(err, "while listing events by %s", filter))
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		report.Errors = append(report.Errors, err)
+	}
+	if len(report.Errors) != 0 {
+		return report, report.Errors[0]
+	}
+	return report, nil
+}
+
+// eventStorage returns the storage of the message of the event, which is empty if it has none.
+func eventStorage(e Event) events.Storage {
+	switch e := e.(type) {
+	case *events.Accepted:
+		return e.Storage
+	case *events.Rejected:
+		return e.Storage
+	case *events.Delivered:
+		return e.Storage
+	case *events.Failed:
+		return e.Storage
+	case *events.Stored:
+		return e.Storage
+	}
+	return events.Storage{}
+}
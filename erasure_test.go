@@ -0,0 +1,81 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestEraseRecipient(t *testing.T) {
+	const address = "erase@example.com"
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			switch r.URL.Path {
+			case "/v3/lists/other@example.com/members/" + address, "/v3/" + testDomain + "/complaints/" + address:
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"message": "not found"}`)
+			case "/v3/domains/" + testDomain + "/messages/broken":
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, `{"message": "try again"}`)
+			default:
+				fmt.Fprint(w, `{"message": "deleted"}`)
+			}
+		case r.URL.Path == "/v3/lists/pages":
+			if q.Get("page") != "" {
+				fmt.Fprint(w, `{"items": [], "paging": {}}`)
+				return
+			}
+			fmt.Fprintf(w, `{"items": [{"address": "news@example.com"}, {"address": "other@example.com"}],
+				"paging": {"next": "http://%s/v3/lists/pages?page=next"}}`, r.Host)
+		case r.URL.Path == "/v3/"+testDomain+"/events":
+			if q.Get("page") != "" {
+				fmt.Fprint(w, `{"items": [], "paging": {}}`)
+				return
+			}
+			storage := fmt.Sprintf("http://%s/v3/domains/%s/messages/", r.Host, testDomain)
+			if q.Get("recipient") == address {
+				fmt.Fprintf(w, `{"items": [
+					{"event": "accepted", "id": "1", "storage": {"url": "%[1]ssent"}},
+					{"event": "delivered", "id": "2", "storage": {"url": "%[1]ssent"}},
+					{"event": "opened", "id": "3"}
+				], "paging": {"next": "http://%[2]s/v3/%[3]s/events?page=2"}}`, storage, r.Host, testDomain)
+				return
+			}
+			ensure.DeepEqual(t, q.Get("from"), address)
+			fmt.Fprintf(w, `{"items": [{"event": "stored", "id": "4", "storage": {"url": "%sbroken"}}],
+				"paging": {"next": "http://%s/v3/%s/events?page=2"}}`, storage, r.Host, testDomain)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	report, err := mg.EraseRecipient(context.Background(), address)
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "while deleting stored message")
+	ensure.DeepEqual(t, report.Lists, []string{"news@example.com"})
+	ensure.DeepEqual(t, report.Suppressions, []string{mailgun.ErasedBounces, mailgun.ErasedUnsubscribes})
+	ensure.DeepEqual(t, report.StoredMessages, []string{srv.URL + "/v3/domains/" + testDomain + "/messages/sent"})
+	ensure.DeepEqual(t, len(report.Errors), 1)
+	ensure.DeepEqual(t, deleted, []string{
+		"/v3/lists/news@example.com/members/" + address,
+		"/v3/lists/other@example.com/members/" + address,
+		"/v3/" + testDomain + "/bounces/" + address,
+		"/v3/" + testDomain + "/unsubscribes/" + address,
+		"/v3/" + testDomain + "/complaints/" + address,
+		"/v3/domains/" + testDomain + "/messages/sent",
+		"/v3/domains/" + testDomain + "/messages/broken",
+	})
+}
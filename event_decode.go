@@ -0,0 +1,458 @@
+package mailgun
+
+import (
+	"bytes"
+	"encoding/json"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// eventDecoders decode the events most consumers process in volume field by field, with no
+// reflection, keeping the fields they do not model in Extra as they go. They are used when the
+// client has no codec of its own; other events are decoded with reflection.
+var eventDecoders = map[string]func(iter *jsoniter.Iterator) Event{
+	events.EventAccepted:  decodeAccepted,
+	events.EventDelivered: decodeDelivered,
+	events.EventFailed:    decodeFailed,
+	events.EventOpened:    decodeOpened,
+	events.EventClicked:   decodeClicked,
+}
+
+// decodeEvent decodes raw with the decoder of the event named name, and reports whether there is one.
+func decodeEvent(name string, raw []byte) (Event, bool, error) {
+	decode, ok := eventDecoders[name]
+	if !ok {
+		return nil, false, nil
+	}
+	iter := jsoniter.ConfigFastest.BorrowIterator(raw)
+	defer jsoniter.ConfigFastest.ReturnIterator(iter)
+	event := decode(iter)
+	if iter.Error != nil {
+		return nil, true, iter.Error
+	}
+	return event, true, nil
+}
+
+func decodeAccepted(iter *jsoniter.Iterator) Event {
+	e := new(events.Accepted)
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "envelope":
+			decodeEnvelope(iter, &e.Envelope)
+		case "message":
+			decodeMessage(iter, &e.Message)
+		case "flags":
+			decodeFlags(iter, &e.Flags)
+		case "recipient":
+			e.Recipient = iter.ReadString()
+		case "recipient-domain":
+			e.RecipientDomain = iter.ReadString()
+		case "method":
+			e.Method = iter.ReadString()
+		case "originating-ip":
+			e.OriginatingIP = iter.ReadString()
+		case "tags":
+			e.Tags = readStrings(iter)
+		case "campaigns":
+			e.Campaigns = readCampaigns(iter)
+		case "user-variables":
+			e.UserVariables = iter.Read()
+		case "storage":
+			decodeStorage(iter, &e.Storage)
+		default:
+			decodeGeneric(iter, field, &e.Generic)
+		}
+	}
+	return e
+}
+
+func decodeDelivered(iter *jsoniter.Iterator) Event {
+	e := new(events.Delivered)
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "envelope":
+			decodeEnvelope(iter, &e.Envelope)
+		case "message":
+			decodeMessage(iter, &e.Message)
+		case "flags":
+			decodeFlags(iter, &e.Flags)
+		case "recipient":
+			e.Recipient = iter.ReadString()
+		case "recipient-domain":
+			e.RecipientDomain = iter.ReadString()
+		case "method":
+			e.Method = iter.ReadString()
+		case "tags":
+			e.Tags = readStrings(iter)
+		case "campaigns":
+			e.Campaigns = readCampaigns(iter)
+		case "storage":
+			decodeStorage(iter, &e.Storage)
+		case "delivery-status":
+			decodeDeliveryStatus(iter, &e.DeliveryStatus)
+		case "user-variables":
+			e.UserVariables = iter.Read()
+		default:
+			decodeGeneric(iter, field, &e.Generic)
+		}
+	}
+	return e
+}
+
+func decodeFailed(iter *jsoniter.Iterator) Event {
+	e := new(events.Failed)
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "envelope":
+			decodeEnvelope(iter, &e.Envelope)
+		case "message":
+			decodeMessage(iter, &e.Message)
+		case "flags":
+			decodeFlags(iter, &e.Flags)
+		case "mailing-list":
+			decodeMailingList(iter, &e.MailingList)
+		case "recipient":
+			e.Recipient = iter.ReadString()
+		case "recipient-domain":
+			e.RecipientDomain = iter.ReadString()
+		case "method":
+			e.Method = iter.ReadString()
+		case "tags":
+			e.Tags = readStrings(iter)
+		case "campaigns":
+			e.Campaigns = readCampaigns(iter)
+		case "storage":
+			decodeStorage(iter, &e.Storage)
+		case "delivery-status":
+			decodeDeliveryStatus(iter, &e.DeliveryStatus)
+		case "severity":
+			e.Severity = iter.ReadString()
+		case "reason":
+			e.Reason = iter.ReadString()
+		case "user-variables":
+			e.UserVariables = iter.Read()
+		default:
+			decodeGeneric(iter, field, &e.Generic)
+		}
+	}
+	return e
+}
+
+func decodeOpened(iter *jsoniter.Iterator) Event {
+	e := new(events.Opened)
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "message":
+			decodeMessage(iter, &e.Message)
+		case "campaigns":
+			e.Campaigns = readCampaigns(iter)
+		case "mailing-list":
+			decodeMailingList(iter, &e.MailingList)
+		case "recipient":
+			e.Recipient = iter.ReadString()
+		case "recipient-domain":
+			e.RecipientDomain = iter.ReadString()
+		case "tags":
+			e.Tags = readStrings(iter)
+		case "ip":
+			e.IP = iter.ReadString()
+		case "client-info":
+			decodeClientInfo(iter, &e.ClientInfo)
+		case "geolocation":
+			decodeGeoLocation(iter, &e.GeoLocation)
+		case "user-variables":
+			e.UserVariables = iter.Read()
+		default:
+			decodeGeneric(iter, field, &e.Generic)
+		}
+	}
+	return e
+}
+
+func decodeClicked(iter *jsoniter.Iterator) Event {
+	e := new(events.Clicked)
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "url":
+			e.Url = iter.ReadString()
+		case "message":
+			decodeMessage(iter, &e.Message)
+		case "campaigns":
+			e.Campaigns = readCampaigns(iter)
+		case "mailing-list":
+			decodeMailingList(iter, &e.MailingList)
+		case "recipient":
+			e.Recipient = iter.ReadString()
+		case "recipient-domain":
+			e.RecipientDomain = iter.ReadString()
+		case "tags":
+			e.Tags = readStrings(iter)
+		case "ip":
+			e.IP = iter.ReadString()
+		case "client-info":
+			decodeClientInfo(iter, &e.ClientInfo)
+		case "geolocation":
+			decodeGeoLocation(iter, &e.GeoLocation)
+		case "user-variables":
+			e.UserVariables = iter.Read()
+		default:
+			decodeGeneric(iter, field, &e.Generic)
+		}
+	}
+	return e
+}
+
+// decodeGeneric decodes a field of events.Generic, keeping fields which are not one of them in Extra.
+func decodeGeneric(iter *jsoniter.Iterator, field string, g *events.Generic) {
+	switch field {
+	case "event":
+		g.Name = iter.ReadString()
+	case "timestamp":
+		g.Timestamp = readFloat(iter)
+	case "id":
+		g.ID = iter.ReadString()
+	default:
+		if g.Extra == nil {
+			g.Extra = make(map[string]json.RawMessage)
+		}
+		g.Extra[field] = append(json.RawMessage(nil), bytes.TrimSpace(iter.SkipAndReturnBytes())...)
+	}
+}
+
+func decodeEnvelope(iter *jsoniter.Iterator, e *events.Envelope) {
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "mail-from":
+			e.MailFrom = iter.ReadString()
+		case "sender":
+			e.Sender = iter.ReadString()
+		case "transport":
+			e.Transport = iter.ReadString()
+		case "targets":
+			e.Targets = iter.ReadString()
+		case "sending-host":
+			e.SendingHost = iter.ReadString()
+		case "sending-ip":
+			e.SendingIP = iter.ReadString()
+		default:
+			iter.Skip()
+		}
+	}
+}
+
+func decodeMessage(iter *jsoniter.Iterator, m *events.Message) {
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "headers":
+			decodeMessageHeaders(iter, &m.Headers)
+		case "attachments":
+			m.Attachments = readEventAttachments(iter)
+		case "recipients":
+			m.Recipients = readStrings(iter)
+		case "size":
+			m.Size = readInt(iter)
+		default:
+			iter.Skip()
+		}
+	}
+}
+
+func decodeMessageHeaders(iter *jsoniter.Iterator, h *events.MessageHeaders) {
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "to":
+			h.To = iter.ReadString()
+		case "message-id":
+			h.MessageID = iter.ReadString()
+		case "from":
+			h.From = iter.ReadString()
+		case "subject":
+			h.Subject = iter.ReadString()
+		default:
+			iter.Skip()
+		}
+	}
+}
+
+func readEventAttachments(iter *jsoniter.Iterator) []events.Attachment {
+	if iter.ReadNil() {
+		return nil
+	}
+	attachments := []events.Attachment{}
+	for iter.ReadArray() {
+		var a events.Attachment
+		for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+			switch field {
+			case "filename":
+				a.FileName = iter.ReadString()
+			case "content-type":
+				a.ContentType = iter.ReadString()
+			case "size":
+				a.Size = readInt(iter)
+			default:
+				iter.Skip()
+			}
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments
+}
+
+func decodeFlags(iter *jsoniter.Iterator, f *events.Flags) {
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "is-authenticated":
+			f.IsAuthenticated = readBool(iter)
+		case "is-big":
+			f.IsBig = readBool(iter)
+		case "is-system-test":
+			f.IsSystemTest = readBool(iter)
+		case "is-test-mode":
+			f.IsTestMode = readBool(iter)
+		case "is-delayed-bounce":
+			f.IsDelayedBounce = readBool(iter)
+		default:
+			iter.Skip()
+		}
+	}
+}
+
+func decodeStorage(iter *jsoniter.Iterator, s *events.Storage) {
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "key":
+			s.Key = iter.ReadString()
+		case "url":
+			s.URL = iter.ReadString()
+		default:
+			iter.Skip()
+		}
+	}
+}
+
+func decodeMailingList(iter *jsoniter.Iterator, l *events.MailingList) {
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "address":
+			l.Address = iter.ReadString()
+		case "list-id":
+			l.ListID = iter.ReadString()
+		case "sid":
+			l.SID = iter.ReadString()
+		default:
+			iter.Skip()
+		}
+	}
+}
+
+func decodeDeliveryStatus(iter *jsoniter.Iterator, s *events.DeliveryStatus) {
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "code":
+			s.Code = readInt(iter)
+		case "attempt-no":
+			s.AttemptNo = readInt(iter)
+		case "description":
+			s.Description = iter.ReadString()
+		case "message":
+			s.Message = iter.ReadString()
+		case "session-seconds":
+			s.SessionSeconds = readFloat(iter)
+		default:
+			iter.Skip()
+		}
+	}
+}
+
+func decodeClientInfo(iter *jsoniter.Iterator, c *events.ClientInfo) {
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "accept-language":
+			c.AcceptLanguage = iter.ReadString()
+		case "client-name":
+			c.ClientName = iter.ReadString()
+		case "client-os":
+			c.ClientOS = iter.ReadString()
+		case "client-type":
+			c.ClientType = iter.ReadString()
+		case "device-type":
+			c.DeviceType = iter.ReadString()
+		case "ip":
+			c.IP = iter.ReadString()
+		case "user-agent":
+			c.UserAgent = iter.ReadString()
+		default:
+			iter.Skip()
+		}
+	}
+}
+
+func decodeGeoLocation(iter *jsoniter.Iterator, g *events.GeoLocation) {
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "city":
+			g.City = iter.ReadString()
+		case "country":
+			g.Country = iter.ReadString()
+		case "region":
+			g.Region = iter.ReadString()
+		default:
+			iter.Skip()
+		}
+	}
+}
+
+func readCampaigns(iter *jsoniter.Iterator) []events.Campaign {
+	if iter.ReadNil() {
+		return nil
+	}
+	campaigns := []events.Campaign{}
+	for iter.ReadArray() {
+		var c events.Campaign
+		for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+			switch field {
+			case "id":
+				c.ID = iter.ReadString()
+			case "name":
+				c.Name = iter.ReadString()
+			default:
+				iter.Skip()
+			}
+		}
+		campaigns = append(campaigns, c)
+	}
+	return campaigns
+}
+
+func readStrings(iter *jsoniter.Iterator) []string {
+	if iter.ReadNil() {
+		return nil
+	}
+	values := []string{}
+	for iter.ReadArray() {
+		values = append(values, iter.ReadString())
+	}
+	return values
+}
+
+func readInt(iter *jsoniter.Iterator) int {
+	if iter.ReadNil() {
+		return 0
+	}
+	return iter.ReadInt()
+}
+
+func readFloat(iter *jsoniter.Iterator) float64 {
+	if iter.ReadNil() {
+		return 0
+	}
+	return iter.ReadFloat64()
+}
+
+func readBool(iter *jsoniter.Iterator) bool {
+	if iter.ReadNil() {
+		return false
+	}
+	return iter.ReadBool()
+}
@@ -0,0 +1,84 @@
+package mailgun
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+)
+
+const decodePayload = `{
+	"event": "%s",
+	"id": "W3X4JOhFT-OZidZGKKr9iA",
+	"timestamp": 1529692198.719125,
+	"envelope": {"mail-from": "bob@example.com", "sender": "bob@example.com", "transport": "smtp",
+		"targets": "joe@example.com", "sending-host": "mail.example.com", "sending-ip": "10.0.0.1"},
+	"message": {
+		"headers": {"to": "joe@example.com", "message-id": "<id@example.com>", "from": "bob@example.com", "subject": "Hi"},
+		"attachments": [{"filename": "a.txt", "content-type": "text/plain", "size": 10}],
+		"recipients": ["joe@example.com"],
+		"size": 1024,
+		"unknown": {"nested": true}
+	},
+	"flags": {"is-authenticated": true, "is-big": false, "is-system-test": null, "is-test-mode": true, "is-delayed-bounce": false},
+	"mailing-list": {"address": "list@example.com", "list-id": "list", "sid": "sid"},
+	"recipient": "joe@example.com",
+	"recipient-domain": "example.com",
+	"method": "http",
+	"originating-ip": "10.0.0.2",
+	"tags": [],
+	"campaigns": [{"id": "c1", "name": "Campaign"}],
+	"user-variables": {"customer": 42, "plan": ["pro"]},
+	"storage": {"key": "key", "url": "https://storage.example.com/key"},
+	"delivery-status": {"code": 250, "attempt-no": 1, "description": null, "message": "OK", "session-seconds": 0.25},
+	"severity": "permanent",
+	"reason": "bounce",
+	"url": "https://example.com/link",
+	"ip": "10.0.0.3",
+	"client-info": {"accept-language": "en", "client-name": "Chrome", "client-os": "Linux", "client-type": "browser",
+		"device-type": "desktop", "ip": "10.0.0.3", "user-agent": "Mozilla/5.0"},
+	"geolocation": {"city": "Austin", "country": "US", "region": "TX"},
+	"new-field": {"added": "later"}
+}`
+
+func TestEventDecoders(t *testing.T) {
+	for name := range eventDecoders {
+		raw := []byte(fmt.Sprintf(decodePayload, name))
+		fast, ok, err := decodeEvent(name, raw)
+		ensure.Nil(t, err)
+		ensure.True(t, ok)
+
+		// The decoders decode as reflection does
+		slow := EventNames[name]()
+		ensure.Nil(t, unmarshalEvent(nil, raw, slow))
+		setEventExtra(slow, raw)
+		ensure.DeepEqual(t, fast, slow)
+	}
+
+	_, _, err := decodeEvent("delivered", []byte(`{"event": "delivered", "timestamp": "soon"}`))
+	ensure.NotNil(t, err)
+	_, ok, _ := decodeEvent("stored", []byte(`{"event": "stored"}`))
+	ensure.False(t, ok)
+}
+
+func BenchmarkParseEvent(b *testing.B) {
+	raw := []byte(fmt.Sprintf(decodePayload, "delivered"))
+	b.Run("decoder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := parseEvent(nil, raw); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reflection", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			e := EventNames["delivered"]()
+			if err := unmarshalEvent(nil, raw, e); err != nil {
+				b.Fatal(err)
+			}
+			setEventExtra(e, raw)
+		}
+	})
+}
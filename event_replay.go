@@ -0,0 +1,75 @@
+package mailgun
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+type replayKey struct{}
+
+// IsReplay reports whether the event being handled, from within a WebhookHandler, is replayed
+// from an archive by `ReplayEvents()` rather than delivered by a webhook.
+func IsReplay(ctx context.Context) bool {
+	replay, _ := ctx.Value(replayKey{}).(bool)
+	return replay
+}
+
+// ReplayError is returned by `ReplayEvents()` when an archived event cannot be parsed or its
+// handler fails.
+type ReplayError struct {
+	// Line is the line of the archive the event is on, counting from 1.
+	Line int
+	Err  error
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("while replaying the event on line %d: %s", e.Line, e.Err)
+}
+
+func (e *ReplayError) Unwrap() error { return e.Err }
+
+// ReplayEvents reads events archived as JSON lines, such as by a sink returned by
+// `NewJSONLSink()`, and passes each one to the handler d has registered for it, as if it was
+// delivered by a webhook. Backfills and reproductions of bugs so run the handlers live events run,
+// parsed as strictly as d parses webhooks. Handlers can tell replayed events apart with
+// `IsReplay()`. Events handled within the dedupe window of d, by a webhook or an earlier replay,
+// are skipped; disable deduplication with `SetDedupeWindow()` to replay an archive again. Blank
+// lines are skipped.
+//
+// Replay stops at the first event which cannot be parsed or whose handler fails, returning the
+// number of events read before it and a *ReplayError naming its line.
+//
+//  f, err := os.Open("events-2021-03-01.jsonl")
+//  n, err := mailgun.ReplayEvents(ctx, f, d)
+func ReplayEvents(ctx context.Context, r io.Reader, d *WebhookDispatcher) (int, error) {
+	ctx = context.WithValue(ctx, replayKey{}, true)
+	parser := d.eventParser()
+	br := bufio.NewReader(r)
+	var count, line int
+	for {
+		data, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return count, err
+		}
+		line++
+		if data = bytes.TrimSpace(data); len(data) != 0 {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return count, ctxErr
+			}
+			event, perr := parser.ParseEvent(data)
+			if perr != nil {
+				return count, &ReplayError{Line: line, Err: perr}
+			}
+			if herr := d.DispatchEvent(ctx, event); herr != nil {
+				return count, &ReplayError{Line: line, Err: herr}
+			}
+			count++
+		}
+		if err == io.EOF {
+			return count, nil
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+func TestReplayEvents(t *testing.T) {
+	archive := `{"event": "delivered", "id": "1", "timestamp": 1500000000, "recipient": "joe@example.com"}
+
+{"event": "failed", "id": "2", "timestamp": 1500000001, "recipient": "sam@example.com", "severity": "permanent"}
+{"event": "opened", "id": "3", "timestamp": 1500000002, "recipient": "joe@example.com"}
+{"event": "delivered", "id": "1", "timestamp": 1500000000, "recipient": "joe@example.com"}
+`
+	var handled []string
+	d := mailgun.NewWebhookDispatcher(testKey)
+	d.OnDelivered(func(ctx context.Context, e *events.Delivered) error {
+		ensure.True(t, mailgun.IsReplay(ctx))
+		ensure.DeepEqual(t, mailgun.WebhookIdempotencyKey(ctx), e.ID)
+		handled = append(handled, "delivered "+e.Recipient)
+		return nil
+	})
+	d.OnFailed(func(ctx context.Context, e *events.Failed) error {
+		handled = append(handled, "failed "+e.Recipient)
+		return nil
+	})
+
+	ctx := context.Background()
+	n, err := mailgun.ReplayEvents(ctx, strings.NewReader(archive), d)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 4)
+	// The duplicate delivery is skipped, and opens have no handler
+	ensure.DeepEqual(t, handled, []string{"delivered joe@example.com", "failed sam@example.com"})
+	ensure.False(t, mailgun.IsReplay(ctx))
+
+	// Events written by a JSONL sink replay the same way
+	var jsonl bytes.Buffer
+	sink := mailgun.NewJSONLSink(&jsonl)
+	delivered := new(events.Delivered)
+	delivered.ID, delivered.Name, delivered.Recipient = "4", events.EventDelivered, "amy@example.com"
+	ensure.Nil(t, sink.Write(ctx, delivered))
+	ensure.Nil(t, sink.Flush(ctx))
+	handled = nil
+	_, err = mailgun.ReplayEvents(ctx, &jsonl, d)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, handled, []string{"delivered amy@example.com"})
+
+	failing := errors.New("database is down")
+	d.OnFailed(func(ctx context.Context, e *events.Failed) error { return failing })
+	d.SetDedupeWindow(0)
+	n, err = mailgun.ReplayEvents(ctx, strings.NewReader(archive), d)
+	ensure.DeepEqual(t, n, 1)
+	var replayErr *mailgun.ReplayError
+	ensure.True(t, errors.As(err, &replayErr))
+	ensure.DeepEqual(t, replayErr.Line, 3)
+	ensure.True(t, errors.Is(err, failing))
+
+	n, err = mailgun.ReplayEvents(ctx, strings.NewReader("{\"event\": \"delivered\", \"id\": \"5\"}\nnot json\n"), d)
+	ensure.DeepEqual(t, n, 1)
+	ensure.True(t, errors.As(err, &replayErr))
+	ensure.DeepEqual(t, replayErr.Line, 2)
+}
@@ -0,0 +1,125 @@
+package mailgun
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// EventSchemaVersion is the version of the event payloads the types of the events package model:
+// the "event-data" of webhooks and the items of the events api, as Mailgun has sent them since
+// the legacy form encoded webhooks were retired.
+const EventSchemaVersion = 3
+
+// strictJSON decodes events for an EventParser in strict mode.
+var strictJSON = jsoniter.Config{DisallowUnknownFields: true}.Froze()
+
+// EventSchemaError is returned by an EventParser in strict mode when an event has a field the
+// events package does not model, so a change Mailgun makes to its payloads is detected rather
+// than dropped.
+type EventSchemaError struct {
+	// Event is the name of the event.
+	Event string
+	// SchemaVersion is the version of the payloads the parser expects, EventSchemaVersion.
+	SchemaVersion int
+	Err           error
+}
+
+func (e *EventSchemaError) Error() string {
+	return fmt.Sprintf("event '%s' does not match schema version %d: %s", e.Event, e.SchemaVersion, e.Err)
+}
+
+// Unwrap returns the error of the decoder.
+func (e *EventSchemaError) Unwrap() error {
+	return e.Err
+}
+
+// EventParser parses events, letting consumers choose between robustness and early detection when
+// Mailgun changes its payloads. `ParseEvent()` and the event iterators parse as the zero
+// EventParser does.
+//
+//  p := mailgun.EventParser{Strict: true}
+//  event, err := p.ParseEvent(raw)
+//  var schemaErr *mailgun.EventSchemaError
+//  if errors.As(err, &schemaErr) {
+//    log.Printf("mailgun changed the %s payload: %s", schemaErr.Event, schemaErr.Err)
+//  }
+type EventParser struct {
+	// Strict, if set, fails to parse events with fields the events package does not model, at
+	// any depth, with an *EventSchemaError. By default such fields are ignored, and the top level
+	// ones are kept in the Extra field of the event.
+	Strict bool
+}
+
+// ParseEvent converts raw bytes data into an event struct.
+func (p EventParser) ParseEvent(raw []byte) (Event, error) {
+	if !p.Strict {
+		return parseEvent(nil, raw)
+	}
+	name, err := eventName(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recognize event: %v", err)
+	}
+	newEvent, ok := EventNames[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported event: '%s'", name)
+	}
+	event := newEvent()
+	if err := strictJSON.Unmarshal(raw, event); err != nil {
+		return nil, &EventSchemaError{Event: name, SchemaVersion: EventSchemaVersion, Err: err}
+	}
+	return event, nil
+}
+
+// ParseEvents converts a slice of raw events into a slice of Event.
+func (p EventParser) ParseEvents(raw []events.RawJSON) ([]Event, error) {
+	if !p.Strict {
+		return parseEvents(nil, raw)
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	result := make([]Event, 0, len(raw))
+	for _, value := range raw {
+		event, err := p.ParseEvent(value)
+		if err != nil {
+			return nil, fmt.Errorf("while parsing event: %w", err)
+		}
+		result = append(result, event)
+	}
+	return result, nil
+}
+
+// setEventExtra keeps the top level fields of raw which have no field of the event in its Extra
+// field. The fields are found by scanning the keys of raw, so events with no extra fields cost no
+// allocations.
+func setEventExtra(event Event, raw []byte) {
+	e, ok := event.(interface {
+		SetExtra(map[string]json.RawMessage)
+	})
+	if !ok {
+		return
+	}
+	known := jsonFieldNames(reflect.TypeOf(event).Elem())
+	iter := jsoniter.ConfigFastest.BorrowIterator(raw)
+	defer jsoniter.ConfigFastest.ReturnIterator(iter)
+
+	var extra map[string]json.RawMessage
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		if known[field] {
+			iter.Skip()
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[field] = append(json.RawMessage(nil), bytes.TrimSpace(iter.SkipAndReturnBytes())...)
+	}
+	if iter.Error == nil && extra != nil {
+		e.SetExtra(extra)
+	}
+}
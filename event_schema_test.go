@@ -0,0 +1,81 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+const changedPayload = `{
+	"event": "delivered",
+	"id": "delivered-1",
+	"recipient": "joe@example.com",
+	"delivery-status": {"code": 250, "attempt-no": 1, "new-detail": "x"},
+	"recipient-provider": "Gmail"
+}`
+
+func TestEventParser(t *testing.T) {
+	e, err := mailgun.ParseEvent([]byte(changedPayload))
+	ensure.Nil(t, err)
+	delivered := e.(*events.Delivered)
+	ensure.DeepEqual(t, delivered.Recipient, "joe@example.com")
+	ensure.DeepEqual(t, delivered.Extra, map[string]json.RawMessage{"recipient-provider": json.RawMessage(`"Gmail"`)})
+
+	e, err = mailgun.ParseEvent([]byte(`{"event": "delivered", "id": "delivered-2"}`))
+	ensure.Nil(t, err)
+	ensure.True(t, e.(*events.Delivered).Extra == nil)
+
+	strict := mailgun.EventParser{Strict: true}
+	_, err = strict.ParseEvent([]byte(changedPayload))
+	var schemaErr *mailgun.EventSchemaError
+	ensure.True(t, errors.As(err, &schemaErr))
+	ensure.DeepEqual(t, schemaErr.Event, "delivered")
+	ensure.DeepEqual(t, schemaErr.SchemaVersion, mailgun.EventSchemaVersion)
+
+	_, err = strict.ParseEvents([]events.RawJSON{events.RawJSON(changedPayload)})
+	ensure.True(t, errors.As(err, &schemaErr))
+
+	e, err = strict.ParseEvent([]byte(`{"event": "delivered", "id": "delivered-2", "delivery-status": {"code": 250}}`))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, e.GetID(), "delivered-2")
+}
+
+func TestWebhookDispatcherStrictParsing(t *testing.T) {
+	const signingKey = "strict-signing-key"
+	d := mailgun.NewWebhookDispatcher(signingKey)
+	var delivered int
+	d.OnDelivered(func(ctx context.Context, e *events.Delivered) error {
+		delivered++
+		return nil
+	})
+
+	send := func() int {
+		body, err := json.Marshal(map[string]interface{}{
+			"signature":  mailgun.SignWebhook(signingKey, strconv.FormatInt(time.Now().Unix(), 10), "token-1"),
+			"event-data": json.RawMessage(changedPayload),
+		})
+		ensure.Nil(t, err)
+		w := httptest.NewRecorder()
+		d.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body)))
+		return w.Code
+	}
+
+	d.SetStrictParsing(true)
+	// Mailgun delivers the event again later
+	ensure.DeepEqual(t, send(), http.StatusInternalServerError)
+	ensure.DeepEqual(t, delivered, 0)
+
+	d.SetStrictParsing(false)
+	ensure.DeepEqual(t, send(), http.StatusOK)
+	ensure.DeepEqual(t, delivered, 1)
+}
@@ -0,0 +1,207 @@
+package mailgun
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// EventSink receives events to archive them, such as to a file, a Kafka topic or an S3 bucket.
+// Implement it to send events to a store of your own; `NewJSONLSink()` and `NewCSVSink()` write
+// them to an io.Writer.
+//
+//  type kafkaSink struct{ w *kafka.Writer }
+//
+//  func (s kafkaSink) Write(ctx context.Context, e mailgun.Event) error {
+//    value, err := json.Marshal(e)
+//    if err != nil {
+//      return err
+//    }
+//    return s.w.WriteMessages(ctx, kafka.Message{Key: []byte(e.GetID()), Value: value})
+//  }
+//
+//  func (s kafkaSink) Flush(ctx context.Context) error { return nil }
+type EventSink interface {
+	// Write archives the event. It may buffer the event until Flush is called.
+	Write(ctx context.Context, e Event) error
+	// Flush archives the events buffered by Write.
+	Flush(ctx context.Context) error
+}
+
+// DefaultCSVSinkColumns are the columns written by a CSV sink which is given none.
+var DefaultCSVSinkColumns = []string{
+	"timestamp", "id", "event", "recipient", "message.headers.message-id", "severity", "reason",
+}
+
+// writerSink writes events to a writer, one after the other; encode appends an event to the
+// buffered writer.
+type writerSink struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	encode func(e Event) error
+}
+
+func (s *writerSink) Write(ctx context.Context, e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encode(e)
+}
+
+func (s *writerSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Flush()
+}
+
+// NewJSONLSink returns a sink which writes each event to w as a line of JSON, in the form the
+// events api and webhooks return it. Events are buffered; call Flush once done. The sink is safe
+// for concurrent use.
+func NewJSONLSink(w io.Writer) EventSink {
+	s := &writerSink{w: bufio.NewWriter(w)}
+	s.encode = func(e Event) error {
+		data, err := jsoniter.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := s.w.Write(data); err != nil {
+			return err
+		}
+		return s.w.WriteByte('\n')
+	}
+	return s
+}
+
+// NewCSVSink returns a sink which writes each event to w as a row of CSV, after a header row of
+// the columns. A column is the path of a field of the event as it is named in JSON, with the
+// names of nested fields separated by dots, such as "delivery-status.code"; fields missing from
+// an event are left empty, and objects are written as JSON. The "timestamp" column is written in
+// RFC 3339 format. Defaults to DefaultCSVSinkColumns. Events are buffered; call Flush once done.
+// The sink is safe for concurrent use.
+//
+//  sink := mailgun.NewCSVSink(f, "timestamp", "event", "recipient", "delivery-status.code")
+func NewCSVSink(w io.Writer, columns ...string) EventSink {
+	if len(columns) == 0 {
+		columns = DefaultCSVSinkColumns
+	}
+	s := &writerSink{w: bufio.NewWriter(w)}
+	cw := csv.NewWriter(s.w)
+	header := false
+	s.encode = func(e Event) error {
+		if !header {
+			if err := cw.Write(columns); err != nil {
+				return err
+			}
+			header = true
+		}
+		data, err := jsoniter.Marshal(e)
+		if err != nil {
+			return err
+		}
+		var fields map[string]interface{}
+		if err := jsoniter.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			if column == "timestamp" {
+				row[i] = e.GetTimestamp().Format(time.RFC3339Nano)
+				continue
+			}
+			row[i] = csvEventField(fields, column)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return s
+}
+
+// csvEventField returns the value of the field at the dotted path of an event decoded from JSON.
+func csvEventField(fields map[string]interface{}, path string) string {
+	var value interface{} = fields
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		if value, ok = object[name]; !ok {
+			return ""
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := jsoniter.Marshal(value)
+	return string(data)
+}
+
+// ExportEvents writes every event returned by the iterator to the sink, flushes it, and returns
+// the number of events written. Pages are fetched at the pace of the client's other bulk helpers,
+// and retried when rate limited.
+//
+//  f, err := os.Create("events.jsonl")
+//  it := mg.ListEvents(&mailgun.ListEventOptions{Begin: time.Now().Add(-24 * time.Hour)})
+//  n, err := mailgun.ExportEvents(ctx, it, mailgun.NewJSONLSink(f))
+func ExportEvents(ctx context.Context, it *EventIterator, sink EventSink) (int, error) {
+	var count int
+	var page []Event
+	var writeErr error
+	err := walkPages(ctx, limiterFor(it.mg), func(ctx context.Context) bool {
+		if !it.Next(ctx, &page) {
+			return false
+		}
+		for _, e := range page {
+			if writeErr = sink.Write(ctx, e); writeErr != nil {
+				return false
+			}
+			count++
+		}
+		return true
+	}, &it.err)
+	if writeErr != nil {
+		return count, writeErr
+	}
+	if err != nil {
+		return count, err
+	}
+	return count, sink.Flush(ctx)
+}
+
+// SinkWebhooks registers handlers with d which write the events named in names, such as
+// events.EventDelivered, to the sink; by default all events which carry delivery updates are
+// written. Each event is flushed before the webhook is acknowledged, so an event the sink fails
+// to archive is delivered again later by Mailgun. Handlers registered with d for the events are
+// replaced.
+//
+//  d := mailgun.NewWebhookDispatcher(signingKey)
+//  mailgun.SinkWebhooks(d, mailgun.NewJSONLSink(f))
+//  http.Handle("/webhooks/mailgun", d)
+func SinkWebhooks(d *WebhookDispatcher, sink EventSink, names ...string) {
+	if len(names) == 0 {
+		names = webhookChannelEvents
+	}
+	for _, name := range names {
+		d.On(name, func(ctx context.Context, e Event) error {
+			if err := sink.Write(ctx, e); err != nil {
+				return err
+			}
+			return sink.Flush(ctx)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+func TestExportEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "" {
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"items": [
+			{"event": "delivered", "id": "1", "timestamp": 1500000000.5, "recipient": "joe@example.com",
+			 "message": {"headers": {"message-id": "abc@example.com"}}, "delivery-status": {"code": 250}},
+			{"event": "failed", "id": "2", "timestamp": 1500000001, "recipient": "sam@example.com",
+			 "severity": "permanent", "reason": "bounce", "delivery-status": {"code": 550}}
+		], "paging": {"next": "http://%s/v3/%s/events?page=2"}}`, r.Host, testDomain)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	var jsonl bytes.Buffer
+	n, err := mailgun.ExportEvents(ctx, mg.ListEvents(nil), mailgun.NewJSONLSink(&jsonl))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 2)
+	lines := strings.Split(strings.TrimSpace(jsonl.String()), "\n")
+	ensure.DeepEqual(t, len(lines), 2)
+	e, err := mailgun.ParseEvent([]byte(lines[1]))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, e.(*events.Failed).Reason, "bounce")
+
+	var csv bytes.Buffer
+	n, err = mailgun.ExportEvents(ctx, mg.ListEvents(nil), mailgun.NewCSVSink(&csv))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 2)
+	ensure.DeepEqual(t, csv.String(), "timestamp,id,event,recipient,message.headers.message-id,severity,reason\n"+
+		"2017-07-14T02:40:00.5Z,1,delivered,joe@example.com,abc@example.com,,\n"+
+		"2017-07-14T02:40:01Z,2,failed,sam@example.com,,permanent,bounce\n")
+
+	csv.Reset()
+	_, err = mailgun.ExportEvents(ctx, mg.ListEvents(nil), mailgun.NewCSVSink(&csv, "id", "delivery-status.code", "missing.field"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, csv.String(), "id,delivery-status.code,missing.field\n1,250,\n2,550,\n")
+}
+
+func TestSinkWebhooks(t *testing.T) {
+	const signingKey = "sink-signing-key"
+	var out bytes.Buffer
+	d := mailgun.NewWebhookDispatcher(signingKey)
+	mailgun.SinkWebhooks(d, mailgun.NewCSVSink(&out, "id", "event"), events.EventDelivered)
+
+	for _, name := range []string{events.EventDelivered, events.EventOpened} {
+		e := mailgun.EventNames[name]()
+		e.SetName(name)
+		e.SetID(name + "-1")
+		req, err := mailgun.NewSignedWebhookRequest("http://example.com/webhook", signingKey, e)
+		ensure.Nil(t, err)
+		w := httptest.NewRecorder()
+		d.ServeHTTP(w, req)
+		ensure.DeepEqual(t, w.Code, http.StatusOK)
+	}
+	// Events are flushed before they are acknowledged
+	ensure.DeepEqual(t, out.String(), "id,event\ndelivered-1,delivered\n")
+}
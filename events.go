@@ -5,10 +5,16 @@ import (
 	"fmt"
 	"time"
 
-	jsoniter "github.com/json-iterator/go"
 	"github.com/yjimk/mailgun-go/v4/events"
 )
 
+const (
+	// DefaultEventPollInterval is the PollInterval of ListEventOptions which set none.
+	DefaultEventPollInterval = 15 * time.Second
+	// DefaultMaxEventPollInterval is the MaxPollInterval of ListEventOptions which set none.
+	DefaultMaxEventPollInterval = 5 * time.Minute
+)
+
 // ListEventOptions{} modifies the behavior of ListEvents()
 type ListEventOptions struct {
 	// Limits the results to a specific start and end time
@@ -24,8 +30,28 @@ type ListEventOptions struct {
 	Limit int
 	// Filter allows the caller to provide more specialized filters on the query.
 	// Consult the Mailgun documentation for more details.
-	Filter       map[string]string
+	Filter map[string]string
+	// Query, if set, is a filter built with `events.And()`, `events.Recipient()` and the other
+	// filters of the events package, validated before any request is made. It may not filter a
+	// field Filter filters as well.
+	Query events.Filter
+	// PollInterval is how long `PollEvents()` waits before polling again, and how long
+	// `EventIterator.NextWait()` first waits before fetching an empty page again; defaults to
+	// DefaultEventPollInterval.
 	PollInterval time.Duration
+	// MaxPollInterval caps the wait of `EventIterator.NextWait()`, which doubles for each empty
+	// page in a row; defaults to DefaultMaxEventPollInterval.
+	MaxPollInterval time.Duration
+	// ThresholdAge, if set, makes `EventIterator.NextWait()` hold back a page until its newest
+	// event is at least this old, and fetch it again then. Mailgun stores events some time after
+	// they happen, not always in order, so a page of recent events may miss some which are later
+	// stored in its time range; half an hour is safe.
+	ThresholdAge time.Duration
+	// Prefetch, if set, makes the iterator fetch the next page in the background as soon as
+	// `Next()` returns a page, so a sequential scan of many events waits less for each page.
+	// `Next()` waits for the page, and fetches it again itself if fetching it in the background
+	// failed. It is ignored by `PollEvents()`.
+	Prefetch bool
 }
 
 // EventIterator maintains the state necessary for paging though small parcels of a larger set of events.
@@ -33,6 +59,13 @@ type EventIterator struct {
 	events.Response
 	mg  Mailgun
 	err error
+	// prefetch is set if each page is fetched in the background once the page before it is returned
+	prefetch bool
+	next     *prefetch
+	// pollInterval, maxPollInterval and thresholdAge are the options of NextWait
+	pollInterval    time.Duration
+	maxPollInterval time.Duration
+	thresholdAge    time.Duration
 }
 
 // Create an new iterator to fetch a page of events from the events api with a specific domain
@@ -49,9 +82,11 @@ func (mg *MailgunImpl) ListEvents(opts *ListEventOptions) *EventIterator {
 
 func (mg *MailgunImpl) listEvents(url string, opts *ListEventOptions) *EventIterator {
 	req := newHTTPRequest(url)
+	var queryErr error
+	var limit int
 	if opts != nil {
 		if opts.Limit > 0 {
-			req.addParameter("limit", fmt.Sprintf("%d", opts.Limit))
+			limit = opts.Limit
 		}
 		if opts.Compact {
 			req.addParameter("pretty", "no")
@@ -72,13 +107,51 @@ func (mg *MailgunImpl) listEvents(url string, opts *ListEventOptions) *EventIter
 				req.addParameter(k, v)
 			}
 		}
+		var params map[string]string
+		if params, queryErr = eventQuery(opts); queryErr == nil {
+			req.addParameters(params)
+		}
 	}
+	mg.addPageLimit(req, limit, MaxEventsPageSize)
 	url, err := req.generateUrlWithParameters()
-	return &EventIterator{
-		mg:       mg,
-		Response: events.Response{Paging: events.Paging{Next: url, First: url}},
-		err:      err,
+	if queryErr != nil {
+		err = queryErr
+	}
+	it := &EventIterator{
+		mg:              mg,
+		Response:        events.Response{Paging: events.Paging{Next: url, First: url}},
+		err:             err,
+		pollInterval:    DefaultEventPollInterval,
+		maxPollInterval: DefaultMaxEventPollInterval,
+	}
+	if opts != nil {
+		it.prefetch = opts.Prefetch
+		it.thresholdAge = opts.ThresholdAge
+		if opts.PollInterval > 0 {
+			it.pollInterval = opts.PollInterval
+		}
+		if opts.MaxPollInterval > 0 {
+			it.maxPollInterval = opts.MaxPollInterval
+		}
+	}
+	if it.maxPollInterval < it.pollInterval {
+		it.maxPollInterval = it.pollInterval
+	}
+	return it
+}
+
+// eventQuery returns the query parameters of the Query of the options.
+func eventQuery(opts *ListEventOptions) (map[string]string, error) {
+	params, err := opts.Query.Compile()
+	if err != nil {
+		return nil, err
+	}
+	for field := range params {
+		if _, ok := opts.Filter[field]; ok {
+			return nil, fmt.Errorf("invalid event filter: %s is filtered by both Filter and Query", field)
+		}
 	}
+	return params, nil
 }
 
 // If an error occurred during iteration `Err()` will return non nil
@@ -93,20 +166,90 @@ func (ei *EventIterator) Next(ctx context.Context, events *[]Event) bool {
 	if ei.err != nil {
 		return false
 	}
-	ei.err = ei.fetch(ctx, ei.Paging.Next)
+	ei.err = ei.fetchNext(ctx)
 	if ei.err != nil {
 		return false
 	}
-	*events, ei.err = ParseEvents(ei.Items)
+	*events, ei.err = parseEvents(codecFor(ei.mg), ei.Items)
 	if ei.err != nil {
 		return false
 	}
 	if len(ei.Items) == 0 {
 		return false
 	}
+	ei.prefetchNext(ctx)
 	return true
 }
 
+// NextWait retrieves the next page of events as `Next()` does, but waits for events rather than
+// returning false when the next page is empty, so a consumer can follow the events of a domain as
+// they are stored. An empty page is fetched again after the PollInterval of the options, then
+// after twice as long for each further empty page, up to MaxPollInterval; the wait starts over
+// once events arrive. With a ThresholdAge, a page whose newest event is younger than it is fetched
+// again once that event is old enough, so events stored late are not skipped. NextWait returns
+// false once the context is done, or on an error; use `Err()` to retrieve the error.
+//
+//  it := mg.ListEvents(&mailgun.ListEventOptions{
+//    Begin:        time.Now().Add(-time.Hour),
+//    ThresholdAge: 30 * time.Minute,
+//  })
+//  var page []mailgun.Event
+//  for it.NextWait(ctx, &page) {
+//    for _, e := range page {
+//      handle(e)
+//    }
+//  }
+func (ei *EventIterator) NextWait(ctx context.Context, events *[]Event) bool {
+	clk := clockFor(ei.mg)
+	wait := ei.pollInterval
+	for ei.err == nil {
+		url := ei.Paging.Next
+		if ei.err = ei.fetchNext(ctx); ei.err != nil {
+			break
+		}
+		var page []Event
+		if page, ei.err = parseEvents(codecFor(ei.mg), ei.Items); ei.err != nil {
+			break
+		}
+
+		var sleep time.Duration
+		switch {
+		case len(page) == 0:
+			sleep = wait
+			if wait *= 2; wait > ei.maxPollInterval {
+				wait = ei.maxPollInterval
+			}
+		case ei.thresholdAge > 0:
+			if age := clk.Now().Sub(newestEvent(page)); age < ei.thresholdAge {
+				sleep = ei.thresholdAge - age
+			}
+		}
+		if sleep == 0 {
+			*events = page
+			ei.prefetchNext(ctx)
+			return true
+		}
+
+		// Fetch the same page again
+		ei.Paging.Next = url
+		if clk.Sleep(ctx, sleep) != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// newestEvent returns the time of the newest event of the page.
+func newestEvent(page []Event) time.Time {
+	var newest time.Time
+	for _, e := range page {
+		if t := e.GetTimestamp(); t.After(newest) {
+			newest = t
+		}
+	}
+	return newest
+}
+
 // First retrieves the first page of events from the api. Returns false if there
 // was an error. It also sets the iterator object to the first page.
 // Use `.Err()` to retrieve the error.
@@ -118,7 +261,7 @@ func (ei *EventIterator) First(ctx context.Context, events *[]Event) bool {
 	if ei.err != nil {
 		return false
 	}
-	*events, ei.err = ParseEvents(ei.Items)
+	*events, ei.err = parseEvents(codecFor(ei.mg), ei.Items)
 	return true
 }
 
@@ -134,7 +277,7 @@ func (ei *EventIterator) Last(ctx context.Context, events *[]Event) bool {
 	if ei.err != nil {
 		return false
 	}
-	*events, ei.err = ParseEvents(ei.Items)
+	*events, ei.err = parseEvents(codecFor(ei.mg), ei.Items)
 	return true
 }
 
@@ -152,7 +295,7 @@ func (ei *EventIterator) Previous(ctx context.Context, events *[]Event) bool {
 	if ei.err != nil {
 		return false
 	}
-	*events, ei.err = ParseEvents(ei.Items)
+	*events, ei.err = parseEvents(codecFor(ei.mg), ei.Items)
 	if len(ei.Items) == 0 {
 		return false
 	}
@@ -160,19 +303,29 @@ func (ei *EventIterator) Previous(ctx context.Context, events *[]Event) bool {
 }
 
 func (ei *EventIterator) fetch(ctx context.Context, url string) error {
-	r := newHTTPRequest(url)
-	r.setClient(ei.mg.Client())
-	r.setBasicAuth(basicAuthUser, ei.mg.APIKey())
+	return ei.fetchInto(ctx, url, &ei.Response)
+}
 
-	resp, err := makeRequest(ctx, r, "GET", nil)
-	if err != nil {
-		return err
+// fetchNext fetches the next page, taking it from the prefetch if it was fetched in the background.
+func (ei *EventIterator) fetchNext(ctx context.Context) error {
+	page, ok := ei.next.wait(ctx, ei.Paging.Next)
+	ei.next = nil
+	if ok {
+		ei.Response = *page.(*events.Response)
+		return nil
 	}
+	return ei.fetch(ctx, ei.Paging.Next)
+}
 
-	if err := jsoniter.Unmarshal(resp.Data, &ei.Response); err != nil {
-		return fmt.Errorf("failed to un-marshall event.Response: %s", err)
+// prefetchNext starts fetching the next page in the background, if the iterator prefetches.
+func (ei *EventIterator) prefetchNext(ctx context.Context) {
+	if ei.prefetch && ei.Paging.Next != "" {
+		ei.next = startPrefetch(ctx, ei.Paging.Next, &events.Response{}, ei.fetchInto)
 	}
-	return nil
+}
+
+func (ei *EventIterator) fetchInto(ctx context.Context, url string, page interface{}) error {
+	return fetchPage(ctx, ei.mg, url, page)
 }
 
 // EventPoller maintains the state necessary for polling events
@@ -218,7 +371,7 @@ func (mg *MailgunImpl) PollEvents(opts *ListEventOptions) *EventPoller {
 
 	// Set a 15 second poll interval if none set
 	if opts.PollInterval.Nanoseconds() == 0 {
-		opts.PollInterval = time.Duration(time.Second * 15)
+		opts.PollInterval = DefaultEventPollInterval
 	}
 
 	return &EventPoller{
@@ -1,6 +1,7 @@
 package events
 
 import (
+	"encoding/json"
 	"strings"
 	"time"
 )
@@ -23,6 +24,9 @@ type Generic struct {
 	EventName
 	Timestamp float64 `json:"timestamp"`
 	ID        string  `json:"id"`
+	// Extra holds the top level fields of the event which have no field of their own, such as
+	// fields Mailgun adds to its payloads before the library supports them.
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 func (g *Generic) GetTimestamp() time.Time {
@@ -43,6 +47,15 @@ func (g *Generic) SetID(id string) {
 	g.ID = id
 }
 
+// GetExtra returns the fields of the event which have no field of their own.
+func (g *Generic) GetExtra() map[string]json.RawMessage {
+	return g.Extra
+}
+
+func (g *Generic) SetExtra(extra map[string]json.RawMessage) {
+	g.Extra = extra
+}
+
 //
 // Message Events
 //
@@ -102,9 +115,10 @@ type Delivered struct {
 type Failed struct {
 	Generic
 
-	Envelope Envelope `json:"envelope"`
-	Message  Message  `json:"message"`
-	Flags    Flags    `json:"flags"`
+	Envelope    Envelope    `json:"envelope"`
+	Message     Message     `json:"message"`
+	Flags       Flags       `json:"flags"`
+	MailingList MailingList `json:"mailing-list"`
 
 	Recipient       string     `json:"recipient"`
 	RecipientDomain string     `json:"recipient-domain"`
@@ -255,7 +269,8 @@ type Paging struct {
 type RawJSON []byte
 
 func (v *RawJSON) UnmarshalJSON(data []byte) error {
-	*v = data
+	// data is only valid until UnmarshalJSON returns when decoding from a stream
+	*v = append(RawJSON(nil), data...)
 	return nil
 }
 
@@ -0,0 +1,230 @@
+package events
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Filter is a filter of the events api, built with `Recipient()`, `Tag()`, `EventType()` and
+// the other field filters, and combined with `And()`, `Or()` and `Not()`. It compiles to Mailgun's
+// filter expressions, one per field; Mailgun applies the fields together, so filters on different
+// fields can only be combined with `And()`. Mistakes are reported when the filter is compiled,
+// rather than by Mailgun.
+//
+//  it := mg.ListEvents(&mailgun.ListEventOptions{
+//    Query: events.And(
+//      events.EventType(events.EventFailed, events.EventRejected),
+//      events.Recipient("joe@example.com"),
+//      events.Not(events.Tag("newsletter")),
+//    ),
+//  })
+//
+// The zero Filter matches every event.
+type Filter struct {
+	// field and value are set for the filter of a single value
+	field, value string
+	// op combines the terms, as "AND", "OR" or "NOT"
+	op    string
+	terms []Filter
+}
+
+func fieldFilter(field, value string) Filter {
+	return Filter{field: field, value: value}
+}
+
+// Recipient matches events of messages to the address.
+func Recipient(address string) Filter { return fieldFilter("recipient", address) }
+
+// From matches events of messages with this From address.
+func From(address string) Filter { return fieldFilter("from", address) }
+
+// Subject matches events of messages with this subject.
+func Subject(subject string) Filter { return fieldFilter("subject", subject) }
+
+// MessageID matches events of the message with this Message-Id, without angle brackets.
+func MessageID(id string) Filter { return fieldFilter("message-id", id) }
+
+// Tag matches events of messages with the tag.
+func Tag(tag string) Filter { return fieldFilter("tags", tag) }
+
+// Severity matches failed events of this severity, such as SeverityPermanent.
+func Severity(severity string) Filter { return fieldFilter("severity", severity) }
+
+// EventType matches events with any of the names, such as EventDelivered.
+func EventType(names ...string) Filter {
+	if len(names) == 1 {
+		return fieldFilter("event", names[0])
+	}
+	terms := make([]Filter, len(names))
+	for i, name := range names {
+		terms[i] = fieldFilter("event", name)
+	}
+	return Filter{op: "OR", terms: terms}
+}
+
+// And matches events which match all the filters.
+func And(filters ...Filter) Filter { return Filter{op: "AND", terms: filters} }
+
+// Or matches events which match any of the filters, which must all be on the same field.
+func Or(filters ...Filter) Filter { return Filter{op: "OR", terms: filters} }
+
+// Not matches events which do not match the filter, which must be on a single field.
+func Not(filter Filter) Filter {
+	return Filter{op: "NOT", terms: []Filter{filter}}
+}
+
+// IsZero reports whether the filter is the zero Filter, which matches every event.
+func (f Filter) IsZero() bool {
+	return f.field == "" && f.op == ""
+}
+
+// Compile returns the filter as the query parameters of the events api, the filter expression of
+// each field by its name. It returns an error if the filter cannot be expressed by Mailgun, such
+// as filters on different fields combined with `Or()`, or an unknown event type.
+func (f Filter) Compile() (map[string]string, error) {
+	if f.IsZero() {
+		return map[string]string{}, nil
+	}
+	return f.compile()
+}
+
+// String returns the compiled filter as a query string, or the error compiling it.
+func (f Filter) String() string {
+	params, err := f.Compile()
+	if err != nil {
+		return err.Error()
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + params[name]
+	}
+	return strings.Join(parts, "&")
+}
+
+// compile returns the expressions of the filter by field.
+func (f Filter) compile() (map[string]string, error) {
+	if f.field != "" {
+		if err := validateFilterValue(f.field, f.value); err != nil {
+			return nil, err
+		}
+		return map[string]string{f.field: quoteFilterValue(f.value)}, nil
+	}
+	if len(f.terms) == 0 {
+		return nil, fmt.Errorf("invalid event filter: %s of no filters", f.op)
+	}
+
+	var fields []map[string]string
+	for _, t := range f.terms {
+		if t.IsZero() {
+			return nil, fmt.Errorf("invalid event filter: %s of an empty filter", f.op)
+		}
+		c, err := t.compile()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, c)
+	}
+
+	switch f.op {
+	case "AND":
+		result := make(map[string]string)
+		for _, c := range fields {
+			for field, expr := range c {
+				if prev, ok := result[field]; ok {
+					result[field] = groupFilterExpr(prev) + " AND " + groupFilterExpr(expr)
+				} else {
+					result[field] = expr
+				}
+			}
+		}
+		return result, nil
+	case "OR", "NOT":
+		field, err := singleFilterField(f.op, fields)
+		if err != nil {
+			return nil, err
+		}
+		if f.op == "NOT" {
+			return map[string]string{field: "NOT " + groupFilterExpr(fields[0][field])}, nil
+		}
+		exprs := make([]string, len(fields))
+		for i, c := range fields {
+			exprs[i] = groupFilterExpr(c[field])
+		}
+		return map[string]string{field: strings.Join(exprs, " OR ")}, nil
+	}
+	return nil, fmt.Errorf("invalid event filter: unknown operator '%s'", f.op)
+}
+
+// singleFilterField returns the field all the compiled terms of op filter, or an error if they
+// filter more than one field, which Mailgun cannot combine with op.
+func singleFilterField(op string, terms []map[string]string) (string, error) {
+	names := make(map[string]bool)
+	for _, c := range terms {
+		for field := range c {
+			names[field] = true
+		}
+	}
+	if len(names) == 1 {
+		for field := range names {
+			return field, nil
+		}
+	}
+	var list []string
+	for field := range names {
+		list = append(list, field)
+	}
+	sort.Strings(list)
+	return "", fmt.Errorf("invalid event filter: Mailgun cannot apply %s to filters on different fields (%s)", op, strings.Join(list, ", "))
+}
+
+// knownEvents are the event types a filter may match.
+var knownEvents = map[string]bool{
+	EventAccepted: true, EventRejected: true, EventDelivered: true, EventFailed: true,
+	EventOpened: true, EventClicked: true, EventUnsubscribed: true, EventComplained: true,
+	EventStored: true, EventDropped: true, EventListMemberUploaded: true,
+	EventListMemberUploadError: true, EventListUploaded: true,
+}
+
+// validateFilterValue returns an error if value cannot be matched against field.
+func validateFilterValue(field, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("invalid event filter: empty %s", field)
+	}
+	if strings.Contains(value, `"`) {
+		return fmt.Errorf("invalid event filter: %s '%s' contains a double quote", field, value)
+	}
+	if field == "event" && !knownEvents[strings.ToLower(value)] {
+		return fmt.Errorf("invalid event filter: unknown event type '%s'", value)
+	}
+	return nil
+}
+
+// quoteFilterValue quotes values Mailgun would otherwise read as an expression, such as values
+// with spaces, parentheses or operators.
+func quoteFilterValue(value string) string {
+	switch value {
+	case "AND", "OR", "NOT":
+		return `"` + value + `"`
+	}
+	if strings.ContainsAny(value, " \t()") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// groupFilterExpr wraps an expression of several terms in parentheses, to combine it with others.
+func groupFilterExpr(expr string) string {
+	if strings.HasPrefix(expr, `"`) && strings.HasSuffix(expr, `"`) && strings.Count(expr, `"`) == 2 {
+		return expr
+	}
+	if strings.ContainsAny(expr, " ") {
+		return "(" + expr + ")"
+	}
+	return expr
+}
@@ -3,6 +3,9 @@ package mailgun_test
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -162,3 +165,72 @@ func ExampleMailgunImpl_ListEvents() {
 	// Unsubscribed client OS: OS X
 	// Unsubscribed client OS: OS X
 }
+
+func TestEventFilter(t *testing.T) {
+	f := events.And(
+		events.EventType(events.EventFailed, events.EventRejected),
+		events.Recipient("joe@example.com"),
+		events.Not(events.Tag("newsletter")),
+		events.Subject("Your order (#42)"),
+	)
+	params, err := f.Compile()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, params, map[string]string{
+		"event":     "failed OR rejected",
+		"recipient": "joe@example.com",
+		"tags":      "NOT newsletter",
+		"subject":   `"Your order (#42)"`,
+	})
+
+	params, err = events.And(events.Tag("a"), events.Or(events.Tag("b"), events.Not(events.Tag("c")))).Compile()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, params["tags"], "a AND (b OR (NOT c))")
+	ensure.DeepEqual(t, events.Tag("OR").String(), `tags="OR"`)
+
+	_, err = events.Or(events.Recipient("joe@example.com"), events.Tag("vip")).Compile()
+	ensure.DeepEqual(t, err.Error(), "invalid event filter: Mailgun cannot apply OR to filters on different fields (recipient, tags)")
+	_, err = events.EventType("bounced").Compile()
+	ensure.DeepEqual(t, err.Error(), "invalid event filter: unknown event type 'bounced'")
+	_, err = events.And(events.Recipient(" ")).Compile()
+	ensure.DeepEqual(t, err.Error(), "invalid event filter: empty recipient")
+	_, err = events.Not(events.And()).Compile()
+	ensure.NotNil(t, err)
+
+	params, err = events.Filter{}.Compile()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(params), 0)
+}
+
+func TestListEventsQuery(t *testing.T) {
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		fmt.Fprint(w, `{"items": [], "paging": {}}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+	var page []mailgun.Event
+
+	it := mg.ListEvents(&mailgun.ListEventOptions{
+		Filter: map[string]string{"severity": "permanent"},
+		Query:  events.And(events.EventType(events.EventFailed), events.Not(events.Tag("test"))),
+	})
+	ensure.False(t, it.Next(ctx, &page))
+	ensure.Nil(t, it.Err())
+	ensure.DeepEqual(t, query.Get("event"), "failed")
+	ensure.DeepEqual(t, query.Get("tags"), "NOT test")
+	ensure.DeepEqual(t, query.Get("severity"), "permanent")
+
+	// Invalid filters are reported without a request
+	query = nil
+	it = mg.ListEvents(&mailgun.ListEventOptions{Query: events.Or(events.Tag("a"), events.Recipient("b@example.com"))})
+	ensure.False(t, it.Next(ctx, &page))
+	ensure.StringContains(t, it.Err().Error(), "cannot apply OR")
+	it = mg.ListEvents(&mailgun.ListEventOptions{Filter: map[string]string{"tags": "a"}, Query: events.Tag("b")})
+	ensure.False(t, it.Next(ctx, &page))
+	ensure.StringContains(t, it.Err().Error(), "filtered by both Filter and Query")
+	ensure.True(t, query == nil)
+}
@@ -159,7 +159,7 @@ func CreateExport(domain, apiKey string) error {
 	return mg.CreateExport(ctx, "/v3/domains")
 }
 
-func CreateMailingList(domain, apiKey string) (mailgun.MailingList, error) {
+func CreateMailingList(domain, apiKey string) (mailgun.ListResponse, error) {
 	mg := mailgun.NewMailgun(domain, apiKey)
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
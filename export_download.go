@@ -0,0 +1,363 @@
+package mailgun
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxExportResumes is the number of times a download is resumed after it is interrupted.
+const maxExportResumes = 5
+
+// ExportRow is a row of a CSV export, read by an `ExportIterator`.
+type ExportRow struct {
+	// Line is the line number of the row in the file, counting the header row as line 1.
+	Line   int
+	Values []string
+
+	columns map[string]int
+}
+
+// Get returns the value of the column with the name, ignoring case, or "" if there is none.
+func (r ExportRow) Get(column string) string {
+	i, ok := r.columns[strings.ToLower(column)]
+	if !ok || i >= len(r.Values) {
+		return ""
+	}
+	return r.Values[i]
+}
+
+// Decode copies the row into the struct v points to. Each exported field is filled from the column
+// named by its `csv` tag, or else by its name, ignoring case; fields tagged `csv:"-"` and fields
+// without a column are left alone. Fields may be strings, numbers, bools or time.Time, which is
+// parsed from RFC 2822 or RFC 3339 times or unix timestamps. Empty values leave the zero value.
+//
+//  var bounce struct {
+//    Address   string    `csv:"address"`
+//    Code      int       `csv:"code"`
+//    CreatedAt time.Time `csv:"created_at"`
+//  }
+//  err := row.Decode(&bounce)
+func (r ExportRow) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode a row into %T; expected a pointer to a struct", v)
+	}
+	rv = rv.Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("csv"); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		if _, ok := r.columns[strings.ToLower(name)]; !ok {
+			continue
+		}
+		value := strings.TrimSpace(r.Get(name))
+		if value == "" {
+			continue
+		}
+		if err := setField(rv.Field(i), value); err != nil {
+			return RowError{Row: r.Line, Err: fmt.Errorf("column '%s': %s", name, err)}
+		}
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func setField(field reflect.Value, value string) error {
+	if field.Type() == timeType {
+		t, err := parseExportTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Bool:
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "y":
+		return true, nil
+	case "no", "n":
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+func parseExportTime(value string) (time.Time, error) {
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(secs*float64(time.Second))).UTC(), nil
+	}
+	for _, layout := range []string{time.RFC1123, time.RFC1123Z, time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse '%s' as a time", value)
+}
+
+// ExportIterator streams the rows of a CSV export as it is downloaded.
+type ExportIterator struct {
+	r       *resumableReader
+	csv     *csv.Reader
+	header  []string
+	columns map[string]int
+	line    int
+	err     error
+}
+
+// DownloadExport returns an iterator over the rows of the export with the id, such as an export of
+// the bounces or events of a domain, downloaded from the link returned by `GetExportLink()`.
+// See `NewExportIterator()`.
+func (mg *MailgunImpl) DownloadExport(ctx context.Context, id string) (*ExportIterator, error) {
+	link, err := mg.GetExportLink(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	it := NewExportIterator(mg.Client(), link)
+	it.r.userAgent = mg.UserAgent()
+	return it, nil
+}
+
+// NewExportIterator returns an iterator over the rows of the CSV file at url, which is downloaded
+// with client as the rows are read, so exports of any size use little memory. Gzipped files are
+// decompressed. A download which is interrupted is resumed where it stopped with a Range request,
+// up to 5 times. The first row of the file is the header, which names the columns of the rest.
+// Close the iterator when done with it.
+//
+//  it, err := mg.DownloadExport(ctx, export.ID)
+//  if err != nil {
+//    return err
+//  }
+//  defer it.Close()
+//  var row mailgun.ExportRow
+//  for it.Next(ctx, &row) {
+//    fmt.Println(row.Get("address"))
+//  }
+//  if it.Err() != nil {
+//    return it.Err()
+//  }
+func NewExportIterator(client *http.Client, url string) *ExportIterator {
+	return &ExportIterator{r: &resumableReader{client: client, url: url}}
+}
+
+// Next reads the next row into row, and returns false when there are no more rows, or reading
+// fails; check `Err()` for the error.
+func (it *ExportIterator) Next(ctx context.Context, row *ExportRow) bool {
+	if it.err != nil {
+		return false
+	}
+	it.r.ctx = ctx
+	if it.csv == nil {
+		if it.err = it.open(); it.err != nil {
+			return false
+		}
+	}
+
+	values, err := it.csv.Read()
+	if err != nil {
+		if err != io.EOF {
+			it.err = err
+		}
+		return false
+	}
+	it.line++
+	*row = ExportRow{Line: it.line, Values: values, columns: it.columns}
+	return true
+}
+
+// open starts the download, and reads the header row.
+func (it *ExportIterator) open() error {
+	buf := bufio.NewReader(it.r)
+	var body io.Reader = buf
+	if magic, err := buf.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			return fmt.Errorf("while decompressing export: %s", err)
+		}
+		body = gz
+	} else if err != nil && err != io.EOF {
+		return err
+	}
+
+	it.csv = csv.NewReader(body)
+	it.csv.FieldsPerRecord = -1
+	it.csv.LazyQuotes = true
+	header, err := it.csv.Read()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	it.line = 1
+	it.columns = make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		it.header = append(it.header, name)
+		if _, ok := it.columns[name]; !ok {
+			it.columns[name] = i
+		}
+	}
+	return nil
+}
+
+// Columns returns the names of the columns of the export, in lower case, once the first row has
+// been read.
+func (it *ExportIterator) Columns() []string {
+	return append([]string(nil), it.header...)
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *ExportIterator) Err() error {
+	return it.err
+}
+
+// Close stops the download.
+func (it *ExportIterator) Close() error {
+	return it.r.Close()
+}
+
+// resumableReader reads the body of a GET request for url, requesting the rest of it with a Range
+// request when reading it fails.
+type resumableReader struct {
+	ctx       context.Context
+	client    *http.Client
+	url       string
+	userAgent string
+
+	body    io.ReadCloser
+	etag    string
+	offset  int64
+	resumes int
+}
+
+func (r *resumableReader) Read(p []byte) (int, error) {
+	for {
+		if r.body == nil {
+			if err := r.open(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		r.body.Close()
+		r.body = nil
+		if r.ctx.Err() != nil || r.resumes >= maxExportResumes {
+			return n, err
+		}
+		r.resumes++
+		if n > 0 {
+			return n, nil
+		}
+		if err := sleepContext(r.ctx, listAllInterval); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// open requests the body from the current offset.
+func (r *resumableReader) open() error {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(r.ctx)
+	// Asking for identity stops the transport from decompressing the body, which would make the
+	// offset meaningless; gzipped files are decompressed by the iterator instead
+	req.Header.Set("Accept-Encoding", "identity")
+	if r.userAgent != "" {
+		req.Header.Set("User-Agent", r.userAgent)
+	}
+	if r.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+		if r.etag != "" {
+			req.Header.Set("If-Range", r.etag)
+		}
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && r.offset > 0:
+	case resp.StatusCode == http.StatusOK:
+		if r.offset > 0 {
+			// The server ignored the range, or the file changed
+			if r.etag != "" && resp.Header.Get("ETag") != r.etag {
+				resp.Body.Close()
+				return fmt.Errorf("export '%s' changed while it was downloaded", r.url)
+			}
+			if _, err := io.CopyN(ioutil.Discard, resp.Body, r.offset); err != nil {
+				resp.Body.Close()
+				return err
+			}
+		}
+		r.etag = resp.Header.Get("ETag")
+	default:
+		resp.Body.Close()
+		return fmt.Errorf("while downloading export: %s returned %d", r.url, resp.StatusCode)
+	}
+	r.body = resp.Body
+	return nil
+}
+
+func (r *resumableReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,101 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestExportIterator(t *testing.T) {
+	var csv strings.Builder
+	csv.WriteString("Address,Code,Error,Created_At\n")
+	for i := 0; i < 500; i++ {
+		csv.WriteString("user" + strconv.Itoa(i) + "@example.com,550,\"No such mailbox, sorry\",1591000000\n")
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(csv.String()))
+	w.Close()
+	data := gz.Bytes()
+
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ensure.DeepEqual(t, r.Header.Get("Accept-Encoding"), "identity")
+		w.Header().Set("ETag", `"export-1"`)
+		if len(ranges) == 0 {
+			// Fail the first download half way through
+			ranges = append(ranges, "")
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data[:len(data)/2])
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "export.csv.gz", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	it := mailgun.NewExportIterator(http.DefaultClient, srv.URL)
+	defer it.Close()
+	ctx := context.Background()
+
+	type bounce struct {
+		Address   string
+		Code      int
+		Error     string
+		CreatedAt time.Time `csv:"created_at"`
+	}
+	var row mailgun.ExportRow
+	var bounces []bounce
+	for it.Next(ctx, &row) {
+		var b bounce
+		ensure.Nil(t, row.Decode(&b))
+		bounces = append(bounces, b)
+	}
+	ensure.Nil(t, it.Err())
+	ensure.DeepEqual(t, it.Columns(), []string{"address", "code", "error", "created_at"})
+	ensure.DeepEqual(t, len(bounces), 500)
+	ensure.DeepEqual(t, bounces[499], bounce{
+		Address:   "user499@example.com",
+		Code:      550,
+		Error:     "No such mailbox, sorry",
+		CreatedAt: time.Unix(1591000000, 0).UTC(),
+	})
+	ensure.DeepEqual(t, row.Line, 501)
+	ensure.DeepEqual(t, row.Get("ADDRESS"), "user499@example.com")
+	ensure.DeepEqual(t, ranges, []string{"", "bytes=" + strconv.Itoa(len(data)/2) + "-"})
+}
+
+func TestExportRowDecode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("address,subscribed,count\njoe@example.com,yes,many\n"))
+	}))
+	defer srv.Close()
+
+	it := mailgun.NewExportIterator(http.DefaultClient, srv.URL)
+	defer it.Close()
+	var row mailgun.ExportRow
+	ensure.True(t, it.Next(context.Background(), &row))
+
+	var v struct {
+		Address    string
+		Subscribed bool
+		Count      int
+		Ignored    string `csv:"-"`
+	}
+	err := row.Decode(&v)
+	ensure.NotNil(t, err)
+	ensure.DeepEqual(t, err.Error(), `row 2: column 'Count': strconv.ParseInt: parsing "many": invalid syntax`)
+	ensure.DeepEqual(t, v.Address, "joe@example.com")
+	ensure.True(t, v.Subscribed)
+	ensure.False(t, it.Next(context.Background(), &row))
+	ensure.Nil(t, it.Err())
+}
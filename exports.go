@@ -19,11 +19,9 @@ type Export struct {
 
 // Create an export based on the URL given
 func (mg *MailgunImpl) CreateExport(ctx context.Context, url string) error {
-	r := newHTTPRequest(generatePublicApiUrl(mg, exportsEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, exportsEndpoint))
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 	payload.addValue("url", url)
 	_, err := makePostRequest(ctx, r, payload)
 	return err
@@ -31,12 +29,10 @@ func (mg *MailgunImpl) CreateExport(ctx context.Context, url string) error {
 
 // List all exports created within the past 24 hours
 func (mg *MailgunImpl) ListExports(ctx context.Context, url string) ([]Export, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, exportsEndpoint))
-	r.setClient(mg.Client())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, exportsEndpoint))
 	if url != "" {
 		r.addParameter("url", url)
 	}
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
 
 	var resp ExportList
 	if err := getResponseFromJSON(ctx, r, &resp); err != nil {
@@ -52,9 +48,7 @@ func (mg *MailgunImpl) ListExports(ctx context.Context, url string) ([]Export, e
 
 // GetExport gets an export by id
 func (mg *MailgunImpl) GetExport(ctx context.Context, id string) (Export, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, exportsEndpoint) + "/" + id)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, exportsEndpoint)+"/"+pathEscape(id))
 	var resp Export
 	err := getResponseFromJSON(ctx, r, &resp)
 	return resp, err
@@ -63,15 +57,16 @@ func (mg *MailgunImpl) GetExport(ctx context.Context, id string) (Export, error)
 // Download an export by ID. This will respond with a '302 Moved'
 // with the Location header of temporary S3 URL if it is available.
 func (mg *MailgunImpl) GetExportLink(ctx context.Context, id string) (string, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, exportsEndpoint) + "/" + id + "/download_url")
-	c := mg.Client()
-
-	// Ensure the client doesn't attempt to retry
+	r := newHTTPRequest(generatePublicApiUrl(mg, exportsEndpoint) + "/" + pathEscape(id) + "/download_url")
+	// Ensure the client doesn't follow the redirect; the client is copied as it is shared, such as
+	// with http.DefaultClient
+	c := *mg.Client()
 	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		return errors.New("redirect")
 	}
 
-	r.setClient(mg.Client())
+	r.setClient(mg)
+	r.Client = &c
 	r.setBasicAuth(basicAuthUser, mg.APIKey())
 
 	r.addHeader("User-Agent", MailgunGoUserAgent)
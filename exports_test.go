@@ -43,4 +43,7 @@ func TestExportsLink(t *testing.T) {
 	url, err := mg.GetExportLink(ctx, "12")
 	ensure.Nil(t, err)
 	ensure.StringContains(t, url, "/some/s3/url")
+
+	// The client of mg, which may be shared, still follows redirects
+	ensure.True(t, mg.Client().CheckRedirect == nil)
 }
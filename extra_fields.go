@@ -0,0 +1,62 @@
+package mailgun
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// knownFields caches the JSON field names of struct types, for extraFields.
+var knownFields sync.Map
+
+// extraFields returns the fields of the JSON object data which are not fields of the struct v,
+// or nil if there are none. Models keep them in their Extra field, so that fields Mailgun adds to
+// its responses can be read before the library supports them.
+func extraFields(data []byte, v interface{}) (map[string]json.RawMessage, error) {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	known := jsonFieldNames(reflect.TypeOf(v))
+	for name := range all {
+		if known[name] {
+			delete(all, name)
+		}
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+	return all, nil
+}
+
+// jsonFieldNames returns the names the fields of the struct type t have in JSON.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	if names, ok := knownFields.Load(t); ok {
+		return names.(map[string]bool)
+	}
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			// The fields of embedded structs are promoted, such as those of events.Generic
+			for embedded := range jsonFieldNames(f.Type) {
+				names[embedded] = true
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		names[name] = true
+	}
+	knownFields.Store(t, names)
+	return names
+}
@@ -0,0 +1,30 @@
+package mailgun
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestExtraFields(t *testing.T) {
+	var list MailingList
+	ensure.Nil(t, json.Unmarshal([]byte(`{"address": "list@example.com", "members_count": 2, "reply_preference": "list", "archived": true}`), &list))
+	ensure.DeepEqual(t, list.Address, "list@example.com")
+	ensure.DeepEqual(t, list.MembersCount, 2)
+	ensure.DeepEqual(t, list.ReplyPreference, ReplyPreference(ReplyPreferenceList))
+	ensure.DeepEqual(t, list.Extra, map[string]json.RawMessage{"archived": json.RawMessage(`true`)})
+
+	var member Member
+	ensure.Nil(t, json.Unmarshal([]byte(`{"address": "joe@example.com", "vars": {"age": 26}}`), &member))
+	ensure.DeepEqual(t, member.Vars, map[string]interface{}{"age": 26.0})
+	ensure.True(t, member.Extra == nil)
+
+	var domain Domain
+	ensure.Nil(t, json.Unmarshal([]byte(`{"name": "example.com", "is_disabled": false, "type": "custom"}`), &domain))
+	ensure.DeepEqual(t, domain.Name, "example.com")
+	ensure.DeepEqual(t, domain.Extra, map[string]json.RawMessage{
+		"is_disabled": json.RawMessage(`false`),
+		"type":        json.RawMessage(`"custom"`),
+	})
+}
@@ -0,0 +1,275 @@
+package mailgun
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// sendRetryBackoff is the delay before the first retry of a send; it doubles with each retry.
+	sendRetryBackoff = time.Second
+	// maxSendRetryBackoff caps the delay between retries of a send.
+	maxSendRetryBackoff = 30 * time.Second
+)
+
+// FailedSendHandler is called with each message `Send()` could not deliver to Mailgun.
+// The context is the one passed to `Send()`, and may already be cancelled.
+type FailedSendHandler func(ctx context.Context, failed *FailedSend)
+
+// FailedSend holds everything needed to submit a message again after `Send()` failed. It can be
+// encoded as JSON, so worker services can persist it in a dead-letter queue and pass it to
+// `ReplayFailedSend()` later.
+type FailedSend struct {
+	// Domain and Endpoint locate the api the message was sent to.
+	Domain   string `json:"domain"`
+	Endpoint string `json:"endpoint"`
+	// Fields are the form fields of the request, in order.
+	Fields []FormField `json:"fields"`
+	// Files are the attachments, inline images and MIME body of the request.
+	Files []FormFile `json:"files,omitempty"`
+	// Attempts is the number of times the request was made.
+	Attempts int `json:"attempts"`
+	// Error describes the final failure.
+	Error string `json:"error"`
+	// FailedAt is when the final attempt failed.
+	FailedAt time.Time `json:"failed_at"`
+
+	// Err is the error returned by `Send()`. It is not persisted.
+	Err error `json:"-"`
+}
+
+// FormField is a single form field of a request.
+type FormField struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// FormFile is a single file uploaded with a request.
+type FormFile struct {
+	Key      string `json:"key"`
+	Filename string `json:"filename"`
+	// ContentType is the content type the file is sent with; if empty it is detected.
+	ContentType string `json:"content_type,omitempty"`
+	Data        []byte `json:"data"`
+}
+
+// SetSendRetries sets how many times `Send()` retries a message after a network error, a
+// 429 Too Many Requests or a 5xx response, waiting a second before the first retry and twice as
+// long before each one that follows. Retrying after a network error can deliver the message twice,
+// if Mailgun accepted it before the connection failed. By default messages are not retried.
+// Attachments are sent again with each retry: files are opened again, and readers which implement
+// io.Seeker, such as an *os.File, are rewound; other readers are read into memory before the
+// first attempt.
+func (mg *MailgunImpl) SetSendRetries(retries int) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.sendRetries = retries
+}
+
+// SetFailedSendHandler sets a handler called whenever `Send()` ultimately fails to submit a message,
+// after any retries, with the full payload of the message. Messages which fail client-side validation
+// are returned as errors without calling the handler. Pass nil to remove the handler.
+//
+//  mg.SetFailedSendHandler(func(ctx context.Context, f *mailgun.FailedSend) {
+//    b, _ := json.Marshal(f)
+//    deadLetters.Push(b)
+//  })
+func (mg *MailgunImpl) SetFailedSendHandler(h FailedSendHandler) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.onFailedSend = h
+}
+
+// ReplayFailedSend submits a message captured by a FailedSendHandler again, as `Send()` would.
+func (mg *MailgunImpl) ReplayFailedSend(ctx context.Context, failed *FailedSend) (mes string, id string, err error) {
+	if err = mg.checkSendingPaused(failed.Domain); err != nil {
+		return
+	}
+	payload := newFormDataPayload()
+	for _, f := range failed.Fields {
+		payload.addValue(f.Key, f.Value)
+	}
+	for _, f := range failed.Files {
+		payload.addBuffer(f.Key, f.Filename, f.Data)
+		if f.ContentType != "" {
+			payload.setContentType(f.Filename, f.ContentType)
+		}
+	}
+
+	r := newAPIRequest(mg, generateApiUrlWithDomain(mg, failed.Endpoint, failed.Domain))
+
+	var response sendMessageResponse
+	err = postResponseFromJSON(ctx, r, payload, &response)
+	if err == nil {
+		mes = response.Message
+		id = response.Id
+	}
+	return
+}
+
+// postMessage makes the request of `Send()`, retrying and reporting failures as configured.
+// If idempotent is set, requests which may have reached Mailgun are not retried.
+func (mg *MailgunImpl) postMessage(ctx context.Context, r *httpRequest, p *formDataPayload, domain, endpoint string, idempotent bool) (*sendMessageResponse, error) {
+	mg.mu.RLock()
+	retries, onFailed := mg.sendRetries, mg.onFailedSend
+	mg.mu.RUnlock()
+
+	if onFailed != nil {
+		// The handler is given the contents of the files
+		if err := p.bufferFiles(); err != nil {
+			return nil, err
+		}
+	} else if retries > 0 {
+		if err := p.rewindable(); err != nil {
+			return nil, err
+		}
+		defer p.close()
+	}
+
+	var response sendMessageResponse
+	var attempts int
+	var err error
+	for {
+		attempts++
+		err = postResponseFromJSON(ctx, r, p, &response)
+		if err == nil {
+			return &response, nil
+		}
+		if attempts > retries || !isRetryableSendError(ctx, err) || (idempotent && isAmbiguousSendError(err)) {
+			break
+		}
+		if mg.clock().Sleep(ctx, sendBackoff(attempts)) != nil {
+			break
+		}
+	}
+
+	if onFailed != nil {
+		failed := &FailedSend{
+			Domain:   domain,
+			Endpoint: endpoint,
+			Attempts: attempts,
+			Error:    err.Error(),
+			FailedAt: mg.clock().Now(),
+			Err:      err,
+		}
+		for _, v := range p.Values {
+			failed.Fields = append(failed.Fields, FormField{Key: v.key, Value: v.value})
+		}
+		for _, b := range p.Buffers {
+			failed.Files = append(failed.Files, FormFile{Key: b.key, Filename: b.name, ContentType: p.contentTypes[b.name], Data: b.value})
+		}
+		onFailed(ctx, failed)
+	}
+	return nil, err
+}
+
+// sendBackoff returns the delay before retrying a request which failed after the given number of attempts.
+func sendBackoff(attempts int) time.Duration {
+	backoff := sendRetryBackoff << uint(attempts-1)
+	if backoff > maxSendRetryBackoff || backoff <= 0 {
+		backoff = maxSendRetryBackoff
+	}
+	return backoff
+}
+
+// isRetryableSendError reports whether a send which failed with err may succeed if made again.
+func isRetryableSendError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if isAmbiguousSendError(err) {
+		return true
+	}
+	status := GetStatusFromErr(err)
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// isAmbiguousSendError reports whether err leaves it unknown if Mailgun accepted the message,
+// because the connection failed before a response was read.
+func isAmbiguousSendError(err error) bool {
+	_, ok := errors.Cause(err).(*url.Error)
+	return ok
+}
+
+// rewindable prepares the payload to be sent more than once, rebuilding its body from the
+// sources of its parts for each request rather than keeping them in memory: files are opened
+// again, and readers which can seek are rewound to where they started. If any reader cannot seek,
+// the readers are read into buffers, as bufferFiles() does, to keep the order of the parts. Call
+// close() once the payload is no longer sent.
+func (f *formDataPayload) rewindable() error {
+	readers := make([]keyNameRC, len(f.ReadClosers))
+	for i, rc := range f.ReadClosers {
+		s, ok := rc.value.(io.Seeker)
+		if !ok {
+			return f.bufferReaders()
+		}
+		offset, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return f.bufferReaders()
+		}
+		rc.offset = offset
+		readers[i] = rc
+	}
+	f.ReadClosers, f.replay = readers, true
+	return nil
+}
+
+// close closes the readers of a rewindable payload.
+func (f *formDataPayload) close() {
+	if !f.replay {
+		return
+	}
+	for _, rc := range f.ReadClosers {
+		rc.value.Close()
+	}
+	f.ReadClosers, f.replay = nil, false
+}
+
+// bufferReaders reads the readers of the payload into buffers, before the buffers it already
+// holds, so the order of the parts is kept.
+func (f *formDataPayload) bufferReaders() error {
+	var buffers []keyNameBuff
+	for _, rc := range f.ReadClosers {
+		if f.replay {
+			if _, err := rc.value.(io.Seeker).Seek(rc.offset, io.SeekStart); err != nil {
+				return errors.Wrapf(err, "while rewinding '%s'", rc.name)
+			}
+		}
+		data, err := ioutil.ReadAll(rc.value)
+		rc.value.Close()
+		if err != nil {
+			return errors.Wrapf(err, "while reading '%s'", rc.name)
+		}
+		buffers = append(buffers, keyNameBuff{key: rc.key, name: rc.name, value: data})
+	}
+	f.ReadClosers, f.replay = nil, false
+	f.Buffers = append(buffers, f.Buffers...)
+	return nil
+}
+
+// bufferFiles reads the files and readers of the payload into buffers, so the payload can be sent more than once.
+// The order of the parts is kept.
+func (f *formDataPayload) bufferFiles() error {
+	var buffers []keyNameBuff
+	for _, file := range f.Files {
+		data, err := ioutil.ReadFile(file.value)
+		if err != nil {
+			return err
+		}
+		buffers = append(buffers, keyNameBuff{key: file.key, name: path.Base(file.value), value: data})
+	}
+	if err := f.bufferReaders(); err != nil {
+		return err
+	}
+
+	f.Files = nil
+	f.Buffers = append(buffers, f.Buffers...)
+	return nil
+}
@@ -0,0 +1,182 @@
+package mailgun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestSendRetries(t *testing.T) {
+	defer func(d time.Duration) { sendRetryBackoff = d }(sendRetryBackoff)
+	sendRetryBackoff = time.Millisecond
+
+	var attempts int
+	var attachments []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		ensure.Nil(t, req.ParseMultipartForm(1<<20))
+		for _, fh := range req.MultipartForm.File["attachment"] {
+			f, err := fh.Open()
+			ensure.Nil(t, err)
+			data, err := ioutil.ReadAll(f)
+			ensure.Nil(t, err)
+			attachments = append(attachments, fh.Filename+"="+string(data))
+		}
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"message": "try again"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id": "<id@example.com>", "message": "Queued. Thank you."}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetSendRetries(2)
+
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "test@example.com")
+	m.AddReaderAttachment("reader.txt", ioutil.NopCloser(strings.NewReader("from a reader")))
+	_, id, err := mg.Send(context.Background(), m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, id, "<id@example.com>")
+	ensure.DeepEqual(t, attempts, 3)
+
+	// The reader is sent with every attempt
+	ensure.DeepEqual(t, attachments, []string{
+		"reader.txt=from a reader",
+		"reader.txt=from a reader",
+		"reader.txt=from a reader",
+	})
+}
+
+// seekCloser is a reader which can seek, and counts how often it is closed.
+type seekCloser struct {
+	*strings.Reader
+	closed int
+}
+
+func (s *seekCloser) Close() error {
+	s.closed++
+	return nil
+}
+
+func TestSendRetriesRewind(t *testing.T) {
+	defer func(d time.Duration) { sendRetryBackoff = d }(sendRetryBackoff)
+	sendRetryBackoff = time.Millisecond
+
+	var attempts int
+	var attachments []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		ensure.Nil(t, req.ParseMultipartForm(1<<20))
+		for _, fh := range req.MultipartForm.File["attachment"] {
+			f, err := fh.Open()
+			ensure.Nil(t, err)
+			data, err := ioutil.ReadAll(f)
+			ensure.Nil(t, err)
+			attachments = append(attachments, fh.Filename+"="+string(data))
+		}
+		if attempts < 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"message": "slow down"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id": "<id@example.com>", "message": "Queued. Thank you."}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetSendRetries(2)
+
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "test@example.com")
+	reader := &seekCloser{Reader: strings.NewReader("skipped, seekable")}
+	reader.Seek(9, io.SeekStart)
+	m.AddReaderAttachment("seekable.txt", reader)
+	_, _, err := mg.Send(context.Background(), m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, attempts, 2)
+
+	// The reader is rewound to where it started for each attempt, and closed once sent
+	ensure.DeepEqual(t, attachments, []string{"seekable.txt=seekable", "seekable.txt=seekable"})
+	ensure.DeepEqual(t, reader.closed, 1)
+
+	// The payload is rebuilt from files and seekable readers, with no buffers kept
+	p := newFormDataPayload()
+	p.addFile("attachment", "./mailgun.go")
+	p.addReadCloser("attachment", "seekable.txt", &seekCloser{Reader: strings.NewReader("seekable")})
+	ensure.Nil(t, p.rewindable())
+	first, err := p.getPayloadBuffer()
+	ensure.Nil(t, err)
+	second, err := p.getPayloadBuffer()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, first.String(), second.String())
+	ensure.DeepEqual(t, len(p.Buffers), 0)
+	p.close()
+}
+
+func TestFailedSendHandler(t *testing.T) {
+	defer func(d time.Duration) { sendRetryBackoff = d }(sendRetryBackoff)
+	sendRetryBackoff = time.Millisecond
+
+	var attempts int
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		ensure.Nil(t, req.ParseMultipartForm(1<<20))
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"message": "internal error"}`)
+			return
+		}
+		ensure.DeepEqual(t, req.FormValue("to"), "test@example.com")
+		ensure.DeepEqual(t, len(req.MultipartForm.File["attachment"]), 1)
+		fmt.Fprint(w, `{"id": "<replayed@example.com>", "message": "Queued. Thank you."}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetSendRetries(1)
+
+	var deadLetters [][]byte
+	mg.SetFailedSendHandler(func(ctx context.Context, f *FailedSend) {
+		ensure.DeepEqual(t, GetStatusFromErr(f.Err), http.StatusInternalServerError)
+		b, err := json.Marshal(f)
+		ensure.Nil(t, err)
+		deadLetters = append(deadLetters, b)
+	})
+
+	// Messages which fail validation are not captured
+	_, _, err := mg.Send(context.Background(), mg.NewMessage("", exampleSubject, exampleText, "test@example.com"))
+	ensure.NotNil(t, err)
+	ensure.DeepEqual(t, len(deadLetters), 0)
+
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "test@example.com")
+	m.AddBufferAttachment("report.csv", []byte("a,b,c"))
+	_, _, err = mg.Send(context.Background(), m)
+	ensure.NotNil(t, err)
+	ensure.DeepEqual(t, attempts, 2)
+	ensure.DeepEqual(t, len(deadLetters), 1)
+
+	var failed FailedSend
+	ensure.Nil(t, json.Unmarshal(deadLetters[0], &failed))
+	ensure.DeepEqual(t, failed.Domain, exampleDomain)
+	ensure.DeepEqual(t, failed.Attempts, 2)
+	ensure.DeepEqual(t, failed.Files, []FormFile{{Key: "attachment", Filename: "report.csv", Data: []byte("a,b,c")}})
+
+	fail = false
+	_, id, err := mg.ReplayFailedSend(context.Background(), &failed)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, id, "<replayed@example.com>")
+}
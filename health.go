@@ -0,0 +1,141 @@
+package mailgun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultHealthCheckTTL is how long the result of `Healthy()` is reused before Mailgun is asked again.
+const DefaultHealthCheckTTL = 30 * time.Second
+
+var (
+	// ErrHealthAuth is wrapped by the HealthError of a client whose API key Mailgun rejects.
+	ErrHealthAuth = errors.New("mailgun rejected the API key")
+	// ErrHealthUnreachable is wrapped by the HealthError of a client which cannot reach Mailgun,
+	// or which Mailgun answers with a server error.
+	ErrHealthUnreachable = errors.New("mailgun is unreachable")
+)
+
+// HealthError is returned by `Healthy()` when the client is not healthy. Reason is ErrHealthAuth
+// or ErrHealthUnreachable, so `errors.Is(err, mailgun.ErrHealthAuth)` tells a misconfigured key,
+// which restarting will not fix, from an outage; Err is the error of the request.
+type HealthError struct {
+	Reason error
+	Err    error
+}
+
+func (e *HealthError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Err)
+}
+
+// Unwrap returns the Reason.
+func (e *HealthError) Unwrap() error {
+	return e.Reason
+}
+
+// healthCheck caches the result of the last health check, and shares a check in flight between
+// its callers.
+type healthCheck struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	checked  time.Time
+	err      error
+	inflight chan struct{}
+}
+
+// SetHealthCheckTTL sets how long the result of `Healthy()` is reused; the default is
+// DefaultHealthCheckTTL.
+func (mg *MailgunImpl) SetHealthCheckTTL(ttl time.Duration) {
+	h := mg.healthCheck()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ttl = ttl
+}
+
+// Healthy reports whether the client can reach Mailgun and its API key is accepted, with a
+// request for a single domain of the account. The result is reused for the TTL set with
+// `SetHealthCheckTTL()`, and concurrent callers share one request, so probes calling it often
+// make few requests. Answers rejected with 429 Too Many Requests count as healthy, as they show
+// that Mailgun is reachable and the key valid. Failures are returned as a *HealthError.
+func (mg *MailgunImpl) Healthy(ctx context.Context) error {
+	h := mg.healthCheck()
+	for {
+		h.mu.Lock()
+		if !h.checked.IsZero() && mg.clock().Now().Sub(h.checked) < h.ttl {
+			err := h.err
+			h.mu.Unlock()
+			return err
+		}
+		if h.inflight == nil {
+			break
+		}
+		wait := h.inflight
+		h.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return &HealthError{Reason: ErrHealthUnreachable, Err: ctx.Err()}
+		}
+	}
+	done := make(chan struct{})
+	h.inflight = done
+	h.mu.Unlock()
+
+	err := mg.checkHealth(ctx)
+
+	h.mu.Lock()
+	h.inflight = nil
+	// A check cut short by the caller says nothing about Mailgun, so it is not cached
+	if ctx.Err() == nil {
+		h.checked, h.err = mg.clock().Now(), err
+	}
+	h.mu.Unlock()
+	close(done)
+	return err
+}
+
+// HealthHandler returns a handler for readiness probes, which answers 200 OK when `Healthy()`
+// returns nil, and 503 Service Unavailable with the error otherwise.
+//
+//  http.Handle("/readyz", mg.HealthHandler())
+func (mg *MailgunImpl) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if err := mg.Healthy(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+}
+
+func (mg *MailgunImpl) checkHealth(ctx context.Context) error {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint))
+	r.addParameter("limit", "1")
+
+	_, err := makeGetRequest(ctx, r)
+	if err == nil {
+		return nil
+	}
+	switch GetStatusFromErr(err) {
+	case http.StatusTooManyRequests:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &HealthError{Reason: ErrHealthAuth, Err: err}
+	}
+	return &HealthError{Reason: ErrHealthUnreachable, Err: err}
+}
+
+// healthCheck returns the health check of the client.
+func (mg *MailgunImpl) healthCheck() *healthCheck {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	if mg.health == nil {
+		mg.health = &healthCheck{ttl: DefaultHealthCheckTTL}
+	}
+	return mg.health
+}
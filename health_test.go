@@ -0,0 +1,59 @@
+package mailgun_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestHealthy(t *testing.T) {
+	var requests int
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(status)
+		w.Write([]byte(`{"total_count": 1, "items": []}`))
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	// Results are reused for the TTL
+	for i := 0; i < 3; i++ {
+		ensure.Nil(t, mg.Healthy(ctx))
+	}
+	ensure.DeepEqual(t, requests, 1)
+
+	mg.SetHealthCheckTTL(0)
+	status = http.StatusUnauthorized
+	err := mg.Healthy(ctx)
+	ensure.True(t, errors.Is(err, mailgun.ErrHealthAuth))
+	var healthErr *mailgun.HealthError
+	ensure.True(t, errors.As(err, &healthErr))
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(healthErr.Err), http.StatusUnauthorized)
+
+	status = http.StatusTooManyRequests
+	ensure.Nil(t, mg.Healthy(ctx))
+
+	status = http.StatusBadGateway
+	ensure.True(t, errors.Is(mg.Healthy(ctx), mailgun.ErrHealthUnreachable))
+	w := httptest.NewRecorder()
+	mg.HealthHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	ensure.DeepEqual(t, w.Code, http.StatusServiceUnavailable)
+
+	status = http.StatusOK
+	w = httptest.NewRecorder()
+	mg.HealthHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	ensure.DeepEqual(t, w.Code, http.StatusOK)
+
+	srv.Close()
+	ensure.True(t, errors.Is(mg.Healthy(ctx), mailgun.ErrHealthUnreachable))
+	ensure.DeepEqual(t, requests, 6)
+}
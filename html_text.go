@@ -0,0 +1,69 @@
+package mailgun
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	htmlHidden     = regexp.MustCompile(`(?is)<!--.*?-->|<head\b.*?</head\s*>|<script\b.*?</script\s*>|<style\b.*?</style\s*>`)
+	htmlSpace      = regexp.MustCompile(`\s+`)
+	htmlLink       = regexp.MustCompile(`(?is)<a\b[^>]*?\bhref\s*=\s*("[^"]*"|'[^']*')[^>]*>(.*?)</a\s*>`)
+	htmlHeading    = regexp.MustCompile(`(?i)<h([1-6])\b[^>]*>`)
+	htmlListItem   = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	htmlRule       = regexp.MustCompile(`(?i)<hr\b[^>]*>`)
+	htmlLineBreak  = regexp.MustCompile(`(?i)<br\b[^>]*>`)
+	htmlCell       = regexp.MustCompile(`(?i)</t[dh]\s*>`)
+	htmlBlock      = regexp.MustCompile(`(?i)</?(p|div|h[1-6]|table|tr|ul|ol|blockquote|pre|section|article|header|footer)\b[^>]*>`)
+	htmlTag        = regexp.MustCompile(`<[^>]*>`)
+	textBlankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// SetTextFromHtml enables or disables deriving the plain text body from the HTML body when the
+// message is sent without one, using `HtmlToText()`. Messages with both parts rank better with
+// spam filters than those with HTML alone. It is disabled by default, and has no effect on MIME messages.
+func (m *Message) SetTextFromHtml(enabled bool) {
+	m.textFromHtml = enabled
+}
+
+// HtmlToText returns a plain text rendering of an HTML body. Tags are stripped, with paragraphs
+// and other blocks separated by blank lines, headings prefixed with #, list items with - and
+// links followed by their URL in parentheses. Scripts, styles and comments are dropped.
+//
+//  mailgun.HtmlToText(`<h1>Hi</h1><p>See <a href="https://example.com">our site</a>.</p>`)
+//  // # Hi
+//  //
+//  // See our site (https://example.com).
+func HtmlToText(body string) string {
+	s := htmlHidden.ReplaceAllString(body, "")
+	s = htmlSpace.ReplaceAllString(s, " ")
+	s = htmlLink.ReplaceAllStringFunc(s, func(a string) string {
+		parts := htmlLink.FindStringSubmatch(a)
+		href := html.UnescapeString(parts[1][1 : len(parts[1])-1])
+		text := strings.TrimSpace(parts[2])
+		if href == "" || strings.HasPrefix(href, "#") || strings.TrimPrefix(href, "mailto:") == html.UnescapeString(htmlTag.ReplaceAllString(text, "")) {
+			return text
+		}
+		return text + " (" + href + ")"
+	})
+	s = htmlHeading.ReplaceAllStringFunc(s, func(h string) string {
+		level, _ := strconv.Atoi(htmlHeading.FindStringSubmatch(h)[1])
+		return "\n\n" + strings.Repeat("#", level) + " "
+	})
+	s = htmlListItem.ReplaceAllString(s, "\n- ")
+	s = htmlRule.ReplaceAllString(s, "\n\n---\n\n")
+	s = htmlLineBreak.ReplaceAllString(s, "\n")
+	s = htmlCell.ReplaceAllString(s, " ")
+	s = htmlBlock.ReplaceAllString(s, "\n\n")
+	s = htmlTag.ReplaceAllString(s, "")
+	s = strings.Replace(html.UnescapeString(s), "\u00a0", " ", -1)
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	s = textBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(s)
+}
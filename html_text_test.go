@@ -0,0 +1,46 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestHtmlToText(t *testing.T) {
+	for _, tt := range []struct {
+		html, text string
+	}{
+		{"Hello", "Hello"},
+		{"<p>Hello,\n   world</p><p>Second&nbsp;paragraph &amp; more</p>", "Hello, world\n\nSecond paragraph & more"},
+		{`<h1>Title</h1><h3>Sub</h3>line one<br>line two`, "# Title\n\n### Sub\n\nline one\nline two"},
+		{`<ul><li>one</li><li>two</li></ul>`, "- one\n- two"},
+		{`<a href="https://example.com/?a=1&amp;b=2">Visit</a> <a href="mailto:me@example.com">me@example.com</a>`, "Visit (https://example.com/?a=1&b=2) me@example.com"},
+		{`<html><head><title>x</title><style>p {}</style></head><body><!-- hidden --><script>alert(1)</script><div>Body</div></body></html>`, "Body"},
+		{`<table><tr><td>a</td><td>b</td></tr><tr><td>c</td></tr></table><hr>end`, "a b\n\nc\n\n---\n\nend"},
+	} {
+		ensure.DeepEqual(t, HtmlToText(tt.html), tt.text)
+	}
+}
+
+func TestSendTextFromHtml(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ensure.Nil(t, req.ParseMultipartForm(32<<20))
+		ensure.DeepEqual(t, req.MultipartForm.Value["text"], []string{"# Welcome\n\nThanks for joining."})
+		fmt.Fprint(w, `{"message":"Queued, Thank you", "id":"<20111114174239.25659.5820@samples.mailgun.org>"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	m := mg.NewMessage(fromUser, exampleSubject, "", "test@test.com")
+	m.SetHtml("<h1>Welcome</h1><p>Thanks for joining.</p>")
+	m.SetTextFromHtml(true)
+
+	_, _, err := mg.Send(context.Background(), m)
+	ensure.Nil(t, err)
+}
@@ -1,7 +1,9 @@
 package mailgun
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -15,9 +17,33 @@ import (
 	"path"
 	"regexp"
 	"strings"
+	"time"
 )
 
-var validURL = regexp.MustCompile(`^/v[2-4].*`)
+// apiVersions are the versions of the API the client addresses, which API bases and the URLs
+// of requests must hold. The versions in endpointVersions must be among them.
+var apiVersions = []string{"v2", "v3", "v4", "v5"}
+
+// errBaseAPI is returned for requests whose URL holds none of apiVersions.
+var errBaseAPI = errors.New(baseAPIMessage())
+
+func baseAPIMessage() string {
+	names := make([]string, len(apiVersions))
+	for i, v := range apiVersions {
+		names[i] = "/" + v
+	}
+	last := len(names) - 1
+	return fmt.Sprintf(`BaseAPI must end with a %s or %s; setBaseAPI("https://host/v3")`,
+		strings.Join(names[:last], ", "), names[last])
+}
+
+// validURL matches the paths of API urls, which hold the API version after any path prefix of a
+// gateway the API is reached through.
+var validURL = regexp.MustCompile(`/` + apiVersionPattern + `(/|$)`)
+var apiVersionSuffix = regexp.MustCompile(`/` + apiVersionPattern + `$`)
+
+// apiVersionPattern matches any of apiVersions.
+var apiVersionPattern = `(` + strings.Join(apiVersions, "|") + `)`
 
 type httpRequest struct {
 	URL               string
@@ -26,13 +52,42 @@ type httpRequest struct {
 	BasicAuthUser     string
 	BasicAuthPassword string
 	Client            *http.Client
+	options           requestOptions
+}
+
+// requestOptions are the settings of a client which apply to every request it makes.
+type requestOptions struct {
+	disableCompression bool
+	maxResponseSize    int64
+	requestTimeout     time.Duration
+	deadlineHeader     string
+	breaker            *circuitBreaker
+	codec              JSONCodec
+	cache              *responseCache
+	revalidation       *revalidationCache
+	userAgent          string
+	headers            map[string]string
+	onDeprecation      DeprecationHandler
+	onAudit            AuditHook
+	onTiming           TimingHook
+	rateBudget         *RateBudget
+}
+
+// httpClient is implemented by the clients requests are made for. Clients which implement
+// requestOptions() as well apply their options to each request.
+type httpClient interface {
+	Client() *http.Client
 }
 
 type httpResponse struct {
-	Code int
-	Data []byte
+	Code   int
+	Data   []byte
+	Header http.Header
+	codec  JSONCodec
 }
 
+// payload is the body of a request. *formDataPayload is the only implementation; it is encoded as
+// application/x-www-form-urlencoded or multipart/form-data, as its fields and files require.
 type payload interface {
 	getPayloadBuffer() (*bytes.Buffer, error)
 	getContentType() string
@@ -48,6 +103,8 @@ type keyNameRC struct {
 	key   string
 	name  string
 	value io.ReadCloser
+	// offset is where a reader of a rewindable payload starts.
+	offset int64
 }
 
 type keyNameBuff struct {
@@ -57,15 +114,19 @@ type keyNameBuff struct {
 }
 
 type formDataPayload struct {
-	contentType string
+	// multipart is set if the payload is encoded as multipart/form-data even with no files.
+	multipart   bool
+	boundary    string
 	Values      []keyValuePair
 	Files       []keyValuePair
 	ReadClosers []keyNameRC
 	Buffers     []keyNameBuff
-}
-
-type urlEncodedPayload struct {
-	Values []keyValuePair
+	// contentTypes are the content types set for files, by name.
+	contentTypes map[string]string
+	// sourceHash, if set, is the hash of the payload this one was transformed from.
+	sourceHash string
+	// replay is set by rewindable(): readers are rewound for each request, and left open.
+	replay bool
 }
 
 func newHTTPRequest(url string) *httpRequest {
@@ -79,8 +140,39 @@ func (r *httpRequest) addParameter(name, value string) {
 	r.Parameters[name] = append(r.Parameters[name], value)
 }
 
-func (r *httpRequest) setClient(c *http.Client) {
-	r.Client = c
+// setParameter sets the query parameter name to value, replacing the values it had, such as one
+// of the extra query parameters of the options of a request.
+func (r *httpRequest) setParameter(name, value string) {
+	if r.Parameters == nil {
+		r.Parameters = make(map[string][]string)
+	}
+	r.Parameters[name] = []string{value}
+}
+
+// addParameters adds the extra query parameters of the options of a request.
+func (r *httpRequest) addParameters(params map[string]string) {
+	for name, value := range params {
+		r.addParameter(name, value)
+	}
+}
+
+// withParameters returns address with the extra query parameters of the options of a request.
+func withParameters(address string, params map[string]string) string {
+	if len(params) == 0 {
+		return address
+	}
+	q := url.Values{}
+	for name, value := range params {
+		q.Add(name, value)
+	}
+	return address + "?" + q.Encode()
+}
+
+func (r *httpRequest) setClient(c httpClient) {
+	r.Client = c.Client()
+	if o, ok := c.(interface{ requestOptions() requestOptions }); ok {
+		r.options = o.requestOptions()
+	}
 }
 
 func (r *httpRequest) setBasicAuth(user, password string) {
@@ -88,36 +180,53 @@ func (r *httpRequest) setBasicAuth(user, password string) {
 	r.BasicAuthPassword = password
 }
 
-func newUrlEncodedPayload() *urlEncodedPayload {
-	return &urlEncodedPayload{}
+// apiClient is a client of the Mailgun API, such as MailgunImpl or EmailValidatorImpl.
+type apiClient interface {
+	httpClient
+	APIKey() string
 }
 
-func (f *urlEncodedPayload) addValue(key, value string) {
-	f.Values = append(f.Values, keyValuePair{key: key, value: value})
+// newAPIRequest returns a request to url sent with the http client and settings of c, and
+// authenticated with its API key.
+func newAPIRequest(c apiClient, url string) *httpRequest {
+	r := newHTTPRequest(url)
+	r.setClient(c)
+	r.setBasicAuth(basicAuthUser, c.APIKey())
+	return r
 }
 
-func (f *urlEncodedPayload) getPayloadBuffer() (*bytes.Buffer, error) {
-	data := url.Values{}
-	for _, keyVal := range f.Values {
-		data.Add(keyVal.key, keyVal.value)
-	}
-	return bytes.NewBufferString(data.Encode()), nil
+// newPayload returns a payload which is url encoded, unless files, buffers or readers are added
+// to it, when it is encoded as multipart/form-data. Multipart fields are encoded in the order they
+// are added, and url encoded fields sorted by key.
+func newPayload() *formDataPayload {
+	return &formDataPayload{}
 }
 
-func (f *urlEncodedPayload) getContentType() string {
-	return "application/x-www-form-urlencoded"
+// newFormDataPayload returns a payload which is encoded as multipart/form-data even with no files,
+// as messages are sent.
+func newFormDataPayload() *formDataPayload {
+	return &formDataPayload{multipart: true}
 }
 
-func (f *urlEncodedPayload) getValues() []keyValuePair {
-	return f.Values
+// isMultipart reports whether the payload is encoded as multipart/form-data.
+func (f *formDataPayload) isMultipart() bool {
+	return f.multipart || len(f.Files) != 0 || len(f.ReadClosers) != 0 || len(f.Buffers) != 0
 }
 
-func (r *httpResponse) parseFromJSON(v interface{}) error {
-	return json.Unmarshal(r.Data, v)
+// urlEncoded returns the fields of the payload url encoded, sorted by key as url.Values encodes them.
+func (f *formDataPayload) urlEncoded() *bytes.Buffer {
+	data := make(url.Values, len(f.Values))
+	for _, keyVal := range f.Values {
+		data.Add(keyVal.key, keyVal.value)
+	}
+	return bytes.NewBufferString(data.Encode())
 }
 
-func newFormDataPayload() *formDataPayload {
-	return &formDataPayload{}
+func (r *httpResponse) parseFromJSON(v interface{}) error {
+	if r.codec != nil {
+		return r.codec.Unmarshal(r.Data, v)
+	}
+	return json.Unmarshal(r.Data, v)
 }
 
 func (f *formDataPayload) getValues() []keyValuePair {
@@ -141,59 +250,85 @@ func (f *formDataPayload) addReadCloser(key, name string, rc io.ReadCloser) {
 }
 
 func (f *formDataPayload) getPayloadBuffer() (*bytes.Buffer, error) {
+	if !f.isMultipart() {
+		return f.urlEncoded(), nil
+	}
 	data := &bytes.Buffer{}
 	writer := multipart.NewWriter(data)
+	if err := writer.SetBoundary(f.getBoundary()); err != nil {
+		return nil, err
+	}
 	defer writer.Close()
 
 	for _, keyVal := range f.Values {
 		if tmp, err := writer.CreateFormField(keyVal.key); err == nil {
-			tmp.Write([]byte(keyVal.value))
+			io.WriteString(tmp, keyVal.value)
 		} else {
 			return nil, err
 		}
 	}
 
 	for _, file := range f.Files {
-		if tmp, err := writer.CreateFormFile(file.key, path.Base(file.value)); err == nil {
-			if fp, err := os.Open(file.value); err == nil {
-				defer fp.Close()
-				io.Copy(tmp, fp)
-			} else {
-				return nil, err
-			}
-		} else {
+		fp, err := os.Open(file.value)
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		if err := f.writeFile(writer, file.key, path.Base(file.value), fp); err != nil {
 			return nil, err
 		}
 	}
 
 	for _, file := range f.ReadClosers {
-		if tmp, err := writer.CreateFormFile(file.key, file.name); err == nil {
-			defer file.value.Close()
-			io.Copy(tmp, file.value)
+		if f.replay {
+			if _, err := file.value.(io.Seeker).Seek(file.offset, io.SeekStart); err != nil {
+				return nil, errors.Wrapf(err, "while rewinding '%s'", file.name)
+			}
 		} else {
+			defer file.value.Close()
+		}
+		if err := f.writeFile(writer, file.key, file.name, file.value); err != nil {
 			return nil, err
 		}
 	}
 
 	for _, buff := range f.Buffers {
-		if tmp, err := writer.CreateFormFile(buff.key, buff.name); err == nil {
-			r := bytes.NewReader(buff.value)
-			io.Copy(tmp, r)
+		if tmp, err := createFormFile(writer, buff.key, buff.name, f.contentType(buff.name, buff.value)); err == nil {
+			tmp.Write(buff.value)
 		} else {
 			return nil, err
 		}
 	}
 
-	f.contentType = writer.FormDataContentType()
-
 	return data, nil
 }
 
+// writeFile writes the file read from r as a part, with the content type detected from its start.
+func (f *formDataPayload) writeFile(writer *multipart.Writer, key, name string, r io.Reader) error {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, _ := br.Peek(sniffLen)
+	tmp, err := createFormFile(writer, key, name, f.contentType(name, head))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, br)
+	return err
+}
+
 func (f *formDataPayload) getContentType() string {
-	if f.contentType == "" {
-		f.getPayloadBuffer()
+	if !f.isMultipart() {
+		return "application/x-www-form-urlencoded"
+	}
+	return "multipart/form-data; boundary=" + f.getBoundary()
+}
+
+// getBoundary returns the multipart boundary of the payload, choosing one the first time, so the
+// content type is known without encoding the payload.
+func (f *formDataPayload) getBoundary() string {
+	if f.boundary == "" {
+		f.boundary = multipart.NewWriter(nil).Boundary()
 	}
-	return f.contentType
+	return f.boundary
 }
 
 func (r *httpRequest) addHeader(name, value string) {
@@ -251,65 +386,197 @@ func (r *httpRequest) NewRequest(ctx context.Context, method string, payload pay
 	for header, value := range r.Headers {
 		req.Header.Add(header, value)
 	}
+	for header, value := range r.options.headers {
+		if req.Header.Get(header) == "" {
+			req.Header.Set(header, value)
+		}
+	}
+	// User-Agent headers set by the caller are kept
+	if ua := r.options.userAgent; ua != "" && (req.Header.Get("User-Agent") == "" || req.Header.Get("User-Agent") == MailgunGoUserAgent) {
+		req.Header.Set("User-Agent", ua)
+	}
+
+	// Asking for gzip explicitly stops the transport from decompressing the response itself,
+	// which makeRequest does instead; identity stops it from asking for gzip.
+	if req.Header.Get("Accept-Encoding") == "" {
+		if r.options.disableCompression {
+			req.Header.Set("Accept-Encoding", "identity")
+		} else {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+	}
 	return req, nil
 }
 
 func (r *httpRequest) makeRequest(ctx context.Context, method string, payload payload) (*httpResponse, error) {
+	var response *httpResponse
+	err := r.doRequest(ctx, method, payload, func(code int, header http.Header, body io.Reader) error {
+		data, err := ioutil.ReadAll(body)
+		if err == ErrResponseTooLarge {
+			return err
+		}
+		if err != nil {
+			return errors.Wrap(err, "while reading response body")
+		}
+		response = &httpResponse{Code: code, Data: data, Header: header, codec: r.options.codec}
+		return nil
+	})
+	return response, err
+}
+
+// makeJSONRequest works as makeRequest, except that a response with one of the good status codes
+// is decoded into v as it is read, rather than read into memory first. The Data of the returned
+// response is only set for other status codes.
+func (r *httpRequest) makeJSONRequest(ctx context.Context, method string, payload payload, good []int, v interface{}) (*httpResponse, error) {
+	var response *httpResponse
+	err := r.doRequest(ctx, method, payload, func(code int, header http.Header, body io.Reader) error {
+		response = &httpResponse{Code: code, Header: header}
+		if notGood(code, good) {
+			data, err := ioutil.ReadAll(body)
+			if err == ErrResponseTooLarge {
+				return err
+			}
+			if err != nil {
+				return errors.Wrap(err, "while reading response body")
+			}
+			response.Data = data
+			return nil
+		}
+		if r.options.codec != nil {
+			data, err := ioutil.ReadAll(body)
+			if err == ErrResponseTooLarge {
+				return err
+			}
+			if err != nil {
+				return errors.Wrap(err, "while reading response body")
+			}
+			return r.options.codec.Unmarshal(data, v)
+		}
+		return json.NewDecoder(body).Decode(v)
+	})
+	return response, err
+}
+
+// doRequest makes the request and passes the status code, headers and body of the response to read.
+func (r *httpRequest) doRequest(ctx context.Context, method string, payload payload, read func(code int, header http.Header, body io.Reader) error) (err error) {
+	ctx, cancel := r.options.requestContext(ctx)
+	defer cancel()
+	ctx, traced := r.traceRequest(ctx, method)
+	var status int
+	defer func() { traced(status, err) }()
 	req, err := r.NewRequest(ctx, method, payload)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	r.options.setDeadlineHeader(req)
 
 	if Debug {
 		fmt.Println(r.curlString(req, payload))
 	}
 
-	response := httpResponse{}
-
+	var generation uint64
+	if b := r.options.breaker; b != nil {
+		if generation, err = b.allow(); err != nil {
+			return err
+		}
+	}
 	resp, err := r.Client.Do(req)
+	if b := r.options.breaker; b != nil {
+		b.done(generation, breakerFailure(resp, err), ctx.Err() == nil)
+	}
+	if c := r.options.cache; c != nil && method != http.MethodGet && method != http.MethodHead {
+		// The request may have changed what is cached for its endpoint, even if it failed
+		c.invalidate(r.URL)
+	}
+	if b := r.options.rateBudget; b != nil {
+		b.observe(r.URL, resp)
+	}
 	if resp != nil {
-		response.Code = resp.StatusCode
+		status = resp.StatusCode
+		r.audit(ctx, method, resp.StatusCode, nil)
+	} else {
+		r.audit(ctx, method, 0, err)
 	}
 	if err != nil {
 		if urlErr, ok := err.(*url.Error); ok {
 			if urlErr.Err == io.EOF {
-				return nil, errors.Wrap(err, "remote server prematurely closed connection")
+				return errors.Wrap(err, "remote server prematurely closed connection")
 			}
 		}
-		return nil, errors.Wrap(err, "while making http request")
+		return errors.Wrap(err, "while making http request")
 	}
 
 	defer resp.Body.Close()
-	responseBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "while reading response body")
+	if h := r.options.onDeprecation; h != nil {
+		if notice, ok := parseDeprecation(req, resp); ok {
+			h(ctx, notice)
+		}
+	}
+	body := io.Reader(resp.Body)
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return errors.Wrap(err, "while decompressing response body")
+		}
+		defer gz.Close()
+		body = gz
+	}
+	if r.options.maxResponseSize > 0 {
+		// Limit the decompressed size, so a small compressed response cannot exhaust memory
+		body = &maxSizeReader{r: body, n: r.options.maxResponseSize}
 	}
+	return read(resp.StatusCode, resp.Header, body)
+}
 
-	response.Data = responseBody
-	return &response, nil
+// maxSizeReader reads from r until n bytes have been read, then fails with ErrResponseTooLarge
+// if r holds more.
+type maxSizeReader struct {
+	r io.Reader
+	n int64
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	if m.n <= 0 {
+		var b [1]byte
+		n, err := m.r.Read(b[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > m.n {
+		p = p[:m.n]
+	}
+	n, err := m.r.Read(p)
+	m.n -= int64(n)
+	return n, err
 }
 
 func (r *httpRequest) generateUrlWithParameters() (string, error) {
-	url, err := url.Parse(r.URL)
+	u, err := url.Parse(r.URL)
 	if err != nil {
 		return "", err
 	}
 
-	if !validURL.MatchString(url.Path) {
-		return "", errors.New(`BaseAPI must end with a /v2, /v3 or /v4; setBaseAPI("https://host/v3")`)
+	if !validURL.MatchString(u.Path) {
+		return "", errBaseAPI
 	}
 
-	q := url.Query()
-	if r.Parameters != nil && len(r.Parameters) > 0 {
-		for name, values := range r.Parameters {
-			for _, value := range values {
-				q.Add(name, value)
-			}
+	if u.RawQuery == "" {
+		// Encoding the parameters directly saves parsing, and copying them into, an empty query
+		u.RawQuery = url.Values(r.Parameters).Encode()
+		return u.String(), nil
+	}
+
+	q := u.Query()
+	for name, values := range r.Parameters {
+		for _, value := range values {
+			q.Add(name, value)
 		}
 	}
-	url.RawQuery = q.Encode()
+	u.RawQuery = q.Encode()
 
-	return url.String(), nil
+	return u.String(), nil
 }
 
 func (r *httpRequest) curlString(req *http.Request, p payload) string {
@@ -322,8 +589,12 @@ func (r *httpRequest) curlString(req *http.Request, p payload) string {
 	//parts = append(parts, fmt.Sprintf(" --user '%s:%s'", r.BasicAuthUser, r.BasicAuthPassword))
 
 	if p != nil {
+		flag := "-F"
+		if p.getContentType() == "application/x-www-form-urlencoded" {
+			flag = "--data-urlencode"
+		}
 		for _, param := range p.getValues() {
-			parts = append(parts, fmt.Sprintf(" -F %s='%s'", param.key, param.value))
+			parts = append(parts, fmt.Sprintf(" %s %s='%s'", flag, param.key, param.value))
 		}
 	}
 	return strings.Join(parts, " ")
@@ -0,0 +1,194 @@
+package mailgun
+
+import (
+	"context"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestGenerateUrlWithParameters(t *testing.T) {
+	r := newHTTPRequest("https://api.mailgun.net/v3/example.com/events")
+	u, err := r.generateUrlWithParameters()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, u, "https://api.mailgun.net/v3/example.com/events")
+
+	r.addParameter("limit", "100")
+	r.addParameter("event", "failed OR delivered")
+	r.addParameter("tags", "a&b")
+	r.addParameter("tags", "c")
+	u, err = r.generateUrlWithParameters()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, u, "https://api.mailgun.net/v3/example.com/events?event=failed+OR+delivered&limit=100&tags=a%26b&tags=c")
+
+	// Parameters are merged with a query already on the url
+	r = newHTTPRequest("https://api.mailgun.net/v3/example.com/events?page=next")
+	r.addParameter("limit", "100")
+	u, err = r.generateUrlWithParameters()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, u, "https://api.mailgun.net/v3/example.com/events?limit=100&page=next")
+
+	_, err = newHTTPRequest("https://api.mailgun.net/events").generateUrlWithParameters()
+	ensure.NotNil(t, err)
+}
+
+func TestUrlEncodedPayload(t *testing.T) {
+	p := newPayload()
+	p.addValue("address", "joe+list@example.com")
+	p.addValue("name", "Joe Example")
+	p.addValue("name", "second")
+
+	b, err := p.getPayloadBuffer()
+	ensure.Nil(t, err)
+	values, err := url.ParseQuery(b.String())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, values, url.Values{
+		"address": {"joe+list@example.com"},
+		"name":    {"Joe Example", "second"},
+	})
+
+	ensure.DeepEqual(t, b.String(), "address=joe%2Blist%40example.com&name=Joe+Example&name=second")
+
+	// Fields are sorted by key, keeping the order of the values of each
+	p = newPayload()
+	p.addValue("name", "Joe Example")
+	p.addValue("address", "joe@example.com")
+	p.addValue("name", "second")
+	b, err = p.getPayloadBuffer()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, b.String(), "address=joe%40example.com&name=Joe+Example&name=second")
+}
+
+func TestPayloadEncoding(t *testing.T) {
+	p := newPayload()
+	p.addValue("description", "Dev team")
+	ensure.DeepEqual(t, p.getContentType(), "application/x-www-form-urlencoded")
+	b, err := p.getPayloadBuffer()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, b.String(), "description=Dev+team")
+	size, err := p.size()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, size, int64(b.Len()))
+
+	// Files switch the payload to multipart
+	p.addBuffer("members", "members.csv", []byte("joe@example.com\n"))
+	mediaType, params, err := mime.ParseMediaType(p.getContentType())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, mediaType, "multipart/form-data")
+	b, err = p.getPayloadBuffer()
+	ensure.Nil(t, err)
+	form, err := multipart.NewReader(b, params["boundary"]).ReadForm(1 << 20)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, form.Value["description"], []string{"Dev team"})
+	ensure.DeepEqual(t, form.File["members"][0].Filename, "members.csv")
+
+	// Messages are always sent as multipart
+	p = newFormDataPayload()
+	p.addValue("from", "joe@example.com")
+	mediaType, _, err = mime.ParseMediaType(p.getContentType())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, mediaType, "multipart/form-data")
+}
+
+func TestFormDataContentType(t *testing.T) {
+	p := newFormDataPayload()
+	p.addValue("from", "joe@example.com")
+	p.addBuffer("attachment", "a.txt", []byte("attached"))
+
+	contentType := p.getContentType()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, mediaType, "multipart/form-data")
+
+	b, err := p.getPayloadBuffer()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, p.getContentType(), contentType)
+
+	form, err := multipart.NewReader(b, params["boundary"]).ReadForm(1 << 20)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, form.Value["from"], []string{"joe@example.com"})
+	ensure.DeepEqual(t, form.File["attachment"][0].Filename, "a.txt")
+}
+
+func TestGeneratePublicApiUrl(t *testing.T) {
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(APIBaseEU)
+	ensure.DeepEqual(t, generatePublicApiUrl(mg, listsEndpoint+"/pages"), "https://api.eu.mailgun.net/v3/lists/pages")
+	ensure.DeepEqual(t, generatePublicApiUrl(mg, accountsEndpoint+"/http_signing_key"), "https://api.eu.mailgun.net/v5/accounts/http_signing_key")
+	ensure.DeepEqual(t, generatePublicApiUrl(mg, ipAllowlistEndpoint), "https://api.eu.mailgun.net/v2/ip_whitelist")
+
+	endpointVersions["newfeature"] = "v4"
+	defer delete(endpointVersions, "newfeature")
+	ensure.DeepEqual(t, generatePublicApiUrl(mg, "newfeature"), "https://api.eu.mailgun.net/v4/newfeature")
+}
+
+func TestAPIVersions(t *testing.T) {
+	// The URLs of registered endpoints must be accepted
+	for endpoint, version := range endpointVersions {
+		ensure.True(t, validURL.MatchString("/"+version+"/"+endpoint))
+	}
+	for _, path := range []string{"/v1", "/v6/domains", "/v30", "/gateway/v3x"} {
+		ensure.False(t, validURL.MatchString(path))
+	}
+	ensure.True(t, validURL.MatchString("/gateway/v3/domains"))
+	ensure.True(t, apiVersionSuffix.MatchString("/gateway/v5"))
+	ensure.False(t, apiVersionSuffix.MatchString("/v1"))
+}
+
+func BenchmarkGenerateUrlWithParameters(b *testing.B) {
+	r := newHTTPRequest("https://api.mailgun.net/v3/example.com/events")
+	r.addParameter("limit", "300")
+	r.addParameter("event", "delivered")
+	r.addParameter("ascending", "yes")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.generateUrlWithParameters(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUrlEncodedPayload(b *testing.B) {
+	p := newPayload()
+	p.addValue("address", "joe@example.com")
+	p.addValue("name", "Joe Example")
+	p.addValue("subscribed", "yes")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.getPayloadBuffer(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestForDomain(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetDefaultPageSize(50)
+	mg.SetSuppressionGuard(&SuppressionGuardOptions{})
+	mg.SetHeader("X-Team", "mail")
+	ctx := context.Background()
+	_, err := mg.PauseSending(ctx, "paused.example.com", nil)
+	ensure.Nil(t, err)
+
+	dm := mg.forDomain("other.example.com")
+	ensure.DeepEqual(t, dm.Domain(), "other.example.com")
+	ensure.DeepEqual(t, dm.defaultPageSize, 50)
+	ensure.DeepEqual(t, dm.guard, mg.guard)
+	ensure.DeepEqual(t, dm.headers, mg.headers)
+	ensure.True(t, dm.bulk == mg.bulk)
+	ensure.True(t, dm.SendingPaused("paused.example.com"))
+	ensure.True(t, mg.forDomain(exampleDomain) == mg)
+
+	// The clone changes apart from mg
+	_, err = dm.ResumeSending(ctx, "paused.example.com")
+	ensure.Nil(t, err)
+	ensure.True(t, mg.SendingPaused("paused.example.com"))
+}
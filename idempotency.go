@@ -0,0 +1,241 @@
+package mailgun
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyWindow is how long a client remembers the idempotency key of a message.
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+var (
+	// ErrSendInProgress is returned by `Send()` when a message with the same idempotency key is being sent.
+	ErrSendInProgress = errors.New("a message with this idempotency key is already being sent")
+	// ErrIdempotencyKeyReused is returned by `Send()` when the idempotency key was used for a different message.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different message")
+	// ErrSendOutcomeUnknown is returned by `Send()` when an earlier send of a message with the same idempotency
+	// key failed in a way that leaves it unknown whether Mailgun accepted it, such as a dropped connection. The
+	// message is not submitted again; check the events api for it, and call `ForgetIdempotencyKey()` to allow
+	// it to be sent.
+	ErrSendOutcomeUnknown = errors.New("an earlier send with this idempotency key may have succeeded")
+)
+
+// States of an IdempotencyRecord.
+const (
+	IdempotencyPending = "pending"
+	IdempotencySent    = "sent"
+	IdempotencyUnknown = "unknown"
+)
+
+// An IdempotencyRecord is what an IdempotencyStore remembers of a message sent with an idempotency key.
+type IdempotencyRecord struct {
+	Key string
+	// Hash identifies the content of the message, so a key cannot be reused for a different message.
+	Hash string
+	// State is IdempotencyPending, IdempotencySent or IdempotencyUnknown.
+	State string
+	// Message and ID are the response to a message which was sent.
+	Message string
+	ID      string
+	// UpdatedAt is when the record was last changed; records older than the window are ignored.
+	UpdatedAt time.Time
+}
+
+// IdempotencyStore keeps the idempotency keys of sent messages. The default store is held in memory
+// by each client; implement the interface to share keys between processes. Implementations must be
+// safe for concurrent use.
+type IdempotencyStore interface {
+	// Reserve records rec if no record with the same key was updated within window before
+	// rec.UpdatedAt, and returns nil. Otherwise it returns the existing record and leaves it
+	// unchanged. This must be atomic.
+	Reserve(ctx context.Context, rec IdempotencyRecord, window time.Duration) (*IdempotencyRecord, error)
+	// Update replaces the record with the same key.
+	Update(ctx context.Context, rec IdempotencyRecord) error
+	// Delete removes the record with the key, if any.
+	Delete(ctx context.Context, key string) error
+}
+
+// SetIdempotencyWindow sets how long the client remembers idempotency keys; the default is
+// DefaultIdempotencyWindow.
+func (mg *MailgunImpl) SetIdempotencyWindow(window time.Duration) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.idempotencyWindow = window
+}
+
+// SetIdempotencyStore sets the store used to remember idempotency keys.
+func (mg *MailgunImpl) SetIdempotencyStore(store IdempotencyStore) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.idempotencyStore = store
+}
+
+// ForgetIdempotencyKey removes the key from the idempotency store, so a message with the key can be sent again.
+func (mg *MailgunImpl) ForgetIdempotencyKey(ctx context.Context, key string) error {
+	store, _ := mg.idempotency()
+	return store.Delete(ctx, key)
+}
+
+func (mg *MailgunImpl) idempotency() (IdempotencyStore, time.Duration) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	if mg.idempotencyStore == nil {
+		mg.idempotencyStore = NewMemoryIdempotencyStore()
+	}
+	window := mg.idempotencyWindow
+	if window == 0 {
+		window = DefaultIdempotencyWindow
+	}
+	return mg.idempotencyStore, window
+}
+
+// sendIdempotent makes the request of `Send()`, at most once for each idempotency key within the window.
+func (mg *MailgunImpl) sendIdempotent(ctx context.Context, key string, r *httpRequest, p *formDataPayload, domain, endpoint string) (*sendMessageResponse, error) {
+	// Every message sent for a domain passes here, so a paused domain sends nothing
+	if err := mg.checkSendingPaused(domain); err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return mg.postMessage(ctx, r, p, domain, endpoint, false)
+	}
+
+	if err := p.bufferFiles(); err != nil {
+		return nil, err
+	}
+	rec := IdempotencyRecord{
+		Key:       key,
+		Hash:      p.hash(domain, endpoint),
+		State:     IdempotencyPending,
+		UpdatedAt: mg.clock().Now(),
+	}
+
+	store, window := mg.idempotency()
+	prev, err := store.Reserve(ctx, rec, window)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil {
+		switch {
+		case prev.Hash != rec.Hash:
+			return nil, ErrIdempotencyKeyReused
+		case prev.State == IdempotencySent:
+			return &sendMessageResponse{Message: prev.Message, Id: prev.ID}, nil
+		case prev.State == IdempotencyUnknown:
+			return nil, ErrSendOutcomeUnknown
+		default:
+			return nil, ErrSendInProgress
+		}
+	}
+
+	response, err := mg.postMessage(ctx, r, p, domain, endpoint, true)
+	rec.UpdatedAt = mg.clock().Now()
+	switch {
+	case err == nil:
+		rec.State, rec.Message, rec.ID = IdempotencySent, response.Message, response.Id
+	case isAmbiguousSendError(err):
+		rec.State = IdempotencyUnknown
+	default:
+		// Mailgun rejected the message, so it is safe to send again
+		if delErr := store.Delete(ctx, key); delErr != nil {
+			return nil, delErr
+		}
+		return nil, err
+	}
+	if updateErr := store.Update(ctx, rec); updateErr != nil && err == nil {
+		return response, updateErr
+	}
+	return response, err
+}
+
+// hash returns a digest of the payload, which does not depend on the order of its fields.
+func (f *formDataPayload) hash(domain, endpoint string) string {
+	if f.sourceHash != "" {
+		return f.sourceHash
+	}
+	values := append([]keyValuePair(nil), f.Values...)
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].key != values[j].key {
+			return values[i].key < values[j].key
+		}
+		return values[i].value < values[j].value
+	})
+
+	h := sha256.New()
+	write := func(s string) {
+		io.WriteString(h, s)
+		h.Write([]byte{0})
+	}
+	write(domain)
+	write(endpoint)
+	for _, v := range values {
+		write(v.key)
+		write(v.value)
+	}
+	for _, b := range f.Buffers {
+		write(b.key)
+		write(b.name)
+		h.Write(b.value)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore held in memory.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+	swept   time.Time
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]IdempotencyRecord)}
+}
+
+// Reserve implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, rec IdempotencyRecord, window time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The time is that of the client reserving the key, which may be a fake clock in tests
+	now := rec.UpdatedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if now.Sub(s.swept) > window {
+		for k, r := range s.records {
+			if now.Sub(r.UpdatedAt) > window {
+				delete(s.records, k)
+			}
+		}
+		s.swept = now
+	}
+
+	if prev, ok := s.records[rec.Key]; ok && now.Sub(prev.UpdatedAt) <= window {
+		return &prev, nil
+	}
+	s.records[rec.Key] = rec
+	return nil, nil
+}
+
+// Update implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Update(ctx context.Context, rec IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.Key] = rec
+	return nil
+}
+
+// Delete implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
@@ -0,0 +1,111 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestSendIdempotencyKey(t *testing.T) {
+	// posts and mode are shared with the handler; posts is counted before the connection is
+	// dropped, so the count is up to date when the client returns
+	var mu sync.Mutex
+	var posts int
+	var mode string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		posts++
+		n, m := posts, mode
+		mu.Unlock()
+		switch m {
+		case "drop":
+			if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+				conn.Close()
+			}
+			return
+		case "reject":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message": "rejected"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"id": "<%d@example.com>", "message": "Queued. Thank you."}`, n)
+	}))
+	defer srv.Close()
+	setMode := func(m string) {
+		mu.Lock()
+		defer mu.Unlock()
+		mode = m
+	}
+	getPosts := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return posts
+	}
+
+	clock := newFakeClock()
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.clk = clock
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetSendRetries(3)
+	ctx := context.Background()
+
+	send := func(key, text string) (string, error) {
+		m := mg.NewMessage(fromUser, exampleSubject, text, "test@example.com")
+		m.AddHeader("X-One", "1")
+		m.AddHeader("X-Two", "2")
+		m.SetIdempotencyKey(key)
+		_, id, err := mg.Send(ctx, m)
+		return id, err
+	}
+
+	// A message sent twice is submitted once
+	id, err := send("order-1", exampleText)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, id, "<1@example.com>")
+	id, err = send("order-1", exampleText)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, id, "<1@example.com>")
+	ensure.DeepEqual(t, getPosts(), 1)
+
+	_, err = send("order-1", "A different message")
+	ensure.DeepEqual(t, err, ErrIdempotencyKeyReused)
+
+	// A rejected message may be sent again
+	setMode("reject")
+	_, err = send("order-2", exampleText)
+	ensure.DeepEqual(t, GetStatusFromErr(err), http.StatusBadRequest)
+	setMode("")
+	id, err = send("order-2", exampleText)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, id, "<3@example.com>")
+
+	// A dropped connection is not retried, and suppresses later sends until the key is forgotten
+	setMode("drop")
+	_, err = send("order-3", exampleText)
+	ensure.NotNil(t, err)
+	ensure.DeepEqual(t, getPosts(), 4)
+	setMode("")
+	_, err = send("order-3", exampleText)
+	ensure.DeepEqual(t, err, ErrSendOutcomeUnknown)
+	ensure.DeepEqual(t, getPosts(), 4)
+
+	ensure.Nil(t, mg.ForgetIdempotencyKey(ctx, "order-3"))
+	id, err = send("order-3", exampleText)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, id, "<5@example.com>")
+
+	// Expired keys are forgotten, by the clock of the client
+	clock.Advance(DefaultIdempotencyWindow)
+	_, err = send("order-1", "A different message")
+	ensure.DeepEqual(t, err, ErrIdempotencyKeyReused)
+	clock.Advance(time.Second)
+	id, err = send("order-1", exampleText)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, id, "<6@example.com>")
+}
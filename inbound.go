@@ -0,0 +1,122 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Outcomes of the steps of provisioning helpers such as `EnsureInboundForwarding()`.
+const (
+	// ProvisionCreated means the resource did not exist and was created.
+	ProvisionCreated = "created"
+	// ProvisionUpdated means the resource existed, and was changed to match.
+	ProvisionUpdated = "updated"
+	// ProvisionExisting means the resource already existed as required.
+	ProvisionExisting = "existing"
+)
+
+// inboundRoutePriority is the priority of catch-all routes created by EnsureInboundForwarding(),
+// low enough that more specific routes are consulted first.
+const inboundRoutePriority = 100
+
+// InboundForwarding reports the configuration `EnsureInboundForwarding()` found or made for a domain.
+type InboundForwarding struct {
+	// MXValid reports whether Mailgun has verified all MX records of the domain. Until it has,
+	// no mail is received; install MXRecords with your DNS provider.
+	MXValid   bool
+	MXRecords []DNSRecord
+	// Route is the catch-all route storing mail sent to the domain and notifying the target URL.
+	Route Route
+	// RouteStatus is ProvisionCreated, ProvisionUpdated or ProvisionExisting.
+	RouteStatus string
+}
+
+// EnsureInboundForwarding configures Mailgun to receive all mail sent to the domain, store it, and
+// notify targetURL of each message with a POST, as an inbound route with store(notify) would. It is
+// idempotent: the catch-all route for the domain is created if missing, and its actions updated if
+// they differ, so it can be called on every deploy. The MX records of the domain are checked but,
+// as Mailgun does not host DNS, not changed.
+//
+//  f, err := mg.EnsureInboundForwarding(ctx, "inbound.example.com", "https://example.com/inbound")
+//  if err == nil && !f.MXValid {
+//    log.Printf("install MX records: %v", f.MXRecords)
+//  }
+func (mg *MailgunImpl) EnsureInboundForwarding(ctx context.Context, domain, targetURL string) (InboundForwarding, error) {
+	var result InboundForwarding
+	if u, err := url.Parse(targetURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return result, fmt.Errorf("target URL '%s' must be an absolute http or https URL", targetURL)
+	}
+
+	d, err := mg.GetDomain(ctx, domain)
+	if err != nil {
+		return result, err
+	}
+	result.MXValid = true
+	for _, record := range d.ReceivingDNSRecords {
+		if strings.EqualFold(record.RecordType, "MX") {
+			result.MXRecords = append(result.MXRecords, record)
+			if record.Valid != "valid" {
+				result.MXValid = false
+			}
+		}
+	}
+	if len(result.MXRecords) == 0 {
+		result.MXValid = false
+	}
+
+	expression := `match_recipient(".*@` + regexp.QuoteMeta(domain) + `")`
+	actions := []string{fmt.Sprintf("store(notify=%q)", targetURL), "stop()"}
+	description := "Inbound forwarding for " + domain
+
+	routes, err := mg.ListRoutesAll(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	for _, route := range routes {
+		if compactRouteExpression(route.Expression) != compactRouteExpression(expression) {
+			continue
+		}
+		if equalStrings(route.Actions, actions) {
+			result.Route, result.RouteStatus = route, ProvisionExisting
+			return result, nil
+		}
+		updated, err := mg.UpdateRoute(ctx, route.Id, Route{Actions: actions})
+		if err != nil {
+			return result, err
+		}
+		result.Route, result.RouteStatus = updated, ProvisionUpdated
+		return result, nil
+	}
+
+	created, err := mg.CreateRoute(ctx, Route{
+		Priority:    inboundRoutePriority,
+		Description: description,
+		Expression:  expression,
+		Actions:     actions,
+	})
+	if err != nil {
+		return result, err
+	}
+	result.Route, result.RouteStatus = created, ProvisionCreated
+	return result, nil
+}
+
+// compactRouteExpression returns the expression without whitespace and in lower case, for comparison.
+func compactRouteExpression(expression string) string {
+	return strings.ToLower(strings.Join(strings.Fields(expression), ""))
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package mailgun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestEnsureInboundForwarding(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	f, err := mg.EnsureInboundForwarding(ctx, testDomain, "https://example.com/inbound")
+	ensure.Nil(t, err)
+	defer mg.DeleteRoute(ctx, f.Route.Id)
+	ensure.True(t, f.MXValid)
+	ensure.DeepEqual(t, len(f.MXRecords), 2)
+	ensure.DeepEqual(t, f.RouteStatus, mailgun.ProvisionCreated)
+	ensure.DeepEqual(t, f.Route.Expression, `match_recipient(".*@mailgun\.test")`)
+	ensure.DeepEqual(t, f.Route.Actions, []string{`store(notify="https://example.com/inbound")`, "stop()"})
+
+	again, err := mg.EnsureInboundForwarding(ctx, testDomain, "https://example.com/inbound")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, again.RouteStatus, mailgun.ProvisionExisting)
+	ensure.DeepEqual(t, again.Route.Id, f.Route.Id)
+
+	moved, err := mg.EnsureInboundForwarding(ctx, testDomain, "https://example.com/v2/inbound")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, moved.RouteStatus, mailgun.ProvisionUpdated)
+	ensure.DeepEqual(t, moved.Route.Id, f.Route.Id)
+	ensure.DeepEqual(t, moved.Route.Actions[0], `store(notify="https://example.com/v2/inbound")`)
+
+	_, err = mg.EnsureInboundForwarding(ctx, testDomain, "/inbound")
+	ensure.NotNil(t, err)
+	_, err = mg.EnsureInboundForwarding(ctx, "unknown.example.com", "https://example.com/inbound")
+	ensure.NotNil(t, err)
+}
@@ -0,0 +1,105 @@
+package mailgun
+
+import (
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// imgSource matches the src attribute of an <img> tag, capturing everything before the value.
+	imgSource = regexp.MustCompile(`(?i)(<img\b[^>]*?\bsrc\s*=\s*)("[^"]*"|'[^']*')`)
+	// unsafeContentID matches the characters which are not kept in a generated Content-ID.
+	unsafeContentID = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// AddInlineImage arranges to send an image inline with the message, as `AddReaderInline()` does,
+// and returns the Content-ID the HTML body can reference it by as cid:<id>. Mailgun uses the
+// filename of an inline as its Content-ID, so the filename is cleaned up, and made unique among
+// the inline images of the message.
+//
+//  cid := m.AddInlineImage(logo, "logo.png")
+//  m.SetHtml(`<img src="cid:` + cid + `">`)
+//
+// If the reader is an io.ReadCloser it is closed once sent. See `SetInlineImageRewrite()` to
+// reference the images by filename instead.
+func (m *Message) AddInlineImage(r io.Reader, filename string) string {
+	rc, ok := r.(io.ReadCloser)
+	if !ok {
+		rc = nopCloser{r}
+	}
+
+	cid := m.inlineImageID(filename)
+	if m.inlineImages == nil {
+		m.inlineImages = make(map[string]string)
+	}
+	if _, ok := m.inlineImages[filename]; !ok {
+		m.inlineImages[filename] = cid
+	}
+	m.AddReaderInline(cid, rc)
+	return cid
+}
+
+// SetInlineImageRewrite enables or disables rewriting the HTML body when the message is sent,
+// so that <img src="logo.png"> refers to the image added by `AddInlineImage(r, "logo.png")`
+// as cid:<id>. Sources which are not the filename of an inline image are left as they are.
+// Rewriting is disabled by default.
+func (m *Message) SetInlineImageRewrite(enabled bool) {
+	m.rewriteInlineImages = enabled
+}
+
+// inlineImageID returns a Content-ID for the filename which no other inline of the message uses.
+func (m *Message) inlineImageID(filename string) string {
+	base := strings.Trim(unsafeContentID.ReplaceAllString(path.Base(filename), "_"), "._")
+	if base == "" {
+		base = "image"
+	}
+
+	ext := path.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	cid := base
+	for n := 2; m.hasInline(cid); n++ {
+		cid = name + "-" + strconv.Itoa(n) + ext
+	}
+	return cid
+}
+
+// hasInline reports whether the message has an inline with the given filename.
+func (m *Message) hasInline(filename string) bool {
+	for _, inline := range m.inlines {
+		if path.Base(inline) == filename {
+			return true
+		}
+	}
+	for _, inline := range m.readerInlines {
+		if inline.Filename == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteImageSources replaces the sources of <img> tags which are filenames in images with
+// references to their Content-IDs.
+func rewriteImageSources(html string, images map[string]string) string {
+	return imgSource.ReplaceAllStringFunc(html, func(tag string) string {
+		parts := imgSource.FindStringSubmatch(tag)
+		quoted := parts[2]
+		src := quoted[1 : len(quoted)-1]
+		cid, ok := images[src]
+		if !ok {
+			return tag
+		}
+		return parts[1] + quoted[:1] + "cid:" + cid + quoted[:1]
+	})
+}
+
+// nopCloser is an io.ReadCloser for a reader which need not be closed. Unlike ioutil.NopCloser,
+// the reader is kept accessible, so its size can be estimated.
+type nopCloser struct {
+	io.Reader
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,80 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// IPAllowlistEntry is an IP address or CIDR range allowed to use the API keys of the account.
+// Once the allowlist holds an entry, requests from addresses outside of it are rejected.
+type IPAllowlistEntry struct {
+	Address     string `json:"ip_address"`
+	Description string `json:"description"`
+}
+
+type ipAllowlistResponse struct {
+	Addresses []IPAllowlistEntry `json:"addresses"`
+}
+
+// ListIPAllowlist returns the IP addresses and CIDR ranges allowed to use the API.
+func (mg *MailgunImpl) ListIPAllowlist(ctx context.Context) ([]IPAllowlistEntry, error) {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, ipAllowlistEndpoint))
+
+	var resp ipAllowlistResponse
+	if err := getResponseFromJSON(ctx, r, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Addresses, nil
+}
+
+// AddIPAllowlist allows an IP address such as 192.0.2.1, or a CIDR range such as 192.0.2.0/24, to use
+// the API. Take care to add the address of the caller before any other; adding the first entry
+// rejects requests from every address not on the allowlist.
+func (mg *MailgunImpl) AddIPAllowlist(ctx context.Context, entry IPAllowlistEntry) error {
+	if err := validateAllowlistAddress(entry.Address); err != nil {
+		return err
+	}
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, ipAllowlistEndpoint))
+
+	p := newPayload()
+	p.addValue("address", entry.Address)
+	p.addValue("description", entry.Description)
+	_, err := makePostRequest(ctx, r, p)
+	return err
+}
+
+// UpdateIPAllowlist changes the description of an address on the allowlist.
+func (mg *MailgunImpl) UpdateIPAllowlist(ctx context.Context, entry IPAllowlistEntry) error {
+	if err := validateAllowlistAddress(entry.Address); err != nil {
+		return err
+	}
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, ipAllowlistEndpoint))
+
+	p := newPayload()
+	p.addValue("address", entry.Address)
+	p.addValue("description", entry.Description)
+	_, err := makePutRequest(ctx, r, p)
+	return err
+}
+
+// DeleteIPAllowlist removes an address from the allowlist. Removing the last entry allows
+// requests from any address again.
+func (mg *MailgunImpl) DeleteIPAllowlist(ctx context.Context, address string) error {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, ipAllowlistEndpoint))
+	r.addParameter("address", address)
+	_, err := makeDeleteRequest(ctx, r)
+	return err
+}
+
+// validateAllowlistAddress checks the address is an IP address or CIDR range before it is
+// sent, as an invalid entry could lock the account out of the API.
+func validateAllowlistAddress(address string) error {
+	if net.ParseIP(address) != nil {
+		return nil
+	}
+	if _, _, err := net.ParseCIDR(address); err != nil {
+		return fmt.Errorf("'%s' is not an IP address or CIDR range", address)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package mailgun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestIPAllowlist(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	ensure.Nil(t, mg.AddIPAllowlist(ctx, mailgun.IPAllowlistEntry{Address: "192.0.2.0/24", Description: "office"}))
+	ensure.Nil(t, mg.AddIPAllowlist(ctx, mailgun.IPAllowlistEntry{Address: "198.51.100.7"}))
+	ensure.Nil(t, mg.UpdateIPAllowlist(ctx, mailgun.IPAllowlistEntry{Address: "198.51.100.7", Description: "ci"}))
+
+	entries, err := mg.ListIPAllowlist(ctx)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, entries, []mailgun.IPAllowlistEntry{
+		{Address: "192.0.2.0/24", Description: "office"},
+		{Address: "198.51.100.7", Description: "ci"},
+	})
+
+	ensure.Nil(t, mg.DeleteIPAllowlist(ctx, "192.0.2.0/24"))
+	entries, err = mg.ListIPAllowlist(ctx)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(entries), 1)
+
+	err = mg.DeleteIPAllowlist(ctx, "192.0.2.0/24")
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), 404)
+
+	// Invalid addresses are rejected before they reach the API
+	err = mg.AddIPAllowlist(ctx, mailgun.IPAllowlistEntry{Address: "192.0.2.0/33"})
+	ensure.NotNil(t, err)
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), -1)
+}
@@ -20,12 +20,10 @@ type okResp struct {
 
 // ListIPS returns a list of IPs assigned to your account
 func (mg *MailgunImpl) ListIPS(ctx context.Context, dedicated bool) ([]IPAddress, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, ipsEndpoint))
-	r.setClient(mg.Client())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, ipsEndpoint))
 	if dedicated {
 		r.addParameter("dedicated", "true")
 	}
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
 
 	var resp ipAddressListResponse
 	if err := getResponseFromJSON(ctx, r, &resp); err != nil {
@@ -40,9 +38,7 @@ func (mg *MailgunImpl) ListIPS(ctx context.Context, dedicated bool) ([]IPAddress
 
 // GetIP returns information about the specified IP
 func (mg *MailgunImpl) GetIP(ctx context.Context, ip string) (IPAddress, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, ipsEndpoint) + "/" + ip)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, ipsEndpoint)+"/"+pathEscape(ip))
 	var resp IPAddress
 	err := getResponseFromJSON(ctx, r, &resp)
 	return resp, err
@@ -50,9 +46,7 @@ func (mg *MailgunImpl) GetIP(ctx context.Context, ip string) (IPAddress, error)
 
 // ListDomainIPS returns a list of IPs currently assigned to the specified domain.
 func (mg *MailgunImpl) ListDomainIPS(ctx context.Context) ([]IPAddress, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + mg.domain + "/ips")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(mg.domain)+"/ips")
 
 	var resp ipAddressListResponse
 	if err := getResponseFromJSON(ctx, r, &resp); err != nil {
@@ -67,11 +61,9 @@ func (mg *MailgunImpl) ListDomainIPS(ctx context.Context) ([]IPAddress, error) {
 
 // Assign a dedicated IP to the domain specified.
 func (mg *MailgunImpl) AddDomainIP(ctx context.Context, ip string) error {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + mg.domain + "/ips")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(mg.domain)+"/ips")
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 	payload.addValue("ip", ip)
 	_, err := makePostRequest(ctx, r, payload)
 	return err
@@ -79,9 +71,7 @@ func (mg *MailgunImpl) AddDomainIP(ctx context.Context, ip string) error {
 
 // Unassign an IP from the domain specified.
 func (mg *MailgunImpl) DeleteDomainIP(ctx context.Context, ip string) error {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + mg.domain + "/ips/" + ip)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(mg.domain)+"/ips/"+pathEscape(ip))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
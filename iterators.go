@@ -0,0 +1,109 @@
+//go:build go1.23
+
+package mailgun
+
+import (
+	"context"
+	"iter"
+)
+
+// The methods in this file expose every List endpoint as a range-over-func iterator.
+// Each yields one item at a time, fetching pages from the api as needed. If fetching
+// a page fails, the error is yielded once with a zero item and iteration stops.
+//
+//  for list, err := range mg.MailingLists(ctx, nil) {
+//    if err != nil {
+//      log.Fatal(err)
+//    }
+//    fmt.Println(list.Address)
+//  }
+
+// MailingLists iterates over all mailing lists administered by your account.
+func (mg *MailgunImpl) MailingLists(ctx context.Context, opts *ListsOptions) iter.Seq2[MailingList, error] {
+	it := mg.ListMailingListsWithOptions(opts)
+	return seqPages(ctx, it.Next, it.Err)
+}
+
+// Members iterates over all members of the mailing list.
+func (mg *MailgunImpl) Members(ctx context.Context, address string, opts *ListOptions) iter.Seq2[Member, error] {
+	it := mg.ListMembers(address, opts)
+	return seqPages(ctx, it.Next, it.Err)
+}
+
+// Bounces iterates over all bounces logged against the domain.
+func (mg *MailgunImpl) Bounces(ctx context.Context, opts *ListOptions) iter.Seq2[Bounce, error] {
+	it := mg.ListBounces(opts)
+	return seqPages(ctx, it.Next, it.Err)
+}
+
+// Credentials iterates over all SMTP credentials of the domain.
+func (mg *MailgunImpl) Credentials(ctx context.Context, opts *ListOptions) iter.Seq2[Credential, error] {
+	it := mg.ListCredentials(opts)
+	return seqPages(ctx, it.Next, it.Err)
+}
+
+// Domains iterates over all domains of your account.
+func (mg *MailgunImpl) Domains(ctx context.Context, opts *ListDomainOptions) iter.Seq2[Domain, error] {
+	it := mg.ListDomainsWithOptions(opts)
+	return seqPages(ctx, it.Next, it.Err)
+}
+
+// Events iterates over the events of the domain.
+func (mg *MailgunImpl) Events(ctx context.Context, opts *ListEventOptions) iter.Seq2[Event, error] {
+	it := mg.ListEvents(opts)
+	return seqPages(ctx, it.Next, it.Err)
+}
+
+// Routes iterates over all routes configured for your account.
+func (mg *MailgunImpl) Routes(ctx context.Context, opts *ListOptions) iter.Seq2[Route, error] {
+	it := mg.ListRoutes(opts)
+	return seqPages(ctx, it.Next, it.Err)
+}
+
+// Complaints iterates over all spam complaints logged against the domain.
+func (mg *MailgunImpl) Complaints(ctx context.Context, opts *ListOptions) iter.Seq2[Complaint, error] {
+	it := mg.ListComplaints(opts)
+	return seqPages(ctx, it.Next, it.Err)
+}
+
+// Tags iterates over the tags of the domain.
+func (mg *MailgunImpl) Tags(ctx context.Context, opts *ListTagOptions) iter.Seq2[Tag, error] {
+	it := mg.ListTags(opts)
+	return seqPages(ctx, it.Next, it.Err)
+}
+
+// Templates iterates over the stored templates of the domain.
+func (mg *MailgunImpl) Templates(ctx context.Context, opts *ListTemplateOptions) iter.Seq2[Template, error] {
+	it := mg.ListTemplates(opts)
+	return seqPages(ctx, it.Next, it.Err)
+}
+
+// TemplateVersions iterates over the versions of the named template.
+func (mg *MailgunImpl) TemplateVersions(ctx context.Context, templateName string, opts *ListOptions) iter.Seq2[TemplateVersion, error] {
+	it := mg.ListTemplateVersions(templateName, opts)
+	return seqPages(ctx, it.Next, it.Err)
+}
+
+// Unsubscribes iterates over all unsubscribed recipients of the domain.
+func (mg *MailgunImpl) Unsubscribes(ctx context.Context, opts *ListOptions) iter.Seq2[Unsubscribe, error] {
+	it := mg.ListUnsubscribes(opts)
+	return seqPages(ctx, it.Next, it.Err)
+}
+
+// seqPages adapts the Next() and Err() methods of a page iterator into an iter.Seq2.
+func seqPages[T any](ctx context.Context, next func(context.Context, *[]T) bool, errFn func() error) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var page []T
+		for next(ctx, &page) {
+			for _, item := range page {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+		if err := errFn(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+//go:build go1.23
+
+package mailgun_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestRangeMailingLists(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	var count int
+	for list, err := range mg.MailingLists(ctx, &mailgun.ListsOptions{Limit: 1}) {
+		ensure.Nil(t, err)
+		ensure.True(t, list.Address != "")
+		count++
+	}
+	ensure.True(t, count > 0)
+}
+
+func TestRangeRoutesBreak(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+
+	var count int
+	for _, err := range mg.Routes(context.Background(), &mailgun.ListOptions{Limit: 2}) {
+		ensure.Nil(t, err)
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	ensure.DeepEqual(t, count, 3)
+}
+
+func TestRangeYieldsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	var errs int
+	for _, err := range mg.Members(context.Background(), "list@"+testDomain, nil) {
+		ensure.NotNil(t, err)
+		ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusInternalServerError)
+		errs++
+	}
+	ensure.DeepEqual(t, errs, 1)
+}
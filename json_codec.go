@@ -0,0 +1,74 @@
+package mailgun
+
+import (
+	"encoding/json"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// JSONCodec encodes and decodes JSON for a client. The configurations of json-iterator, such as
+// jsoniter.ConfigFastest, implement it as they are, and other libraries are adapted with a few lines.
+//
+//  type segmentioCodec struct{}
+//
+//  func (segmentioCodec) Marshal(v interface{}) ([]byte, error)      { return segjson.Marshal(v) }
+//  func (segmentioCodec) Unmarshal(data []byte, v interface{}) error { return segjson.Unmarshal(data, v) }
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// SetJSONCodec sets the codec the client uses to decode responses and events, and to encode the
+// JSON values of requests, such as member vars. Pass nil to restore the default, which decodes
+// responses with encoding/json as they are read, and events with json-iterator; accepted,
+// delivered, failed, opened and clicked events, the bulk of most event streams, are decoded with
+// decoders written for them rather than reflection. A codec set here decodes each response once
+// it has been read in full, and every event with reflection.
+//
+//  mg.SetJSONCodec(jsoniter.ConfigFastest)
+func (mg *MailgunImpl) SetJSONCodec(codec JSONCodec) {
+	mg.mu.Lock()
+	mg.codec = codec
+	mg.mu.Unlock()
+}
+
+// codecFor returns the codec set on the client, or nil if it uses the default.
+func codecFor(c interface{}) JSONCodec {
+	if o, ok := c.(interface{ requestOptions() requestOptions }); ok {
+		return o.requestOptions().codec
+	}
+	return nil
+}
+
+// marshalJSON encodes v with the codec of the request's client.
+func (r *httpRequest) marshalJSON(v interface{}) ([]byte, error) {
+	if r.options.codec != nil {
+		return r.options.codec.Marshal(v)
+	}
+	return json.Marshal(v)
+}
+
+// unmarshalEvent decodes an event with codec, or json-iterator if it is nil.
+func unmarshalEvent(codec JSONCodec, raw []byte, v interface{}) error {
+	if codec != nil {
+		return codec.Unmarshal(raw, v)
+	}
+	return jsoniter.Unmarshal(raw, v)
+}
+
+// eventName returns the name of the event in raw. It scans the event only as far as its "event"
+// field, skipping the values before it, rather than decoding the whole event to recognize it.
+func eventName(raw []byte) (string, error) {
+	iter := jsoniter.ConfigFastest.BorrowIterator(raw)
+	defer jsoniter.ConfigFastest.ReturnIterator(iter)
+
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		if field == "event" {
+			name := iter.ReadString()
+			return strings.ToLower(name), iter.Error
+		}
+		iter.Skip()
+	}
+	return "", iter.Error
+}
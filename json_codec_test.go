@@ -0,0 +1,61 @@
+package mailgun_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+type countingCodec struct {
+	marshals, unmarshals int
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	c.marshals++
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	c.unmarshals++
+	return json.Unmarshal(data, v)
+}
+
+func TestSetJSONCodec(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	codec := &countingCodec{}
+	mg.SetJSONCodec(codec)
+	ctx := context.Background()
+
+	it := mg.ListEvents(&mailgun.ListEventOptions{Limit: 5})
+	var page []mailgun.Event
+	ensure.True(t, it.Next(ctx, &page))
+	ensure.Nil(t, it.Err())
+	ensure.DeepEqual(t, len(page), 5)
+	// The page, and then each event on it
+	ensure.DeepEqual(t, codec.unmarshals, 6)
+
+	address := randomEmail("codec", testDomain)
+	_, err := mg.CreateMailingList(ctx, mailgun.MailingList{Address: address, Name: address})
+	ensure.Nil(t, err)
+	defer mg.DeleteMailingList(ctx, address)
+
+	ensure.Nil(t, mg.CreateMember(ctx, true, address, mailgun.Member{
+		Address: "joe@example.com",
+		Vars:    map[string]interface{}{"plan": "pro"},
+	}))
+	ensure.DeepEqual(t, codec.marshals, 1)
+
+	member, err := mg.GetMember(ctx, "joe@example.com", address)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, member.Vars["plan"], "pro")
+
+	mg.SetJSONCodec(nil)
+	before := codec.unmarshals
+	_, err = mg.GetMember(ctx, "joe@example.com", address)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, codec.unmarshals, before)
+}
@@ -2,6 +2,80 @@ package mailgun
 
 import "context"
 
+// The limits Mailgun documents for its API. Requests which break them are rejected by Mailgun; the
+// client checks those it can before sending, so they fail early with a clear error.
+
+// MaxNumberOfRecipients represents the largest batch of recipients that Mailgun can support in a single API call.
+// This figure includes To:, Cc:, Bcc:, etc. recipients.
+const MaxNumberOfRecipients = 1000
+
+// MaxNumberOfTags represents the maximum number of tags that can be added for a message
+const MaxNumberOfTags = 3
+
+// MaxTagLength is the longest tag, in characters, Mailgun accepts.
+const MaxTagLength = 128
+
+// MaxMessageSize represents the largest message, including attachments, that Mailgun will accept.
+const MaxMessageSize = 25 * 1024 * 1024
+
+// MaxVariablesSize is the largest total size, in bytes, of the names and values of the variables
+// added to a message with `AddVariable()` that Mailgun will accept.
+const MaxVariablesSize = 4 * 1024
+
+// MaxTemplateSize is the largest content, in bytes, of a version of a stored template.
+const MaxTemplateSize = 100 * 1024
+
+// MaxTemplateVersions is the most versions a stored template may have.
+const MaxTemplateVersions = 40
+
+// MaxMembersPerRequest is the largest number of members `CreateMemberList()` sends to Mailgun in
+// one request; larger lists are split.
+const MaxMembersPerRequest = 1000
+
+// MaxMemberListSize is the largest body, in bytes, `CreateMemberList()` sends to Mailgun in one
+// request; lists of larger members are split into more requests.
+const MaxMemberListSize = 8 << 20
+
+// MaxPageSize is the largest page most list endpoints return, and MaxEventsPageSize the largest
+// page of events.
+const (
+	MaxPageSize       = 1000
+	MaxEventsPageSize = 300
+)
+
+// Limits holds the limits Mailgun documents, so applications can check their own input, such as
+// a form building a campaign, against the values the client checks.
+type Limits struct {
+	MaxRecipients        int
+	MaxMessageSize       int
+	MaxTags              int
+	MaxTagLength         int
+	MaxVariablesSize     int
+	MaxTemplateSize      int
+	MaxTemplateVersions  int
+	MaxMembersPerRequest int
+	MaxMemberListSize    int
+	MaxPageSize          int
+	MaxEventsPageSize    int
+}
+
+// DefaultLimits returns the limits Mailgun documents, as held by the Max constants.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxRecipients:        MaxNumberOfRecipients,
+		MaxMessageSize:       MaxMessageSize,
+		MaxTags:              MaxNumberOfTags,
+		MaxTagLength:         MaxTagLength,
+		MaxVariablesSize:     MaxVariablesSize,
+		MaxTemplateSize:      MaxTemplateSize,
+		MaxTemplateVersions:  MaxTemplateVersions,
+		MaxMembersPerRequest: MaxMembersPerRequest,
+		MaxMemberListSize:    MaxMemberListSize,
+		MaxPageSize:          MaxPageSize,
+		MaxEventsPageSize:    MaxEventsPageSize,
+	}
+}
+
 type TagLimits struct {
 	Limit int `json:"limit"`
 	Count int `json:"count"`
@@ -9,9 +83,7 @@ type TagLimits struct {
 
 // GetTagLimits returns tracking settings for a domain
 func (mg *MailgunImpl) GetTagLimits(ctx context.Context, domain string) (TagLimits, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + domain + "/limits/tag")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, domainsEndpoint)+"/"+pathEscape(domain)+"/limits/tag")
 	var resp TagLimits
 	err := getResponseFromJSON(ctx, r, &resp)
 	return resp, err
@@ -1,10 +1,19 @@
 package mailgun_test
 
 import (
+	"compress/gzip"
 	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/facebookgo/ensure"
+	"github.com/pkg/errors"
 	"github.com/yjimk/mailgun-go/v4"
 )
 
@@ -19,3 +28,97 @@ func TestLimits(t *testing.T) {
 	ensure.DeepEqual(t, limits.Limit, 50000)
 	ensure.DeepEqual(t, limits.Count, 5000)
 }
+
+func TestGzipResponses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := `{"limit": 50000, "count": 5000}`
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			ensure.DeepEqual(t, r.Header.Get("Accept-Encoding"), "identity")
+			fmt.Fprint(w, body)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		fmt.Fprint(gz, body)
+		gz.Close()
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	limits, err := mg.GetTagLimits(ctx, testDomain)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, limits.Limit, 50000)
+
+	mg.SetCompression(false)
+	limits, err = mg.GetTagLimits(ctx, testDomain)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, limits.Count, 5000)
+}
+
+func TestDefaultLimits(t *testing.T) {
+	limits := mailgun.DefaultLimits()
+	ensure.DeepEqual(t, limits.MaxRecipients, mailgun.MaxNumberOfRecipients)
+	ensure.DeepEqual(t, limits.MaxMessageSize, mailgun.MaxMessageSize)
+	ensure.DeepEqual(t, limits.MaxTags, mailgun.MaxNumberOfTags)
+	ensure.DeepEqual(t, limits.MaxTemplateSize, mailgun.MaxTemplateSize)
+	ensure.DeepEqual(t, limits.MaxMembersPerRequest, mailgun.MaxMembersPerRequest)
+	ensure.DeepEqual(t, limits.MaxMemberListSize, mailgun.MaxMemberListSize)
+	ensure.DeepEqual(t, limits.MaxEventsPageSize, mailgun.MaxEventsPageSize)
+}
+
+func TestTemplateSizeLimit(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"template": {"name": "big"}}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	big := strings.Repeat("x", mailgun.MaxTemplateSize+1)
+	err := mg.CreateTemplate(ctx, &mailgun.Template{Name: "big", Version: mailgun.TemplateVersion{Template: big}})
+	ensure.StringContains(t, err.Error(), "over the limit")
+	validation, ok := err.(*mailgun.ValidationError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, validation.Field, "template")
+	err = mg.AddTemplateVersion(ctx, "big", &mailgun.TemplateVersion{Template: big})
+	ensure.StringContains(t, err.Error(), "over the limit")
+	err = mg.UpdateTemplateVersion(ctx, "big", &mailgun.TemplateVersion{Tag: "v1", Template: big})
+	ensure.StringContains(t, err.Error(), "over the limit")
+	ensure.DeepEqual(t, requests, 0)
+
+	fits := strings.Repeat("x", mailgun.MaxTemplateSize)
+	ensure.Nil(t, mg.CreateTemplate(ctx, &mailgun.Template{Name: "big", Version: mailgun.TemplateVersion{Template: fits}}))
+	ensure.DeepEqual(t, requests, 1)
+}
+
+func TestTemplateVersionsLimit(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "templates")
+	ensure.Nil(t, err)
+	defer os.RemoveAll(dir)
+	for i := 0; i <= mailgun.MaxTemplateVersions; i++ {
+		content := fmt.Sprintf("---\nname: welcome\ntag: v%d\n---\n<p>Hi</p>\n", i)
+		ensure.Nil(t, ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("welcome-v%d.html", i)), []byte(content), 0644))
+	}
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	_, err = mg.SyncTemplates(context.Background(), dir, nil)
+	validation, ok := errors.Cause(err).(*mailgun.ValidationError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, validation.Field, "versions")
+	ensure.DeepEqual(t, requests, 0)
+}
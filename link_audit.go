@@ -0,0 +1,167 @@
+package mailgun
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultNoTrackAttribute is the attribute `AuditLinks()` takes as marking links which must not be
+// rewritten for click tracking, unless LinkAuditOptions names another.
+const DefaultNoTrackAttribute = "data-notrack"
+
+var (
+	htmlAnchor    = regexp.MustCompile(`(?is)<a\b([^>]*)>(.*?)</a\s*>`)
+	htmlAttribute = regexp.MustCompile(`([^\s"'<>/=]+)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+)
+
+// signedURLParameters are query parameters which suggest a URL is signed, or carries a one-time token.
+var signedURLParameters = []string{"signature", "sig", "x-amz-signature", "x-goog-signature", "hmac", "token"}
+
+// LinkAuditOptions configures `AuditLinks()`.
+type LinkAuditOptions struct {
+	// NoTrackAttribute is the attribute which marks links that must not be rewritten, such as
+	// deep links into an app; defaults to DefaultNoTrackAttribute.
+	NoTrackAttribute string
+	// ClickTrackingDisabled is set when click tracking is disabled for the message, so no link is rewritten.
+	ClickTrackingDisabled bool
+}
+
+// LinkAudit describes a link of an HTML body, and what click tracking does to it.
+type LinkAudit struct {
+	// URL is the href of the link, with entities decoded.
+	URL string
+	// Text is the text of the link, with tags removed.
+	Text string
+	// Rewritten reports whether Mailgun rewrites the link to go through its click tracking
+	// redirect. Mailgun rewrites every http and https link of the HTML body when click
+	// tracking is enabled.
+	Rewritten bool
+	// NoTrack reports whether the link carries the no-track attribute.
+	NoTrack bool
+	// Warnings describe what may go wrong with the link.
+	Warnings []string
+}
+
+// AuditLinks reports the links of an HTML body, and which of them Mailgun rewrites for click
+// tracking. Rewritten links reach their destination through a redirect, which breaks app deep
+// links, URLs whose signature covers the referrer, and links a user is meant to inspect before
+// clicking. Links which are not http or https are not tracked at all. Mailgun cannot leave some
+// links of a message untracked, so a link marked with the no-track attribute which would be
+// rewritten is warned about; send such messages with click tracking disabled.
+//
+//  for _, link := range mailgun.AuditLinks(body, nil) {
+//    for _, w := range link.Warnings {
+//      log.Printf("%s: %s", link.URL, w)
+//    }
+//  }
+func AuditLinks(body string, opts *LinkAuditOptions) []LinkAudit {
+	noTrack := DefaultNoTrackAttribute
+	tracking := true
+	if opts != nil {
+		if opts.NoTrackAttribute != "" {
+			noTrack = opts.NoTrackAttribute
+		}
+		tracking = !opts.ClickTrackingDisabled
+	}
+
+	var links []LinkAudit
+	body = htmlHidden.ReplaceAllString(body, "")
+	for _, match := range htmlAnchor.FindAllStringSubmatch(body, -1) {
+		attrs := parseAttributes(match[1])
+		href, ok := attrs["href"]
+		if !ok {
+			continue
+		}
+		_, marked := attrs[strings.ToLower(noTrack)]
+		link := LinkAudit{
+			URL:     strings.TrimSpace(href),
+			Text:    strings.TrimSpace(htmlSpace.ReplaceAllString(html.UnescapeString(htmlTag.ReplaceAllString(match[2], "")), " ")),
+			NoTrack: marked,
+		}
+		auditLink(&link, tracking)
+		links = append(links, link)
+	}
+	return links
+}
+
+// AuditLinks reports the links of the HTML body of the message, as `AuditLinks()` does, taking
+// into account whether the message disables click tracking. MIME messages and messages sent
+// with a template have no HTML body to audit.
+func (m *Message) AuditLinks(opts *LinkAuditOptions) []LinkAudit {
+	pm, ok := m.specific.(*plainMessage)
+	if !ok {
+		return nil
+	}
+	var o LinkAuditOptions
+	if opts != nil {
+		o = *opts
+	}
+	if (m.trackingSet && !m.tracking) || (m.trackingClicksSet && !m.trackingClicks) {
+		o.ClickTrackingDisabled = true
+	}
+	return AuditLinks(pm.html, &o)
+}
+
+func auditLink(link *LinkAudit, tracking bool) {
+	switch {
+	case link.URL == "" || strings.HasPrefix(link.URL, "#"):
+		return
+	case strings.HasPrefix(link.URL, "%") || strings.HasPrefix(link.URL, "{{"):
+		link.Warnings = append(link.Warnings, "the link is a template variable; check the URL it is replaced with")
+		return
+	}
+
+	u, err := url.Parse(link.URL)
+	if err != nil {
+		link.Warnings = append(link.Warnings, "the URL is not valid: "+err.Error())
+		return
+	}
+	scheme := strings.ToLower(u.Scheme)
+	switch scheme {
+	case "http", "https":
+	case "mailto", "tel", "sms":
+		return
+	case "":
+		link.Warnings = append(link.Warnings, "the URL is relative, and has no meaning in an email")
+		return
+	default:
+		link.Warnings = append(link.Warnings, "not an http link, so clicks on it are not tracked")
+		return
+	}
+
+	link.Rewritten = tracking
+	if !link.Rewritten {
+		return
+	}
+	if link.NoTrack {
+		link.Warnings = append(link.Warnings, "marked not to be tracked, but Mailgun rewrites every link when click tracking is enabled")
+	}
+	for name := range u.Query() {
+		if containsFold(signedURLParameters, name) {
+			link.Warnings = append(link.Warnings, "the URL looks signed ('"+name+"'); check it still works through the tracking redirect")
+			break
+		}
+	}
+	if text := strings.ToLower(link.Text); strings.HasPrefix(text, "http://") || strings.HasPrefix(text, "https://") || strings.HasPrefix(text, "www.") {
+		link.Warnings = append(link.Warnings, "the text is a URL, which will not match the tracking URL the link goes to")
+	}
+}
+
+// parseAttributes returns the attributes of a tag, keyed by lower case name, with entities decoded.
+func parseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range htmlAttribute.FindAllStringSubmatch(s, -1) {
+		name := strings.ToLower(m[1])
+		if _, ok := attrs[name]; ok {
+			continue
+		}
+		value := m[2]
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+			value = value[1 : len(value)-1]
+		}
+		attrs[name] = html.UnescapeString(value)
+	}
+	return attrs
+}
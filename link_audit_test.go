@@ -0,0 +1,70 @@
+package mailgun_test
+
+import (
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestAuditLinks(t *testing.T) {
+	body := `<html><head><link href="https://example.com/style.css"></head><body>
+		<p><a href="https://example.com/welcome?utm_source=email">Get <b>started</b></a></p>
+		<!-- <a href="https://example.com/hidden">hidden</a> -->
+		<a HREF='https://app.example.com/open' data-notrack>Open the app</a>
+		<a href="myapp://inbox">Open inbox</a>
+		<a href="mailto:help@example.com">Email us</a>
+		<a href="https://files.example.com/report.pdf?X-Amz-Signature=abc&amp;X-Amz-Expires=60">Report</a>
+		<a href="https://example.com/a">https://example.com/a</a>
+		<a href="/relative">Relative</a>
+		<a href="%recipient.unsubscribe_url%">Unsubscribe</a>
+		<a name="top">Top</a>
+	</body></html>`
+
+	links := mailgun.AuditLinks(body, nil)
+	ensure.DeepEqual(t, len(links), 8)
+
+	ensure.DeepEqual(t, links[0].URL, "https://example.com/welcome?utm_source=email")
+	ensure.DeepEqual(t, links[0].Text, "Get started")
+	ensure.True(t, links[0].Rewritten)
+	ensure.DeepEqual(t, len(links[0].Warnings), 0)
+
+	ensure.True(t, links[1].Rewritten)
+	ensure.True(t, links[1].NoTrack)
+	ensure.DeepEqual(t, len(links[1].Warnings), 1)
+
+	ensure.False(t, links[2].Rewritten)
+	ensure.DeepEqual(t, links[2].Warnings, []string{"not an http link, so clicks on it are not tracked"})
+
+	ensure.False(t, links[3].Rewritten)
+	ensure.DeepEqual(t, len(links[3].Warnings), 0)
+
+	ensure.DeepEqual(t, links[4].URL, "https://files.example.com/report.pdf?X-Amz-Signature=abc&X-Amz-Expires=60")
+	ensure.DeepEqual(t, links[4].Warnings, []string{"the URL looks signed ('X-Amz-Signature'); check it still works through the tracking redirect"})
+
+	ensure.DeepEqual(t, len(links[5].Warnings), 1)
+	ensure.DeepEqual(t, len(links[6].Warnings), 1)
+	ensure.False(t, links[7].Rewritten)
+	ensure.DeepEqual(t, len(links[7].Warnings), 1)
+
+	// No links are rewritten without click tracking
+	links = mailgun.AuditLinks(body, &mailgun.LinkAuditOptions{ClickTrackingDisabled: true})
+	ensure.False(t, links[0].Rewritten)
+	ensure.DeepEqual(t, len(links[1].Warnings), 0)
+
+	links = mailgun.AuditLinks(`<a href="https://example.com" data-keep>x</a>`, &mailgun.LinkAuditOptions{NoTrackAttribute: "data-keep"})
+	ensure.True(t, links[0].NoTrack)
+}
+
+func TestMessageAuditLinks(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	m := mg.NewMessage("root@"+testDomain, "Subject", "Text", "joe@example.com")
+	m.SetHtml(`<a href="https://example.com">Example</a>`)
+
+	links := m.AuditLinks(nil)
+	ensure.DeepEqual(t, len(links), 1)
+	ensure.True(t, links[0].Rewritten)
+
+	m.SetTrackingClicks(false)
+	ensure.False(t, m.AuditLinks(nil)[0].Rewritten)
+}
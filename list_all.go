@@ -0,0 +1,114 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	// listAllInterval is the default minimum delay between requests issued by the bulk helpers.
+	listAllInterval = 100 * time.Millisecond
+	// listAllMaxRetries is the number of times a page is retried after a 429 Too Many Requests response.
+	listAllMaxRetries = 5
+)
+
+// ListMailingListsAll walks every page of ListMailingListsWithOptions() and returns the complete
+// set of mailing lists administered by your account.
+func (mg *MailgunImpl) ListMailingListsAll(ctx context.Context, opts *ListsOptions) ([]MailingList, error) {
+	var result, page []MailingList
+	it := mg.ListMailingListsWithOptions(opts)
+	err := walkPages(ctx, mg.bulkLimiter(), func(ctx context.Context) bool {
+		if !it.Next(ctx, &page) {
+			return false
+		}
+		result = append(result, page...)
+		return true
+	}, &it.err)
+	return result, err
+}
+
+// ListMembersAll walks every page of ListMembers() and returns all members of the mailing list.
+func (mg *MailgunImpl) ListMembersAll(ctx context.Context, address string, opts *ListOptions) ([]Member, error) {
+	var result, page []Member
+	it := mg.ListMembers(address, opts)
+	err := walkPages(ctx, mg.bulkLimiter(), func(ctx context.Context) bool {
+		if !it.Next(ctx, &page) {
+			return false
+		}
+		result = append(result, page...)
+		return true
+	}, &it.err)
+	return result, err
+}
+
+// ListBouncesAll walks every page of ListBounces() and returns all bounces logged against the domain.
+func (mg *MailgunImpl) ListBouncesAll(ctx context.Context, opts *ListOptions) ([]Bounce, error) {
+	var result, page []Bounce
+	it := mg.ListBounces(opts)
+	err := walkPages(ctx, mg.bulkLimiter(), func(ctx context.Context) bool {
+		if !it.Next(ctx, &page) {
+			return false
+		}
+		result = append(result, page...)
+		return true
+	}, &it.err)
+	return result, err
+}
+
+// ListRoutesAll walks every page of ListRoutes() and returns all routes configured for your account.
+func (mg *MailgunImpl) ListRoutesAll(ctx context.Context, opts *ListOptions) ([]Route, error) {
+	var result, page []Route
+	it := mg.ListRoutes(opts)
+	err := walkPages(ctx, mg.bulkLimiter(), func(ctx context.Context) bool {
+		if !it.Next(ctx, &page) {
+			return false
+		}
+		result = append(result, page...)
+		return true
+	}, &it.err)
+	return result, err
+}
+
+// walkPages calls next until it reports there are no more pages. Requests are paced by the bulk
+// limiter l, and a page rejected with 429 Too Many Requests is retried once the limiter has slowed
+// down. errp points at the iterator error, which is cleared before each retry.
+func walkPages(ctx context.Context, l *bulkLimiter, next func(context.Context) bool, errp *error) error {
+	var retries int
+	for {
+		if err := l.acquire(ctx); err != nil {
+			return err
+		}
+		more := next(ctx)
+		l.release(*errp)
+
+		if more {
+			retries = 0
+			continue
+		}
+		if *errp == nil {
+			return nil
+		}
+		if GetStatusFromErr(*errp) != http.StatusTooManyRequests || retries >= listAllMaxRetries {
+			return *errp
+		}
+
+		retries++
+		*errp = nil
+	}
+}
+
+// sleepContext pauses for the duration d or until the context is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestListMailingListsAll(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	address := randomEmail("list", testDomain)
+	_, err := mg.CreateMailingList(ctx, mailgun.MailingList{
+		Address:     address,
+		Name:        address,
+		AccessLevel: mailgun.AccessLevelMembers,
+	})
+	ensure.Nil(t, err)
+	defer func() {
+		ensure.Nil(t, mg.DeleteMailingList(ctx, address))
+	}()
+
+	for i := 0; i < 5; i++ {
+		ensure.Nil(t, mg.CreateMember(ctx, true, address, mailgun.Member{
+			Address: fmt.Sprintf("member%d@example.com", i),
+		}))
+	}
+
+	lists, err := mg.ListMailingListsAll(ctx, &mailgun.ListsOptions{Limit: 1})
+	ensure.Nil(t, err)
+	var found bool
+	for _, l := range lists {
+		if l.Address == address {
+			found = true
+		}
+	}
+	ensure.True(t, found)
+
+	members, err := mg.ListMembersAll(ctx, address, &mailgun.ListOptions{Limit: 2})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(members), 5)
+}
+
+func TestListRoutesAll(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+
+	routes, err := mg.ListRoutesAll(context.Background(), &mailgun.ListOptions{Limit: 3})
+	ensure.Nil(t, err)
+
+	var count int
+	it := mg.ListRoutes(nil)
+	var page []mailgun.Route
+	for it.Next(context.Background(), &page) {
+		count += len(page)
+	}
+	ensure.Nil(t, it.Err())
+	ensure.DeepEqual(t, len(routes), count)
+}
+
+func TestListAllRetriesTooManyRequests(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"message":"slow down"}`)
+		case 2:
+			fmt.Fprint(w, `{"total_count": 1, "items": [{"id": "route-1"}]}`)
+		default:
+			fmt.Fprint(w, `{"total_count": 1, "items": []}`)
+		}
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	routes, err := mg.ListRoutesAll(context.Background(), nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(routes), 1)
+	ensure.DeepEqual(t, routes[0].Id, "route-1")
+	ensure.DeepEqual(t, calls, 3)
+}
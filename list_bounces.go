@@ -0,0 +1,121 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+	"net/mail"
+
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// ListBounceAction is what `HandleListBounce()` does with a member of a mailing list whose mail
+// failed permanently.
+type ListBounceAction int
+
+const (
+	// ListBounceUnsubscribe marks the member as unsubscribed, keeping their name and vars.
+	ListBounceUnsubscribe ListBounceAction = iota
+	// ListBounceRemove removes the member from the list.
+	ListBounceRemove
+)
+
+// HandleListBounce applies the action to the member of the mailing list the failed event was sent
+// to, if the failure is permanent, and reports whether a member was changed. The list is the one
+// named by the event, or else each address of the To header of the message; addresses which are
+// not mailing lists, or of which the recipient is not a member, are skipped. Temporary failures,
+// which Mailgun retries, are ignored.
+func HandleListBounce(ctx context.Context, mg Mailgun, e *events.Failed, action ListBounceAction) (bool, error) {
+	if e.Severity != events.SeverityPermanent || e.Recipient == "" {
+		return false, nil
+	}
+
+	var changed bool
+	for _, list := range eventLists(e.MailingList.Address, e.Message.Headers.To, e.Recipient) {
+		var err error
+		if action == ListBounceRemove {
+			err = mg.DeleteMember(ctx, e.Recipient, list)
+		} else {
+			_, err = mg.UpdateMember(ctx, e.Recipient, list, Member{Subscribed: Unsubscribed})
+		}
+		if GetStatusFromErr(err) == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// eventLists returns the mailing lists an event of the recipient may concern: the list named by
+// the event, or else each address of the To header of its message other than the recipient.
+func eventLists(list, to, recipient string) []string {
+	var candidates []string
+	if list != "" {
+		candidates = []string{list}
+	} else if addrs, err := mail.ParseAddressList(to); err == nil {
+		for _, a := range addrs {
+			candidates = append(candidates, a.Address)
+		}
+	}
+
+	var lists []string
+	for _, l := range candidates {
+		if normalizeAddress(l) != normalizeAddress(recipient) {
+			lists = append(lists, l)
+		}
+	}
+	return lists
+}
+
+// ListBounceHandler returns a handler for `WebhookDispatcher.OnFailed()` which calls
+// `HandleListBounce()` with every failed event, keeping mailing lists free of addresses which
+// bounce. Errors are returned to the dispatcher, so Mailgun delivers the event again later.
+//
+//  d.OnFailed(mailgun.ListBounceHandler(mg, mailgun.ListBounceUnsubscribe))
+func ListBounceHandler(mg Mailgun, action ListBounceAction) func(context.Context, *events.Failed) error {
+	return func(ctx context.Context, e *events.Failed) error {
+		_, err := HandleListBounce(ctx, mg, e, action)
+		return err
+	}
+}
+
+// SyncListBounces calls `HandleListBounce()` with every failed event returned by the iterator,
+// and returns the number of events which changed a member. Use it to clean up lists from the
+// events api, or to catch up on webhooks missed while the application was down. Pages are fetched
+// at the pace of the client's other bulk helpers, and retried when rate limited.
+//
+//  it := mg.ListEvents(&mailgun.ListEventOptions{
+//    Begin:  time.Now().Add(-24 * time.Hour),
+//    Filter: map[string]string{"event": "failed", "severity": "permanent"},
+//  })
+//  n, err := mailgun.SyncListBounces(ctx, it, mg, mailgun.ListBounceRemove)
+func SyncListBounces(ctx context.Context, it *EventIterator, mg Mailgun, action ListBounceAction) (int, error) {
+	var count int
+	var page []Event
+	var handleErr error
+	err := walkPages(ctx, limiterFor(it.mg), func(ctx context.Context) bool {
+		if !it.Next(ctx, &page) {
+			return false
+		}
+		for _, e := range page {
+			failed, ok := e.(*events.Failed)
+			if !ok {
+				continue
+			}
+			var changed bool
+			if changed, handleErr = HandleListBounce(ctx, mg, failed, action); handleErr != nil {
+				return false
+			}
+			if changed {
+				count++
+			}
+		}
+		return true
+	}, &it.err)
+	if handleErr != nil {
+		return count, handleErr
+	}
+	return count, err
+}
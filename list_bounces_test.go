@@ -0,0 +1,67 @@
+package mailgun_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+func TestHandleListBounce(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.FormValue("subscribed"))
+		if !strings.HasPrefix(r.URL.Path, "/v3/lists/news@lists.example.com/") {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Mailing list not found"}`))
+			return
+		}
+		w.Write([]byte(`{"member": {"address": "joe@example.com"}, "message": "ok"}`))
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	failed := func(severity, to, list string) *events.Failed {
+		e := &events.Failed{Severity: severity, Recipient: "joe@example.com"}
+		e.Message.Headers.To = to
+		e.MailingList.Address = list
+		return e
+	}
+
+	// Temporary failures are retried by Mailgun, and leave the member alone
+	changed, err := mailgun.HandleListBounce(ctx, mg, failed(events.SeverityTemporary, "news@lists.example.com", ""), mailgun.ListBounceUnsubscribe)
+	ensure.Nil(t, err)
+	ensure.False(t, changed)
+	ensure.DeepEqual(t, len(requests), 0)
+
+	// The list is found in the To header, skipping addresses which are not lists
+	changed, err = mailgun.HandleListBounce(ctx, mg, failed(events.SeverityPermanent, "Other <other@example.com>, News <news@lists.example.com>", ""), mailgun.ListBounceUnsubscribe)
+	ensure.Nil(t, err)
+	ensure.True(t, changed)
+	ensure.DeepEqual(t, requests, []string{
+		"PUT /v3/lists/other@example.com/members/joe@example.com no",
+		"PUT /v3/lists/news@lists.example.com/members/joe@example.com no",
+	})
+
+	// or named by the event
+	requests = nil
+	handler := mailgun.ListBounceHandler(mg, mailgun.ListBounceRemove)
+	ensure.Nil(t, handler(ctx, failed(events.SeverityPermanent, "joe@example.com", "news@lists.example.com")))
+	ensure.DeepEqual(t, requests, []string{"DELETE /v3/lists/news@lists.example.com/members/joe@example.com "})
+
+	// Mail sent to the recipient directly belongs to no list
+	requests = nil
+	changed, err = mailgun.HandleListBounce(ctx, mg, failed(events.SeverityPermanent, "joe@example.com", ""), mailgun.ListBounceRemove)
+	ensure.Nil(t, err)
+	ensure.False(t, changed)
+	ensure.DeepEqual(t, len(requests), 0)
+}
@@ -0,0 +1,110 @@
+package mailgun
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yjimk/mailgun-go/v4/addresses"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// Kinds of MembershipChange.
+const (
+	// MembershipJoined members were added to the list subscribed.
+	MembershipJoined = "joined"
+	// MembershipAddedUnsubscribed members were added to the list, but not subscribed.
+	MembershipAddedUnsubscribed = "added-unsubscribed"
+	// MembershipUnsubscribed members unsubscribed from the list.
+	MembershipUnsubscribed = "unsubscribed"
+	// MembershipUploadFailed members could not be added by a bulk upload.
+	MembershipUploadFailed = "upload-failed"
+)
+
+// MembershipChange is a single change to the members of a mailing list, from the events API.
+type MembershipChange struct {
+	Time time.Time
+	// Kind is MembershipJoined, MembershipAddedUnsubscribed, MembershipUnsubscribed or MembershipUploadFailed.
+	Kind    string
+	Address string
+	Name    string
+	// Detail describes the error of a failed upload, or the member it was for.
+	Detail string
+	// Event is the event the change was read from.
+	Event Event
+}
+
+// GetListMembershipChanges returns the changes to the members of the mailing list at addr since
+// the given time, oldest first, read from the list_member_uploaded, list_member_upload_error and
+// unsubscribed events of the domain of the list. Removals made through the api are not recorded
+// as events, and events are only kept for the retention period of your plan.
+func (mg *MailgunImpl) GetListMembershipChanges(ctx context.Context, addr string, since time.Time) ([]MembershipChange, error) {
+	_, domain, err := addresses.Split(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	it := mg.ListEventsWithDomain(&ListEventOptions{
+		Begin:          since,
+		ForceAscending: true,
+		Filter: map[string]string{
+			"event": strings.Join([]string{
+				events.EventListMemberUploaded,
+				events.EventListMemberUploadError,
+				events.EventUnsubscribed,
+			}, " OR "),
+		},
+	}, domain)
+
+	var changes []MembershipChange
+	var page []Event
+	for it.Next(ctx, &page) {
+		for _, e := range page {
+			if c, ok := membershipChange(addr, e); ok {
+				changes = append(changes, c)
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Time.Before(changes[j].Time)
+	})
+	return changes, nil
+}
+
+// membershipChange returns the change recorded by the event, if it is one for the list.
+func membershipChange(list string, e Event) (MembershipChange, bool) {
+	c := MembershipChange{Time: e.GetTimestamp(), Event: e}
+	switch e := e.(type) {
+	case *events.ListMemberUploaded:
+		if !strings.EqualFold(e.MailingList.Address, list) {
+			return c, false
+		}
+		c.Kind = MembershipJoined
+		if !e.Member.Subscribed {
+			c.Kind = MembershipAddedUnsubscribed
+		}
+		c.Address, c.Name = e.Member.Address, e.Member.Name
+	case *events.ListMemberUploadError:
+		if !strings.EqualFold(e.MailingList.Address, list) {
+			return c, false
+		}
+		c.Kind = MembershipUploadFailed
+		c.Detail = e.Error.Message
+		if e.MemberDescription != "" {
+			c.Detail += ": " + e.MemberDescription
+		}
+	case *events.Unsubscribed:
+		if !strings.EqualFold(e.MailingList.Address, list) {
+			return c, false
+		}
+		c.Kind, c.Address = MembershipUnsubscribed, e.Recipient
+	default:
+		return c, false
+	}
+	return c, true
+}
@@ -0,0 +1,61 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestGetListMembershipChanges(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("page") == "next" {
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+			return
+		}
+		ensure.DeepEqual(t, r.URL.Path, "/v3/lists.example.com/events")
+		ensure.DeepEqual(t, r.FormValue("event"), "list_member_uploaded OR list_member_upload_error OR unsubscribed")
+		ensure.DeepEqual(t, r.FormValue("ascending"), "yes")
+		ensure.True(t, r.FormValue("begin") != "")
+
+		at := float64(since.Unix())
+		fmt.Fprintf(w, `{"items": [
+			{"event": "list_member_uploaded", "id": "1", "timestamp": %[1]f,
+			 "mailing-list": {"address": "News@lists.example.com"}, "member": {"address": "joe@example.com", "name": "Joe", "subscribed": true}},
+			{"event": "list_member_uploaded", "id": "2", "timestamp": %[2]f,
+			 "mailing-list": {"address": "other@lists.example.com"}, "member": {"address": "ann@example.com", "subscribed": true}},
+			{"event": "list_member_upload_error", "id": "3", "timestamp": %[2]f,
+			 "mailing-list": {"address": "news@lists.example.com"}, "member-description": "not-an-address", "error": {"message": "Invalid address"}},
+			{"event": "unsubscribed", "id": "4", "timestamp": %[3]f,
+			 "mailing-list": {"address": "news@lists.example.com"}, "recipient": "joe@example.com"}
+		], "paging": {"next": "%[4]s/v3/lists.example.com/events?page=next"}}`, at+60, at+120, at+180, "http://"+r.Host)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	changes, err := mg.GetListMembershipChanges(context.Background(), "news@lists.example.com", since)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(changes), 3)
+
+	ensure.DeepEqual(t, changes[0].Kind, mailgun.MembershipJoined)
+	ensure.DeepEqual(t, changes[0].Address, "joe@example.com")
+	ensure.DeepEqual(t, changes[0].Name, "Joe")
+	ensure.DeepEqual(t, changes[0].Time.Unix(), since.Unix()+60)
+
+	ensure.DeepEqual(t, changes[1].Kind, mailgun.MembershipUploadFailed)
+	ensure.DeepEqual(t, changes[1].Detail, "Invalid address: not-an-address")
+
+	ensure.DeepEqual(t, changes[2].Kind, mailgun.MembershipUnsubscribed)
+	ensure.DeepEqual(t, changes[2].Address, "joe@example.com")
+
+	_, err = mg.GetListMembershipChanges(context.Background(), "not-an-address", since)
+	ensure.NotNil(t, err)
+}
@@ -0,0 +1,105 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// listPollInterval is the first wait between polls for a mailing list which is not found yet,
+	// doubling up to listPollMaxInterval.
+	listPollInterval    = 250 * time.Millisecond
+	listPollMaxInterval = 4 * time.Second
+)
+
+// SetListConsistencyWait sets how long after the client created a mailing list `GetMailingList()`
+// keeps polling for it while Mailgun answers 404, as it may until the new list has propagated.
+// Zero, the default, returns the 404 at once. Lists created by other clients are not waited for;
+// use `WaitForMailingList()` for those.
+func (mg *MailgunImpl) SetListConsistencyWait(d time.Duration) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.listConsistencyWait = d
+	if d <= 0 {
+		mg.createdLists = nil
+	}
+}
+
+// WaitForMailingList returns the mailing list at addr, polling for it with backoff while Mailgun
+// answers 404, for up to timeout. It returns the last error once the timeout has passed.
+//
+//  _, err := mg.CreateMailingList(ctx, mailgun.MailingList{Address: "dev@example.com"})
+//  // ...
+//  list, err := mg.WaitForMailingList(ctx, "dev@example.com", 30*time.Second)
+func (mg *MailgunImpl) WaitForMailingList(ctx context.Context, addr string, timeout time.Duration) (MailingList, error) {
+	return mg.pollMailingList(ctx, addr, mg.clock().Now().Add(timeout))
+}
+
+// pollMailingList gets the mailing list at addr, retrying 404s until deadline.
+func (mg *MailgunImpl) pollMailingList(ctx context.Context, addr string, deadline time.Time) (MailingList, error) {
+	clk := mg.clock()
+	interval := listPollInterval
+	for {
+		list, err := mg.getMailingList(ctx, addr)
+		if GetStatusFromErr(err) != http.StatusNotFound {
+			if err == nil {
+				mg.forgetCreatedList(addr)
+			}
+			return list, err
+		}
+		wait := deadline.Sub(clk.Now())
+		if wait <= 0 {
+			mg.forgetCreatedList(addr)
+			return list, err
+		}
+		if wait > interval {
+			wait = interval
+		}
+		if err := clk.Sleep(ctx, wait); err != nil {
+			return list, err
+		}
+		if interval *= 2; interval > listPollMaxInterval {
+			interval = listPollMaxInterval
+		}
+	}
+}
+
+// listCreated records that the client created the mailing list at addr, if it waits for lists to
+// propagate.
+func (mg *MailgunImpl) listCreated(addr string) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	if mg.listConsistencyWait <= 0 || addr == "" {
+		return
+	}
+	now := mg.clock().Now()
+	if mg.createdLists == nil {
+		mg.createdLists = make(map[string]time.Time)
+	}
+	for a, created := range mg.createdLists {
+		if now.Sub(created) >= mg.listConsistencyWait {
+			delete(mg.createdLists, a)
+		}
+	}
+	mg.createdLists[strings.ToLower(addr)] = now
+}
+
+// listConsistencyDeadline returns until when a 404 for the mailing list at addr is retried. ok is
+// false if the client did not create the list recently.
+func (mg *MailgunImpl) listConsistencyDeadline(addr string) (deadline time.Time, ok bool) {
+	mg.mu.RLock()
+	defer mg.mu.RUnlock()
+	created, ok := mg.createdLists[strings.ToLower(addr)]
+	if !ok {
+		return deadline, false
+	}
+	return created.Add(mg.listConsistencyWait), true
+}
+
+func (mg *MailgunImpl) forgetCreatedList(addr string) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	delete(mg.createdLists, strings.ToLower(addr))
+}
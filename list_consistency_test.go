@@ -0,0 +1,76 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestListConsistencyWait(t *testing.T) {
+	var gets int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			fmt.Fprint(w, `{"message": "Mailing list has been created", "list": {"address": "dev@example.com"}}`)
+			return
+		}
+		// The list propagates after the third poll
+		if gets++; gets <= 3 {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Mailing list dev@example.com not found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"member": {"address": "dev@example.com"}}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	clock := newFakeClock()
+	mg.clk = clock
+	ctx := context.Background()
+
+	// Lists the client did not create are not waited for
+	mg.SetListConsistencyWait(10 * time.Second)
+	_, err := mg.GetMailingList(ctx, "dev@example.com")
+	ensure.DeepEqual(t, GetStatusFromErr(err), http.StatusNotFound)
+
+	_, err = mg.CreateMailingList(ctx, MailingList{Address: "Dev@example.com"})
+	ensure.Nil(t, err)
+	list, err := mg.GetMailingList(ctx, "dev@example.com")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, list.Address, "dev@example.com")
+	ensure.DeepEqual(t, gets, 4)
+	ensure.DeepEqual(t, clock.sleeps, []time.Duration{250 * time.Millisecond, 500 * time.Millisecond})
+
+	// Once found, the list is no longer waited for
+	gets = 0
+	_, err = mg.GetMailingList(ctx, "dev@example.com")
+	ensure.DeepEqual(t, GetStatusFromErr(err), http.StatusNotFound)
+	ensure.DeepEqual(t, gets, 1)
+}
+
+func TestWaitForMailingList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message": "Mailing list dev@example.com not found"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	clock := newFakeClock()
+	mg.clk = clock
+
+	start := clock.Now()
+	_, err := mg.WaitForMailingList(context.Background(), "dev@example.com", 10*time.Second)
+	ensure.DeepEqual(t, GetStatusFromErr(err), http.StatusNotFound)
+	ensure.DeepEqual(t, clock.Now().Sub(start), 10*time.Second)
+	ensure.DeepEqual(t, clock.sleeps, []time.Duration{
+		250 * time.Millisecond, 500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second, 2250 * time.Millisecond,
+	})
+}
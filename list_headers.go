@@ -0,0 +1,124 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxListCacheEntries is how many addresses the lookups of `SetListHeaders()` are cached for.
+const maxListCacheEntries = 1000
+
+// SetListHeaders enables or disables automatic list headers. When enabled, `Send()` looks up each
+// To: address of a message with `GetMailingList()`, and if one is a mailing list, adds the List-Id,
+// List-Post and List-Unsubscribe headers derived from it, as `Message.SetMailingList()` would.
+// Up to maxListCacheEntries lookups are cached, and those of lists the client creates, updates or
+// deletes are forgotten. List headers are disabled by default, as each new recipient costs an
+// extra request; a failed lookup sends the message without list headers.
+func (mg *MailgunImpl) SetListHeaders(enabled bool) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.listHeaders = enabled
+}
+
+// mailingListFor returns the mailing list the message is sent to, if known.
+func (mg *MailgunImpl) mailingListFor(ctx context.Context, m *Message) *MailingList {
+	if m.mailingList != nil {
+		return m.mailingList
+	}
+
+	mg.mu.RLock()
+	enabled := mg.listHeaders
+	mg.mu.RUnlock()
+	if !enabled {
+		return nil
+	}
+
+	for _, to := range m.to {
+		address := normalizeAddress(to)
+
+		mg.mu.RLock()
+		list, ok := mg.listCache[address]
+		mg.mu.RUnlock()
+		if !ok {
+			l, err := mg.GetMailingList(ctx, address)
+			if err != nil && GetStatusFromErr(err) != http.StatusNotFound {
+				// Try again on the next send
+				continue
+			}
+			if err == nil && l.Address != "" {
+				list = &l
+			}
+			mg.cacheList(address, list)
+		}
+		if list != nil {
+			return list
+		}
+	}
+	return nil
+}
+
+// cacheList caches the mailing list at address, or nil if there is none, dropping an arbitrary
+// entry if the cache is full.
+func (mg *MailgunImpl) cacheList(address string, list *MailingList) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	if mg.listCache == nil {
+		mg.listCache = make(map[string]*MailingList)
+	}
+	if _, ok := mg.listCache[address]; !ok && len(mg.listCache) >= maxListCacheEntries {
+		for a := range mg.listCache {
+			delete(mg.listCache, a)
+			break
+		}
+	}
+	mg.listCache[address] = list
+}
+
+// forgetList drops the cached lookups of the mailing lists at addresses, which have changed.
+func (mg *MailgunImpl) forgetList(addresses ...string) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	for _, address := range addresses {
+		delete(mg.listCache, normalizeAddress(address))
+	}
+}
+
+// listHeaders returns the RFC 2369 and RFC 2919 headers for mail sent to the list.
+func listHeaders(list MailingList) map[string]string {
+	id := strings.Replace(list.Address, "@", ".", 1)
+	if list.Name != "" {
+		id = fmt.Sprintf(`"%s" <%s>`, strings.Replace(list.Name, `"`, "", -1), id)
+	} else {
+		id = "<" + id + ">"
+	}
+
+	post := "<mailto:" + list.Address + ">"
+	if list.AccessLevel == AccessLevelReadOnly {
+		post = "NO"
+	}
+
+	return map[string]string{
+		"List-Id":   id,
+		"List-Post": post,
+		// Mailgun substitutes the unsubscribe link of each member when sending to a list
+		"List-Unsubscribe": "<%mailing_list_unsubscribe_url%>",
+	}
+}
+
+// hasHeader reports whether headers includes the named header, ignoring case.
+func hasHeader(headers map[string]string, name string) bool {
+	_, ok := headerKey(headers, name)
+	return ok
+}
+
+// headerKey returns the key under which the named header is stored in headers, ignoring case.
+func headerKey(headers map[string]string, name string) (string, bool) {
+	for h := range headers {
+		if strings.EqualFold(h, name) {
+			return h, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,85 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestListHeaders(t *testing.T) {
+	var lookups int
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v3/lists/News@example.com" && r.Method == http.MethodPut:
+			fmt.Fprint(w, `{"list": {"address": "news@example.com", "name": "Daily News"}}`)
+		case r.URL.Path == "/v3/lists/news@example.com":
+			lookups++
+			fmt.Fprint(w, `{"member": {"address": "news@example.com", "name": "Weekly News", "access_level": "readonly"}}`)
+		case strings.HasPrefix(r.URL.Path, "/v3/lists/"):
+			lookups++
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Mailing list not found"}`)
+		default:
+			ensure.Nil(t, r.ParseMultipartForm(1<<20))
+			headers = http.Header{}
+			for k, v := range r.MultipartForm.Value {
+				if strings.HasPrefix(k, "h:") {
+					headers[strings.TrimPrefix(k, "h:")] = v
+				}
+			}
+			fmt.Fprint(w, `{"id": "<id@example.com>", "message": "Queued. Thank you."}`)
+		}
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	// Explicitly marked list mail
+	m := mg.NewMessage("sender@example.com", "Hello", "Text", "team@example.com")
+	m.SetMailingList(mailgun.MailingList{Address: "team@example.com", Name: "Team"})
+	m.AddHeader("List-Post", "<https://example.com/post>")
+	_, _, err := mg.Send(ctx, m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, headers, http.Header{
+		"List-Id":          {`"Team" <team.example.com>`},
+		"List-Post":        {"<https://example.com/post>"},
+		"List-Unsubscribe": {"<%mailing_list_unsubscribe_url%>"},
+	})
+
+	// Disabled by default
+	_, _, err = mg.Send(ctx, mg.NewMessage("sender@example.com", "Hello", "Text", "news@example.com"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(headers), 0)
+	ensure.DeepEqual(t, lookups, 0)
+
+	// Looked up once, and cached
+	mg.SetListHeaders(true)
+	for i := 0; i < 2; i++ {
+		_, _, err = mg.Send(ctx, mg.NewMessage("sender@example.com", "Hello", "Text", "Reader <news@example.com>"))
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, headers.Get("List-Id"), `"Weekly News" <news.example.com>`)
+		ensure.DeepEqual(t, headers.Get("List-Post"), "NO")
+	}
+	ensure.DeepEqual(t, lookups, 1)
+
+	_, _, err = mg.Send(ctx, mg.NewMessage("sender@example.com", "Hello", "Text", "bob@example.com"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(headers), 0)
+	ensure.DeepEqual(t, lookups, 2)
+
+	// Changing the list forgets the lookup
+	_, err = mg.UpdateMailingList(ctx, "News@example.com", mailgun.MailingList{Name: "Daily News"})
+	ensure.Nil(t, err)
+	_, _, err = mg.Send(ctx, mg.NewMessage("sender@example.com", "Hello", "Text", "news@example.com"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, lookups, 3)
+}
@@ -0,0 +1,110 @@
+package mailgun
+
+import (
+	"context"
+	"time"
+
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// ListStatsOptions limits the period covered by GetListStats().
+type ListStatsOptions struct {
+	// Begin and End bound the period; by default the 30 days up to now.
+	// Events are only available for as long as your plan retains them.
+	Begin, End time.Time
+}
+
+// ListStats summarizes engagement with mail sent to a mailing list.
+type ListStats struct {
+	Address    string
+	Begin, End time.Time
+
+	Delivered int
+	// Failed counts permanent failures only; temporary failures are retried by Mailgun.
+	Failed        int
+	Opened        int
+	UniqueOpened  int
+	Clicked       int
+	UniqueClicked int
+	Unsubscribed  int
+	Complained    int
+}
+
+// OpenRate returns the proportion of delivered messages opened at least once.
+func (s ListStats) OpenRate() float64 {
+	if s.Delivered == 0 {
+		return 0
+	}
+	return float64(s.UniqueOpened) / float64(s.Delivered)
+}
+
+// ClickRate returns the proportion of delivered messages clicked at least once.
+func (s ListStats) ClickRate() float64 {
+	if s.Delivered == 0 {
+		return 0
+	}
+	return float64(s.UniqueClicked) / float64(s.Delivered)
+}
+
+// GetListStats counts the delivered, failed, opened, clicked, unsubscribed and complained events
+// of mail sent to the mailing list at addr over a period. Mailgun does not keep statistics per
+// list, so every matching event is fetched from the events api and aggregated locally; unique
+// opens and clicks count each recipient of each message once.
+func (mg *MailgunImpl) GetListStats(ctx context.Context, addr string, opts *ListStatsOptions) (ListStats, error) {
+	stats := ListStats{Address: addr, End: time.Now()}
+	if opts != nil {
+		stats.Begin, stats.End = opts.Begin, opts.End
+		if stats.End.IsZero() {
+			stats.End = time.Now()
+		}
+	}
+	if stats.Begin.IsZero() {
+		stats.Begin = stats.End.Add(-30 * 24 * time.Hour)
+	}
+
+	it := mg.ListEvents(&ListEventOptions{
+		Begin:          stats.Begin,
+		End:            stats.End,
+		ForceAscending: true,
+		Limit:          300,
+		Filter: map[string]string{
+			"list":  addr,
+			"event": "delivered OR failed OR opened OR clicked OR unsubscribed OR complained",
+		},
+	})
+
+	opened := make(map[string]bool)
+	clicked := make(map[string]bool)
+	var page []Event
+	for it.Next(ctx, &page) {
+		for _, e := range page {
+			switch e := e.(type) {
+			case *events.Delivered:
+				stats.Delivered++
+			case *events.Failed:
+				if e.Severity == events.SeverityPermanent {
+					stats.Failed++
+				}
+			case *events.Opened:
+				stats.Opened++
+				key := e.Message.Headers.MessageID + " " + normalizeAddress(e.Recipient)
+				if !opened[key] {
+					opened[key] = true
+					stats.UniqueOpened++
+				}
+			case *events.Clicked:
+				stats.Clicked++
+				key := e.Message.Headers.MessageID + " " + normalizeAddress(e.Recipient)
+				if !clicked[key] {
+					clicked[key] = true
+					stats.UniqueClicked++
+				}
+			case *events.Unsubscribed:
+				stats.Unsubscribed++
+			case *events.Complained:
+				stats.Complained++
+			}
+		}
+	}
+	return stats, it.Err()
+}
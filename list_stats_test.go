@@ -0,0 +1,60 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestGetListStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+			return
+		}
+		ensure.DeepEqual(t, r.URL.Query().Get("list"), "news@example.com")
+		fmt.Fprintf(w, `{"items": [
+			{"event": "delivered", "id": "1", "recipient": "a@example.com", "message": {"headers": {"message-id": "m1"}}},
+			{"event": "delivered", "id": "2", "recipient": "b@example.com", "message": {"headers": {"message-id": "m1"}}},
+			{"event": "delivered", "id": "3", "recipient": "c@example.com", "message": {"headers": {"message-id": "m1"}}},
+			{"event": "delivered", "id": "4", "recipient": "d@example.com", "message": {"headers": {"message-id": "m1"}}},
+			{"event": "failed", "id": "5", "severity": "permanent", "recipient": "e@example.com"},
+			{"event": "failed", "id": "6", "severity": "temporary", "recipient": "d@example.com"},
+			{"event": "opened", "id": "7", "recipient": "a@example.com", "message": {"headers": {"message-id": "m1"}}},
+			{"event": "opened", "id": "8", "recipient": "a@example.com", "message": {"headers": {"message-id": "m1"}}},
+			{"event": "opened", "id": "9", "recipient": "b@example.com", "message": {"headers": {"message-id": "m1"}}},
+			{"event": "clicked", "id": "10", "recipient": "a@example.com", "message": {"headers": {"message-id": "m1"}}},
+			{"event": "unsubscribed", "id": "11", "recipient": "b@example.com"},
+			{"event": "complained", "id": "12", "recipient": "c@example.com"}
+		], "paging": {"next": "http://%s/v3/mailgun.test/events?page=2"}}`, r.Host)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	end := time.Now()
+	stats, err := mg.GetListStats(context.Background(), "news@example.com", &mailgun.ListStatsOptions{End: end})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, stats, mailgun.ListStats{
+		Address:       "news@example.com",
+		Begin:         end.Add(-30 * 24 * time.Hour),
+		End:           end,
+		Delivered:     4,
+		Failed:        1,
+		Opened:        3,
+		UniqueOpened:  2,
+		Clicked:       1,
+		UniqueClicked: 1,
+		Unsubscribed:  1,
+		Complained:    1,
+	})
+	ensure.DeepEqual(t, stats.OpenRate(), 0.5)
+	ensure.DeepEqual(t, stats.ClickRate(), 0.25)
+}
@@ -0,0 +1,187 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultListWatchInterval is how often a ListWatcher polls its list, unless given an interval.
+const DefaultListWatchInterval = time.Minute
+
+// The fields of a mailing list a ListWatcher detects changes to, by their names in the api.
+const (
+	ListFieldName            = "name"
+	ListFieldDescription     = "description"
+	ListFieldAccessLevel     = "access_level"
+	ListFieldReplyPreference = "reply_preference"
+	ListFieldMembersCount    = "members_count"
+)
+
+// ListChange is a change to a mailing list seen by a ListWatcher between two polls.
+type ListChange struct {
+	// Time is when the change was seen.
+	Time time.Time
+	// Previous and Current are the list as of the previous poll and this one. Current is the zero
+	// MailingList if the list was deleted.
+	Previous MailingList
+	Current  MailingList
+	// Fields are the fields which changed, such as ListFieldAccessLevel, in the order of the
+	// ListField constants.
+	Fields []string
+	// MembersDelta is the change in the number of members, which is negative if members were
+	// removed. Members joining and leaving between two polls cancel out.
+	MembersDelta int
+	// Deleted is set if the list was deleted, after which the watcher stops.
+	Deleted bool
+}
+
+// Changed reports whether the field, such as ListFieldAccessLevel, changed.
+func (c ListChange) Changed(field string) bool {
+	for _, f := range c.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ListWatcher polls a mailing list and delivers changes to its settings and members count on a
+// channel, for changes Mailgun has no webhooks for.
+//
+//  w := mg.WatchList(ctx, "dev@example.com", 5*time.Minute)
+//  defer w.Stop()
+//  for c := range w.Changes() {
+//    if c.Changed(mailgun.ListFieldAccessLevel) {
+//      log.Printf("access level of %s is now %s", c.Current.Address, c.Current.AccessLevel)
+//    }
+//    log.Printf("%+d members", c.MembersDelta)
+//  }
+//  if err := w.Err(); err != nil {
+//    log.Printf("watching stopped: %s", err)
+//  }
+type ListWatcher struct {
+	mg       *MailgunImpl
+	addr     string
+	interval time.Duration
+	changes  chan ListChange
+	cancel   context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// WatchList starts polling the mailing list at addr every interval, or DefaultListWatchInterval if
+// it is zero, until the context is cancelled or `ListWatcher.Stop()` is called. The first poll
+// takes the state changes are compared with. A failed poll is retried at the next interval; if
+// the list is not found, a change with Deleted set is delivered and the watcher stops. Polling
+// waits while a change is not received.
+func (mg *MailgunImpl) WatchList(ctx context.Context, addr string, interval time.Duration) *ListWatcher {
+	if interval <= 0 {
+		interval = DefaultListWatchInterval
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	w := &ListWatcher{
+		mg:       mg,
+		addr:     addr,
+		interval: interval,
+		changes:  make(chan ListChange),
+		cancel:   cancel,
+	}
+	go w.run(ctx)
+	return w
+}
+
+// Changes returns the channel changes are delivered on. It is closed when the watcher stops.
+func (w *ListWatcher) Changes() <-chan ListChange {
+	return w.changes
+}
+
+// Err returns the error of the last poll, or nil if it succeeded. Once the watcher stopped, it is
+// the error of the context if it was cancelled.
+func (w *ListWatcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Stop stops polling, and closes the channel of changes.
+func (w *ListWatcher) Stop() {
+	w.cancel()
+}
+
+func (w *ListWatcher) setErr(err error) {
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+}
+
+func (w *ListWatcher) run(ctx context.Context) {
+	defer close(w.changes)
+	defer w.cancel()
+	clk := w.mg.clock()
+
+	var previous MailingList
+	var polled bool
+	for {
+		current, err := w.mg.GetMailingList(ctx, w.addr)
+		switch {
+		case ctx.Err() != nil:
+			w.setErr(ctx.Err())
+			return
+		case err == nil:
+			w.setErr(nil)
+			if polled {
+				if c, ok := listChange(clk.Now(), previous, current); ok && !w.send(ctx, c) {
+					return
+				}
+			}
+			previous, polled = current, true
+		case polled && GetStatusFromErr(err) == http.StatusNotFound:
+			w.setErr(nil)
+			w.send(ctx, ListChange{Time: clk.Now(), Previous: previous, Deleted: true})
+			return
+		default:
+			w.setErr(err)
+		}
+
+		if err := clk.Sleep(ctx, w.interval); err != nil {
+			w.setErr(err)
+			return
+		}
+	}
+}
+
+// send delivers the change, and reports whether it was received before the watcher stopped.
+func (w *ListWatcher) send(ctx context.Context, c ListChange) bool {
+	select {
+	case w.changes <- c:
+		return true
+	case <-ctx.Done():
+		w.setErr(ctx.Err())
+		return false
+	}
+}
+
+// listChange returns the change between two polls of a list, if there is one.
+func listChange(now time.Time, previous, current MailingList) (ListChange, bool) {
+	c := ListChange{Time: now, Previous: previous, Current: current}
+	if previous.Name != current.Name {
+		c.Fields = append(c.Fields, ListFieldName)
+	}
+	if previous.Description != current.Description {
+		c.Fields = append(c.Fields, ListFieldDescription)
+	}
+	if previous.AccessLevel != current.AccessLevel {
+		c.Fields = append(c.Fields, ListFieldAccessLevel)
+	}
+	if previous.ReplyPreference != current.ReplyPreference {
+		c.Fields = append(c.Fields, ListFieldReplyPreference)
+	}
+	if previous.MembersCount != current.MembersCount {
+		c.Fields = append(c.Fields, ListFieldMembersCount)
+		c.MembersDelta = current.MembersCount - previous.MembersCount
+	}
+	return c, len(c.Fields) != 0
+}
@@ -0,0 +1,79 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestWatchList(t *testing.T) {
+	states := []string{
+		`{"access_level": "readonly", "members_count": 2}`,
+		`{"access_level": "readonly", "members_count": 2}`,
+		`{"access_level": "readonly", "members_count": 5}`,
+		`{"access_level": "members", "description": "Dev team", "members_count": 4}`,
+	}
+	var polls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ensure.DeepEqual(t, r.URL.Path, "/v3/lists/dev@example.com")
+		if polls++; polls > len(states) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Mailing list not found"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"member": %s}`, states[polls-1])
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	clock := newFakeClock()
+	mg.clk = clock
+
+	w := mg.WatchList(context.Background(), "dev@example.com", 0)
+	var changes []ListChange
+	for c := range w.Changes() {
+		changes = append(changes, c)
+	}
+	ensure.Nil(t, w.Err())
+	ensure.DeepEqual(t, len(changes), 3)
+
+	ensure.DeepEqual(t, changes[0].Fields, []string{ListFieldMembersCount})
+	ensure.DeepEqual(t, changes[0].MembersDelta, 3)
+
+	ensure.DeepEqual(t, changes[1].Fields, []string{ListFieldDescription, ListFieldAccessLevel, ListFieldMembersCount})
+	ensure.True(t, changes[1].Changed(ListFieldAccessLevel))
+	ensure.DeepEqual(t, changes[1].Previous.AccessLevel, AccessLevel("readonly"))
+	ensure.DeepEqual(t, changes[1].Current.AccessLevel, AccessLevel("members"))
+	ensure.DeepEqual(t, changes[1].MembersDelta, -1)
+
+	ensure.True(t, changes[2].Deleted)
+	ensure.DeepEqual(t, changes[2].Previous.MembersCount, 4)
+	ensure.DeepEqual(t, changes[2].Time, clock.Now())
+	ensure.DeepEqual(t, clock.sleeps, []time.Duration{
+		DefaultListWatchInterval, DefaultListWatchInterval, DefaultListWatchInterval, DefaultListWatchInterval,
+	})
+}
+
+func TestWatchListStop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	w := mg.WatchList(context.Background(), "dev@example.com", time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	w.Stop()
+	for range w.Changes() {
+		t.Fatal("no change expected")
+	}
+	ensure.DeepEqual(t, w.Err(), context.Canceled)
+}
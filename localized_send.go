@@ -0,0 +1,128 @@
+package mailgun
+
+import (
+	"context"
+	"strings"
+)
+
+// DefaultLocale is the locale of the recipients `SendLocalized()` has no locale for, unless its
+// options set another.
+const DefaultLocale = "en"
+
+// LocalizedOptions modifies how `SendLocalized()` picks the version of the template of each locale.
+type LocalizedOptions struct {
+	// DefaultLocale is the locale of recipients without one; defaults to DefaultLocale.
+	DefaultLocale string
+	// VersionTag returns the tag of the template version of a locale; defaults to LocaleVersionTag.
+	VersionTag func(locale string) string
+	// CheckVersions lists the versions of the template first, and sends the recipients of locales
+	// without a version in the default locale, rather than failing the send of their locale.
+	CheckVersions bool
+}
+
+// LocaleVersionTag returns the tag of the template version of a locale, by convention "v-"
+// followed by the lowercased locale, such as "v-de" or "v-pt-br".
+func LocaleVersionTag(locale string) string {
+	return "v-" + strings.ToLower(locale)
+}
+
+// SendLocalized sends a message using a stored template to each To: recipient in their own
+// language: recipientLocales maps the addresses of recipients to their locale, such as "de", and
+// the recipients of each locale are sent the message as one batch, with the version of the template
+// tagged for that locale, such as "v-de". Recipients missing from recipientLocales get the default
+// locale. Each batch is sent with `SendBatch()`, with the recipient variables of its recipients;
+// Cc: and Bcc: recipients receive the batch of the first locale only. opts may be nil.
+//
+//  m := mg.NewMessage("Example <no-reply@mg.example.com>", "", "", "anna@example.com", "joe@example.com")
+//  m.SetTemplate("welcome")
+//  result, err := mg.SendLocalized(ctx, m, map[string]string{"anna@example.com": "de"}, nil)
+//
+// The result holds every recipient, with the ID of the message of their batch if it differs from
+// the first. If a batch fails, the result holds the recipients of those which were sent, with the
+// error.
+func (mg *MailgunImpl) SendLocalized(ctx context.Context, m *Message, recipientLocales map[string]string, opts *LocalizedOptions) (*BatchResult, error) {
+	var o LocalizedOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.DefaultLocale == "" {
+		o.DefaultLocale = DefaultLocale
+	}
+	if o.VersionTag == nil {
+		o.VersionTag = LocaleVersionTag
+	}
+	pm, ok := m.specific.(*plainMessage)
+	if !ok || pm.template == "" {
+		return nil, newValidationError("template", "SendLocalized() requires a message sent with a stored template")
+	}
+	if len(m.to) == 0 {
+		return nil, newValidationError("to", "SendLocalized() requires To: recipients")
+	}
+
+	var versions map[string]bool
+	if o.CheckVersions {
+		versions = make(map[string]bool)
+		var page []TemplateVersion
+		it := mg.ListTemplateVersions(pm.template, nil)
+		for it.Next(ctx, &page) {
+			for _, v := range page {
+				versions[v.Tag] = true
+			}
+		}
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	locales := make(map[string]string, len(recipientLocales))
+	for address, locale := range recipientLocales {
+		locales[normalizeAddress(address)] = locale
+	}
+	var tags []string
+	byTag := make(map[string][]string)
+	for _, to := range m.to {
+		locale, ok := locales[normalizeAddress(to)]
+		if !ok || locale == "" {
+			locale = o.DefaultLocale
+		}
+		tag := o.VersionTag(locale)
+		if versions != nil && !versions[tag] {
+			tag = o.VersionTag(o.DefaultLocale)
+		}
+		if _, ok := byTag[tag]; !ok {
+			tags = append(tags, tag)
+		}
+		byTag[tag] = append(byTag[tag], to)
+	}
+
+	groups := make([][]string, len(tags))
+	for i, tag := range tags {
+		groups[i] = byTag[tag]
+	}
+	parts, err := m.splitRecipients(groups, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *BatchResult
+	for i, part := range parts {
+		part.templateVersionTag = tags[i]
+		r, err := mg.SendBatch(ctx, part)
+		if r != nil {
+			if result == nil {
+				result = r
+			} else {
+				for _, rr := range r.Recipients {
+					if rr.MessageID == "" {
+						rr.MessageID = r.ID
+					}
+					result.Recipients = append(result.Recipients, rr)
+				}
+			}
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,98 @@
+package mailgun_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestSendLocalized(t *testing.T) {
+	type request struct {
+		to, version, cc string
+		vars            map[string]interface{}
+	}
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			// One page of versions, then an empty one
+			if r.FormValue("page") != "" {
+				fmt.Fprint(w, `{"template": {"versions": []}}`)
+				return
+			}
+			fmt.Fprintf(w, `{"template": {"versions": [{"tag": "v-en"}, {"tag": "v-de"}]}, "paging": {"next": "http://%s%s?page=next"}}`, r.Host, r.URL.Path)
+			return
+		}
+		req := request{
+			version: r.FormValue("t:version"),
+			cc:      r.FormValue("cc"),
+		}
+		req.to = strings.Join(r.Form["to"], ",")
+		ensure.Nil(t, json.Unmarshal([]byte(r.FormValue("recipient-variables")), &req.vars))
+		requests = append(requests, req)
+		fmt.Fprintf(w, `{"id": "<batch-%d@example.com>", "message": "Queued. Thank you."}`, len(requests))
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	newMessage := func() *mailgun.Message {
+		m := mg.NewMessage("root@"+testDomain, "Welcome", "")
+		m.SetTemplate("welcome")
+		m.AddCC("audit@example.com")
+		for _, to := range []string{"anna@example.com", "joe@example.com", "marie@example.com", "jan@example.com"} {
+			ensure.Nil(t, m.AddRecipientAndVariables(to, map[string]interface{}{"name": to[:strings.Index(to, "@")]}))
+		}
+		return m
+	}
+	locales := map[string]string{"Anna@example.com": "de", "marie@example.com": "fr", "jan@example.com": "DE"}
+
+	result, err := mg.SendLocalized(ctx, newMessage(), locales, nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(requests), 3)
+	ensure.DeepEqual(t, requests[0], request{
+		to:      "anna@example.com,jan@example.com",
+		version: "v-de",
+		cc:      "audit@example.com",
+		vars: map[string]interface{}{
+			"anna@example.com": map[string]interface{}{"name": "anna"},
+			"jan@example.com":  map[string]interface{}{"name": "jan"},
+		},
+	})
+	ensure.DeepEqual(t, requests[1].to, "joe@example.com")
+	ensure.DeepEqual(t, requests[1].version, "v-en")
+	ensure.DeepEqual(t, requests[1].cc, "")
+	ensure.DeepEqual(t, requests[2].version, "v-fr")
+
+	ensure.DeepEqual(t, result.ID, "<batch-1@example.com>")
+	var ids []string
+	for _, r := range result.Recipients {
+		ids = append(ids, r.Address+"="+r.MessageID)
+	}
+	ensure.DeepEqual(t, ids, []string{
+		"anna@example.com=",
+		"jan@example.com=",
+		"joe@example.com=<batch-2@example.com>",
+		"marie@example.com=<batch-3@example.com>",
+	})
+
+	// Locales without a version fall back to the default locale
+	requests = nil
+	_, err = mg.SendLocalized(ctx, newMessage(), locales, &mailgun.LocalizedOptions{CheckVersions: true})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(requests), 2)
+	ensure.DeepEqual(t, requests[1].to, "joe@example.com,marie@example.com")
+	ensure.DeepEqual(t, requests[1].version, "v-en")
+
+	_, err = mg.SendLocalized(ctx, mg.NewMessage("root@"+testDomain, "Welcome", "Text", "joe@example.com"), locales, nil)
+	ensure.True(t, errors.Is(err, mailgun.ErrInvalidMessage))
+}
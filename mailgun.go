@@ -81,7 +81,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -95,6 +98,7 @@ const (
 	APIBaseEU            = "https://api.eu.mailgun.net/v3"
 	messagesEndpoint     = "messages"
 	mimeMessagesEndpoint = "messages.mime"
+	envelopesEndpoint    = "envelopes"
 	bouncesEndpoint      = "bounces"
 	statsTotalEndpoint   = "stats/total"
 	domainsEndpoint      = "domains"
@@ -108,8 +112,22 @@ const (
 	listsEndpoint        = "lists"
 	basicAuthUser        = "api"
 	templatesEndpoint    = "templates"
+	accountsEndpoint     = "accounts"
+	sandboxEndpoint      = "sandbox"
+	ipAllowlistEndpoint  = "ip_whitelist"
+	usersEndpoint        = "users"
 )
 
+// endpointVersions registers the endpoints served by another version of the API than the one the
+// API base ends with, such as the v5 accounts API. URLs for them are generated with the version of
+// the registry in place of the version of the API base; other endpoints use the API base as is.
+var endpointVersions = map[string]string{
+	accountsEndpoint:    "v5",
+	sandboxEndpoint:     "v5",
+	usersEndpoint:       "v5",
+	ipAllowlistEndpoint: "v2",
+}
+
 // Mailgun defines the supported subset of the Mailgun API.
 // The Mailgun API may contain additional features which have been deprecated since writing this SDK.
 // This SDK only covers currently supported interface endpoints.
@@ -125,9 +143,16 @@ type Mailgun interface {
 	Client() *http.Client
 	SetClient(client *http.Client)
 	SetAPIBase(url string)
+	Capabilities() Capabilities
 
-	Send(ctx context.Context, m *Message) (string, string, error)
+	Send(ctx context.Context, m SendableMessage) (string, string, error)
 	ReSend(ctx context.Context, id string, recipients ...string) (string, string, error)
+	SendBatch(ctx context.Context, m *Message) (*BatchResult, error)
+	SendLocalized(ctx context.Context, m *Message, recipientLocales map[string]string, opts *LocalizedOptions) (*BatchResult, error)
+	UpdateBatchResult(ctx context.Context, result *BatchResult) error
+	SendABTest(ctx context.Context, name string, variants []ABVariant, recipients []ABRecipient) (*ABTest, error)
+	GetABTestReport(ctx context.Context, test *ABTest, metric string) (*ABTestReport, error)
+	GetMessageTimeline(ctx context.Context, id string) (*MessageTimeline, error)
 	NewMessage(from, subject, text string, to ...string) *Message
 	NewMIMEMessage(body io.ReadCloser, to ...string) *Message
 
@@ -138,25 +163,42 @@ type Mailgun interface {
 	DeleteBounceList(ctx context.Context) error
 
 	GetStats(ctx context.Context, events []string, opts *GetStatOptions) ([]Stats, error)
+	GetAccountStats(ctx context.Context, domains []string, events []string, opts *GetStatOptions) ([]Stats, error)
+	GetReputationSnapshot(ctx context.Context, domain string, opts *ReputationOptions) (ReputationSnapshot, error)
+	MonitorRates(ctx context.Context, domain string, opts *RateMonitorOptions, onAlert RateAlertFunc) *RateMonitor
 	GetTag(ctx context.Context, tag string) (Tag, error)
 	DeleteTag(ctx context.Context, tag string) error
 	ListTags(*ListTagOptions) *TagIterator
 
 	ListDomains(opts *ListOptions) *DomainsIterator
+	ListDomainsWithOptions(opts *ListDomainOptions) *DomainsIterator
 	GetDomain(ctx context.Context, domain string) (DomainResponse, error)
+	Healthy(ctx context.Context) error
 	CreateDomain(ctx context.Context, name string, opts *CreateDomainOptions) (DomainResponse, error)
+	CreateDomainsBatch(ctx context.Context, specs []DomainSpec, concurrency int) []DomainResult
+	CreateDomainsBatchWithOptions(ctx context.Context, specs []DomainSpec, opts DomainsBatchOptions) ([]DomainResult, BulkSummary)
 	DeleteDomain(ctx context.Context, name string) error
+	DecommissionDomain(ctx context.Context, domain string, opts *DecommissionOptions) ([]DecommissionStep, error)
 	VerifyDomain(ctx context.Context, name string) (string, error)
+	CheckDomainDNS(ctx context.Context, domain string, resolver DNSResolver) (DNSReport, error)
+	PreflightProduction(ctx context.Context, domain string, opts *PreflightOptions) (PreflightReport, error)
 	UpdateDomainConnection(ctx context.Context, domain string, dc DomainConnection) error
 	GetDomainConnection(ctx context.Context, domain string) (DomainConnection, error)
 	GetDomainTracking(ctx context.Context, domain string) (DomainTracking, error)
 	UpdateClickTracking(ctx context.Context, domain, active string) error
 	UpdateUnsubscribeTracking(ctx context.Context, domain, active, htmlFooter, textFooter string) error
 	UpdateOpenTracking(ctx context.Context, domain, active string) error
+	UpdateDomainDKIMAuthority(ctx context.Context, domain string, self bool) (DKIMAuthorityResponse, error)
+	PauseSending(ctx context.Context, domain string, opts *PauseOptions) (SendingStatus, error)
+	ResumeSending(ctx context.Context, domain string) (SendingStatus, error)
 
 	GetStoredMessage(ctx context.Context, url string) (StoredMessage, error)
 	GetStoredMessageRaw(ctx context.Context, id string) (StoredMessageRaw, error)
 	GetStoredAttachment(ctx context.Context, url string) ([]byte, error)
+	DeleteStoredMessage(ctx context.Context, url string) error
+	DeleteScheduledMessages(ctx context.Context, domain string) error
+	GetMessageStorage(ctx context.Context, id string) (MessageStorage, error)
+	EventStorage(e Event) (MessageStorage, bool)
 
 	// Deprecated
 	GetStoredMessageForURL(ctx context.Context, url string) (StoredMessage, error)
@@ -184,27 +226,42 @@ type Mailgun interface {
 	CreateRoute(ctx context.Context, address Route) (Route, error)
 	DeleteRoute(ctx context.Context, address string) error
 	UpdateRoute(ctx context.Context, address string, r Route) (Route, error)
+	ReorderRoutes(ctx context.Context, ids []string) error
+	EnsureInboundForwarding(ctx context.Context, domain, targetURL string) (InboundForwarding, error)
 
 	ListWebhooks(ctx context.Context) (map[string][]string, error)
 	CreateWebhook(ctx context.Context, kind string, url []string) error
 	DeleteWebhook(ctx context.Context, kind string) error
 	GetWebhook(ctx context.Context, kind string) ([]string, error)
+	ListWebhookFailures(ctx context.Context, opts *ListEventOptions) ([]WebhookFailure, error)
+	TestWebhook(ctx context.Context, domain, kind, url string) (*WebhookTestResult, error)
 	UpdateWebhook(ctx context.Context, kind string, url []string) error
 	VerifyWebhookRequest(req *http.Request) (verified bool, err error)
 	VerifyWebhookSignature(sig Signature) (verified bool, err error)
 
 	ListMailingLists(opts *ListOptions) *ListsIterator
-	CreateMailingList(ctx context.Context, address MailingList) (MailingList, error)
+	ListMailingListsWithOptions(opts *ListsOptions) *ListsIterator
+	CreateMailingList(ctx context.Context, address MailingList) (ListResponse, error)
 	DeleteMailingList(ctx context.Context, address string) error
+	ArchiveMailingList(ctx context.Context, addr string, w io.Writer) (*MailingListArchive, error)
+	RestoreMailingList(ctx context.Context, archive *MailingListArchive) error
+	GetListMembershipChanges(ctx context.Context, addr string, since time.Time) ([]MembershipChange, error)
+	WatchList(ctx context.Context, addr string, interval time.Duration) *ListWatcher
+	TrackEngagement(ctx context.Context, opts *EngagementOptions) *EngagementTracker
 	GetMailingList(ctx context.Context, address string) (MailingList, error)
-	UpdateMailingList(ctx context.Context, address string, ml MailingList) (MailingList, error)
+	WaitForMailingList(ctx context.Context, addr string, timeout time.Duration) (MailingList, error)
+	UpdateMailingList(ctx context.Context, address string, ml MailingList) (ListResponse, error)
 
 	ListMembers(address string, opts *ListOptions) *MemberListIterator
+	ExportAllMembers(ctx context.Context, w io.Writer, format ExportFormat) error
 	GetMember(ctx context.Context, MemberAddr, listAddr string) (Member, error)
 	CreateMember(ctx context.Context, merge bool, addr string, prototype Member) error
+	UpsertMember(ctx context.Context, listAddr string, prototype Member) (Member, error)
+	MemberExists(ctx context.Context, memberAddr, listAddr string) (bool, error)
 	CreateMemberList(ctx context.Context, subscribed *bool, addr string, newMembers []interface{}) error
 	UpdateMember(ctx context.Context, Member, list string, prototype Member) (Member, error)
 	DeleteMember(ctx context.Context, Member, list string) error
+	EraseRecipient(ctx context.Context, address string) (*ErasureReport, error)
 
 	ListEventsWithDomain(opts *ListEventOptions, domain string) *EventIterator
 	ListEvents(*ListEventOptions) *EventIterator
@@ -219,6 +276,7 @@ type Mailgun interface {
 	ListExports(ctx context.Context, url string) ([]Export, error)
 	GetExport(ctx context.Context, id string) (Export, error)
 	GetExportLink(ctx context.Context, id string) (string, error)
+	DownloadExport(ctx context.Context, id string) (*ExportIterator, error)
 	CreateExport(ctx context.Context, url string) error
 
 	GetTagLimits(ctx context.Context, domain string) (TagLimits, error)
@@ -234,26 +292,140 @@ type Mailgun interface {
 	UpdateTemplateVersion(ctx context.Context, templateName string, version *TemplateVersion) error
 	DeleteTemplateVersion(ctx context.Context, templateName, tag string) error
 	ListTemplateVersions(templateName string, opts *ListOptions) *TemplateVersionsIterator
+	SyncTemplates(ctx context.Context, dir string, opts *SyncTemplatesOptions) ([]TemplateChange, error)
+
+	GetAccount(ctx context.Context) (Account, error)
+	UpdateAccount(ctx context.Context, opts UpdateAccountOptions) error
+	ExportAccountConfig(ctx context.Context, w io.Writer) (*AccountConfig, error)
+	ApplyAccountConfig(ctx context.Context, cfg *AccountConfig, opts *ApplyConfigOptions) ([]ConfigChange, error)
+	GetWebhookSigningKey(ctx context.Context) (string, error)
+	RegenerateWebhookSigningKey(ctx context.Context) (string, error)
+	ListAuthorizedRecipients(ctx context.Context) ([]AuthorizedRecipient, error)
+	AddAuthorizedRecipient(ctx context.Context, email string) (AuthorizedRecipient, error)
+	DeleteAuthorizedRecipient(ctx context.Context, email string) error
+
+	ListIPAllowlist(ctx context.Context) ([]IPAllowlistEntry, error)
+	AddIPAllowlist(ctx context.Context, entry IPAllowlistEntry) error
+	UpdateIPAllowlist(ctx context.Context, entry IPAllowlistEntry) error
+	DeleteIPAllowlist(ctx context.Context, address string) error
+
+	ListUsers(ctx context.Context, opts *ListUsersOptions) ([]User, error)
+	GetUser(ctx context.Context, id string) (User, error)
 }
 
 // MailgunImpl bundles data needed by a large number of methods in order to interact with the Mailgun API.
 // Colloquially, we refer to instances of this structure as "clients."
+//
+// A MailgunImpl is safe for concurrent use by multiple goroutines, including
+// calls to SetClient() and SetAPIBase() while requests are in flight; requests
+// which have already started continue with the settings they began with.
+// Create one client and share it, rather than creating a client per request,
+// so the underlying HTTP connections can be reused. See NewHTTPClient() for
+// tuning the connection pool.
 type MailgunImpl struct {
+	mu sync.RWMutex
+	clientSettings
+}
+
+// clientSettings is the state of a MailgunImpl, which its mu guards. It is kept apart from the
+// mutex so `clone()` copies all of it, including settings added later.
+type clientSettings struct {
 	apiBase string
 	domain  string
 	apiKey  string
 	client  *http.Client
 	baseURL string
+
+	listHeaders bool
+	listCache   map[string]*MailingList
+
+	listConsistencyWait time.Duration
+	createdLists        map[string]time.Time
+
+	sendRetries  int
+	onFailedSend FailedSendHandler
+
+	messageRetention time.Duration
+
+	idempotencyWindow time.Duration
+	idempotencyStore  IdempotencyStore
+
+	disableCompression bool
+	maxResponseSize    int64
+	requestTimeout     time.Duration
+	deadlineHeader     string
+	defaultPageSize    int
+	userAgent          string
+	headers            map[string]string
+	onDeprecation      DeprecationHandler
+	auditHook          AuditHook
+	timingHook         TimingHook
+	breaker            *circuitBreaker
+	codec              JSONCodec
+
+	bulk       *bulkLimiter
+	rateBudget *RateBudget
+
+	webhookSigningKeys []string
+
+	guard      *SuppressionGuardOptions
+	guardCache map[string]*guardEntry
+
+	cache        *responseCache
+	revalidation *revalidationCache
+
+	transformers []MIMETransformer
+	sendDefaults map[string]SendDefaults
+	health       *healthCheck
+	clk          clock
+
+	attachmentPolicies []AttachmentPolicy
+	contentFilters     []ContentFilter
+	deletionProtection bool
+	pausedDomains      map[string]time.Time
 }
 
 // NewMailGun creates a new client instance.
 func NewMailgun(domain, apiKey string) *MailgunImpl {
-	return &MailgunImpl{
+	return &MailgunImpl{clientSettings: clientSettings{
 		apiBase: APIBase,
 		domain:  domain,
 		apiKey:  apiKey,
 		client:  http.DefaultClient,
+	}}
+}
+
+// clone returns a client with the settings of mg. The bulk limiter, circuit breaker, response
+// cache and other state with locks of their own are shared with mg; the maps mg changes in place
+// are copied, and the list header and suppression guard caches start empty.
+func (mg *MailgunImpl) clone() *MailgunImpl {
+	// Create the bulk limiter now, so requests of both clients are limited together
+	mg.bulkLimiter()
+	mg.mu.RLock()
+	defer mg.mu.RUnlock()
+
+	c := &MailgunImpl{clientSettings: mg.clientSettings}
+	c.listCache, c.guardCache = nil, nil
+	c.createdLists = copyTimes(mg.createdLists)
+	c.pausedDomains = copyTimes(mg.pausedDomains)
+	if mg.sendDefaults != nil {
+		c.sendDefaults = make(map[string]SendDefaults, len(mg.sendDefaults))
+		for domain, d := range mg.sendDefaults {
+			c.sendDefaults[domain] = d
+		}
 	}
+	return c
+}
+
+func copyTimes(m map[string]time.Time) map[string]time.Time {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]time.Time, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
 }
 
 // NewMailgunFromEnv returns a new Mailgun client using the environment variables
@@ -280,6 +452,8 @@ func NewMailgunFromEnv() (*MailgunImpl, error) {
 
 // APIBase returns the API Base URL configured for this client.
 func (mg *MailgunImpl) APIBase() string {
+	mg.mu.RLock()
+	defer mg.mu.RUnlock()
 	return mg.apiBase
 }
 
@@ -295,12 +469,107 @@ func (mg *MailgunImpl) APIKey() string {
 
 // Client returns the HTTP client configured for this client.
 func (mg *MailgunImpl) Client() *http.Client {
+	mg.mu.RLock()
+	defer mg.mu.RUnlock()
 	return mg.client
 }
 
 // SetClient updates the HTTP client for this client.
 func (mg *MailgunImpl) SetClient(c *http.Client) {
+	mg.mu.Lock()
 	mg.client = c
+	mg.mu.Unlock()
+}
+
+// SetCompression enables or disables gzip compression of responses, which is enabled by default.
+// Compression trades a little CPU for much less bandwidth on large pages of events or members.
+func (mg *MailgunImpl) SetCompression(enabled bool) {
+	mg.mu.Lock()
+	mg.disableCompression = !enabled
+	mg.mu.Unlock()
+}
+
+// SetMaxResponseSize limits the size of responses the client reads, after decompression. Requests
+// whose responses are larger fail with ErrResponseTooLarge. Zero, the default, does not limit
+// responses. Pages of events or members can be several megabytes, so leave ample headroom.
+func (mg *MailgunImpl) SetMaxResponseSize(bytes int64) {
+	mg.mu.Lock()
+	mg.maxResponseSize = bytes
+	mg.mu.Unlock()
+}
+
+// SetUserAgent appends identifiers of the application, such as "billing/1.4.2", to the User-Agent
+// sent with every request, after MailgunGoUserAgent, so that Mailgun support and proxies can
+// attribute the traffic of the client. Pass no identifiers to send MailgunGoUserAgent alone.
+//
+//  mg.SetUserAgent("billing/1.4.2", "(+https://billing.example.com)")
+func (mg *MailgunImpl) SetUserAgent(products ...string) {
+	ua := MailgunGoUserAgent
+	for _, p := range products {
+		if p = strings.TrimSpace(p); p != "" {
+			ua += " " + p
+		}
+	}
+	mg.mu.Lock()
+	mg.userAgent = ua
+	mg.mu.Unlock()
+}
+
+// UserAgent returns the User-Agent sent with every request.
+func (mg *MailgunImpl) UserAgent() string {
+	mg.mu.RLock()
+	defer mg.mu.RUnlock()
+	if mg.userAgent == "" {
+		return MailgunGoUserAgent
+	}
+	return mg.userAgent
+}
+
+// SetHeader sets a header sent with every request, such as the credentials of an API gateway
+// which Mailgun traffic is routed through. Headers set by a request itself, such as its
+// Content-Type, are kept. Pass an empty value to stop sending the header.
+//
+//  mg.SetAPIBase("https://gateway.example.com/mailgun/v3")
+//  mg.SetHeader("X-Gateway-Token", os.Getenv("GATEWAY_TOKEN"))
+func (mg *MailgunImpl) SetHeader(name, value string) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	// Requests hold on to the map, so it is copied rather than changed
+	headers := make(map[string]string, len(mg.headers)+1)
+	for k, v := range mg.headers {
+		headers[k] = v
+	}
+	if value == "" {
+		delete(headers, http.CanonicalHeaderKey(name))
+	} else {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	mg.headers = headers
+}
+
+// ErrResponseTooLarge is returned by requests whose response exceeds the size set with `SetMaxResponseSize()`.
+var ErrResponseTooLarge = errors.New("response exceeds the maximum response size")
+
+// requestOptions returns the settings of the client applied to each request.
+func (mg *MailgunImpl) requestOptions() requestOptions {
+	mg.mu.RLock()
+	defer mg.mu.RUnlock()
+	return requestOptions{
+		disableCompression: mg.disableCompression,
+		maxResponseSize:    mg.maxResponseSize,
+		requestTimeout:     mg.requestTimeout,
+		deadlineHeader:     mg.deadlineHeader,
+		breaker:            mg.breaker,
+		codec:              mg.codec,
+		cache:              mg.cache,
+		revalidation:       mg.revalidation,
+		userAgent:          mg.userAgent,
+		headers:            mg.headers,
+		onDeprecation:      mg.onDeprecation,
+		onAudit:            mg.auditHook,
+		onTiming:           mg.timingHook,
+		rateBudget:         mg.rateBudget,
+	}
 }
 
 // SetAPIBase updates the API Base URL for this client.
@@ -312,24 +581,35 @@ func (mg *MailgunImpl) SetClient(c *http.Client) {
 //
 //  // Set a custom base API
 //  mg.SetAPIBase("https://localhost/v3")
+//
+//  // Route requests through a gateway, under a path prefix
+//  mg.SetAPIBase("https://gateway.example.com/mailgun/v3")
 func (mg *MailgunImpl) SetAPIBase(address string) {
-	mg.apiBase = address
+	mg.mu.Lock()
+	mg.apiBase = strings.TrimSuffix(address, "/")
+	mg.mu.Unlock()
+}
+
+// pathEscape escapes s to be used as a segment of the path of an API url, such as the address of a
+// mailing list. Plus signs are escaped as well, as Mailgun would decode them as spaces.
+func pathEscape(s string) string {
+	return strings.Replace(url.PathEscape(s), "+", "%2B", -1)
 }
 
 // generateApiUrl renders a URL for an API endpoint using the domain and endpoint name.
 func generateApiUrl(m Mailgun, endpoint string) string {
-	return fmt.Sprintf("%s/%s/%s", m.APIBase(), m.Domain(), endpoint)
+	return fmt.Sprintf("%s/%s/%s", m.APIBase(), pathEscape(m.Domain()), endpoint)
 }
 
 // generateApiUrlWithDomain renders a URL for an API endpoint using a separate domain and endpoint name.
 func generateApiUrlWithDomain(m Mailgun, endpoint, domain string) string {
-	return fmt.Sprintf("%s/%s/%s", m.APIBase(), domain, endpoint)
+	return fmt.Sprintf("%s/%s/%s", m.APIBase(), pathEscape(domain), endpoint)
 }
 
 // generateMemberApiUrl renders a URL relevant for specifying mailing list members.
 // The address parameter refers to the mailing list in question.
 func generateMemberApiUrl(m Mailgun, endpoint, address string) string {
-	return fmt.Sprintf("%s/%s/%s/members", m.APIBase(), endpoint, address)
+	return fmt.Sprintf("%s/%s/%s/members", m.APIBase(), endpoint, pathEscape(address))
 }
 
 // generateApiUrlWithTarget works as generateApiUrl,
@@ -337,7 +617,7 @@ func generateMemberApiUrl(m Mailgun, endpoint, address string) string {
 func generateApiUrlWithTarget(m Mailgun, endpoint, target string) string {
 	tail := ""
 	if target != "" {
-		tail = fmt.Sprintf("/%s", target)
+		tail = fmt.Sprintf("/%s", pathEscape(target))
 	}
 	return fmt.Sprintf("%s%s", generateApiUrl(m, endpoint), tail)
 }
@@ -347,31 +627,56 @@ func generateApiUrlWithTarget(m Mailgun, endpoint, target string) string {
 // Most URLs consume a domain in the 2nd position, but some endpoints
 // require the word "domains" to be there instead.
 func generateDomainApiUrl(m Mailgun, endpoint string) string {
-	return fmt.Sprintf("%s/domains/%s/%s", m.APIBase(), m.Domain(), endpoint)
+	return generateDomainApiUrlWithDomain(m, endpoint, m.Domain())
+}
+
+// generateDomainApiUrlWithDomain works as generateDomainApiUrl, using a separate domain.
+func generateDomainApiUrlWithDomain(m Mailgun, endpoint, domain string) string {
+	return fmt.Sprintf("%s/domains/%s/%s", m.APIBase(), pathEscape(domain), endpoint)
 }
 
 // generateCredentialsUrl renders a URL as generateDomainApiUrl,
 // but focuses on the SMTP credentials family of API functions.
 func generateCredentialsUrl(m Mailgun, login string) string {
+	return generateCredentialsUrlWithDomain(m, login, m.Domain())
+}
+
+// generateCredentialsUrlWithDomain works as generateCredentialsUrl, using a separate domain.
+func generateCredentialsUrlWithDomain(m Mailgun, login, domain string) string {
 	tail := ""
 	if login != "" {
-		tail = fmt.Sprintf("/%s", login)
+		tail = fmt.Sprintf("/%s", pathEscape(login))
 	}
-	return generateDomainApiUrl(m, fmt.Sprintf("credentials%s", tail))
+	return generateDomainApiUrlWithDomain(m, fmt.Sprintf("credentials%s", tail), domain)
 	// return fmt.Sprintf("%s/domains/%s/credentials%s", apiBase, m.Domain(), tail)
 }
 
 // generateStoredMessageUrl generates the URL needed to acquire a copy of a stored message.
 func generateStoredMessageUrl(m Mailgun, endpoint, id string) string {
-	return generateDomainApiUrl(m, fmt.Sprintf("%s/%s", endpoint, id))
+	return generateDomainApiUrl(m, fmt.Sprintf("%s/%s", endpoint, pathEscape(id)))
 	// return fmt.Sprintf("%s/domains/%s/%s/%s", apiBase, m.Domain(), endpoint, id)
 }
 
 // generatePublicApiUrl works as generateApiUrl, except that generatePublicApiUrl has no need for the domain.
+// Endpoints registered in endpointVersions are addressed with their own API version.
 func generatePublicApiUrl(m Mailgun, endpoint string) string {
+	name := endpoint
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		name = name[:i]
+	}
+	if version, ok := endpointVersions[name]; ok {
+		return generateVersionedApiUrl(m, version, endpoint)
+	}
 	return fmt.Sprintf("%s/%s", m.APIBase(), endpoint)
 }
 
+// generateVersionedApiUrl works as generatePublicApiUrl, but replaces the API version at the end of
+// the API base with version, for endpoints which are only available from a different version of the API.
+func generateVersionedApiUrl(m Mailgun, version, endpoint string) string {
+	base := apiVersionSuffix.ReplaceAllString(m.APIBase(), "")
+	return fmt.Sprintf("%s/%s/%s", base, version, endpoint)
+}
+
 // generateParameterizedUrl works as generateApiUrl, but supports query parameters.
 func generateParameterizedUrl(m Mailgun, endpoint string, payload payload) (string, error) {
 	paramBuffer, err := payload.getPayloadBuffer()
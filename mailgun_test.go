@@ -2,7 +2,9 @@ package mailgun_test
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/facebookgo/ensure"
@@ -31,5 +33,89 @@ func TestInvalidBaseAPI(t *testing.T) {
 	ctx := context.Background()
 	_, err := mg.GetDomain(ctx, "unknown.domain")
 	ensure.NotNil(t, err)
-	ensure.DeepEqual(t, err.Error(), `BaseAPI must end with a /v2, /v3 or /v4; setBaseAPI("https://host/v3")`)
+	ensure.DeepEqual(t, err.Error(), `BaseAPI must end with a /v2, /v3, /v4 or /v5; setBaseAPI("https://host/v3")`)
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	mg.SetMaxResponseSize(10)
+	_, err := mg.GetTagLimits(ctx, testDomain)
+	ensure.DeepEqual(t, err, mailgun.ErrResponseTooLarge)
+
+	// Error responses are limited as well
+	_, err = mg.GetBounce(ctx, "unknown@mailgun.test")
+	ensure.DeepEqual(t, err, mailgun.ErrResponseTooLarge)
+
+	mg.SetMaxResponseSize(1 << 20)
+	limits, err := mg.GetTagLimits(ctx, testDomain)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, limits.Limit, 50000)
+}
+
+func TestUserAgent(t *testing.T) {
+	var agents []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.Header.Get("User-Agent"))
+		fmt.Fprint(w, `{"domain": {"name": "example.com"}}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+	ensure.DeepEqual(t, mg.UserAgent(), mailgun.MailgunGoUserAgent)
+
+	_, err := mg.GetDomain(ctx, "example.com")
+	ensure.Nil(t, err)
+	mg.SetUserAgent("billing/1.4.2", " ", "(+https://billing.example.com)")
+	_, err = mg.GetDomain(ctx, "example.com")
+	ensure.Nil(t, err)
+	err = mg.DeleteDomain(ctx, "example.com")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, agents, []string{
+		mailgun.MailgunGoUserAgent,
+		mailgun.MailgunGoUserAgent + " billing/1.4.2 (+https://billing.example.com)",
+		mailgun.MailgunGoUserAgent + " billing/1.4.2 (+https://billing.example.com)",
+	})
+}
+
+func TestGatewayBaseAndHeaders(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path+" "+r.Header.Get("X-Gateway-Token"))
+		if r.URL.Path == "/mailgun/v3/"+testDomain+"/events" && r.URL.Query().Get("page") == "" {
+			// Mailgun links to pages on its own host, without the prefix of the gateway
+			fmt.Fprintf(w, `{"items": [{"event": "delivered"}],
+				"paging": {"next": "https://api.mailgun.net/v3/%s/events?page=next"}}`, testDomain)
+			return
+		}
+		fmt.Fprint(w, `{"items": [], "paging": {}}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/mailgun/v3/")
+	mg.SetHeader("x-gateway-token", "secret")
+	ctx := context.Background()
+
+	it := mg.ListEvents(nil)
+	var page []mailgun.Event
+	var count int
+	for it.Next(ctx, &page) {
+		count += len(page)
+	}
+	ensure.Nil(t, it.Err())
+	ensure.DeepEqual(t, count, 1)
+
+	mg.SetHeader("X-Gateway-Token", "")
+	_, err := mg.GetTag(ctx, "newsletter")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, requests, []string{
+		"/mailgun/v3/" + testDomain + "/events secret",
+		"/mailgun/v3/" + testDomain + "/events secret",
+		"/mailgun/v3/" + testDomain + "/tags/newsletter ",
+	})
 }
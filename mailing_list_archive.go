@@ -0,0 +1,91 @@
+package mailgun
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MailingListArchive holds everything needed to recreate a mailing list deleted with
+// `ArchiveMailingList()`.
+type MailingListArchive struct {
+	List       MailingList `json:"list"`
+	Members    []Member    `json:"members"`
+	ArchivedAt time.Time   `json:"archived_at"`
+}
+
+// ArchiveMailingList fetches the mailing list at addr and all of its members, writes them to w
+// as a JSON MailingListArchive, and only then deletes the list, so an accidental deletion can be
+// undone with `RestoreMailingList()`. If w is nil, nothing is written, and the returned archive
+// is the only copy. If fetching or writing fails, the list is not deleted. For a CSV of the
+// members alone, see `ExportMembersCSV()`.
+//
+//  f, err := os.Create("news@example.com.json")
+//  _, err = mg.ArchiveMailingList(ctx, "news@example.com", f)
+func (mg *MailgunImpl) ArchiveMailingList(ctx context.Context, addr string, w io.Writer) (*MailingListArchive, error) {
+	list, err := mg.GetMailingList(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	members, err := mg.ListMembersAll(ctx, addr, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while fetching the members of '%s'", addr)
+	}
+
+	archive := &MailingListArchive{List: list, Members: members, ArchivedAt: time.Now().UTC()}
+	if w != nil {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(archive); err != nil {
+			return nil, errors.Wrapf(err, "while writing the archive of '%s'", addr)
+		}
+	}
+
+	if err := mg.DeleteMailingList(ctx, addr); err != nil {
+		return archive, err
+	}
+	return archive, nil
+}
+
+// ReadMailingListArchive reads an archive written by `ArchiveMailingList()`.
+func ReadMailingListArchive(r io.Reader) (*MailingListArchive, error) {
+	var archive MailingListArchive
+	if err := json.NewDecoder(r).Decode(&archive); err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// RestoreMailingList recreates a mailing list from an archive made by `ArchiveMailingList()`,
+// with its name, description, access level and members. Members are added in batches, updating
+// any already on the list, so a restore which failed part way may be retried.
+func (mg *MailgunImpl) RestoreMailingList(ctx context.Context, archive *MailingListArchive) error {
+	addr := archive.List.Address
+	if _, err := mg.GetMailingList(ctx, addr); err != nil {
+		if GetStatusFromErr(err) != http.StatusNotFound {
+			return err
+		}
+		if _, err := mg.CreateMailingList(ctx, archive.List); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(archive.Members); start += MaxMembersPerRequest {
+		end := start + MaxMembersPerRequest
+		if end > len(archive.Members) {
+			end = len(archive.Members)
+		}
+		batch := make([]interface{}, 0, end-start)
+		for _, m := range archive.Members[start:end] {
+			batch = append(batch, m)
+		}
+		if err := mg.CreateMemberList(ctx, &yes, addr, batch); err != nil {
+			return errors.Wrapf(err, "while restoring members %d to %d of '%s'", start+1, end, addr)
+		}
+	}
+	return nil
+}
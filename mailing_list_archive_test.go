@@ -0,0 +1,57 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestArchiveMailingList(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	address := randomEmail("archive", testDomain)
+	_, err := mg.CreateMailingList(ctx, mailgun.MailingList{
+		Address:     address,
+		Name:        "Archived",
+		Description: "A list to archive",
+		AccessLevel: mailgun.AccessLevelReadOnly,
+	})
+	ensure.Nil(t, err)
+	ensure.Nil(t, mg.CreateMemberList(ctx, nil, address, []interface{}{
+		mailgun.Member{Address: "one@example.com", Name: "One", Vars: map[string]interface{}{"plan": "pro"}},
+		mailgun.Member{Address: "two@example.com", Subscribed: mailgun.Unsubscribed},
+	}))
+
+	var buf bytes.Buffer
+	archive, err := mg.ArchiveMailingList(ctx, address, &buf)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(archive.Members), 2)
+	_, err = mg.GetMailingList(ctx, address)
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), 404)
+
+	read, err := mailgun.ReadMailingListArchive(&buf)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, read.List.Name, "Archived")
+	ensure.DeepEqual(t, len(read.Members), 2)
+
+	ensure.Nil(t, mg.RestoreMailingList(ctx, read))
+	defer mg.DeleteMailingList(ctx, address)
+
+	list, err := mg.GetMailingList(ctx, address)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, list.Description, "A list to archive")
+	ensure.DeepEqual(t, list.AccessLevel, mailgun.AccessLevel(mailgun.AccessLevelReadOnly))
+
+	member, err := mg.GetMember(ctx, "one@example.com", address)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, member.Name, "One")
+	ensure.DeepEqual(t, member.Vars["plan"], "pro")
+
+	_, err = mg.ArchiveMailingList(ctx, "missing@"+testDomain, &buf)
+	ensure.NotNil(t, err)
+}
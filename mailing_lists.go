@@ -2,7 +2,10 @@ package mailgun
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"strconv"
+	"strings"
 )
 
 // A mailing list may have one of three membership modes.
@@ -22,16 +25,43 @@ const (
 // Specify the access of a mailing list member
 type AccessLevel string
 
+// Where replies to messages distributed by a mailing list go, set as their Reply-To.
+const (
+	// ReplyPreferenceList sends replies to the list, so every member receives them. Replies are
+	// only accepted from those the access level of the list lets post: members of a "members"
+	// list, and nobody on a "readonly" list, whose replies bounce.
+	ReplyPreferenceList = "list"
+	// ReplyPreferenceSender sends replies to the sender of the message only.
+	ReplyPreferenceSender = "sender"
+)
+
+// Specify where replies to messages distributed by a mailing list go
+type ReplyPreference string
+
 // A List structure provides information for a mailing list.
 //
-// AccessLevel may be one of ReadOnly, Members, or Everyone.
+// AccessLevel may be one of ReadOnly, Members, or Everyone, and ReplyPreference one of List or
+// Sender.
 type MailingList struct {
-	Address      string      `json:"address,omitempty"`
-	Name         string      `json:"name,omitempty"`
-	Description  string      `json:"description,omitempty"`
-	AccessLevel  AccessLevel `json:"access_level,omitempty"`
-	CreatedAt    RFC2822Time `json:"created_at,omitempty"`
-	MembersCount int         `json:"members_count,omitempty"`
+	Address         string          `json:"address,omitempty"`
+	Name            string          `json:"name,omitempty"`
+	Description     string          `json:"description,omitempty"`
+	AccessLevel     AccessLevel     `json:"access_level,omitempty"`
+	ReplyPreference ReplyPreference `json:"reply_preference,omitempty"`
+	CreatedAt       RFC2822Time     `json:"created_at,omitempty"`
+	MembersCount    int             `json:"members_count,omitempty"`
+	// Extra holds the fields of the list returned by Mailgun which have no field of their own.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (l *MailingList) UnmarshalJSON(data []byte) error {
+	type plain MailingList
+	if err := json.Unmarshal(data, (*plain)(l)); err != nil {
+		return err
+	}
+	var err error
+	l.Extra, err = extraFields(data, plain{})
+	return err
 }
 
 type listsResponse struct {
@@ -43,28 +73,91 @@ type mailingListResponse struct {
 	MailingList MailingList `json:"member"`
 }
 
+// ListResponse is returned by `CreateMailingList()` and `UpdateMailingList()`. Message is the
+// confirmation text of Mailgun, and List the mailing list as Mailgun stored it, which may be
+// compared with the prototype to detect fields which were not changed.
+type ListResponse struct {
+	Message string      `json:"message"`
+	List    MailingList `json:"list"`
+}
+
 type ListsIterator struct {
 	listsResponse
-	mg  Mailgun
-	err error
+	mg       Mailgun
+	err      error
+	contains string
+	// fetched is the number of lists on the last page, before filtering
+	fetched int
+	// pages, if set, is the request of the pages endpoint to link the page fetched from the
+	// offset endpoint to, which returns no links of its own
+	pages *httpRequest
+}
+
+// ListsOptions specifies the mailing lists ListMailingListsWithOptions() iterates over. Mailgun returns lists
+// in the order of their addresses, and cannot sort them otherwise.
+type ListsOptions struct {
+	// Limit is the page size fetched from the api.
+	Limit int
+	// Skip, if set, skips that many lists before the first page. The first page is fetched from the
+	// offset endpoint of Mailgun; the pages after it are fetched by address as usual.
+	Skip int
+	// After, if set, starts the first page after the list with this address, such as the last
+	// address of a page fetched earlier. It is ignored if Skip is set.
+	After string
+	// Contains, if set, restricts the results to lists whose address or name contains it, ignoring case.
+	// Mailgun cannot filter lists, so all pages are fetched and filtered by the client; `Next()`
+	// skips pages with no matching lists.
+	Contains string
+	// Params are further query parameters sent with the request, such as a flag Mailgun supports
+	// which has no field of its own yet. Pages after the first are fetched from the links Mailgun
+	// returns, which carry the parameters Mailgun applied. Limit, Skip and After win over
+	// parameters of the same name.
+	Params map[string]string
 }
 
 // ListMailingLists returns the specified set of mailing lists administered by your account.
 func (mg *MailgunImpl) ListMailingLists(opts *ListOptions) *ListsIterator {
-	r := newHTTPRequest(generatePublicApiUrl(mg, listsEndpoint) + "/pages")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	if opts == nil {
+		return mg.ListMailingListsWithOptions(nil)
+	}
+	return mg.ListMailingListsWithOptions(&ListsOptions{Limit: opts.Limit, Params: opts.Params})
+}
+
+// ListMailingListsWithOptions returns the mailing lists administered by your account, starting
+// from a position or filtered as the options specify.
+func (mg *MailgunImpl) ListMailingListsWithOptions(opts *ListsOptions) *ListsIterator {
+	pages := generatePublicApiUrl(mg, listsEndpoint) + "/pages"
+	r := newAPIRequest(mg, pages)
+	var contains string
+	var skip bool
+	var limit int
 	if opts != nil {
-		if opts.Limit != 0 {
-			r.addParameter("limit", strconv.Itoa(opts.Limit))
+		r.addParameters(opts.Params)
+		limit = opts.Limit
+		if opts.Skip > 0 {
+			r.URL = generatePublicApiUrl(mg, listsEndpoint)
+			r.setParameter("skip", strconv.Itoa(opts.Skip))
+			skip = true
+		} else if opts.After != "" {
+			r.setParameter("page", "next")
+			r.setParameter("address", opts.After)
 		}
+		contains = strings.ToLower(opts.Contains)
 	}
+	mg.addPageLimit(r, limit, 0)
 	url, err := r.generateUrlWithParameters()
-	return &ListsIterator{
+	li := &ListsIterator{
 		mg:            mg,
 		listsResponse: listsResponse{Paging: Paging{Next: url, First: url}},
 		err:           err,
+		contains:      contains,
 	}
+	if skip {
+		li.pages = r
+		li.pages.URL = pages
+		delete(li.pages.Parameters, "skip")
+	}
+	return li
 }
 
 // If an error occurred during iteration `Err()` will return non nil
@@ -79,9 +172,14 @@ func (li *ListsIterator) Next(ctx context.Context, items *[]MailingList) bool {
 	if li.err != nil {
 		return false
 	}
-	li.err = li.fetch(ctx, li.Paging.Next)
-	if li.err != nil {
-		return false
+	for {
+		li.err = li.fetch(ctx, li.Paging.Next)
+		if li.err != nil {
+			return false
+		}
+		if len(li.Items) != 0 || li.fetched == 0 {
+			break
+		}
 	}
 	cpy := make([]MailingList, len(li.Items))
 	copy(cpy, li.Items)
@@ -151,23 +249,116 @@ func (li *ListsIterator) Previous(ctx context.Context, items *[]MailingList) boo
 }
 
 func (li *ListsIterator) fetch(ctx context.Context, url string) error {
-	r := newHTTPRequest(url)
-	r.setClient(li.mg.Client())
-	r.setBasicAuth(basicAuthUser, li.mg.APIKey())
+	if li.pages != nil {
+		var page listsResponse
+		if err := fetchPage(ctx, li.mg, url, &page); err != nil {
+			return err
+		}
+		li.listsResponse = page
+		if err := li.linkPages(); err != nil {
+			return err
+		}
+	} else if err := fetchPage(ctx, li.mg, url, &li.listsResponse); err != nil {
+		return err
+	}
+	li.fetched = len(li.Items)
+	if li.contains != "" {
+		matched := li.Items[:0]
+		for _, l := range li.Items {
+			if strings.Contains(strings.ToLower(l.Address), li.contains) || strings.Contains(strings.ToLower(l.Name), li.contains) {
+				matched = append(matched, l)
+			}
+		}
+		li.Items = matched
+	}
+	return nil
+}
 
-	return getResponseFromJSON(ctx, r, &li.listsResponse)
+// linkPages sets the links of a page fetched from the offset endpoint to those of the pages
+// endpoint, once; pages fetched from the pages endpoint have links of their own.
+func (li *ListsIterator) linkPages() error {
+	r := li.pages
+	li.pages = nil
+	if li.Paging != (Paging{}) {
+		return nil
+	}
+	link := func(page, address string) (string, error) {
+		p := newHTTPRequest(r.URL)
+		for name, values := range r.Parameters {
+			for _, v := range values {
+				p.addParameter(name, v)
+			}
+		}
+		p.addParameter("page", page)
+		if address != "" {
+			p.addParameter("address", address)
+		}
+		return p.generateUrlWithParameters()
+	}
+	var err error
+	if li.Paging.First, err = link("first", ""); err != nil {
+		return err
+	}
+	if li.Paging.Last, err = link("last", ""); err != nil {
+		return err
+	}
+	if len(li.Items) == 0 {
+		return nil
+	}
+	if li.Paging.Next, err = link("next", li.Items[len(li.Items)-1].Address); err != nil {
+		return err
+	}
+	li.Paging.Previous, err = link("prev", li.Items[0].Address)
+	return err
+}
+
+// InvalidMailingListError is returned by `CreateMailingList()` and `UpdateMailingList()` when a
+// field of the prototype has a value Mailgun does not accept; nothing is sent.
+type InvalidMailingListError struct {
+	// Field is the name of the field in the api, such as "access_level".
+	Field string
+	Value string
+	// Allowed are the values the field accepts.
+	Allowed []string
+}
+
+func (e *InvalidMailingListError) Error() string {
+	return fmt.Sprintf("invalid %s '%s' for mailing list; allowed values are %s", e.Field, e.Value, strings.Join(e.Allowed, ", "))
+}
+
+// validateMailingList returns an *InvalidMailingListError if a field of the prototype is set to a
+// value Mailgun does not accept.
+func validateMailingList(prototype MailingList) error {
+	check := func(field, value string, allowed ...string) error {
+		if value == "" {
+			return nil
+		}
+		for _, a := range allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return &InvalidMailingListError{Field: field, Value: value, Allowed: allowed}
+	}
+	if err := check("access_level", string(prototype.AccessLevel), AccessLevelReadOnly, AccessLevelMembers, AccessLevelEveryone); err != nil {
+		return err
+	}
+	return check("reply_preference", string(prototype.ReplyPreference), ReplyPreferenceList, ReplyPreferenceSender)
 }
 
 // CreateMailingList creates a new mailing list under your Mailgun account.
 // You need specify only the Address and Name members of the prototype;
-// Description, and AccessLevel are optional.
+// Description, AccessLevel and ReplyPreference are optional.
 // If unspecified, Description remains blank,
-// while AccessLevel defaults to Everyone.
-func (mg *MailgunImpl) CreateMailingList(ctx context.Context, prototype MailingList) (MailingList, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, listsEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	p := newUrlEncodedPayload()
+// while AccessLevel defaults to Everyone, and ReplyPreference to List.
+// An AccessLevel or ReplyPreference Mailgun does not accept is returned
+// as an *InvalidMailingListError.
+func (mg *MailgunImpl) CreateMailingList(ctx context.Context, prototype MailingList) (ListResponse, error) {
+	if err := validateMailingList(prototype); err != nil {
+		return ListResponse{}, err
+	}
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, listsEndpoint))
+	p := newPayload()
 	if prototype.Address != "" {
 		p.addValue("address", prototype.Address)
 	}
@@ -180,53 +371,71 @@ func (mg *MailgunImpl) CreateMailingList(ctx context.Context, prototype MailingL
 	if prototype.AccessLevel != "" {
 		p.addValue("access_level", string(prototype.AccessLevel))
 	}
+	if prototype.ReplyPreference != "" {
+		p.addValue("reply_preference", string(prototype.ReplyPreference))
+	}
 	response, err := makePostRequest(ctx, r, p)
 	if err != nil {
-		return MailingList{}, err
+		return ListResponse{}, err
+	}
+	var resp ListResponse
+	if err = response.parseFromJSON(&resp); err != nil {
+		return resp, err
 	}
-	var l MailingList
-	err = response.parseFromJSON(&l)
-	return l, err
+	mg.listCreated(prototype.Address)
+	mg.forgetList(prototype.Address)
+	return resp, nil
 }
 
 // DeleteMailingList removes all current members of the list, then removes the list itself.
 // Attempts to send e-mail to the list will fail subsequent to this call.
+// Clients with deletion protection require the deletion to be confirmed (see `SetDeletionProtection()`).
 func (mg *MailgunImpl) DeleteMailingList(ctx context.Context, addr string) error {
-	r := newHTTPRequest(generatePublicApiUrl(mg, listsEndpoint) + "/" + addr)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	if err := mg.checkDeletion(ctx, addr); err != nil {
+		return err
+	}
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, listsEndpoint)+"/"+pathEscape(addr))
 	_, err := makeDeleteRequest(ctx, r)
+	if err == nil {
+		mg.forgetCreatedList(addr)
+		mg.forgetList(addr)
+	}
 	return err
 }
 
 // GetMailingList allows your application to recover the complete List structure
 // representing a mailing list, so long as you have its e-mail address.
+// A list the client created recently is polled for while it propagates; see
+// `SetListConsistencyWait()`.
 func (mg *MailgunImpl) GetMailingList(ctx context.Context, addr string) (MailingList, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, listsEndpoint) + "/" + addr)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	response, err := makeGetRequest(ctx, r)
-	if err != nil {
-		return MailingList{}, err
+	if deadline, ok := mg.listConsistencyDeadline(addr); ok {
+		return mg.pollMailingList(ctx, addr, deadline)
 	}
+	return mg.getMailingList(ctx, addr)
+}
 
+func (mg *MailgunImpl) getMailingList(ctx context.Context, addr string) (MailingList, error) {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, listsEndpoint)+"/"+pathEscape(addr))
 	var resp mailingListResponse
-	err = response.parseFromJSON(&resp)
+	err := getResponseFromJSON(ctx, r, &resp)
 	return resp.MailingList, err
 }
 
 // UpdateMailingList allows you to change various attributes of a list.
-// Address, Name, Description, and AccessLevel are all optional;
+// Address, Name, Description, AccessLevel and ReplyPreference are all optional;
 // only those fields which are set in the prototype will change.
+// An AccessLevel or ReplyPreference Mailgun does not accept is returned
+// as an *InvalidMailingListError.
 //
 // Be careful!  If changing the address of a mailing list,
 // e-mail sent to the old address will not succeed.
 // Make sure you account for the change accordingly.
-func (mg *MailgunImpl) UpdateMailingList(ctx context.Context, addr string, prototype MailingList) (MailingList, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, listsEndpoint) + "/" + addr)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	p := newUrlEncodedPayload()
+func (mg *MailgunImpl) UpdateMailingList(ctx context.Context, addr string, prototype MailingList) (ListResponse, error) {
+	if err := validateMailingList(prototype); err != nil {
+		return ListResponse{}, err
+	}
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, listsEndpoint)+"/"+pathEscape(addr))
+	p := newPayload()
 	if prototype.Address != "" {
 		p.addValue("address", prototype.Address)
 	}
@@ -239,11 +448,15 @@ func (mg *MailgunImpl) UpdateMailingList(ctx context.Context, addr string, proto
 	if prototype.AccessLevel != "" {
 		p.addValue("access_level", string(prototype.AccessLevel))
 	}
-	var l MailingList
+	if prototype.ReplyPreference != "" {
+		p.addValue("reply_preference", string(prototype.ReplyPreference))
+	}
+	var resp ListResponse
 	response, err := makePutRequest(ctx, r, p)
 	if err != nil {
-		return l, err
+		return resp, err
 	}
-	err = response.parseFromJSON(&l)
-	return l, err
+	mg.forgetList(addr, prototype.Address)
+	err = response.parseFromJSON(&resp)
+	return resp, err
 }
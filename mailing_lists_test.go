@@ -2,6 +2,12 @@ package mailgun_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/facebookgo/ensure"
@@ -92,6 +98,41 @@ func TestMailingListMembers(t *testing.T) {
 	ensure.True(t, *theMember.Subscribed)
 }
 
+func TestUpsertMember(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+
+	ctx := context.Background()
+	address := randomEmail("list", testDomain)
+	_, err := mg.CreateMailingList(ctx, mailgun.MailingList{Address: address, Name: address})
+	ensure.Nil(t, err)
+	defer func() {
+		ensure.Nil(t, mg.DeleteMailingList(ctx, address))
+	}()
+
+	exists, err := mg.MemberExists(ctx, "joe@example.com", address)
+	ensure.Nil(t, err)
+	ensure.False(t, exists)
+
+	member, err := mg.UpsertMember(ctx, address, mailgun.Member{Address: "joe@example.com", Name: "Joe"})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, member.Address, "joe@example.com")
+	ensure.DeepEqual(t, member.Name, "Joe")
+
+	member, err = mg.UpsertMember(ctx, address, mailgun.Member{Address: "joe@example.com", Name: "Joe Example", Subscribed: mailgun.Unsubscribed})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, member.Name, "Joe Example")
+	ensure.DeepEqual(t, *member.Subscribed, false)
+
+	exists, err = mg.MemberExists(ctx, "joe@example.com", address)
+	ensure.Nil(t, err)
+	ensure.True(t, exists)
+
+	members, err := mg.ListMembersAll(ctx, address, nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(members), 1)
+}
+
 func TestMailingLists(t *testing.T) {
 	mg := mailgun.NewMailgun(testDomain, testKey)
 	mg.SetAPIBase(server.URL())
@@ -116,8 +157,10 @@ func TestMailingLists(t *testing.T) {
 		return count
 	}
 
-	_, err := mg.CreateMailingList(ctx, protoList)
+	created, err := mg.CreateMailingList(ctx, protoList)
 	ensure.Nil(t, err)
+	ensure.DeepEqual(t, created.Message, "Mailing list has been created")
+	ensure.DeepEqual(t, created.List.Address, address)
 	defer func() {
 		ensure.Nil(t, mg.DeleteMailingList(ctx, address))
 
@@ -134,10 +177,12 @@ func TestMailingLists(t *testing.T) {
 	protoList.CreatedAt = theList.CreatedAt // ignore this field when comparing.
 	ensure.DeepEqual(t, theList, protoList)
 
-	_, err = mg.UpdateMailingList(ctx, address, mailgun.MailingList{
+	updated, err := mg.UpdateMailingList(ctx, address, mailgun.MailingList{
 		Description: "A list whose description changed",
 	})
 	ensure.Nil(t, err)
+	ensure.DeepEqual(t, updated.Message, "Mailing list has been updated")
+	ensure.DeepEqual(t, updated.List.Description, "A list whose description changed")
 
 	theList, err = mg.GetMailingList(ctx, address)
 	ensure.Nil(t, err)
@@ -146,3 +191,293 @@ func TestMailingLists(t *testing.T) {
 	newList.Description = "A list whose description changed"
 	ensure.DeepEqual(t, theList, newList)
 }
+
+func TestListMailingListsContains(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	for _, name := range []string{"Weekly Digest", "Release Notes", "weekly-ops"} {
+		address := randomEmail("contains", testDomain)
+		_, err := mg.CreateMailingList(ctx, mailgun.MailingList{Address: address, Name: name})
+		ensure.Nil(t, err)
+		defer mg.DeleteMailingList(ctx, address)
+	}
+
+	var names []string
+	it := mg.ListMailingListsWithOptions(&mailgun.ListsOptions{Limit: 1, Contains: "WEEKLY"})
+	var page []mailgun.MailingList
+	for it.Next(ctx, &page) {
+		ensure.True(t, len(page) != 0)
+		for _, l := range page {
+			names = append(names, l.Name)
+		}
+	}
+	ensure.Nil(t, it.Err())
+	ensure.DeepEqual(t, names, []string{"Weekly Digest", "weekly-ops"})
+
+	it = mg.ListMailingListsWithOptions(&mailgun.ListsOptions{Contains: "no-such-list"})
+	ensure.False(t, it.Next(ctx, &page))
+	ensure.Nil(t, it.Err())
+}
+
+func TestCreateMemberListChunks(t *testing.T) {
+	var chunks []int
+	fail := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ensure.Nil(t, r.ParseMultipartForm(32<<20))
+		ensure.DeepEqual(t, r.FormValue("upsert"), "yes")
+		var members []json.RawMessage
+		ensure.Nil(t, json.Unmarshal([]byte(r.FormValue("members")), &members))
+		chunks = append(chunks, len(members))
+		if len(chunks) == fail {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message": "invalid member"}`))
+			return
+		}
+		w.Write([]byte(`{"message": "Mailing list has been updated"}`))
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+	upsert := true
+
+	members := make([]interface{}, 2500)
+	for i := range members {
+		members[i] = "member@example.com"
+	}
+	err := mg.CreateMemberList(ctx, &upsert, "list@example.com", members)
+	ensure.NotNil(t, err)
+	// The chunk after the failed one is still sent
+	ensure.DeepEqual(t, chunks, []int{1000, 1000, 500})
+	merr, ok := err.(*mailgun.MemberListError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, len(merr.Chunks), 3)
+	ensure.DeepEqual(t, merr.Chunks[1].Offset, 1000)
+	ensure.NotNil(t, merr.Chunks[1].Err)
+	ensure.Nil(t, merr.Chunks[2].Err)
+	ensure.DeepEqual(t, len(merr.Failed(members)), 1000)
+	ensure.StringContains(t, err.Error(), "1 of 3 chunks of members failed")
+
+	// Members are split by size as well as by count
+	chunks, fail = nil, 0
+	large := strings.Repeat("x", 3<<20)
+	members = []interface{}{
+		mailgun.Member{Address: "a@example.com", Vars: map[string]interface{}{"v": large}},
+		mailgun.Member{Address: "b@example.com", Vars: map[string]interface{}{"v": large}},
+		mailgun.Member{Address: "c@example.com", Vars: map[string]interface{}{"v": large}},
+	}
+	ensure.Nil(t, mg.CreateMemberList(ctx, &upsert, "list@example.com", members))
+	ensure.DeepEqual(t, chunks, []int{2, 1})
+}
+
+func TestEscapedAddresses(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.EscapedPath())
+		if strings.HasSuffix(r.URL.Path, "/members/pages") && r.URL.Query().Get("page") == "" {
+			// Mailgun links the next page with the address escaped
+			fmt.Fprint(w, `{"items": [{"address": "joe+news@example.com"}],
+				"paging": {"next": "https://api.mailgun.net/v3/lists/dev%2Blist@example.com/members/pages?page=next"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"items": [], "paging": {}, "message": "ok"}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+	const list = "dev+list@example.com"
+
+	_, err := mg.GetMailingList(ctx, list)
+	ensure.Nil(t, err)
+	ensure.Nil(t, mg.DeleteMailingList(ctx, "o/brien@example.com"))
+	_, err = mg.GetMember(ctx, "joe+news@example.com", list)
+	ensure.Nil(t, err)
+	ensure.Nil(t, mg.DeleteMember(ctx, "jo e@example.com", list))
+	_, err = mg.GetBounce(ctx, "joe+news@example.com")
+	ensure.Nil(t, err)
+	ensure.Nil(t, mg.DeleteUnsubscribe(ctx, "joe+news@example.com"))
+	_, err = mg.GetTemplate(ctx, "welcome email")
+	ensure.Nil(t, err)
+
+	it := mg.ListMembers(list, nil)
+	var page []mailgun.Member
+	for it.Next(ctx, &page) {
+	}
+	ensure.Nil(t, it.Err())
+
+	ensure.DeepEqual(t, paths, []string{
+		"GET /v3/lists/dev%2Blist@example.com",
+		"DELETE /v3/lists/o%2Fbrien@example.com",
+		"GET /v3/lists/dev%2Blist@example.com/members/joe%2Bnews@example.com",
+		"DELETE /v3/lists/dev%2Blist@example.com/members/jo%20e@example.com",
+		"GET /v3/" + testDomain + "/bounces/joe%2Bnews@example.com",
+		"DELETE /v3/" + testDomain + "/unsubscribes/joe%2Bnews@example.com",
+		"GET /v3/" + testDomain + "/templates/welcome%20email",
+		"GET /v3/lists/dev%2Blist@example.com/members/pages",
+		"GET /v3/lists/dev%2Blist@example.com/members/pages",
+	})
+}
+
+func TestListParams(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path+"?"+r.URL.RawQuery)
+		fmt.Fprint(w, `{"items": [], "paging": {}, "total_count": 0}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+	params := map[string]string{"subscribed": "yes"}
+
+	var members []mailgun.Member
+	mg.ListMembers("list@example.com", &mailgun.ListOptions{Limit: 10, Params: params}).Next(ctx, &members)
+	var lists []mailgun.MailingList
+	mg.ListMailingLists(&mailgun.ListOptions{Params: params}).Next(ctx, &lists)
+	var routes []mailgun.Route
+	mg.ListRoutes(&mailgun.ListOptions{Limit: 10, Params: params}).Next(ctx, &routes)
+	ensure.DeepEqual(t, queries, []string{
+		"/v3/lists/list@example.com/members/pages?limit=10&subscribed=yes",
+		"/v3/lists/pages?subscribed=yes",
+		"/v3/routes?limit=10&subscribed=yes",
+	})
+}
+
+func TestListMailingListsPosition(t *testing.T) {
+	addresses := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"}
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path+"?"+r.URL.RawQuery)
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		start := 0
+		if r.URL.Path == "/v3/lists" {
+			start, _ = strconv.Atoi(q.Get("skip"))
+		} else if q.Get("page") == "next" {
+			for i, a := range addresses {
+				if a == q.Get("address") {
+					start = i + 1
+				}
+			}
+		}
+		end := start + limit
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+		var items []string
+		for _, a := range addresses[start:end] {
+			items = append(items, fmt.Sprintf(`{"address": %q}`, a))
+		}
+		if r.URL.Path == "/v3/lists" {
+			fmt.Fprintf(w, `{"items": [%s], "total_count": %d}`, strings.Join(items, ","), len(addresses))
+			return
+		}
+		// Paging links relative to the server, as returned by some proxies
+		next := ""
+		if end > start {
+			next = fmt.Sprintf("/v3/lists/pages?page=next&address=%s&limit=%d", addresses[end-1], limit)
+		}
+		fmt.Fprintf(w, `{"items": [%s], "paging": {"next": %q}}`, strings.Join(items, ","), next)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+	collect := func(opts *mailgun.ListsOptions) []string {
+		var got []string
+		var page []mailgun.MailingList
+		it := mg.ListMailingListsWithOptions(opts)
+		for it.Next(ctx, &page) {
+			for _, l := range page {
+				got = append(got, l.Address)
+			}
+		}
+		ensure.Nil(t, it.Err())
+		return got
+	}
+
+	ensure.DeepEqual(t, collect(&mailgun.ListsOptions{Limit: 2, Skip: 2}), addresses[2:])
+	ensure.DeepEqual(t, queries[:2], []string{
+		"/v3/lists?limit=2&skip=2",
+		"/v3/lists/pages?address=d%40example.com&limit=2&page=next",
+	})
+
+	queries = nil
+	ensure.DeepEqual(t, collect(&mailgun.ListsOptions{Limit: 2, After: "c@example.com"}), addresses[3:])
+	ensure.DeepEqual(t, queries[0], "/v3/lists/pages?address=c%40example.com&limit=2&page=next")
+}
+
+func TestListMembersPrefetch(t *testing.T) {
+	requested := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("address")
+		requested <- page
+		switch page {
+		case "":
+			fmt.Fprintf(w, `{"items": [{"address": "a@example.com"}], "paging": {"next": "http://%s/v3/lists/dev@example.com/members/pages?page=next&address=a@example.com"}}`, r.Host)
+		case "a@example.com":
+			fmt.Fprintf(w, `{"items": [{"address": "b@example.com"}], "paging": {"next": "http://%s/v3/lists/dev@example.com/members/pages?page=next&address=b@example.com"}}`, r.Host)
+		default:
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+		}
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	it := mg.ListMembers("dev@example.com", &mailgun.ListOptions{Prefetch: true})
+	var page []mailgun.Member
+	ensure.True(t, it.Next(ctx, &page))
+	ensure.DeepEqual(t, page[0].Address, "a@example.com")
+	ensure.DeepEqual(t, <-requested, "")
+	// The second page is fetched before it is asked for
+	ensure.DeepEqual(t, <-requested, "a@example.com")
+
+	ensure.True(t, it.Next(ctx, &page))
+	ensure.DeepEqual(t, page[0].Address, "b@example.com")
+	ensure.DeepEqual(t, <-requested, "b@example.com")
+	ensure.False(t, it.Next(ctx, &page))
+	ensure.Nil(t, it.Err())
+	ensure.DeepEqual(t, len(requested), 0)
+}
+
+func TestMailingListValidation(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	address := randomEmail("validation", testDomain)
+	_, err := mg.CreateMailingList(ctx, mailgun.MailingList{Address: address, AccessLevel: "Member"})
+	ensure.DeepEqual(t, err, &mailgun.InvalidMailingListError{
+		Field:   "access_level",
+		Value:   "Member",
+		Allowed: []string{mailgun.AccessLevelReadOnly, mailgun.AccessLevelMembers, mailgun.AccessLevelEveryone},
+	})
+	ensure.StringContains(t, err.Error(), "allowed values are readonly, members, everyone")
+	_, err = mg.GetMailingList(ctx, address)
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusNotFound)
+
+	created, err := mg.CreateMailingList(ctx, mailgun.MailingList{
+		Address:         address,
+		AccessLevel:     mailgun.AccessLevelMembers,
+		ReplyPreference: mailgun.ReplyPreferenceSender,
+	})
+	ensure.Nil(t, err)
+	defer mg.DeleteMailingList(ctx, address)
+	ensure.DeepEqual(t, created.List.ReplyPreference, mailgun.ReplyPreference(mailgun.ReplyPreferenceSender))
+
+	_, err = mg.UpdateMailingList(ctx, address, mailgun.MailingList{ReplyPreference: "everyone"})
+	ensure.DeepEqual(t, err.(*mailgun.InvalidMailingListError).Field, "reply_preference")
+	updated, err := mg.UpdateMailingList(ctx, address, mailgun.MailingList{ReplyPreference: mailgun.ReplyPreferenceList})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, updated.List.ReplyPreference, mailgun.ReplyPreference(mailgun.ReplyPreferenceList))
+}
@@ -3,7 +3,8 @@ package mailgun
 import (
 	"context"
 	"encoding/json"
-	"strconv"
+	"fmt"
+	"net/http"
 )
 
 // yes and no are variables which provide us the ability to take their addresses.
@@ -33,6 +34,18 @@ type Member struct {
 	Name       string                 `json:"name,omitempty"`
 	Subscribed *bool                  `json:"subscribed,omitempty"`
 	Vars       map[string]interface{} `json:"vars,omitempty"`
+	// Extra holds the fields of the member returned by Mailgun which have no field of their own.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (m *Member) UnmarshalJSON(data []byte) error {
+	type plain Member
+	if err := json.Unmarshal(data, (*plain)(m)); err != nil {
+		return err
+	}
+	var err error
+	m.Extra, err = extraFields(data, plain{})
+	return err
 }
 
 type memberListResponse struct {
@@ -44,31 +57,49 @@ type memberResponse struct {
 	Member Member `json:"member"`
 }
 
+type memberCreateResponse struct {
+	Member  Member `json:"member"`
+	Message string `json:"message"`
+}
+
 type MemberListIterator struct {
 	memberListResponse
 	mg  Mailgun
 	err error
+	// prefetch is set if each page is fetched in the background once the page before it is returned
+	prefetch bool
+	next     *prefetch
 }
 
 // Used by List methods to specify what list parameters to send to the mailgun API
 type ListOptions struct {
 	Limit int
+	// Prefetch, if set, makes `ListMembers()` fetch the next page in the background as soon as
+	// `Next()` returns a page, so a sequential scan of a large list waits less for each page.
+	// `Next()` waits for the page, and fetches it again itself if fetching it in the background
+	// failed. A page is fetched beyond the last one you ask for, so leave it off for short scans.
+	Prefetch bool
+	// Params are further query parameters sent with the request, such as a flag Mailgun supports
+	// which has no field of its own yet. Pages after the first are fetched from the links Mailgun
+	// returns, which carry the parameters Mailgun applied. Limit wins over a limit among
+	// Params; routes and credentials, which are paged with skip, set skip themselves.
+	Params map[string]string
 }
 
 func (mg *MailgunImpl) ListMembers(address string, opts *ListOptions) *MemberListIterator {
-	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, address) + "/pages")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateMemberApiUrl(mg, listsEndpoint, address)+"/pages")
+	var limit int
 	if opts != nil {
-		if opts.Limit != 0 {
-			r.addParameter("limit", strconv.Itoa(opts.Limit))
-		}
+		r.addParameters(opts.Params)
+		limit = opts.Limit
 	}
+	mg.addPageLimit(r, limit, 0)
 	url, err := r.generateUrlWithParameters()
 	return &MemberListIterator{
 		mg:                 mg,
 		memberListResponse: memberListResponse{Paging: Paging{Next: url, First: url}},
 		err:                err,
+		prefetch:           opts != nil && opts.Prefetch,
 	}
 }
 
@@ -84,7 +115,7 @@ func (li *MemberListIterator) Next(ctx context.Context, items *[]Member) bool {
 	if li.err != nil {
 		return false
 	}
-	li.err = li.fetch(ctx, li.Paging.Next)
+	li.err = li.fetchNext(ctx)
 	if li.err != nil {
 		return false
 	}
@@ -92,6 +123,7 @@ func (li *MemberListIterator) Next(ctx context.Context, items *[]Member) bool {
 	if len(li.Lists) == 0 {
 		return false
 	}
+	li.prefetchNext(ctx)
 	return true
 }
 
@@ -148,19 +180,35 @@ func (li *MemberListIterator) Previous(ctx context.Context, items *[]Member) boo
 }
 
 func (li *MemberListIterator) fetch(ctx context.Context, url string) error {
-	r := newHTTPRequest(url)
-	r.setClient(li.mg.Client())
-	r.setBasicAuth(basicAuthUser, li.mg.APIKey())
+	return li.fetchInto(ctx, url, &li.memberListResponse)
+}
 
-	return getResponseFromJSON(ctx, r, &li.memberListResponse)
+// fetchNext fetches the next page, taking it from the prefetch if it was fetched in the background.
+func (li *MemberListIterator) fetchNext(ctx context.Context) error {
+	page, ok := li.next.wait(ctx, li.Paging.Next)
+	li.next = nil
+	if ok {
+		li.memberListResponse = *page.(*memberListResponse)
+		return nil
+	}
+	return li.fetch(ctx, li.Paging.Next)
+}
+
+// prefetchNext starts fetching the next page in the background, if the iterator prefetches.
+func (li *MemberListIterator) prefetchNext(ctx context.Context) {
+	if li.prefetch && li.Paging.Next != "" {
+		li.next = startPrefetch(ctx, li.Paging.Next, &memberListResponse{}, li.fetchInto)
+	}
+}
+
+func (li *MemberListIterator) fetchInto(ctx context.Context, url string, page interface{}) error {
+	return fetchPage(ctx, li.mg, url, page)
 }
 
 // GetMember returns a complete Member structure for a member of a mailing list,
 // given only their subscription e-mail address.
 func (mg *MailgunImpl) GetMember(ctx context.Context, s, l string) (Member, error) {
-	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, l) + "/" + s)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateMemberApiUrl(mg, listsEndpoint, l)+"/"+pathEscape(s))
 	response, err := makeGetRequest(ctx, r)
 	if err != nil {
 		return Member{}, err
@@ -174,17 +222,14 @@ func (mg *MailgunImpl) GetMember(ctx context.Context, s, l string) (Member, erro
 // If merge is set to true, then the registration may update an existing Member's settings.
 // Otherwise, an error will occur if you attempt to add a member with a duplicate e-mail address.
 func (mg *MailgunImpl) CreateMember(ctx context.Context, merge bool, addr string, prototype Member) error {
-	vs, err := json.Marshal(prototype.Vars)
+	r := newAPIRequest(mg, generateMemberApiUrl(mg, listsEndpoint, addr))
+	vs, err := r.marshalJSON(prototype.Vars)
 	if err != nil {
 		return err
 	}
-
-	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, addr))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
 	p := newFormDataPayload()
 	p.addValue("upsert", yesNo(merge))
-	p.addValue("address", prototype.Address)
+	p.addValue("address", normalizeMemberAddress(prototype.Address))
 	p.addValue("name", prototype.Name)
 	p.addValue("vars", string(vs))
 	if prototype.Subscribed != nil {
@@ -194,12 +239,49 @@ func (mg *MailgunImpl) CreateMember(ctx context.Context, merge bool, addr string
 	return err
 }
 
+// UpsertMember adds the member to the mailing list, or updates the member with the same address if
+// there is one, and returns the member as stored. Only the Address of the prototype is required;
+// other fields are left as they are on an existing member when unset, as with `UpdateMember()`.
+func (mg *MailgunImpl) UpsertMember(ctx context.Context, listAddr string, prototype Member) (Member, error) {
+	r := newAPIRequest(mg, generateMemberApiUrl(mg, listsEndpoint, listAddr))
+	p := newFormDataPayload()
+	p.addValue("upsert", "yes")
+	p.addValue("address", normalizeMemberAddress(prototype.Address))
+	if prototype.Name != "" {
+		p.addValue("name", prototype.Name)
+	}
+	if prototype.Vars != nil {
+		vs, err := r.marshalJSON(prototype.Vars)
+		if err != nil {
+			return Member{}, err
+		}
+		p.addValue("vars", string(vs))
+	}
+	if prototype.Subscribed != nil {
+		p.addValue("subscribed", yesNo(*prototype.Subscribed))
+	}
+	var resp memberCreateResponse
+	err := postResponseFromJSON(ctx, r, p, &resp)
+	return resp.Member, err
+}
+
+// MemberExists reports whether the address is a member of the mailing list. Unlike `GetMember()`,
+// an unknown member is not an error; neither is an unknown list, which has no members.
+func (mg *MailgunImpl) MemberExists(ctx context.Context, memberAddr, listAddr string) (bool, error) {
+	_, err := mg.GetMember(ctx, memberAddr, listAddr)
+	if err == nil {
+		return true, nil
+	}
+	if GetStatusFromErr(err) == http.StatusNotFound {
+		return false, nil
+	}
+	return false, err
+}
+
 // UpdateMember lets you change certain details about the indicated mailing list member.
 // Address, Name, Vars, and Subscribed fields may be changed.
 func (mg *MailgunImpl) UpdateMember(ctx context.Context, s, l string, prototype Member) (Member, error) {
-	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, l) + "/" + s)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateMemberApiUrl(mg, listsEndpoint, l)+"/"+pathEscape(s))
 	p := newFormDataPayload()
 	if prototype.Address != "" {
 		p.addValue("address", prototype.Address)
@@ -208,7 +290,7 @@ func (mg *MailgunImpl) UpdateMember(ctx context.Context, s, l string, prototype
 		p.addValue("name", prototype.Name)
 	}
 	if prototype.Vars != nil {
-		vs, err := json.Marshal(prototype.Vars)
+		vs, err := r.marshalJSON(prototype.Vars)
 		if err != nil {
 			return Member{}, err
 		}
@@ -230,13 +312,53 @@ func (mg *MailgunImpl) UpdateMember(ctx context.Context, s, l string, prototype
 
 // DeleteMember removes the member from the list.
 func (mg *MailgunImpl) DeleteMember(ctx context.Context, member, addr string) error {
-	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, addr) + "/" + member)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateMemberApiUrl(mg, listsEndpoint, addr)+"/"+pathEscape(member))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
 
+// MemberListChunk is the outcome of one of the requests CreateMemberList() split its members into.
+type MemberListChunk struct {
+	// Offset is the index in newMembers of the first member of the chunk.
+	Offset int
+	// Count is the number of members in the chunk.
+	Count int
+	// Err is the error of the request, or nil if Mailgun accepted the members.
+	Err error
+}
+
+// MemberListError is returned by CreateMemberList() when some of the requests it split its
+// members into failed. Chunks holds the outcome of every request, in order.
+type MemberListError struct {
+	Chunks []MemberListChunk
+}
+
+func (e *MemberListError) Error() string {
+	var failed int
+	var first error
+	for _, c := range e.Chunks {
+		if c.Err != nil {
+			if first == nil {
+				first = c.Err
+			}
+			failed++
+		}
+	}
+	return fmt.Sprintf("%d of %d chunks of members failed: %s", failed, len(e.Chunks), first)
+}
+
+// Failed returns the members of newMembers which belong to the chunks that failed, so they can
+// be retried.
+func (e *MemberListError) Failed(newMembers []interface{}) []interface{} {
+	var failed []interface{}
+	for _, c := range e.Chunks {
+		if c.Err != nil && c.Offset+c.Count <= len(newMembers) {
+			failed = append(failed, newMembers[c.Offset:c.Offset+c.Count]...)
+		}
+	}
+	return failed
+}
+
 // CreateMemberList registers multiple Members and non-Member members to a single mailing list
 // in a single round-trip.
 // u indicates if the existing members should be updated or duplicates should be updated.
@@ -246,19 +368,100 @@ func (mg *MailgunImpl) DeleteMember(ctx context.Context, member, addr string) er
 // If a simple slice of strings is passed, each string refers to the member's e-mail address.
 // Otherwise, each Member needs to have at least the Address field filled out.
 // Other fields are optional, but may be set according to your needs.
+//
+// Lists of more than MaxMembersPerRequest members, or whose encoded members exceed 8 MiB, are
+// split into chunks sent one after the other, as Mailgun accepts neither larger nor compressed
+// requests. Chunks are sent at the pace of the client's other bulk helpers, and retried when rate limited; if any
+// fail, the rest are still sent, and a *MemberListError reports the outcome of each.
 func (mg *MailgunImpl) CreateMemberList(ctx context.Context, u *bool, addr string, newMembers []interface{}) error {
-	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, addr) + ".json")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateMemberApiUrl(mg, listsEndpoint, addr)+".json")
+	encoded := make([][]byte, len(newMembers))
+	for i, m := range newMembers {
+		switch v := m.(type) {
+		case string:
+			m = normalizeMemberAddress(v)
+		case Member:
+			v.Address = normalizeMemberAddress(v.Address)
+			m = v
+		case *Member:
+			cpy := *v
+			cpy.Address = normalizeMemberAddress(v.Address)
+			m = cpy
+		}
+		var err error
+		if encoded[i], err = r.marshalJSON(m); err != nil {
+			return err
+		}
+	}
+
+	chunks := memberListChunks(encoded, MaxMembersPerRequest, MaxMemberListSize)
+	if len(chunks) == 1 {
+		return mg.postMemberList(ctx, r, u, encoded)
+	}
+	limiter := mg.bulkLimiter()
+	var failed bool
+	for i := range chunks {
+		c := &chunks[i]
+		c.Err = limiter.do(ctx, func() error {
+			return mg.postMemberList(ctx, r, u, encoded[c.Offset:c.Offset+c.Count])
+		})
+		failed = failed || c.Err != nil
+	}
+	if failed {
+		return &MemberListError{Chunks: chunks}
+	}
+	return nil
+}
+
+// postMemberList sends the JSON encoded members in a single members.json request.
+func (mg *MailgunImpl) postMemberList(ctx context.Context, r *httpRequest, u *bool, encoded [][]byte) error {
 	p := newFormDataPayload()
 	if u != nil {
 		p.addValue("upsert", yesNo(*u))
 	}
-	bs, err := json.Marshal(newMembers)
-	if err != nil {
-		return err
+	body := make([]byte, 0, memberListSize(encoded))
+	body = append(body, '[')
+	for i, m := range encoded {
+		if i > 0 {
+			body = append(body, ',')
+		}
+		body = append(body, m...)
 	}
-	p.addValue("members", string(bs))
-	_, err = makePostRequest(ctx, r, p)
+	body = append(body, ']')
+	p.addValue("members", string(body))
+	_, err := makePostRequest(ctx, r, p)
 	return err
 }
+
+// memberListChunks splits the encoded members into chunks of up to count members, whose JSON
+// array is at most size bytes. A member larger than size is sent in a chunk of its own.
+func memberListChunks(encoded [][]byte, count, size int) []MemberListChunk {
+	chunks := []MemberListChunk{{}}
+	total := 2
+	for i, m := range encoded {
+		c := &chunks[len(chunks)-1]
+		if c.Count > 0 && (c.Count == count || total+1+len(m) > size) {
+			chunks = append(chunks, MemberListChunk{Offset: i})
+			c = &chunks[len(chunks)-1]
+			total = 2
+		}
+		if c.Count > 0 {
+			total++
+		}
+		total += len(m)
+		c.Count++
+	}
+	return chunks
+}
+
+// memberListSize returns the size of the JSON array of the encoded members.
+func memberListSize(encoded [][]byte) int {
+	size := 2
+	for i, m := range encoded {
+		if i > 0 {
+			size++
+		}
+		size += len(m)
+	}
+	return size
+}
@@ -0,0 +1,295 @@
+package mailgun
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+)
+
+// ImportOptions controls how ImportMembersCSV() maps the columns of a CSV file to members.
+// Columns are matched by the names in the header row, ignoring case.
+type ImportOptions struct {
+	// AddressColumn holds the member's email address; defaults to "address".
+	AddressColumn string
+	// NameColumn holds the member's name; defaults to "name". Optional.
+	NameColumn string
+	// SubscribedColumn holds yes/no, true/false or 1/0; defaults to "subscribed".
+	// Optional; members with no value are subscribed.
+	SubscribedColumn string
+	// VarsColumn holds the member's vars as a JSON object; defaults to "vars". Optional.
+	VarsColumn string
+	// VarColumns are further columns imported as vars, keyed by the column name.
+	VarColumns []string
+	// Upsert updates members already on the list, instead of failing the batch which contains them.
+	Upsert bool
+	// BatchSize is the number of members sent to Mailgun in each request; defaults to, and may not
+	// exceed, MaxMembersPerRequest.
+	BatchSize int
+	// MaxErrorRate, if set, stops the import once the ratio of the rows which failed exceeds it,
+	// such as 0.05 for one in twenty, so a file in the wrong format is not imported in part. A
+	// batch rejected by Mailgun then fails its rows instead of stopping the import. The ratio is
+	// only checked once MinErrorSamples rows were read; defaults to DefaultErrorRateSamples. Rows
+	// read but not yet sent when the import stops are not imported.
+	MaxErrorRate    float64
+	MinErrorSamples int
+}
+
+// ImportResult reports the outcome of ImportMembersCSV().
+type ImportResult struct {
+	// Imported is the number of members sent to Mailgun.
+	Imported int
+	// Errors lists the rows which were skipped.
+	Errors []RowError
+	// Summary counts the rows imported and failed, with the reason the import stopped early, if
+	// it did.
+	Summary BulkSummary
+}
+
+// RowError describes a row of a CSV file which could not be imported.
+type RowError struct {
+	// Row is the line number of the row in the file, counting the header row as line 1.
+	Row int
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Err)
+}
+
+// ImportMembersCSV reads members from CSV and adds them to the mailing list at addr. The file is
+// processed in a single pass, and members are sent to Mailgun in batches, so files of any size may
+// be imported; a batch rejected with 429 Too Many Requests is retried as the client slows down (see
+// `BulkStats()`). Rows which cannot be parsed are skipped and reported in the result; an error is
+// returned only if the file cannot be read, Mailgun rejects a batch, or the import exceeds the
+// MaxErrorRate of opts, in which case the error is an *ErrorRateError.
+//
+//  f, err := os.Open("members.csv")
+//  result, err := mg.ImportMembersCSV(ctx, "list@example.com", f, mailgun.ImportOptions{
+//    VarColumns: []string{"plan", "country"},
+//    Upsert:     true,
+//  })
+//  for _, rowErr := range result.Errors {
+//    log.Println(rowErr)
+//  }
+func (mg *MailgunImpl) ImportMembersCSV(ctx context.Context, addr string, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	if opts.AddressColumn == "" {
+		opts.AddressColumn = "address"
+	}
+	if opts.NameColumn == "" {
+		opts.NameColumn = "name"
+	}
+	if opts.SubscribedColumn == "" {
+		opts.SubscribedColumn = "subscribed"
+	}
+	if opts.VarsColumn == "" {
+		opts.VarsColumn = "vars"
+	}
+	if opts.BatchSize <= 0 || opts.BatchSize > MaxMembersPerRequest {
+		opts.BatchSize = MaxMembersPerRequest
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("while reading csv header: %s", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	column := func(name string) int {
+		if i, ok := columns[strings.ToLower(name)]; ok {
+			return i
+		}
+		return -1
+	}
+
+	addressCol := column(opts.AddressColumn)
+	if addressCol == -1 {
+		return nil, fmt.Errorf("csv has no '%s' column", opts.AddressColumn)
+	}
+	varCols := make(map[string]int, len(opts.VarColumns))
+	for _, name := range opts.VarColumns {
+		i := column(name)
+		if i == -1 {
+			return nil, fmt.Errorf("csv has no '%s' column", name)
+		}
+		varCols[name] = i
+	}
+	nameCol, subscribedCol, varsCol := column(opts.NameColumn), column(opts.SubscribedColumn), column(opts.VarsColumn)
+
+	var upsert *bool
+	if opts.Upsert {
+		upsert = &yes
+	}
+
+	limiter := mg.bulkLimiter()
+	exec := BatchExecutor{Mode: FailFast, MaxErrorRate: opts.MaxErrorRate, MinErrorSamples: opts.MinErrorSamples}
+	if opts.MaxErrorRate > 0 {
+		exec.Mode = ContinueOnError
+	}
+	outcome := exec.newOutcome()
+	var result ImportResult
+	var batch []interface{}
+	var batchRows []int
+	// fail records the rows which failed.
+	fail := func(err error, rows ...int) {
+		for _, row := range rows {
+			result.Errors = append(result.Errors, RowError{Row: row, Err: err})
+		}
+		result.Summary.Failed += len(rows)
+	}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := limiter.do(ctx, func() error {
+			return mg.CreateMemberList(ctx, upsert, addr, batch)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			// Rows are counted as they are read, so the rows of the batch were counted as succeeded
+			fail(err, batchRows...)
+			err = outcome.revise(len(batchRows), err)
+		} else {
+			result.Imported += len(batch)
+			result.Summary.Succeeded += len(batch)
+		}
+		batch, batchRows = batch[:0], batchRows[:0]
+		return err
+	}
+	stop := func(err error) (*ImportResult, error) {
+		result.Summary.AbortReason = err
+		return &result, err
+	}
+
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok {
+				fail(err, row)
+				if err := outcome.tolerate(1); err != nil {
+					return stop(err)
+				}
+				continue
+			}
+			return stop(err)
+		}
+
+		m, err := parseMemberRecord(record, addressCol, nameCol, subscribedCol, varsCol, varCols)
+		if err != nil {
+			fail(err, row)
+			if err := outcome.tolerate(1); err != nil {
+				return stop(err)
+			}
+			continue
+		}
+		if err := outcome.record(1, nil); err != nil {
+			return stop(err)
+		}
+		batch = append(batch, m)
+		batchRows = append(batchRows, row)
+		if len(batch) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return stop(err)
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return stop(err)
+	}
+	return &result, nil
+}
+
+func parseMemberRecord(record []string, addressCol, nameCol, subscribedCol, varsCol int, varCols map[string]int) (Member, error) {
+	field := func(i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var m Member
+	addr, err := mail.ParseAddress(field(addressCol))
+	if err != nil {
+		return m, fmt.Errorf("invalid address '%s': %s", field(addressCol), err)
+	}
+	m.Address = addr.Address
+	m.Name = field(nameCol)
+	if m.Name == "" {
+		m.Name = addr.Name
+	}
+
+	switch strings.ToLower(field(subscribedCol)) {
+	case "":
+	case "yes", "true", "1":
+		m.Subscribed = Subscribed
+	case "no", "false", "0":
+		m.Subscribed = Unsubscribed
+	default:
+		return m, fmt.Errorf("invalid subscribed value '%s'", field(subscribedCol))
+	}
+
+	if v := field(varsCol); v != "" {
+		if err := json.Unmarshal([]byte(v), &m.Vars); err != nil {
+			return m, fmt.Errorf("invalid vars: %s", err)
+		}
+	}
+	for name, i := range varCols {
+		if m.Vars == nil {
+			m.Vars = make(map[string]interface{}, len(varCols))
+		}
+		m.Vars[name] = field(i)
+	}
+	return m, nil
+}
+
+// ExportMembersCSV writes every member of the mailing list at addr to w as CSV, with the columns
+// address, name, subscribed and vars, the last as a JSON object. The output can be read back with
+// ImportMembersCSV() using the default options. Members are written a page at a time as they are fetched.
+func (mg *MailgunImpl) ExportMembersCSV(ctx context.Context, addr string, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"address", "name", "subscribed", "vars"}); err != nil {
+		return err
+	}
+
+	var page []Member
+	it := mg.ListMembers(addr, nil)
+	for it.Next(ctx, &page) {
+		for _, m := range page {
+			var subscribed, vars string
+			if m.Subscribed != nil {
+				subscribed = yesNo(*m.Subscribed)
+			}
+			if len(m.Vars) != 0 {
+				b, err := json.Marshal(m.Vars)
+				if err != nil {
+					return fmt.Errorf("while encoding vars of '%s': %s", m.Address, err)
+				}
+				vars = string(b)
+			}
+			if err := cw.Write([]string{m.Address, m.Name, subscribed, vars}); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	if it.Err() != nil {
+		return it.Err()
+	}
+	cw.Flush()
+	return cw.Error()
+}
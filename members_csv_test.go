@@ -0,0 +1,113 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestMembersCSV(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+
+	ctx := context.Background()
+	address := randomEmail("list", testDomain)
+	_, err := mg.CreateMailingList(ctx, mailgun.MailingList{Address: address, Name: address})
+	ensure.Nil(t, err)
+	defer func() {
+		ensure.Nil(t, mg.DeleteMailingList(ctx, address))
+	}()
+
+	csv := strings.Join([]string{
+		"Email,Name,Subscribed,Plan",
+		"joe@example.com,Joe Example,yes,pro",
+		"not-an-address,Nobody,yes,free",
+		"Jane Doe <jane@example.com>,,no,free",
+		"bad@example.com,Bad,maybe,free",
+		"sam@example.com,Sam,,pro",
+	}, "\n")
+
+	result, err := mg.ImportMembersCSV(ctx, address, strings.NewReader(csv), mailgun.ImportOptions{
+		AddressColumn: "email",
+		VarColumns:    []string{"plan"},
+		BatchSize:     2,
+	})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, result.Imported, 3)
+	ensure.DeepEqual(t, len(result.Errors), 2)
+	ensure.DeepEqual(t, result.Errors[0].Row, 3)
+	ensure.DeepEqual(t, result.Errors[1].Row, 5)
+
+	jane, err := mg.GetMember(ctx, "jane@example.com", address)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, jane.Name, "Jane Doe")
+	ensure.DeepEqual(t, *jane.Subscribed, false)
+	ensure.DeepEqual(t, jane.Vars["plan"], "free")
+
+	var out bytes.Buffer
+	ensure.Nil(t, mg.ExportMembersCSV(ctx, address, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	ensure.DeepEqual(t, len(lines), 4)
+	ensure.DeepEqual(t, lines[0], "address,name,subscribed,vars")
+	ensure.StringContains(t, out.String(), `joe@example.com,Joe Example,yes,"{""plan"":""pro""}"`)
+
+	// An export can be imported again
+	result, err = mg.ImportMembersCSV(ctx, address, &out, mailgun.ImportOptions{Upsert: true})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, result.Imported, 3)
+	ensure.DeepEqual(t, len(result.Errors), 0)
+}
+
+func TestMembersCSVMaxErrorRate(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+
+	ctx := context.Background()
+	address := randomEmail("list", testDomain)
+	_, err := mg.CreateMailingList(ctx, mailgun.MailingList{Address: address, Name: address})
+	ensure.Nil(t, err)
+	defer func() {
+		ensure.Nil(t, mg.DeleteMailingList(ctx, address))
+	}()
+
+	csv := strings.Join([]string{
+		"address",
+		"one@example.com",
+		"not-an-address",
+		"two@example.com",
+		"still-not-an-address",
+		"three@example.com",
+	}, "\n")
+
+	result, err := mg.ImportMembersCSV(ctx, address, strings.NewReader(csv), mailgun.ImportOptions{
+		BatchSize:       1,
+		MaxErrorRate:    0.3,
+		MinErrorSamples: 4,
+	})
+	rateErr, ok := err.(*mailgun.ErrorRateError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, *rateErr, mailgun.ErrorRateError{Failed: 2, Processed: 4, MaxErrorRate: 0.3})
+	ensure.DeepEqual(t, result.Imported, 2)
+	ensure.DeepEqual(t, result.Summary.Succeeded, 2)
+	ensure.DeepEqual(t, result.Summary.Failed, 2)
+	ensure.True(t, result.Summary.Aborted())
+	ensure.DeepEqual(t, result.Summary.AbortReason, err)
+
+	// Batches rejected by Mailgun fail their rows rather than the import
+	result, err = mg.ImportMembersCSV(ctx, randomEmail("missing", testDomain), strings.NewReader(csv), mailgun.ImportOptions{
+		BatchSize:       1,
+		MaxErrorRate:    0.9,
+		MinErrorSamples: 2,
+	})
+	_, ok = err.(*mailgun.ErrorRateError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, result.Imported, 0)
+	ensure.DeepEqual(t, len(result.Errors), 2)
+	ensure.DeepEqual(t, result.Errors[0].Row, 2)
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(result.Errors[0].Err), http.StatusNotFound)
+}
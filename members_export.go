@@ -0,0 +1,208 @@
+package mailgun
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ExportFormat selects the format `ExportAllMembers()` writes.
+type ExportFormat int
+
+const (
+	// FormatCSV writes a row of CSV for each member.
+	FormatCSV ExportFormat = iota
+	// FormatJSONL writes each member as a line of JSON.
+	FormatJSONL
+)
+
+// ExportedMember is an address on one or more mailing lists, as written by `ExportAllMembers()`.
+type ExportedMember struct {
+	Address string `json:"address"`
+	// Name and Vars are those of the address on the lists it is on. Vars set on several lists
+	// keep the value of the first list, by address.
+	Name string                 `json:"name,omitempty"`
+	Vars map[string]interface{} `json:"vars,omitempty"`
+	// Lists maps the address of each list the member is on to whether they are subscribed to it.
+	Lists map[string]bool `json:"lists"`
+}
+
+// ExportAllMembers walks every mailing list of the account and writes each address on any of
+// them once to w, with the lists it is on, for backups and migrations to other providers. Pages
+// are fetched at the pace of the client's other bulk helpers, and retried when rate limited.
+// Mailgun pages the members of a list in the order of their addresses, so the lists are read
+// side by side and members written in that order as they are read, holding a page of each list
+// in memory; a list whose members are out of order fails the export.
+//
+// FormatCSV writes the columns address, name and vars, the last as a JSON object, followed by a
+// column for each list, by address, holding yes or no for a member subscribed to or unsubscribed
+// from it, or nothing if they are not on it. FormatJSONL writes each member as an ExportedMember.
+//
+//  f, err := os.Create("members.csv")
+//  err = mg.ExportAllMembers(ctx, f, mailgun.FormatCSV)
+func (mg *MailgunImpl) ExportAllMembers(ctx context.Context, w io.Writer, format ExportFormat) error {
+	if format != FormatCSV && format != FormatJSONL {
+		return errors.Errorf("unknown export format %d", format)
+	}
+	lists, err := mg.ListMailingListsAll(ctx, nil)
+	if err != nil {
+		return err
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].Address < lists[j].Address })
+
+	write, flush, err := memberWriter(w, format, lists)
+	if err != nil {
+		return err
+	}
+
+	limiter := mg.bulkLimiter()
+	cursors := make([]*memberCursor, len(lists))
+	for i, l := range lists {
+		cursors[i] = &memberCursor{list: l.Address, it: mg.ListMembers(l.Address, nil)}
+	}
+	for {
+		// The next member is the first address at the head of any list
+		var next string
+		var found bool
+		for _, c := range cursors {
+			m, err := c.peek(ctx, limiter)
+			if err != nil {
+				return errors.Wrapf(err, "while listing members of '%s'", c.list)
+			}
+			if m != nil && (!found || c.key < next) {
+				next, found = c.key, true
+			}
+		}
+		if !found {
+			return flush()
+		}
+
+		// Lists are in the order of their addresses, so the vars of the first list are kept
+		var e *ExportedMember
+		for _, c := range cursors {
+			for {
+				m, err := c.peek(ctx, limiter)
+				if err != nil {
+					return errors.Wrapf(err, "while listing members of '%s'", c.list)
+				}
+				if m == nil || c.key != next {
+					break
+				}
+				if e == nil {
+					e = &ExportedMember{Address: m.Address, Lists: make(map[string]bool)}
+				}
+				mergeMember(e, c.list, m)
+				c.pos++
+			}
+		}
+		if err := write(e); err != nil {
+			return err
+		}
+	}
+}
+
+// mergeMember adds the member m of list to the exported member e.
+func mergeMember(e *ExportedMember, list string, m *Member) {
+	if e.Name == "" {
+		e.Name = m.Name
+	}
+	for k, v := range m.Vars {
+		if e.Vars == nil {
+			e.Vars = make(map[string]interface{}, len(m.Vars))
+		}
+		if _, ok := e.Vars[k]; !ok {
+			e.Vars[k] = v
+		}
+	}
+	e.Lists[list] = m.Subscribed == nil || *m.Subscribed
+}
+
+// memberCursor reads the members of a list a page at a time.
+type memberCursor struct {
+	list string
+	it   *MemberListIterator
+	page []Member
+	pos  int
+	// key is the normalized address of the member at pos
+	key string
+}
+
+// peek returns the member at the head of the list, fetching the next page once the page read is
+// exhausted, or nil at the end of the list.
+func (c *memberCursor) peek(ctx context.Context, l *bulkLimiter) (*Member, error) {
+	for c.pos >= len(c.page) {
+		if c.it == nil {
+			return nil, nil
+		}
+		var more bool
+		err := walkPages(ctx, l, func(ctx context.Context) bool {
+			more = c.it.Next(ctx, &c.page)
+			return false
+		}, &c.it.err)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			c.it, c.page = nil, nil
+			return nil, nil
+		}
+		c.pos = 0
+	}
+
+	m := &c.page[c.pos]
+	key := normalizeAddress(m.Address)
+	if key < c.key {
+		return nil, errors.Errorf("member '%s' is out of the order of addresses", m.Address)
+	}
+	c.key = key
+	return m, nil
+}
+
+// memberWriter returns the functions which write exported members to w in format, and flush
+// what is buffered once all are written.
+func memberWriter(w io.Writer, format ExportFormat, lists []MailingList) (write func(*ExportedMember) error, flush func() error, err error) {
+	if format == FormatJSONL {
+		bw := bufio.NewWriter(w)
+		enc := json.NewEncoder(bw)
+		write = func(e *ExportedMember) error { return enc.Encode(e) }
+		return write, bw.Flush, nil
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{"address", "name", "vars"}
+	for _, l := range lists {
+		header = append(header, l.Address)
+	}
+	if err := cw.Write(header); err != nil {
+		return nil, nil, err
+	}
+	write = func(e *ExportedMember) error {
+		var vars string
+		if len(e.Vars) != 0 {
+			b, err := json.Marshal(e.Vars)
+			if err != nil {
+				return errors.Wrapf(err, "while encoding vars of '%s'", e.Address)
+			}
+			vars = string(b)
+		}
+		row := []string{e.Address, e.Name, vars}
+		for _, l := range lists {
+			var value string
+			if subscribed, ok := e.Lists[l.Address]; ok {
+				value = yesNo(subscribed)
+			}
+			row = append(row, value)
+		}
+		return cw.Write(row)
+	}
+	flush = func() error {
+		cw.Flush()
+		return cw.Error()
+	}
+	return write, flush, nil
+}
@@ -0,0 +1,59 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestExportAllMembers(t *testing.T) {
+	srv := mailgun.NewMockServer()
+	defer srv.Stop()
+
+	srv.SeedLists(
+		mailgun.MailingList{Address: "news@mailgun.test"},
+		mailgun.MailingList{Address: "beta@mailgun.test"},
+	)
+	srv.SeedMembers("news@mailgun.test",
+		mailgun.Member{Address: "joe@example.com", Name: "Joe", Vars: map[string]interface{}{"plan": "pro"}},
+		mailgun.Member{Address: "jane@example.com", Subscribed: mailgun.Unsubscribed},
+	)
+	srv.SeedMembers("beta@mailgun.test",
+		mailgun.Member{Address: "Joe@Example.com", Vars: map[string]interface{}{"plan": "free", "beta": true}},
+	)
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL())
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	ensure.Nil(t, mg.ExportAllMembers(ctx, &out, mailgun.FormatCSV))
+	ensure.DeepEqual(t, strings.Split(strings.TrimSpace(out.String()), "\n"), []string{
+		"address,name,vars,beta@mailgun.test,foo@mailgun.test,news@mailgun.test",
+		"dev@samples.mailgun.org,Developer,,,yes,",
+		"jane@example.com,,,,,no",
+		`Joe@Example.com,Joe,"{""beta"":true,""plan"":""free""}",yes,,yes`,
+	})
+
+	out.Reset()
+	ensure.Nil(t, mg.ExportAllMembers(ctx, &out, mailgun.FormatJSONL))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	ensure.DeepEqual(t, len(lines), 3)
+	var joe mailgun.ExportedMember
+	ensure.Nil(t, json.Unmarshal([]byte(lines[2]), &joe))
+	ensure.DeepEqual(t, joe.Lists, map[string]bool{"beta@mailgun.test": true, "news@mailgun.test": true})
+	ensure.DeepEqual(t, joe.Vars["plan"], "free")
+
+	// Lists are merged a page at a time
+	mg.SetDefaultPageSize(1)
+	out.Reset()
+	ensure.Nil(t, mg.ExportAllMembers(ctx, &out, mailgun.FormatJSONL))
+	ensure.DeepEqual(t, strings.Split(strings.TrimSpace(out.String()), "\n"), lines)
+
+	ensure.NotNil(t, mg.ExportAllMembers(ctx, &out, mailgun.ExportFormat(7)))
+}
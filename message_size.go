@@ -0,0 +1,129 @@
+package mailgun
+
+import (
+	"io"
+	"mime/multipart"
+	"os"
+	"path"
+)
+
+// EstimateSize returns the approximate size in bytes of the request `Send()` makes for the message,
+// including its attachments, inlines and recipient variables, so batches can be split before they
+// exceed MaxMessageSize. The message is not modified. Attachments added with `AddReaderAttachment()`
+// are counted if the reader reports its length, as *bytes.Reader and *strings.Reader do, or can seek;
+// others are not counted. List headers added by `SetListHeaders()` are not included.
+func (m *Message) EstimateSize() (int64, error) {
+	payload := newFormDataPayload()
+	if err := m.addValues(payload); err != nil {
+		return 0, err
+	}
+	if m.mailingList != nil {
+		m.addListHeaders(payload, m.mailingList)
+	}
+	return payload.size()
+}
+
+// size returns the size of the encoded payload, without reading its files and readers.
+func (f *formDataPayload) size() (int64, error) {
+	if !f.isMultipart() {
+		return int64(f.urlEncoded().Len()), nil
+	}
+	var counter countingWriter
+	writer := multipart.NewWriter(&counter)
+	var content int64
+
+	for _, keyVal := range f.Values {
+		if _, err := writer.CreateFormField(keyVal.key); err != nil {
+			return 0, err
+		}
+		content += int64(len(keyVal.value))
+	}
+
+	for _, file := range f.Files {
+		fp, err := os.Open(file.value)
+		if err != nil {
+			return 0, err
+		}
+		head := readerHead(fp)
+		fi, err := fp.Stat()
+		fp.Close()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := createFormFile(writer, file.key, path.Base(file.value), f.contentType(path.Base(file.value), head)); err != nil {
+			return 0, err
+		}
+		content += fi.Size()
+	}
+
+	for _, file := range f.ReadClosers {
+		if _, err := createFormFile(writer, file.key, file.name, f.contentType(file.name, readerHead(file.value))); err != nil {
+			return 0, err
+		}
+		content += readerSize(file.value)
+	}
+
+	for _, buff := range f.Buffers {
+		if _, err := createFormFile(writer, buff.key, buff.name, f.contentType(buff.name, buff.value)); err != nil {
+			return 0, err
+		}
+		content += int64(len(buff.value))
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+	return counter.n + content, nil
+}
+
+// readerSize returns the number of bytes left to read from r, or 0 if it cannot tell without reading.
+func readerSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case nopCloser:
+		return readerSize(r.Reader)
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case io.Seeker:
+		cur, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if _, serr := r.Seek(cur, io.SeekStart); err != nil || serr != nil {
+			return 0
+		}
+		return end - cur
+	}
+	return 0
+}
+
+// readerHead returns the bytes the content type of r is detected from, without consuming them, or
+// nil if it cannot tell without reading, as readerSize does.
+func readerHead(r io.Reader) []byte {
+	switch r := r.(type) {
+	case nopCloser:
+		return readerHead(r.Reader)
+	case io.ReadSeeker:
+		cur, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil
+		}
+		head := make([]byte, sniffLen)
+		n, _ := io.ReadFull(r, head)
+		if _, err := r.Seek(cur, io.SeekStart); err != nil {
+			return nil
+		}
+		return head[:n]
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written to it, discarding them.
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,42 @@
+package mailgun
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestEstimateSize(t *testing.T) {
+	f, err := ioutil.TempFile("", "attachment")
+	ensure.Nil(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(strings.Repeat("x", 1000))
+	ensure.Nil(t, err)
+	ensure.Nil(t, f.Close())
+
+	opened, err := os.Open(f.Name())
+	ensure.Nil(t, err)
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText)
+	for _, r := range []string{"one@example.com", "two@example.com"} {
+		ensure.Nil(t, m.AddRecipientAndVariables(r, map[string]interface{}{"name": r}))
+	}
+	m.AddAttachment(f.Name())
+	m.AddBufferAttachment("buffer.txt", []byte("buffered"))
+	m.AddReaderAttachment("file.txt", opened)
+	m.AddInlineImage(bytes.NewReader(make([]byte, 500)), "logo.png")
+
+	estimate, err := m.EstimateSize()
+	ensure.Nil(t, err)
+
+	payload := newFormDataPayload()
+	ensure.Nil(t, m.addValues(payload))
+	body, err := payload.getPayloadBuffer()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, estimate, int64(body.Len()))
+}
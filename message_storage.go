@@ -0,0 +1,116 @@
+package mailgun
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// DefaultMessageRetention is how long Mailgun keeps the messages it stores, unless the account
+// is set to keep them for longer.
+const DefaultMessageRetention = 72 * time.Hour
+
+// ErrMessageNotStored is returned by `GetMessageStorage()` when no event of the message says
+// where it is stored, such as when its events have expired along with it.
+var ErrMessageNotStored = errors.New("no stored copy of the message was found")
+
+// MessageStorage is where Mailgun stores a message, and until when it can be retrieved with
+// `GetStoredMessage()` or re-sent with `ReSend()`.
+type MessageStorage struct {
+	// Key and URL are those of the storage of the message. The URL points at the storage region
+	// of the message, which may differ from the API base of the client.
+	Key string
+	URL string
+	// Region is the storage region, from the host of the URL, such as "us-east4"; it is empty if
+	// the host does not name one.
+	Region string
+	// StoredAt is the time of the event the storage was taken from, and ExpiresAt when the
+	// retention of the client runs out after it.
+	StoredAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Expired reports whether the message has passed its retention at now, so retrieving it fails.
+func (s MessageStorage) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// SetMessageRetention sets how long the account keeps stored messages, which defaults to
+// DefaultMessageRetention, for the ExpiresAt of the storage returned by the client. Mailgun
+// stores every message it accepts, and has no per-message option to keep one for longer, so set
+// this to the retention of the account if Mailgun was asked to change it.
+func (mg *MailgunImpl) SetMessageRetention(d time.Duration) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.messageRetention = d
+}
+
+func (mg *MailgunImpl) retention() time.Duration {
+	mg.mu.RLock()
+	defer mg.mu.RUnlock()
+	if mg.messageRetention > 0 {
+		return mg.messageRetention
+	}
+	return DefaultMessageRetention
+}
+
+// EventStorage returns the storage of the message of an event, such as an accepted, delivered or
+// stored event. ok is false if the event does not say where the message is stored.
+func (mg *MailgunImpl) EventStorage(e Event) (s MessageStorage, ok bool) {
+	storage := eventStorage(e)
+	if storage.URL == "" {
+		return s, false
+	}
+	s = MessageStorage{
+		Key:      storage.Key,
+		URL:      storage.URL,
+		Region:   storageRegion(storage.URL),
+		StoredAt: e.GetTimestamp(),
+	}
+	s.ExpiresAt = s.StoredAt.Add(mg.retention())
+	return s, true
+}
+
+// GetMessageStorage returns where the message with the id returned by `Send()` is stored, from
+// its earliest event which says so. It returns ErrMessageNotStored if none does.
+//
+//  _, id, err := mg.Send(ctx, m)
+//  s, err := mg.GetMessageStorage(ctx, id)
+//  log.Printf("message can be retrieved from %s until %s", s.URL, s.ExpiresAt)
+func (mg *MailgunImpl) GetMessageStorage(ctx context.Context, id string) (MessageStorage, error) {
+	it := mg.ListEvents(&ListEventOptions{
+		Begin:          time.Now().Add(-mg.retention()),
+		ForceAscending: true,
+		Query:          events.MessageID(strings.Trim(id, "<>")),
+	})
+	var page []Event
+	for it.Next(ctx, &page) {
+		for _, e := range page {
+			if s, ok := mg.EventStorage(e); ok {
+				return s, nil
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return MessageStorage{}, err
+	}
+	return MessageStorage{}, ErrMessageNotStored
+}
+
+// storageRegion returns the region named by the host of a storage URL, such as
+// storage-us-east4.api.mailgun.net.
+func storageRegion(address string) string {
+	u, err := url.Parse(address)
+	if err != nil {
+		return ""
+	}
+	host := u.Hostname()
+	if !strings.HasPrefix(host, "storage-") {
+		return ""
+	}
+	return strings.SplitN(strings.TrimPrefix(host, "storage-"), ".", 2)[0]
+}
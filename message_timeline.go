@@ -0,0 +1,149 @@
+package mailgun
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// MessageID returns the ID of a sent message as the events API reports it, without the angle
+// brackets of the ID returned by `Send()`.
+func MessageID(id string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(id), "<"), ">")
+}
+
+// MessageTimeline is the history of a single message, built from its events.
+type MessageTimeline struct {
+	// MessageID is the ID of the message, without angle brackets.
+	MessageID string
+	// Rejected is the event of a message Mailgun refused to send, if any.
+	Rejected *events.Rejected
+	// Recipients are the timelines of the recipients of the message, in the order first seen.
+	Recipients []RecipientTimeline
+	// Events are all the events of the message, oldest first.
+	Events []Event
+}
+
+// RecipientTimeline is the history of a message for one of its recipients:
+// accepted, then delivered or failed, then opened and clicked.
+type RecipientTimeline struct {
+	// Recipient is the normalized address of the recipient.
+	Recipient string
+	Accepted  *events.Accepted
+	Delivered *events.Delivered
+	// Failed holds the temporary failures of each delivery attempt, and the permanent failure if
+	// Mailgun gave up, oldest first.
+	Failed    []*events.Failed
+	Opened    []*events.Opened
+	Clicked   []*events.Clicked
+	Complaint *events.Complained
+}
+
+// Status returns the delivery status of the recipient: one of RecipientQueued, RecipientAccepted,
+// RecipientDeferred, RecipientDelivered or RecipientFailed.
+func (r RecipientTimeline) Status() string {
+	switch {
+	case r.Delivered != nil:
+		return RecipientDelivered
+	case len(r.Failed) != 0 && r.Failed[len(r.Failed)-1].Severity == "temporary":
+		return RecipientDeferred
+	case len(r.Failed) != 0:
+		return RecipientFailed
+	case r.Accepted != nil:
+		return RecipientAccepted
+	}
+	return RecipientQueued
+}
+
+// GetMessageTimeline fetches the events of the message with the ID returned by `Send()`, and
+// returns its timeline for each recipient. Support teams can answer "did my email arrive?" with it:
+//
+//  _, id, err := mg.Send(ctx, m)
+//  // ...later
+//  timeline, err := mg.GetMessageTimeline(ctx, id)
+//  for _, r := range timeline.Recipients {
+//    fmt.Printf("%s: %s, opened %d times\n", r.Recipient, r.Status(), len(r.Opened))
+//  }
+func (mg *MailgunImpl) GetMessageTimeline(ctx context.Context, id string) (*MessageTimeline, error) {
+	it := mg.ListEvents(&ListEventOptions{
+		Filter: map[string]string{"message-id": MessageID(id)},
+	})
+	var all, page []Event
+	for it.Next(ctx, &page) {
+		all = append(all, page...)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return NewMessageTimeline(id, all), nil
+}
+
+// NewMessageTimeline builds the timeline of the message with the ID id from evs, in any order.
+// Events of other messages, and events which are not part of a delivery timeline, are ignored.
+func NewMessageTimeline(id string, evs []Event) *MessageTimeline {
+	t := &MessageTimeline{MessageID: MessageID(id)}
+	sorted := append([]Event(nil), evs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].GetTimestamp().Before(sorted[j].GetTimestamp())
+	})
+
+	for _, e := range sorted {
+		switch e := e.(type) {
+		case *events.Accepted:
+			if e.Message.Headers.MessageID == t.MessageID {
+				if r := t.recipient(e.Recipient); r.Accepted == nil {
+					r.Accepted = e
+				}
+				t.Events = append(t.Events, e)
+			}
+		case *events.Rejected:
+			if e.Message.Headers.MessageID == t.MessageID {
+				t.Rejected = e
+				t.Events = append(t.Events, e)
+			}
+		case *events.Delivered:
+			if e.Message.Headers.MessageID == t.MessageID {
+				t.recipient(e.Recipient).Delivered = e
+				t.Events = append(t.Events, e)
+			}
+		case *events.Failed:
+			if e.Message.Headers.MessageID == t.MessageID {
+				r := t.recipient(e.Recipient)
+				r.Failed = append(r.Failed, e)
+				t.Events = append(t.Events, e)
+			}
+		case *events.Opened:
+			if e.Message.Headers.MessageID == t.MessageID {
+				r := t.recipient(e.Recipient)
+				r.Opened = append(r.Opened, e)
+				t.Events = append(t.Events, e)
+			}
+		case *events.Clicked:
+			if e.Message.Headers.MessageID == t.MessageID {
+				r := t.recipient(e.Recipient)
+				r.Clicked = append(r.Clicked, e)
+				t.Events = append(t.Events, e)
+			}
+		case *events.Complained:
+			if e.Message.Headers.MessageID == t.MessageID {
+				t.recipient(e.Recipient).Complaint = e
+				t.Events = append(t.Events, e)
+			}
+		}
+	}
+	return t
+}
+
+// recipient returns the timeline of the recipient, adding it if it is new.
+func (t *MessageTimeline) recipient(address string) *RecipientTimeline {
+	address = normalizeAddress(address)
+	for i := range t.Recipients {
+		if t.Recipients[i].Recipient == address {
+			return &t.Recipients[i]
+		}
+	}
+	t.Recipients = append(t.Recipients, RecipientTimeline{Recipient: address})
+	return &t.Recipients[len(t.Recipients)-1]
+}
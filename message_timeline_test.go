@@ -0,0 +1,79 @@
+package mailgun_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+func TestGetMessageTimeline(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	m := mg.NewMessage("root@"+testDomain, "Subject", "Text Body", "Timeline <Timeline@Mailgun.Test>")
+	_, id, err := mg.Send(ctx, m)
+	ensure.Nil(t, err)
+
+	timeline, err := mg.GetMessageTimeline(ctx, id)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, timeline.MessageID, mailgun.MessageID(id))
+	ensure.DeepEqual(t, len(timeline.Recipients), 1)
+	ensure.DeepEqual(t, timeline.Recipients[0].Recipient, "timeline@mailgun.test")
+	ensure.DeepEqual(t, timeline.Recipients[0].Status(), mailgun.RecipientAccepted)
+}
+
+func TestNewMessageTimeline(t *testing.T) {
+	const id = "timeline-id@example.com"
+	now := time.Now()
+	at := func(e mailgun.Event, d time.Duration) mailgun.Event {
+		e.SetTimestamp(now.Add(d))
+		return e
+	}
+	headers := events.Message{Headers: events.MessageHeaders{MessageID: id}}
+
+	accepted := &events.Accepted{Recipient: "One@example.com", Message: headers}
+	deferred := &events.Failed{Recipient: "one@example.com", Message: headers, Severity: "temporary"}
+	delivered := &events.Delivered{Recipient: "one@example.com", Message: headers}
+	opened := &events.Opened{Recipient: "one@example.com", Message: headers}
+	clicked := &events.Clicked{Recipient: "one@example.com", Message: headers, Url: "https://example.com"}
+	bounced := &events.Failed{Recipient: "two@example.com", Message: headers, Severity: "permanent"}
+	other := &events.Delivered{Recipient: "one@example.com"}
+	other.Message.Headers.MessageID = "other-id@example.com"
+
+	// Events arrive newest first from the events API
+	timeline := mailgun.NewMessageTimeline("<"+id+">", []mailgun.Event{
+		at(clicked, 5*time.Second),
+		at(opened, 4*time.Second),
+		at(delivered, 3*time.Second),
+		at(bounced, 2*time.Second),
+		at(deferred, time.Second),
+		at(accepted, 0),
+		at(other, 0),
+	})
+
+	ensure.DeepEqual(t, timeline.MessageID, id)
+	ensure.DeepEqual(t, len(timeline.Events), 6)
+	ensure.DeepEqual(t, timeline.Events[0], mailgun.Event(accepted))
+	ensure.DeepEqual(t, len(timeline.Recipients), 2)
+
+	one := timeline.Recipients[0]
+	ensure.DeepEqual(t, one.Recipient, "one@example.com")
+	ensure.DeepEqual(t, one.Accepted, accepted)
+	ensure.DeepEqual(t, one.Failed, []*events.Failed{deferred})
+	ensure.DeepEqual(t, one.Delivered, delivered)
+	ensure.DeepEqual(t, one.Opened, []*events.Opened{opened})
+	ensure.DeepEqual(t, one.Clicked, []*events.Clicked{clicked})
+	ensure.DeepEqual(t, one.Status(), mailgun.RecipientDelivered)
+
+	two := timeline.Recipients[1]
+	ensure.DeepEqual(t, two.Recipient, "two@example.com")
+	ensure.DeepEqual(t, two.Status(), mailgun.RecipientFailed)
+
+	ensure.DeepEqual(t, mailgun.RecipientTimeline{Failed: []*events.Failed{deferred}}.Status(), mailgun.RecipientDeferred)
+	ensure.DeepEqual(t, mailgun.RecipientTimeline{}.Status(), mailgun.RecipientQueued)
+}
@@ -6,16 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
-)
-
-// MaxNumberOfRecipients represents the largest batch of recipients that Mailgun can support in a single API call.
-// This figure includes To:, Cc:, Bcc:, etc. recipients.
-const MaxNumberOfRecipients = 1000
 
-// MaxNumberOfTags represents the maximum number of tags that can be added for a message
-const MaxNumberOfTags = 3
+	"github.com/yjimk/mailgun-go/v4/events"
+)
 
 // Message structures contain both the message text and the envelop for an e-mail message.
 type Message struct {
@@ -29,6 +29,13 @@ type Message struct {
 	inlines           []string
 	readerInlines     []ReaderAttachment
 	bufferAttachments []BufferAttachment
+	// inlineImages maps the filenames passed to AddInlineImage to their Content-IDs
+	inlineImages        map[string]string
+	contentTypes        map[string]string
+	rewriteInlineImages bool
+	textFromHtml        bool
+	tagLimit            int
+	truncateTags        bool
 
 	nativeSend         bool
 	testMode           bool
@@ -36,9 +43,11 @@ type Message struct {
 	trackingClicks     bool
 	trackingOpens      bool
 	headers            map[string]string
+	extraHeaders       map[string][]string
 	variables          map[string]string
 	templateVariables  map[string]interface{}
 	recipientVariables map[string]map[string]interface{}
+	recipientTracking  map[string]recipientTracking
 	domain             string
 	templateVersionTag string
 	templateRenderText bool
@@ -48,10 +57,18 @@ type Message struct {
 	trackingClicksSet bool
 	trackingOpensSet  bool
 	requireTLS        bool
+	requireTLSSet     bool
 	skipVerification  bool
+	sendingIP         string
+	ipPool            string
+	secondaryDKIM     dkimSignature
+	secondaryDKIMPub  dkimSignature
+	fields            []keyValuePair
 
-	specific features
-	mg       Mailgun
+	specific       features
+	mg             Mailgun
+	mailingList    *MailingList
+	idempotencyKey string
 }
 
 type ReaderAttachment struct {
@@ -137,7 +154,7 @@ type sendMessageResponse struct {
 // addCC, addBCC, recipientCount, setHtml and setAMPHtml are invoked via the package-global AddCC, AddBCC,
 // RecipientCount, SetHtml and SetAMPHtml calls, as these functions are ignored for MIME messages.
 // Send() invokes addValues to add message-type-specific MIME headers for the API call
-// to Mailgun.  isValid yeilds true if and only if the message is valid enough for sending
+// to Mailgun.  validate yields nil if and only if the message is valid enough for sending
 // through the API.  Finally, endpoint() tells Send() which endpoint to use to submit the API call.
 type features interface {
 	addCC(string)
@@ -145,7 +162,7 @@ type features interface {
 	setHtml(string)
 	setAMPHtml(string)
 	addValues(*formDataPayload)
-	isValid() bool
+	validate() error
 	endpoint() string
 	recipientCount() int
 	setTemplate(string)
@@ -354,15 +371,75 @@ func (mm *mimeMessage) setAMPHtml(_ string) {}
 
 // AddTag attaches tags to the message.  Tags are useful for metrics gathering and event tracking purposes.
 // Refer to the Mailgun documentation for further details.
+//
+// Tags are checked against Mailgun's constraints, which would otherwise reject them or drop them from
+// analytics: at most MaxNumberOfTags per message, or the limit set with `SetTagLimit()`, each of at most
+// MaxTagLength printable ASCII characters. Tags which break them are returned as a *ValidationError
+// for the field o:tag, and none of the tags are added. Tags differing only in case are the same to
+// Mailgun, and are added once. See `SetTagTruncation()` to shorten long tags instead.
 func (m *Message) AddTag(tag ...string) error {
-	if len(m.tags) >= MaxNumberOfTags {
-		return fmt.Errorf("cannot add any new tags. Message tag limit (%d) reached", MaxNumberOfTags)
+	limit := m.tagLimit
+	if limit == 0 {
+		limit = MaxNumberOfTags
+	}
+
+	tags := m.tags
+	for _, t := range tag {
+		if m.truncateTags && len(t) > MaxTagLength {
+			t = t[:MaxTagLength]
+		}
+		if err := validateTag(t); err != nil {
+			return err
+		}
+		if containsFold(tags, t) {
+			continue
+		}
+		if len(tags) >= limit {
+			return newValidationError("o:tag", "cannot add tag '%s'; message tag limit (%d) reached", t, limit)
+		}
+		tags = append(tags, t)
 	}
+	m.tags = tags
+	return nil
+}
+
+// SetTagLimit sets the number of tags `AddTag()` accepts for the message, for accounts whose limit
+// differs from MaxNumberOfTags. Zero restores the default.
+func (m *Message) SetTagLimit(limit int) {
+	m.tagLimit = limit
+}
 
-	m.tags = append(m.tags, tag...)
+// SetTagTruncation enables or disables shortening tags longer than MaxTagLength to fit, rather than
+// rejecting them in `AddTag()`. It is disabled by default.
+func (m *Message) SetTagTruncation(enabled bool) {
+	m.truncateTags = enabled
+}
+
+// validateTag returns a *ValidationError if Mailgun would not accept the tag.
+func validateTag(tag string) error {
+	if strings.TrimSpace(tag) == "" {
+		return newValidationError("o:tag", "tag must not be empty")
+	}
+	if len(tag) > MaxTagLength {
+		return newValidationError("o:tag", "tag '%s...' is longer than %d characters", tag[:32], MaxTagLength)
+	}
+	for _, c := range tag {
+		if c < ' ' || c > '~' {
+			return newValidationError("o:tag", "tag '%s' contains %q; only printable ASCII characters are allowed", tag, c)
+		}
+	}
 	return nil
 }
 
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
 // SetTemplate sets the name of a template stored via the template API.
 // See https://documentation.mailgun.com/en/latest/user_manual.html#templating
 func (m *Message) SetTemplate(t string) {
@@ -387,6 +464,46 @@ func (m *Message) SetDKIM(dkim bool) {
 	m.dkimSet = true
 }
 
+// SetSecondaryDKIM signs the message a second time, with the DKIM key of domain published under
+// selector, as the o:secondary-dkim parameter. Senders using a delegated subdomain use it so the
+// message also carries a signature aligned with the organizational domain of its From: address.
+// The key of domain must be known to Mailgun. `Send()` returns a *ValidationError if either is
+// empty or contains a '/'.
+func (m *Message) SetSecondaryDKIM(domain, selector string) {
+	m.secondaryDKIM = dkimSignature{domain: domain, selector: selector}
+}
+
+// SetSecondaryDKIMPublic sets the domain and selector the secondary DKIM signature set with
+// `SetSecondaryDKIM()` names in its d= and s= tags, as the o:secondary-dkim-public parameter, for
+// when the key of the secondary domain is published under another name, such as a CNAME to the
+// key of the sending domain.
+func (m *Message) SetSecondaryDKIMPublic(domain, selector string) {
+	m.secondaryDKIMPub = dkimSignature{domain: domain, selector: selector}
+}
+
+// dkimSignature names the key of a DKIM signature.
+type dkimSignature struct {
+	domain, selector string
+}
+
+func (s dkimSignature) isSet() bool {
+	return s.domain != "" || s.selector != ""
+}
+
+func (s dkimSignature) String() string {
+	return s.domain + "/" + s.selector
+}
+
+func (s dkimSignature) validate(param string) error {
+	if s.domain == "" || s.selector == "" {
+		return newValidationError(param, "both a domain and a selector are required, got '%s'", s)
+	}
+	if strings.Contains(s.domain, "/") || strings.Contains(s.selector, "/") {
+		return newValidationError(param, "domain and selector must not contain '/', got '%s'", s)
+	}
+	return nil
+}
+
 // EnableNativeSend allows the return path to match the address in the Message.Headers.From:
 // field when sending from Mailgun rather than the usual bounce+ address in the return path.
 func (m *Message) EnableNativeSend() {
@@ -427,6 +544,7 @@ func (m *Message) SetTrackingClicks(trackingClicks bool) {
 // SetRequireTLS information is found in the Mailgun documentation.
 func (m *Message) SetRequireTLS(b bool) {
 	m.requireTLS = b
+	m.requireTLSSet = true
 }
 
 // SetSkipVerification information is found in the Mailgun documentation.
@@ -434,6 +552,29 @@ func (m *Message) SetSkipVerification(b bool) {
 	m.skipVerification = b
 }
 
+// SetSendingIP sends the message from one of the dedicated IPs of the account, as the
+// o:sending-ip parameter, rather than from the IPs assigned to the domain. `Send()` returns a
+// *ValidationError if ip is not an IP address.
+func (m *Message) SetSendingIP(ip string) {
+	m.sendingIP = ip
+}
+
+// SetIPPool sends the message from the IP pool with the id, as the o:sending-ip-pool parameter,
+// so that messages of different kinds, such as marketing and transactional mail, build the
+// reputation of different IPs. A message may set a sending IP or an IP pool, but not both.
+func (m *Message) SetIPPool(id string) {
+	m.ipPool = id
+}
+
+// AddField sends a form field with the message as it is, such as an option Mailgun supports which
+// has no method of its own yet. Fields are sent after those set by the other methods, and a field
+// added more than once is sent once per value.
+//
+//  m.AddField("o:tracking-pixel-location-top", "yes")
+func (m *Message) AddField(name, value string) {
+	m.fields = append(m.fields, keyValuePair{key: name, value: value})
+}
+
 //SetTrackingOpens information is found in the Mailgun documentation.
 func (m *Message) SetTrackingOpens(trackingOpens bool) {
 	m.trackingOpens = trackingOpens
@@ -450,25 +591,71 @@ func (m *Message) SetTemplateRenderText(render bool) {
 	m.templateRenderText = render
 }
 
+// reservedHeaders are set by Mailgun from the message itself, and may not be sent as custom headers.
+var reservedHeaders = []string{
+	"From", "To", "Cc", "Bcc", "Subject",
+	"Content-Type", "Content-Transfer-Encoding", "MIME-Version",
+}
+
 // AddHeader allows you to send custom MIME headers with the message.
+// It replaces all values of the header added before, ignoring the case of its name.
+// Headers Mailgun sets from the message itself, such as To or Subject, are reserved;
+// `Send()` returns a *ValidationError if one is added.
 func (m *Message) AddHeader(header, value string) {
 	if m.headers == nil {
 		m.headers = make(map[string]string)
 	}
+	if key, ok := headerKey(m.headers, header); ok {
+		delete(m.headers, key)
+		delete(m.extraHeaders, key)
+	}
 	m.headers[header] = value
 }
 
+// AppendHeader adds another value of a custom MIME header, which is sent once per value,
+// such as a second References or X- header. If the header was not added before, AppendHeader
+// behaves as `AddHeader()`.
+func (m *Message) AppendHeader(header, value string) {
+	key, ok := headerKey(m.headers, header)
+	if !ok {
+		m.AddHeader(header, value)
+		return
+	}
+	if m.extraHeaders == nil {
+		m.extraHeaders = make(map[string][]string)
+	}
+	m.extraHeaders[key] = append(m.extraHeaders[key], value)
+}
+
+// GetHeaderValues returns all values of a custom MIME header, ignoring the case of its name.
+func (m *Message) GetHeaderValues(header string) []string {
+	key, ok := headerKey(m.headers, header)
+	if !ok {
+		return nil
+	}
+	return append([]string{m.headers[key]}, m.extraHeaders[key]...)
+}
+
 // AddVariable lets you associate a set of variables with messages you send,
 // which Mailgun can use to, in essence, complete form-mail.
 // Refer to the Mailgun documentation for more information.
+//
+// The value may be any Go value encoding/json can marshal, such as a struct, a map or a type
+// implementing json.Marshaler; strings are sent as they are, and other values as JSON, which
+// Mailgun returns decoded in the user-variables of events. A value which cannot be marshaled is
+// returned as a *VariableError. Variables whose names and values add up to more than
+// MaxVariablesSize bytes are returned as a *ValidationError for the field of the variable.
+// In both cases the variable is not added; a variable added again replaces the previous value.
+//
+//  type order struct {
+//    ID    string  `json:"id"`
+//    Total float64 `json:"total"`
+//  }
+//  err := m.AddVariable("order", order{ID: "A-1001", Total: 42.5})
 func (m *Message) AddVariable(variable string, value interface{}) error {
-	if m.variables == nil {
-		m.variables = make(map[string]string)
-	}
-
 	j, err := json.Marshal(value)
 	if err != nil {
-		return err
+		return &VariableError{Variable: variable, Err: err}
 	}
 
 	encoded := string(j)
@@ -477,10 +664,39 @@ func (m *Message) AddVariable(variable string, value interface{}) error {
 		v = encoded
 	}
 
+	size := len(variable) + len(v)
+	for name, value := range m.variables {
+		if name != variable {
+			size += len(name) + len(value)
+		}
+	}
+	if size > MaxVariablesSize {
+		return newValidationError("v:"+variable, "variables total %d bytes, over the limit of %d bytes", size, MaxVariablesSize)
+	}
+
+	if m.variables == nil {
+		m.variables = make(map[string]string)
+	}
 	m.variables[variable] = v
 	return nil
 }
 
+// VariableError is returned by `AddVariable()` when the value of a variable cannot be marshaled
+// to JSON, such as a channel, a function, or a type whose MarshalJSON method fails.
+type VariableError struct {
+	Variable string
+	Err      error
+}
+
+func (e *VariableError) Error() string {
+	return fmt.Sprintf("cannot encode variable '%s' as JSON: %s", e.Variable, e.Err)
+}
+
+// Unwrap returns the error of encoding/json.
+func (e *VariableError) Unwrap() error {
+	return e.Err
+}
+
 // AddTemplateVariable adds a template variable to the map of template variables, replacing the variable if it is already there.
 // This is used for server-side message templates and can nest arbitrary values. At send time, the resulting map will be converted into
 // a JSON string and sent as a header in the X-Mailgun-Variables header.
@@ -497,7 +713,21 @@ func (m *Message) AddDomain(domain string) {
 	m.domain = domain
 }
 
-// GetHeaders retrieves the http headers associated with this message
+// SetMailingList marks the message as mail to the mailing list, so `Send()` adds the List-Id,
+// List-Post and List-Unsubscribe headers derived from it. Headers set with `AddHeader()` take
+// precedence. See `SetListHeaders()` to look up lists automatically instead.
+func (m *Message) SetMailingList(list MailingList) {
+	m.mailingList = &list
+}
+
+// SetIdempotencyKey identifies the message, so that calling `Send()` again with the same key within
+// the client's idempotency window does not submit it twice. See `SetIdempotencyWindow()`.
+func (m *Message) SetIdempotencyKey(key string) {
+	m.idempotencyKey = key
+}
+
+// GetHeaders retrieves the http headers associated with this message.
+// Only the first value of headers added with `AppendHeader()` is included; see `GetHeaderValues()`.
 func (m *Message) GetHeaders() map[string]string {
 	return m.headers
 }
@@ -505,11 +735,109 @@ func (m *Message) GetHeaders() map[string]string {
 // ErrInvalidMessage is returned by `Send()` when the `mailgun.Message` struct is incomplete
 var ErrInvalidMessage = errors.New("message not valid")
 
+// ValidationError is returned by `Send()` when a message fails client-side validation,
+// before any request is made to Mailgun. Field names the offending API parameter.
+// ValidationError wraps ErrInvalidMessage, so `errors.Is(err, ErrInvalidMessage)` holds.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrInvalidMessage, e.Field, e.Reason)
+}
+
+// Unwrap returns ErrInvalidMessage
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidMessage
+}
+
+func newValidationError(field, format string, args ...interface{}) error {
+	return &ValidationError{Field: field, Reason: fmt.Sprintf(format, args...)}
+}
+
+// SendableMessage is a message `Send()` can submit. *Message implements it; implement it to send
+// messages built without the Message builder, for example from protobuf types or a templating
+// system of your own. FormFields and FormFiles return the parameters of the messages API, see
+// https://documentation.mailgun.com/en/latest/api-sending.html
+//
+//  type ReceiptMessage struct{ Order *pb.Order }
+//
+//  func (m ReceiptMessage) Domain() string   { return "" }
+//  func (m ReceiptMessage) Endpoint() string { return "messages" }
+//  func (m ReceiptMessage) FormFields() ([]mailgun.FormField, error) {
+//    return []mailgun.FormField{
+//      {Key: "from", Value: "shop@example.com"},
+//      {Key: "to", Value: m.Order.Email},
+//      {Key: "subject", Value: "Your receipt"},
+//      {Key: "template", Value: "receipt"},
+//      {Key: "o:tag", Value: "receipt"},
+//    }, nil
+//  }
+//  func (m ReceiptMessage) FormFiles() ([]mailgun.FormFile, error) { return nil, nil }
+type SendableMessage interface {
+	// Domain returns the domain to send the message from, or "" for the domain of the client.
+	Domain() string
+	// Endpoint returns "messages" for a message built from its parts, or "messages.mime" for
+	// a MIME message uploaded as the "message" file.
+	Endpoint() string
+	// FormFields returns the form fields of the request, such as from, to, text, o:tag or h:Reply-To.
+	FormFields() ([]FormField, error)
+	// FormFiles returns the files of the request, such as attachment, inline or message.
+	FormFiles() ([]FormFile, error)
+}
+
+// Domain returns the domain set with `AddDomain()`, or "" if the message is sent from the domain of the client.
+func (m *Message) Domain() string {
+	return m.domain
+}
+
+// Endpoint returns the messages API endpoint the message is sent to.
+func (m *Message) Endpoint() string {
+	return m.specific.endpoint()
+}
+
+// FormFields returns the form fields `Send()` submits for the message. Headers of a list set with
+// `SetMailingList()` are included; lists found by `SetListHeaders()` are not.
+func (m *Message) FormFields() ([]FormField, error) {
+	payload := newFormDataPayload()
+	if err := m.addValues(payload); err != nil {
+		return nil, err
+	}
+	if m.mailingList != nil {
+		m.addListHeaders(payload, m.mailingList)
+	}
+	fields := make([]FormField, 0, len(payload.Values))
+	for _, v := range payload.Values {
+		fields = append(fields, FormField{Key: v.key, Value: v.value})
+	}
+	return fields, nil
+}
+
+// FormFiles returns the attachments, inlines and MIME body `Send()` submits for the message.
+// Attachments added with `AddReaderAttachment()` and the body of a MIME message are read and
+// closed, so the message cannot be sent afterwards.
+func (m *Message) FormFiles() ([]FormFile, error) {
+	payload := newFormDataPayload()
+	if err := m.addValues(payload); err != nil {
+		return nil, err
+	}
+	if err := payload.bufferFiles(); err != nil {
+		return nil, err
+	}
+	var files []FormFile
+	for _, b := range payload.Buffers {
+		files = append(files, FormFile{Key: b.key, Filename: b.name, ContentType: payload.contentTypes[b.name], Data: b.value})
+	}
+	return files, nil
+}
+
 // Send attempts to queue a message (see Message, NewMessage, and its methods) for delivery.
 // It returns the Mailgun server response, which consists of two components:
 // a human-readable status message, and a message ID.  The status and message ID are set only
-// if no error occurred.
-func (mg *MailgunImpl) Send(ctx context.Context, message *Message) (mes string, id string, err error) {
+// if no error occurred. Messages other than *Message are sent as returned by their
+// SendableMessage methods.
+func (mg *MailgunImpl) Send(ctx context.Context, sendable SendableMessage) (mes string, id string, err error) {
 	if mg.domain == "" {
 		err = errors.New("you must provide a valid domain before calling Send()")
 		return
@@ -520,135 +848,267 @@ func (mg *MailgunImpl) Send(ctx context.Context, message *Message) (mes string,
 		return
 	}
 
-	if !isValid(message) {
-		err = ErrInvalidMessage
+	message, ok := sendable.(*Message)
+	if !ok {
+		return mg.sendMessage(ctx, sendable)
+	}
+
+	if message, err = mg.prepareMessage(ctx, message); err != nil {
 		return
 	}
 	payload := newFormDataPayload()
+	if err = message.addValues(payload); err != nil {
+		return
+	}
+	if list := mg.mailingListFor(ctx, message); list != nil {
+		message.addListHeaders(payload, list)
+	}
 
-	message.specific.addValues(payload)
-	for _, to := range message.to {
-		payload.addValue("to", to)
+	if message.domain == "" {
+		message.domain = mg.Domain()
 	}
-	for _, tag := range message.tags {
+
+	response, err := mg.sendPayload(ctx, message.idempotencyKey, payload, message.domain, message.specific.endpoint())
+	if err == nil {
+		mes = response.Message
+		id = response.Id
+	}
+
+	return
+}
+
+// prepareMessage applies the send defaults, validation and suppression guard of the client to a
+// *Message, and returns the message to send: m itself, or a copy without suppressed recipients.
+func (mg *MailgunImpl) prepareMessage(ctx context.Context, m *Message) (*Message, error) {
+	if err := mg.applySendDefaults(m); err != nil {
+		return nil, err
+	}
+	if err := validateMessage(m); err != nil {
+		return nil, err
+	}
+	return mg.guardRecipients(ctx, m)
+}
+
+// sendPayload sends the payload of a message after the attachment policies, content filters and
+// MIME transformers of the client are applied to it. `Send()` and an Outbox both send through it.
+func (mg *MailgunImpl) sendPayload(ctx context.Context, key string, payload *formDataPayload, domain, endpoint string) (*sendMessageResponse, error) {
+	if err := mg.checkAttachments(ctx, payload); err != nil {
+		return nil, err
+	}
+	if err := mg.filterContent(ctx, payload, domain, endpoint); err != nil {
+		return nil, err
+	}
+	mg.mu.RLock()
+	transformers := mg.transformers
+	mg.mu.RUnlock()
+	if len(transformers) != 0 {
+		var err error
+		if payload, err = transformMIME(ctx, payload, transformers, domain, endpoint); err != nil {
+			return nil, err
+		}
+		endpoint = mimeMessagesEndpoint
+	}
+
+	r := newAPIRequest(mg, generateApiUrlWithDomain(mg, endpoint, domain))
+	return mg.sendIdempotent(ctx, key, r, payload, domain, endpoint)
+}
+
+// sendMessage sends a SendableMessage other than *Message.
+func (mg *MailgunImpl) sendMessage(ctx context.Context, message SendableMessage) (mes string, id string, err error) {
+	if message == nil {
+		return "", "", ErrInvalidMessage
+	}
+	endpoint := message.Endpoint()
+	if endpoint != messagesEndpoint && endpoint != mimeMessagesEndpoint {
+		return "", "", newValidationError("endpoint", "must be '%s' or '%s', got '%s'", messagesEndpoint, mimeMessagesEndpoint, endpoint)
+	}
+
+	payload := newFormDataPayload()
+	fields, err := message.FormFields()
+	if err != nil {
+		return "", "", err
+	}
+	for _, f := range fields {
+		payload.addValue(f.Key, f.Value)
+	}
+	files, err := message.FormFiles()
+	if err != nil {
+		return "", "", err
+	}
+	for _, f := range files {
+		payload.addBuffer(f.Key, f.Filename, f.Data)
+		if f.ContentType != "" {
+			payload.setContentType(f.Filename, f.ContentType)
+		}
+	}
+
+	domain := message.Domain()
+	if domain == "" {
+		domain = mg.Domain()
+	}
+
+	response, err := mg.sendPayload(ctx, "", payload, domain, endpoint)
+	if err == nil {
+		mes = response.Message
+		id = response.Id
+	}
+	return
+}
+
+// addValues adds the fields and files of the message to the payload of the request.
+func (m *Message) addValues(payload *formDataPayload) error {
+	specific := m.specific
+	if pm, ok := specific.(*plainMessage); ok {
+		rewritten := *pm
+		if m.rewriteInlineImages && len(m.inlineImages) != 0 {
+			rewritten.html = rewriteImageSources(pm.html, m.inlineImages)
+		}
+		if m.textFromHtml && pm.text == "" && pm.html != "" {
+			rewritten.text = HtmlToText(pm.html)
+		}
+		specific = &rewritten
+	}
+	specific.addValues(payload)
+	for name, contentType := range m.contentTypes {
+		payload.setContentType(name, contentType)
+	}
+	for _, to := range m.to {
+		payload.addValue("to", encodeAddresses(to))
+	}
+	for _, tag := range m.tags {
 		payload.addValue("o:tag", tag)
 	}
-	for _, campaign := range message.campaigns {
+	for _, campaign := range m.campaigns {
 		payload.addValue("o:campaign", campaign)
 	}
-	if message.dkimSet {
-		payload.addValue("o:dkim", yesNo(message.dkim))
+	if m.dkimSet {
+		payload.addValue("o:dkim", yesNo(m.dkim))
 	}
-	if !message.deliveryTime.IsZero() {
-		payload.addValue("o:deliverytime", formatMailgunTime(message.deliveryTime))
+	if !m.deliveryTime.IsZero() {
+		payload.addValue("o:deliverytime", formatMailgunTime(m.deliveryTime))
 	}
-	if message.nativeSend {
+	if m.nativeSend {
 		payload.addValue("o:native-send", "yes")
 	}
-	if message.testMode {
+	if m.testMode {
 		payload.addValue("o:testmode", "yes")
 	}
-	if message.trackingSet {
-		payload.addValue("o:tracking", yesNo(message.tracking))
+	if m.trackingSet {
+		payload.addValue("o:tracking", yesNo(m.tracking))
 	}
-	if message.trackingClicksSet {
-		payload.addValue("o:tracking-clicks", yesNo(message.trackingClicks))
+	if m.trackingClicksSet {
+		payload.addValue("o:tracking-clicks", yesNo(m.trackingClicks))
 	}
-	if message.trackingOpensSet {
-		payload.addValue("o:tracking-opens", yesNo(message.trackingOpens))
+	if m.trackingOpensSet {
+		payload.addValue("o:tracking-opens", yesNo(m.trackingOpens))
 	}
-	if message.requireTLS {
-		payload.addValue("o:require-tls", trueFalse(message.requireTLS))
+	if m.requireTLS {
+		payload.addValue("o:require-tls", trueFalse(m.requireTLS))
 	}
-	if message.skipVerification {
-		payload.addValue("o:skip-verification", trueFalse(message.skipVerification))
+	if m.skipVerification {
+		payload.addValue("o:skip-verification", trueFalse(m.skipVerification))
 	}
-	if message.headers != nil {
-		for header, value := range message.headers {
-			payload.addValue("h:"+header, value)
-		}
+	if m.sendingIP != "" {
+		payload.addValue("o:sending-ip", m.sendingIP)
+	}
+	if m.ipPool != "" {
+		payload.addValue("o:sending-ip-pool", m.ipPool)
 	}
-	if message.variables != nil {
-		for variable, value := range message.variables {
-			payload.addValue("v:"+variable, value)
+	if m.secondaryDKIM.isSet() {
+		payload.addValue("o:secondary-dkim", m.secondaryDKIM.String())
+	}
+	if m.secondaryDKIMPub.isSet() {
+		payload.addValue("o:secondary-dkim-public", m.secondaryDKIMPub.String())
+	}
+	for _, f := range m.fields {
+		payload.addValue(f.key, f.value)
+	}
+	// Headers and variables are added in order of their names, so the payload of a message is
+	// always the same
+	for _, header := range sortedKeys(m.headers) {
+		payload.addValue("h:"+header, encodeHeader(header, m.headers[header]))
+		for _, extra := range m.extraHeaders[header] {
+			payload.addValue("h:"+header, encodeHeader(header, extra))
 		}
 	}
-	if message.templateVariables != nil {
-		variableString, err := json.Marshal(message.templateVariables)
+	for _, variable := range sortedKeys(m.variables) {
+		payload.addValue("v:"+variable, m.variables[variable])
+	}
+	if m.templateVariables != nil {
+		variableString, err := json.Marshal(m.templateVariables)
 		if err == nil {
 			// the map was marshalled as json so add it
 			payload.addValue("h:X-Mailgun-Variables", string(variableString))
 		}
 	}
-	if message.recipientVariables != nil {
-		j, err := json.Marshal(message.recipientVariables)
+	if m.recipientVariables != nil {
+		// Keys must match the recipients as sent
+		vars := make(map[string]map[string]interface{}, len(m.recipientVariables))
+		for recipient, v := range m.recipientVariables {
+			vars[encodeAddresses(recipient)] = v
+		}
+		j, err := json.Marshal(vars)
 		if err != nil {
-			return "", "", err
+			return err
 		}
 		payload.addValue("recipient-variables", string(j))
 	}
-	if message.attachments != nil {
-		for _, attachment := range message.attachments {
+	if m.attachments != nil {
+		for _, attachment := range m.attachments {
 			payload.addFile("attachment", attachment)
 		}
 	}
-	if message.readerAttachments != nil {
-		for _, readerAttachment := range message.readerAttachments {
+	if m.readerAttachments != nil {
+		for _, readerAttachment := range m.readerAttachments {
 			payload.addReadCloser("attachment", readerAttachment.Filename, readerAttachment.ReadCloser)
 		}
 	}
-	if message.bufferAttachments != nil {
-		for _, bufferAttachment := range message.bufferAttachments {
+	if m.bufferAttachments != nil {
+		for _, bufferAttachment := range m.bufferAttachments {
 			payload.addBuffer("attachment", bufferAttachment.Filename, bufferAttachment.Buffer)
 		}
 	}
-	if message.inlines != nil {
-		for _, inline := range message.inlines {
+	if m.inlines != nil {
+		for _, inline := range m.inlines {
 			payload.addFile("inline", inline)
 		}
 	}
 
-	if message.readerInlines != nil {
-		for _, readerAttachment := range message.readerInlines {
+	if m.readerInlines != nil {
+		for _, readerAttachment := range m.readerInlines {
 			payload.addReadCloser("inline", readerAttachment.Filename, readerAttachment.ReadCloser)
 		}
 	}
 
-	if message.domain == "" {
-		message.domain = mg.Domain()
+	if m.templateVersionTag != "" {
+		payload.addValue("t:version", m.templateVersionTag)
 	}
 
-	if message.templateVersionTag != "" {
-		payload.addValue("t:version", message.templateVersionTag)
+	if m.templateRenderText {
+		payload.addValue("t:text", yesNo(m.templateRenderText))
 	}
+	return nil
+}
 
-	if message.templateRenderText {
-		payload.addValue("t:text", yesNo(message.templateRenderText))
-	}
-
-	r := newHTTPRequest(generateApiUrlWithDomain(mg, message.specific.endpoint(), message.domain))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-
-	var response sendMessageResponse
-	err = postResponseFromJSON(ctx, r, payload, &response)
-	if err == nil {
-		mes = response.Message
-		id = response.Id
+// addListHeaders adds the headers of mail to the list which were not set on the message.
+func (m *Message) addListHeaders(payload *formDataPayload, list *MailingList) {
+	headers := listHeaders(*list)
+	for _, header := range sortedKeys(headers) {
+		if !hasHeader(m.headers, header) {
+			payload.addValue("h:"+header, headers[header])
+		}
 	}
-
-	return
 }
 
 func (pm *plainMessage) addValues(p *formDataPayload) {
-	p.addValue("from", pm.from)
+	p.addValue("from", encodeAddresses(pm.from))
 	p.addValue("subject", pm.subject)
 	p.addValue("text", pm.text)
 	for _, cc := range pm.cc {
-		p.addValue("cc", cc)
+		p.addValue("cc", encodeAddresses(cc))
 	}
 	for _, bcc := range pm.bcc {
-		p.addValue("bcc", bcc)
+		p.addValue("bcc", encodeAddresses(bcc))
 	}
 	if pm.html != "" {
 		p.addValue("html", pm.html)
@@ -673,6 +1133,16 @@ func (mm *mimeMessage) endpoint() string {
 	return mimeMessagesEndpoint
 }
 
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // yesNo translates a true/false boolean value into a yes/no setting suitable for the Mailgun API.
 func yesNo(b bool) string {
 	if b {
@@ -688,59 +1158,127 @@ func trueFalse(b bool) string {
 	return "false"
 }
 
-// isValid returns true if, and only if,
+// validateMessage returns nil if, and only if,
 // a Message instance is sufficiently initialized to send via the Mailgun interface.
-func isValid(m *Message) bool {
+// Otherwise a *ValidationError is returned naming the offending field.
+func validateMessage(m *Message) error {
 	if m == nil {
-		return false
+		return ErrInvalidMessage
 	}
 
-	if !m.specific.isValid() {
-		return false
+	if err := m.specific.validate(); err != nil {
+		return err
 	}
 
-	if m.RecipientCount() == 0 {
-		return false
+	if !validateStringList(m.to, false) {
+		return newValidationError("to", "recipient address must not be empty")
+	}
+
+	count := m.RecipientCount()
+	if count == 0 {
+		return newValidationError("to", "at least one recipient is required")
+	}
+	if count > MaxNumberOfRecipients {
+		return newValidationError("to", "recipient limit exceeded (max %d, got %d)", MaxNumberOfRecipients, count)
+	}
+	if len(m.recipientTracking) != 0 {
+		return newValidationError("o:tracking", "messages with per-recipient tracking must be sent with SendBatch()")
 	}
 
 	if !validateStringList(m.tags, false) {
-		return false
+		return newValidationError("o:tag", "tag must not be empty")
 	}
 
 	if !validateStringList(m.campaigns, false) || len(m.campaigns) > 3 {
-		return false
+		return newValidationError("o:campaign", "must provide at most 3 non-empty campaigns")
+	}
+
+	if m.sendingIP != "" && net.ParseIP(m.sendingIP) == nil {
+		return newValidationError("o:sending-ip", "'%s' is not an IP address", m.sendingIP)
+	}
+	if m.sendingIP != "" && m.ipPool != "" {
+		return newValidationError("o:sending-ip-pool", "cannot set both a sending IP and an IP pool")
+	}
+	if m.secondaryDKIM.isSet() {
+		if err := m.secondaryDKIM.validate("o:secondary-dkim"); err != nil {
+			return err
+		}
+	}
+	if m.secondaryDKIMPub.isSet() {
+		if !m.secondaryDKIM.isSet() {
+			return newValidationError("o:secondary-dkim-public", "requires a secondary DKIM signature")
+		}
+		if err := m.secondaryDKIMPub.validate("o:secondary-dkim-public"); err != nil {
+			return err
+		}
 	}
 
-	return true
+	for _, header := range reservedHeaders {
+		if key, ok := headerKey(m.headers, header); ok {
+			return newValidationError("h:"+key, "header is reserved; set it with the Message methods instead")
+		}
+	}
+
+	size, err := m.attachmentSize()
+	if err != nil {
+		return newValidationError("attachment", err.Error())
+	}
+	if size > MaxMessageSize {
+		return newValidationError("attachment", "total attachment size %d exceeds limit of %d bytes", size, MaxMessageSize)
+	}
+
+	return nil
 }
 
-func (pm *plainMessage) isValid() bool {
+// attachmentSize returns the combined size of all attachments and inlines whose size is
+// known ahead of time. Attachments provided as an io.ReadCloser are not counted.
+func (m *Message) attachmentSize() (int64, error) {
+	var size int64
+	for _, b := range m.bufferAttachments {
+		size += int64(len(b.Buffer))
+	}
+	for _, files := range [][]string{m.attachments, m.inlines} {
+		for _, file := range files {
+			fi, err := os.Stat(file)
+			if err != nil {
+				return 0, err
+			}
+			size += fi.Size()
+		}
+	}
+	return size, nil
+}
+
+func (pm *plainMessage) validate() error {
 	if pm.from == "" {
-		return false
+		return newValidationError("from", "sender address is required")
 	}
 
 	if !validateStringList(pm.cc, false) {
-		return false
+		return newValidationError("cc", "recipient address must not be empty")
 	}
 
 	if !validateStringList(pm.bcc, false) {
-		return false
+		return newValidationError("bcc", "recipient address must not be empty")
 	}
 
 	if pm.template != "" {
 		// pm.text or pm.html not needed if template is supplied
-		return true
+		return nil
 	}
 
-	if pm.text == "" && pm.html == "" {
-		return false
+	if pm.text == "" && pm.html == "" && pm.ampHtml == "" {
+		return newValidationError("text", "one of text, html, amp-html or template is required")
 	}
 
-	return true
+	return nil
 }
 
-func (mm *mimeMessage) isValid() bool {
-	return mm.body != nil
+func (mm *mimeMessage) validate() error {
+	if mm.body == nil {
+		return newValidationError("message", "MIME body is required")
+	}
+	return nil
 }
 
 // validateStringList returns true if, and only if,
@@ -768,9 +1306,7 @@ func validateStringList(list []string, requireOne bool) bool {
 // GetStoredMessage retrieves information about a received e-mail message.
 // This provides visibility into, e.g., replies to a message sent to a mailing list.
 func (mg *MailgunImpl) GetStoredMessage(ctx context.Context, url string) (StoredMessage, error) {
-	r := newHTTPRequest(url)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, url)
 
 	var response StoredMessage
 	err := getResponseFromJSON(ctx, r, &response)
@@ -779,9 +1315,10 @@ func (mg *MailgunImpl) GetStoredMessage(ctx context.Context, url string) (Stored
 
 // Given a storage id resend the stored message to the specified recipients
 func (mg *MailgunImpl) ReSend(ctx context.Context, url string, recipients ...string) (string, string, error) {
-	r := newHTTPRequest(url)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	if err := mg.checkSendingPaused(storedMessageDomain(url)); err != nil {
+		return "", "", err
+	}
+	r := newAPIRequest(mg, url)
 
 	payload := newFormDataPayload()
 
@@ -806,9 +1343,7 @@ func (mg *MailgunImpl) ReSend(ctx context.Context, url string, recipients ...str
 // Compared to GetStoredMessage, it gives access to the unparsed MIME body, and
 // thus delegates to the caller the required parsing.
 func (mg *MailgunImpl) GetStoredMessageRaw(ctx context.Context, url string) (StoredMessageRaw, error) {
-	r := newHTTPRequest(url)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, url)
 	r.addHeader("Accept", "message/rfc2822")
 
 	var response StoredMessageRaw
@@ -828,12 +1363,64 @@ func (mg *MailgunImpl) GetStoredMessageRawForURL(ctx context.Context, url string
 
 // GetStoredAttachment retrieves the raw MIME body of a received e-mail message attachment.
 func (mg *MailgunImpl) GetStoredAttachment(ctx context.Context, url string) ([]byte, error) {
-	r := newHTTPRequest(url)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, url)
 	r.addHeader("Accept", "message/rfc2822")
 
 	response, err := makeGetRequest(ctx, r)
 
 	return response.Data, err
 }
+
+// DeleteStoredMessage removes a stored message, given the storage URL found in the `stored` event.
+// Once deleted, the message can no longer be retrieved or re-sent.
+func (mg *MailgunImpl) DeleteStoredMessage(ctx context.Context, url string) error {
+	r := newAPIRequest(mg, url)
+	_, err := makeDeleteRequest(ctx, r)
+	return err
+}
+
+// DeleteScheduledMessages cancels every message of the domain which Mailgun has not delivered yet:
+// those scheduled with `SetDeliveryTime()`, and those queued or waiting to be retried. Use it to
+// stop a campaign sent by mistake. Messages already delivered are not affected, and the deletion
+// cannot be undone. Pass "" for the domain of the client.
+func (mg *MailgunImpl) DeleteScheduledMessages(ctx context.Context, domain string) error {
+	if domain == "" {
+		domain = mg.Domain()
+	}
+	r := newAPIRequest(mg, generateApiUrlWithDomain(mg, envelopesEndpoint, domain))
+	_, err := makeDeleteRequest(ctx, r)
+	return err
+}
+
+// PurgeStoredMessages deletes every stored message whose `stored` event is older than the
+// given age, and returns the number of messages deleted. Messages which have already
+// expired are skipped. Run it periodically to enforce a retention policy shorter than
+// Mailgun's own for domains which store() inbound mail.
+func (mg *MailgunImpl) PurgeStoredMessages(ctx context.Context, olderThan time.Duration) (int, error) {
+	cutoff := time.Now().Add(-olderThan)
+	it := mg.ListEvents(&ListEventOptions{
+		Begin:           cutoff,
+		ForceDescending: true,
+		Filter:          map[string]string{"event": events.EventStored},
+	})
+
+	var deleted int
+	var page []Event
+	for it.Next(ctx, &page) {
+		for _, e := range page {
+			stored, ok := e.(*events.Stored)
+			if !ok || !stored.GetTimestamp().Before(cutoff) || stored.Storage.URL == "" {
+				continue
+			}
+			err := mg.DeleteStoredMessage(ctx, stored.Storage.URL)
+			if err != nil {
+				if GetStatusFromErr(err) == http.StatusNotFound {
+					continue
+				}
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+	return deleted, it.Err()
+}
@@ -2,8 +2,10 @@ package mailgun
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -427,7 +429,8 @@ func TestHasRecipient(t *testing.T) {
 	m := mg.NewMessage(fromUser, exampleSubject, exampleText)
 	_, _, err := mg.Send(context.Background(), m)
 	ensure.NotNil(t, err)
-	ensure.DeepEqual(t, err.Error(), "message not valid")
+	ensure.True(t, errors.Is(err, ErrInvalidMessage))
+	ensure.DeepEqual(t, err.Error(), "message not valid: to: at least one recipient is required")
 
 	// Provided Bcc
 	m = mg.NewMessage(fromUser, exampleSubject, exampleText)
@@ -572,3 +575,486 @@ func TestSendTemplateOptions(t *testing.T) {
 	ensure.DeepEqual(t, msg, exampleMessage)
 	ensure.DeepEqual(t, id, exampleID)
 }
+
+func TestSendValidationErrors(t *testing.T) {
+	// The handler reports requests to the test goroutine, as it may not fail the test itself
+	requests := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case requests <- req.URL.Path:
+		default:
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	tests := []struct {
+		name  string
+		msg   func() *Message
+		field string
+	}{
+		{
+			name:  "no from",
+			msg:   func() *Message { return mg.NewMessage("", exampleSubject, exampleText, "test@test.com") },
+			field: "from",
+		},
+		{
+			name:  "no body",
+			msg:   func() *Message { return mg.NewMessage(fromUser, exampleSubject, "", "test@test.com") },
+			field: "text",
+		},
+		{
+			name:  "no recipients",
+			msg:   func() *Message { return mg.NewMessage(fromUser, exampleSubject, exampleText) },
+			field: "to",
+		},
+		{
+			name: "too many recipients",
+			msg: func() *Message {
+				m := mg.NewMessage(fromUser, exampleSubject, exampleText)
+				for i := 0; i < MaxNumberOfRecipients; i++ {
+					m.AddRecipient(fmt.Sprintf("recipient_%d@example.com", i))
+				}
+				m.AddCC("cc@example.com")
+				return m
+			},
+			field: "to",
+		},
+		{
+			name: "attachments too large",
+			msg: func() *Message {
+				m := mg.NewMessage(fromUser, exampleSubject, exampleText, "test@test.com")
+				m.AddBufferAttachment("big.bin", make([]byte, MaxMessageSize+1))
+				return m
+			},
+			field: "attachment",
+		},
+		{
+			name: "missing attachment file",
+			msg: func() *Message {
+				m := mg.NewMessage(fromUser, exampleSubject, exampleText, "test@test.com")
+				m.AddAttachment("/does/not/exist.txt")
+				return m
+			},
+			field: "attachment",
+		},
+		{
+			name: "reserved header",
+			msg: func() *Message {
+				m := mg.NewMessage(fromUser, exampleSubject, exampleText, "test@test.com")
+				m.AddHeader("subject", "Overridden")
+				return m
+			},
+			field: "h:subject",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := mg.Send(context.Background(), tt.msg())
+			ensure.NotNil(t, err)
+			ensure.True(t, errors.Is(err, ErrInvalidMessage))
+
+			var verr *ValidationError
+			ensure.True(t, errors.As(err, &verr))
+			ensure.DeepEqual(t, verr.Field, tt.field)
+
+			select {
+			case path := <-requests:
+				t.Fatalf("request to %s should not be sent for an invalid message", path)
+			default:
+			}
+		})
+	}
+}
+
+func TestSendRepeatedHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ensure.Nil(t, req.ParseMultipartForm(32<<20))
+		ensure.DeepEqual(t, req.MultipartForm.Value["h:References"], []string{"<1@example.com>", "<2@example.com>"})
+		ensure.DeepEqual(t, req.MultipartForm.Value["h:x-custom"], []string{"replaced"})
+		fmt.Fprint(w, `{"message":"Queued, Thank you", "id":"<20111114174239.25659.5820@samples.mailgun.org>"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "test@test.com")
+	m.AppendHeader("References", "<1@example.com>")
+	m.AppendHeader("references", "<2@example.com>")
+	m.AppendHeader("X-Custom", "first")
+	m.AppendHeader("X-Custom", "second")
+	m.AddHeader("x-custom", "replaced")
+	ensure.DeepEqual(t, m.GetHeaderValues("REFERENCES"), []string{"<1@example.com>", "<2@example.com>"})
+
+	_, _, err := mg.Send(context.Background(), m)
+	ensure.Nil(t, err)
+}
+
+func TestSendInternationalized(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ensure.Nil(t, req.ParseMultipartForm(32<<20))
+		ensure.DeepEqual(t, req.MultipartForm.Value["subject"], []string{"お知らせ 🎉"})
+		ensure.DeepEqual(t, req.MultipartForm.Value["to"], []string{"zoe@xn--bcher-kva.example", "=?utf-8?q?=E7=8E=8B=E5=B0=8F=E6=98=8E?= <wang@xn--fiqs8s.example>"})
+		ensure.DeepEqual(t, req.MultipartForm.Value["h:X-Campaign"], []string{"=?utf-8?q?=E6=98=A5=E5=AD=A3?="})
+		ensure.DeepEqual(t, req.MultipartForm.Value["h:Reply-To"], []string{"=?utf-8?q?Zo=C3=AB?= <zoe@xn--bcher-kva.example>"})
+		ensure.StringContains(t, req.MultipartForm.Value["recipient-variables"][0], `"zoe@xn--bcher-kva.example"`)
+		fmt.Fprint(w, `{"message":"Queued, Thank you", "id":"<20111114174239.25659.5820@samples.mailgun.org>"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	m := mg.NewMessage(fromUser, "お知らせ 🎉", exampleText)
+	ensure.Nil(t, m.AddRecipientAndVariables("zoe@bücher.example", map[string]interface{}{"id": 1}))
+	ensure.Nil(t, m.AddRecipient("王小明 <wang@中国.example>"))
+	m.AddHeader("X-Campaign", "春季")
+	m.AddHeader("Reply-To", "Zoë <zoe@bücher.example>")
+
+	_, _, err := mg.Send(context.Background(), m)
+	ensure.Nil(t, err)
+}
+
+func TestEncodeHeader(t *testing.T) {
+	ensure.DeepEqual(t, EncodeHeader("Hello"), "Hello")
+
+	var dec mime.WordDecoder
+	for _, subject := range []string{"お知らせ 🎉", "你好，世界", "🚀 Launch"} {
+		encoded := EncodeHeader(subject)
+		ensure.True(t, isASCII(encoded))
+		decoded, err := dec.DecodeHeader(encoded)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, decoded, subject)
+	}
+
+	ensure.DeepEqual(t, FormatAddress("", "user@example.com"), "user@example.com")
+	ensure.DeepEqual(t, FormatAddress("Zoë", "zoe@bücher.example"), "=?utf-8?q?Zo=C3=AB?= <zoe@xn--bcher-kva.example>")
+}
+
+func TestSendInlineImages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ensure.Nil(t, req.ParseMultipartForm(32<<20))
+		ensure.DeepEqual(t, req.MultipartForm.Value["html"], []string{
+			`<img src="cid:logo.png"><IMG alt='x' SRC='cid:logo-2.png'><img src="https://example.com/a.png">`,
+		})
+		var names []string
+		for _, f := range req.MultipartForm.File["inline"] {
+			names = append(names, f.Filename)
+		}
+		ensure.DeepEqual(t, names, []string{"logo.png", "logo-2.png", "dark_logo.png"})
+		fmt.Fprint(w, `{"message":"Queued, Thank you", "id":"<20111114174239.25659.5820@samples.mailgun.org>"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "test@test.com")
+	ensure.DeepEqual(t, m.AddInlineImage(strings.NewReader("first"), "logo.png"), "logo.png")
+	ensure.DeepEqual(t, m.AddInlineImage(strings.NewReader("second"), "images/logo.png"), "logo-2.png")
+	ensure.DeepEqual(t, m.AddInlineImage(ioutil.NopCloser(strings.NewReader("third")), "dark logo.png"), "dark_logo.png")
+	m.SetHtml(`<img src="logo.png"><IMG alt='x' SRC='images/logo.png'><img src="https://example.com/a.png">`)
+	m.SetInlineImageRewrite(true)
+
+	_, _, err := mg.Send(context.Background(), m)
+	ensure.Nil(t, err)
+}
+
+func TestAddTag(t *testing.T) {
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "test@test.com")
+
+	ensure.Nil(t, m.AddTag("one", "two"))
+	ensure.Nil(t, m.AddTag("ONE"))
+	err := m.AddTag("three", "four")
+	ensure.NotNil(t, err)
+	var verr *ValidationError
+	ensure.True(t, errors.As(err, &verr))
+	ensure.DeepEqual(t, verr.Field, "o:tag")
+	ensure.True(t, errors.Is(err, ErrInvalidMessage))
+	// None are added when one is rejected
+	ensure.DeepEqual(t, m.tags, []string{"one", "two"})
+
+	ensure.NotNil(t, m.AddTag(" "))
+	ensure.NotNil(t, m.AddTag("café"))
+	ensure.NotNil(t, m.AddTag(strings.Repeat("x", MaxTagLength+1)))
+
+	m.SetTagTruncation(true)
+	ensure.Nil(t, m.AddTag(strings.Repeat("x", MaxTagLength+1)))
+	ensure.DeepEqual(t, m.tags[2], strings.Repeat("x", MaxTagLength))
+
+	m.SetTagLimit(5)
+	ensure.Nil(t, m.AddTag("four", "five"))
+	ensure.NotNil(t, m.AddTag("six"))
+}
+
+type receiptMessage struct {
+	domain   string
+	endpoint string
+	to       string
+}
+
+func (m receiptMessage) Domain() string   { return m.domain }
+func (m receiptMessage) Endpoint() string { return m.endpoint }
+
+func (m receiptMessage) FormFields() ([]FormField, error) {
+	return []FormField{
+		{Key: "from", Value: fromUser},
+		{Key: "to", Value: m.to},
+		{Key: "subject", Value: exampleSubject},
+		{Key: "text", Value: exampleText},
+		{Key: "o:tag", Value: "receipt"},
+	}, nil
+}
+
+func (m receiptMessage) FormFiles() ([]FormFile, error) {
+	return []FormFile{{Key: "attachment", Filename: "receipt.txt", Data: []byte("total: 42")}}, nil
+}
+
+func TestSendCustomMessage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ensure.DeepEqual(t, req.URL.Path, "/v3/receipts.example.com/messages")
+		ensure.DeepEqual(t, req.FormValue("to"), "test@test.com")
+		ensure.DeepEqual(t, req.FormValue("o:tag"), "receipt")
+		f, h, err := req.FormFile("attachment")
+		ensure.Nil(t, err)
+		defer f.Close()
+		data, _ := ioutil.ReadAll(f)
+		ensure.DeepEqual(t, h.Filename, "receipt.txt")
+		ensure.DeepEqual(t, string(data), "total: 42")
+		fmt.Fprint(w, `{"message":"Queued, Thank you", "id":"<20111114174239.25659.5820@samples.mailgun.org>"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	_, id, err := mg.Send(context.Background(), receiptMessage{
+		domain:   "receipts.example.com",
+		endpoint: "messages",
+		to:       "test@test.com",
+	})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, id, "<20111114174239.25659.5820@samples.mailgun.org>")
+
+	_, _, err = mg.Send(context.Background(), receiptMessage{endpoint: "bounces"})
+	ensure.True(t, errors.Is(err, ErrInvalidMessage))
+}
+
+func TestMessageFormFields(t *testing.T) {
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "test@test.com")
+	ensure.Nil(t, m.AddTag("welcome"))
+	m.AddBufferAttachment("hello.txt", []byte("hello"))
+
+	fields, err := m.FormFields()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, fields, []FormField{
+		{Key: "from", Value: fromUser},
+		{Key: "subject", Value: exampleSubject},
+		{Key: "text", Value: exampleText},
+		{Key: "to", Value: "test@test.com"},
+		{Key: "o:tag", Value: "welcome"},
+	})
+
+	files, err := m.FormFiles()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, files, []FormFile{{Key: "attachment", Filename: "hello.txt", Data: []byte("hello")}})
+	ensure.DeepEqual(t, m.Endpoint(), messagesEndpoint)
+}
+
+func TestMessageFormFieldsOrder(t *testing.T) {
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "test@test.com")
+	m.AddHeader("X-Zone", "eu")
+	m.AddHeader("X-Account", "42")
+	ensure.Nil(t, m.AddVariable("plan", "pro"))
+	ensure.Nil(t, m.AddVariable("account", "42"))
+
+	fields, err := m.FormFields()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, fields[4:], []FormField{
+		{Key: "h:X-Account", Value: "42"},
+		{Key: "h:X-Zone", Value: "eu"},
+		{Key: "v:account", Value: "42"},
+		{Key: "v:plan", Value: "pro"},
+	})
+}
+
+func TestSendAMPOnly(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ensure.DeepEqual(t, req.FormValue("amp-html"), exampleAMPHtml)
+		fmt.Fprint(w, `{"message":"Queued, Thank you", "id":"<20111114174239.25659.5820@samples.mailgun.org>"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	m := mg.NewMessage(fromUser, exampleSubject, "", "test@test.com")
+	m.SetAMPHtml(exampleAMPHtml)
+	_, _, err := mg.Send(context.Background(), m)
+	ensure.Nil(t, err)
+}
+
+func TestDeleteScheduledMessages(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req.Method+" "+req.URL.Path)
+		fmt.Fprint(w, `{"message": "done"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	ctx := context.Background()
+	ensure.Nil(t, mg.DeleteScheduledMessages(ctx, ""))
+	ensure.Nil(t, mg.DeleteScheduledMessages(ctx, "other.example.com"))
+	ensure.DeepEqual(t, requests, []string{
+		"DELETE /v3/" + exampleDomain + "/envelopes",
+		"DELETE /v3/other.example.com/envelopes",
+	})
+}
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("not today")
+}
+
+func TestAddVariableValues(t *testing.T) {
+	m := NewMailgun(exampleDomain, exampleAPIKey).NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com")
+
+	type order struct {
+		ID    string  `json:"id"`
+		Total float64 `json:"total"`
+	}
+	ensure.Nil(t, m.AddVariable("order", order{ID: "A-1001", Total: 42.5}))
+	ensure.Nil(t, m.AddVariable("plan", "gold"))
+	ensure.DeepEqual(t, m.variables["order"], `{"id":"A-1001","total":42.5}`)
+	ensure.DeepEqual(t, m.variables["plan"], "gold")
+
+	err := m.AddVariable("broken", failingMarshaler{})
+	var varErr *VariableError
+	ensure.True(t, errors.As(err, &varErr))
+	ensure.DeepEqual(t, varErr.Variable, "broken")
+	ensure.StringContains(t, err.Error(), "not today")
+	err = m.AddVariable("channel", make(chan int))
+	ensure.True(t, errors.As(err, &varErr))
+	_, ok := m.variables["broken"]
+	ensure.False(t, ok)
+
+	// Variables are limited in total, and a replaced value does not count
+	ensure.Nil(t, m.AddVariable("big", strings.Repeat("x", MaxVariablesSize-100)))
+	ensure.Nil(t, m.AddVariable("big", strings.Repeat("y", MaxVariablesSize-100)))
+	err = m.AddVariable("more", strings.Repeat("z", 100))
+	var validationErr *ValidationError
+	ensure.True(t, errors.As(err, &validationErr))
+	ensure.DeepEqual(t, validationErr.Field, "v:more")
+	_, ok = m.variables["more"]
+	ensure.False(t, ok)
+}
+
+func TestSendingIPAndPool(t *testing.T) {
+	var forms []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		forms = append(forms, req.FormValue("o:sending-ip")+"|"+req.FormValue("o:sending-ip-pool"))
+		fmt.Fprint(w, `{"message":"Queued. Thank you.", "id":"<id@example.com>"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com")
+	m.SetSendingIP("192.0.2.10")
+	_, _, err := mg.Send(ctx, m)
+	ensure.Nil(t, err)
+
+	m = mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com")
+	m.SetIPPool("marketing-pool")
+	_, _, err = mg.Send(ctx, m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, forms, []string{"192.0.2.10|", "|marketing-pool"})
+
+	m.SetSendingIP("192.0.2.10")
+	_, _, err = mg.Send(ctx, m)
+	var validationErr *ValidationError
+	ensure.True(t, errors.As(err, &validationErr))
+	ensure.DeepEqual(t, validationErr.Field, "o:sending-ip-pool")
+
+	m = mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com")
+	m.SetSendingIP("mail.example.com")
+	_, _, err = mg.Send(ctx, m)
+	ensure.True(t, errors.As(err, &validationErr))
+	ensure.DeepEqual(t, validationErr.Field, "o:sending-ip")
+	ensure.DeepEqual(t, len(forms), 2)
+}
+
+func TestSecondaryDKIM(t *testing.T) {
+	var forms []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		forms = append(forms, req.FormValue("o:secondary-dkim")+"|"+req.FormValue("o:secondary-dkim-public"))
+		fmt.Fprint(w, `{"message":"Queued. Thank you.", "id":"<id@example.com>"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com")
+	m.SetSecondaryDKIM("example.com", "s1")
+	_, _, err := mg.Send(ctx, m)
+	ensure.Nil(t, err)
+
+	m.SetSecondaryDKIMPublic("public.example.com", "s2")
+	_, _, err = mg.Send(ctx, m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, forms, []string{"example.com/s1|", "example.com/s1|public.example.com/s2"})
+
+	var validationErr *ValidationError
+	m.SetSecondaryDKIM("example.com", "")
+	_, _, err = mg.Send(ctx, m)
+	ensure.True(t, errors.As(err, &validationErr))
+	ensure.DeepEqual(t, validationErr.Field, "o:secondary-dkim")
+
+	m = mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com")
+	m.SetSecondaryDKIMPublic("public.example.com", "s2")
+	_, _, err = mg.Send(ctx, m)
+	ensure.True(t, errors.As(err, &validationErr))
+	ensure.DeepEqual(t, validationErr.Field, "o:secondary-dkim-public")
+
+	m.SetSecondaryDKIM("example.com/s1", "s1")
+	_, _, err = mg.Send(ctx, m)
+	ensure.True(t, errors.As(err, &validationErr))
+	ensure.DeepEqual(t, validationErr.Field, "o:secondary-dkim")
+	ensure.DeepEqual(t, len(forms), 2)
+}
+
+func TestAddField(t *testing.T) {
+	var values []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ensure.Nil(t, req.ParseMultipartForm(1<<20))
+		values = req.MultipartForm.Value["o:new-flag"]
+		fmt.Fprint(w, `{"message":"Queued. Thank you.", "id":"<id@example.com>"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com")
+	m.AddField("o:new-flag", "yes")
+	m.AddField("o:new-flag", "again")
+	_, _, err := mg.Send(context.Background(), m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, values, []string{"yes", "again"})
+}
@@ -0,0 +1,78 @@
+package mailgun
+
+import (
+	"mime"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yjimk/mailgun-go/v4/addresses"
+)
+
+// addressHeaders are custom headers which hold addresses rather than text.
+var addressHeaders = []string{"Reply-To", "Sender", "Disposition-Notification-To"}
+
+// EncodeHeader returns the value for a header of a MIME message, as RFC 2047 encoded words if it
+// holds non-ASCII characters. Use it when building the body of `NewMIMEMessage()` yourself; headers
+// of other messages are encoded by `Send()` and Mailgun.
+//
+//  fmt.Fprintf(body, "Subject: %s\r\n", mailgun.EncodeHeader("お知らせ 🎉"))
+func EncodeHeader(value string) string {
+	if isASCII(value) {
+		return value
+	}
+	return mime.QEncoding.Encode("utf-8", value)
+}
+
+// FormatAddress returns the mailbox for an address header of a MIME message, with a non-ASCII
+// display name encoded as RFC 2047 encoded words and an internationalized domain as punycode.
+//
+//  fmt.Fprintf(body, "To: %s\r\n", mailgun.FormatAddress("Zoë", "zoe@bücher.example"))
+//  // To: =?utf-8?q?Zo=C3=AB?= <zoe@xn--bcher-kva.example>
+func FormatAddress(name, address string) string {
+	if local, domain, err := addresses.Split(address); err == nil {
+		if domain, err = addresses.ToASCII(domain); err == nil {
+			address = local + "@" + domain
+		}
+	}
+	if name == "" {
+		return address
+	}
+	return (&mail.Address{Name: name, Address: address}).String()
+}
+
+// encodeAddresses formats a list of addresses with FormatAddress, so internationalized domains
+// are sent in punycode. Lists which are ASCII already, or cannot be parsed, are sent as they are.
+func encodeAddresses(list string) string {
+	if isASCII(list) {
+		return list
+	}
+	parsed, err := mail.ParseAddressList(list)
+	if err != nil {
+		return list
+	}
+	formatted := make([]string, len(parsed))
+	for i, a := range parsed {
+		formatted[i] = FormatAddress(a.Name, a.Address)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// encodeHeader encodes the value of a custom header of a message.
+func encodeHeader(name, value string) string {
+	for _, h := range addressHeaders {
+		if strings.EqualFold(h, name) {
+			return encodeAddresses(value)
+		}
+	}
+	return EncodeHeader(value)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
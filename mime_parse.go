@@ -0,0 +1,206 @@
+package mailgun
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// maxMIMEDepth is the deepest nesting of multipart parts ParseMIMEMessage descends into.
+const maxMIMEDepth = 16
+
+// ParsedMessage is a MIME message parsed into its headers and decoded parts.
+type ParsedMessage struct {
+	// Message holds the headers of the message; its Body reads the undecoded body.
+	Message *mail.Message
+	// Subject is the decoded subject of the message.
+	Subject string
+	// Text and HTML are the first text/plain and text/html parts which are not attachments.
+	// Bodies in charsets other than UTF-8 are not converted.
+	Text string
+	HTML string
+	// Parts are the attachments and inline parts of the message, such as inline images, in the
+	// order they appear in the message.
+	Parts []MessagePart
+}
+
+// MessagePart is an attachment or inline part of a parsed MIME message.
+type MessagePart struct {
+	Header textproto.MIMEHeader
+	// ContentType is the media type of the part, such as "image/png", without parameters.
+	ContentType string
+	// Filename is the decoded file name of the part, which may be empty for inline parts.
+	Filename string
+	// ContentID is the Content-ID of the part without its angle brackets, which the HTML of the
+	// message refers to as "cid:" URLs.
+	ContentID string
+	// Inline is set if the part is shown in the body of the message rather than attached.
+	Inline bool
+	// Data is the decoded content of the part.
+	Data []byte
+}
+
+// InlinePart returns the part with the content id, as the HTML of the message refers to it with a
+// "cid:" URL. ok is false if there is none.
+func (p *ParsedMessage) InlinePart(contentID string) (part MessagePart, ok bool) {
+	contentID = strings.Trim(strings.TrimPrefix(contentID, "cid:"), "<>")
+	for _, part := range p.Parts {
+		if part.ContentID == contentID {
+			return part, true
+		}
+	}
+	return part, false
+}
+
+// ParseMIMEMessage parses a MIME message, such as the body of a stored message, into its headers,
+// its text and HTML bodies, and its attachments and inline parts, decoding base64 and
+// quoted-printable content.
+//
+//  raw, err := mg.GetStoredMessageRaw(ctx, url)
+//  if err != nil {
+//    return err
+//  }
+//  msg, err := raw.Parse()
+//  if err != nil {
+//    return err
+//  }
+//  for _, part := range msg.Parts {
+//    log.Printf("%s (%s): %d bytes", part.Filename, part.ContentType, len(part.Data))
+//  }
+func ParseMIMEMessage(raw []byte) (*ParsedMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("while reading message: %w", err)
+	}
+	body, err := ioutil.ReadAll(msg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("while reading message: %w", err)
+	}
+	msg.Body = bytes.NewReader(body)
+
+	p := &ParsedMessage{Message: msg}
+	if p.Subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil {
+		p.Subject = msg.Header.Get("Subject")
+	}
+	header := textproto.MIMEHeader(msg.Header)
+	if err := p.addPart(header, decodeTransfer(header, bytes.NewReader(body)), 0); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Parse parses the MIME body of the stored message with `ParseMIMEMessage()`.
+func (m StoredMessageRaw) Parse() (*ParsedMessage, error) {
+	return ParseMIMEMessage([]byte(m.BodyMime))
+}
+
+// addPart adds the part with the header, whose content is read from r, descending into multipart
+// parts.
+func (p *ParsedMessage) addPart(header textproto.MIMEHeader, r io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth == maxMIMEDepth {
+			return fmt.Errorf("message has more than %d levels of multipart parts", maxMIMEDepth)
+		}
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("while reading %s part: %w", mediaType, err)
+			}
+			// Quoted-printable parts are decoded by the multipart reader
+			if err := p.addPart(part.Header, decodeTransfer(part.Header, part), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("while reading %s part: %w", mediaType, err)
+	}
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := partFilename(dispositionParams, params)
+
+	if disposition != "attachment" && filename == "" {
+		switch {
+		case mediaType == "text/plain" && p.Text == "":
+			p.Text = string(data)
+			return nil
+		case mediaType == "text/html" && p.HTML == "":
+			p.HTML = string(data)
+			return nil
+		}
+	}
+	p.Parts = append(p.Parts, MessagePart{
+		Header:      header,
+		ContentType: mediaType,
+		Filename:    filename,
+		ContentID:   strings.Trim(header.Get("Content-ID"), "<> "),
+		Inline:      disposition == "inline" || (disposition == "" && header.Get("Content-ID") != ""),
+		Data:        data,
+	})
+	return nil
+}
+
+// decodeTransfer returns a reader of the content of a part read from r, decoded from its
+// Content-Transfer-Encoding.
+func decodeTransfer(header textproto.MIMEHeader, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Stripper{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// partFilename returns the decoded file name of a part, from its Content-Disposition or the name
+// parameter of its Content-Type.
+func partFilename(disposition, contentType map[string]string) string {
+	name := disposition["filename"]
+	if name == "" {
+		name = contentType["name"]
+	}
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(name); err == nil {
+		name = decoded
+	}
+	return name
+}
+
+// base64Stripper drops the line breaks and spaces base64 content is wrapped with, which the
+// decoder of encoding/base64 only skips if they are \r and \n.
+type base64Stripper struct {
+	r io.Reader
+}
+
+func (s *base64Stripper) Read(p []byte) (int, error) {
+	for {
+		n, err := s.r.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			if b != ' ' && b != '\t' {
+				p[kept] = b
+				kept++
+			}
+		}
+		if kept != 0 || err != nil {
+			return kept, err
+		}
+	}
+}
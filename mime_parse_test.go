@@ -0,0 +1,81 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestParseMIMEMessage(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	m := mg.NewMessage("root@"+testDomain, "Grüße", "Hello, world", "joe@example.com")
+	m.SetHtml(`<p>Hello, <img src="logo.png"></p>`)
+	m.AddBufferAttachment("report.pdf", []byte("%PDF-1.4 report"))
+	m.SetInlineImageRewrite(true)
+	cid := m.AddInlineImage(strings.NewReader("png data"), "logo.png")
+
+	var buf bytes.Buffer
+	ensure.Nil(t, m.WriteEML(&buf))
+	msg, err := mailgun.StoredMessageRaw{BodyMime: buf.String()}.Parse()
+	ensure.Nil(t, err)
+
+	ensure.DeepEqual(t, msg.Subject, "Grüße")
+	ensure.DeepEqual(t, msg.Message.Header.Get("To"), "joe@example.com")
+	ensure.DeepEqual(t, msg.Text, "Hello, world")
+	ensure.DeepEqual(t, msg.HTML, `<p>Hello, <img src="cid:`+cid+`"></p>`)
+	ensure.DeepEqual(t, len(msg.Parts), 2)
+
+	inline, ok := msg.InlinePart("cid:" + cid)
+	ensure.True(t, ok)
+	ensure.True(t, inline.Inline)
+	ensure.DeepEqual(t, inline.Filename, "logo.png")
+	ensure.DeepEqual(t, string(inline.Data), "png data")
+
+	attachment := msg.Parts[1]
+	ensure.False(t, attachment.Inline)
+	ensure.DeepEqual(t, attachment.ContentType, "application/pdf")
+	ensure.DeepEqual(t, attachment.Filename, "report.pdf")
+	ensure.DeepEqual(t, string(attachment.Data), "%PDF-1.4 report")
+}
+
+func TestParseMIMEMessageEncodings(t *testing.T) {
+	raw := strings.Join([]string{
+		"From: ann@example.com",
+		"Subject: Invoice",
+		"Content-Type: multipart/mixed; boundary=outer",
+		"",
+		"--outer",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: quoted-printable",
+		"",
+		"Caf=C3=A9 au lait, a long line which is wrapped by the encoder in=",
+		"to two",
+		"--outer",
+		"Content-Type: text/csv",
+		"Content-Disposition: attachment; filename=\"=?utf-8?q?r=C3=A9sum=C3=A9.csv?=\"",
+		"Content-Transfer-Encoding: base64",
+		"",
+		"YSxi",
+		"LGMK",
+		"--outer--",
+		"",
+	}, "\r\n")
+	msg, err := mailgun.ParseMIMEMessage([]byte(raw))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, msg.Text, "Café au lait, a long line which is wrapped by the encoder into two")
+	ensure.DeepEqual(t, len(msg.Parts), 1)
+	ensure.DeepEqual(t, msg.Parts[0].Filename, "résumé.csv")
+	ensure.DeepEqual(t, string(msg.Parts[0].Data), "a,b,c\n")
+
+	// A message of a single part
+	msg, err = mailgun.ParseMIMEMessage([]byte("Subject: Hi\r\nContent-Type: text/html\r\nContent-Transfer-Encoding: base64\r\n\r\nPGI+SGk8L2I+\r\n"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, msg.HTML, "<b>Hi</b>")
+	ensure.DeepEqual(t, msg.Text, "")
+
+	_, err = mailgun.ParseMIMEMessage([]byte("not a message"))
+	ensure.NotNil(t, err)
+}
@@ -0,0 +1,85 @@
+package mailgun
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MIMETransformer transforms a message assembled as MIME before it is sent, such as by signing it
+// with S/MIME or PGP, or encrypting it. It is given the whole message, headers included, and
+// returns the message to send in its place.
+type MIMETransformer func(ctx context.Context, message []byte) ([]byte, error)
+
+// SetMIMETransformers sets transformers `Send()` applies to each `*Message` before posting it, in
+// order, so a message can be signed then encrypted. Messages built from their parts are assembled
+// as MIME, as `WriteEML()` does, and sent as MIME messages; transformers cannot be applied to
+// messages sent with a template, which Mailgun renders. Options, variables and recipients are sent
+// alongside the message as usual. Mailgun rewrites links and adds a tracking pixel to the body of
+// messages with click or open tracking enabled, which breaks a signature over the body, so disable
+// tracking for the domain or the message. Pass no transformers to disable them.
+//
+//  mg.SetMIMETransformers(func(ctx context.Context, msg []byte) ([]byte, error) {
+//    return smime.Sign(msg, cert, key)
+//  })
+func (mg *MailgunImpl) SetMIMETransformers(transformers ...MIMETransformer) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.transformers = append([]MIMETransformer(nil), transformers...)
+}
+
+// mimeFields are the fields of the messages API which are carried by the MIME message itself.
+var mimeFields = []string{"from", "subject", "text", "html", "amp-html", "cc", "bcc"}
+
+// transformMIME returns the payload to send to the MIME messages endpoint in place of the payload
+// p of a message, with the message assembled and transformed.
+func transformMIME(ctx context.Context, p *formDataPayload, transformers []MIMETransformer, domain, endpoint string) (*formDataPayload, error) {
+	if err := p.bufferFiles(); err != nil {
+		return nil, err
+	}
+
+	var raw []byte
+	if endpoint == mimeMessagesEndpoint {
+		for _, b := range p.Buffers {
+			if b.key == "message" {
+				raw = b.value
+			}
+		}
+	} else {
+		for _, v := range p.Values {
+			if v.key == "template" {
+				return nil, newValidationError("template", "MIME transformers cannot be applied to messages sent with a template")
+			}
+		}
+		var buf bytes.Buffer
+		if err := writeEML(&buf, p); err != nil {
+			return nil, err
+		}
+		raw = buf.Bytes()
+	}
+
+	for i, t := range transformers {
+		var err error
+		if raw, err = t(ctx, raw); err != nil {
+			return nil, errors.Wrapf(err, "while applying MIME transformer %d", i)
+		}
+	}
+
+	transformed := newFormDataPayload()
+	for _, v := range p.Values {
+		switch {
+		case v.key == "cc" || v.key == "bcc":
+			// The MIME messages API delivers the message to the "to" recipients only
+			transformed.addValue("to", v.value)
+		case containsFold(mimeFields, v.key) || strings.HasPrefix(v.key, "h:"):
+		default:
+			transformed.addValue(v.key, v.value)
+		}
+	}
+	transformed.addBuffer("message", "message.mime", raw)
+	// Assembling the message is not repeatable, so the message is identified by what it was built from
+	transformed.sourceHash = p.hash(domain, endpoint)
+	return transformed, nil
+}
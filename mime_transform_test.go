@@ -0,0 +1,75 @@
+package mailgun_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestMIMETransformers(t *testing.T) {
+	var path, message string
+	var values map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ensure.Nil(t, r.ParseMultipartForm(1<<20))
+		path = r.URL.Path
+		values = r.MultipartForm.Value
+		f, err := r.MultipartForm.File["message"][0].Open()
+		ensure.Nil(t, err)
+		data, err := ioutil.ReadAll(f)
+		ensure.Nil(t, err)
+		message = string(data)
+		fmt.Fprint(w, `{"id": "<20200101.1@example.com>", "message": "Queued. Thank you."}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetMIMETransformers(
+		func(ctx context.Context, msg []byte) ([]byte, error) {
+			return append([]byte("X-Signed: 1\r\n"), msg...), nil
+		},
+		func(ctx context.Context, msg []byte) ([]byte, error) {
+			return append([]byte("X-Encrypted: 1\r\n"), msg...), nil
+		},
+	)
+	ctx := context.Background()
+
+	m := mg.NewMessage("root@"+testDomain, "Subject", "Hello", "joe@example.com")
+	m.AddCC("cc@example.com")
+	m.AddTag("signed")
+	_, _, err := mg.Send(ctx, m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, path, "/v3/"+testDomain+"/messages.mime")
+	ensure.DeepEqual(t, values["to"], []string{"cc@example.com", "joe@example.com"})
+	ensure.DeepEqual(t, values["o:tag"], []string{"signed"})
+	ensure.DeepEqual(t, values["subject"], []string(nil))
+	ensure.True(t, strings.HasPrefix(message, "X-Encrypted: 1\r\nX-Signed: 1\r\nFrom: root@"+testDomain+"\r\n"))
+	ensure.StringContains(t, message, "Cc: cc@example.com\r\n")
+
+	// MIME messages are transformed as they are
+	m = mg.NewMIMEMessage(ioutil.NopCloser(strings.NewReader("Subject: Hi\r\n\r\nHello")), "joe@example.com")
+	_, _, err = mg.Send(ctx, m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, message, "X-Encrypted: 1\r\nX-Signed: 1\r\nSubject: Hi\r\n\r\nHello")
+
+	m = mg.NewMessage("root@"+testDomain, "Subject", "", "joe@example.com")
+	m.SetTemplate("welcome")
+	_, _, err = mg.Send(ctx, m)
+	ensure.NotNil(t, err)
+
+	mg.SetMIMETransformers(func(ctx context.Context, msg []byte) ([]byte, error) {
+		return nil, errors.New("no signing key")
+	})
+	m = mg.NewMessage("root@"+testDomain, "Subject", "Hello", "joe@example.com")
+	_, _, err = mg.Send(ctx, m)
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "no signing key")
+}
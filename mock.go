@@ -25,14 +25,27 @@ type MockServer struct {
 	routeList   []Route
 	events      []Event
 	webhooks    WebHooksListResponse
+
+	account        Account
+	signingKey     string
+	authRecipients []AuthorizedRecipient
+	ipAllowlist    []IPAllowlistEntry
+	users          []User
+
+	// state is the server the handlers were registered on, which seeding a copy returned by
+	// NewMockServer() updates.
+	state    *MockServer
+	failures *mockFailures
 }
 
 // Create a new instance of the mailgun API mock server
 func NewMockServer() MockServer {
-	ms := MockServer{}
+	ms := MockServer{failures: &mockFailures{}}
+	ms.state = &ms
 
 	// Add all our handlers
 	r := chi.NewRouter()
+	r.Use(ms.failures.middleware)
 
 	r.Route("/v3", func(r chi.Router) {
 		ms.addIPRoutes(r)
@@ -44,6 +57,13 @@ func NewMockServer() MockServer {
 		ms.addRoutes(r)
 		ms.addWebhookRoutes(r)
 	})
+	r.Route("/v2", func(r chi.Router) {
+		ms.addIPAllowlistRoutes(r)
+	})
+	r.Route("/v5", func(r chi.Router) {
+		ms.addAccountRoutes(r)
+		ms.addUserRoutes(r)
+	})
 	ms.addValidationRoutes(r)
 
 	// Start the server
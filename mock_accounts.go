@@ -0,0 +1,82 @@
+package mailgun
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+func (ms *MockServer) addAccountRoutes(r chi.Router) {
+	r.Get("/accounts", ms.getAccount)
+	r.Put("/accounts", ms.updateAccount)
+	r.Get("/accounts/http_signing_key", ms.getSigningKey)
+	r.Post("/accounts/http_signing_key", ms.regenerateSigningKey)
+
+	r.Get("/sandbox/auth_recipients", ms.listAuthorizedRecipients)
+	r.Post("/sandbox/auth_recipients", ms.addAuthorizedRecipient)
+	r.Delete("/sandbox/auth_recipients/{email}", ms.deleteAuthorizedRecipient)
+
+	ms.account = Account{
+		ID:                     "5e0f8d4b1a2b3c4d5e6f7a8b",
+		Name:                   "Mailgun Test",
+		InactiveSessionTimeout: 3600,
+		AbsoluteSessionTimeout: 86400,
+	}
+	ms.signingKey = "c8f370ecf2a7ffa0b5c6a9e0d7b1e3f2"
+}
+
+func (ms *MockServer) getAccount(w http.ResponseWriter, _ *http.Request) {
+	toJSON(w, accountResponse{Account: ms.account})
+}
+
+func (ms *MockServer) updateAccount(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("name") != "" {
+		ms.account.Name = r.FormValue("name")
+	}
+	if r.FormValue("inactive_session_timeout") != "" {
+		ms.account.InactiveSessionTimeout = stringToInt(r.FormValue("inactive_session_timeout"))
+	}
+	if r.FormValue("absolute_session_timeout") != "" {
+		ms.account.AbsoluteSessionTimeout = stringToInt(r.FormValue("absolute_session_timeout"))
+	}
+	if r.FormValue("logout_redirect_url") != "" {
+		ms.account.LogoutRedirectURL = r.FormValue("logout_redirect_url")
+	}
+	toJSON(w, map[string]bool{"success": true})
+}
+
+func (ms *MockServer) getSigningKey(w http.ResponseWriter, _ *http.Request) {
+	toJSON(w, signingKeyResponse{SigningKey: ms.signingKey})
+}
+
+func (ms *MockServer) regenerateSigningKey(w http.ResponseWriter, _ *http.Request) {
+	ms.signingKey = randomString(32, "")
+	toJSON(w, signingKeyResponse{Message: "New HTTP signing key generated", SigningKey: ms.signingKey})
+}
+
+func (ms *MockServer) listAuthorizedRecipients(w http.ResponseWriter, _ *http.Request) {
+	toJSON(w, authorizedRecipientListResponse{Recipients: ms.authRecipients})
+}
+
+func (ms *MockServer) addAuthorizedRecipient(w http.ResponseWriter, r *http.Request) {
+	recipient := AuthorizedRecipient{
+		Email:     r.FormValue("email"),
+		CreatedAt: RFC2822Time(time.Now().UTC()),
+	}
+	ms.authRecipients = append(ms.authRecipients, recipient)
+	toJSON(w, authorizedRecipientResponse{Recipient: recipient})
+}
+
+func (ms *MockServer) deleteAuthorizedRecipient(w http.ResponseWriter, r *http.Request) {
+	for i, recipient := range ms.authRecipients {
+		if recipient.Email == chi.URLParam(r, "email") {
+			ms.authRecipients = append(ms.authRecipients[:i], ms.authRecipients[i+1:]...)
+			toJSON(w, okResp{Message: "Authorized recipient has been deleted"})
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	toJSON(w, okResp{Message: "recipient not found"})
+}
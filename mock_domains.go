@@ -98,12 +98,16 @@ func (ms *MockServer) addDomainRoutes(r chi.Router) {
 	r.Put("/domains/{domain}/tracking/unsubscribe", ms.updateUnsubTracking)
 	r.Get("/domains/{domain}/limits/tag", ms.getTagLimits)
 	r.Put("/domains/{domain}/dkim_selector", ms.updateDKIMSelector)
+	r.Put("/domains/{domain}/dkim_authority", ms.updateDKIMAuthority)
 	r.Put("/domains/{domain}/web_prefix", ms.updateWebPrefix)
 }
 
 func (ms *MockServer) listDomains(w http.ResponseWriter, r *http.Request) {
 	var list []Domain
 	for _, domain := range ms.domainList {
+		if state := r.FormValue("state"); state != "" && domain.Domain.State != state {
+			continue
+		}
 		list = append(list, domain.Domain)
 	}
 
@@ -299,6 +303,33 @@ func (ms *MockServer) updateDKIMSelector(w http.ResponseWriter, r *http.Request)
 	toJSON(w, okResp{Message: "domain not found"})
 }
 
+func (ms *MockServer) updateDKIMAuthority(w http.ResponseWriter, r *http.Request) {
+	for _, d := range ms.domainList {
+		if d.Domain.Name == chi.URLParam(r, "domain") {
+			if r.FormValue("self") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				toJSON(w, okResp{Message: "self param required"})
+				return
+			}
+			toJSON(w, DKIMAuthorityResponse{
+				Changed: true,
+				Message: "Domain DKIM authority has been changed",
+				SendingDNSRecords: []DNSRecord{
+					{
+						RecordType: "TXT",
+						Valid:      "unknown",
+						Name:       "mailo._domainkey." + d.Domain.Name,
+						Value:      "k=rsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQ",
+					},
+				},
+			})
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	toJSON(w, okResp{Message: "domain not found"})
+}
+
 func (ms *MockServer) updateWebPrefix(w http.ResponseWriter, r *http.Request) {
 	for _, d := range ms.domainList {
 		if d.Domain.Name == chi.URLParam(r, "domain") {
@@ -0,0 +1,57 @@
+package mailgun
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+func (ms *MockServer) addIPAllowlistRoutes(r chi.Router) {
+	r.Get("/ip_whitelist", ms.listIPAllowlist)
+	r.Post("/ip_whitelist", ms.addIPAllowlist)
+	r.Put("/ip_whitelist", ms.updateIPAllowlist)
+	r.Delete("/ip_whitelist", ms.deleteIPAllowlist)
+}
+
+func (ms *MockServer) listIPAllowlist(w http.ResponseWriter, _ *http.Request) {
+	toJSON(w, ipAllowlistResponse{Addresses: ms.ipAllowlist})
+}
+
+func (ms *MockServer) addIPAllowlist(w http.ResponseWriter, r *http.Request) {
+	for _, entry := range ms.ipAllowlist {
+		if entry.Address == r.FormValue("address") {
+			w.WriteHeader(http.StatusConflict)
+			toJSON(w, okResp{Message: "address already exists"})
+			return
+		}
+	}
+	ms.ipAllowlist = append(ms.ipAllowlist, IPAllowlistEntry{
+		Address:     r.FormValue("address"),
+		Description: r.FormValue("description"),
+	})
+	toJSON(w, ipAllowlistResponse{Addresses: ms.ipAllowlist})
+}
+
+func (ms *MockServer) updateIPAllowlist(w http.ResponseWriter, r *http.Request) {
+	for i, entry := range ms.ipAllowlist {
+		if entry.Address == r.FormValue("address") {
+			ms.ipAllowlist[i].Description = r.FormValue("description")
+			toJSON(w, ipAllowlistResponse{Addresses: ms.ipAllowlist})
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	toJSON(w, okResp{Message: "address not found"})
+}
+
+func (ms *MockServer) deleteIPAllowlist(w http.ResponseWriter, r *http.Request) {
+	for i, entry := range ms.ipAllowlist {
+		if entry.Address == r.FormValue("address") {
+			ms.ipAllowlist = append(ms.ipAllowlist[:i], ms.ipAllowlist[i+1:]...)
+			toJSON(w, ipAllowlistResponse{Addresses: ms.ipAllowlist})
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	toJSON(w, okResp{Message: "address not found"})
+}
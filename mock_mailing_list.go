@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
@@ -15,6 +17,7 @@ type mailingListContainer struct {
 }
 
 func (ms *MockServer) addMailingListRoutes(r chi.Router) {
+	r.Get("/lists", ms.listMailingListsOffset)
 	r.Get("/lists/pages", ms.listMailingLists)
 	r.Get("/lists/{address}", ms.getMailingList)
 	r.Post("/lists", ms.createMailingList)
@@ -89,6 +92,28 @@ func (ms *MockServer) listMailingLists(w http.ResponseWriter, r *http.Request) {
 	toJSON(w, resp)
 }
 
+func (ms *MockServer) listMailingListsOffset(w http.ResponseWriter, r *http.Request) {
+	skip := stringToInt(r.FormValue("skip"))
+	limit := stringToInt(r.FormValue("limit"))
+	if limit == 0 {
+		limit = 100
+	}
+
+	if skip > len(ms.mailingList) {
+		skip = len(ms.mailingList)
+	}
+	end := limit + skip
+	if end > len(ms.mailingList) {
+		end = len(ms.mailingList)
+	}
+
+	list := []MailingList{}
+	for _, ml := range ms.mailingList[skip:end] {
+		list = append(list, ml.MailingList)
+	}
+	toJSON(w, listsResponse{Items: list})
+}
+
 func (ms *MockServer) getMailingList(w http.ResponseWriter, r *http.Request) {
 	for _, ml := range ms.mailingList {
 		if ml.MailingList.Address == chi.URLParam(r, "address") {
@@ -134,7 +159,10 @@ func (ms *MockServer) updateMailingList(w http.ResponseWriter, r *http.Request)
 			if r.FormValue("access_level") != "" {
 				ms.mailingList[i].MailingList.AccessLevel = AccessLevel(r.FormValue("access_level"))
 			}
-			toJSON(w, okResp{Message: "Mailing list member has been updated"})
+			if r.FormValue("reply_preference") != "" {
+				ms.mailingList[i].MailingList.ReplyPreference = ReplyPreference(r.FormValue("reply_preference"))
+			}
+			toJSON(w, ListResponse{Message: "Mailing list has been updated", List: ms.mailingList[i].MailingList})
 			return
 		}
 	}
@@ -143,16 +171,16 @@ func (ms *MockServer) updateMailingList(w http.ResponseWriter, r *http.Request)
 }
 
 func (ms *MockServer) createMailingList(w http.ResponseWriter, r *http.Request) {
-	ms.mailingList = append(ms.mailingList, mailingListContainer{
-		MailingList: MailingList{
-			CreatedAt:   RFC2822Time(time.Now().UTC()),
-			Name:        r.FormValue("name"),
-			Address:     r.FormValue("address"),
-			Description: r.FormValue("description"),
-			AccessLevel: AccessLevel(r.FormValue("access_level")),
-		},
-	})
-	toJSON(w, okResp{Message: "Mailing list has been created"})
+	list := MailingList{
+		CreatedAt:       RFC2822Time(time.Now().UTC()),
+		Name:            r.FormValue("name"),
+		Address:         r.FormValue("address"),
+		Description:     r.FormValue("description"),
+		AccessLevel:     AccessLevel(r.FormValue("access_level")),
+		ReplyPreference: ReplyPreference(r.FormValue("reply_preference")),
+	}
+	ms.mailingList = append(ms.mailingList, mailingListContainer{MailingList: list})
+	toJSON(w, ListResponse{Message: "Mailing list has been created", List: list})
 }
 
 func (ms *MockServer) listMembers(w http.ResponseWriter, r *http.Request) {
@@ -176,6 +204,14 @@ func (ms *MockServer) listMembers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Mailgun pages members in the order of their addresses
+	sort.SliceStable(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Address) < strings.ToLower(list[j].Address)
+	})
+	for i := range list {
+		idx[i] = list[i].Address
+	}
+
 	limit := stringToInt(r.FormValue("limit"))
 	if limit == 0 {
 		limit = 100
@@ -295,7 +331,7 @@ func (ms *MockServer) updateMember(w http.ResponseWriter, r *http.Request) {
 				sub := stringToBool(r.FormValue("subscribed"))
 				ms.mailingList[idx].Members[i].Subscribed = &sub
 			}
-			toJSON(w, okResp{Message: "Mailing list member has been updated"})
+			toJSON(w, ListResponse{Message: "Mailing list has been updated", List: ms.mailingList[i].MailingList})
 			return
 		}
 	}
@@ -332,7 +368,11 @@ func (ms *MockServer) createMember(w http.ResponseWriter, r *http.Request) {
 				ms.mailingList[idx].Members[i].Name = r.FormValue("name")
 				ms.mailingList[idx].Members[i].Vars = stringToMap(r.FormValue("vars"))
 				ms.mailingList[idx].Members[i].Subscribed = &sub
-				break
+				toJSON(w, memberCreateResponse{
+					Member:  ms.mailingList[idx].Members[i],
+					Message: "Mailing list member has been updated",
+				})
+				return
 			}
 		}
 	}
@@ -343,7 +383,10 @@ func (ms *MockServer) createMember(w http.ResponseWriter, r *http.Request) {
 		Vars:       stringToMap(r.FormValue("vars")),
 		Subscribed: &sub,
 	})
-	toJSON(w, okResp{Message: "Mailing list member has been created"})
+	toJSON(w, memberCreateResponse{
+		Member:  ms.mailingList[idx].Members[len(ms.mailingList[idx].Members)-1],
+		Message: "Mailing list member has been created",
+	})
 }
 
 func (ms *MockServer) bulkCreate(w http.ResponseWriter, r *http.Request) {
@@ -12,10 +12,12 @@ import (
 
 func (ms *MockServer) addMessagesRoutes(r chi.Router) {
 	r.Post("/{domain}/messages", ms.createMessages)
+	r.Delete("/{domain}/envelopes", ms.deleteEnvelopes)
 
 	// This path is made up; it could be anything as the storage url could change over time
 	r.Get("/se.storage.url/messages/{id}", ms.getStoredMessages)
 	r.Post("/se.storage.url/messages/{id}", ms.sendStoredMessages)
+	r.Delete("/se.storage.url/messages/{id}", ms.deleteStoredMessages)
 }
 
 // TODO: This implementation doesn't support multiple recipients
@@ -121,3 +123,22 @@ func (ms *MockServer) sendStoredMessages(w http.ResponseWriter, r *http.Request)
 
 	toJSON(w, okResp{ID: "<" + id + ">", Message: "Queued. Thank you."})
 }
+
+func (ms *MockServer) deleteEnvelopes(w http.ResponseWriter, r *http.Request) {
+	toJSON(w, okResp{Message: "done"})
+}
+
+func (ms *MockServer) deleteStoredMessages(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	for i, event := range ms.events {
+		if _, ok := event.(*events.Stored); ok && event.GetID() == id {
+			ms.events = append(ms.events[:i], ms.events[i+1:]...)
+			toJSON(w, okResp{Message: "Message has been deleted"})
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	toJSON(w, okResp{Message: "not found"})
+}
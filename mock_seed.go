@@ -0,0 +1,162 @@
+package mailgun
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The seed helpers add fixtures to the mock server, so tests can start from the state they need
+// rather than creating it through the api. Seed before making requests; the mock server does not
+// guard its state against concurrent requests.
+
+// SeedDomains adds domains to the mock server. A domain with no state is active, and one with no
+// creation time was created now.
+func (ms *MockServer) SeedDomains(domains ...Domain) {
+	s := ms.state
+	for _, d := range domains {
+		if d.State == "" {
+			d.State = DomainStateActive
+		}
+		if time.Time(d.CreatedAt).IsZero() {
+			d.CreatedAt = RFC2822Time(time.Now().UTC())
+		}
+		s.domainList = append(s.domainList, domainContainer{Domain: d})
+	}
+}
+
+// SeedLists adds mailing lists with no members to the mock server; add members with
+// `SeedMembers()`. A list with no access level is open to everyone.
+func (ms *MockServer) SeedLists(lists ...MailingList) {
+	s := ms.state
+	for _, l := range lists {
+		if l.AccessLevel == "" {
+			l.AccessLevel = "everyone"
+		}
+		if time.Time(l.CreatedAt).IsZero() {
+			l.CreatedAt = RFC2822Time(time.Now().UTC())
+		}
+		l.MembersCount = 0
+		s.mailingList = append(s.mailingList, mailingListContainer{MailingList: l})
+	}
+}
+
+// SeedMembers adds members to the mailing list at addr, which must have been seeded or created
+// first, and reports whether the list was found.
+func (ms *MockServer) SeedMembers(addr string, members ...Member) bool {
+	s := ms.state
+	for i := range s.mailingList {
+		ml := &s.mailingList[i]
+		if !strings.EqualFold(ml.MailingList.Address, addr) {
+			continue
+		}
+		for _, m := range members {
+			if m.Subscribed == nil {
+				m.Subscribed = Subscribed
+			}
+			ml.Members = append(ml.Members, m)
+		}
+		ml.MailingList.MembersCount = len(ml.Members)
+		return true
+	}
+	return false
+}
+
+// SeedEvents appends events to those the mock server lists, in the order given. Events are paged
+// by their ID, so give each a distinct one.
+func (ms *MockServer) SeedEvents(events ...Event) {
+	s := ms.state
+	s.events = append(s.events, events...)
+}
+
+// MockFailure describes requests `InjectFailure()` makes the mock server fail.
+type MockFailure struct {
+	// Method and Path select the requests counted, such as "GET" and "/v3/lists" for the
+	// requests for mailing lists. The path matches as a prefix, and an empty method or path
+	// matches any.
+	Method string
+	Path   string
+	// Nth is the request which fails, counting matching requests from when the failure was
+	// injected from 1; zero fails the next one.
+	Nth int
+	// Times is the number of consecutive matching requests which fail from the Nth; defaults to 1.
+	Times int
+	// Status is the status of the failed response, such as http.StatusTooManyRequests.
+	Status int
+	// RetryAfter, if set, is sent as the Retry-After header of the response in seconds.
+	RetryAfter time.Duration
+}
+
+// InjectFailure makes the mock server fail requests matching f with f.Status, so tests can
+// reproduce rate limiting and server errors deterministically. Failures are matched in the order
+// they were injected, and forgotten once spent.
+//
+//  server.InjectFailure(mailgun.MockFailure{Path: "/v3/lists", Nth: 2, Status: http.StatusTooManyRequests})
+func (ms *MockServer) InjectFailure(f MockFailure) {
+	if f.Nth <= 0 {
+		f.Nth = 1
+	}
+	if f.Times <= 0 {
+		f.Times = 1
+	}
+	ms.failures.mu.Lock()
+	defer ms.failures.mu.Unlock()
+	ms.failures.pending = append(ms.failures.pending, &mockFailure{MockFailure: f})
+}
+
+// ClearFailures forgets the failures injected which are not spent yet.
+func (ms *MockServer) ClearFailures() {
+	ms.failures.mu.Lock()
+	defer ms.failures.mu.Unlock()
+	ms.failures.pending = nil
+}
+
+type mockFailure struct {
+	MockFailure
+	// seen is the number of matching requests so far.
+	seen int
+}
+
+// mockFailures holds the failures injected into the mock server, and is shared by its copies.
+type mockFailures struct {
+	mu      sync.Mutex
+	pending []*mockFailure
+}
+
+func (m *mockFailures) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f := m.match(r); f != nil {
+			if f.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(f.RetryAfter/time.Second)))
+			}
+			w.WriteHeader(f.Status)
+			toJSON(w, okResp{Message: "injected failure: " + http.StatusText(f.Status)})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// match counts the request against the pending failures, and returns the first it is among the
+// failed requests of, if any. Spent failures are dropped.
+func (m *mockFailures) match(r *http.Request) *MockFailure {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var failed *MockFailure
+	pending := m.pending[:0]
+	for _, f := range m.pending {
+		if (f.Method == "" || strings.EqualFold(f.Method, r.Method)) && strings.HasPrefix(r.URL.Path, f.Path) {
+			f.seen++
+			if failed == nil && f.seen >= f.Nth {
+				failed = &f.MockFailure
+			}
+		}
+		if f.seen < f.Nth+f.Times-1 {
+			pending = append(pending, f)
+		}
+	}
+	m.pending = pending
+	return failed
+}
@@ -0,0 +1,81 @@
+package mailgun_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+func TestMockServerSeed(t *testing.T) {
+	srv := mailgun.NewMockServer()
+	defer srv.Stop()
+
+	srv.SeedDomains(mailgun.Domain{Name: "seeded.test"})
+	srv.SeedLists(mailgun.MailingList{Address: "seeded@mailgun.test", Name: "Seeded"})
+	ensure.True(t, srv.SeedMembers("Seeded@mailgun.test",
+		mailgun.Member{Address: "one@mailgun.test"}, mailgun.Member{Address: "two@mailgun.test"}))
+	ensure.False(t, srv.SeedMembers("unknown@mailgun.test", mailgun.Member{Address: "one@mailgun.test"}))
+	seeded := new(events.Delivered)
+	seeded.ID = "seeded-event"
+	seeded.Name = events.EventDelivered
+	srv.SeedEvents(seeded)
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL())
+	ctx := context.Background()
+
+	domain, err := mg.GetDomain(ctx, "seeded.test")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, domain.Domain.State, mailgun.DomainStateActive)
+
+	list, err := mg.GetMailingList(ctx, "seeded@mailgun.test")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, list.Name, "Seeded")
+	ensure.DeepEqual(t, list.MembersCount, 2)
+
+	var members []mailgun.Member
+	it := mg.ListMembers("seeded@mailgun.test", nil)
+	ensure.True(t, it.Next(ctx, &members))
+	ensure.DeepEqual(t, len(members), 2)
+	ensure.DeepEqual(t, *members[0].Subscribed, true)
+
+	var all []mailgun.Event
+	var page []mailgun.Event
+	eit := mg.ListEvents(nil)
+	for eit.Next(ctx, &page) {
+		all = append(all, page...)
+	}
+	ensure.Nil(t, eit.Err())
+	ensure.DeepEqual(t, all[len(all)-1].GetID(), "seeded-event")
+}
+
+func TestMockServerInjectFailure(t *testing.T) {
+	srv := mailgun.NewMockServer()
+	defer srv.Stop()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL())
+	ctx := context.Background()
+
+	srv.InjectFailure(mailgun.MockFailure{Method: "GET", Path: "/v3/lists/", Nth: 2, Times: 2, Status: http.StatusTooManyRequests})
+	// Requests which do not match are not counted
+	_, err := mg.GetDomain(ctx, testDomain)
+	ensure.Nil(t, err)
+
+	var statuses []int
+	for i := 0; i < 4; i++ {
+		_, err := mg.GetMailingList(ctx, "foo@mailgun.test")
+		statuses = append(statuses, mailgun.GetStatusFromErr(err))
+	}
+	ensure.DeepEqual(t, statuses, []int{-1, http.StatusTooManyRequests, http.StatusTooManyRequests, -1})
+
+	srv.InjectFailure(mailgun.MockFailure{Status: http.StatusInternalServerError})
+	srv.InjectFailure(mailgun.MockFailure{Nth: 5, Status: http.StatusInternalServerError})
+	srv.ClearFailures()
+	_, err = mg.GetDomain(ctx, testDomain)
+	ensure.Nil(t, err)
+}
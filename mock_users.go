@@ -0,0 +1,51 @@
+package mailgun
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+func (ms *MockServer) addUserRoutes(r chi.Router) {
+	r.Get("/users", ms.listUsers)
+	r.Get("/users/{id}", ms.getUser)
+
+	ms.users = []User{
+		{ID: "5e0f8d4b1a2b3c4d5e6f7a01", Name: "Owner", Email: "owner@mailgun.test", Role: UserRoleAdmin, Activated: true, IsMaster: true},
+		{ID: "5e0f8d4b1a2b3c4d5e6f7a02", Name: "Dev One", Email: "dev1@mailgun.test", Role: UserRoleDeveloper, Activated: true},
+		{ID: "5e0f8d4b1a2b3c4d5e6f7a03", Name: "Dev Two", Email: "dev2@mailgun.test", Role: UserRoleDeveloper},
+		{ID: "5e0f8d4b1a2b3c4d5e6f7a04", Name: "Accounts", Email: "billing@mailgun.test", Role: UserRoleBilling, Disabled: true},
+	}
+}
+
+func (ms *MockServer) listUsers(w http.ResponseWriter, r *http.Request) {
+	var users []User
+	for _, user := range ms.users {
+		if role := r.FormValue("role"); role != "" && user.Role != role {
+			continue
+		}
+		users = append(users, user)
+	}
+
+	resp := userListResponse{Total: len(users), Users: []User{}}
+	skip, limit := stringToInt(r.FormValue("skip")), stringToInt(r.FormValue("limit"))
+	if skip < len(users) {
+		users = users[skip:]
+		if limit != 0 && limit < len(users) {
+			users = users[:limit]
+		}
+		resp.Users = users
+	}
+	toJSON(w, resp)
+}
+
+func (ms *MockServer) getUser(w http.ResponseWriter, r *http.Request) {
+	for _, user := range ms.users {
+		if user.ID == chi.URLParam(r, "id") {
+			toJSON(w, user)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	toJSON(w, okResp{Message: "user not found"})
+}
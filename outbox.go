@@ -0,0 +1,308 @@
+package mailgun
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultOutboxMaxAttempts is the number of times an Outbox tries to send a message before it
+// marks it as failed.
+const DefaultOutboxMaxAttempts = 5
+
+// outboxBatchSize is the number of messages `Outbox.Run()` sends at a time.
+const outboxBatchSize = 100
+
+// States of an OutboxMessage.
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// An OutboxMessage is a message recorded in an OutboxStore, with what is needed to send it.
+type OutboxMessage struct {
+	// ID identifies the message, and is the idempotency key it is sent with.
+	ID string `json:"id"`
+	// Domain and Endpoint locate the api the message is sent to; an empty Domain is the domain of
+	// the client.
+	Domain   string `json:"domain"`
+	Endpoint string `json:"endpoint"`
+	// Fields and Files are the form fields and files of the request.
+	Fields []FormField `json:"fields"`
+	Files  []FormFile  `json:"files,omitempty"`
+	// State is OutboxPending, OutboxSent or OutboxFailed.
+	State string `json:"state"`
+	// Attempts is the number of times sending the message failed.
+	Attempts int `json:"attempts"`
+	// Error describes the last failure.
+	Error string `json:"error,omitempty"`
+	// MessageID is the id Mailgun gave the message once it was sent.
+	MessageID string `json:"message_id,omitempty"`
+	// CreatedAt is when the message was recorded, and NextAttemptAt when it is next due to be sent.
+	CreatedAt     time.Time `json:"created_at"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+// NewOutboxMessage returns the message to record in an OutboxStore to send m later. Messages are
+// validated as `Send()` would, and their attachments read, so m cannot be sent afterwards. The
+// idempotency key of a *Message, if set, is the ID of the message; otherwise a random ID is used.
+func NewOutboxMessage(m SendableMessage) (OutboxMessage, error) {
+	id := randomString(32, "outbox-")
+	if message, ok := m.(*Message); ok {
+		if err := validateMessage(message); err != nil {
+			return OutboxMessage{}, err
+		}
+		if message.idempotencyKey != "" {
+			id = message.idempotencyKey
+		}
+	}
+	fields, err := m.FormFields()
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+	files, err := m.FormFiles()
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+	now := time.Now()
+	return OutboxMessage{
+		ID:            id,
+		Domain:        m.Domain(),
+		Endpoint:      m.Endpoint(),
+		Fields:        fields,
+		Files:         files,
+		State:         OutboxPending,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}, nil
+}
+
+// OutboxStore keeps the messages of an Outbox. MemoryOutboxStore and SQLOutboxStore are provided;
+// implement the interface to use any other storage. Implementations must be safe for concurrent use.
+type OutboxStore interface {
+	// Add records the message.
+	Add(ctx context.Context, m OutboxMessage) error
+	// Due returns up to limit pending messages due to be sent at or before now, oldest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error)
+	// Update replaces the message with the same ID.
+	Update(ctx context.Context, m OutboxMessage) error
+}
+
+// Outbox sends messages recorded in an OutboxStore, so that applications can record a message in
+// the same database transaction as the change which causes it, and have it sent once the
+// transaction commits; see `SQLOutboxStore.AddTx()`. Messages are sent with their ID as the
+// idempotency key, so a message is not sent twice by clients which share an IdempotencyStore, even
+// if a worker stops between sending a message and marking it as sent. Messages which fail with a
+// network error, a 429 or a 5xx response are retried, waiting a second before the first retry and
+// twice as long before each one that follows; messages which Mailgun rejects, or which fail too
+// many times, are marked as failed. As with `SetSendRetries()`, a message retried after a network
+// error is delivered twice if Mailgun accepted it before the connection failed. Messages for a domain paused with `PauseSending()` stay
+// pending until it is resumed.
+//
+//  outbox := mailgun.NewOutbox(mg, store)
+//  go outbox.Run(ctx, 10*time.Second)
+type Outbox struct {
+	mg    *MailgunImpl
+	store OutboxStore
+
+	mu          sync.RWMutex
+	maxAttempts int
+}
+
+// NewOutbox returns an Outbox sending the messages of the store with the client.
+func NewOutbox(mg *MailgunImpl, store OutboxStore) *Outbox {
+	return &Outbox{mg: mg, store: store, maxAttempts: DefaultOutboxMaxAttempts}
+}
+
+// SetMaxAttempts sets how many times a message is tried before it is marked as failed; the default
+// is DefaultOutboxMaxAttempts.
+func (o *Outbox) SetMaxAttempts(attempts int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.maxAttempts = attempts
+}
+
+// Enqueue records the message in the store to be sent, and returns its ID. The send defaults and
+// suppression guard of the client are applied to a *Message, as `Send()` would, and messages
+// with an attachment the attachment policies veto are rejected; the policies, content filters and
+// MIME transformers are applied again when the message is sent.
+func (o *Outbox) Enqueue(ctx context.Context, m SendableMessage) (string, error) {
+	if message, ok := m.(*Message); ok {
+		var err error
+		if m, err = o.mg.prepareMessage(ctx, message); err != nil {
+			return "", err
+		}
+	}
+	om, err := NewOutboxMessage(m)
+	if err != nil {
+		return "", err
+	}
+	om.CreatedAt = o.mg.clock().Now()
+	om.NextAttemptAt = om.CreatedAt
+	// Attachments are checked again when the message is sent, but rejecting them here tells the caller
+	if err := o.mg.checkAttachments(ctx, om.payload()); err != nil {
+		return "", err
+	}
+	if err := o.store.Add(ctx, om); err != nil {
+		return "", err
+	}
+	return om.ID, nil
+}
+
+// Process sends up to limit messages which are due, and returns the number sent. Failures to send
+// a message are recorded with the message rather than returned; the error is that of the store.
+func (o *Outbox) Process(ctx context.Context, limit int) (int, error) {
+	due, err := o.store.Due(ctx, o.mg.clock().Now(), limit)
+	if err != nil {
+		return 0, err
+	}
+	var sent int
+	for _, m := range due {
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+		o.send(ctx, &m)
+		if err := o.store.Update(ctx, m); err != nil {
+			return sent, err
+		}
+		if m.State == OutboxSent {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// Run processes the messages which are due every interval, until the context is cancelled.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		for {
+			n, err := o.Process(ctx, outboxBatchSize)
+			if err != nil {
+				return err
+			}
+			if n < outboxBatchSize {
+				break
+			}
+		}
+		if err := o.mg.clock().Sleep(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+// send sends the message, and records the outcome in it.
+func (o *Outbox) send(ctx context.Context, m *OutboxMessage) {
+	payload := m.payload()
+	domain := m.Domain
+	if domain == "" {
+		domain = o.mg.Domain()
+	}
+
+	// The payload is checked, filtered and transformed when sent, as with `Send()`, so it sees the
+	// policies of the client sending it
+	response, err := o.mg.sendPayload(ctx, m.ID, payload, domain, m.Endpoint)
+	if err == nil {
+		m.State, m.MessageID, m.Error = OutboxSent, response.Id, ""
+		return
+	}
+
+	if _, ok := err.(*SendingPausedError); ok {
+		// The message waits for the domain to be resumed without using up its attempts
+		m.Error = err.Error()
+		m.NextAttemptAt = o.mg.clock().Now().Add(maxSendRetryBackoff)
+		return
+	}
+
+	o.mu.RLock()
+	maxAttempts := o.maxAttempts
+	o.mu.RUnlock()
+	m.Attempts++
+	m.Error = err.Error()
+	// A network error leaves the outcome of the send unknown to the idempotency store, which
+	// refuses to send the message again; the outbox retries it like any other failure
+	unknown := err == ErrSendOutcomeUnknown || isAmbiguousSendError(err)
+	if unknown {
+		if err := o.mg.ForgetIdempotencyKey(ctx, m.ID); err != nil {
+			m.Error = err.Error()
+		}
+	}
+	retryable := unknown || err == ErrSendInProgress || isRetryableSendError(ctx, err)
+	if m.Attempts >= maxAttempts || !retryable || ctx.Err() != nil {
+		m.State = OutboxFailed
+		return
+	}
+	m.NextAttemptAt = o.mg.clock().Now().Add(sendBackoff(m.Attempts))
+}
+
+// payload returns the payload of the request which sends the message.
+func (m *OutboxMessage) payload() *formDataPayload {
+	payload := newFormDataPayload()
+	for _, f := range m.Fields {
+		payload.addValue(f.Key, f.Value)
+	}
+	for _, f := range m.Files {
+		payload.addBuffer(f.Key, f.Filename, f.Data)
+		if f.ContentType != "" {
+			payload.setContentType(f.Filename, f.ContentType)
+		}
+	}
+	return payload
+}
+
+// MemoryOutboxStore is an OutboxStore held in memory. Its contents are lost when the process exits;
+// use it in tests.
+type MemoryOutboxStore struct {
+	mu       sync.RWMutex
+	messages map[string]OutboxMessage
+}
+
+// NewMemoryOutboxStore returns an empty MemoryOutboxStore.
+func NewMemoryOutboxStore() *MemoryOutboxStore {
+	return &MemoryOutboxStore{messages: make(map[string]OutboxMessage)}
+}
+
+// Add implements OutboxStore.
+func (s *MemoryOutboxStore) Add(ctx context.Context, m OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[m.ID] = m
+	return nil
+}
+
+// Due implements OutboxStore.
+func (s *MemoryOutboxStore) Due(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var due []OutboxMessage
+	for _, m := range s.messages {
+		if m.State == OutboxPending && !m.NextAttemptAt.After(now) {
+			due = append(due, m)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedAt.Before(due[j].CreatedAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// Update implements OutboxStore.
+func (s *MemoryOutboxStore) Update(ctx context.Context, m OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[m.ID] = m
+	return nil
+}
+
+// Get returns the message with the ID, or nil if there is none.
+func (s *MemoryOutboxStore) Get(ctx context.Context, id string) (*OutboxMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, ok := s.messages[id]
+	if !ok {
+		return nil, nil
+	}
+	return &m, nil
+}
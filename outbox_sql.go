@@ -0,0 +1,187 @@
+package mailgun
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SQLOutboxStore is an OutboxStore kept in a table of a SQL database. It is a reference
+// implementation which uses portable SQL, and works with any database/sql driver. Queries
+// use `?` placeholders unless SetNumberedPlaceholders() is called, as PostgreSQL requires, and
+// limit the rows read with LIMIT unless SetFetchFirst() is called, as SQL Server requires.
+type SQLOutboxStore struct {
+	db         *sql.DB
+	table      string
+	numbered   bool
+	fetchFirst bool
+}
+
+// NewSQLOutboxStore returns an OutboxStore kept in the named table of db.
+// Call CreateTable() to create the table if it does not already exist.
+func NewSQLOutboxStore(db *sql.DB, table string) (*SQLOutboxStore, error) {
+	if !sqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name '%s'", table)
+	}
+	return &SQLOutboxStore{db: db, table: table}, nil
+}
+
+// SetNumberedPlaceholders uses `$1`, `$2`... placeholders in queries instead of `?`.
+func (s *SQLOutboxStore) SetNumberedPlaceholders() {
+	s.numbered = true
+}
+
+// SetFetchFirst limits the rows read with `OFFSET 0 ROWS FETCH FIRST n ROWS ONLY` instead of
+// `LIMIT n`, for databases without LIMIT such as SQL Server and Oracle.
+func (s *SQLOutboxStore) SetFetchFirst() {
+	s.fetchFirst = true
+}
+
+// CreateTable creates the outbox table if it does not already exist. Large outboxes benefit from
+// an index on (state, next_attempt_at), which is left to the application as its syntax varies.
+func (s *SQLOutboxStore) CreateTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+s.table+` (
+		id VARCHAR(255) NOT NULL PRIMARY KEY,
+		domain VARCHAR(255) NOT NULL,
+		endpoint VARCHAR(32) NOT NULL,
+		fields TEXT NOT NULL,
+		files TEXT NOT NULL,
+		state VARCHAR(32) NOT NULL,
+		attempts INTEGER NOT NULL,
+		error TEXT NOT NULL,
+		message_id VARCHAR(512) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		next_attempt_at TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+// Add implements OutboxStore.
+func (s *SQLOutboxStore) Add(ctx context.Context, m OutboxMessage) error {
+	return s.add(ctx, s.db, m)
+}
+
+// AddTx records the message within the transaction, so that it is only sent if the transaction
+// commits.
+//
+//  tx, err := db.BeginTx(ctx, nil)
+//  ...
+//  _, err = tx.ExecContext(ctx, "INSERT INTO orders ...")
+//  ...
+//  m, err := mailgun.NewOutboxMessage(receipt)
+//  ...
+//  err = store.AddTx(ctx, tx, m)
+//  ...
+//  err = tx.Commit()
+func (s *SQLOutboxStore) AddTx(ctx context.Context, tx *sql.Tx, m OutboxMessage) error {
+	return s.add(ctx, tx, m)
+}
+
+// execer is what *sql.DB and *sql.Tx have in common.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (s *SQLOutboxStore) add(ctx context.Context, db execer, m OutboxMessage) error {
+	fields, files, err := encodeOutboxPayload(m)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		s.query(`INSERT INTO `+s.table+` (id, domain, endpoint, fields, files, state, attempts, error, message_id, created_at, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		m.ID, m.Domain, m.Endpoint, fields, files, m.State, m.Attempts, m.Error, m.MessageID, m.CreatedAt.UTC(), m.NextAttemptAt.UTC())
+	return err
+}
+
+// Due implements OutboxStore.
+func (s *SQLOutboxStore) Due(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error) {
+	rows, err := s.db.QueryContext(ctx, s.dueQuery(), OutboxPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []OutboxMessage
+	for rows.Next() {
+		m, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, m)
+	}
+	return due, rows.Err()
+}
+
+// dueQuery returns the query of Due(), which reads a batch of rows rather than every pending message.
+func (s *SQLOutboxStore) dueQuery() string {
+	q := `SELECT ` + outboxColumns + ` FROM ` + s.table + ` WHERE state = ? AND next_attempt_at <= ? ORDER BY created_at`
+	if s.fetchFirst {
+		return s.query(q + ` OFFSET 0 ROWS FETCH FIRST ? ROWS ONLY`)
+	}
+	return s.query(q + ` LIMIT ?`)
+}
+
+// Update implements OutboxStore.
+func (s *SQLOutboxStore) Update(ctx context.Context, m OutboxMessage) error {
+	fields, files, err := encodeOutboxPayload(m)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		s.query(`UPDATE `+s.table+` SET domain = ?, endpoint = ?, fields = ?, files = ?, state = ?, attempts = ?, error = ?, message_id = ?, created_at = ?, next_attempt_at = ? WHERE id = ?`),
+		m.Domain, m.Endpoint, fields, files, m.State, m.Attempts, m.Error, m.MessageID, m.CreatedAt.UTC(), m.NextAttemptAt.UTC(), m.ID)
+	return err
+}
+
+// Get returns the message with the ID, or nil if there is none.
+func (s *SQLOutboxStore) Get(ctx context.Context, id string) (*OutboxMessage, error) {
+	row := s.db.QueryRowContext(ctx, s.query(`SELECT `+outboxColumns+` FROM `+s.table+` WHERE id = ?`), id)
+	m, err := scanOutboxMessage(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// query rewrites the `?` placeholders of q as `$1`, `$2`... if numbered placeholders are in use.
+func (s *SQLOutboxStore) query(q string) string {
+	return numberPlaceholders(q, s.numbered)
+}
+
+const outboxColumns = `id, domain, endpoint, fields, files, state, attempts, error, message_id, created_at, next_attempt_at`
+
+// encodeOutboxPayload returns the fields and files of the message encoded as JSON.
+func encodeOutboxPayload(m OutboxMessage) (string, string, error) {
+	fields, err := json.Marshal(m.Fields)
+	if err != nil {
+		return "", "", err
+	}
+	files, err := json.Marshal(m.Files)
+	if err != nil {
+		return "", "", err
+	}
+	return string(fields), string(files), nil
+}
+
+func scanOutboxMessage(row interface{ Scan(...interface{}) error }) (OutboxMessage, error) {
+	var m OutboxMessage
+	var fields, files string
+	err := row.Scan(&m.ID, &m.Domain, &m.Endpoint, &fields, &files, &m.State, &m.Attempts, &m.Error,
+		&m.MessageID, &m.CreatedAt, &m.NextAttemptAt)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal([]byte(fields), &m.Fields); err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal([]byte(files), &m.Files); err != nil {
+		return m, err
+	}
+	m.CreatedAt, m.NextAttemptAt = m.CreatedAt.UTC(), m.NextAttemptAt.UTC()
+	return m, nil
+}
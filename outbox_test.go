@@ -0,0 +1,215 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestOutbox(t *testing.T) {
+	defer func(d time.Duration) { sendRetryBackoff = d }(sendRetryBackoff)
+	sendRetryBackoff = time.Millisecond
+
+	requests := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ensure.Nil(t, req.ParseMultipartForm(1<<20))
+		subject := req.FormValue("subject")
+		requests[subject]++
+		switch {
+		case subject == "flaky" && requests[subject] == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"message": "try again"}`)
+		case subject == "dropped" && requests[subject] == 1:
+			conn, _, err := w.(http.Hijacker).Hijack()
+			ensure.Nil(t, err)
+			conn.Close()
+		case subject == "rejected":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message": "'to' parameter is not a valid address"}`)
+		default:
+			fmt.Fprintf(w, `{"id": "<%s@example.com>", "message": "Queued. Thank you."}`, subject)
+		}
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	store := NewMemoryOutboxStore()
+	outbox := NewOutbox(mg, store)
+	ctx := context.Background()
+
+	var ids []string
+	for _, subject := range []string{"welcome", "flaky", "rejected", "dropped"} {
+		m := mg.NewMessage(fromUser, subject, exampleText, "joe@example.com")
+		m.AddBufferAttachment("receipt.txt", []byte("receipt"))
+		id, err := outbox.Enqueue(ctx, m)
+		ensure.Nil(t, err)
+		ids = append(ids, id)
+	}
+	m := mg.NewMessage(fromUser, "keyed", exampleText, "joe@example.com")
+	m.SetIdempotencyKey("order-1")
+	id, err := outbox.Enqueue(ctx, m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, id, "order-1")
+
+	_, err = outbox.Enqueue(ctx, mg.NewMessage(fromUser, "", "", "joe@example.com"))
+	ensure.NotNil(t, err)
+
+	sent, err := outbox.Process(ctx, 10)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, sent, 2)
+
+	flaky, err := store.Get(ctx, ids[1])
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, flaky.State, OutboxPending)
+	ensure.DeepEqual(t, flaky.Attempts, 1)
+	// A dropped connection is retried, although the outcome of the first attempt is unknown
+	dropped, err := store.Get(ctx, ids[3])
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, dropped.State, OutboxPending)
+	ensure.DeepEqual(t, dropped.Attempts, 1)
+	rejected, err := store.Get(ctx, ids[2])
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, rejected.State, OutboxFailed)
+	ensure.StringContains(t, rejected.Error, "not a valid address")
+
+	time.Sleep(5 * time.Millisecond)
+	sent, err = outbox.Process(ctx, 10)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, sent, 2)
+	flaky, err = store.Get(ctx, ids[1])
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, flaky.State, OutboxSent)
+	ensure.DeepEqual(t, flaky.MessageID, "<flaky@example.com>")
+	dropped, err = store.Get(ctx, ids[3])
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, dropped.State, OutboxSent)
+	ensure.DeepEqual(t, dropped.MessageID, "<dropped@example.com>")
+
+	// Messages sent are not sent again, even if the store is not updated
+	flaky.State = OutboxPending
+	ensure.Nil(t, store.Update(ctx, *flaky))
+	sent, err = outbox.Process(ctx, 10)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, sent, 1)
+	ensure.DeepEqual(t, requests, map[string]int{"welcome": 1, "flaky": 2, "rejected": 1, "dropped": 2, "keyed": 1})
+}
+
+func TestOutboxPaused(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			// The domain lookup of PauseSending() is best-effort
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		requests++
+		fmt.Fprint(w, `{"id": "<paused@example.com>", "message": "Queued. Thank you."}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	store := NewMemoryOutboxStore()
+	outbox := NewOutbox(mg, store)
+	ctx := context.Background()
+
+	id, err := outbox.Enqueue(ctx, mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com"))
+	ensure.Nil(t, err)
+	_, err = mg.PauseSending(ctx, exampleDomain, nil)
+	ensure.Nil(t, err)
+
+	// The message waits for the domain to be resumed, without using up its attempts
+	sent, err := outbox.Process(ctx, 10)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, sent, 0)
+	m, err := store.Get(ctx, id)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, m.State, OutboxPending)
+	ensure.DeepEqual(t, m.Attempts, 0)
+	ensure.StringContains(t, m.Error, "paused")
+	ensure.DeepEqual(t, requests, 0)
+
+	_, err = mg.ResumeSending(ctx, exampleDomain)
+	ensure.Nil(t, err)
+	m.NextAttemptAt = time.Now()
+	ensure.Nil(t, store.Update(ctx, *m))
+	sent, err = outbox.Process(ctx, 10)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, sent, 1)
+	ensure.DeepEqual(t, requests, 1)
+}
+
+func TestOutboxSendPipeline(t *testing.T) {
+	type request struct {
+		path string
+		to   []string
+	}
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ensure.Nil(t, req.ParseMultipartForm(1<<20))
+		requests = append(requests, request{path: req.URL.Path, to: req.MultipartForm.Value["to"]})
+		fmt.Fprint(w, `{"id": "<pipeline@example.com>", "message": "Queued. Thank you."}`)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	suppressions := NewMemorySuppressionStore()
+	ensure.Nil(t, suppressions.Suppress(ctx, Suppression{Address: "bounced@example.com", Reason: SuppressionBounce}))
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetSuppressionGuard(&SuppressionGuardOptions{Store: suppressions})
+	mg.SetMIMETransformers(func(ctx context.Context, msg []byte) ([]byte, error) {
+		return append([]byte("X-Signed: yes\r\n"), msg...), nil
+	})
+	store := NewMemoryOutboxStore()
+	outbox := NewOutbox(mg, store)
+
+	// Suppressed recipients are removed when the message is enqueued
+	_, err := outbox.Enqueue(ctx, mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com", "bounced@example.com"))
+	ensure.Nil(t, err)
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com")
+	m.AddBufferAttachment("invoice.exe", []byte("MZ"))
+	vetoed, err := outbox.Enqueue(ctx, m)
+	ensure.Nil(t, err)
+
+	// Attachment policies set since are applied when the message is sent
+	mg.SetAttachmentPolicies(func(ctx context.Context, a PolicyAttachment) error {
+		return fmt.Errorf("%s is not allowed", a.Filename)
+	})
+	sent, err := outbox.Process(ctx, 10)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, sent, 1)
+	ensure.DeepEqual(t, requests, []request{{path: "/v3/" + exampleDomain + "/messages.mime", to: []string{"joe@example.com"}}})
+
+	failed, err := store.Get(ctx, vetoed)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, failed.State, OutboxFailed)
+	ensure.StringContains(t, failed.Error, "invoice.exe is not allowed")
+
+	// Messages the policies veto are not enqueued
+	m = mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com")
+	m.AddBufferAttachment("setup.exe", []byte("MZ"))
+	_, err = outbox.Enqueue(ctx, m)
+	violation, ok := err.(*PolicyViolation)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, violation.Filename, "setup.exe")
+}
+
+func TestSQLOutboxStoreDueQuery(t *testing.T) {
+	store, err := NewSQLOutboxStore(nil, "outbox")
+	ensure.Nil(t, err)
+	ensure.StringContains(t, store.dueQuery(), "ORDER BY created_at LIMIT ?")
+
+	store.SetNumberedPlaceholders()
+	ensure.StringContains(t, store.dueQuery(), "next_attempt_at <= $2 ORDER BY created_at LIMIT $3")
+
+	store.SetFetchFirst()
+	ensure.StringContains(t, store.dueQuery(), "ORDER BY created_at OFFSET 0 ROWS FETCH FIRST $3 ROWS ONLY")
+}
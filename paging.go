@@ -0,0 +1,228 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SetDefaultPageSize sets the page size `ListMailingLists()`, `ListMembers()`, `ListEvents()`,
+// `ListBounces()`, `ListUnsubscribes()` and `ListComplaints()` fetch when their options set no
+// Limit, and no limit among their Params. Zero, the default, leaves the page size to Mailgun,
+// which assumes 100. Mailgun caps the page size of most endpoints at MaxPageSize, and of events
+// at MaxEventsPageSize; event pages are capped at that size rather than failing.
+func (mg *MailgunImpl) SetDefaultPageSize(n int) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.defaultPageSize = n
+}
+
+// addPageLimit sets the limit parameter of the request of the first page of an iterator: limit,
+// replacing any limit among the Params of its options, or if limit is zero and the request has
+// no limit yet, the default page size of the client, up to max if it is not zero.
+func (mg *MailgunImpl) addPageLimit(r *httpRequest, limit, max int) {
+	if limit == 0 {
+		if _, ok := r.Parameters["limit"]; ok {
+			return
+		}
+		mg.mu.RLock()
+		limit = mg.defaultPageSize
+		mg.mu.RUnlock()
+		if max != 0 && limit > max {
+			limit = max
+		}
+	}
+	if limit != 0 {
+		r.setParameter("limit", strconv.Itoa(limit))
+	}
+}
+
+// offsetPageOptions returns the page size of an iterator paged with skip and limit, such as that
+// of routes, and the Params of opts to add to its url. The Limit of opts wins over a limit among
+// Params, which is used if Limit is not set; the iterator sets skip and limit for each page, so
+// they are left out of the params.
+func offsetPageOptions(opts *ListOptions) (int, map[string]string) {
+	var limit int
+	var params map[string]string
+	if opts != nil {
+		limit = opts.Limit
+		for name, value := range opts.Params {
+			switch name {
+			case "limit":
+				if n, err := strconv.Atoi(value); err == nil && limit == 0 {
+					limit = n
+				}
+			case "skip":
+			default:
+				if params == nil {
+					params = make(map[string]string, len(opts.Params))
+				}
+				params[name] = value
+			}
+		}
+	}
+	if limit == 0 {
+		limit = 100
+	}
+	return limit, params
+}
+
+// PageURL is a parsed page link of a Paging.
+type PageURL struct {
+	URL *url.URL
+	// Page is the direction of the page relative to the pivot, such as first, last, next or prev.
+	Page string
+	// Pivot is the item the page starts or ends at, such as the address of a bounce or the name of a
+	// template, or the cursor of an events page.
+	Pivot string
+	// Limit is the page size, or 0 if the API default is used.
+	Limit int
+}
+
+// ParsePageURL parses and validates a page link returned by Mailgun.
+func ParsePageURL(raw string) (*PageURL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid paging url '%s': %s", raw, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid paging url '%s': not an absolute http url", raw)
+	}
+	if !validURL.MatchString(u.Path) {
+		return nil, fmt.Errorf("invalid paging url '%s': no api version in path", raw)
+	}
+
+	q := u.Query()
+	p := &PageURL{URL: u, Page: q.Get("page")}
+	for _, pivot := range []string{"p", "address", "tag", "pivot"} {
+		if v := q.Get(pivot); v != "" {
+			p.Pivot = v
+			break
+		}
+	}
+	if p.Pivot == "" {
+		// Events pages have their cursor as the last path segment, /v3/{domain}/events/{cursor}
+		parts := strings.Split(strings.TrimSuffix(u.Path, "/"), "/")
+		if len(parts) > 1 && parts[len(parts)-2] == eventsEndpoint {
+			p.Pivot = parts[len(parts)-1]
+		}
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if p.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, fmt.Errorf("invalid paging url '%s': limit is not a number", raw)
+		}
+	}
+	return p, nil
+}
+
+// Validate returns an error if any of the page links is not a valid URL.
+func (p Paging) Validate() error {
+	for _, raw := range []string{p.First, p.Next, p.Previous, p.Last} {
+		if raw == "" {
+			continue
+		}
+		if _, err := ParsePageURL(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FirstPage parses the link to the first page, returning nil if there is none.
+func (p Paging) FirstPage() (*PageURL, error) { return parseOptionalPageURL(p.First) }
+
+// NextPage parses the link to the next page, returning nil if there is none.
+func (p Paging) NextPage() (*PageURL, error) { return parseOptionalPageURL(p.Next) }
+
+// PreviousPage parses the link to the previous page, returning nil if there is none.
+func (p Paging) PreviousPage() (*PageURL, error) { return parseOptionalPageURL(p.Previous) }
+
+// LastPage parses the link to the last page, returning nil if there is none.
+func (p Paging) LastPage() (*PageURL, error) { return parseOptionalPageURL(p.Last) }
+
+func parseOptionalPageURL(raw string) (*PageURL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	return ParsePageURL(raw)
+}
+
+// fetchPage fetches the page at link, as returned by Mailgun, decoding it into page.
+func fetchPage(ctx context.Context, m Mailgun, link string, page interface{}) error {
+	url, err := pageURL(m, link)
+	if err != nil {
+		return err
+	}
+	return getResponseFromJSON(ctx, newAPIRequest(m, url), page)
+}
+
+// pageURL returns the page link to fetch with the client. Mailgun returns absolute links, which
+// may point at a different region or host than the API base of the client, so the scheme, host
+// and path prefix are replaced with those of the API base. Relative links, as returned by some
+// proxies and older servers, are resolved against the API base.
+func pageURL(m Mailgun, raw string) (string, error) {
+	if raw == "" {
+		return raw, nil
+	}
+	base, err := url.Parse(m.APIBase())
+	if err != nil || base.Host == "" {
+		// Leave the link as it is; the request reports an invalid API base
+		return raw, nil
+	}
+	if ref, err := url.Parse(raw); err == nil && !ref.IsAbs() {
+		dir := *base
+		dir.Path, dir.RawPath = strings.TrimSuffix(base.Path, "/")+"/", ""
+		raw = dir.ResolveReference(ref).String()
+	}
+	page, err := ParsePageURL(raw)
+	if err != nil {
+		return "", err
+	}
+
+	u := *page.URL
+	// The escaped path is kept, so escaped addresses in it, such as of list members, stay escaped
+	escaped := u.EscapedPath()
+	loc := validURL.FindStringIndex(escaped)
+	u.Scheme, u.Host, u.User = base.Scheme, base.Host, base.User
+	u.RawPath = apiVersionSuffix.ReplaceAllString(strings.TrimSuffix(base.EscapedPath(), "/"), "") + escaped[loc[0]:]
+	if u.Path, err = url.PathUnescape(u.RawPath); err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// prefetch is a page being fetched in the background by an iterator, while the caller processes
+// the page before it.
+type prefetch struct {
+	url  string
+	page interface{}
+	done chan struct{}
+	err  error
+}
+
+// startPrefetch calls fetch in the background to decode the page at url into page.
+func startPrefetch(ctx context.Context, url string, page interface{}, fetch func(ctx context.Context, url string, page interface{}) error) *prefetch {
+	p := &prefetch{url: url, page: page, done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		p.err = fetch(ctx, url, page)
+	}()
+	return p
+}
+
+// wait returns the page prefetched from url. ok is false if no page was prefetched from url, the
+// prefetch failed, or ctx is done first; the caller then fetches the page itself, so errors are
+// returned with the context of the call which asked for the page.
+func (p *prefetch) wait(ctx context.Context, url string) (page interface{}, ok bool) {
+	if p == nil || p.url != url {
+		return nil, false
+	}
+	select {
+	case <-p.done:
+		return p.page, p.err == nil
+	case <-ctx.Done():
+		return nil, false
+	}
+}
@@ -0,0 +1,169 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestParsePageURL(t *testing.T) {
+	page, err := mailgun.ParsePageURL("https://api.mailgun.net/v3/mailgun.test/bounces?page=next&address=bob%40example.com&limit=50")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, page.Page, "next")
+	ensure.DeepEqual(t, page.Pivot, "bob@example.com")
+	ensure.DeepEqual(t, page.Limit, 50)
+	ensure.DeepEqual(t, page.URL.Host, "api.mailgun.net")
+
+	page, err = mailgun.ParsePageURL("https://api.eu.mailgun.net/v3/mailgun.test/events/W3siYiI6IDF9XQ==")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, page.Pivot, "W3siYiI6IDF9XQ==")
+	ensure.DeepEqual(t, page.Limit, 0)
+
+	for _, raw := range []string{
+		"/v3/mailgun.test/bounces?page=next",
+		"ftp://api.mailgun.net/v3/mailgun.test/bounces",
+		"https://api.mailgun.net/mailgun.test/bounces",
+		"https://api.mailgun.net/v3/mailgun.test/bounces?limit=ten",
+		"https://api.mailgun.net/v3/%zz",
+	} {
+		_, err := mailgun.ParsePageURL(raw)
+		ensure.NotNil(t, err)
+	}
+
+	paging := mailgun.Paging{Next: "https://api.mailgun.net/v3/mailgun.test/tags?page=next&tag=a", Last: "not a url"}
+	ensure.NotNil(t, paging.Validate())
+	next, err := paging.NextPage()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, next.Pivot, "a")
+	prev, err := paging.PreviousPage()
+	ensure.Nil(t, err)
+	ensure.True(t, prev == nil)
+}
+
+func TestPagingOtherRegion(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/v3/mailgun.test/events" {
+			// Mailgun links the next page on the US region, although the client uses another host
+			fmt.Fprint(w, `{"items": [{"event": "delivered", "id": "1"}],
+				"paging": {"next": "https://api.mailgun.net/v3/mailgun.test/events/CURSOR"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"items": [], "paging": {}}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	it := mg.ListEvents(nil)
+	var page []mailgun.Event
+	ensure.True(t, it.Next(context.Background(), &page))
+	ensure.False(t, it.Next(context.Background(), &page))
+	ensure.Nil(t, it.Err())
+	ensure.DeepEqual(t, paths, []string{"/v3/mailgun.test/events", "/v3/mailgun.test/events/CURSOR"})
+}
+
+func TestDefaultPageSize(t *testing.T) {
+	var limits []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits = append(limits, r.URL.Path+"?limit="+r.URL.Query().Get("limit"))
+		fmt.Fprint(w, `{"items": [], "paging": {}}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+	fetch := func() {
+		limits = nil
+		var lists []mailgun.MailingList
+		mg.ListMailingLists(nil).Next(ctx, &lists)
+		var members []mailgun.Member
+		mg.ListMembers("dev@example.com", nil).Next(ctx, &members)
+		var events []mailgun.Event
+		mg.ListEvents(nil).Next(ctx, &events)
+		var bounces []mailgun.Bounce
+		mg.ListBounces(nil).Next(ctx, &bounces)
+		var unsubscribes []mailgun.Unsubscribe
+		mg.ListUnsubscribes(nil).Next(ctx, &unsubscribes)
+		var complaints []mailgun.Complaint
+		mg.ListComplaints(nil).Next(ctx, &complaints)
+	}
+
+	fetch()
+	ensure.DeepEqual(t, limits, []string{
+		"/v3/lists/pages?limit=",
+		"/v3/lists/dev@example.com/members/pages?limit=",
+		"/v3/mailgun.test/events?limit=",
+		"/v3/mailgun.test/bounces?limit=",
+		"/v3/mailgun.test/unsubscribes?limit=",
+		"/v3/mailgun.test/complaints?limit=",
+	})
+
+	mg.SetDefaultPageSize(500)
+	fetch()
+	ensure.DeepEqual(t, limits, []string{
+		"/v3/lists/pages?limit=500",
+		"/v3/lists/dev@example.com/members/pages?limit=500",
+		"/v3/mailgun.test/events?limit=300",
+		"/v3/mailgun.test/bounces?limit=500",
+		"/v3/mailgun.test/unsubscribes?limit=500",
+		"/v3/mailgun.test/complaints?limit=500",
+	})
+
+	// The options of a call override the default
+	limits = nil
+	var bounces []mailgun.Bounce
+	mg.ListBounces(&mailgun.ListOptions{Limit: 10}).Next(ctx, &bounces)
+	mg.ListBounces(&mailgun.ListOptions{Params: map[string]string{"limit": "20"}}).Next(ctx, &bounces)
+	var events []mailgun.Event
+	mg.ListEvents(&mailgun.ListEventOptions{Limit: 300}).Next(ctx, &events)
+	ensure.DeepEqual(t, limits, []string{
+		"/v3/mailgun.test/bounces?limit=10",
+		"/v3/mailgun.test/bounces?limit=20",
+		"/v3/mailgun.test/events?limit=300",
+	})
+}
+
+func TestLimitOverridesParams(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path+"?"+r.URL.RawQuery)
+		fmt.Fprint(w, `{"items": [], "paging": {}, "total_count": 0}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+	params := map[string]string{"limit": "20", "skip": "5", "extra": "yes"}
+
+	var bounces []mailgun.Bounce
+	mg.ListBounces(&mailgun.ListOptions{Limit: 10, Params: params}).Next(ctx, &bounces)
+	var templates []mailgun.Template
+	mg.ListTemplates(&mailgun.ListTemplateOptions{Limit: 10, Params: params}).Next(ctx, &templates)
+	var tags []mailgun.Tag
+	mg.ListTags(&mailgun.ListTagOptions{Limit: 10, Params: params}).Next(ctx, &tags)
+	var routes []mailgun.Route
+	mg.ListRoutes(&mailgun.ListOptions{Limit: 10, Params: params}).Next(ctx, &routes)
+	// Without a Limit, the limit among Params is the page size of routes
+	mg.ListRoutes(&mailgun.ListOptions{Params: params}).Next(ctx, &routes)
+	ensure.DeepEqual(t, queries, []string{
+		"/v3/mailgun.test/bounces?extra=yes&limit=10&skip=5",
+		"/v3/mailgun.test/templates?extra=yes&limit=10&skip=5",
+		"/v3/mailgun.test/tags?extra=yes&limit=10&skip=5",
+		"/v3/routes?extra=yes&limit=10",
+		"/v3/routes?extra=yes&limit=20",
+	})
+}
@@ -62,9 +62,16 @@ func parseResponse(raw []byte) ([]Event, error) {
 
 // Given a slice of events.RawJSON events return a slice of Event for each parsed event
 func ParseEvents(raw []events.RawJSON) ([]Event, error) {
-	var result []Event
+	return parseEvents(nil, raw)
+}
+
+func parseEvents(codec JSONCodec, raw []events.RawJSON) ([]Event, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	result := make([]Event, 0, len(raw))
 	for _, value := range raw {
-		event, err := ParseEvent(value)
+		event, err := parseEvent(codec, value)
 		if err != nil {
 			return nil, fmt.Errorf("while parsing event: %s", err)
 		}
@@ -75,23 +82,37 @@ func ParseEvents(raw []events.RawJSON) ([]Event, error) {
 
 // Parse converts raw bytes data into an event struct. Can accept events.RawJSON as input
 func ParseEvent(raw []byte) (Event, error) {
+	return parseEvent(nil, raw)
+}
+
+func parseEvent(codec JSONCodec, raw []byte) (Event, error) {
 	// Try to recognize the event first.
-	var e events.EventName
-	if err := jsoniter.Unmarshal(raw, &e); err != nil {
+	name, err := eventName(raw)
+	if err != nil {
 		return nil, fmt.Errorf("failed to recognize event: %v", err)
 	}
 
 	// Get the event "constructor" from the map.
-	newEvent, ok := EventNames[e.GetName()]
+	newEvent, ok := EventNames[name]
 	if !ok {
-		return nil, fmt.Errorf("unsupported event: '%s'", e.GetName())
+		return nil, fmt.Errorf("unsupported event: '%s'", name)
+	}
+	if codec == nil {
+		event, ok, err := decodeEvent(name, raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse event '%s': %v", name, err)
+		}
+		if ok {
+			return event, nil
+		}
 	}
 	event := newEvent()
 
 	// Parse the known event.
-	if err := jsoniter.Unmarshal(raw, event); err != nil {
-		return nil, fmt.Errorf("failed to parse event '%s': %v", e.GetName(), err)
+	if err := unmarshalEvent(codec, raw, event); err != nil {
+		return nil, fmt.Errorf("failed to parse event '%s': %v", name, err)
 	}
+	setEventExtra(event, raw)
 
 	return event, nil
 }
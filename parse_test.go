@@ -24,6 +24,25 @@ func TestParseErrors(t *testing.T) {
 	ensure.StringContains(t, err.Error(), "failed to parse event 'accepted'")
 }
 
+func TestParseEventNameNotFirst(t *testing.T) {
+	event, err := ParseEvent([]byte(`{
+		"id": "id-1",
+		"message": {"headers": {"event": "not-this-one"}},
+		"tags": ["event"],
+		"event": "DELIVERED",
+		"recipient": "joe@example.com"
+	}`))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, reflect.TypeOf(event).String(), "*events.Delivered")
+	ensure.DeepEqual(t, event.(*events.Delivered).Recipient, "joe@example.com")
+
+	_, err = ParseEvent([]byte(`{"id": "id-1"}`))
+	ensure.DeepEqual(t, err.Error(), "unsupported event: ''")
+
+	_, err = ParseEvent([]byte(`{"event": "delivered"`))
+	ensure.NotNil(t, err)
+}
+
 func TestParseSuccess(t *testing.T) {
 	event, err := ParseEvent([]byte(`{
 		"event": "accepted",
@@ -0,0 +1,103 @@
+package mailgun
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// PlanLimitError describes a request which failed because the plan of the account does not
+// include what was asked for, such as a feature of a higher plan, or more domains than the plan
+// allows. It is reported by Mailgun as 402 Payment Required, or as 403 Forbidden with a message
+// about the plan. Upgrading the plan, rather than retrying, resolves it.
+//
+// Requests still fail with an *UnexpectedResponseError, so code asserting that type keeps
+// working; find the PlanLimitError in it with errors.As:
+//
+//  var planErr *mailgun.PlanLimitError
+//  if errors.As(err, &planErr) {
+//    log.Printf("upgrade the plan: %s", planErr.Message)
+//  }
+type PlanLimitError struct {
+	*UnexpectedResponseError
+	// Message is the message of the response.
+	Message string
+}
+
+// Unwrap returns the UnexpectedResponseError of the response.
+func (e *PlanLimitError) Unwrap() error {
+	return e.UnexpectedResponseError
+}
+
+// QuotaExceededError describes a request which failed because the account has used up its
+// quota, such as the number of messages it may send in a month or a day. Requests succeed again
+// once the quota resets or is raised. As with PlanLimitError, find it in the
+// *UnexpectedResponseError of the request with errors.As.
+type QuotaExceededError struct {
+	*UnexpectedResponseError
+	// Message is the message of the response.
+	Message string
+}
+
+// Unwrap returns the UnexpectedResponseError of the response.
+func (e *QuotaExceededError) Unwrap() error {
+	return e.UnexpectedResponseError
+}
+
+// As lets errors.As find the PlanLimitError or QuotaExceededError the response was recognized as.
+func (e *UnexpectedResponseError) As(target interface{}) bool {
+	switch t := target.(type) {
+	case **PlanLimitError:
+		if p, ok := e.limit.(*PlanLimitError); ok {
+			*t = p
+			return true
+		}
+	case **QuotaExceededError:
+		if q, ok := e.limit.(*QuotaExceededError); ok {
+			*t = q
+			return true
+		}
+	}
+	return false
+}
+
+// quotaPhrases and planPhrases are found in the messages of 402 and 403 responses caused by
+// quotas and plans. Quota phrases name the limit, as "limit exceeded" alone is also said of
+// rate limits and of the limits of other resources.
+var (
+	quotaPhrases = []string{"sending limit", "messaging limit", "message limit", "sending quota", "quota exceeded", "exceeded your quota"}
+	planPhrases  = []string{"plan", "upgrade", "free account", "payment", "billing", "not available on"}
+)
+
+// planLimitError records on e whether it is a PlanLimitError or a QuotaExceededError, and returns it.
+func planLimitError(e *UnexpectedResponseError) *UnexpectedResponseError {
+	if e.Actual != http.StatusPaymentRequired && e.Actual != http.StatusForbidden {
+		return e
+	}
+	message := string(e.Data)
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(e.Data, &body) == nil && body.Message != "" {
+		message = body.Message
+	}
+
+	lower := strings.ToLower(message)
+	for _, p := range quotaPhrases {
+		if strings.Contains(lower, p) {
+			e.limit = &QuotaExceededError{UnexpectedResponseError: e, Message: message}
+			return e
+		}
+	}
+	if e.Actual == http.StatusPaymentRequired {
+		e.limit = &PlanLimitError{UnexpectedResponseError: e, Message: message}
+		return e
+	}
+	for _, p := range planPhrases {
+		if strings.Contains(lower, p) {
+			e.limit = &PlanLimitError{UnexpectedResponseError: e, Message: message}
+			return e
+		}
+	}
+	return e
+}
@@ -0,0 +1,64 @@
+package mailgun_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestPlanLimitErrors(t *testing.T) {
+	responses := map[string]struct {
+		code int
+		body string
+	}{
+		"/v3/domains/feature.example.com":   {http.StatusPaymentRequired, `{"message": "Dedicated IPs are not available on your plan"}`},
+		"/v3/domains/free.example.com":      {http.StatusForbidden, `{"message": "Free accounts are for test purposes only. Please upgrade"}`},
+		"/v3/domains/quota.example.com":     {http.StatusForbidden, `{"message": "Domain quota.example.com is not allowed to send: monthly sending limit reached"}`},
+		"/v3/domains/tags.example.com":      {http.StatusForbidden, `{"message": "Tag limit exceeded"}`},
+		"/v3/domains/forbidden.example.com": {http.StatusForbidden, `{"message": "Forbidden"}`},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := responses[r.URL.Path]
+		w.WriteHeader(resp.code)
+		w.Write([]byte(resp.body))
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	_, err := mg.GetDomain(ctx, "feature.example.com")
+	var planErr *mailgun.PlanLimitError
+	ensure.True(t, errors.As(err, &planErr))
+	ensure.DeepEqual(t, planErr.Message, "Dedicated IPs are not available on your plan")
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusPaymentRequired)
+	// Callers asserting the type of the error are not broken
+	_, ok := err.(*mailgun.UnexpectedResponseError)
+	ensure.True(t, ok)
+
+	_, err = mg.GetDomain(ctx, "free.example.com")
+	ensure.True(t, errors.As(err, &planErr))
+
+	_, err = mg.GetDomain(ctx, "quota.example.com")
+	var quotaErr *mailgun.QuotaExceededError
+	ensure.True(t, errors.As(err, &quotaErr))
+	ensure.DeepEqual(t, quotaErr.Actual, http.StatusForbidden)
+	ensure.False(t, errors.As(err, &planErr))
+
+	// Other limits are not the quota of the account
+	_, err = mg.GetDomain(ctx, "tags.example.com")
+	ensure.False(t, errors.As(err, &quotaErr))
+
+	_, err = mg.GetDomain(ctx, "forbidden.example.com")
+	_, ok = err.(*mailgun.UnexpectedResponseError)
+	ensure.True(t, ok)
+	ensure.False(t, errors.As(err, &planErr))
+	ensure.False(t, errors.As(err, &quotaErr))
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusForbidden)
+}
@@ -0,0 +1,274 @@
+package mailgun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PreflightCheck names a check made by `PreflightProduction()`.
+type PreflightCheck string
+
+const (
+	// PreflightDomainState checks that the domain is active, and not a sandbox domain.
+	PreflightDomainState PreflightCheck = "domain-state"
+	// PreflightDNS checks the sending and receiving records Mailgun expects.
+	PreflightDNS PreflightCheck = "dns"
+	// PreflightTracking checks the open, click and unsubscribe tracking settings.
+	PreflightTracking PreflightCheck = "tracking"
+	// PreflightWebhooks checks that bounces and complaints are reported to a webhook.
+	PreflightWebhooks PreflightCheck = "webhooks"
+	// PreflightSuppressions checks that the suppression lists of the domain were populated.
+	PreflightSuppressions PreflightCheck = "suppressions"
+	// PreflightDMARC checks that the domain publishes a DMARC policy which its mail aligns with.
+	PreflightDMARC PreflightCheck = "dmarc"
+)
+
+// DefaultPreflightWebhooks are the webhooks `PreflightProduction()` warns about when the domain has
+// none of that kind, as they report the addresses to stop sending to.
+var DefaultPreflightWebhooks = []string{"permanent_fail", "complained"}
+
+// PreflightIssue is a problem found by `PreflightProduction()`.
+type PreflightIssue struct {
+	Check PreflightCheck
+	// Blocker issues should be fixed before the domain sends real mail; others are warnings.
+	Blocker bool
+	Message string
+}
+
+func (i PreflightIssue) String() string {
+	severity := "warning"
+	if i.Blocker {
+		severity = "blocker"
+	}
+	return fmt.Sprintf("%s: %s: %s", severity, i.Check, i.Message)
+}
+
+// PreflightReport is the result of `PreflightProduction()`.
+type PreflightReport struct {
+	Domain string
+	// DNS holds the checks of each record Mailgun expects for the domain.
+	DNS DNSReport
+	// DMARC is the DMARC record which applies to the domain, empty if there is none.
+	DMARC  string
+	Issues []PreflightIssue
+}
+
+// Ready reports whether no blocker was found.
+func (r PreflightReport) Ready() bool {
+	return len(r.Blockers()) == 0
+}
+
+// Blockers returns the issues to fix before the domain sends real mail.
+func (r PreflightReport) Blockers() []PreflightIssue {
+	return r.issues(true)
+}
+
+// Warnings returns the issues which do not block sending.
+func (r PreflightReport) Warnings() []PreflightIssue {
+	return r.issues(false)
+}
+
+func (r PreflightReport) issues(blocker bool) []PreflightIssue {
+	var issues []PreflightIssue
+	for _, i := range r.Issues {
+		if i.Blocker == blocker {
+			issues = append(issues, i)
+		}
+	}
+	return issues
+}
+
+func (r *PreflightReport) add(check PreflightCheck, blocker bool, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, PreflightIssue{Check: check, Blocker: blocker, Message: fmt.Sprintf(format, args...)})
+}
+
+// PreflightOptions modifies the checks of `PreflightProduction()`.
+type PreflightOptions struct {
+	// Resolver looks up DNS records; defaults to net.DefaultResolver.
+	Resolver DNSResolver
+	// RequiredWebhooks are the kinds of webhooks, such as "delivered", whose absence is a blocker.
+	// The absence of the DefaultPreflightWebhooks not listed is a warning.
+	RequiredWebhooks []string
+	// RequireSuppressions makes empty suppression lists a blocker rather than a warning. Set it
+	// when moving from another provider, whose bounces, unsubscribes and complaints must be
+	// imported before sending.
+	RequireSuppressions bool
+}
+
+// PreflightProduction checks whether a domain is ready to send real mail, such as before switching
+// traffic from a sandbox or another provider, and reports the blockers to fix first:
+//
+//   - the domain must be active, and not a sandbox domain;
+//   - its sending DNS records must resolve as `CheckDomainDNS()` expects;
+//   - with open or click tracking enabled, its tracking CNAME must resolve;
+//   - the domain, or its organizational domain, must publish a DMARC record, and the DKIM or SPF
+//     record of the domain must resolve for its mail to align with it.
+//
+// Missing receiving records, disabled unsubscribe tracking, missing bounce and complaint webhooks
+// and empty suppression lists are reported as warnings, unless opts requires them. opts may be nil.
+// The organizational domain is taken as the last two labels of the domain, which is wrong for
+// domains under public suffixes such as co.uk.
+//
+//  report, err := mg.PreflightProduction(ctx, "mg.example.com", nil)
+//  for _, issue := range report.Blockers() {
+//    fmt.Println(issue)
+//  }
+//
+// An error is returned when a request to Mailgun fails, along with the checks made before it.
+func (mg *MailgunImpl) PreflightProduction(ctx context.Context, domain string, opts *PreflightOptions) (PreflightReport, error) {
+	var o PreflightOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Resolver == nil {
+		o.Resolver = net.DefaultResolver
+	}
+	report := PreflightReport{Domain: domain}
+
+	resp, err := mg.GetDomain(ctx, domain)
+	if err != nil {
+		return report, err
+	}
+	if isSandboxDomain(domain) {
+		report.add(PreflightDomainState, true, "sandbox domains only deliver to authorized recipients")
+	}
+	if resp.Domain.State != DomainStateActive {
+		report.add(PreflightDomainState, true, "domain is %s, not %s", resp.Domain.State, DomainStateActive)
+	}
+
+	report.DNS = CheckDNSRecords(ctx, o.Resolver, resp)
+	var dkimOK, spfOK, trackingOK bool
+	for _, c := range report.DNS.Sending {
+		ok := c.Status == DNSRecordOK
+		switch {
+		case strings.EqualFold(c.Record.RecordType, "CNAME"):
+			trackingOK = ok
+		case strings.Contains(c.Record.Name, "._domainkey."):
+			dkimOK = ok
+		case strings.HasPrefix(compactTXT(c.Record.Value), "v=spf1 "):
+			spfOK = ok
+		}
+		if !ok {
+			report.add(PreflightDNS, true, "%s record %s is %s", c.Record.RecordType, recordName(c.Record, domain), c.Status)
+		}
+	}
+	for _, c := range report.DNS.Receiving {
+		if c.Status != DNSRecordOK {
+			report.add(PreflightDNS, false, "%s record %s is %s; replies and inbound routes will not reach Mailgun",
+				c.Record.RecordType, recordName(c.Record, domain), c.Status)
+		}
+	}
+
+	tracking, err := mg.GetDomainTracking(ctx, domain)
+	if err != nil {
+		return report, errors.Wrap(err, "while getting tracking settings")
+	}
+	if (tracking.Open.Active || tracking.Click.Active) && !trackingOK {
+		report.add(PreflightTracking, true, "open or click tracking is enabled, but the tracking CNAME does not resolve")
+	}
+	if !tracking.Unsubscribe.Active {
+		report.add(PreflightTracking, false, "unsubscribe tracking is disabled; messages need unsubscribe links of their own")
+	}
+
+	hooks, err := mg.listWebhooks(ctx, domain)
+	if err != nil {
+		return report, errors.Wrap(err, "while listing webhooks")
+	}
+	required := make(map[string]bool, len(o.RequiredWebhooks))
+	for _, kind := range o.RequiredWebhooks {
+		required[kind] = true
+		if len(hooks[kind]) == 0 {
+			report.add(PreflightWebhooks, true, "no %s webhook", kind)
+		}
+	}
+	for _, kind := range DefaultPreflightWebhooks {
+		if !required[kind] && len(hooks[kind]) == 0 {
+			report.add(PreflightWebhooks, false, "no %s webhook", kind)
+		}
+	}
+
+	empty := true
+	for _, endpoint := range []string{bouncesEndpoint, unsubscribesEndpoint, complaintsEndpoint} {
+		n, err := mg.countSuppressions(ctx, domain, endpoint)
+		if err != nil {
+			return report, errors.Wrapf(err, "while listing %s", endpoint)
+		}
+		if n != 0 {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		report.add(PreflightSuppressions, o.RequireSuppressions,
+			"the bounces, unsubscribes and complaints of the domain are empty; import those of any previous provider")
+	}
+
+	var dmarcErr error
+	report.DMARC, dmarcErr = lookupDMARC(ctx, o.Resolver, domain)
+	switch {
+	case dmarcErr != nil:
+		report.add(PreflightDMARC, true, "%s", dmarcErr)
+	case report.DMARC == "":
+		report.add(PreflightDMARC, true, "no DMARC record at _dmarc.%s or its organizational domain", domain)
+	case !dkimOK && !spfOK:
+		report.add(PreflightDMARC, true, "neither the DKIM nor the SPF record resolves, so mail will not align with the DMARC policy")
+	case !dkimOK:
+		report.add(PreflightDMARC, false, "only SPF aligns with the DMARC policy, which forwarding breaks; fix the DKIM record")
+	}
+	return report, nil
+}
+
+// countSuppressions returns the number of entries, up to one, of a suppression list of the domain.
+func (mg *MailgunImpl) countSuppressions(ctx context.Context, domain, endpoint string) (int, error) {
+	r := newAPIRequest(mg, generateApiUrlWithDomain(mg, endpoint, domain))
+	r.addParameter("limit", "1")
+	var resp struct {
+		Items []json.RawMessage `json:"items"`
+	}
+	err := getResponseFromJSON(ctx, r, &resp)
+	return len(resp.Items), err
+}
+
+// lookupDMARC returns the DMARC record of the domain, or else of its organizational domain, or
+// nothing if neither publishes one.
+func lookupDMARC(ctx context.Context, resolver DNSResolver, domain string) (string, error) {
+	names := []string{domain}
+	labels := strings.Split(strings.TrimSuffix(domain, "."), ".")
+	if len(labels) > 2 {
+		names = append(names, strings.Join(labels[len(labels)-2:], "."))
+	}
+	for _, name := range names {
+		found, err := resolver.LookupTXT(ctx, "_dmarc."+name)
+		if err != nil {
+			if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+				continue
+			}
+			return "", errors.Wrapf(err, "while looking up _dmarc.%s", name)
+		}
+		for _, txt := range found {
+			txt = compactTXT(txt)
+			if strings.HasPrefix(strings.ToUpper(txt), "V=DMARC1") {
+				return txt, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// isSandboxDomain reports whether the domain is a sandbox domain Mailgun created with the account.
+func isSandboxDomain(domain string) bool {
+	domain = strings.ToLower(domain)
+	return strings.HasPrefix(domain, "sandbox") && strings.HasSuffix(domain, ".mailgun.org")
+}
+
+func recordName(record DNSRecord, domain string) string {
+	if record.Name == "" {
+		return domain
+	}
+	return record.Name
+}
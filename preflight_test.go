@@ -0,0 +1,105 @@
+package mailgun_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestPreflightProduction(t *testing.T) {
+	var bounces string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body interface{}
+		switch r.URL.Path {
+		case "/v3/domains/mg.example.com":
+			body = mailgun.DomainResponse{
+				Domain: mailgun.Domain{Name: "mg.example.com", State: mailgun.DomainStateActive},
+				SendingDNSRecords: []mailgun.DNSRecord{
+					{RecordType: "TXT", Name: "mg.example.com", Value: "v=spf1 include:mailgun.org ~all"},
+					{RecordType: "TXT", Name: "smtp._domainkey.mg.example.com", Value: "k=rsa; p=MIGfMA0"},
+					{RecordType: "CNAME", Name: "email.mg.example.com", Value: "mailgun.org"},
+				},
+				ReceivingDNSRecords: []mailgun.DNSRecord{
+					{RecordType: "MX", Name: "mg.example.com", Value: "mxa.mailgun.org"},
+				},
+			}
+		case "/v3/domains/mg.example.com/tracking":
+			body = map[string]interface{}{"tracking": mailgun.DomainTracking{
+				Open:        mailgun.TrackingStatus{Active: true},
+				Unsubscribe: mailgun.TrackingStatus{Active: true},
+			}}
+		case "/v3/domains/mg.example.com/webhooks":
+			body = mailgun.WebHooksListResponse{Webhooks: map[string]mailgun.UrlOrUrls{
+				"permanent_fail": {Urls: []string{"https://example.com/hooks"}},
+			}}
+		case "/v3/mg.example.com/bounces":
+			if r.FormValue("limit") != "1" {
+				t.Errorf("limit = %q", r.FormValue("limit"))
+			}
+			items := []interface{}{}
+			if bounces != "" {
+				items = append(items, map[string]string{"address": bounces})
+			}
+			body = map[string]interface{}{"items": items}
+		case "/v3/mg.example.com/unsubscribes", "/v3/mg.example.com/complaints":
+			body = map[string]interface{}{"items": []interface{}{}}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	// Only the SPF record resolves, and no DMARC record is published
+	resolver := fakeResolver{
+		txt: map[string][]string{"mg.example.com": {"v=spf1 include:mailgun.org ~all"}},
+		mx:  map[string][]*net.MX{"mg.example.com": {{Host: "mxa.mailgun.org."}}},
+	}
+	report, err := mg.PreflightProduction(ctx, "mg.example.com", &mailgun.PreflightOptions{
+		Resolver:            resolver,
+		RequireSuppressions: true,
+	})
+	ensure.Nil(t, err)
+	ensure.False(t, report.Ready())
+	var checks []mailgun.PreflightCheck
+	for _, issue := range report.Blockers() {
+		checks = append(checks, issue.Check)
+	}
+	ensure.DeepEqual(t, checks, []mailgun.PreflightCheck{
+		mailgun.PreflightDNS,
+		mailgun.PreflightDNS,
+		mailgun.PreflightTracking,
+		mailgun.PreflightSuppressions,
+		mailgun.PreflightDMARC,
+	})
+	ensure.DeepEqual(t, len(report.Warnings()), 1)
+	ensure.DeepEqual(t, report.Warnings()[0].String(), "warning: webhooks: no complained webhook")
+
+	// The DMARC record of the organizational domain applies
+	resolver.txt["smtp._domainkey.mg.example.com"] = []string{"k=rsa; p=MIGfMA0"}
+	resolver.txt["_dmarc.example.com"] = []string{"v=DMARC1; p=quarantine"}
+	resolver.cname = map[string]string{"email.mg.example.com": "mailgun.org."}
+	bounces = "bounced@example.com"
+	report, err = mg.PreflightProduction(ctx, "mg.example.com", &mailgun.PreflightOptions{
+		Resolver:         resolver,
+		RequiredWebhooks: []string{"complained"},
+	})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, report.DMARC, "v=DMARC1; p=quarantine")
+	ensure.DeepEqual(t, len(report.Blockers()), 1)
+	ensure.DeepEqual(t, report.Blockers()[0].Message, "no complained webhook")
+
+	_, err = mg.PreflightProduction(ctx, "unknown.example.com", &mailgun.PreflightOptions{Resolver: resolver})
+	ensure.NotNil(t, err)
+}
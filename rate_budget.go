@@ -0,0 +1,274 @@
+package mailgun
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The classes of endpoints a RateBudget tracks requests by.
+const (
+	// RateClassSend is the class of requests which send messages.
+	RateClassSend = "send"
+	// RateClassLists is the class of requests to mailing lists and their members.
+	RateClassLists = "lists"
+	// RateClassEvents is the class of requests to the events api.
+	RateClassEvents = "events"
+	// RateClassDomains is the class of requests to domains and their settings.
+	RateClassDomains = "domains"
+	// RateClassSuppressions is the class of requests to bounces, unsubscribes and complaints.
+	RateClassSuppressions = "suppressions"
+	// RateClassOther is the class of requests to every other endpoint.
+	RateClassOther = "other"
+)
+
+// rateBudgetWindow is the window request rates are observed over.
+const rateBudgetWindow = time.Minute
+
+// rateClass returns the class of the request to the url.
+func rateClass(address string) string {
+	if u, err := url.Parse(address); err == nil {
+		if name := path.Base(u.Path); name == messagesEndpoint || name == mimeMessagesEndpoint {
+			// Sends are made to the path of their domain, which need not be a domain name.
+			return RateClassSend
+		}
+	}
+	switch cacheEndpoint(address) {
+	case listsEndpoint:
+		return RateClassLists
+	case eventsEndpoint:
+		return RateClassEvents
+	case domainsEndpoint:
+		return RateClassDomains
+	case bouncesEndpoint, unsubscribesEndpoint, complaintsEndpoint:
+		return RateClassSuppressions
+	}
+	return RateClassOther
+}
+
+// RateClassStats are the requests of a class a RateBudget observed in the last minute.
+type RateClassStats struct {
+	Class string
+	// Requests is the number of requests made, and Throttled the number rejected with 429.
+	Requests  int
+	Throttled int
+	// Limit and Remaining are the last rate limit Mailgun reported for the class in the
+	// X-RateLimit headers of a response, and Reset when it resets; they are zero if Mailgun
+	// reported none.
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// RateDecision is the answer of `RateBudget.CanStart()`.
+type RateDecision struct {
+	// OK is set if the work can start without exceeding the budget.
+	OK bool
+	// Reason explains the decision.
+	Reason string
+	// Needed is the number of requests a minute the work needs, and Available the number the
+	// budget has left for it; Available is -1 if the budget has no limit.
+	Needed    int
+	Available int
+}
+
+// RateBudget tracks the rate of the requests a client makes, by class of endpoint, against the
+// rate limit of its API key, so operators sharing a key across workloads can plan bulk work
+// around the traffic which matters most. Requests are observed over the last minute.
+//
+//  b := mg.RateBudget()
+//  b.SetLimit(1000)
+//  b.Reserve(mailgun.RateClassSend, 600)
+//  // 50 000 members are created in chunks of 1000, over at least 5 minutes
+//  if d := b.CanStart(mailgun.RateClassLists, 50, 5*time.Minute); !d.OK {
+//    log.Printf("import postponed: %s", d.Reason)
+//  }
+//
+// A RateBudget is safe for concurrent use.
+type RateBudget struct {
+	clock    clock
+	mu       sync.Mutex
+	limit    int
+	reserves map[string]int
+	classes  map[string]*rateClassState
+}
+
+type rateClassState struct {
+	requests  []time.Time
+	throttled []time.Time
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+func newRateBudget(c clock) *RateBudget {
+	return &RateBudget{clock: c, reserves: make(map[string]int), classes: make(map[string]*rateClassState)}
+}
+
+// RateBudget returns the rate budget of the client, which observes every request the client
+// makes once it was first called.
+func (mg *MailgunImpl) RateBudget() *RateBudget {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	if mg.rateBudget == nil {
+		mg.rateBudget = newRateBudget(mg.clock())
+	}
+	return mg.rateBudget
+}
+
+// SetLimit sets the number of requests a minute the API key may make, across all classes. Zero,
+// the default, means the limit is unknown; the budget then only refuses work while requests are
+// being throttled.
+func (b *RateBudget) SetLimit(perMinute int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = perMinute
+}
+
+// Reserve keeps perMinute requests a minute of the limit for the class, such as transactional
+// sends, which work of other classes may not use even while the class is idle.
+func (b *RateBudget) Reserve(class string, perMinute int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if perMinute <= 0 {
+		delete(b.reserves, class)
+		return
+	}
+	b.reserves[class] = perMinute
+}
+
+// Stats returns the requests observed in the last minute, by class, sorted by class.
+func (b *RateBudget) Stats() []RateClassStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	stats := make([]RateClassStats, 0, len(b.classes))
+	for name, c := range b.classes {
+		c.trim(now)
+		stats = append(stats, RateClassStats{
+			Class:     name,
+			Requests:  len(c.requests),
+			Throttled: len(c.throttled),
+			Limit:     c.limit,
+			Remaining: c.remaining,
+			Reset:     c.reset,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Class < stats[j].Class })
+	return stats
+}
+
+// CanStart reports whether work of the class which makes the number of requests over at least
+// within can start now without exceeding the budget: the requests of the other classes in the last
+// minute, the reserves of the other classes, and the requests a minute the work needs must fit in
+// the limit. Work is refused while requests of a class with a reserve are being throttled, and
+// while Mailgun reports the class has no requests remaining. Pass 0 for within if the work starts
+// its requests as fast as it can.
+func (b *RateBudget) CanStart(class string, requests int, within time.Duration) RateDecision {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+
+	needed := requests
+	if within > rateBudgetWindow {
+		needed = int((int64(requests)*int64(rateBudgetWindow) + int64(within) - 1) / int64(within))
+	}
+	d := RateDecision{Needed: needed, Available: -1}
+
+	for name := range b.reserves {
+		if c := b.classes[name]; c != nil && name != class {
+			if c.trim(now); len(c.throttled) != 0 {
+				d.Reason = fmt.Sprintf("%s requests are being throttled", name)
+				return d
+			}
+		}
+	}
+	if c := b.classes[class]; c != nil && c.limit > 0 && c.remaining == 0 && now.Before(c.reset) {
+		d.Reason = fmt.Sprintf("mailgun reports no %s requests remaining until %s", class, c.reset.Format(time.RFC3339))
+		return d
+	}
+	if b.limit == 0 {
+		d.OK, d.Reason = true, "no limit is set"
+		return d
+	}
+
+	used := 0
+	for name, c := range b.classes {
+		if name == class {
+			continue
+		}
+		c.trim(now)
+		count := len(c.requests)
+		if reserve := b.reserves[name]; reserve > count {
+			count = reserve
+		}
+		used += count
+	}
+	for name, reserve := range b.reserves {
+		if _, seen := b.classes[name]; !seen && name != class {
+			used += reserve
+		}
+	}
+	d.Available = b.limit - used
+	if d.Available < 0 {
+		d.Available = 0
+	}
+	if needed > d.Available {
+		d.Reason = fmt.Sprintf("needs %d requests a minute; %d of %d are left after other classes and reserves", needed, d.Available, b.limit)
+		return d
+	}
+	d.OK, d.Reason = true, fmt.Sprintf("needs %d of the %d requests a minute left", needed, d.Available)
+	return d
+}
+
+// observe records a request to the url, and the response to it, if any.
+func (b *RateBudget) observe(address string, resp *http.Response) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	name := rateClass(address)
+	c := b.classes[name]
+	if c == nil {
+		c = &rateClassState{}
+		b.classes[name] = c
+	}
+	c.trim(now)
+	c.requests = append(c.requests, now)
+	if resp == nil {
+		return
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		c.throttled = append(c.throttled, now)
+	}
+	if limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit")); err == nil {
+		c.limit = limit
+		c.remaining, _ = strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+		c.reset = time.Time{}
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			c.reset = time.Unix(reset, 0)
+		}
+	}
+}
+
+// trim forgets the requests observed before the window.
+func (c *rateClassState) trim(now time.Time) {
+	cutoff := now.Add(-rateBudgetWindow)
+	c.requests = trimTimes(c.requests, cutoff)
+	c.throttled = trimTimes(c.throttled, cutoff)
+}
+
+func trimTimes(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	if i == 0 {
+		return times
+	}
+	return append(times[:0], times[i:]...)
+}
@@ -0,0 +1,76 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestRateBudget(t *testing.T) {
+	throttle := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/messages") {
+			if throttle {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			fmt.Fprint(w, `{"message":"Queued. Thank you.", "id":"<id@example.com>"}`)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", "300")
+		w.Header().Set("X-RateLimit-Remaining", "299")
+		w.Header().Set("X-RateLimit-Reset", "1614600060")
+		fmt.Fprint(w, `{"member": {"address": "dev@example.com"}}`)
+	}))
+	defer srv.Close()
+
+	clock := newFakeClock()
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.clk = clock
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	b := mg.RateBudget()
+	for i := 0; i < 3; i++ {
+		_, _, err := mg.Send(ctx, mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com"))
+		ensure.Nil(t, err)
+	}
+	_, err := mg.GetMailingList(ctx, "dev@example.com")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, b.Stats(), []RateClassStats{
+		{Class: RateClassLists, Requests: 1, Limit: 300, Remaining: 299, Reset: time.Unix(1614600060, 0)},
+		{Class: RateClassSend, Requests: 3},
+	})
+
+	// Without a limit, only throttling blocks work
+	ensure.True(t, b.CanStart(RateClassLists, 50, 0).OK)
+
+	b.SetLimit(100)
+	b.Reserve(RateClassSend, 60)
+	d := b.CanStart(RateClassLists, 50, 0)
+	ensure.False(t, d.OK)
+	ensure.DeepEqual(t, d.Available, 40)
+	ensure.DeepEqual(t, d.Needed, 50)
+	// Spread over two minutes, the import needs 25 requests a minute
+	d = b.CanStart(RateClassLists, 50, 2*time.Minute)
+	ensure.True(t, d.OK)
+	ensure.DeepEqual(t, d.Needed, 25)
+
+	throttle = true
+	_, _, err = mg.Send(ctx, mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com"))
+	ensure.NotNil(t, err)
+	d = b.CanStart(RateClassLists, 1, 0)
+	ensure.False(t, d.OK)
+	ensure.DeepEqual(t, d.Reason, "send requests are being throttled")
+
+	// Requests older than a minute are forgotten
+	clock.Advance(61 * time.Second)
+	ensure.DeepEqual(t, b.Stats()[1], RateClassStats{Class: RateClassSend})
+	ensure.True(t, b.CanStart(RateClassLists, 40, 0).OK)
+}
@@ -0,0 +1,202 @@
+package mailgun
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Defaults of RateMonitorOptions.
+const (
+	DefaultRateMonitorInterval = 15 * time.Minute
+	DefaultRateMonitorPeriod   = 24 * time.Hour
+	DefaultRateClearRatio      = 0.8
+	DefaultRateMinVolume       = 100
+)
+
+// The rates a RateMonitor alerts on.
+const (
+	RateMetricBounce    = "bounce"
+	RateMetricComplaint = "complaint"
+)
+
+// RateMonitorOptions configures `MonitorRates()`. Zero fields take their defaults.
+type RateMonitorOptions struct {
+	// Interval is how often the stats are polled; defaults to 15 minutes.
+	Interval time.Duration
+	// Period is how far back from each poll the rates are computed over; defaults to 24 hours.
+	Period time.Duration
+	// BounceRateThreshold is the proportion of outgoing messages which may bounce before an
+	// alert is raised; defaults to 2%, as for `GetReputationSnapshot()`.
+	BounceRateThreshold float64
+	// ComplaintRateThreshold is the proportion of delivered messages which may be reported as
+	// spam before an alert is raised; defaults to 0.1%.
+	ComplaintRateThreshold float64
+	// ClearRatio is the proportion of its threshold a rate must fall under before its alert is
+	// cleared, so a rate hovering around the threshold does not raise an alert at every poll;
+	// defaults to 0.8.
+	ClearRatio float64
+	// MinVolume is the number of messages a rate must be computed from to be considered, so a
+	// single bounce on a quiet day raises no alert; defaults to 100.
+	MinVolume int
+}
+
+// RateAlert is raised by a RateMonitor when a rate goes over its threshold, and again when it
+// falls back under the clear threshold.
+type RateAlert struct {
+	Domain string
+	// Time is when the stats were polled.
+	Time time.Time
+	// Metric is RateMetricBounce or RateMetricComplaint.
+	Metric string
+	// Rate is the rate over the period, and Threshold the threshold it crossed: the alert
+	// threshold, or the clear threshold if Cleared is set.
+	Rate      float64
+	Threshold float64
+	// Cleared is set when the rate fell back under the clear threshold after an alert.
+	Cleared bool
+}
+
+// RateAlertFunc is called by a RateMonitor for each alert, from the goroutine of the monitor.
+type RateAlertFunc func(RateAlert)
+
+// RateMonitor polls the stats of a domain and calls a RateAlertFunc when its bounce or complaint
+// rate crosses a threshold.
+//
+//  m := mg.MonitorRates(ctx, "example.com", nil, func(a mailgun.RateAlert) {
+//    if a.Cleared {
+//      notify("%s %s rate is back to %.2f%%", a.Domain, a.Metric, a.Rate*100)
+//      return
+//    }
+//    notify("%s %s rate is %.2f%%", a.Domain, a.Metric, a.Rate*100)
+//  })
+//  defer m.Stop()
+type RateMonitor struct {
+	mg      *MailgunImpl
+	domain  string
+	opts    RateMonitorOptions
+	onAlert RateAlertFunc
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// MonitorRates starts polling the stats of domain at each interval, until the context is cancelled
+// or `RateMonitor.Stop()` is called. A rate over its threshold raises one alert, and another once
+// it falls under its clear threshold; rates computed from fewer than MinVolume messages are
+// ignored. A failed poll is retried at the next interval.
+func (mg *MailgunImpl) MonitorRates(ctx context.Context, domain string, opts *RateMonitorOptions, onAlert RateAlertFunc) *RateMonitor {
+	var o RateMonitorOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Interval <= 0 {
+		o.Interval = DefaultRateMonitorInterval
+	}
+	if o.Period <= 0 {
+		o.Period = DefaultRateMonitorPeriod
+	}
+	if o.BounceRateThreshold <= 0 {
+		o.BounceRateThreshold = DefaultBounceRateThreshold
+	}
+	if o.ComplaintRateThreshold <= 0 {
+		o.ComplaintRateThreshold = DefaultComplaintRateThreshold
+	}
+	if o.ClearRatio <= 0 || o.ClearRatio > 1 {
+		o.ClearRatio = DefaultRateClearRatio
+	}
+	if o.MinVolume <= 0 {
+		o.MinVolume = DefaultRateMinVolume
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	m := &RateMonitor{
+		mg:      mg,
+		domain:  domain,
+		opts:    o,
+		onAlert: onAlert,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go m.run(ctx)
+	return m
+}
+
+// Err returns the error of the last poll, or nil if it succeeded. Once the monitor stopped, it is
+// the error of the context if it was cancelled.
+func (m *RateMonitor) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// Stop stops polling, and waits for an alert being raised to return. It must not be called from
+// the RateAlertFunc.
+func (m *RateMonitor) Stop() {
+	m.cancel()
+	<-m.done
+}
+
+func (m *RateMonitor) setErr(err error) {
+	m.mu.Lock()
+	m.err = err
+	m.mu.Unlock()
+}
+
+func (m *RateMonitor) run(ctx context.Context) {
+	defer close(m.done)
+	defer m.cancel()
+	clk := m.mg.clock()
+
+	alerting := make(map[string]bool)
+	for {
+		now := clk.Now()
+		stats, err := m.mg.getStats(ctx, m.domain, []string{"accepted", "delivered", "failed", "complained"},
+			&GetStatOptions{Resolution: ResolutionHour, Start: now.Add(-m.opts.Period), End: now})
+		if ctx.Err() != nil {
+			m.setErr(ctx.Err())
+			return
+		}
+		m.setErr(err)
+		if err == nil {
+			var total Stats
+			for _, s := range stats {
+				total.add(s)
+			}
+			m.check(now, alerting, RateMetricBounce, total.Failed.Permanent.Bounce+total.Failed.Permanent.DelayedBounce,
+				total.Accepted.Outgoing, m.opts.BounceRateThreshold)
+			m.check(now, alerting, RateMetricComplaint, total.Complained.Total, total.Delivered.Total,
+				m.opts.ComplaintRateThreshold)
+		}
+
+		if err := clk.Sleep(ctx, m.opts.Interval); err != nil {
+			m.setErr(err)
+			return
+		}
+	}
+}
+
+// check raises an alert if the rate of n messages of a volume crossed the alert threshold, or the
+// clear threshold of an alert raised earlier.
+func (m *RateMonitor) check(now time.Time, alerting map[string]bool, metric string, n, volume int, threshold float64) {
+	if volume < m.opts.MinVolume {
+		return
+	}
+	r := rate(n, volume)
+	alert := RateAlert{Domain: m.domain, Time: now, Metric: metric, Rate: r, Threshold: threshold}
+	switch {
+	case !alerting[metric] && r > threshold:
+		alerting[metric] = true
+	case alerting[metric] && r < threshold*m.opts.ClearRatio:
+		alerting[metric] = false
+		alert.Threshold = threshold * m.opts.ClearRatio
+		alert.Cleared = true
+	default:
+		return
+	}
+	if m.onAlert != nil {
+		m.onAlert(alert)
+	}
+}
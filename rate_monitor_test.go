@@ -0,0 +1,73 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestMonitorRates(t *testing.T) {
+	// Outgoing, bounced, delivered and complained messages of each poll
+	polls := [][4]int{
+		{1000, 10, 990, 0},
+		{1000, 30, 970, 2}, // both over their thresholds
+		{1000, 19, 980, 2}, // bounces under the threshold, but not the clear threshold
+		{1000, 10, 990, 0}, // both cleared
+		{50, 25, 25, 5},    // too few messages to be considered
+		{1000, 25, 975, 0}, // bounces over again
+		{1000, 25, 975, 0}, // the monitor is stopped during this poll
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var n int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ensure.DeepEqual(t, r.URL.Path, "/v3/example.com/stats/total")
+		ensure.DeepEqual(t, r.FormValue("resolution"), "hour")
+		p := polls[n]
+		if n++; n == len(polls) {
+			defer cancel()
+		}
+		fmt.Fprintf(w, `{"stats": [{"accepted": {"outgoing": %d}, "failed": {"permanent": {"bounce": %d}},
+			"delivered": {"total": %d}, "complained": {"total": %d}}]}`, p[0], p[1], p[2], p[3])
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	clock := newFakeClock()
+	mg.clk = clock
+	start := clock.Now()
+
+	var alerts []RateAlert
+	m := mg.MonitorRates(ctx, "example.com", nil, func(a RateAlert) {
+		alerts = append(alerts, a)
+	})
+	<-ctx.Done()
+	m.Stop()
+	ensure.DeepEqual(t, m.Err(), context.Canceled)
+	ensure.DeepEqual(t, n, len(polls))
+
+	type summary struct {
+		Poll    int
+		Metric  string
+		Cleared bool
+	}
+	var got []summary
+	for _, a := range alerts {
+		ensure.DeepEqual(t, a.Domain, "example.com")
+		got = append(got, summary{int(a.Time.Sub(start) / DefaultRateMonitorInterval), a.Metric, a.Cleared})
+	}
+	ensure.DeepEqual(t, got, []summary{
+		{1, RateMetricBounce, false},
+		{1, RateMetricComplaint, false},
+		{3, RateMetricBounce, true},
+		{3, RateMetricComplaint, true},
+		{5, RateMetricBounce, false},
+	})
+	ensure.DeepEqual(t, alerts[0].Rate, 0.03)
+	ensure.DeepEqual(t, alerts[0].Threshold, DefaultBounceRateThreshold)
+	ensure.DeepEqual(t, alerts[2].Threshold, DefaultBounceRateThreshold*DefaultRateClearRatio)
+}
@@ -0,0 +1,83 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// versionedPath matches paths given to DoRequest() which name their own API version.
+var versionedPath = regexp.MustCompile(`^/` + apiVersionPattern + `/(.*)$`)
+
+// DoRequest calls an endpoint of the Mailgun API this package does not wrap yet, with the API key,
+// API base and error handling of the client. The path is relative to the API base, such as
+// "/domains/example.com/limits/tag"; paths starting with an API version, such as "/v5/accounts",
+// replace the version of the API base. Params are sent in the query string of GET, HEAD and
+// DELETE requests, and as a form otherwise. If result is not nil, the JSON response is decoded
+// into it. Responses other than 2xx are returned as *UnexpectedResponseError, in which
+// errors.As finds a *PlanLimitError or *QuotaExceededError if the plan or quota of the account
+// caused them.
+//
+// GET, HEAD, PUT and DELETE requests are retried as configured with `SetSendRetries()`;
+// POST requests are never retried, as they may not be safe to repeat.
+//
+//  var resp struct {
+//    Items []struct{ Name string `json:"name"` } `json:"items"`
+//  }
+//  err := mg.DoRequest(ctx, http.MethodGet, "/domains/example.com/newfeature", url.Values{"limit": {"10"}}, &resp)
+func (mg *MailgunImpl) DoRequest(ctx context.Context, method, path string, params url.Values, result interface{}) error {
+	method = strings.ToUpper(method)
+
+	address := mg.APIBase() + "/" + strings.TrimPrefix(path, "/")
+	if m := versionedPath.FindStringSubmatch(path); m != nil {
+		address = generateVersionedApiUrl(mg, m[1], m[2])
+	}
+
+	var attempts int
+	for {
+		attempts++
+		r := newAPIRequest(mg, address)
+
+		var p payload
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodDelete:
+			for key, values := range params {
+				for _, value := range values {
+					r.addParameter(key, value)
+				}
+			}
+		default:
+			form := newPayload()
+			for key, values := range params {
+				for _, value := range values {
+					form.addValue(key, value)
+				}
+			}
+			p = form
+		}
+
+		rsp, err := makeRequest(ctx, r, method, p)
+		if err == nil {
+			if result == nil || len(rsp.Data) == 0 {
+				return nil
+			}
+			if err := rsp.parseFromJSON(result); err != nil {
+				return fmt.Errorf("while decoding response of %s %s: %s", method, path, err)
+			}
+			return nil
+		}
+
+		mg.mu.RLock()
+		retries := mg.sendRetries
+		mg.mu.RUnlock()
+		if method == http.MethodPost || attempts > retries || !isRetryableSendError(ctx, err) {
+			return err
+		}
+		if mg.clock().Sleep(ctx, sendBackoff(attempts)) != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestDoRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		ensure.DeepEqual(t, user, "api")
+		ensure.DeepEqual(t, pass, testKey)
+
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v3/domains/mailgun.test/feature":
+			ensure.DeepEqual(t, r.URL.Query().Get("limit"), "10")
+			fmt.Fprint(w, `{"items": [{"name": "one"}, {"name": "two"}]}`)
+		case "POST /v5/accounts/feature":
+			ensure.DeepEqual(t, r.FormValue("enabled"), "true")
+			fmt.Fprint(w, `{"message": "updated"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "not found"}`)
+		}
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	var list struct {
+		Items []struct {
+			Name string `json:"name"`
+		} `json:"items"`
+	}
+	err := mg.DoRequest(ctx, http.MethodGet, "/domains/mailgun.test/feature", url.Values{"limit": {"10"}}, &list)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(list.Items), 2)
+	ensure.DeepEqual(t, list.Items[1].Name, "two")
+
+	var resp struct {
+		Message string `json:"message"`
+	}
+	err = mg.DoRequest(ctx, http.MethodPost, "/v5/accounts/feature", url.Values{"enabled": {"true"}}, &resp)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, resp.Message, "updated")
+
+	err = mg.DoRequest(ctx, http.MethodDelete, "/unknown", nil, nil)
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusNotFound)
+}
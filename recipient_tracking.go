@@ -0,0 +1,151 @@
+package mailgun
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// recipientTracking is the click and open tracking of a recipient set with `SetRecipientTracking()`.
+type recipientTracking struct {
+	clicks, opens bool
+}
+
+// SetRecipientTracking overrides click and open tracking for one of the To: recipients of the
+// message, so that privacy-sensitive recipients of a batch can be excluded from tracking while the
+// rest are tracked as the message and domain say. Mailgun applies tracking options to a whole
+// request, so `SendBatch()` sends the message as one request for each set of tracking options in
+// use, with the recipients and recipient variables of that set; Cc: and Bcc: recipients receive
+// the first of them only. `Send()` cannot send messages with overrides, and returns an error.
+//
+//  m.AddRecipientAndVariables("opted-out@example.com", vars)
+//  m.SetRecipientTracking("opted-out@example.com", false, false)
+//  result, err := mg.SendBatch(ctx, m)
+func (m *Message) SetRecipientTracking(recipient string, clicks, opens bool) {
+	if m.recipientTracking == nil {
+		m.recipientTracking = make(map[string]recipientTracking)
+	}
+	m.recipientTracking[normalizeAddress(recipient)] = recipientTracking{clicks: clicks, opens: opens}
+}
+
+// splitByTracking returns a message for each set of tracking options used by the recipients of
+// m, in the order the sets are first used; recipients without an override share the options of m.
+func (m *Message) splitByTracking() ([]*Message, error) {
+	type group struct {
+		tracking *recipientTracking
+		to       []string
+	}
+	var groups []*group
+	byTracking := make(map[recipientTracking]*group)
+	var defaults *group
+	for _, to := range m.to {
+		t, ok := m.recipientTracking[normalizeAddress(to)]
+		var g *group
+		switch {
+		case !ok && defaults == nil:
+			defaults = &group{}
+			groups = append(groups, defaults)
+			g = defaults
+		case !ok:
+			g = defaults
+		case byTracking[t] == nil:
+			tracking := t
+			byTracking[t] = &group{tracking: &tracking}
+			groups = append(groups, byTracking[t])
+			g = byTracking[t]
+		default:
+			g = byTracking[t]
+		}
+		g.to = append(g.to, to)
+	}
+
+	to := make([][]string, len(groups))
+	keys := make([]string, len(groups))
+	for i, g := range groups {
+		to[i], keys[i] = g.to, strconv.Itoa(i)
+	}
+	parts, err := m.splitRecipients(to, keys)
+	if err != nil {
+		return nil, err
+	}
+	for i, part := range parts {
+		part.recipientTracking = nil
+		if t := groups[i].tracking; t != nil {
+			part.trackingClicks, part.trackingClicksSet = t.clicks, true
+			part.trackingOpens, part.trackingOpensSet = t.opens, true
+		}
+	}
+	return parts, nil
+}
+
+// splitRecipients returns a copy of m for each group of its To: recipients, with the recipient
+// variables of the group; Cc: and Bcc: recipients are kept by the first copy only. The idempotency
+// key of m, if any, is suffixed with the key of each group.
+func (m *Message) splitRecipients(groups [][]string, keys []string) ([]*Message, error) {
+	// Readers can only be read once, so keep their contents to give each part a reader of its own
+	attachments, err := readAttachments(m.readerAttachments)
+	if err != nil {
+		return nil, err
+	}
+	inlines, err := readAttachments(m.readerInlines)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]*Message, 0, len(groups))
+	for i, to := range groups {
+		part := *m
+		part.to = to
+		part.recipientVariables = nil
+		for _, to := range to {
+			if vars, ok := m.recipientVariables[to]; ok {
+				if part.recipientVariables == nil {
+					part.recipientVariables = make(map[string]map[string]interface{})
+				}
+				part.recipientVariables[to] = vars
+			}
+		}
+		if pm, ok := m.specific.(*plainMessage); ok && i > 0 {
+			cp := *pm
+			cp.cc, cp.bcc = nil, nil
+			part.specific = &cp
+		}
+		part.readerAttachments = attachmentReaders(m.readerAttachments, attachments)
+		part.readerInlines = attachmentReaders(m.readerInlines, inlines)
+		if m.idempotencyKey != "" {
+			part.idempotencyKey = m.idempotencyKey + "/" + keys[i]
+		}
+		parts = append(parts, &part)
+	}
+	m.readerAttachments = attachmentReaders(m.readerAttachments, attachments)
+	m.readerInlines = attachmentReaders(m.readerInlines, inlines)
+	return parts, nil
+}
+
+// readAttachments reads and closes the readers of the attachments.
+func readAttachments(attachments []ReaderAttachment) ([][]byte, error) {
+	var data [][]byte
+	for _, a := range attachments {
+		b, err := ioutil.ReadAll(a.ReadCloser)
+		a.ReadCloser.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading '%s'", a.Filename)
+		}
+		data = append(data, b)
+	}
+	return data, nil
+}
+
+// attachmentReaders returns the attachments with new readers of their data.
+func attachmentReaders(attachments []ReaderAttachment, data [][]byte) []ReaderAttachment {
+	if attachments == nil {
+		return nil
+	}
+	readers := make([]ReaderAttachment, len(attachments))
+	for i, a := range attachments {
+		readers[i] = ReaderAttachment{Filename: a.Filename, ReadCloser: ioutil.NopCloser(bytes.NewReader(data[i]))}
+	}
+	return readers
+}
@@ -1,7 +1,12 @@
 package mailgun
 
-import "fmt"
-import "strings"
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/yjimk/mailgun-go/v4/addresses"
+)
 
 type Recipient struct {
 	Name  string `json:"-"`
@@ -40,3 +45,24 @@ func (r *Recipient) UnmarshalText(text []byte) error {
 
 	return nil
 }
+
+// normalizeAddress returns the address as the package compares it, see addresses.Normalize().
+// Addresses which cannot be parsed are only trimmed and lower-cased.
+func normalizeAddress(address string) string {
+	if a, err := addresses.Normalize(address, nil); err == nil {
+		return a
+	}
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// normalizeMemberAddress normalizes the address of a new list member. Addresses with a display
+// name, which Mailgun takes as the name of the member, and invalid addresses are left to the API.
+func normalizeMemberAddress(address string) string {
+	if a, err := mail.ParseAddress(address); err == nil && a.Name != "" {
+		return address
+	}
+	if a, err := addresses.Normalize(address, nil); err == nil {
+		return a
+	}
+	return address
+}
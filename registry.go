@@ -0,0 +1,104 @@
+package mailgun
+
+import (
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ClientRegistry holds the clients of a multi-tenant platform, each with its own domain, API key
+// and region, keyed by domain or by any other name such as an account ID. Messages are routed to
+// the right client with ForSender(). A ClientRegistry is safe for concurrent use.
+//
+//  reg := mailgun.NewClientRegistry()
+//  reg.Add(mailgun.NewMailgun("mg.tenant-a.com", keyA))
+//  eu := mailgun.NewMailgun("mg.tenant-b.eu", keyB)
+//  eu.SetAPIBase(mailgun.APIBaseEU)
+//  reg.Add(eu)
+//
+//  mg, err := reg.ForSender("billing@mg.tenant-b.eu")
+//  if err != nil {
+//    return err
+//  }
+//  _, _, err = mg.Send(ctx, mg.NewMessage("billing@mg.tenant-b.eu", subject, text, to))
+type ClientRegistry struct {
+	mu      sync.RWMutex
+	clients map[string]Mailgun
+}
+
+// NewClientRegistry returns an empty registry.
+func NewClientRegistry() *ClientRegistry {
+	return &ClientRegistry{clients: make(map[string]Mailgun)}
+}
+
+// Add registers the client under its domain, replacing any client already registered for the domain.
+func (r *ClientRegistry) Add(mg Mailgun) {
+	r.Register(mg.Domain(), mg)
+}
+
+// Register registers the client under key, which is a domain or any other name, replacing any
+// client already registered under key. Keys are case-insensitive. Register the same client
+// under several domains if one API key sends for all of them.
+func (r *ClientRegistry) Register(key string, mg Mailgun) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients[strings.ToLower(key)] = mg
+}
+
+// Remove removes the client registered under key, if any.
+func (r *ClientRegistry) Remove(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, strings.ToLower(key))
+}
+
+// Get returns the client registered under key.
+func (r *ClientRegistry) Get(key string) (Mailgun, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mg, ok := r.clients[strings.ToLower(key)]
+	return mg, ok
+}
+
+// Keys returns the keys of all registered clients, sorted.
+func (r *ClientRegistry) Keys() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]string, 0, len(r.clients))
+	for k := range r.clients {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ForSender returns the client registered for the domain of the sender address, which may
+// include a display name. If no client is registered for the domain itself, the client of the
+// closest parent domain is returned, so a client registered for example.com also sends for
+// news.example.com.
+func (r *ClientRegistry) ForSender(from string) (Mailgun, error) {
+	address := from
+	if addr, err := mail.ParseAddress(from); err == nil {
+		address = addr.Address
+	}
+	at := strings.LastIndex(address, "@")
+	if at == -1 {
+		return nil, fmt.Errorf("sender '%s' is not an email address", from)
+	}
+
+	domain := strings.ToLower(address[at+1:])
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for {
+		if mg, ok := r.clients[domain]; ok {
+			return mg, nil
+		}
+		dot := strings.Index(domain, ".")
+		if dot == -1 || !strings.Contains(domain[dot+1:], ".") {
+			return nil, fmt.Errorf("no client registered for sender '%s'", from)
+		}
+		domain = domain[dot+1:]
+	}
+}
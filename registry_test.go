@@ -0,0 +1,43 @@
+package mailgun_test
+
+import (
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestClientRegistry(t *testing.T) {
+	us := mailgun.NewMailgun("example.com", "us-key")
+	eu := mailgun.NewMailgun("mg.example.eu", "eu-key")
+	eu.SetAPIBase(mailgun.APIBaseEU)
+
+	reg := mailgun.NewClientRegistry()
+	reg.Add(us)
+	reg.Add(eu)
+	reg.Register("tenant-42", eu)
+	ensure.DeepEqual(t, reg.Keys(), []string{"example.com", "mg.example.eu", "tenant-42"})
+
+	mg, ok := reg.Get("Tenant-42")
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, mg.APIKey(), "eu-key")
+
+	for from, key := range map[string]string{
+		"bob@example.com":                    "us-key",
+		"Billing <billing@news.example.com>": "us-key",
+		"alice@MG.example.eu":                "eu-key",
+	} {
+		mg, err := reg.ForSender(from)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, mg.APIKey(), key)
+	}
+
+	for _, from := range []string{"bob@other.com", "bob@example.eu", "not-an-address"} {
+		_, err := reg.ForSender(from)
+		ensure.NotNil(t, err)
+	}
+
+	reg.Remove("example.com")
+	_, err := reg.ForSender("bob@example.com")
+	ensure.NotNil(t, err)
+}
@@ -0,0 +1,159 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// Defaults of ReputationOptions.
+const (
+	DefaultReputationPeriod       = 7 * 24 * time.Hour
+	DefaultBounceRateThreshold    = 0.02
+	DefaultComplaintRateThreshold = 0.001
+	DefaultDeferralRateThreshold  = 0.05
+	defaultReputationFailures     = 1000
+)
+
+// ReputationOptions configures `GetReputationSnapshot()`. Zero fields take their defaults.
+type ReputationOptions struct {
+	// Period is how far back from now the snapshot looks; defaults to 7 days.
+	Period time.Duration
+	// BounceRateThreshold is the proportion of outgoing messages which may bounce before the
+	// snapshot warns; defaults to 2%.
+	BounceRateThreshold float64
+	// ComplaintRateThreshold is the proportion of delivered messages which may be reported as
+	// spam before the snapshot warns; defaults to 0.1%.
+	ComplaintRateThreshold float64
+	// DeferralRateThreshold is the proportion of outgoing messages which may be deferred by the
+	// receiving provider before the snapshot warns; defaults to 5%.
+	DeferralRateThreshold float64
+	// MaxFailures caps the number of failed events fetched to categorize failures; defaults to 1000.
+	MaxFailures int
+}
+
+// ReputationSnapshot summarizes the recent sending reputation of a domain.
+type ReputationSnapshot struct {
+	Domain     string
+	Begin, End time.Time
+
+	Accepted   int
+	Delivered  int
+	Bounced    int
+	Deferred   int
+	Complained int
+
+	// BounceRate is the proportion of outgoing messages which bounced.
+	BounceRate float64
+	// DeferralRate is the proportion of outgoing messages which the receiving provider deferred.
+	DeferralRate float64
+	// ComplaintRate is the proportion of delivered messages reported as spam.
+	ComplaintRate float64
+
+	// FailureCategories counts the permanent failures of the period by reason, such as
+	// "bounce", "suppress-bounce" or "espblock". Only the most recent MaxFailures are counted.
+	FailureCategories map[string]int
+	// Warnings describe each rate which is over its threshold; empty if the domain is healthy.
+	Warnings []string
+}
+
+// GetReputationSnapshot builds a snapshot of the reputation of a domain over a recent period:
+// bounce, deferral and complaint rates from the stats api, and the reasons of permanent
+// failures from the events api. Each rate over its threshold adds a warning to the snapshot,
+// so alerts can be raised before providers start deferring or blocking mail.
+//
+//  snap, err := mg.GetReputationSnapshot(ctx, "example.com", nil)
+//  if err != nil {
+//    return err
+//  }
+//  for _, w := range snap.Warnings {
+//    alert(snap.Domain, w)
+//  }
+func (mg *MailgunImpl) GetReputationSnapshot(ctx context.Context, domain string, opts *ReputationOptions) (ReputationSnapshot, error) {
+	var o ReputationOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Period <= 0 {
+		o.Period = DefaultReputationPeriod
+	}
+	if o.BounceRateThreshold <= 0 {
+		o.BounceRateThreshold = DefaultBounceRateThreshold
+	}
+	if o.ComplaintRateThreshold <= 0 {
+		o.ComplaintRateThreshold = DefaultComplaintRateThreshold
+	}
+	if o.DeferralRateThreshold <= 0 {
+		o.DeferralRateThreshold = DefaultDeferralRateThreshold
+	}
+	if o.MaxFailures <= 0 {
+		o.MaxFailures = defaultReputationFailures
+	}
+
+	end := time.Now()
+	snap := ReputationSnapshot{Domain: domain, Begin: end.Add(-o.Period), End: end}
+	stats, err := mg.getStats(ctx, domain, []string{"accepted", "delivered", "failed", "complained"},
+		&GetStatOptions{Resolution: ResolutionDay, Start: snap.Begin, End: snap.End})
+	if err != nil {
+		return snap, err
+	}
+	var total Stats
+	for _, s := range stats {
+		total.add(s)
+	}
+	snap.Accepted = total.Accepted.Outgoing
+	snap.Delivered = total.Delivered.Total
+	snap.Bounced = total.Failed.Permanent.Bounce + total.Failed.Permanent.DelayedBounce
+	snap.Deferred = total.Failed.Temporary.Espblock
+	snap.Complained = total.Complained.Total
+	snap.BounceRate = rate(snap.Bounced, snap.Accepted)
+	snap.DeferralRate = rate(snap.Deferred, snap.Accepted)
+	snap.ComplaintRate = rate(snap.Complained, snap.Delivered)
+
+	snap.FailureCategories = make(map[string]int)
+	it := mg.ListEventsWithDomain(&ListEventOptions{
+		Begin:           snap.Begin,
+		End:             snap.End,
+		ForceDescending: true,
+		Limit:           300,
+		Filter:          map[string]string{"event": "failed", "severity": events.SeverityPermanent},
+	}, domain)
+	var page []Event
+	counted := 0
+	for counted < o.MaxFailures && it.Next(ctx, &page) {
+		for _, e := range page {
+			f, ok := e.(*events.Failed)
+			if !ok || counted == o.MaxFailures {
+				continue
+			}
+			reason := f.Reason
+			if reason == "" {
+				reason = "unknown"
+			}
+			snap.FailureCategories[reason]++
+			counted++
+		}
+	}
+	if err := it.Err(); err != nil {
+		return snap, err
+	}
+
+	warn := func(name string, r, threshold float64) {
+		if r > threshold {
+			snap.Warnings = append(snap.Warnings, fmt.Sprintf("%s rate %.2f%% is over the threshold of %.2f%%", name, r*100, threshold*100))
+		}
+	}
+	warn("bounce", snap.BounceRate, o.BounceRateThreshold)
+	warn("deferral", snap.DeferralRate, o.DeferralRateThreshold)
+	warn("complaint", snap.ComplaintRate, o.ComplaintRateThreshold)
+	return snap, nil
+}
+
+func rate(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return float64(n) / float64(of)
+}
@@ -0,0 +1,70 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestGetReputationSnapshot(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ensure.True(t, strings.HasPrefix(r.URL.Path, "/v3/other.test/"))
+		if strings.HasSuffix(r.URL.Path, "/stats/total") {
+			ensure.DeepEqual(t, r.URL.Query()["event"], []string{"accepted", "delivered", "failed", "complained"})
+			fmt.Fprint(w, `{"stats": [
+				{"time": "Mon, 01 Jun 2020 00:00:00 UTC", "accepted": {"outgoing": 600}, "delivered": {"total": 560},
+					"failed": {"temporary": {"espblock": 10}, "permanent": {"bounce": 20, "total": 25}}, "complained": {"total": 1}},
+				{"time": "Tue, 02 Jun 2020 00:00:00 UTC", "accepted": {"outgoing": 400}, "delivered": {"total": 390},
+					"failed": {"permanent": {"bounce": 5, "delayed-bounce": 5, "total": 10}}, "complained": {"total": 1}}
+			]}`)
+			return
+		}
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+			return
+		}
+		ensure.DeepEqual(t, r.URL.Query().Get("event"), "failed")
+		ensure.DeepEqual(t, r.URL.Query().Get("severity"), "permanent")
+		fmt.Fprintf(w, `{"items": [
+			{"event": "failed", "id": "1", "severity": "permanent", "reason": "bounce"},
+			{"event": "failed", "id": "2", "severity": "permanent", "reason": "bounce"},
+			{"event": "failed", "id": "3", "severity": "permanent", "reason": "suppress-bounce"},
+			{"event": "failed", "id": "4", "severity": "permanent"}
+		], "paging": {"next": "http://%s/v3/other.test/events?page=2"}}`, r.Host)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	snap, err := mg.GetReputationSnapshot(context.Background(), "other.test", nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, snap.Domain, "other.test")
+	ensure.DeepEqual(t, snap.Accepted, 1000)
+	ensure.DeepEqual(t, snap.Delivered, 950)
+	ensure.DeepEqual(t, snap.Bounced, 30)
+	ensure.DeepEqual(t, snap.Deferred, 10)
+	ensure.DeepEqual(t, snap.Complained, 2)
+	ensure.DeepEqual(t, snap.BounceRate, 0.03)
+	ensure.DeepEqual(t, snap.DeferralRate, 0.01)
+	ensure.DeepEqual(t, snap.FailureCategories, map[string]int{"bounce": 2, "suppress-bounce": 1, "unknown": 1})
+	ensure.DeepEqual(t, snap.Warnings, []string{
+		"bounce rate 3.00% is over the threshold of 2.00%",
+		"complaint rate 0.21% is over the threshold of 0.10%",
+	})
+
+	snap, err = mg.GetReputationSnapshot(context.Background(), "other.test", &mailgun.ReputationOptions{
+		BounceRateThreshold:    0.05,
+		ComplaintRateThreshold: 0.01,
+		MaxFailures:            2,
+	})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(snap.Warnings), 0)
+	ensure.DeepEqual(t, snap.FailureCategories, map[string]int{"bounce": 2})
+}
@@ -0,0 +1,59 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SetRequestTimeout bounds each request made to the API, including reading its response, to d, or
+// to the time left before the deadline of its context if that is sooner, so a slow response
+// cannot hold up a caller which set no deadline. Helpers making several requests, such as the
+// ListAll methods, bound each of them, while the context still bounds the whole. Zero, the
+// default, leaves requests bounded by their context and the timeout of the HTTP client alone.
+func (mg *MailgunImpl) SetRequestTimeout(d time.Duration) {
+	mg.mu.Lock()
+	mg.requestTimeout = d
+	mg.mu.Unlock()
+}
+
+// SetDeadlineHeader sends the header name with each request whose deadline is known, from its
+// context or `SetRequestTimeout()`, holding the whole number of milliseconds left before it. The
+// Mailgun API does not read such a hint today, but a gateway or proxy which API traffic is routed
+// through may, to stop work the client will no longer wait for, such as Envoy with
+// x-envoy-upstream-rq-timeout-ms. Pass an empty name to stop sending the header, which is not
+// sent by default.
+//
+//  mg.SetRequestTimeout(10 * time.Second)
+//  mg.SetDeadlineHeader("X-Envoy-Upstream-Rq-Timeout-Ms")
+func (mg *MailgunImpl) SetDeadlineHeader(name string) {
+	mg.mu.Lock()
+	mg.deadlineHeader = http.CanonicalHeaderKey(name)
+	mg.mu.Unlock()
+}
+
+// requestContext returns the context a request is made with, bounded by the request timeout of
+// the options.
+func (o requestOptions) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.requestTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, o.requestTimeout)
+}
+
+// setDeadlineHeader sets the deadline hint of the options on req, if its context has a deadline.
+func (o requestOptions) setDeadlineHeader(req *http.Request) {
+	if o.deadlineHeader == "" {
+		return
+	}
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return
+	}
+	ms := time.Until(deadline).Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	req.Header.Set(o.deadlineHeader, strconv.FormatInt(ms, 10))
+}
@@ -0,0 +1,69 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestRequestDeadlines(t *testing.T) {
+	hints := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hints <- r.Header.Get("X-Request-Budget-Ms")
+		if r.URL.Path == "/v3/domains/slow.test" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		fmt.Fprint(w, `{"domain": {"name": "mailgun.test"}}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	// Without a deadline, no hint is sent
+	mg.SetDeadlineHeader("X-Request-Budget-Ms")
+	_, err := mg.GetDomain(ctx, testDomain)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, <-hints, "")
+
+	// The hint is the time left before the deadline of the context
+	deadlineCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	_, err = mg.GetDomain(deadlineCtx, testDomain)
+	ensure.Nil(t, err)
+	ms, err := strconv.Atoi(<-hints)
+	ensure.Nil(t, err)
+	ensure.True(t, ms > 50000 && ms <= 60000)
+
+	// or before the request timeout, if it is sooner
+	mg.SetRequestTimeout(200 * time.Millisecond)
+	_, err = mg.GetDomain(deadlineCtx, testDomain)
+	ensure.Nil(t, err)
+	ms, err = strconv.Atoi(<-hints)
+	ensure.Nil(t, err)
+	ensure.True(t, ms > 0 && ms <= 200)
+
+	// Requests are bounded by the timeout, even without a deadline
+	start := time.Now()
+	_, err = mg.GetDomain(ctx, "slow.test")
+	<-hints
+	ensure.NotNil(t, err)
+	ensure.True(t, time.Since(start) < 2*time.Second)
+
+	mg.SetDeadlineHeader("")
+	_, err = mg.GetDomain(deadlineCtx, testDomain)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, <-hints, "")
+}
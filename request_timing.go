@@ -0,0 +1,143 @@
+package mailgun
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestTiming breaks down the time a request of the client took, as traced with net/http/httptrace.
+// Phases which did not happen, such as the DNS lookup and connect of a request sent over a
+// connection kept alive, are zero.
+type RequestTiming struct {
+	// Method and URL are those of the request.
+	Method string
+	URL    string
+	// Endpoint is the kind of resource requested, such as "messages" or "events".
+	Endpoint string
+	// ReusedConn is set if the request was sent over a connection kept alive from an earlier one.
+	ReusedConn bool
+	// DNS, Connect and TLS are the time spent looking up the host, connecting to it and making
+	// the TLS handshake.
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	// Wait is the time from the request being written to the first byte of the response, which
+	// is mostly the time Mailgun took to answer.
+	Wait time.Duration
+	// TTFB is the time from the start of the request to the first byte of the response.
+	TTFB time.Duration
+	// Total is the time from the start of the request until its response was read.
+	Total time.Duration
+	// Status is the status code of the response; it is zero if no response was received.
+	Status int
+	// Err is the error which kept the request from completing, if any.
+	Err  error
+	Time time.Time
+}
+
+// Network returns the time spent setting up the connection: the DNS lookup, connect and TLS
+// handshake. Compare it with Wait to tell a slow network from a slow API.
+func (t RequestTiming) Network() time.Duration {
+	return t.DNS + t.Connect + t.TLS
+}
+
+// TimingHook is called with the timing of each request of the client, once its response was read.
+// The context is the one of the request.
+type TimingHook func(ctx context.Context, t RequestTiming)
+
+// SetTimingHook enables diagnostics, calling h with the timing of every request the client makes,
+// so slow sends can be told apart as network-side, such as connections which are not reused or
+// slow DNS, or API-side, as shown by a long Wait. Tracing adds a little overhead to each request,
+// so enable it while investigating, or for a sample of clients. The hook is called in the
+// goroutine of the request. Pass nil to disable diagnostics, which are disabled by default.
+//
+//  mg.SetTimingHook(func(ctx context.Context, t mailgun.RequestTiming) {
+//    log.Printf("%s %s: reused=%v network=%s wait=%s total=%s",
+//      t.Method, t.Endpoint, t.ReusedConn, t.Network(), t.Wait, t.Total)
+//  })
+func (mg *MailgunImpl) SetTimingHook(h TimingHook) {
+	mg.mu.Lock()
+	mg.timingHook = h
+	mg.mu.Unlock()
+}
+
+// requestTrace records the times of the phases of a request.
+type requestTrace struct {
+	start time.Time
+
+	mu                        sync.Mutex
+	reused                    bool
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wroteRequest, firstByte   time.Time
+}
+
+// traceRequest returns a context tracing the request made with it, and a function which passes
+// the timing of the request to the timing hook, if the client has one.
+func (r *httpRequest) traceRequest(ctx context.Context, method string) (context.Context, func(status int, err error)) {
+	h := r.options.onTiming
+	if h == nil {
+		return ctx, func(int, error) {}
+	}
+	t := &requestTrace{start: time.Now()}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.set(func() { t.reused = info.Reused })
+		},
+		DNSStart:          func(httptrace.DNSStartInfo) { t.set(func() { t.dnsStart = time.Now() }) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.set(func() { t.dnsDone = time.Now() }) },
+		ConnectStart:      func(string, string) { t.set(func() { setFirst(&t.connectStart) }) },
+		ConnectDone:       func(string, string, error) { t.set(func() { t.connectDone = time.Now() }) },
+		TLSHandshakeStart: func() { t.set(func() { t.tlsStart = time.Now() }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.set(func() { t.tlsDone = time.Now() })
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(func() { t.wroteRequest = time.Now() }) },
+		GotFirstResponseByte: func() { t.set(func() { t.firstByte = time.Now() }) },
+	})
+	return ctx, func(status int, err error) {
+		t.mu.Lock()
+		timing := RequestTiming{
+			Method:     method,
+			URL:        r.URL,
+			Endpoint:   cacheEndpoint(r.URL),
+			ReusedConn: t.reused,
+			DNS:        since(t.dnsStart, t.dnsDone),
+			Connect:    since(t.connectStart, t.connectDone),
+			TLS:        since(t.tlsStart, t.tlsDone),
+			Wait:       since(t.wroteRequest, t.firstByte),
+			TTFB:       since(t.start, t.firstByte),
+			Total:      time.Since(t.start),
+			Status:     status,
+			Err:        err,
+			Time:       t.start,
+		}
+		t.mu.Unlock()
+		h(ctx, timing)
+	}
+}
+
+func (t *requestTrace) set(f func()) {
+	t.mu.Lock()
+	f()
+	t.mu.Unlock()
+}
+
+// setFirst sets t to now, unless it is set already; several addresses may be dialed for one connection.
+func setFirst(t *time.Time) {
+	if t.IsZero() {
+		*t = time.Now()
+	}
+}
+
+// since returns the time from start to end, or zero if either is unset.
+func since(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
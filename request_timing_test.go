@@ -0,0 +1,105 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func newTimingServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			fmt.Fprint(w, `{"id": "<id@example.com>", "message": "Queued. Thank you."}`)
+			return
+		}
+		fmt.Fprint(w, `{"limit": 50000, "count": 5000}`)
+	}))
+}
+
+func TestTimingHook(t *testing.T) {
+	srv := newTimingServer()
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetClient(&http.Client{Transport: &http.Transport{}})
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	var timings []mailgun.RequestTiming
+	mg.SetTimingHook(func(ctx context.Context, t mailgun.RequestTiming) {
+		timings = append(timings, t)
+	})
+	for i := 0; i < 2; i++ {
+		_, err := mg.GetTagLimits(ctx, testDomain)
+		ensure.Nil(t, err)
+	}
+	ensure.DeepEqual(t, len(timings), 2)
+
+	first, second := timings[0], timings[1]
+	ensure.DeepEqual(t, first.Method, http.MethodGet)
+	ensure.DeepEqual(t, first.Endpoint, "domains")
+	ensure.DeepEqual(t, first.Status, http.StatusOK)
+	ensure.Nil(t, first.Err)
+	ensure.False(t, first.ReusedConn)
+	ensure.True(t, first.Connect > 0)
+	ensure.True(t, first.Wait > 0)
+	ensure.True(t, first.TTFB >= first.Wait)
+	ensure.True(t, first.Total >= first.TTFB)
+
+	// The second request is sent over the connection of the first
+	ensure.True(t, second.ReusedConn)
+	ensure.DeepEqual(t, second.Network(), time.Duration(0))
+
+	// Requests which fail are reported with their error
+	srv.Close()
+	_, err := mg.GetTagLimits(ctx, testDomain)
+	ensure.NotNil(t, err)
+	ensure.DeepEqual(t, len(timings), 3)
+	ensure.NotNil(t, timings[2].Err)
+	ensure.DeepEqual(t, timings[2].Status, 0)
+
+	mg.SetTimingHook(nil)
+	mg.GetTagLimits(ctx, testDomain)
+	ensure.DeepEqual(t, len(timings), 3)
+}
+
+func benchmarkSend(b *testing.B, transport *http.Transport, hook mailgun.TimingHook) {
+	srv := newTimingServer()
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetClient(&http.Client{Transport: transport})
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetTimingHook(hook)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := mg.NewMessage("root@"+testDomain, "Subject", "Text", "joe@example.com")
+		if _, _, err := mg.Send(ctx, m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSend sends over a connection kept alive, as the default transport does.
+func BenchmarkSend(b *testing.B) {
+	benchmarkSend(b, &http.Transport{}, nil)
+}
+
+// BenchmarkSendNoKeepAlive connects for each message, showing what reusing connections saves.
+func BenchmarkSendNoKeepAlive(b *testing.B) {
+	benchmarkSend(b, &http.Transport{DisableKeepAlives: true}, nil)
+}
+
+// BenchmarkSendTimingHook shows the overhead of tracing requests for diagnostics.
+func BenchmarkSendTimingHook(b *testing.B) {
+	benchmarkSend(b, &http.Transport{}, func(ctx context.Context, t mailgun.RequestTiming) {})
+}
@@ -0,0 +1,198 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultResponseCacheTTL is how long responses are cached for the endpoints cached by default.
+const DefaultResponseCacheTTL = time.Minute
+
+// ResponseCacheStore keeps the responses cached by `SetResponseCache()`. Implement it to share
+// cached responses between processes; share a store only between clients of the same account.
+// Implementations must be safe for concurrent use.
+type ResponseCacheStore interface {
+	// Get returns the response cached under key, and whether one was found which has not expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set caches the response under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// ResponseCacheOptions configures the cache set with `SetResponseCache()`.
+type ResponseCacheOptions struct {
+	// Store keeps the cached responses; defaults to a store held in memory by the client.
+	Store ResponseCacheStore
+	// TTL is how long the responses of each endpoint are cached, keyed by the name of the
+	// endpoint, such as "lists", "domains" or "tags". Endpoints missing from it are not cached.
+	// Defaults to caching those three for DefaultResponseCacheTTL.
+	TTL map[string]time.Duration
+}
+
+// SetResponseCache enables caching the responses of GET requests to the endpoints of
+// the options, such as `GetMailingList()`, `GetDomain()` and `ListTags()`, which cuts the
+// requests made by dashboards which poll the same resources. Any other request the client
+// makes to an endpoint, such as `UpdateMailingList()`, invalidates what is cached for it, but
+// changes made by other clients are only seen once the cached response expires. Pass nil to
+// disable the cache, which is disabled by default.
+//
+//  mg.SetResponseCache(&mailgun.ResponseCacheOptions{
+//    TTL: map[string]time.Duration{"lists": 30 * time.Second, "domains": 5 * time.Minute},
+//  })
+func (mg *MailgunImpl) SetResponseCache(opts *ResponseCacheOptions) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.cache = nil
+	if opts == nil {
+		return
+	}
+	c := &responseCache{store: opts.Store, ttl: opts.TTL, generations: make(map[string]int)}
+	if c.store == nil {
+		store := NewMemoryResponseCacheStore()
+		store.clock = mg.clock()
+		c.store = store
+	}
+	if c.ttl == nil {
+		c.ttl = map[string]time.Duration{
+			listsEndpoint:   DefaultResponseCacheTTL,
+			domainsEndpoint: DefaultResponseCacheTTL,
+			tagsEndpoint:    DefaultResponseCacheTTL,
+		}
+	}
+	mg.cache = c
+}
+
+// responseCache caches responses in a store. Each endpoint has a generation, which is part of
+// the keys of its responses, so that bumping it invalidates them without touching the store.
+type responseCache struct {
+	store ResponseCacheStore
+	ttl   map[string]time.Duration
+
+	mu          sync.Mutex
+	generations map[string]int
+}
+
+// key returns the key the response to the GET request for address is cached under, and the TTL
+// of its endpoint; ok is false if the endpoint is not cached.
+func (c *responseCache) key(address string) (key string, ttl time.Duration, ok bool) {
+	endpoint := cacheEndpoint(address)
+	if ttl, ok = c.ttl[endpoint]; !ok || ttl <= 0 {
+		return "", 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("%s#%d %s", endpoint, c.generations[endpoint], address), ttl, true
+}
+
+// invalidate drops what is cached for the endpoint of address.
+func (c *responseCache) invalidate(address string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generations[cacheEndpoint(address)]++
+}
+
+// cacheEndpoint returns the name of the endpoint of an API url, such as "lists" for
+// https://api.mailgun.net/v3/lists/list@example.com, or "tags" for
+// https://api.mailgun.net/v3/example.com/tags.
+func cacheEndpoint(address string) string {
+	u, err := url.Parse(address)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, p := range parts {
+		if !apiVersionSuffix.MatchString("/" + p) {
+			continue
+		}
+		parts = parts[i+1:]
+		if len(parts) > 1 && strings.Contains(parts[0], ".") {
+			// The path starts with a domain
+			return parts[1]
+		}
+		return parts[0]
+	}
+	return ""
+}
+
+// getCachedJSON works as getResponseFromJSON, answering from the cache if it can.
+func getCachedJSON(ctx context.Context, r *httpRequest, v interface{}) (bool, error) {
+	c := r.options.cache
+	if c == nil {
+		return false, nil
+	}
+	address, err := r.generateUrlWithParameters()
+	if err != nil {
+		return false, nil
+	}
+	key, ttl, ok := c.key(address)
+	if !ok {
+		return false, nil
+	}
+
+	// A failing store is skipped, rather than failing the request
+	if data, found, err := c.store.Get(ctx, key); err == nil && found {
+		return true, (&httpResponse{Data: data, codec: r.options.codec}).parseFromJSON(v)
+	}
+	response, err := r.makeRequest(ctx, "GET", nil)
+	if err != nil {
+		return true, err
+	}
+	if notGood(response.Code, expected) {
+		return true, newError(r.URL, expected, response)
+	}
+	if err := response.parseFromJSON(v); err != nil {
+		return true, err
+	}
+	c.store.Set(ctx, key, response.Data, ttl)
+	return true, nil
+}
+
+// MemoryResponseCacheStore is a ResponseCacheStore held in memory.
+type MemoryResponseCacheStore struct {
+	clock   clock
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+	swept   time.Time
+}
+
+type memoryCacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryResponseCacheStore returns an empty MemoryResponseCacheStore.
+func NewMemoryResponseCacheStore() *MemoryResponseCacheStore {
+	return &MemoryResponseCacheStore{clock: systemClock{}, entries: make(map[string]memoryCacheEntry)}
+}
+
+// Get implements ResponseCacheStore.
+func (s *MemoryResponseCacheStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || s.clock.Now().After(e.expires) {
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+// Set implements ResponseCacheStore.
+func (s *MemoryResponseCacheStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	if now.Sub(s.swept) > ttl {
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.swept = now
+	}
+	s.entries[key] = memoryCacheEntry{value: value, expires: now.Add(ttl)}
+	return nil
+}
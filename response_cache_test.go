@@ -0,0 +1,82 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestResponseCache(t *testing.T) {
+	requests := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.Method+" "+r.URL.Path]++
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v3/lists/"):
+			fmt.Fprintf(w, `{"member": {"address": "list@example.com", "name": "v%d"}}`, requests["PUT /v3/lists/list@example.com"])
+		case strings.HasPrefix(r.URL.Path, "/v3/domains/"):
+			fmt.Fprint(w, `{"domain": {"name": "example.com"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "not found"}`)
+		}
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetResponseCache(&mailgun.ResponseCacheOptions{TTL: map[string]time.Duration{"lists": time.Minute, "routes": time.Minute}})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		list, err := mg.GetMailingList(ctx, "list@example.com")
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, list.Name, "v0")
+	}
+	ensure.DeepEqual(t, requests["GET /v3/lists/list@example.com"], 1)
+
+	// Changes made through the client invalidate the cache of the endpoint
+	_, err := mg.UpdateMailingList(ctx, "list@example.com", mailgun.MailingList{Name: "v1"})
+	ensure.Nil(t, err)
+	list, err := mg.GetMailingList(ctx, "list@example.com")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, list.Name, "v1")
+	ensure.DeepEqual(t, requests["GET /v3/lists/list@example.com"], 2)
+
+	// Endpoints without a TTL, and errors, are not cached
+	for i := 0; i < 2; i++ {
+		_, err = mg.GetDomain(ctx, "example.com")
+		ensure.Nil(t, err)
+		_, err = mg.GetRoute(ctx, "missing")
+		ensure.NotNil(t, err)
+	}
+	ensure.DeepEqual(t, requests["GET /v3/domains/example.com"], 2)
+	ensure.DeepEqual(t, requests["GET /v3/routes/missing"], 2)
+
+	mg.SetResponseCache(nil)
+	_, err = mg.GetMailingList(ctx, "list@example.com")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, requests["GET /v3/lists/list@example.com"], 3)
+}
+
+func TestMemoryResponseCacheStore(t *testing.T) {
+	s := mailgun.NewMemoryResponseCacheStore()
+	ctx := context.Background()
+
+	ensure.Nil(t, s.Set(ctx, "a", []byte("1"), time.Minute))
+	ensure.Nil(t, s.Set(ctx, "b", []byte("2"), time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	v, ok, err := s.Get(ctx, "a")
+	ensure.Nil(t, err)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, v, []byte("1"))
+	_, ok, _ = s.Get(ctx, "b")
+	ensure.False(t, ok)
+}
@@ -3,6 +3,8 @@ package mailgun
 import (
 	"context"
 	"fmt"
+
+	"github.com/pkg/errors"
 )
 
 // The MailgunGoUserAgent identifies the client to the server, for logging purposes.
@@ -18,6 +20,8 @@ type UnexpectedResponseError struct {
 	Actual   int
 	URL      string
 	Data     []byte
+	// limit is the *PlanLimitError or *QuotaExceededError the response is, if any
+	limit error
 }
 
 // String() converts the error into a human-readable, logfmt-compliant string.
@@ -31,14 +35,15 @@ func (e *UnexpectedResponseError) Error() string {
 	return e.String()
 }
 
-// newError creates a new error condition to be returned.
+// newError creates a new error condition to be returned. Errors caused by the plan or quota of the
+// account hold a *PlanLimitError or *QuotaExceededError, found with errors.As.
 func newError(url string, expected []int, got *httpResponse) error {
-	return &UnexpectedResponseError{
+	return planLimitError(&UnexpectedResponseError{
 		URL:      url,
 		Expected: expected,
 		Actual:   got.Code,
 		Data:     got.Data,
-	}
+	})
 }
 
 // notGood searches a list of response codes (the haystack) for a matching entry (the needle).
@@ -71,42 +76,48 @@ func makeRequest(ctx context.Context, r *httpRequest, method string, p payload)
 // See simplehttp.GetResponseFromJSON for more details.
 func getResponseFromJSON(ctx context.Context, r *httpRequest, v interface{}) error {
 	r.addHeader("User-Agent", MailgunGoUserAgent)
-	response, err := r.makeGetRequest(ctx)
+	if cached, err := getCachedJSON(ctx, r, v); cached {
+		return err
+	}
+	if revalidated, err := getRevalidatedJSON(ctx, r, v); revalidated {
+		return err
+	}
+	response, err := r.makeJSONRequest(ctx, "GET", nil, expected, v)
 	if err != nil {
 		return err
 	}
 	if notGood(response.Code, expected) {
 		return newError(r.URL, expected, response)
 	}
-	return response.parseFromJSON(v)
+	return nil
 }
 
 // postResponseFromJSON shim performs a POST request, checking for a positive outcome.
 // See simplehttp.PostResponseFromJSON for more details.
 func postResponseFromJSON(ctx context.Context, r *httpRequest, p payload, v interface{}) error {
 	r.addHeader("User-Agent", MailgunGoUserAgent)
-	response, err := r.makePostRequest(ctx, p)
+	response, err := r.makeJSONRequest(ctx, "POST", p, expected, v)
 	if err != nil {
 		return err
 	}
 	if notGood(response.Code, expected) {
 		return newError(r.URL, expected, response)
 	}
-	return response.parseFromJSON(v)
+	return nil
 }
 
 // putResponseFromJSON shim performs a PUT request, checking for a positive outcome.
 // See simplehttp.PutResponseFromJSON for more details.
 func putResponseFromJSON(ctx context.Context, r *httpRequest, p payload, v interface{}) error {
 	r.addHeader("User-Agent", MailgunGoUserAgent)
-	response, err := r.makePutRequest(ctx, p)
+	response, err := r.makeJSONRequest(ctx, "PUT", p, expected, v)
 	if err != nil {
 		return err
 	}
 	if notGood(response.Code, expected) {
 		return newError(r.URL, expected, response)
 	}
-	return response.parseFromJSON(v)
+	return nil
 }
 
 // makeGetRequest shim performs a GET request, checking for a positive outcome.
@@ -153,11 +164,15 @@ func makeDeleteRequest(ctx context.Context, r *httpRequest) (*httpResponse, erro
 	return rsp, err
 }
 
-// Extract the http status code from error object
+// Extract the http status code from error object, looking through errors wrapped with github.com/pkg/errors
 func GetStatusFromErr(err error) int {
-	obj, ok := err.(*UnexpectedResponseError)
-	if !ok {
-		return -1
+	switch obj := errors.Cause(err).(type) {
+	case *UnexpectedResponseError:
+		return obj.Actual
+	case *PlanLimitError:
+		return obj.Actual
+	case *QuotaExceededError:
+		return obj.Actual
 	}
-	return obj.Actual
+	return -1
 }
@@ -2,7 +2,14 @@ package mailgun
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
 )
 
 // A Route structure contains information on a configured or to-be-configured route.
@@ -41,18 +48,10 @@ type createRouteResp struct {
 
 // ListRoutes allows you to iterate through a list of routes returned by the API
 func (mg *MailgunImpl) ListRoutes(opts *ListOptions) *RoutesIterator {
-	var limit int
-	if opts != nil {
-		limit = opts.Limit
-	}
-
-	if limit == 0 {
-		limit = 100
-	}
-
+	limit, params := offsetPageOptions(opts)
 	return &RoutesIterator{
 		mg:                 mg,
-		url:                generatePublicApiUrl(mg, routesEndpoint),
+		url:                withParameters(generatePublicApiUrl(mg, routesEndpoint), params),
 		routesListResponse: routesListResponse{TotalCount: -1},
 		limit:              limit,
 	}
@@ -178,9 +177,7 @@ func (ri *RoutesIterator) Previous(ctx context.Context, items *[]Route) bool {
 }
 
 func (ri *RoutesIterator) fetch(ctx context.Context, skip, limit int) error {
-	r := newHTTPRequest(ri.url)
-	r.setBasicAuth(basicAuthUser, ri.mg.APIKey())
-	r.setClient(ri.mg.Client())
+	r := newAPIRequest(ri.mg, ri.url)
 
 	if skip != 0 {
 		r.addParameter("skip", strconv.Itoa(skip))
@@ -197,10 +194,8 @@ func (ri *RoutesIterator) fetch(ctx context.Context, skip, limit int) error {
 // only a subset of the fields influence the operation.
 // See the Route structure definition for more details.
 func (mg *MailgunImpl) CreateRoute(ctx context.Context, prototype Route) (_ignored Route, err error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, routesEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	p := newUrlEncodedPayload()
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, routesEndpoint))
+	p := newPayload()
 	p.addValue("priority", strconv.Itoa(prototype.Priority))
 	p.addValue("description", prototype.Description)
 	p.addValue("expression", prototype.Expression)
@@ -218,18 +213,14 @@ func (mg *MailgunImpl) CreateRoute(ctx context.Context, prototype Route) (_ignor
 // To avoid ambiguity, Mailgun identifies the route by unique ID.
 // See the Route structure definition and the Mailgun API documentation for more details.
 func (mg *MailgunImpl) DeleteRoute(ctx context.Context, id string) error {
-	r := newHTTPRequest(generatePublicApiUrl(mg, routesEndpoint) + "/" + id)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, routesEndpoint)+"/"+pathEscape(id))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
 
 // GetRoute retrieves the complete route definition associated with the unique route ID.
 func (mg *MailgunImpl) GetRoute(ctx context.Context, id string) (Route, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, routesEndpoint) + "/" + id)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, routesEndpoint)+"/"+pathEscape(id))
 	var envelope struct {
 		Message string `json:"message"`
 		*Route  `json:"route"`
@@ -246,10 +237,8 @@ func (mg *MailgunImpl) GetRoute(ctx context.Context, id string) (Route, error) {
 // Only those route fields which are non-zero or non-empty are updated.
 // All other fields remain as-is.
 func (mg *MailgunImpl) UpdateRoute(ctx context.Context, id string, route Route) (Route, error) {
-	r := newHTTPRequest(generatePublicApiUrl(mg, routesEndpoint) + "/" + id)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	p := newUrlEncodedPayload()
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, routesEndpoint)+"/"+pathEscape(id))
+	p := newPayload()
 	if route.Priority != 0 {
 		p.addValue("priority", strconv.Itoa(route.Priority))
 	}
@@ -270,3 +259,194 @@ func (mg *MailgunImpl) UpdateRoute(ctx context.Context, id string, route Route)
 	err := putResponseFromJSON(ctx, r, p, &envelope)
 	return envelope, err
 }
+
+// ReorderRoutes sets the priorities of the routes so Mailgun consults them in the order of ids,
+// giving the first route priority 0, the next priority 1 and so on. Routes already at their new
+// priority are not updated, and routes not in ids are left as they are. All ids are checked before
+// any route is updated; if an update fails, the routes before it keep their new priorities.
+func (mg *MailgunImpl) ReorderRoutes(ctx context.Context, ids []string) error {
+	routes, err := mg.ListRoutesAll(ctx, nil)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]int, len(routes))
+	for _, route := range routes {
+		current[route.Id] = route.Priority
+	}
+
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := current[id]; !ok {
+			return fmt.Errorf("route '%s' not found", id)
+		}
+		if seen[id] {
+			return fmt.Errorf("route '%s' is listed more than once", id)
+		}
+		seen[id] = true
+	}
+
+	for priority, id := range ids {
+		if current[id] == priority {
+			continue
+		}
+		if err := mg.setRoutePriority(ctx, id, priority); err != nil {
+			return errors.Wrapf(err, "while updating the priority of route '%s'", id)
+		}
+	}
+	return nil
+}
+
+// setRoutePriority updates the priority of a route; unlike UpdateRoute() it can set priority 0.
+func (mg *MailgunImpl) setRoutePriority(ctx context.Context, id string, priority int) error {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, routesEndpoint)+"/"+pathEscape(id))
+	p := newPayload()
+	p.addValue("priority", strconv.Itoa(priority))
+	_, err := makePutRequest(ctx, r, p)
+	return err
+}
+
+// MatchRouteExpression reports whether a message sent to recipient would match the route expression,
+// so route changes can be validated in CI before they are deployed. The expression is evaluated
+// locally; no request is made to Mailgun. The filters match_recipient(), match_header() and catch_all()
+// are supported, and may be combined with `and`. As with Mailgun, patterns must match from the start
+// of the value, so use a leading `.*` to match anywhere.
+//
+//  ok, err := mailgun.MatchRouteExpression(`match_recipient(".*@support.example.com")`, "bob@support.example.com")
+func MatchRouteExpression(expression, recipient string) (bool, error) {
+	return MatchRouteExpressionHeaders(expression, recipient, nil)
+}
+
+// MatchRouteExpressionHeaders is like MatchRouteExpression, but also evaluates match_header()
+// filters against the given message headers. Header names are case-insensitive.
+func MatchRouteExpressionHeaders(expression, recipient string, headers http.Header) (bool, error) {
+	filters, err := parseRouteExpression(expression)
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range filters {
+		var value string
+		switch f.name {
+		case "catch_all":
+			continue
+		case "match_recipient":
+			value = recipient
+		case "match_header":
+			value = headers.Get(f.args[0])
+		}
+		if !f.pattern.MatchString(value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+type routeFilter struct {
+	name    string
+	args    []string
+	pattern *regexp.Regexp
+}
+
+// parseRouteExpression splits expression into its filters and compiles their patterns.
+func parseRouteExpression(expression string) ([]routeFilter, error) {
+	var filters []routeFilter
+	rest := strings.TrimSpace(expression)
+	if rest == "" {
+		return nil, fmt.Errorf("route expression is empty")
+	}
+
+	for {
+		f, remain, err := parseRouteFilter(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route expression '%s': %s", expression, err)
+		}
+		filters = append(filters, f)
+
+		rest = strings.TrimSpace(remain)
+		if rest == "" {
+			return filters, nil
+		}
+		if !strings.HasPrefix(rest, "and") || len(rest) == 3 || !unicode.IsSpace(rune(rest[3])) {
+			return nil, fmt.Errorf("invalid route expression '%s': expected 'and' before '%s'", expression, rest)
+		}
+		rest = strings.TrimSpace(rest[3:])
+	}
+}
+
+// parseRouteFilter parses a single filter such as match_header("subject", ".*help") from the start of s,
+// returning the filter and the remainder of s.
+func parseRouteFilter(s string) (routeFilter, string, error) {
+	var f routeFilter
+	open := strings.IndexByte(s, '(')
+	if open == -1 {
+		return f, "", fmt.Errorf("expected '(' after '%s'", s)
+	}
+	f.name = strings.TrimSpace(s[:open])
+	s = s[open+1:]
+
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if strings.HasPrefix(s, ")") {
+			s = s[1:]
+			break
+		}
+		if len(f.args) != 0 {
+			if !strings.HasPrefix(s, ",") {
+				return f, "", fmt.Errorf("expected ',' or ')' in %s()", f.name)
+			}
+			s = strings.TrimLeftFunc(s[1:], unicode.IsSpace)
+		}
+		arg, remain, err := parseRouteString(s)
+		if err != nil {
+			return f, "", fmt.Errorf("in %s(): %s", f.name, err)
+		}
+		f.args = append(f.args, arg)
+		s = remain
+	}
+
+	var want int
+	switch f.name {
+	case "catch_all":
+		want = 0
+	case "match_recipient":
+		want = 1
+	case "match_header":
+		want = 2
+	default:
+		return f, "", fmt.Errorf("unsupported filter %s()", f.name)
+	}
+	if len(f.args) != want {
+		return f, "", fmt.Errorf("%s() takes %d arguments; got %d", f.name, want, len(f.args))
+	}
+
+	if want != 0 {
+		var err error
+		if f.pattern, err = regexp.Compile("^(?:" + f.args[want-1] + ")"); err != nil {
+			return f, "", fmt.Errorf("in %s(): %s", f.name, err)
+		}
+	}
+	return f, s, nil
+}
+
+// parseRouteString parses a single or double quoted string from the start of s, returning its
+// contents and the remainder of s. A backslash escapes the quote character; all other backslashes
+// are kept so regular expression escapes pass through unchanged.
+func parseRouteString(s string) (string, string, error) {
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return "", "", fmt.Errorf("expected a quoted string")
+	}
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case quote:
+			return b.String(), s[i+1:], nil
+		case '\\':
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
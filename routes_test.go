@@ -2,6 +2,7 @@ package mailgun_test
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/facebookgo/ensure"
@@ -56,6 +57,38 @@ func TestRouteCRUD(t *testing.T) {
 	ensure.DeepEqual(t, len(changedRoute.Actions), 2)
 }
 
+func TestReorderRoutes(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	var ids []string
+	for _, priority := range []int{5, 0, 1} {
+		route, err := mg.CreateRoute(ctx, mailgun.Route{
+			Priority:    priority,
+			Description: "Reordered Route",
+			Expression:  `match_recipient(".*@samples.mailgun.org")`,
+			Actions:     []string{"stop()"},
+		})
+		ensure.Nil(t, err)
+		ids = append(ids, route.Id)
+		defer mg.DeleteRoute(ctx, route.Id)
+	}
+
+	ensure.Nil(t, mg.ReorderRoutes(ctx, ids))
+	for priority, id := range ids {
+		route, err := mg.GetRoute(ctx, id)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, route.Priority, priority)
+	}
+
+	err := mg.ReorderRoutes(ctx, []string{ids[0], "ID-missing"})
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "ID-missing")
+	err = mg.ReorderRoutes(ctx, []string{ids[0], ids[0]})
+	ensure.NotNil(t, err)
+}
+
 func TestRoutesIterator(t *testing.T) {
 	mg := mailgun.NewMailgun(testDomain, testKey)
 	mg.SetAPIBase(server.URL())
@@ -105,3 +138,43 @@ func TestRoutesIterator(t *testing.T) {
 	ensure.True(t, len(firstPage) != 0)
 
 }
+
+func TestMatchRouteExpression(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Subject", "Need help with billing")
+
+	for _, tt := range []struct {
+		expression string
+		recipient  string
+		match      bool
+	}{
+		{`match_recipient(".*@samples.mailgun.org")`, "bob@samples.mailgun.org", true},
+		{`match_recipient('.*@samples.mailgun.org')`, "bob@example.com", false},
+		{`match_recipient("bob@example.com")`, "alice+bob@example.com", false},
+		{`match_recipient("^support-(?P<team>.+)@example\.com$")`, "support-eu@example.com", true},
+		{`match_header("subject", ".*help")`, "bob@example.com", true},
+		{`match_header('X-Mailgun-Sflag', 'Yes')`, "bob@example.com", false},
+		{`match_recipient(".*@example.com") and match_header("Subject", "Need")`, "bob@example.com", true},
+		{`match_recipient(".*@example.com") and match_header("Subject", "billing")`, "bob@example.com", false},
+		{`catch_all()`, "anyone@anywhere.com", true},
+	} {
+		t.Run(tt.expression, func(t *testing.T) {
+			match, err := mailgun.MatchRouteExpressionHeaders(tt.expression, tt.recipient, headers)
+			ensure.Nil(t, err)
+			ensure.DeepEqual(t, match, tt.match)
+		})
+	}
+
+	for _, expression := range []string{
+		``,
+		`match_recipient(".*@example.com"`,
+		`match_recipient(.*@example.com)`,
+		`match_recipient("[")`,
+		`match_header("subject")`,
+		`match_sender("bob@example.com")`,
+		`catch_all() or catch_all()`,
+	} {
+		_, err := mailgun.MatchRouteExpression(expression, "bob@example.com")
+		ensure.NotNil(t, err)
+	}
+}
@@ -0,0 +1,432 @@
+package mailgun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxDeliveryTimeAhead is the furthest in the future Mailgun accepts an o:deliverytime.
+	MaxDeliveryTimeAhead = 3 * 24 * time.Hour
+	// DefaultScheduleLeadTime is how long before its delivery time a SendScheduler submits a message.
+	DefaultScheduleLeadTime = time.Hour
+	// DefaultSchedulePollInterval is how often SendScheduler.Run() checks the store for due messages.
+	DefaultSchedulePollInterval = time.Minute
+	// DefaultScheduleMaxAttempts is how many times a SendScheduler tries to submit a message.
+	DefaultScheduleMaxAttempts = 10
+)
+
+// ErrAlreadyScheduled is returned when a message is scheduled with the ID of a message already in the store.
+var ErrAlreadyScheduled = errors.New("a message with this id is already scheduled")
+
+// A ScheduledMessage is a message waiting in a ScheduleStore to be submitted by a SendScheduler.
+// Unlike a Message it holds only plain data, so it can be persisted; attachments are not supported.
+type ScheduledMessage struct {
+	// ID uniquely identifies the message in the store, so that scheduling it twice has no effect.
+	ID string `json:"id"`
+	// DeliverAt is when the message should be delivered. It may be any time in the future.
+	DeliverAt time.Time `json:"deliver_at"`
+
+	From      string            `json:"from"`
+	To        []string          `json:"to"`
+	Cc        []string          `json:"cc,omitempty"`
+	Bcc       []string          `json:"bcc,omitempty"`
+	Subject   string            `json:"subject,omitempty"`
+	Text      string            `json:"text,omitempty"`
+	HTML      string            `json:"html,omitempty"`
+	Template  string            `json:"template,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+
+	// Attempts is the number of failed attempts to submit the message.
+	Attempts int `json:"attempts"`
+	// NextAttempt is when the scheduler next tries to submit the message.
+	NextAttempt time.Time `json:"next_attempt"`
+	// LastError describes why the last attempt failed.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// ScheduleStore persists the messages of a SendScheduler until they are submitted.
+// MemoryScheduleStore and FileScheduleStore are provided; implement the interface to use any other storage.
+// Implementations must be safe for concurrent use.
+type ScheduleStore interface {
+	// Add stores a new message, or returns ErrAlreadyScheduled if a message with the same ID is stored.
+	Add(ctx context.Context, m ScheduledMessage) error
+	// Update replaces the stored message with the same ID.
+	Update(ctx context.Context, m ScheduledMessage) error
+	// Remove deletes the message with the ID, if any.
+	Remove(ctx context.Context, id string) error
+	// Due returns up to limit messages whose NextAttempt is not after now, earliest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]ScheduledMessage, error)
+}
+
+// SendScheduler accepts messages to be delivered at any time in the future, beyond the 3 day limit
+// of o:deliverytime, and keeps them in a ScheduleStore. Once a message is within the lead time of its
+// delivery time, the scheduler submits it to Mailgun with o:deliverytime set, so Mailgun delivers
+// it on time. Submissions which fail are retried with backoff; messages Mailgun rejects, or which
+// fail DefaultScheduleMaxAttempts times, are removed and passed to the failure handler.
+//
+//  store, err := mailgun.NewFileScheduleStore("/var/lib/myapp/schedule.json")
+//  if err != nil {
+//    log.Fatal(err)
+//  }
+//  s := mailgun.NewSendScheduler(mg, store)
+//  go s.Run(ctx)
+//
+//  err = s.Schedule(ctx, mailgun.ScheduledMessage{
+//    ID:        "renewal-reminder-42",
+//    DeliverAt: renewal.Add(-7 * 24 * time.Hour),
+//    From:      "billing@example.com",
+//    To:        []string{"bob@example.com"},
+//    Subject:   "Your subscription renews next week",
+//    Text:      "...",
+//  })
+//
+// A message could be sent twice if the process stops after Mailgun accepts it but before it is removed
+// from the store; the scheduler adds the `scheduled-id` variable to every message so duplicates can be detected.
+type SendScheduler struct {
+	mg    Mailgun
+	store ScheduleStore
+	clock clock
+
+	leadTime     time.Duration
+	pollInterval time.Duration
+	maxAttempts  int
+	onFailure    func(ctx context.Context, m ScheduledMessage, err error)
+}
+
+// NewSendScheduler returns a scheduler which submits messages kept in store using mg.
+func NewSendScheduler(mg Mailgun, store ScheduleStore) *SendScheduler {
+	return &SendScheduler{
+		mg:           mg,
+		clock:        clockFor(mg),
+		store:        store,
+		leadTime:     DefaultScheduleLeadTime,
+		pollInterval: DefaultSchedulePollInterval,
+		maxAttempts:  DefaultScheduleMaxAttempts,
+	}
+}
+
+// SetLeadTime sets how long before its delivery time a message is submitted. It must not exceed MaxDeliveryTimeAhead.
+func (s *SendScheduler) SetLeadTime(d time.Duration) {
+	s.leadTime = d
+}
+
+// SetPollInterval sets how often Run() checks the store for due messages.
+func (s *SendScheduler) SetPollInterval(d time.Duration) {
+	s.pollInterval = d
+}
+
+// SetMaxAttempts sets how many times a message is submitted before the scheduler gives up on it.
+func (s *SendScheduler) SetMaxAttempts(n int) {
+	s.maxAttempts = n
+}
+
+// SetFailureHandler sets a function called with each message the scheduler gives up on, and the last error.
+func (s *SendScheduler) SetFailureHandler(h func(ctx context.Context, m ScheduledMessage, err error)) {
+	s.onFailure = h
+}
+
+// Schedule adds the message to the store. It returns ErrAlreadyScheduled if a message with the same ID
+// is already scheduled, so it is safe to call again with the same message.
+func (s *SendScheduler) Schedule(ctx context.Context, m ScheduledMessage) error {
+	if m.ID == "" {
+		return fmt.Errorf("scheduled message must have an id")
+	}
+	if m.DeliverAt.IsZero() {
+		return fmt.Errorf("scheduled message '%s' must have a delivery time", m.ID)
+	}
+	if len(m.To) == 0 {
+		return fmt.Errorf("scheduled message '%s' must have at least one recipient", m.ID)
+	}
+	m.Attempts = 0
+	m.LastError = ""
+	m.NextAttempt = m.DeliverAt.Add(-s.leadTime)
+	return s.store.Add(ctx, m)
+}
+
+// Cancel removes a scheduled message which has not yet been submitted.
+func (s *SendScheduler) Cancel(ctx context.Context, id string) error {
+	return s.store.Remove(ctx, id)
+}
+
+// Run submits due messages every poll interval until the context is cancelled.
+func (s *SendScheduler) Run(ctx context.Context) error {
+	for {
+		// A failing store is retried on the next poll
+		_, _ = s.SendDue(ctx)
+		if err := s.clock.Sleep(ctx, s.pollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// SendDue submits every message which is due, and returns the number submitted.
+// An error is returned only if the store fails.
+func (s *SendScheduler) SendDue(ctx context.Context) (int, error) {
+	var sent int
+	for {
+		due, err := s.store.Due(ctx, s.clock.Now(), 100)
+		if err != nil || len(due) == 0 {
+			return sent, err
+		}
+		for _, m := range due {
+			ok, err := s.submit(ctx, m)
+			if err != nil {
+				return sent, err
+			}
+			if ok {
+				sent++
+			}
+		}
+	}
+}
+
+// submit sends m and updates the store with the outcome. It reports whether m was sent.
+func (s *SendScheduler) submit(ctx context.Context, m ScheduledMessage) (bool, error) {
+	msg, err := s.newMessage(m)
+	if err == nil {
+		_, _, err = s.mg.Send(ctx, msg)
+	}
+	if err == nil {
+		return true, s.store.Remove(ctx, m.ID)
+	}
+
+	m.Attempts++
+	m.LastError = err.Error()
+	if isPermanentSendError(err) || m.Attempts >= s.maxAttempts {
+		if rmErr := s.store.Remove(ctx, m.ID); rmErr != nil {
+			return false, rmErr
+		}
+		if s.onFailure != nil {
+			s.onFailure(ctx, m, err)
+		}
+		return false, nil
+	}
+
+	backoff := time.Minute << uint(m.Attempts-1)
+	if backoff > time.Hour || backoff <= 0 {
+		backoff = time.Hour
+	}
+	m.NextAttempt = s.clock.Now().Add(backoff)
+	return false, s.store.Update(ctx, m)
+}
+
+func (s *SendScheduler) newMessage(m ScheduledMessage) (*Message, error) {
+	msg := s.mg.NewMessage(m.From, m.Subject, m.Text, m.To...)
+	for _, cc := range m.Cc {
+		msg.AddCC(cc)
+	}
+	for _, bcc := range m.Bcc {
+		msg.AddBCC(bcc)
+	}
+	if m.HTML != "" {
+		msg.SetHtml(m.HTML)
+	}
+	if m.Template != "" {
+		msg.SetTemplate(m.Template)
+	}
+	if len(m.Tags) != 0 {
+		if err := msg.AddTag(m.Tags...); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range m.Headers {
+		msg.AddHeader(k, v)
+	}
+	for k, v := range m.Variables {
+		if err := msg.AddVariable(k, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := msg.AddVariable("scheduled-id", m.ID); err != nil {
+		return nil, err
+	}
+	if m.DeliverAt.After(s.clock.Now()) {
+		msg.SetDeliveryTime(m.DeliverAt)
+	}
+	return msg, nil
+}
+
+// isPermanentSendError reports whether retrying the send cannot succeed.
+func isPermanentSendError(err error) bool {
+	if errors.Is(err, ErrInvalidMessage) {
+		return true
+	}
+	status := GetStatusFromErr(err)
+	return status >= 400 && status < 500 && status != http.StatusTooManyRequests
+}
+
+// MemoryScheduleStore is a ScheduleStore held in memory. Scheduled messages are lost when the
+// process exits; use FileScheduleStore to keep them.
+type MemoryScheduleStore struct {
+	mu       sync.Mutex
+	messages map[string]ScheduledMessage
+}
+
+// NewMemoryScheduleStore returns an empty MemoryScheduleStore.
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{messages: make(map[string]ScheduledMessage)}
+}
+
+// Add implements ScheduleStore.
+func (s *MemoryScheduleStore) Add(ctx context.Context, m ScheduledMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.messages[m.ID]; ok {
+		return ErrAlreadyScheduled
+	}
+	s.messages[m.ID] = m
+	return nil
+}
+
+// Update implements ScheduleStore.
+func (s *MemoryScheduleStore) Update(ctx context.Context, m ScheduledMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[m.ID] = m
+	return nil
+}
+
+// Remove implements ScheduleStore.
+func (s *MemoryScheduleStore) Remove(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.messages, id)
+	return nil
+}
+
+// Due implements ScheduleStore.
+func (s *MemoryScheduleStore) Due(ctx context.Context, now time.Time, limit int) ([]ScheduledMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []ScheduledMessage
+	for _, m := range s.messages {
+		if !m.NextAttempt.After(now) {
+			due = append(due, m)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttempt.Before(due[j].NextAttempt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// FileScheduleStore is a ScheduleStore kept in a JSON file on local disk. The file is rewritten
+// atomically after every change, so it is suited to modest numbers of scheduled messages.
+type FileScheduleStore struct {
+	path string
+	mem  *MemoryScheduleStore
+	// mu serializes changes with the write of the file which follows them
+	mu sync.Mutex
+}
+
+// NewFileScheduleStore returns a store kept in the file at path, loading any messages already in it.
+func NewFileScheduleStore(path string) (*FileScheduleStore, error) {
+	s := &FileScheduleStore{path: path, mem: NewMemoryScheduleStore()}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []ScheduledMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("while reading schedule '%s': %s", path, err)
+	}
+	for _, m := range messages {
+		s.mem.messages[m.ID] = m
+	}
+	return s, nil
+}
+
+// Add implements ScheduleStore.
+func (s *FileScheduleStore) Add(ctx context.Context, m ScheduledMessage) error {
+	return s.change(func(next *MemoryScheduleStore) error { return next.Add(ctx, m) })
+}
+
+// Update implements ScheduleStore.
+func (s *FileScheduleStore) Update(ctx context.Context, m ScheduledMessage) error {
+	return s.change(func(next *MemoryScheduleStore) error { return next.Update(ctx, m) })
+}
+
+// Remove implements ScheduleStore.
+func (s *FileScheduleStore) Remove(ctx context.Context, id string) error {
+	return s.change(func(next *MemoryScheduleStore) error { return next.Remove(ctx, id) })
+}
+
+// Due implements ScheduleStore.
+func (s *FileScheduleStore) Due(ctx context.Context, now time.Time, limit int) ([]ScheduledMessage, error) {
+	return s.mem.Due(ctx, now, limit)
+}
+
+// change applies fn to a copy of the messages and writes the copy to the file, keeping it only
+// once it is written, so a change which cannot be written leaves the store as it was.
+func (s *FileScheduleStore) change(fn func(next *MemoryScheduleStore) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := NewMemoryScheduleStore()
+	s.mem.mu.Lock()
+	for id, m := range s.mem.messages {
+		next.messages[id] = m
+	}
+	s.mem.mu.Unlock()
+	if err := fn(next); err != nil {
+		return err
+	}
+	if err := s.write(next.messages); err != nil {
+		return err
+	}
+
+	s.mem.mu.Lock()
+	s.mem.messages = next.messages
+	s.mem.mu.Unlock()
+	return nil
+}
+
+// write replaces the file with messages atomically.
+func (s *FileScheduleStore) write(byID map[string]ScheduledMessage) error {
+	messages := make([]ScheduledMessage, 0, len(byID))
+	for _, m := range byID {
+		messages = append(messages, m)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+
+	data, err := json.MarshalIndent(messages, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
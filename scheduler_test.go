@@ -0,0 +1,123 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestSendScheduler(t *testing.T) {
+	var mu sync.Mutex
+	sent := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ensure.Nil(t, r.ParseMultipartForm(1<<20))
+		switch r.FormValue("to") {
+		case "rejected@example.com":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message": "to parameter is not a valid address"}`)
+			return
+		case "unavailable@example.com":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"message": "try again later"}`)
+			return
+		}
+		mu.Lock()
+		sent[r.FormValue("v:scheduled-id")] = r.FormValue("o:deliverytime")
+		mu.Unlock()
+		fmt.Fprint(w, `{"id": "<id@example.com>", "message": "Queued. Thank you."}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	ctx := context.Background()
+	store := mailgun.NewMemoryScheduleStore()
+	s := mailgun.NewSendScheduler(mg, store)
+
+	var failed []string
+	s.SetFailureHandler(func(ctx context.Context, m mailgun.ScheduledMessage, err error) {
+		failed = append(failed, m.ID)
+	})
+
+	soon := time.Now().Add(30 * time.Minute).Truncate(time.Second)
+	schedule := func(id, to string, at time.Time) {
+		ensure.Nil(t, s.Schedule(ctx, mailgun.ScheduledMessage{
+			ID:        id,
+			DeliverAt: at,
+			From:      "sender@example.com",
+			To:        []string{to},
+			Subject:   "Reminder",
+			Text:      "Your subscription renews next week",
+		}))
+	}
+	schedule("soon", "bob@example.com", soon)
+	schedule("next-month", "bob@example.com", time.Now().Add(30*24*time.Hour))
+	schedule("rejected", "rejected@example.com", soon)
+	schedule("unavailable", "unavailable@example.com", soon)
+
+	// Scheduling the same message again has no effect
+	err := s.Schedule(ctx, mailgun.ScheduledMessage{ID: "soon", DeliverAt: soon, To: []string{"bob@example.com"}})
+	ensure.DeepEqual(t, err, mailgun.ErrAlreadyScheduled)
+
+	count, err := s.SendDue(ctx)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, count, 1)
+	ensure.DeepEqual(t, sent, map[string]string{"soon": soon.Format("Mon, 2 Jan 2006 15:04:05 -0700")})
+	ensure.DeepEqual(t, failed, []string{"rejected"})
+
+	// The unavailable message is retried later, and the distant one waits
+	due, err := store.Due(ctx, time.Now().Add(2*time.Minute), 0)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(due), 1)
+	ensure.DeepEqual(t, due[0].ID, "unavailable")
+	ensure.DeepEqual(t, due[0].Attempts, 1)
+	ensure.DeepEqual(t, due[0].LastError != "", true)
+
+	due, err = store.Due(ctx, time.Now().Add(31*24*time.Hour), 0)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(due), 2)
+}
+
+func TestFileScheduleStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schedule")
+	ensure.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schedule.json")
+
+	ctx := context.Background()
+	store, err := mailgun.NewFileScheduleStore(path)
+	ensure.Nil(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	ensure.Nil(t, store.Add(ctx, mailgun.ScheduledMessage{ID: "one", NextAttempt: now, To: []string{"bob@example.com"}}))
+	ensure.Nil(t, store.Add(ctx, mailgun.ScheduledMessage{ID: "two", NextAttempt: now.Add(time.Hour)}))
+	ensure.Nil(t, store.Remove(ctx, "two"))
+
+	// Messages survive a restart
+	store, err = mailgun.NewFileScheduleStore(path)
+	ensure.Nil(t, err)
+	due, err := store.Due(ctx, now.Add(2*time.Hour), 0)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, due, []mailgun.ScheduledMessage{{ID: "one", NextAttempt: now, To: []string{"bob@example.com"}}})
+	ensure.DeepEqual(t, store.Add(ctx, due[0]), mailgun.ErrAlreadyScheduled)
+
+	// A change which cannot be written is not kept
+	ensure.Nil(t, os.RemoveAll(dir))
+	ensure.NotNil(t, store.Add(ctx, mailgun.ScheduledMessage{ID: "three", NextAttempt: now}))
+	ensure.NotNil(t, store.Remove(ctx, "one"))
+	due, err = store.Due(ctx, now.Add(2*time.Hour), 0)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(due), 1)
+	ensure.DeepEqual(t, due[0].ID, "one")
+}
@@ -0,0 +1,109 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// A MemberFilter selects the members of a mailing list which belong to a segment.
+type MemberFilter func(Member) bool
+
+// MemberVarEquals returns a MemberFilter selecting members whose var named key equals value,
+// for example MemberVarEquals("plan", "pro").
+func MemberVarEquals(key string, value interface{}) MemberFilter {
+	return func(m Member) bool {
+		v, ok := m.Vars[key]
+		return ok && reflect.DeepEqual(v, value)
+	}
+}
+
+// SegmentMembers iterates over the members of the mailing list and returns the subscribed members
+// selected by filter. Mailgun has no server-side segmentation of lists, so every page of members
+// is fetched.
+//
+//  pro, err := mg.SegmentMembers(ctx, "customers@example.com", func(m mailgun.Member) bool {
+//    return m.Vars["plan"] == "pro"
+//  })
+func (mg *MailgunImpl) SegmentMembers(ctx context.Context, address string, filter MemberFilter) ([]Member, error) {
+	var result, page []Member
+	it := mg.ListMembers(address, nil)
+	for it.Next(ctx, &page) {
+		for _, m := range page {
+			if m.Subscribed != nil && !*m.Subscribed {
+				continue
+			}
+			if filter(m) {
+				result = append(result, m)
+			}
+		}
+	}
+	return result, it.Err()
+}
+
+// CreateSegmentList creates a new mailing list from prototype and adds to it the subscribed members
+// of the mailing list at address selected by filter, with their names and vars. The new list can
+// then be sent to like any other list.
+func (mg *MailgunImpl) CreateSegmentList(ctx context.Context, address string, filter MemberFilter, prototype MailingList) (MailingList, error) {
+	members, err := mg.SegmentMembers(ctx, address, filter)
+	if err != nil {
+		return MailingList{}, err
+	}
+
+	resp, err := mg.CreateMailingList(ctx, prototype)
+	if err != nil {
+		return MailingList{}, err
+	}
+	list := resp.List
+	if list.Address == "" {
+		list = prototype
+	}
+
+	for len(members) > 0 {
+		n := len(members)
+		if n > MaxMembersPerRequest {
+			n = MaxMembersPerRequest
+		}
+		batch := make([]interface{}, n)
+		for i, m := range members[:n] {
+			batch[i] = m
+		}
+		if err := mg.CreateMemberList(ctx, nil, list.Address, batch); err != nil {
+			return list, fmt.Errorf("while adding members to '%s': %s", list.Address, err)
+		}
+		members = members[n:]
+	}
+	return list, nil
+}
+
+// NewSegmentMessages returns batch messages addressed to the members, with each member's vars as
+// its recipient variables, so that %recipient.name% style substitutions work as they do for
+// list mail. A message is created for every MaxNumberOfRecipients members.
+//
+//  members, err := mg.SegmentMembers(ctx, "customers@example.com", mailgun.MemberVarEquals("plan", "pro"))
+//  messages, err := mg.NewSegmentMessages(members, "news@example.com", "Pro news", "Hi %recipient.first%")
+//  for _, m := range messages {
+//    _, _, err = mg.Send(ctx, m)
+//  }
+func (mg *MailgunImpl) NewSegmentMessages(members []Member, from, subject, text string) ([]*Message, error) {
+	var messages []*Message
+	for len(members) > 0 {
+		n := len(members)
+		if n > MaxNumberOfRecipients {
+			n = MaxNumberOfRecipients
+		}
+		m := mg.NewMessage(from, subject, text)
+		for _, member := range members[:n] {
+			vars := member.Vars
+			if vars == nil {
+				vars = map[string]interface{}{}
+			}
+			if err := m.AddRecipientAndVariables(member.Address, vars); err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, m)
+		members = members[n:]
+	}
+	return messages, nil
+}
@@ -0,0 +1,67 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestSegmentMembers(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+
+	ctx := context.Background()
+	address := randomEmail("list", testDomain)
+	_, err := mg.CreateMailingList(ctx, mailgun.MailingList{Address: address, Name: address})
+	ensure.Nil(t, err)
+	defer func() {
+		ensure.Nil(t, mg.DeleteMailingList(ctx, address))
+	}()
+
+	ensure.Nil(t, mg.CreateMemberList(ctx, nil, address, []interface{}{
+		mailgun.Member{Address: "pro@example.com", Subscribed: mailgun.Subscribed, Vars: map[string]interface{}{"plan": "pro"}},
+		mailgun.Member{Address: "free@example.com", Subscribed: mailgun.Subscribed, Vars: map[string]interface{}{"plan": "free"}},
+		mailgun.Member{Address: "gone@example.com", Subscribed: mailgun.Unsubscribed, Vars: map[string]interface{}{"plan": "pro"}},
+	}))
+
+	members, err := mg.SegmentMembers(ctx, address, mailgun.MemberVarEquals("plan", "pro"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(members), 1)
+	ensure.DeepEqual(t, members[0].Address, "pro@example.com")
+
+	segment := randomEmail("segment", testDomain)
+	list, err := mg.CreateSegmentList(ctx, address, mailgun.MemberVarEquals("plan", "pro"), mailgun.MailingList{
+		Address: segment,
+		Name:    "Pro customers",
+	})
+	ensure.Nil(t, err)
+	defer func() {
+		ensure.Nil(t, mg.DeleteMailingList(ctx, segment))
+	}()
+	ensure.DeepEqual(t, list.Address, segment)
+
+	member, err := mg.GetMember(ctx, "pro@example.com", segment)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, member.Vars["plan"], "pro")
+}
+
+func TestNewSegmentMessages(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+
+	var members []mailgun.Member
+	for i := 0; i < mailgun.MaxNumberOfRecipients+1; i++ {
+		members = append(members, mailgun.Member{
+			Address: fmt.Sprintf("user%d@example.com", i),
+			Vars:    map[string]interface{}{"id": i},
+		})
+	}
+
+	messages, err := mg.NewSegmentMessages(members, "news@example.com", "News", "Hello %recipient.id%")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(messages), 2)
+	ensure.DeepEqual(t, messages[0].RecipientCount(), mailgun.MaxNumberOfRecipients)
+	ensure.DeepEqual(t, messages[1].RecipientCount(), 1)
+}
@@ -0,0 +1,84 @@
+package mailgun
+
+import "strings"
+
+// SendDefaults are options `Send()` applies to the messages of a domain which do not set them
+// themselves. Pointer fields which are nil, and empty fields, leave the option unset.
+type SendDefaults struct {
+	// From is the sender of messages created without one.
+	From string
+	// Tags are added to messages which have no tags of their own.
+	Tags []string
+	// RequireTLS, Tracking, TrackingClicks and TrackingOpens apply to messages which did not call
+	// `SetRequireTLS()`, `SetTracking()`, `SetTrackingClicks()` or `SetTrackingOpens()`.
+	RequireTLS     *bool
+	Tracking       *bool
+	TrackingClicks *bool
+	TrackingOpens  *bool
+}
+
+// SetSendDefaults sets the options applied to every message sent for domain, such as the sender,
+// tags and tracking settings, unless the message sets them itself; nil removes the defaults of the
+// domain. The domain of a message is the one set with `Message.AddDomain()`, or else the domain of
+// the client. Defaults are applied to the message when it is sent, so they show up on it
+// afterwards, and apply to messages sent with `SendBatch()` and enqueued in an Outbox as well.
+//
+//  requireTLS, trackOpens := true, false
+//  mg.SetSendDefaults("mg.example.com", &mailgun.SendDefaults{
+//    From:          "Example <no-reply@mg.example.com>",
+//    Tags:          []string{"transactional"},
+//    RequireTLS:    &requireTLS,
+//    TrackingOpens: &trackOpens,
+//  })
+//  m := mg.NewMessage("", "Your receipt", text, "joe@example.com")
+func (mg *MailgunImpl) SetSendDefaults(domain string, defaults *SendDefaults) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	domain = strings.ToLower(domain)
+	if defaults == nil {
+		delete(mg.sendDefaults, domain)
+		return
+	}
+	if mg.sendDefaults == nil {
+		mg.sendDefaults = make(map[string]SendDefaults)
+	}
+	d := *defaults
+	d.Tags = append([]string(nil), defaults.Tags...)
+	mg.sendDefaults[domain] = d
+}
+
+// applySendDefaults sets the options of m which it leaves unset to the defaults of its domain.
+func (mg *MailgunImpl) applySendDefaults(m *Message) error {
+	domain := m.domain
+	if domain == "" {
+		domain = mg.Domain()
+	}
+	mg.mu.RLock()
+	d, ok := mg.sendDefaults[strings.ToLower(domain)]
+	mg.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	if pm, ok := m.specific.(*plainMessage); ok && pm.from == "" {
+		pm.from = d.From
+	}
+	if len(m.tags) == 0 && len(d.Tags) != 0 {
+		if err := m.AddTag(d.Tags...); err != nil {
+			return err
+		}
+	}
+	if !m.requireTLSSet && d.RequireTLS != nil {
+		m.SetRequireTLS(*d.RequireTLS)
+	}
+	if !m.trackingSet && d.Tracking != nil {
+		m.SetTracking(*d.Tracking)
+	}
+	if !m.trackingClicksSet && d.TrackingClicks != nil {
+		m.SetTrackingClicks(*d.TrackingClicks)
+	}
+	if !m.trackingOpensSet && d.TrackingOpens != nil {
+		m.SetTrackingOpens(*d.TrackingOpens)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestSendDefaults(t *testing.T) {
+	var forms []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseMultipartForm(1 << 20)
+		forms = append(forms, req.MultipartForm.Value)
+		fmt.Fprint(w, `{"message":"Queued. Thank you.", "id":"<id@example.com>"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+	mg.SetSendDefaults(exampleDomain, &SendDefaults{
+		From:          "no-reply@example.com",
+		Tags:          []string{"transactional"},
+		RequireTLS:    &yes,
+		TrackingOpens: &no,
+	})
+
+	m := mg.NewMessage("", exampleSubject, exampleText, "joe@example.com")
+	_, _, err := mg.Send(ctx, m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, forms[0]["from"], []string{"no-reply@example.com"})
+	ensure.DeepEqual(t, forms[0]["o:tag"], []string{"transactional"})
+	ensure.DeepEqual(t, forms[0]["o:require-tls"], []string{"true"})
+	ensure.DeepEqual(t, forms[0]["o:tracking-opens"], []string{"no"})
+	ensure.DeepEqual(t, forms[0]["o:tracking"], []string(nil))
+
+	// Options set on the message override the defaults
+	m = mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com")
+	ensure.Nil(t, m.AddTag("receipt"))
+	m.SetRequireTLS(false)
+	m.SetTrackingOpens(true)
+	_, _, err = mg.Send(ctx, m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, forms[1]["from"], []string{fromUser})
+	ensure.DeepEqual(t, forms[1]["o:tag"], []string{"receipt"})
+	ensure.DeepEqual(t, forms[1]["o:require-tls"], []string(nil))
+	ensure.DeepEqual(t, forms[1]["o:tracking-opens"], []string{"yes"})
+
+	// Defaults only apply to the messages of their domain
+	m = mg.NewMessage(fromUser, exampleSubject, exampleText, "joe@example.com")
+	m.AddDomain("other.example.com")
+	_, _, err = mg.Send(ctx, m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, forms[2]["o:tag"], []string(nil))
+
+	mg.SetSendDefaults(exampleDomain, nil)
+	_, _, err = mg.Send(ctx, mg.NewMessage("", exampleSubject, exampleText, "joe@example.com"))
+	ensure.NotNil(t, err)
+	ensure.DeepEqual(t, len(forms), 3)
+}
@@ -0,0 +1,125 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PauseOptions configures `PauseSending()`.
+type PauseOptions struct {
+	// DropQueued also deletes the messages Mailgun has queued or scheduled for the domain (see
+	// `DeleteScheduledMessages()`), so mail already accepted from a compromised key is not
+	// delivered. The messages cannot be restored.
+	DropQueued bool
+}
+
+// SendingStatus is the outcome of `PauseSending()` and `ResumeSending()`.
+type SendingStatus struct {
+	Domain string
+	// Paused is whether the client refuses to send for the domain.
+	Paused bool
+	// PausedAt is when sending was paused, or the zero time if it is not.
+	PausedAt time.Time
+	// State is the state of the domain as reported by Mailgun, such as DomainStateDisabled if
+	// Mailgun itself has suspended the domain.
+	State string
+	// DroppedQueued is set when the messages queued for the domain were deleted.
+	DroppedQueued bool
+}
+
+// SendingPausedError is returned by `Send()`, `ReSend()` and `ReplayFailedSend()` for a domain
+// paused with `PauseSending()`.
+type SendingPausedError struct {
+	Domain   string
+	PausedAt time.Time
+}
+
+func (e *SendingPausedError) Error() string {
+	return fmt.Sprintf("sending for %s is paused since %s", e.Domain, e.PausedAt.Format(time.RFC3339))
+}
+
+// PauseSending stops the client sending for domain, for incident response such as when a key
+// starts sending spam: `Send()` fails with a *SendingPausedError until `ResumeSending()` is called.
+// So do `ReSend()` of a message stored for the domain and `ReplayFailedSend()`, and an Outbox
+// using the client keeps the messages of the domain until then.
+//
+// Mailgun has no API to suspend sending for a domain, so the pause only applies to this client;
+// other clients, and anyone holding a leaked key, can still send. Rotate the API key and the SMTP
+// credentials of the domain to lock them out, and set DropQueued to discard what they have
+// already queued. The pause is recorded before Mailgun is called, so it holds even while the API
+// is unreachable; the state Mailgun has for the domain is then looked up on a best-effort basis,
+// leaving the State of the status empty if it cannot be.
+//
+//  status, err := mg.PauseSending(ctx, "example.com", &mailgun.PauseOptions{DropQueued: true})
+//  // ... rotate keys ...
+//  status, err = mg.ResumeSending(ctx, "example.com")
+func (mg *MailgunImpl) PauseSending(ctx context.Context, domain string, opts *PauseOptions) (SendingStatus, error) {
+	mg.mu.Lock()
+	pausedAt, ok := mg.pausedDomains[strings.ToLower(domain)]
+	if !ok {
+		pausedAt = mg.clock().Now()
+		if mg.pausedDomains == nil {
+			mg.pausedDomains = make(map[string]time.Time)
+		}
+		mg.pausedDomains[strings.ToLower(domain)] = pausedAt
+	}
+	mg.mu.Unlock()
+	status := SendingStatus{Domain: domain, Paused: true, PausedAt: pausedAt, State: mg.domainState(ctx, domain)}
+
+	if opts != nil && opts.DropQueued {
+		if err := mg.DeleteScheduledMessages(ctx, domain); err != nil {
+			return status, err
+		}
+		status.DroppedQueued = true
+	}
+	return status, nil
+}
+
+// ResumeSending lets the client send for a domain paused with `PauseSending()` again. Resuming a
+// domain which is not paused is not an error. As with `PauseSending()`, the pause is cleared
+// first and the status reports the state Mailgun has for the domain if it can be looked up; it
+// must be active for messages to be accepted.
+func (mg *MailgunImpl) ResumeSending(ctx context.Context, domain string) (SendingStatus, error) {
+	mg.mu.Lock()
+	delete(mg.pausedDomains, strings.ToLower(domain))
+	mg.mu.Unlock()
+	return SendingStatus{Domain: domain, State: mg.domainState(ctx, domain)}, nil
+}
+
+// domainState returns the state Mailgun has for domain, or "" if it cannot be looked up.
+func (mg *MailgunImpl) domainState(ctx context.Context, domain string) string {
+	resp, err := mg.GetDomain(ctx, domain)
+	if err != nil {
+		return ""
+	}
+	return resp.Domain.State
+}
+
+// SendingPaused returns whether sending for domain is paused with `PauseSending()`.
+func (mg *MailgunImpl) SendingPaused(domain string) bool {
+	return mg.checkSendingPaused(domain) != nil
+}
+
+// checkSendingPaused returns a *SendingPausedError if sending for domain is paused.
+func (mg *MailgunImpl) checkSendingPaused(domain string) error {
+	mg.mu.RLock()
+	defer mg.mu.RUnlock()
+	if pausedAt, ok := mg.pausedDomains[strings.ToLower(domain)]; ok {
+		return &SendingPausedError{Domain: domain, PausedAt: pausedAt}
+	}
+	return nil
+}
+
+// storedMessageDomain returns the domain of the url of a stored message, such as
+// https://storage.api.mailgun.net/v3/domains/example.com/messages/KEY, or "" if it has none.
+func storedMessageDomain(url string) string {
+	parts := strings.Split(url, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "domains" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
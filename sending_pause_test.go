@@ -0,0 +1,93 @@
+package mailgun_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestPauseSending(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	// The domain lookup is best-effort
+	unknown, err := mg.PauseSending(ctx, "unknown.domain", nil)
+	ensure.Nil(t, err)
+	ensure.True(t, unknown.Paused)
+	ensure.DeepEqual(t, unknown.State, "")
+	ensure.True(t, mg.SendingPaused("unknown.domain"))
+	unknown, err = mg.ResumeSending(ctx, "unknown.domain")
+	ensure.Nil(t, err)
+	ensure.False(t, mg.SendingPaused("unknown.domain"))
+
+	status, err := mg.PauseSending(ctx, testDomain, &mailgun.PauseOptions{DropQueued: true})
+	ensure.Nil(t, err)
+	ensure.True(t, status.Paused)
+	ensure.True(t, status.DroppedQueued)
+	ensure.False(t, status.PausedAt.IsZero())
+	ensure.DeepEqual(t, status.State, mailgun.DomainStateActive)
+	ensure.True(t, mg.SendingPaused("Mailgun.Test"))
+
+	m := mg.NewMessage("root@"+testDomain, "Subject", "Text Body", "user@"+testDomain)
+	_, _, err = mg.Send(ctx, m)
+	paused, ok := err.(*mailgun.SendingPausedError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, paused.Domain, testDomain)
+	ensure.DeepEqual(t, paused.PausedAt, status.PausedAt)
+
+	// Replaying a failed send, or sending a stored message again, is refused too
+	failed := &mailgun.FailedSend{
+		Domain:   testDomain,
+		Endpoint: "messages",
+		Fields:   []mailgun.FormField{{Key: "from", Value: "root@" + testDomain}, {Key: "to", Value: "user@" + testDomain}},
+	}
+	_, _, err = mg.ReplayFailedSend(ctx, failed)
+	_, ok = err.(*mailgun.SendingPausedError)
+	ensure.True(t, ok)
+	_, _, err = mg.ReSend(ctx, server.URL()+"/domains/"+testDomain+"/messages/key", "user@"+testDomain)
+	_, ok = err.(*mailgun.SendingPausedError)
+	ensure.True(t, ok)
+
+	// Pausing again keeps the original pause time
+	again, err := mg.PauseSending(ctx, testDomain, nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, again.PausedAt, status.PausedAt)
+	ensure.False(t, again.DroppedQueued)
+
+	status, err = mg.ResumeSending(ctx, testDomain)
+	ensure.Nil(t, err)
+	ensure.False(t, status.Paused)
+	ensure.True(t, status.PausedAt.IsZero())
+	ensure.False(t, mg.SendingPaused(testDomain))
+	_, _, err = mg.Send(ctx, m)
+	ensure.Nil(t, err)
+}
+
+func TestPauseSendingAPIDown(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	// The pause is recorded even though Mailgun cannot be reached to drop the queue
+	status, err := mg.PauseSending(ctx, testDomain, &mailgun.PauseOptions{DropQueued: true})
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusServiceUnavailable)
+	ensure.True(t, status.Paused)
+	ensure.False(t, status.DroppedQueued)
+	ensure.DeepEqual(t, status.State, "")
+	ensure.True(t, mg.SendingPaused(testDomain))
+
+	status, err = mg.ResumeSending(ctx, testDomain)
+	ensure.Nil(t, err)
+	ensure.False(t, status.Paused)
+	ensure.False(t, mg.SendingPaused(testDomain))
+}
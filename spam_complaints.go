@@ -2,7 +2,6 @@ package mailgun
 
 import (
 	"context"
-	"strconv"
 )
 
 const (
@@ -26,14 +25,13 @@ type complaintsResponse struct {
 // Recipients of your messages can click on a link which sends feedback to Mailgun
 // indicating that the message they received is, to them, spam.
 func (mg *MailgunImpl) ListComplaints(opts *ListOptions) *ComplaintsIterator {
-	r := newHTTPRequest(generateApiUrl(mg, complaintsEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, complaintsEndpoint))
+	var limit int
 	if opts != nil {
-		if opts.Limit != 0 {
-			r.addParameter("limit", strconv.Itoa(opts.Limit))
-		}
+		r.addParameters(opts.Params)
+		limit = opts.Limit
 	}
+	mg.addPageLimit(r, limit, 0)
 	url, err := r.generateUrlWithParameters()
 	return &ComplaintsIterator{
 		mg:                 mg,
@@ -132,19 +130,13 @@ func (ci *ComplaintsIterator) Previous(ctx context.Context, items *[]Complaint)
 }
 
 func (ci *ComplaintsIterator) fetch(ctx context.Context, url string) error {
-	r := newHTTPRequest(url)
-	r.setClient(ci.mg.Client())
-	r.setBasicAuth(basicAuthUser, ci.mg.APIKey())
-
-	return getResponseFromJSON(ctx, r, &ci.complaintsResponse)
+	return fetchPage(ctx, ci.mg, url, &ci.complaintsResponse)
 }
 
 // GetComplaint returns a single complaint record filed by a recipient at the email address provided.
 // If no complaint exists, the Complaint instance returned will be empty.
 func (mg *MailgunImpl) GetComplaint(ctx context.Context, address string) (Complaint, error) {
-	r := newHTTPRequest(generateApiUrl(mg, complaintsEndpoint) + "/" + address)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, complaintsEndpoint)+"/"+pathEscape(address))
 
 	var c Complaint
 	err := getResponseFromJSON(ctx, r, &c)
@@ -154,10 +146,8 @@ func (mg *MailgunImpl) GetComplaint(ctx context.Context, address string) (Compla
 // CreateComplaint registers the specified address as a recipient who has complained of receiving spam
 // from your domain.
 func (mg *MailgunImpl) CreateComplaint(ctx context.Context, address string) error {
-	r := newHTTPRequest(generateApiUrl(mg, complaintsEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	p := newUrlEncodedPayload()
+	r := newAPIRequest(mg, generateApiUrl(mg, complaintsEndpoint))
+	p := newPayload()
 	p.addValue("address", address)
 	_, err := makePostRequest(ctx, r, p)
 	return err
@@ -166,9 +156,7 @@ func (mg *MailgunImpl) CreateComplaint(ctx context.Context, address string) erro
 // DeleteComplaint removes a previously registered e-mail address from the list of people who complained
 // of receiving spam from your domain.
 func (mg *MailgunImpl) DeleteComplaint(ctx context.Context, address string) error {
-	r := newHTTPRequest(generateApiUrl(mg, complaintsEndpoint) + "/" + address)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, complaintsEndpoint)+"/"+pathEscape(address))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
@@ -2,8 +2,11 @@ package mailgun
 
 import (
 	"context"
+	"sort"
 	"strconv"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 // Stats on accepted messages
@@ -86,7 +89,11 @@ type GetStatOptions struct {
 
 // GetStats returns total stats for a given domain for the specified time period
 func (mg *MailgunImpl) GetStats(ctx context.Context, events []string, opts *GetStatOptions) ([]Stats, error) {
-	r := newHTTPRequest(generateApiUrl(mg, statsTotalEndpoint))
+	return mg.getStats(ctx, mg.Domain(), events, opts)
+}
+
+func (mg *MailgunImpl) getStats(ctx context.Context, domain string, events []string, opts *GetStatOptions) ([]Stats, error) {
+	r := newAPIRequest(mg, generateApiUrlWithDomain(mg, statsTotalEndpoint, domain))
 
 	if opts != nil {
 		if !opts.Start.IsZero() {
@@ -107,9 +114,6 @@ func (mg *MailgunImpl) GetStats(ctx context.Context, events []string, opts *GetS
 		r.addParameter("event", e)
 	}
 
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-
 	var res statsTotalResponse
 	err := getResponseFromJSON(ctx, r, &res)
 	if err != nil {
@@ -118,3 +122,70 @@ func (mg *MailgunImpl) GetStats(ctx context.Context, events []string, opts *GetS
 		return res.Stats, nil
 	}
 }
+
+// accountStatsConcurrency is the number of domains GetAccountStats() fetches stats for at once.
+const accountStatsConcurrency = 4
+
+// GetAccountStats returns the total stats of several domains, as `GetStats()` would for each,
+// merged into one series: the counters of buckets with the same time are added together. Stats
+// are fetched for a few domains at once. If fetching the stats of any domain fails, the error
+// is returned, naming the domain.
+func (mg *MailgunImpl) GetAccountStats(ctx context.Context, domains []string, events []string, opts *GetStatOptions) ([]Stats, error) {
+	perDomain := make([][]Stats, len(domains))
+	exec := BatchExecutor{Concurrency: accountStatsConcurrency, Mode: ContinueOnError}
+	err := exec.Run(ctx, len(domains), func(ctx context.Context, i int) (err error) {
+		perDomain[i], err = mg.getStats(ctx, domains[i], events, opts)
+		return err
+	})
+	if merr, ok := err.(*MultiError); ok {
+		first := merr.Errors[0]
+		return nil, errors.Wrapf(first.Err, "while fetching stats for '%s'", domains[first.Index])
+	}
+	return mergeStats(perDomain...), nil
+}
+
+// mergeStats adds together the buckets of the series with the same time, ordered by time.
+func mergeStats(series ...[]Stats) []Stats {
+	var merged []Stats
+	index := make(map[string]int)
+	for _, stats := range series {
+		for _, s := range stats {
+			i, ok := index[s.Time]
+			if !ok {
+				index[s.Time] = len(merged)
+				merged = append(merged, Stats{Time: s.Time})
+				i = len(merged) - 1
+			}
+			merged[i].add(s)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		ti, erri := time.Parse(time.RFC1123, merged[i].Time)
+		tj, errj := time.Parse(time.RFC1123, merged[j].Time)
+		return erri == nil && errj == nil && ti.Before(tj)
+	})
+	return merged
+}
+
+// add adds the counters of o to s.
+func (s *Stats) add(o Stats) {
+	s.Accepted.Incoming += o.Accepted.Incoming
+	s.Accepted.Outgoing += o.Accepted.Outgoing
+	s.Accepted.Total += o.Accepted.Total
+	s.Delivered.Smtp += o.Delivered.Smtp
+	s.Delivered.Http += o.Delivered.Http
+	s.Delivered.Total += o.Delivered.Total
+	s.Failed.Temporary.Espblock += o.Failed.Temporary.Espblock
+	s.Failed.Permanent.SuppressBounce += o.Failed.Permanent.SuppressBounce
+	s.Failed.Permanent.SuppressUnsubscribe += o.Failed.Permanent.SuppressUnsubscribe
+	s.Failed.Permanent.SuppressComplaint += o.Failed.Permanent.SuppressComplaint
+	s.Failed.Permanent.Bounce += o.Failed.Permanent.Bounce
+	s.Failed.Permanent.DelayedBounce += o.Failed.Permanent.DelayedBounce
+	s.Failed.Permanent.Total += o.Failed.Permanent.Total
+	s.Stored.Total += o.Stored.Total
+	s.Opened.Total += o.Opened.Total
+	s.Clicked.Total += o.Clicked.Total
+	s.Unsubscribed.Total += o.Unsubscribed.Total
+	s.Complained.Total += o.Complained.Total
+}
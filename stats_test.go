@@ -2,6 +2,9 @@ package mailgun
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/facebookgo/ensure"
@@ -38,3 +41,42 @@ func TestDeleteTag(t *testing.T) {
 	ensure.Nil(t, err)
 	ensure.Nil(t, mg.DeleteTag(ctx, "newsletter"))
 }
+
+func TestGetAccountStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/one.example.com/stats/total":
+			fmt.Fprint(w, `{"stats": [
+				{"time": "Tue, 02 Mar 2021 00:00:00 UTC", "accepted": {"outgoing": 5, "total": 5}, "delivered": {"smtp": 4, "total": 4}},
+				{"time": "Mon, 01 Mar 2021 00:00:00 UTC", "accepted": {"outgoing": 1, "total": 1}}
+			]}`)
+		case "/v3/two.example.com/stats/total":
+			fmt.Fprint(w, `{"stats": [
+				{"time": "Tue, 02 Mar 2021 00:00:00 UTC", "accepted": {"outgoing": 2, "total": 2}, "failed": {"permanent": {"bounce": 1, "total": 1}}},
+				{"time": "Wed, 03 Mar 2021 00:00:00 UTC", "opened": {"total": 3}}
+			]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Domain not found"}`)
+		}
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	stats, err := mg.GetAccountStats(ctx, []string{"one.example.com", "two.example.com"}, []string{"accepted"}, nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(stats), 3)
+	ensure.DeepEqual(t, stats[0].Time, "Mon, 01 Mar 2021 00:00:00 UTC")
+	ensure.DeepEqual(t, stats[1].Accepted.Total, 7)
+	ensure.DeepEqual(t, stats[1].Delivered.Smtp, 4)
+	ensure.DeepEqual(t, stats[1].Failed.Permanent.Bounce, 1)
+	ensure.DeepEqual(t, stats[2].Opened.Total, 3)
+
+	_, err = mg.GetAccountStats(ctx, []string{"one.example.com", "missing.example.com"}, []string{"accepted"}, nil)
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "missing.example.com")
+	ensure.DeepEqual(t, GetStatusFromErr(err), http.StatusNotFound)
+}
@@ -3,11 +3,16 @@ package mailgun_test
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
 	"github.com/facebookgo/ensure"
 	"github.com/yjimk/mailgun-go/v4"
 	"github.com/yjimk/mailgun-go/v4/events"
-	"strings"
-	"testing"
 )
 
 func TestStorage(t *testing.T) {
@@ -35,6 +40,69 @@ func TestStorage(t *testing.T) {
 	ensure.Nil(t, err)
 }
 
+func TestDeleteStoredMessage(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+
+	var ctx = context.Background()
+
+	m := mg.NewMessage("root@"+testDomain, "Subject", "Text Body", "stored@"+testDomain)
+	_, id, err := mg.Send(ctx, m)
+	ensure.Nil(t, err)
+
+	url, err := findStoredMessageURL(mg, strings.Trim(id, "<>"))
+	ensure.Nil(t, err)
+
+	ensure.Nil(t, mg.DeleteStoredMessage(ctx, url))
+
+	// The message is gone once deleted
+	err = mg.DeleteStoredMessage(ctx, url)
+	ensure.NotNil(t, err)
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), http.StatusNotFound)
+}
+
+func TestPurgeStoredMessages(t *testing.T) {
+	now := time.Now()
+	var mu sync.Mutex
+	var deleted []string
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodDelete:
+			mu.Lock()
+			deleted = append(deleted, r.URL.Path)
+			mu.Unlock()
+			if r.URL.Path == "/v3/storage/expired" {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"message": "not found"}`)
+				return
+			}
+			fmt.Fprint(w, `{"message": "Message has been deleted"}`)
+		case r.URL.Query().Get("page") == "2":
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+		default:
+			ensure.DeepEqual(t, r.URL.Query().Get("event"), "stored")
+			ensure.DeepEqual(t, r.URL.Query().Get("ascending"), "no")
+			fmt.Fprintf(w, `{"items": [
+				{"event": "stored", "id": "1", "timestamp": %[1]d, "storage": {"url": "%[3]s/v3/storage/old"}},
+				{"event": "stored", "id": "2", "timestamp": %[1]d, "storage": {"url": "%[3]s/v3/storage/expired"}},
+				{"event": "stored", "id": "3", "timestamp": %[2]d, "storage": {"url": "%[3]s/v3/storage/recent"}}
+			], "paging": {"next": "%[3]s/v3/mailgun.test/events?page=2"}}`,
+				now.Add(-72*time.Hour).Unix(), now.Unix(), srv.URL)
+		}
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	count, err := mg.PurgeStoredMessages(context.Background(), 48*time.Hour)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, count, 1)
+	ensure.DeepEqual(t, deleted, []string{"/v3/storage/old", "/v3/storage/expired"})
+}
+
 // Tries to locate the first stored event type, returning the associated stored message key.
 func findStoredMessageURL(mg mailgun.Mailgun, id string) (string, error) {
 	it := mg.ListEvents(nil)
@@ -52,3 +120,41 @@ func findStoredMessageURL(mg mailgun.Mailgun, id string) (string, error) {
 	}
 	return "", fmt.Errorf("No stored messages found.  Try changing MG_EMAIL_TO to an address that stores messages and try again.")
 }
+
+func TestGetMessageStorage(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("message-id")
+		if query != "stored@example.com" {
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+			return
+		}
+		fmt.Fprint(w, `{"items": [
+			{"event": "accepted", "id": "1", "timestamp": 1500000000, "message": {"headers": {"message-id": "stored@example.com"}},
+			 "storage": {"key": "key-1", "url": "https://storage-us-east4.api.mailgun.net/v3/domains/example.com/messages/key-1"}}
+		], "paging": {}}`)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	s, err := mg.GetMessageStorage(ctx, "<stored@example.com>")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, query, "stored@example.com")
+	ensure.DeepEqual(t, s.Key, "key-1")
+	ensure.DeepEqual(t, s.Region, "us-east4")
+	ensure.DeepEqual(t, s.StoredAt, time.Unix(1500000000, 0).UTC())
+	ensure.DeepEqual(t, s.ExpiresAt, s.StoredAt.Add(mailgun.DefaultMessageRetention))
+	ensure.False(t, s.Expired(s.StoredAt.Add(time.Hour)))
+	ensure.True(t, s.Expired(s.ExpiresAt))
+
+	mg.SetMessageRetention(7 * 24 * time.Hour)
+	s, err = mg.GetMessageStorage(ctx, "stored@example.com")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, s.ExpiresAt, s.StoredAt.Add(7*24*time.Hour))
+
+	_, err = mg.GetMessageStorage(ctx, "missing@example.com")
+	ensure.DeepEqual(t, err, mailgun.ErrMessageNotStored)
+}
@@ -0,0 +1,144 @@
+package mailgun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultSubscriptionTokenTTL is how long the confirmation token of a SubscriptionFlow is valid.
+const DefaultSubscriptionTokenTTL = 48 * time.Hour
+
+var (
+	// ErrSubscriptionToken is returned by `SubscriptionFlow.ConfirmToken()` for tokens which were
+	// not signed with the secret of the flow, or are malformed.
+	ErrSubscriptionToken = errors.New("subscription token not valid")
+	// ErrSubscriptionTokenExpired is returned by `SubscriptionFlow.ConfirmToken()` for tokens
+	// older than the token TTL of the flow.
+	ErrSubscriptionTokenExpired = errors.New("subscription token expired")
+)
+
+// SubscriptionFlow implements double opt-in for a mailing list: a subscriber is only added to the
+// list once they confirm their address, by following the link of a confirmation message sent to
+// it. Nothing is stored while the subscription is pending; the member is carried by a token signed
+// with a secret of your own, which expires after the token TTL.
+//
+//  flow := mailgun.NewSubscriptionFlow(mg, "news@lists.example.com", os.Getenv("SUBSCRIBE_SECRET"))
+//  flow.SetConfirmURL("https://example.com/subscribe/confirm")
+//
+//  m := mg.NewMessage("news@example.com", "Confirm your subscription",
+//    "Follow %recipient.confirm_url% to confirm your subscription.")
+//  err := flow.SendConfirmation(ctx, mailgun.Member{Address: "joe@example.com", Name: "Joe"}, m)
+//
+//  // In the handler of the confirmation link
+//  member, err := flow.ConfirmToken(ctx, r.FormValue("token"))
+//
+// A SubscriptionFlow is safe for concurrent use.
+type SubscriptionFlow struct {
+	mg     Mailgun
+	list   string
+	secret []byte
+
+	mu         sync.RWMutex
+	ttl        time.Duration
+	confirmURL string
+}
+
+// subscriptionToken is the payload of the token of a pending member.
+type subscriptionToken struct {
+	List    string                 `json:"l"`
+	Address string                 `json:"a"`
+	Name    string                 `json:"n,omitempty"`
+	Vars    map[string]interface{} `json:"v,omitempty"`
+	Expires int64                  `json:"e"`
+}
+
+// NewSubscriptionFlow returns a flow which adds confirmed members to list through mg, and signs
+// and verifies its tokens with secret.
+func NewSubscriptionFlow(mg Mailgun, list, secret string) *SubscriptionFlow {
+	return &SubscriptionFlow{mg: mg, list: list, secret: []byte(secret), ttl: DefaultSubscriptionTokenTTL}
+}
+
+// SetTokenTTL sets how long tokens are valid once they are issued.
+func (f *SubscriptionFlow) SetTokenTTL(ttl time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ttl = ttl
+}
+
+// SetConfirmURL sets the page confirmation links point to; the token of the member is added to
+// its query as the "token" parameter.
+func (f *SubscriptionFlow) SetConfirmURL(base string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.confirmURL = base
+}
+
+// Token returns a token which adds the member to the list when it is confirmed. The address,
+// name and vars of the member are kept.
+func (f *SubscriptionFlow) Token(member Member) (string, error) {
+	f.mu.RLock()
+	ttl := f.ttl
+	f.mu.RUnlock()
+	payload, err := json.Marshal(subscriptionToken{
+		List:    f.list,
+		Address: member.Address,
+		Name:    member.Name,
+		Vars:    member.Vars,
+		Expires: time.Now().Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return signToken(f.secret, payload), nil
+}
+
+// SendConfirmation sends m to the member, asking them to confirm their subscription. m must have
+// no recipients; the member is added as its only recipient, with the recipient variables
+// "confirm_token" and, once `SetConfirmURL()` is called, "confirm_url", which the body of the
+// message refers to as %recipient.confirm_url%.
+func (f *SubscriptionFlow) SendConfirmation(ctx context.Context, member Member, m *Message) error {
+	if m.RecipientCount() != 0 {
+		return errors.New("the confirmation message must have no recipients; the member is added to it")
+	}
+	token, err := f.Token(member)
+	if err != nil {
+		return err
+	}
+	vars := map[string]interface{}{"confirm_token": token}
+	f.mu.RLock()
+	if f.confirmURL != "" {
+		vars["confirm_url"] = tokenURL(f.confirmURL, token)
+	}
+	f.mu.RUnlock()
+	if err := m.AddRecipientAndVariables(member.Address, vars); err != nil {
+		return err
+	}
+	_, _, err = f.mg.Send(ctx, m)
+	return err
+}
+
+// ConfirmToken adds the member the token was issued for to the list as a subscribed member,
+// updating them if they are already on it, and returns it. Tokens may be confirmed more than once
+// until they expire.
+func (f *SubscriptionFlow) ConfirmToken(ctx context.Context, token string) (Member, error) {
+	payload, ok := verifyToken(f.secret, token)
+	if !ok {
+		return Member{}, ErrSubscriptionToken
+	}
+	var t subscriptionToken
+	if err := json.Unmarshal(payload, &t); err != nil || t.Address == "" || t.List != f.list {
+		return Member{}, ErrSubscriptionToken
+	}
+	if time.Now().Unix() > t.Expires {
+		return Member{}, ErrSubscriptionTokenExpired
+	}
+
+	member := Member{Address: t.Address, Name: t.Name, Vars: t.Vars, Subscribed: Subscribed}
+	if err := f.mg.CreateMember(ctx, true, f.list, member); err != nil {
+		return Member{}, err
+	}
+	return member, nil
+}
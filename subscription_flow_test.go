@@ -0,0 +1,65 @@
+package mailgun_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestSubscriptionFlow(t *testing.T) {
+	var forms []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		forms = append(forms, r.Form)
+		w.Write([]byte(`{"id": "<id@example.com>", "message": "Queued. Thank you."}`))
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	flow := mailgun.NewSubscriptionFlow(mg, "news@lists.example.com", "subscribe-secret")
+	flow.SetConfirmURL("https://example.com/confirm")
+	ctx := context.Background()
+
+	m := mg.NewMessage("news@example.com", "Confirm", "Follow %recipient.confirm_url%")
+	member := mailgun.Member{Address: "joe@example.com", Name: "Joe", Vars: map[string]interface{}{"source": "footer"}}
+	ensure.Nil(t, flow.SendConfirmation(ctx, member, m))
+	ensure.DeepEqual(t, len(forms), 1)
+	ensure.DeepEqual(t, forms[0]["to"], []string{"joe@example.com"})
+	var vars map[string]map[string]string
+	ensure.Nil(t, json.Unmarshal([]byte(forms[0].Get("recipient-variables")), &vars))
+	token := vars["joe@example.com"]["confirm_token"]
+	ensure.DeepEqual(t, vars["joe@example.com"]["confirm_url"], "https://example.com/confirm?token="+url.QueryEscape(token))
+
+	// Nothing is added to the list until the token is confirmed
+	confirmed, err := flow.ConfirmToken(ctx, token)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, confirmed.Address, "joe@example.com")
+	ensure.DeepEqual(t, len(forms), 2)
+	ensure.DeepEqual(t, forms[1].Get("address"), "joe@example.com")
+	ensure.DeepEqual(t, forms[1].Get("name"), "Joe")
+	ensure.DeepEqual(t, forms[1].Get("subscribed"), "yes")
+	ensure.DeepEqual(t, forms[1].Get("upsert"), "yes")
+	ensure.DeepEqual(t, forms[1].Get("vars"), `{"source":"footer"}`)
+
+	// Tokens of other secrets or lists, and expired tokens, are refused
+	_, err = mailgun.NewSubscriptionFlow(mg, "news@lists.example.com", "other-secret").ConfirmToken(ctx, token)
+	ensure.DeepEqual(t, err, mailgun.ErrSubscriptionToken)
+	_, err = mailgun.NewSubscriptionFlow(mg, "other@lists.example.com", "subscribe-secret").ConfirmToken(ctx, token)
+	ensure.DeepEqual(t, err, mailgun.ErrSubscriptionToken)
+	flow.SetTokenTTL(-time.Second)
+	expired, err := flow.Token(member)
+	ensure.Nil(t, err)
+	_, err = flow.ConfirmToken(ctx, expired)
+	ensure.DeepEqual(t, err, mailgun.ErrSubscriptionTokenExpired)
+	ensure.DeepEqual(t, len(forms), 2)
+
+	ensure.NotNil(t, flow.SendConfirmation(ctx, member, mg.NewMessage("news@example.com", "Confirm", "text", "jane@example.com")))
+}
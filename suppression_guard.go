@@ -0,0 +1,305 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GuardAction selects what the suppression guard set with `SetSuppressionGuard()` does with
+// suppressed recipients.
+type GuardAction int
+
+const (
+	// StripSuppressed removes suppressed recipients from the message, and sends it to the rest.
+	StripSuppressed GuardAction = iota
+	// FailSuppressed fails the send with a *SuppressedRecipientsError if any recipient is suppressed.
+	FailSuppressed
+)
+
+const (
+	defaultGuardTTL = 10 * time.Minute
+	// DefaultGuardCacheSize is the number of addresses the suppression guard caches by default.
+	DefaultGuardCacheSize = 10000
+	// guardConcurrency is the number of recipients the guard looks up at once.
+	guardConcurrency = 8
+)
+
+// SuppressionGuardOptions configures the suppression guard set with `SetSuppressionGuard()`.
+type SuppressionGuardOptions struct {
+	Action GuardAction
+	// TTL is how long the suppression status of an address is cached; defaults to 10 minutes.
+	TTL time.Duration
+	// CacheSize is the number of addresses cached, after which expired addresses, then the
+	// addresses which expire soonest, are dropped; defaults to DefaultGuardCacheSize.
+	CacheSize int
+	// Store, if set, is checked instead of the bounces, unsubscribes and complaints APIs; keep it
+	// up to date with `SuppressionHandler()`.
+	Store SuppressionStore
+	// OnLookupError, if set, is called with the recipients whose lookup failed, which are let
+	// through, and the error of the lookup.
+	OnLookupError func(address string, err error)
+}
+
+// SuppressedRecipientsError is returned by `Send()` when the suppression guard finds suppressed
+// recipients, and either its action is FailSuppressed or no To: recipients remain.
+type SuppressedRecipientsError struct {
+	// Suppressed lists the suppressed recipients, in the order they were added to the message.
+	Suppressed []Suppression
+}
+
+func (e *SuppressedRecipientsError) Error() string {
+	list := make([]string, len(e.Suppressed))
+	for i, s := range e.Suppressed {
+		list[i] = fmt.Sprintf("%s (%s)", s.Address, s.Reason)
+	}
+	return "suppressed recipients: " + strings.Join(list, ", ")
+}
+
+// SetSuppressionGuard enables checking the recipients of each `*Message` against the bounces,
+// unsubscribes and complaints of the client's domain before it is sent, so mail to suppressed
+// addresses does not damage the reputation of the domain. Depending on the action, suppressed
+// recipients are removed from the message, or the send fails listing them. An address
+// unsubscribed only from some tags is suppressed only for messages with one of those tags.
+// Lookups are cached for the TTL, and each new recipient costs up to three requests; a lookup
+// which fails lets the recipient through, and is reported to OnLookupError. The message passed to
+// `Send()` is left as it is; the recipients are removed from the copy sent. Pass nil to disable the guard, which is disabled by
+// default.
+//
+//  mg.SetSuppressionGuard(&mailgun.SuppressionGuardOptions{Action: mailgun.FailSuppressed})
+//  _, _, err := mg.Send(ctx, m)
+//  if serr, ok := err.(*mailgun.SuppressedRecipientsError); ok {
+//    for _, s := range serr.Suppressed {
+//      log.Printf("not sending to %s: %s", s.Address, s.Reason)
+//    }
+//  }
+func (mg *MailgunImpl) SetSuppressionGuard(opts *SuppressionGuardOptions) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.guard = nil
+	mg.guardCache = nil
+	if opts != nil {
+		cpy := *opts
+		if cpy.TTL <= 0 {
+			cpy.TTL = defaultGuardTTL
+		}
+		if cpy.CacheSize <= 0 {
+			cpy.CacheSize = DefaultGuardCacheSize
+		}
+		mg.guard = &cpy
+	}
+}
+
+// guardEntry is a cached suppression lookup; suppressions is nil for an address which is not
+// suppressed.
+type guardEntry struct {
+	suppressions []Suppression
+	unsubscribed []string
+	expires      time.Time
+}
+
+// guardRecipients applies the suppression guard to the recipients of the message, and returns the
+// message to send: m itself, or a copy of it without the suppressed recipients.
+func (mg *MailgunImpl) guardRecipients(ctx context.Context, m *Message) (*Message, error) {
+	mg.mu.RLock()
+	guard := mg.guard
+	mg.mu.RUnlock()
+	if guard == nil {
+		return m, nil
+	}
+
+	var cc, bcc []string
+	pm, plain := m.specific.(*plainMessage)
+	if plain {
+		cc, bcc = pm.cc, pm.bcc
+	}
+	all := append(append(append([]string(nil), m.to...), cc...), bcc...)
+
+	entries := make([]*guardEntry, len(all))
+	exec := BatchExecutor{Concurrency: guardConcurrency}
+	err := exec.Run(ctx, len(all), func(ctx context.Context, i int) error {
+		var err error
+		entries[i], err = mg.guardLookup(ctx, guard, normalizeAddress(all[i]))
+		return err
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if guard.OnLookupError != nil {
+			for _, e := range err.(*MultiError).Errors {
+				guard.OnLookupError(all[e.Index], e.Err)
+			}
+		}
+	}
+
+	var suppressed []Suppression
+	isSuppressed := make(map[string]bool)
+	for i, e := range entries {
+		if s, ok := e.suppressedFor(m.tags); ok && !isSuppressed[all[i]] {
+			isSuppressed[all[i]] = true
+			suppressed = append(suppressed, s)
+		}
+	}
+	if len(suppressed) == 0 {
+		return m, nil
+	}
+
+	serr := &SuppressedRecipientsError{Suppressed: suppressed}
+	if guard.Action == FailSuppressed {
+		return nil, serr
+	}
+	cpy := *m
+	cpy.to = stripRecipients(m.to, isSuppressed)
+	if len(cpy.to) == 0 {
+		return nil, serr
+	}
+	if m.recipientVariables != nil {
+		cpy.recipientVariables = make(map[string]map[string]interface{}, len(m.recipientVariables))
+		for r, v := range m.recipientVariables {
+			if !isSuppressed[r] {
+				cpy.recipientVariables[r] = v
+			}
+		}
+	}
+	if plain {
+		pcpy := *pm
+		pcpy.cc, pcpy.bcc = stripRecipients(pm.cc, isSuppressed), stripRecipients(pm.bcc, isSuppressed)
+		cpy.specific = &pcpy
+	}
+	return &cpy, nil
+}
+
+// suppressedFor returns the suppression of the address which applies to a message with the tags.
+func (e *guardEntry) suppressedFor(tags []string) (Suppression, bool) {
+	if e == nil {
+		return Suppression{}, false
+	}
+	for _, s := range e.suppressions {
+		if s.Reason != SuppressionUnsubscribe || len(e.unsubscribed) == 0 {
+			return s, true
+		}
+		for _, t := range e.unsubscribed {
+			if t == "*" || containsFold(tags, t) {
+				return s, true
+			}
+		}
+	}
+	return Suppression{}, false
+}
+
+// guardLookup returns the cached suppression status of the address, looking it up if necessary.
+func (mg *MailgunImpl) guardLookup(ctx context.Context, guard *SuppressionGuardOptions, address string) (*guardEntry, error) {
+	mg.mu.RLock()
+	e, ok := mg.guardCache[address]
+	mg.mu.RUnlock()
+	now := mg.clock().Now()
+	if ok && now.Before(e.expires) {
+		return e, nil
+	}
+
+	e = &guardEntry{expires: now.Add(guard.TTL)}
+	if guard.Store != nil {
+		s, err := guard.Store.GetSuppression(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			e.suppressions = append(e.suppressions, *s)
+		}
+	} else if err := mg.lookupSuppressions(ctx, address, e); err != nil {
+		return nil, err
+	}
+
+	mg.mu.Lock()
+	if mg.guard == guard {
+		if mg.guardCache == nil {
+			mg.guardCache = make(map[string]*guardEntry)
+		}
+		if _, ok := mg.guardCache[address]; !ok && len(mg.guardCache) >= guard.CacheSize {
+			evictGuardEntries(mg.guardCache, now)
+		}
+		mg.guardCache[address] = e
+	}
+	mg.mu.Unlock()
+	return e, nil
+}
+
+// evictGuardEntries drops the expired entries of a full cache, or the entry expiring soonest if
+// none expired.
+func evictGuardEntries(cache map[string]*guardEntry, now time.Time) {
+	var soonest string
+	var dropped bool
+	for address, e := range cache {
+		if !now.Before(e.expires) {
+			delete(cache, address)
+			dropped = true
+		} else if soonest == "" || e.expires.Before(cache[soonest].expires) {
+			soonest = address
+		}
+	}
+	if !dropped {
+		delete(cache, soonest)
+	}
+}
+
+// lookupSuppressions fills e from the suppression APIs, and returns the error of the first lookup
+// which failed.
+func (mg *MailgunImpl) lookupSuppressions(ctx context.Context, address string, e *guardEntry) error {
+	found := func(err error) (bool, error) {
+		if err == nil {
+			return true, nil
+		}
+		if GetStatusFromErr(err) == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	b, err := mg.GetBounce(ctx, address)
+	isBounce, err := found(err)
+	if err != nil {
+		return err
+	}
+	if isBounce {
+		code, _ := strconv.Atoi(b.Code)
+		e.suppressions = append(e.suppressions, Suppression{Address: address, Reason: SuppressionBounce,
+			Code: code, Error: b.Error, CreatedAt: time.Time(b.CreatedAt)})
+	}
+
+	c, err := mg.GetComplaint(ctx, address)
+	isComplaint, err := found(err)
+	if err != nil {
+		return err
+	}
+	if isComplaint {
+		e.suppressions = append(e.suppressions, Suppression{Address: address, Reason: SuppressionComplaint,
+			CreatedAt: time.Time(c.CreatedAt)})
+	}
+
+	u, err := mg.GetUnsubscribe(ctx, address)
+	isUnsubscribe, err := found(err)
+	if err != nil {
+		return err
+	}
+	if isUnsubscribe {
+		e.suppressions = append(e.suppressions, Suppression{Address: address, Reason: SuppressionUnsubscribe,
+			CreatedAt: time.Time(u.CreatedAt)})
+		e.unsubscribed = u.Tags
+	}
+	return nil
+}
+
+// stripRecipients returns the recipients which are not suppressed.
+func stripRecipients(recipients []string, suppressed map[string]bool) []string {
+	var kept []string
+	for _, r := range recipients {
+		if !suppressed[r] {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,137 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestSuppressionGuard(t *testing.T) {
+	var mu sync.Mutex
+	lookups := map[string]int{}
+	var sentTo, sentCC []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/messages") {
+			ensure.Nil(t, r.ParseMultipartForm(1<<20))
+			sentTo, sentCC = r.MultipartForm.Value["to"], r.MultipartForm.Value["cc"]
+			fmt.Fprint(w, `{"id": "<id@example.com>", "message": "Queued. Thank you."}`)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		list, address := parts[len(parts)-2], parts[len(parts)-1]
+		mu.Lock()
+		lookups[address]++
+		mu.Unlock()
+		switch {
+		case list == "bounces" && address == "bounced@example.com":
+			fmt.Fprint(w, `{"address": "bounced@example.com", "code": "550", "error": "No such mailbox"}`)
+		case list == "complaints" && address == "complained@example.com":
+			fmt.Fprint(w, `{"address": "complained@example.com", "count": 1}`)
+		case list == "unsubscribes" && address == "newsletter@example.com":
+			fmt.Fprint(w, `{"address": "newsletter@example.com", "tags": ["newsletter"]}`)
+		case list == "unsubscribes" && address == "flaky@example.com":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"message": "internal error"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Address not found"}`)
+		}
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	var lookupErrors []string
+	mg.SetSuppressionGuard(&SuppressionGuardOptions{
+		Action: StripSuppressed,
+		OnLookupError: func(address string, err error) {
+			mu.Lock()
+			lookupErrors = append(lookupErrors, address)
+			mu.Unlock()
+			ensure.DeepEqual(t, GetStatusFromErr(err), http.StatusInternalServerError)
+		},
+	})
+	ctx := context.Background()
+
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText,
+		"ok@example.com", "Bounced <Bounced@example.com>", "newsletter@example.com", "flaky@example.com")
+	m.AddCC("complained@example.com")
+	m.AddTag("receipts")
+	_, _, err := mg.Send(ctx, m)
+	ensure.Nil(t, err)
+	// Unsubscribed from other tags only, and failed lookups, are let through
+	ensure.DeepEqual(t, sentTo, []string{"ok@example.com", "newsletter@example.com", "flaky@example.com"})
+	ensure.DeepEqual(t, len(sentCC), 0)
+	ensure.DeepEqual(t, lookupErrors, []string{"flaky@example.com"})
+	ensure.DeepEqual(t, lookups["ok@example.com"], 3)
+	// The message passed to Send is left as it is
+	ensure.DeepEqual(t, len(m.to), 4)
+	ensure.DeepEqual(t, m.specific.(*plainMessage).cc, []string{"complained@example.com"})
+	ensure.DeepEqual(t, lookups["bounced@example.com"], 3)
+
+	// Lookups are cached, except those which failed
+	mg.SetSuppressionGuard(&SuppressionGuardOptions{Action: FailSuppressed})
+	m = mg.NewMessage(fromUser, exampleSubject, exampleText, "ok@example.com", "newsletter@example.com", "bounced@example.com")
+	m.AddTag("newsletter")
+	_, _, err = mg.Send(ctx, m)
+	serr, ok := err.(*SuppressedRecipientsError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, len(serr.Suppressed), 2)
+	ensure.DeepEqual(t, serr.Suppressed[0].Address, "newsletter@example.com")
+	ensure.DeepEqual(t, serr.Suppressed[0].Reason, SuppressionUnsubscribe)
+	ensure.DeepEqual(t, serr.Suppressed[1].Reason, SuppressionBounce)
+	ensure.DeepEqual(t, serr.Suppressed[1].Code, 550)
+	ensure.DeepEqual(t, err.Error(), "suppressed recipients: newsletter@example.com (unsubscribe), bounced@example.com (bounce)")
+
+	n := lookups["ok@example.com"]
+	m = mg.NewMessage(fromUser, exampleSubject, exampleText, "ok@example.com")
+	_, _, err = mg.Send(ctx, m)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, lookups["ok@example.com"], n)
+
+	// A message left with no recipients is not sent
+	mg.SetSuppressionGuard(&SuppressionGuardOptions{Action: StripSuppressed})
+	m = mg.NewMessage(fromUser, exampleSubject, exampleText, "bounced@example.com")
+	_, _, err = mg.Send(ctx, m)
+	_, ok = err.(*SuppressedRecipientsError)
+	ensure.True(t, ok)
+
+	// The cache holds up to CacheSize addresses
+	mg.SetSuppressionGuard(&SuppressionGuardOptions{Action: StripSuppressed, CacheSize: 2})
+	for _, to := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		_, _, err = mg.Send(ctx, mg.NewMessage(fromUser, exampleSubject, exampleText, to))
+		ensure.Nil(t, err)
+	}
+	ensure.DeepEqual(t, len(mg.guardCache), 2)
+	_, ok = mg.guardCache["a@example.com"]
+	ensure.False(t, ok)
+}
+
+func TestSuppressionGuardStore(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ensure.True(t, strings.HasSuffix(r.URL.Path, "/messages"))
+		fmt.Fprint(w, `{"id": "<id@example.com>", "message": "Queued. Thank you."}`)
+	}))
+	defer srv.Close()
+
+	store := NewMemorySuppressionStore()
+	ctx := context.Background()
+	ensure.Nil(t, store.Suppress(ctx, Suppression{Address: "gone@example.com", Reason: SuppressionComplaint}))
+
+	mg := NewMailgun(exampleDomain, exampleAPIKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	mg.SetSuppressionGuard(&SuppressionGuardOptions{Action: FailSuppressed, Store: store})
+
+	_, _, err := mg.Send(ctx, mg.NewMessage(fromUser, exampleSubject, exampleText, "here@example.com"))
+	ensure.Nil(t, err)
+	_, _, err = mg.Send(ctx, mg.NewMessage(fromUser, exampleSubject, exampleText, "here@example.com", "gone@example.com"))
+	ensure.NotNil(t, err)
+	ensure.DeepEqual(t, err.Error(), "suppressed recipients: gone@example.com (complaint)")
+}
@@ -0,0 +1,204 @@
+package mailgun
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// Reasons recorded with a Suppression.
+const (
+	SuppressionBounce      = "bounce"
+	SuppressionComplaint   = "complaint"
+	SuppressionUnsubscribe = "unsubscribe"
+)
+
+// A Suppression records why mail to an address should no longer be sent.
+type Suppression struct {
+	// Address is the suppressed email address, in lower case.
+	Address string
+	// Reason is one of SuppressionBounce, SuppressionComplaint or SuppressionUnsubscribe.
+	Reason string
+	// Code is the SMTP status code of a bounce.
+	Code int
+	// Error is the description of a bounce given by the receiving server.
+	Error string
+	// CreatedAt is when the event which caused the suppression occurred.
+	CreatedAt time.Time
+}
+
+// SuppressionStore keeps a local copy of suppressed addresses, so that applications can check
+// whether an address is suppressed before sending to it. MemorySuppressionStore and
+// SQLSuppressionStore are provided; implement the interface to use any other storage.
+// Implementations must be safe for concurrent use.
+type SuppressionStore interface {
+	// Suppress records the suppression, replacing any previous suppression of the address.
+	Suppress(ctx context.Context, s Suppression) error
+	// GetSuppression returns the suppression of the address, or nil if it is not suppressed.
+	GetSuppression(ctx context.Context, address string) (*Suppression, error)
+	// RemoveSuppression removes the suppression of the address, if any.
+	RemoveSuppression(ctx context.Context, address string) error
+}
+
+// IsSuppressed reports whether the address is suppressed in the store.
+func IsSuppressed(ctx context.Context, store SuppressionStore, address string) (bool, error) {
+	s, err := store.GetSuppression(ctx, address)
+	if err != nil {
+		return false, err
+	}
+	return s != nil, nil
+}
+
+// SuppressionFromEvent returns the suppression implied by the event. Permanent failures,
+// complaints and unsubscribes result in a suppression; for any other event ok is false.
+func SuppressionFromEvent(event Event) (s Suppression, ok bool) {
+	switch e := event.(type) {
+	case *events.Failed:
+		if e.Severity != events.SeverityPermanent {
+			return s, false
+		}
+		s = Suppression{
+			Address: e.Recipient,
+			Reason:  SuppressionBounce,
+			Code:    e.DeliveryStatus.Code,
+			Error:   e.DeliveryStatus.Message,
+		}
+		if s.Error == "" {
+			s.Error = e.DeliveryStatus.Description
+		}
+	case *events.Complained:
+		s = Suppression{Address: e.Recipient, Reason: SuppressionComplaint}
+	case *events.Unsubscribed:
+		s = Suppression{Address: e.Recipient, Reason: SuppressionUnsubscribe}
+	default:
+		return s, false
+	}
+	if s.Address == "" {
+		return s, false
+	}
+	s.Address = normalizeAddress(s.Address)
+	s.CreatedAt = event.GetTimestamp()
+	return s, true
+}
+
+// SuppressionHandler returns a WebhookHandler which records the suppression implied by each event
+// in store. Register it with a WebhookDispatcher for the failed, complained and unsubscribed events.
+//
+//  h := mailgun.SuppressionHandler(store)
+//  d.On(events.EventFailed, h)
+//  d.On(events.EventComplained, h)
+//  d.On(events.EventUnsubscribed, h)
+func SuppressionHandler(store SuppressionStore) WebhookHandler {
+	return func(ctx context.Context, event Event) error {
+		s, ok := SuppressionFromEvent(event)
+		if !ok {
+			return nil
+		}
+		return store.Suppress(ctx, s)
+	}
+}
+
+// SyncSuppressions records the suppression implied by every event returned by the iterator in
+// store, and returns the number of suppressions recorded. Use it to backfill the store from the
+// events api, or to catch up on webhooks missed while the application was down. Pages are fetched
+// at the pace of the client's other bulk helpers, and retried when rate limited.
+//
+//  it := mg.ListEvents(&mailgun.ListEventOptions{
+//    Begin:  time.Now().Add(-24 * time.Hour),
+//    Filter: map[string]string{"event": "failed OR complained OR unsubscribed"},
+//  })
+//  n, err := mailgun.SyncSuppressions(ctx, it, store)
+func SyncSuppressions(ctx context.Context, it *EventIterator, store SuppressionStore) (int, error) {
+	summary, err := SyncSuppressionsWithOptions(ctx, it, store, SyncSuppressionsOptions{})
+	return summary.Succeeded, err
+}
+
+// SyncSuppressionsOptions controls `SyncSuppressionsWithOptions()`.
+type SyncSuppressionsOptions struct {
+	// MaxErrorRate, if set, lets the sync carry on past suppressions the store fails to record,
+	// until the ratio of those which failed exceeds it, such as 0.01 for one in a hundred. The
+	// ratio is only checked once MinErrorSamples suppressions were attempted; defaults to
+	// DefaultErrorRateSamples. Without it, the sync stops at the first failure.
+	MaxErrorRate    float64
+	MinErrorSamples int
+}
+
+// SyncSuppressionsWithOptions records suppressions in store as `SyncSuppressions()` does, and
+// returns the number recorded and failed, with the reason the sync stopped early, if it did. The
+// error returned is that reason: the error of the store or iterator, or an *ErrorRateError if
+// the sync exceeded the MaxErrorRate of opts.
+func SyncSuppressionsWithOptions(ctx context.Context, it *EventIterator, store SuppressionStore, opts SyncSuppressionsOptions) (BulkSummary, error) {
+	var summary BulkSummary
+	var page []Event
+	var stopErr error
+	exec := BatchExecutor{Mode: FailFast, MaxErrorRate: opts.MaxErrorRate, MinErrorSamples: opts.MinErrorSamples}
+	if opts.MaxErrorRate > 0 {
+		exec.Mode = ContinueOnError
+	}
+	exec.outcome = exec.newOutcome()
+	err := walkPages(ctx, limiterFor(it.mg), func(ctx context.Context) bool {
+		if !it.Next(ctx, &page) {
+			return false
+		}
+		var suppressions []Suppression
+		for _, e := range page {
+			if s, ok := SuppressionFromEvent(e); ok {
+				suppressions = append(suppressions, s)
+			}
+		}
+		pageSummary, _ := exec.RunSummary(ctx, len(suppressions), func(ctx context.Context, i int) error {
+			return store.Suppress(ctx, suppressions[i])
+		})
+		summary.Succeeded += pageSummary.Succeeded
+		summary.Failed += pageSummary.Failed
+		stopErr = pageSummary.AbortReason
+		return stopErr == nil
+	}, &it.err)
+	if stopErr == nil {
+		stopErr = err
+	}
+	summary.AbortReason = stopErr
+	return summary, stopErr
+}
+
+// MemorySuppressionStore is a SuppressionStore held in memory. Its contents are lost when the
+// process exits; use it in tests, or in front of a persistent store.
+type MemorySuppressionStore struct {
+	mu           sync.RWMutex
+	suppressions map[string]Suppression
+}
+
+// NewMemorySuppressionStore returns an empty MemorySuppressionStore.
+func NewMemorySuppressionStore() *MemorySuppressionStore {
+	return &MemorySuppressionStore{suppressions: make(map[string]Suppression)}
+}
+
+// Suppress implements SuppressionStore.
+func (m *MemorySuppressionStore) Suppress(ctx context.Context, s Suppression) error {
+	s.Address = normalizeAddress(s.Address)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.suppressions[s.Address] = s
+	return nil
+}
+
+// GetSuppression implements SuppressionStore.
+func (m *MemorySuppressionStore) GetSuppression(ctx context.Context, address string) (*Suppression, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.suppressions[normalizeAddress(address)]
+	if !ok {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+// RemoveSuppression implements SuppressionStore.
+func (m *MemorySuppressionStore) RemoveSuppression(ctx context.Context, address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.suppressions, normalizeAddress(address))
+	return nil
+}
@@ -0,0 +1,117 @@
+package mailgun
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// SQLSuppressionStore is a SuppressionStore kept in a table of a SQL database. It is a reference
+// implementation which uses only portable SQL, and works with any database/sql driver. Queries
+// use `?` placeholders unless SetNumberedPlaceholders() is called, as PostgreSQL requires.
+type SQLSuppressionStore struct {
+	db       *sql.DB
+	table    string
+	numbered bool
+}
+
+// NewSQLSuppressionStore returns a SuppressionStore kept in the named table of db.
+// Call CreateTable() to create the table if it does not already exist.
+func NewSQLSuppressionStore(db *sql.DB, table string) (*SQLSuppressionStore, error) {
+	if !sqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name '%s'", table)
+	}
+	return &SQLSuppressionStore{db: db, table: table}, nil
+}
+
+// SetNumberedPlaceholders uses `$1`, `$2`... placeholders in queries instead of `?`.
+func (s *SQLSuppressionStore) SetNumberedPlaceholders() {
+	s.numbered = true
+}
+
+// CreateTable creates the suppression table if it does not already exist.
+func (s *SQLSuppressionStore) CreateTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+s.table+` (
+		address VARCHAR(512) NOT NULL PRIMARY KEY,
+		reason VARCHAR(32) NOT NULL,
+		code INTEGER NOT NULL,
+		error TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+// Suppress implements SuppressionStore.
+func (s *SQLSuppressionStore) Suppress(ctx context.Context, sup Suppression) error {
+	sup.Address = normalizeAddress(sup.Address)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Delete then insert, as there is no portable upsert
+	if _, err := tx.ExecContext(ctx, s.query(`DELETE FROM `+s.table+` WHERE address = ?`), sup.Address); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		s.query(`INSERT INTO `+s.table+` (address, reason, code, error, created_at) VALUES (?, ?, ?, ?, ?)`),
+		sup.Address, sup.Reason, sup.Code, sup.Error, sup.CreatedAt.UTC())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetSuppression implements SuppressionStore.
+func (s *SQLSuppressionStore) GetSuppression(ctx context.Context, address string) (*Suppression, error) {
+	var sup Suppression
+	var createdAt time.Time
+	row := s.db.QueryRowContext(ctx,
+		s.query(`SELECT address, reason, code, error, created_at FROM `+s.table+` WHERE address = ?`),
+		normalizeAddress(address))
+	err := row.Scan(&sup.Address, &sup.Reason, &sup.Code, &sup.Error, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sup.CreatedAt = createdAt.UTC()
+	return &sup, nil
+}
+
+// RemoveSuppression implements SuppressionStore.
+func (s *SQLSuppressionStore) RemoveSuppression(ctx context.Context, address string) error {
+	_, err := s.db.ExecContext(ctx, s.query(`DELETE FROM `+s.table+` WHERE address = ?`),
+		normalizeAddress(address))
+	return err
+}
+
+// query rewrites the `?` placeholders of q as `$1`, `$2`... if numbered placeholders are in use.
+func (s *SQLSuppressionStore) query(q string) string {
+	return numberPlaceholders(q, s.numbered)
+}
+
+// numberPlaceholders rewrites the `?` placeholders of q as `$1`, `$2`... if numbered is set.
+func numberPlaceholders(q string, numbered bool) string {
+	if !numbered {
+		return q
+	}
+	var b strings.Builder
+	var n int
+	for _, c := range q {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,173 @@
+package mailgun_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+func TestMemorySuppressionStore(t *testing.T) {
+	ctx := context.Background()
+	store := mailgun.NewMemorySuppressionStore()
+
+	ensure.Nil(t, store.Suppress(ctx, mailgun.Suppression{
+		Address: "Bob@Example.com",
+		Reason:  mailgun.SuppressionComplaint,
+	}))
+
+	suppressed, err := mailgun.IsSuppressed(ctx, store, "bob@example.com")
+	ensure.Nil(t, err)
+	ensure.True(t, suppressed)
+
+	s, err := store.GetSuppression(ctx, " BOB@example.com")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, s.Address, "bob@example.com")
+
+	// Display names and internationalized domains are normalized as well
+	ensure.Nil(t, store.Suppress(ctx, mailgun.Suppression{Address: "Jo <jo@Bücher.example>"}))
+	suppressed, err = mailgun.IsSuppressed(ctx, store, "jo@xn--bcher-kva.example")
+	ensure.Nil(t, err)
+	ensure.True(t, suppressed)
+	ensure.DeepEqual(t, s.Reason, mailgun.SuppressionComplaint)
+
+	ensure.Nil(t, store.RemoveSuppression(ctx, "bob@example.com"))
+	suppressed, err = mailgun.IsSuppressed(ctx, store, "bob@example.com")
+	ensure.Nil(t, err)
+	ensure.False(t, suppressed)
+}
+
+func TestSuppressionFromEvent(t *testing.T) {
+	failed := new(events.Failed)
+	failed.Recipient = "bounced@example.com"
+	failed.Severity = events.SeverityTemporary
+	_, ok := mailgun.SuppressionFromEvent(failed)
+	ensure.False(t, ok)
+
+	failed.Severity = events.SeverityPermanent
+	failed.DeliveryStatus.Code = 550
+	failed.DeliveryStatus.Message = "No such user"
+	failed.SetTimestamp(time.Unix(1500000000, 0))
+	s, ok := mailgun.SuppressionFromEvent(failed)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, s, mailgun.Suppression{
+		Address:   "bounced@example.com",
+		Reason:    mailgun.SuppressionBounce,
+		Code:      550,
+		Error:     "No such user",
+		CreatedAt: time.Unix(1500000000, 0).UTC(),
+	})
+
+	_, ok = mailgun.SuppressionFromEvent(new(events.Delivered))
+	ensure.False(t, ok)
+}
+
+func TestSuppressionHandler(t *testing.T) {
+	const signingKey = "suppression-signing-key"
+	store := mailgun.NewMemorySuppressionStore()
+	d := mailgun.NewWebhookDispatcher(signingKey)
+	d.On(events.EventComplained, mailgun.SuppressionHandler(store))
+
+	complained := new(events.Complained)
+	complained.SetName(events.EventComplained)
+	complained.SetID("complained-id")
+	complained.Recipient = "angry@example.com"
+
+	req, err := mailgun.NewSignedWebhookRequest("http://example.com/webhook", signingKey, complained)
+	ensure.Nil(t, err)
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, req)
+	ensure.DeepEqual(t, w.Code, http.StatusOK)
+
+	suppressed, err := mailgun.IsSuppressed(context.Background(), store, "angry@example.com")
+	ensure.Nil(t, err)
+	ensure.True(t, suppressed)
+}
+
+func TestSyncSuppressions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"items": [
+			{"event": "failed", "id": "1", "severity": "permanent", "recipient": "bounced@example.com"},
+			{"event": "failed", "id": "2", "severity": "temporary", "recipient": "retry@example.com"},
+			{"event": "complained", "id": "3", "recipient": "angry@example.com"},
+			{"event": "delivered", "id": "4", "recipient": "happy@example.com"}
+		], "paging": {"next": "http://%s/v3/mailgun.test/events?page=2"}}`, r.Host)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	ctx := context.Background()
+	store := mailgun.NewMemorySuppressionStore()
+	count, err := mailgun.SyncSuppressions(ctx, mg.ListEvents(nil), store)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, count, 2)
+
+	for address, want := range map[string]bool{
+		"bounced@example.com": true,
+		"retry@example.com":   false,
+		"angry@example.com":   true,
+		"happy@example.com":   false,
+	} {
+		suppressed, err := mailgun.IsSuppressed(ctx, store, address)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, suppressed, want)
+	}
+}
+
+type flakySuppressionStore struct {
+	*mailgun.MemorySuppressionStore
+}
+
+func (f flakySuppressionStore) Suppress(ctx context.Context, s mailgun.Suppression) error {
+	if strings.HasPrefix(s.Address, "flaky") {
+		return errors.New("store unavailable")
+	}
+	return f.MemorySuppressionStore.Suppress(ctx, s)
+}
+
+func TestSyncSuppressionsMaxErrorRate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+			return
+		}
+		var items []string
+		for i, address := range []string{"a", "flaky1", "b", "flaky2", "flaky3", "c"} {
+			items = append(items, fmt.Sprintf(`{"event": "complained", "id": "%d", "recipient": "%s@example.com"}`, i, address))
+		}
+		fmt.Fprintf(w, `{"items": [%s], "paging": {"next": "http://%s/v3/mailgun.test/events?page=2"}}`,
+			strings.Join(items, ","), r.Host)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	ctx := context.Background()
+	store := flakySuppressionStore{mailgun.NewMemorySuppressionStore()}
+	summary, err := mailgun.SyncSuppressionsWithOptions(ctx, mg.ListEvents(nil), store, mailgun.SyncSuppressionsOptions{
+		MaxErrorRate:    0.4,
+		MinErrorSamples: 3,
+	})
+	ensure.DeepEqual(t, err, &mailgun.ErrorRateError{Failed: 2, Processed: 4, MaxErrorRate: 0.4})
+	ensure.DeepEqual(t, summary, mailgun.BulkSummary{Succeeded: 2, Failed: 2, AbortReason: err})
+
+	// Without a maximum error rate, the first failure stops the sync
+	count, err := mailgun.SyncSuppressions(ctx, mg.ListEvents(nil), store)
+	ensure.DeepEqual(t, err.Error(), "store unavailable")
+	ensure.DeepEqual(t, count, 1)
+}
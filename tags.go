@@ -24,22 +24,23 @@ type ListTagOptions struct {
 	Limit int
 	// Return only the tags starting with the given prefix
 	Prefix string
+	// Params are further query parameters sent with the request, such as a flag Mailgun supports
+	// which has no field of its own yet. Pages after the first are fetched from the links Mailgun
+	// returns, which carry the parameters Mailgun applied. Limit and Prefix win over
+	// parameters of the same name.
+	Params map[string]string
 }
 
 // DeleteTag removes all counters for a particular tag, including the tag itself.
 func (mg *MailgunImpl) DeleteTag(ctx context.Context, tag string) error {
-	r := newHTTPRequest(generateApiUrl(mg, tagsEndpoint) + "/" + tag)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, tagsEndpoint)+"/"+pathEscape(tag))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
 
 // GetTag retrieves metadata about the tag from the api
 func (mg *MailgunImpl) GetTag(ctx context.Context, tag string) (Tag, error) {
-	r := newHTTPRequest(generateApiUrl(mg, tagsEndpoint) + "/" + tag)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, tagsEndpoint)+"/"+pathEscape(tag))
 	var tagItem Tag
 	return tagItem, getResponseFromJSON(ctx, r, &tagItem)
 }
@@ -58,11 +59,12 @@ func (mg *MailgunImpl) GetTag(ctx context.Context, tag string) (Tag, error) {
 func (mg *MailgunImpl) ListTags(opts *ListTagOptions) *TagIterator {
 	req := newHTTPRequest(generateApiUrl(mg, tagsEndpoint))
 	if opts != nil {
+		req.addParameters(opts.Params)
 		if opts.Limit != 0 {
-			req.addParameter("limit", strconv.Itoa(opts.Limit))
+			req.setParameter("limit", strconv.Itoa(opts.Limit))
 		}
 		if opts.Prefix != "" {
-			req.addParameter("prefix", opts.Prefix)
+			req.setParameter("prefix", opts.Prefix)
 		}
 	}
 
@@ -158,10 +160,7 @@ func (ti *TagIterator) Err() error {
 }
 
 func (ti *TagIterator) fetch(ctx context.Context, url string) error {
-	req := newHTTPRequest(url)
-	req.setClient(ti.mg.Client())
-	req.setBasicAuth(basicAuthUser, ti.mg.APIKey())
-	return getResponseFromJSON(ctx, req, &ti.tagsResponse)
+	return fetchPage(ctx, ti.mg, url, &ti.tagsResponse)
 }
 
 func canFetchPage(slug string) bool {
@@ -33,11 +33,12 @@ type templateListResp struct {
 
 // Create a new template which can be used to attach template versions to
 func (mg *MailgunImpl) CreateTemplate(ctx context.Context, template *Template) error {
-	r := newHTTPRequest(generateApiUrl(mg, templatesEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	if err := checkTemplateSize(template.Version.Template); err != nil {
+		return err
+	}
+	r := newAPIRequest(mg, generateApiUrl(mg, templatesEndpoint))
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 
 	if template.Name != "" {
 		payload.addValue("name", template.Name)
@@ -69,9 +70,7 @@ func (mg *MailgunImpl) CreateTemplate(ctx context.Context, template *Template) e
 
 // GetTemplate gets a template given the template name
 func (mg *MailgunImpl) GetTemplate(ctx context.Context, name string) (Template, error) {
-	r := newHTTPRequest(generateApiUrl(mg, templatesEndpoint) + "/" + name)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, templatesEndpoint)+"/"+pathEscape(name))
 	r.addParameter("active", "yes")
 
 	var resp templateResp
@@ -88,10 +87,8 @@ func (mg *MailgunImpl) UpdateTemplate(ctx context.Context, template *Template) e
 		return errors.New("UpdateTemplate() Template.Name cannot be empty")
 	}
 
-	r := newHTTPRequest(generateApiUrl(mg, templatesEndpoint) + "/" + template.Name)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	p := newUrlEncodedPayload()
+	r := newAPIRequest(mg, generateApiUrl(mg, templatesEndpoint)+"/"+pathEscape(template.Name))
+	p := newPayload()
 
 	if template.Name != "" {
 		p.addValue("name", template.Name)
@@ -111,9 +108,7 @@ func (mg *MailgunImpl) UpdateTemplate(ctx context.Context, template *Template) e
 
 // Delete a template given a template name
 func (mg *MailgunImpl) DeleteTemplate(ctx context.Context, name string) error {
-	r := newHTTPRequest(generateApiUrl(mg, templatesEndpoint) + "/" + name)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, templatesEndpoint)+"/"+pathEscape(name))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
@@ -127,19 +122,23 @@ type TemplatesIterator struct {
 type ListTemplateOptions struct {
 	Limit  int
 	Active bool
+	// Params are further query parameters sent with the request, such as a flag Mailgun supports
+	// which has no field of its own yet. Pages after the first are fetched from the links Mailgun
+	// returns, which carry the parameters Mailgun applied. Limit and Active win over
+	// parameters of the same name.
+	Params map[string]string
 }
 
 // List all available templates
 func (mg *MailgunImpl) ListTemplates(opts *ListTemplateOptions) *TemplatesIterator {
-	r := newHTTPRequest(generateApiUrl(mg, templatesEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, templatesEndpoint))
 	if opts != nil {
+		r.addParameters(opts.Params)
 		if opts.Limit != 0 {
-			r.addParameter("limit", strconv.Itoa(opts.Limit))
+			r.setParameter("limit", strconv.Itoa(opts.Limit))
 		}
 		if opts.Active {
-			r.addParameter("active", "yes")
+			r.setParameter("active", "yes")
 		}
 	}
 	url, err := r.generateUrlWithParameters()
@@ -234,9 +233,14 @@ func (ti *TemplatesIterator) Previous(ctx context.Context, items *[]Template) bo
 }
 
 func (ti *TemplatesIterator) fetch(ctx context.Context, url string) error {
-	r := newHTTPRequest(url)
-	r.setClient(ti.mg.Client())
-	r.setBasicAuth(basicAuthUser, ti.mg.APIKey())
+	return fetchPage(ctx, ti.mg, url, &ti.templateListResp)
+}
 
-	return getResponseFromJSON(ctx, r, &ti.templateListResp)
+// checkTemplateSize returns a *ValidationError, without making a request, for content Mailgun
+// would reject as larger than MaxTemplateSize.
+func checkTemplateSize(content string) error {
+	if len(content) > MaxTemplateSize {
+		return newValidationError("template", "%d bytes is over the limit of %d bytes", len(content), MaxTemplateSize)
+	}
+	return nil
 }
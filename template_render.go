@@ -0,0 +1,381 @@
+package mailgun
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// RenderTemplate renders a Handlebars template locally with the given variables, as Mailgun would when
+// sending a message with SetTemplate() and AddTemplateVariable(). Use it to assert on the output of
+// templates in tests before sending real mail. Variable expressions ({{name}}, {{{raw}}}, {{a.b}},
+// {{this}}, {{../parent}}, {{@index}}), comments, and the built-in if, unless, each and with block
+// helpers are supported; custom helpers and partials are not.
+//
+//  html, err := mailgun.RenderTemplate("<p>Hello {{name}}</p>", map[string]interface{}{"name": "Bob"})
+func RenderTemplate(tmpl string, vars map[string]interface{}) (string, error) {
+	nodes, err := parseHandlebars(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := renderHandlebars(&b, nodes, &hbScope{value: vars}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// RenderTemplateVersion fetches a version of a stored template and renders it locally with the
+// given variables. Templates using TemplateEngineGo are rendered with text/template; all others
+// with RenderTemplate(). Pass an empty tag to render the active version.
+func (mg *MailgunImpl) RenderTemplateVersion(ctx context.Context, templateName, tag string, vars map[string]interface{}) (string, error) {
+	var version TemplateVersion
+	if tag == "" {
+		t, err := mg.GetTemplate(ctx, templateName)
+		if err != nil {
+			return "", err
+		}
+		version = t.Version
+	} else {
+		var err error
+		if version, err = mg.GetTemplateVersion(ctx, templateName, tag); err != nil {
+			return "", err
+		}
+	}
+
+	if version.Engine != TemplateEngineGo {
+		return RenderTemplate(version.Template, vars)
+	}
+	t, err := template.New(templateName).Parse(version.Template)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+type hbNode struct {
+	// text is the literal text of a text node, or the path of a variable or block helper
+	text string
+	// isVar is set for {{variable}} expressions, and raw for those which are not escaped
+	isVar bool
+	raw   bool
+	// block is the name of a block helper, which renders body or, for {{else}}, inverse
+	block   string
+	body    []hbNode
+	inverse []hbNode
+}
+
+// parseHandlebars parses the template into a tree of nodes.
+func parseHandlebars(tmpl string) ([]hbNode, error) {
+	p := hbParser{src: tmpl}
+	nodes, end, err := p.parse("")
+	if err != nil {
+		return nil, err
+	}
+	if end != "" {
+		return nil, fmt.Errorf("template: unexpected {{%s}}", end)
+	}
+	return nodes, nil
+}
+
+type hbParser struct {
+	src string
+	pos int
+}
+
+// parse reads nodes until the end of the template, or until an {{else}} or closing tag of the
+// block helper named block. It returns the tag which ended the nodes.
+func (p *hbParser) parse(block string) ([]hbNode, string, error) {
+	var nodes []hbNode
+	for p.pos < len(p.src) {
+		start := strings.Index(p.src[p.pos:], "{{")
+		if start == -1 {
+			nodes = append(nodes, hbNode{text: p.src[p.pos:]})
+			p.pos = len(p.src)
+			break
+		}
+		if start > 0 {
+			nodes = append(nodes, hbNode{text: p.src[p.pos : p.pos+start]})
+		}
+		p.pos += start
+
+		tag, raw, err := p.readTag()
+		if err != nil {
+			return nil, "", err
+		}
+		switch {
+		case strings.HasPrefix(tag, "!"):
+			continue
+		case strings.HasPrefix(tag, "#"):
+			fields := strings.Fields(tag[1:])
+			if len(fields) != 2 {
+				return nil, "", fmt.Errorf("template: {{%s}} requires exactly one argument", tag)
+			}
+			switch fields[0] {
+			case "if", "unless", "each", "with":
+			default:
+				return nil, "", fmt.Errorf("template: unsupported block helper '%s'", fields[0])
+			}
+			n := hbNode{block: fields[0], text: fields[1]}
+			var end string
+			if n.body, end, err = p.parse(fields[0]); err != nil {
+				return nil, "", err
+			}
+			if end == "else" {
+				if n.inverse, end, err = p.parse(fields[0]); err != nil {
+					return nil, "", err
+				}
+			}
+			if end != "/"+fields[0] {
+				return nil, "", fmt.Errorf("template: {{#%s}} is not closed", fields[0])
+			}
+			nodes = append(nodes, n)
+		case tag == "else" || strings.HasPrefix(tag, "/"):
+			if block == "" || (tag != "else" && tag != "/"+block) {
+				return nil, "", fmt.Errorf("template: unexpected {{%s}}", tag)
+			}
+			return nodes, tag, nil
+		default:
+			if len(strings.Fields(tag)) != 1 {
+				return nil, "", fmt.Errorf("template: unsupported helper in {{%s}}", tag)
+			}
+			nodes = append(nodes, hbNode{isVar: true, text: tag, raw: raw})
+		}
+	}
+	if block != "" {
+		return nil, "", fmt.Errorf("template: {{#%s}} is not closed", block)
+	}
+	return nodes, "", nil
+}
+
+// readTag reads the mustache at the current position, returning its trimmed contents and
+// whether it was a triple-stash {{{raw}}} or {{& raw}} expression.
+func (p *hbParser) readTag() (string, bool, error) {
+	open, closing := "{{", "}}"
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "{{{"):
+		open, closing = "{{{", "}}}"
+	case strings.HasPrefix(p.src[p.pos:], "{{!--"):
+		open, closing = "{{", "--}}"
+	}
+	end := strings.Index(p.src[p.pos+len(open):], closing)
+	if end == -1 {
+		return "", false, fmt.Errorf("template: unclosed '%s' at offset %d", open, p.pos)
+	}
+	tag := strings.TrimSpace(p.src[p.pos+len(open) : p.pos+len(open)+end])
+	p.pos += len(open) + end + len(closing)
+
+	raw := open == "{{{"
+	if strings.HasPrefix(tag, "&") {
+		tag, raw = strings.TrimSpace(tag[1:]), true
+	}
+	return tag, raw, nil
+}
+
+// hbScope is a context in which paths are resolved, linked to the enclosing context.
+type hbScope struct {
+	value  interface{}
+	data   map[string]interface{}
+	parent *hbScope
+}
+
+func renderHandlebars(b *strings.Builder, nodes []hbNode, scope *hbScope) error {
+	for _, n := range nodes {
+		switch {
+		case n.isVar:
+			v := scope.lookup(n.text)
+			if v == nil {
+				continue
+			}
+			s := fmt.Sprint(v)
+			if !n.raw {
+				s = hbEscaper.Replace(s)
+			}
+			b.WriteString(s)
+		case n.block != "":
+			if err := renderBlock(b, n, scope); err != nil {
+				return err
+			}
+		default:
+			b.WriteString(n.text)
+		}
+	}
+	return nil
+}
+
+func renderBlock(b *strings.Builder, n hbNode, scope *hbScope) error {
+	v := scope.lookup(n.text)
+	switch n.block {
+	case "if":
+		if hbTruthy(v) {
+			return renderHandlebars(b, n.body, scope)
+		}
+		return renderHandlebars(b, n.inverse, scope)
+	case "unless":
+		if !hbTruthy(v) {
+			return renderHandlebars(b, n.body, scope)
+		}
+		return renderHandlebars(b, n.inverse, scope)
+	case "with":
+		if !hbTruthy(v) {
+			return renderHandlebars(b, n.inverse, scope)
+		}
+		return renderHandlebars(b, n.body, &hbScope{value: v, parent: scope})
+	}
+
+	// each
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Len() == 0 {
+			return renderHandlebars(b, n.inverse, scope)
+		}
+		for i := 0; i < rv.Len(); i++ {
+			item := &hbScope{value: rv.Index(i).Interface(), parent: scope, data: map[string]interface{}{
+				"index": i,
+				"first": i == 0,
+				"last":  i == rv.Len()-1,
+			}}
+			if err := renderHandlebars(b, n.body, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if rv.Len() == 0 {
+			return renderHandlebars(b, n.inverse, scope)
+		}
+		keys := make([]string, 0, rv.Len())
+		values := make(map[string]interface{}, rv.Len())
+		for _, k := range rv.MapKeys() {
+			key := fmt.Sprint(k.Interface())
+			keys = append(keys, key)
+			values[key] = rv.MapIndex(k).Interface()
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			item := &hbScope{value: values[key], parent: scope, data: map[string]interface{}{
+				"key":   key,
+				"index": i,
+				"first": i == 0,
+				"last":  i == len(keys)-1,
+			}}
+			if err := renderHandlebars(b, n.body, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return renderHandlebars(b, n.inverse, scope)
+}
+
+// lookup resolves a path such as `name`, `user.name`, `this`, `../name` or `@index` in the scope.
+// Paths which cannot be resolved return nil, which renders as an empty string.
+func (s *hbScope) lookup(path string) interface{} {
+	for strings.HasPrefix(path, "../") {
+		path = path[3:]
+		if s.parent != nil {
+			s = s.parent
+		}
+	}
+	if strings.HasPrefix(path, "@") {
+		for ; s != nil; s = s.parent {
+			if v, ok := s.data[path[1:]]; ok {
+				return v
+			}
+		}
+		return nil
+	}
+	if path == "this" || path == "." {
+		return s.value
+	}
+	path = strings.TrimPrefix(path, "this.")
+
+	v := s.value
+	for _, part := range strings.Split(path, ".") {
+		v = hbField(v, part)
+		if v == nil {
+			return nil
+		}
+	}
+	return v
+}
+
+// hbField returns the named key of a map, the indexed element of a slice, or the named field of a struct.
+func hbField(v interface{}, name string) interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		f := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !f.IsValid() {
+			return nil
+		}
+		return f.Interface()
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(name)
+		if err != nil || i < 0 || i >= rv.Len() {
+			return nil
+		}
+		return rv.Index(i).Interface()
+	case reflect.Struct:
+		f := rv.FieldByName(name)
+		if !f.IsValid() || !f.CanInterface() {
+			return nil
+		}
+		return f.Interface()
+	}
+	return nil
+}
+
+// hbTruthy reports whether v is truthy in the sense of Handlebars; false, nil, zero,
+// the empty string and empty lists are falsy.
+func hbTruthy(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len() != 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() != 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint() != 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() != 0
+	case reflect.Ptr, reflect.Interface:
+		return !rv.IsNil()
+	}
+	return true
+}
+
+// hbEscaper escapes the same characters as Handlebars.
+var hbEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+	"'", "&#x27;",
+	"`", "&#x60;",
+	"=", "&#x3D;",
+)
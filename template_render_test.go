@@ -0,0 +1,88 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	vars := map[string]interface{}{
+		"name": "Bob & Co",
+		"html": "<b>bold</b>",
+		"user": map[string]interface{}{"plan": "pro", "admin": false},
+		"items": []interface{}{
+			map[string]interface{}{"sku": "A1", "qty": 2},
+			map[string]interface{}{"sku": "B2", "qty": 1},
+		},
+		"tags":  []string{},
+		"attrs": map[string]string{"b": "2", "a": "1"},
+	}
+
+	for _, tt := range []struct {
+		template string
+		output   string
+	}{
+		{`Hello {{name}}`, `Hello Bob &amp; Co`},
+		{`{{{html}}} {{&html}} {{html}}`, `<b>bold</b> <b>bold</b> &lt;b&gt;bold&lt;/b&gt;`},
+		{`{{user.plan}}{{missing}}{{user.missing.deeper}}`, `pro`},
+		{`{{#if user.admin}}admin{{else}}member{{/if}}`, `member`},
+		{`{{#unless user.admin}}not admin{{/unless}}`, `not admin`},
+		{`{{#with user}}{{plan}} for {{../name}}{{/with}}`, `pro for Bob &amp; Co`},
+		{`{{#each items}}{{@index}}:{{sku}}x{{qty}}{{#unless @last}}, {{/unless}}{{/each}}`, `0:A1x2, 1:B2x1`},
+		{`{{#each tags}}{{this}}{{else}}no tags{{/each}}`, `no tags`},
+		{`{{#each attrs}}{{@key}}={{this}};{{/each}}`, `a=1;b=2;`},
+		{`a{{! comment }}b{{!-- {{name}} --}}c`, `abc`},
+	} {
+		out, err := mailgun.RenderTemplate(tt.template, vars)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, out, tt.output)
+	}
+
+	for _, tmpl := range []string{
+		`{{name`,
+		`{{#if name}}unclosed`,
+		`{{#each}}{{/each}}`,
+		`{{#if name}}{{/each}}`,
+		`{{/if}}`,
+		`{{#custom name}}{{/custom}}`,
+		`{{format name}}`,
+	} {
+		_, err := mailgun.RenderTemplate(tmpl, vars)
+		ensure.NotNil(t, err)
+	}
+}
+
+func TestRenderTemplateVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/" + testDomain + "/templates/welcome/versions/v1":
+			fmt.Fprint(w, `{"template": {"name": "welcome", "version": {"tag": "v1", "engine": "handlebars", "template": "<p>Hi {{name}}</p>"}}}`)
+		case "/v3/" + testDomain + "/templates/welcome":
+			fmt.Fprint(w, `{"template": {"name": "welcome", "version": {"tag": "v2", "engine": "go", "template": "<p>Hello {{.name}}</p>"}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "not found"}`)
+		}
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	ctx := context.Background()
+	vars := map[string]interface{}{"name": "Bob"}
+
+	out, err := mg.RenderTemplateVersion(ctx, "welcome", "v1", vars)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, out, "<p>Hi Bob</p>")
+
+	out, err = mg.RenderTemplateVersion(ctx, "welcome", "", vars)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, out, "<p>Hello Bob</p>")
+}
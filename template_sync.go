@@ -0,0 +1,333 @@
+package mailgun
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TemplateSyncAction is a change `SyncTemplates()` makes to the templates stored by Mailgun.
+type TemplateSyncAction string
+
+const (
+	// TemplateSyncCreate creates a template, with its first version.
+	TemplateSyncCreate TemplateSyncAction = "create"
+	// TemplateSyncDescribe updates the description of a template.
+	TemplateSyncDescribe TemplateSyncAction = "describe"
+	// TemplateSyncAddVersion adds a version to a template.
+	TemplateSyncAddVersion TemplateSyncAction = "add-version"
+	// TemplateSyncUpdateVersion updates the content, comment or activity of a version.
+	TemplateSyncUpdateVersion TemplateSyncAction = "update-version"
+	// TemplateSyncDeleteVersion deletes a version which has no file.
+	TemplateSyncDeleteVersion TemplateSyncAction = "delete-version"
+)
+
+// TemplateChange is a change made, or to be made in a dry run, by `SyncTemplates()`.
+type TemplateChange struct {
+	Action   TemplateSyncAction
+	Template string
+	// Tag is the tag of the version changed; it is the tag of the first version of a template
+	// which is created, and empty when the description of a template is updated.
+	Tag string
+	// File is the file the change comes from, or "" for deleted versions.
+	File string
+}
+
+// SyncTemplatesOptions modifies how `SyncTemplates()` syncs templates.
+type SyncTemplatesOptions struct {
+	// DryRun returns the changes which would be made without making them.
+	DryRun bool
+	// Pattern selects the files of the directory which hold templates; defaults to "*", every file
+	// except those whose name starts with a dot.
+	Pattern string
+}
+
+// localTemplateVersion is a version of a template read from a file.
+type localTemplateVersion struct {
+	file        string
+	name        string
+	description string
+	version     TemplateVersion
+}
+
+// SyncTemplates makes the templates stored by Mailgun match the template files in dir, so that
+// templates can be kept in version control and deployed with the rest of your code. Each file
+// holds a version of a template, and starts with a front matter block naming it:
+//
+//  ---
+//  name: welcome
+//  tag: v2
+//  engine: handlebars
+//  comment: Shorter subject line
+//  description: Sent when an account is created
+//  active: true
+//  ---
+//  <p>Welcome, {{name}}!</p>
+//
+// Only tag is required; name defaults to the file name without its extension. Templates which do
+// not exist are created. Versions with no stored counterpart are added, and those whose content,
+// comment or activity differ are updated. Stored versions of the templates in dir which have no
+// file are deleted, while templates with no file at all are left alone. A version is made active
+// only if its file says so, and the engine of a stored version is never changed.
+//
+// The changes made are returned, in order, including when an error stops the sync part way.
+//
+//  changes, err := mg.SyncTemplates(ctx, "templates", &mailgun.SyncTemplatesOptions{DryRun: true})
+//  for _, c := range changes {
+//    fmt.Println(c.Action, c.Template, c.Tag)
+//  }
+func (mg *MailgunImpl) SyncTemplates(ctx context.Context, dir string, opts *SyncTemplatesOptions) ([]TemplateChange, error) {
+	if opts == nil {
+		opts = &SyncTemplatesOptions{}
+	}
+	templates, err := readTemplateFiles(dir, opts.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changes []TemplateChange
+	for _, name := range names {
+		if changes, err = mg.syncTemplate(ctx, templates[name], opts.DryRun, changes); err != nil {
+			return changes, errors.Wrapf(err, "while syncing template '%s'", name)
+		}
+	}
+	return changes, nil
+}
+
+// syncTemplate syncs the versions of one template, read from its files, appending the changes it
+// makes to changes.
+func (mg *MailgunImpl) syncTemplate(ctx context.Context, local []localTemplateVersion, dryRun bool, changes []TemplateChange) ([]TemplateChange, error) {
+	name := local[0].name
+	if len(local) > MaxTemplateVersions {
+		return changes, newValidationError("versions", "%d versions of '%s' are over the limit of %d", len(local), name, MaxTemplateVersions)
+	}
+	var description string
+	for _, v := range local {
+		if v.description != "" {
+			description = v.description
+		}
+	}
+
+	stored, err := mg.GetTemplate(ctx, name)
+	if GetStatusFromErr(err) == http.StatusNotFound {
+		// The first version of a new template becomes active, so create it with the active one
+		sort.SliceStable(local, func(i, j int) bool { return local[i].version.Active && !local[j].version.Active })
+		first := local[0]
+		changes = append(changes, TemplateChange{Action: TemplateSyncCreate, Template: name, Tag: first.version.Tag, File: first.file})
+		if !dryRun {
+			t := Template{Name: name, Description: description, Version: first.version}
+			if err := mg.CreateTemplate(ctx, &t); err != nil {
+				return changes, err
+			}
+		}
+		for _, v := range local[1:] {
+			if changes, err = mg.addTemplateVersion(ctx, v, dryRun, changes); err != nil {
+				return changes, err
+			}
+		}
+		return changes, nil
+	}
+	if err != nil {
+		return changes, err
+	}
+
+	if description != "" && description != stored.Description {
+		changes = append(changes, TemplateChange{Action: TemplateSyncDescribe, Template: name, File: local[0].file})
+		if !dryRun {
+			if err := mg.UpdateTemplate(ctx, &Template{Name: name, Description: description}); err != nil {
+				return changes, err
+			}
+		}
+	}
+
+	remote := map[string]bool{}
+	var page []TemplateVersion
+	it := mg.ListTemplateVersions(name, &ListOptions{Limit: 100})
+	err = walkPages(ctx, mg.bulkLimiter(), func(ctx context.Context) bool {
+		if !it.Next(ctx, &page) {
+			return false
+		}
+		for _, v := range page {
+			remote[v.Tag] = true
+		}
+		return true
+	}, &it.err)
+	if err != nil {
+		return changes, err
+	}
+
+	for _, v := range local {
+		if !remote[v.version.Tag] {
+			if changes, err = mg.addTemplateVersion(ctx, v, dryRun, changes); err != nil {
+				return changes, err
+			}
+			continue
+		}
+		delete(remote, v.version.Tag)
+		current, err := mg.GetTemplateVersion(ctx, name, v.version.Tag)
+		if err != nil {
+			return changes, err
+		}
+		if current.Template == v.version.Template && current.Comment == v.version.Comment &&
+			(current.Active || !v.version.Active) {
+			continue
+		}
+		changes = append(changes, TemplateChange{Action: TemplateSyncUpdateVersion, Template: name, Tag: v.version.Tag, File: v.file})
+		if !dryRun {
+			version := v.version
+			if err := mg.UpdateTemplateVersion(ctx, name, &version); err != nil {
+				return changes, err
+			}
+		}
+	}
+
+	// Versions are deleted last, once the version replacing a deleted active one is active
+	tags := make([]string, 0, len(remote))
+	for tag := range remote {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		changes = append(changes, TemplateChange{Action: TemplateSyncDeleteVersion, Template: name, Tag: tag})
+		if !dryRun {
+			if err := mg.DeleteTemplateVersion(ctx, name, tag); err != nil {
+				return changes, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+func (mg *MailgunImpl) addTemplateVersion(ctx context.Context, v localTemplateVersion, dryRun bool, changes []TemplateChange) ([]TemplateChange, error) {
+	changes = append(changes, TemplateChange{Action: TemplateSyncAddVersion, Template: v.name, Tag: v.version.Tag, File: v.file})
+	if dryRun {
+		return changes, nil
+	}
+	version := v.version
+	return changes, mg.AddTemplateVersion(ctx, v.name, &version)
+}
+
+// readTemplateFiles reads the template files of dir matching pattern, keyed by template name.
+func readTemplateFiles(dir, pattern string) (map[string][]localTemplateVersion, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	files, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	templates := map[string][]localTemplateVersion{}
+	for _, file := range files {
+		base := filepath.Base(file)
+		if strings.HasPrefix(base, ".") {
+			continue
+		}
+		if info, err := os.Stat(file); err != nil {
+			return nil, err
+		} else if info.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		v, err := parseTemplateFile(file, data)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range templates[v.name] {
+			if other.version.Tag == v.version.Tag {
+				return nil, fmt.Errorf("%s: version '%s' of template '%s' is also in %s", file, v.version.Tag, v.name, other.file)
+			}
+			if other.version.Active && v.version.Active {
+				return nil, fmt.Errorf("%s: template '%s' has another active version in %s", file, v.name, other.file)
+			}
+		}
+		templates[v.name] = append(templates[v.name], v)
+	}
+	return templates, nil
+}
+
+// parseTemplateFile parses the front matter and content of a template file.
+func parseTemplateFile(file string, data []byte) (localTemplateVersion, error) {
+	v := localTemplateVersion{file: file}
+	line, offset := 0, 0
+	closed := false
+	for offset < len(data) {
+		end := bytes.IndexByte(data[offset:], '\n')
+		if end < 0 {
+			end = len(data) - offset
+		}
+		text := strings.TrimRight(string(data[offset:offset+end]), "\r")
+		line++
+		offset += end + 1
+		if line == 1 {
+			if text != "---" {
+				return v, fmt.Errorf("%s: the file does not start with a front matter block", file)
+			}
+			continue
+		}
+		if text == "---" {
+			closed = true
+			break
+		}
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(strings.TrimSpace(text), "#") {
+			continue
+		}
+		colon := strings.Index(text, ":")
+		if colon < 0 {
+			return v, fmt.Errorf("%s:%d: expected 'key: value'", file, line)
+		}
+		key := strings.ToLower(strings.TrimSpace(text[:colon]))
+		value := strings.Trim(strings.TrimSpace(text[colon+1:]), `"'`)
+		switch key {
+		case "name":
+			v.name = value
+		case "tag":
+			v.version.Tag = value
+		case "engine":
+			v.version.Engine = TemplateEngine(value)
+		case "comment":
+			v.version.Comment = value
+		case "description":
+			v.description = value
+		case "active":
+			active, err := strconv.ParseBool(value)
+			if err != nil {
+				return v, fmt.Errorf("%s:%d: active must be true or false", file, line)
+			}
+			v.version.Active = active
+		default:
+			return v, fmt.Errorf("%s:%d: unknown front matter key '%s'", file, line, key)
+		}
+	}
+	if !closed {
+		return v, fmt.Errorf("%s: the front matter block is not closed with '---'", file)
+	}
+	if v.version.Tag == "" {
+		return v, fmt.Errorf("%s: the front matter has no tag", file)
+	}
+	if v.name == "" {
+		v.name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if offset < len(data) {
+		v.version.Template = string(data[offset:])
+	}
+	return v, nil
+}
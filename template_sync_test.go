@@ -0,0 +1,164 @@
+package mailgun_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+// templateServer stores templates as the templates API does.
+type templateServer struct {
+	descriptions map[string]string
+	versions     map[string]map[string]*mailgun.TemplateVersion
+	requests     []string
+}
+
+func (s *templateServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v3/"+testDomain+"/templates"), "/")
+	if r.Method != http.MethodGet {
+		s.requests = append(s.requests, r.Method+" "+strings.Join(parts, "/"))
+	}
+	var name, tag string
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	if len(parts) > 3 {
+		tag = parts[3]
+	}
+	version := func() mailgun.TemplateVersion {
+		return mailgun.TemplateVersion{Tag: r.FormValue("tag"), Template: r.FormValue("template"), Comment: r.FormValue("comment"), Active: r.FormValue("active") == "yes"}
+	}
+	activate := func(tag string) {
+		for _, v := range s.versions[name] {
+			v.Active = v.Tag == tag
+		}
+	}
+	reply := func(v interface{}) { json.NewEncoder(w).Encode(v) }
+
+	if name != "" && s.versions[name] == nil {
+		w.WriteHeader(http.StatusNotFound)
+		reply(map[string]string{"message": "template not found"})
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && name == "":
+		v := version()
+		v.Active = true
+		name = r.FormValue("name")
+		s.descriptions[name] = r.FormValue("description")
+		s.versions[name] = map[string]*mailgun.TemplateVersion{v.Tag: &v}
+		reply(map[string]interface{}{"template": map[string]interface{}{"name": name}})
+	case r.Method == http.MethodGet && len(parts) == 2:
+		reply(map[string]interface{}{"template": map[string]interface{}{"name": name, "description": s.descriptions[name]}})
+	case r.Method == http.MethodPut && len(parts) == 2:
+		s.descriptions[name] = r.FormValue("description")
+		reply(map[string]interface{}{"template": map[string]interface{}{"name": name}})
+	case r.Method == http.MethodGet && len(parts) == 3:
+		var versions []mailgun.TemplateVersion
+		if r.FormValue("page") == "" {
+			for _, v := range s.versions[name] {
+				versions = append(versions, mailgun.TemplateVersion{Tag: v.Tag, Active: v.Active})
+			}
+		}
+		reply(map[string]interface{}{
+			"template": map[string]interface{}{"name": name, "versions": versions},
+			"paging":   map[string]string{"next": "https://api.mailgun.net/v3/" + testDomain + "/templates/" + name + "/versions?page=next"},
+		})
+	case r.Method == http.MethodPost && len(parts) == 3:
+		v := version()
+		s.versions[name][v.Tag] = &v
+		if v.Active {
+			activate(v.Tag)
+		}
+		reply(map[string]interface{}{"template": map[string]interface{}{"name": name, "version": v}})
+	case r.Method == http.MethodGet && len(parts) == 4:
+		reply(map[string]interface{}{"template": map[string]interface{}{"name": name, "version": s.versions[name][tag]}})
+	case r.Method == http.MethodPut && len(parts) == 4:
+		v := s.versions[name][tag]
+		v.Template, v.Comment = r.FormValue("template"), r.FormValue("comment")
+		if r.FormValue("active") == "yes" {
+			activate(tag)
+		}
+		reply(map[string]interface{}{"template": map[string]interface{}{"name": name, "version": v}})
+	case r.Method == http.MethodDelete && len(parts) == 4:
+		delete(s.versions[name], tag)
+		reply(map[string]string{"message": "deleted"})
+	}
+}
+
+func TestSyncTemplates(t *testing.T) {
+	s := &templateServer{descriptions: map[string]string{}, versions: map[string]map[string]*mailgun.TemplateVersion{}}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "templates")
+	ensure.Nil(t, err)
+	defer os.RemoveAll(dir)
+	write := func(file, content string) {
+		ensure.Nil(t, ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0600))
+	}
+	write("welcome-v1.html", "---\nname: welcome\ntag: v1\ndescription: Sent on sign up\n---\n<p>Hi</p>\n")
+	write("welcome-v2.html", "---\nname: welcome\ntag: v2\nactive: true\n---\n<p>Hello</p>\n")
+	write("receipt.html", "---\r\ntag: v1\r\ncomment: First\r\n---\r\n<p>Thanks</p>\r\n")
+	write(".draft.html", "not a template")
+
+	// A dry run makes no changes
+	changes, err := mg.SyncTemplates(ctx, dir, &mailgun.SyncTemplatesOptions{DryRun: true})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, changes, []mailgun.TemplateChange{
+		{Action: mailgun.TemplateSyncCreate, Template: "receipt", Tag: "v1", File: filepath.Join(dir, "receipt.html")},
+		{Action: mailgun.TemplateSyncCreate, Template: "welcome", Tag: "v2", File: filepath.Join(dir, "welcome-v2.html")},
+		{Action: mailgun.TemplateSyncAddVersion, Template: "welcome", Tag: "v1", File: filepath.Join(dir, "welcome-v1.html")},
+	})
+	ensure.DeepEqual(t, len(s.requests), 0)
+
+	_, err = mg.SyncTemplates(ctx, dir, nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, s.descriptions["welcome"], "Sent on sign up")
+	ensure.DeepEqual(t, s.versions["receipt"]["v1"].Template, "<p>Thanks</p>\r\n")
+	ensure.DeepEqual(t, s.versions["receipt"]["v1"].Comment, "First")
+	ensure.DeepEqual(t, s.versions["welcome"]["v1"].Template, "<p>Hi</p>\n")
+	ensure.True(t, s.versions["welcome"]["v2"].Active)
+
+	// Syncing again changes nothing
+	s.requests = nil
+	changes, err = mg.SyncTemplates(ctx, dir, nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(changes), 0)
+	ensure.DeepEqual(t, len(s.requests), 0)
+
+	// Edited files update their version, and versions without a file are deleted
+	ensure.Nil(t, os.Remove(filepath.Join(dir, "welcome-v1.html")))
+	write("welcome-v2.html", "---\nname: welcome\ntag: v2\nactive: true\n---\n<p>Hello again</p>\n")
+	write("welcome-v3.html", "---\nname: welcome\ntag: v3\n---\n<p>Hey</p>\n")
+	changes, err = mg.SyncTemplates(ctx, dir, nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, changes, []mailgun.TemplateChange{
+		{Action: mailgun.TemplateSyncUpdateVersion, Template: "welcome", Tag: "v2", File: filepath.Join(dir, "welcome-v2.html")},
+		{Action: mailgun.TemplateSyncAddVersion, Template: "welcome", Tag: "v3", File: filepath.Join(dir, "welcome-v3.html")},
+		{Action: mailgun.TemplateSyncDeleteVersion, Template: "welcome", Tag: "v1"},
+	})
+	ensure.DeepEqual(t, s.versions["welcome"]["v2"].Template, "<p>Hello again</p>\n")
+	ensure.DeepEqual(t, len(s.versions["welcome"]), 2)
+
+	// Files without a tag are refused before anything is changed
+	write("broken.html", "---\nname: broken\n---\n")
+	s.requests = nil
+	_, err = mg.SyncTemplates(ctx, dir, nil)
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "the front matter has no tag")
+	ensure.DeepEqual(t, len(s.requests), 0)
+}
@@ -24,11 +24,12 @@ type templateVersionListResp struct {
 
 // AddTemplateVersion adds a template version to a template
 func (mg *MailgunImpl) AddTemplateVersion(ctx context.Context, templateName string, version *TemplateVersion) error {
-	r := newHTTPRequest(generateApiUrl(mg, templatesEndpoint) + "/" + templateName + "/versions")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	if err := checkTemplateSize(version.Template); err != nil {
+		return err
+	}
+	r := newAPIRequest(mg, generateApiUrl(mg, templatesEndpoint)+"/"+pathEscape(templateName)+"/versions")
 
-	payload := newUrlEncodedPayload()
+	payload := newPayload()
 	payload.addValue("template", version.Template)
 
 	if version.Tag != "" {
@@ -54,9 +55,7 @@ func (mg *MailgunImpl) AddTemplateVersion(ctx context.Context, templateName stri
 
 // GetTemplateVersion gets a specific version of a template
 func (mg *MailgunImpl) GetTemplateVersion(ctx context.Context, templateName, tag string) (TemplateVersion, error) {
-	r := newHTTPRequest(generateApiUrl(mg, templatesEndpoint) + "/" + templateName + "/versions/" + tag)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, templatesEndpoint)+"/"+pathEscape(templateName)+"/versions/"+pathEscape(tag))
 
 	var resp templateResp
 	err := getResponseFromJSON(ctx, r, &resp)
@@ -68,10 +67,11 @@ func (mg *MailgunImpl) GetTemplateVersion(ctx context.Context, templateName, tag
 
 // Update the comment and mark a version of a template active
 func (mg *MailgunImpl) UpdateTemplateVersion(ctx context.Context, templateName string, version *TemplateVersion) error {
-	r := newHTTPRequest(generateApiUrl(mg, templatesEndpoint) + "/" + templateName + "/versions/" + version.Tag)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	p := newUrlEncodedPayload()
+	if err := checkTemplateSize(version.Template); err != nil {
+		return err
+	}
+	r := newAPIRequest(mg, generateApiUrl(mg, templatesEndpoint)+"/"+pathEscape(templateName)+"/versions/"+pathEscape(version.Tag))
+	p := newPayload()
 
 	if version.Comment != "" {
 		p.addValue("comment", version.Comment)
@@ -94,9 +94,7 @@ func (mg *MailgunImpl) UpdateTemplateVersion(ctx context.Context, templateName s
 
 // Delete a specific version of a template
 func (mg *MailgunImpl) DeleteTemplateVersion(ctx context.Context, templateName, tag string) error {
-	r := newHTTPRequest(generateApiUrl(mg, templatesEndpoint) + "/" + templateName + "/versions/" + tag)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, templatesEndpoint)+"/"+pathEscape(templateName)+"/versions/"+pathEscape(tag))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
@@ -109,12 +107,11 @@ type TemplateVersionsIterator struct {
 
 // List all the versions of a specific template
 func (mg *MailgunImpl) ListTemplateVersions(templateName string, opts *ListOptions) *TemplateVersionsIterator {
-	r := newHTTPRequest(generateApiUrl(mg, templatesEndpoint) + "/" + templateName + "/versions")
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, templatesEndpoint)+"/"+pathEscape(templateName)+"/versions")
 	if opts != nil {
+		r.addParameters(opts.Params)
 		if opts.Limit != 0 {
-			r.addParameter("limit", strconv.Itoa(opts.Limit))
+			r.setParameter("limit", strconv.Itoa(opts.Limit))
 		}
 	}
 	url, err := r.generateUrlWithParameters()
@@ -209,9 +206,5 @@ func (li *TemplateVersionsIterator) Previous(ctx context.Context, items *[]Templ
 }
 
 func (li *TemplateVersionsIterator) fetch(ctx context.Context, url string) error {
-	r := newHTTPRequest(url)
-	r.setClient(li.mg.Client())
-	r.setBasicAuth(basicAuthUser, li.mg.APIKey())
-
-	return getResponseFromJSON(ctx, r, &li.templateVersionListResp)
+	return fetchPage(ctx, li.mg, url, &li.templateVersionListResp)
 }
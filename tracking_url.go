@@ -0,0 +1,157 @@
+package mailgun
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Kinds of tracking URLs, as reported by `ParseTrackingURL()`.
+const (
+	TrackingClick = "click"
+	TrackingOpen  = "open"
+)
+
+// ErrNoTrackingTarget is returned by `UnwrapTrackingURL()` for a tracking URL which carries no
+// target, such as the pixel of open tracking.
+var ErrNoTrackingTarget = errors.New("tracking URL has no target")
+
+// trackingTargetKeys are the keys of a tracking payload which may hold the target of the link.
+var trackingTargetKeys = []string{"url", "u", "l", "link", "target"}
+
+// TrackingURL is a URL rewritten by Mailgun for click or open tracking.
+type TrackingURL struct {
+	// Kind is TrackingClick or TrackingOpen.
+	Kind string
+	// Host is the tracking host of the sending domain, such as "email.example.com".
+	Host string
+	// Target is the URL a click link redirects to; empty for open tracking.
+	Target string
+}
+
+// ParseTrackingURL decodes a URL Mailgun rewrote for click or open tracking, as found in the body
+// of a delivered message or in the url of a clicked event. ok is false if raw is not such a URL.
+//
+// Tracking URLs have the form https://<web prefix>.<domain>/c/<token> for clicks and
+// /o/<token> for opens, where the token is a compressed payload. The format of the payload is
+// not documented by Mailgun, so decoding is best effort: a URL is only reported as a tracking
+// URL if its token decodes, whatever its web prefix, and may stop being recognized if Mailgun
+// changes the format.
+func ParseTrackingURL(raw string) (t TrackingURL, ok bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return t, false
+	}
+	parts := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	if len(parts) != 2 || (parts[0] != "c" && parts[0] != "o") {
+		return t, false
+	}
+	payload, ok := decodeTrackingToken(parts[1])
+	if !ok {
+		return t, false
+	}
+
+	t.Host = u.Hostname()
+	if parts[0] == "o" {
+		t.Kind = TrackingOpen
+		return t, true
+	}
+	t.Kind = TrackingClick
+	t.Target = trackingTarget(payload)
+	return t, true
+}
+
+// IsTrackingURL reports whether raw is a URL Mailgun rewrote for click or open tracking; see
+// `ParseTrackingURL()`.
+func IsTrackingURL(raw string) bool {
+	_, ok := ParseTrackingURL(raw)
+	return ok
+}
+
+// UnwrapTrackingURL returns the URL a click tracking link redirects to, for log analysis and
+// support tooling. A URL which is not a tracking URL is returned unchanged, so every link of a
+// message can be passed through it. ErrNoTrackingTarget is returned for an open tracking pixel,
+// or a click link whose target could not be found in its payload.
+//
+//  target, err := mailgun.UnwrapTrackingURL(clicked.Url)
+func UnwrapTrackingURL(raw string) (string, error) {
+	t, ok := ParseTrackingURL(raw)
+	if !ok {
+		return raw, nil
+	}
+	if t.Target == "" {
+		return "", ErrNoTrackingTarget
+	}
+	return t.Target, nil
+}
+
+// decodeTrackingToken decodes the token of a tracking URL, base64 of a payload which is usually
+// compressed with zlib, into its JSON object.
+func decodeTrackingToken(token string) (map[string]interface{}, bool) {
+	token, err := url.PathUnescape(token)
+	if err != nil {
+		return nil, false
+	}
+	var b []byte
+	for _, enc := range []*base64.Encoding{base64.URLEncoding, base64.RawURLEncoding, base64.StdEncoding, base64.RawStdEncoding} {
+		if b, err = enc.DecodeString(token); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, false
+	}
+	if zr, err := zlib.NewReader(bytes.NewReader(b)); err == nil {
+		b, err = ioutil.ReadAll(zr)
+		if err != nil {
+			return nil, false
+		}
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return nil, false
+	}
+	return payload, true
+}
+
+// trackingTarget finds the target of a click in its payload. The payload may wrap another JSON
+// object in a string, which is searched as well.
+func trackingTarget(payload map[string]interface{}) string {
+	for _, key := range trackingTargetKeys {
+		if s, ok := payload[key].(string); ok && isAbsoluteURL(s) {
+			return s
+		}
+	}
+	keys := make([]string, 0, len(payload))
+	for key := range payload {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch v := payload[key].(type) {
+		case map[string]interface{}:
+			if target := trackingTarget(v); target != "" {
+				return target
+			}
+		case string:
+			var nested map[string]interface{}
+			if strings.HasPrefix(v, "{") && json.Unmarshal([]byte(v), &nested) == nil {
+				if target := trackingTarget(nested); target != "" {
+					return target
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func isAbsoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != "")
+}
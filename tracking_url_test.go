@@ -0,0 +1,59 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func trackingToken(payload string) string {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write([]byte(payload))
+	zw.Close()
+	return base64.URLEncoding.EncodeToString(b.Bytes())
+}
+
+func TestParseTrackingURL(t *testing.T) {
+	click := "https://email.mailgun.test/c/" + trackingToken(`{"h": "4f2a", "p": "{\"v\": 1, \"url\": \"https://example.com/offer?id=7\"}"}`)
+	tracked, ok := mailgun.ParseTrackingURL(click)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, tracked, mailgun.TrackingURL{
+		Kind:   mailgun.TrackingClick,
+		Host:   "email.mailgun.test",
+		Target: "https://example.com/offer?id=7",
+	})
+	target, err := mailgun.UnwrapTrackingURL(click)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, target, "https://example.com/offer?id=7")
+
+	// Uncompressed payloads with a custom web prefix
+	custom := "http://track.mailgun.test/c/" + base64.RawURLEncoding.EncodeToString([]byte(`{"l": "https://example.com/"}`))
+	target, err = mailgun.UnwrapTrackingURL(custom)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, target, "https://example.com/")
+
+	open := "https://email.mailgun.test/o/" + trackingToken(`{"h": "4f2a", "p": "{\"v\": 1}"}`)
+	tracked, ok = mailgun.ParseTrackingURL(open)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, tracked.Kind, mailgun.TrackingOpen)
+	_, err = mailgun.UnwrapTrackingURL(open)
+	ensure.DeepEqual(t, err, mailgun.ErrNoTrackingTarget)
+
+	// URLs which merely look like tracking URLs are left alone
+	for _, u := range []string{
+		"https://www.youtube.com/c/SomeChannel",
+		"https://example.com/offer?id=7",
+		"mailto:c@example.com",
+		"https://email.mailgun.test/c/",
+	} {
+		ensure.False(t, mailgun.IsTrackingURL(u))
+		target, err := mailgun.UnwrapTrackingURL(u)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, target, u)
+	}
+}
@@ -0,0 +1,323 @@
+package mailgun
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout is the overall request timeout of clients created by NewHTTPClient().
+const DefaultTimeout = 60 * time.Second
+
+// TransportOption configures the HTTP client created by NewHTTPClient().
+type TransportOption func(*transportConfig) error
+
+type transportConfig struct {
+	transport    *http.Transport
+	roundTripper http.RoundTripper
+	dialer       *net.Dialer
+	timeout      time.Duration
+}
+
+// NewHTTPClient returns an *http.Client suitable for SetClient(), tuned for sending large
+// volumes of mail through a single shared client. The transport starts as a clone of
+// http.DefaultTransport, so the standard dial, TLS handshake and idle timeouts apply unless
+// overridden by one of the options.
+//
+//  client, err := mailgun.NewHTTPClient(
+//    mailgun.WithMaxIdleConnsPerHost(100),
+//    mailgun.WithKeepAlive(time.Minute),
+//  )
+//  if err != nil {
+//    log.Fatal(err)
+//  }
+//  mg.SetClient(client)
+func NewHTTPClient(opts ...TransportOption) (*http.Client, error) {
+	client := &http.Client{Timeout: DefaultTimeout}
+	if err := applyTransportOptions(client, opts); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// ConfigureTransport replaces the transport of this client's HTTP client with one built from opts,
+// as NewHTTPClient() would. The Timeout, CheckRedirect and Jar of the current HTTP client are kept,
+// and DefaultTimeout is used if the current HTTP client has no timeout. The current HTTP client
+// itself is not modified, so it is safe to call this on a client created with http.DefaultClient.
+//
+//  // Route all API calls through the corporate proxy
+//  err := mg.ConfigureTransport(mailgun.WithProxy("http://proxy.corp:3128"))
+func (mg *MailgunImpl) ConfigureTransport(opts ...TransportOption) error {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+
+	client := &http.Client{Timeout: DefaultTimeout}
+	if mg.client != nil {
+		client.CheckRedirect = mg.client.CheckRedirect
+		client.Jar = mg.client.Jar
+		if mg.client.Timeout != 0 {
+			client.Timeout = mg.client.Timeout
+		}
+	}
+	if err := applyTransportOptions(client, opts); err != nil {
+		return err
+	}
+	mg.client = client
+	return nil
+}
+
+func applyTransportOptions(client *http.Client, opts []TransportOption) error {
+	cfg := transportConfig{
+		transport: http.DefaultTransport.(*http.Transport).Clone(),
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+		timeout: client.Timeout,
+	}
+	cfg.transport.DialContext = cfg.dialer.DialContext
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return err
+		}
+	}
+	client.Timeout = cfg.timeout
+
+	if cfg.roundTripper != nil {
+		client.Transport = cfg.roundTripper
+		return nil
+	}
+	client.Transport = cfg.transport
+	return nil
+}
+
+// WithTimeout sets the overall time limit for requests, including reading the response body.
+// A timeout of zero means no timeout.
+func WithTimeout(d time.Duration) TransportOption {
+	return func(c *transportConfig) error {
+		c.timeout = d
+		return nil
+	}
+}
+
+// WithMaxIdleConns limits the number of idle (keep-alive) connections across all hosts.
+func WithMaxIdleConns(n int) TransportOption {
+	return func(c *transportConfig) error {
+		c.transport.MaxIdleConns = n
+		return nil
+	}
+}
+
+// WithMaxIdleConnsPerHost limits the number of idle (keep-alive) connections kept to the Mailgun API.
+// The net/http default of 2 is too low for clients sending from many goroutines.
+func WithMaxIdleConnsPerHost(n int) TransportOption {
+	return func(c *transportConfig) error {
+		c.transport.MaxIdleConnsPerHost = n
+		return nil
+	}
+}
+
+// WithMaxConnsPerHost limits the total number of connections, including those in use, to the Mailgun API.
+func WithMaxConnsPerHost(n int) TransportOption {
+	return func(c *transportConfig) error {
+		c.transport.MaxConnsPerHost = n
+		return nil
+	}
+}
+
+// WithIdleConnTimeout sets how long an idle connection remains in the pool before it is closed.
+func WithIdleConnTimeout(d time.Duration) TransportOption {
+	return func(c *transportConfig) error {
+		c.transport.IdleConnTimeout = d
+		return nil
+	}
+}
+
+// WithKeepAlive sets the TCP keep-alive period of connections to the Mailgun API.
+// A negative value disables keep-alive probes.
+func WithKeepAlive(d time.Duration) TransportOption {
+	return func(c *transportConfig) error {
+		c.dialer.KeepAlive = d
+		c.transport.DialContext = c.dialer.DialContext
+		return nil
+	}
+}
+
+// WithProxyFunc sets the function which selects the proxy for each request.
+// Use http.ProxyFromEnvironment to honor HTTP_PROXY, HTTPS_PROXY and NO_PROXY (the default).
+func WithProxyFunc(proxy func(*http.Request) (*url.URL, error)) TransportOption {
+	return func(c *transportConfig) error {
+		c.transport.Proxy = proxy
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for connections to the Mailgun API.
+func WithTLSConfig(cfg *tls.Config) TransportOption {
+	return func(c *transportConfig) error {
+		c.transport.TLSClientConfig = cfg
+		return nil
+	}
+}
+
+// WithClientTLS presents the client certificate in the PEM files certFile and keyFile to the
+// servers the client connects to, for egress through a proxy which terminates mutual TLS. If caFile
+// is not empty, the certificates of servers are verified against the PEM certificates it holds
+// instead of the system roots. The TLS configuration set by WithTLSConfig() is kept, so apply
+// WithClientTLS() after it.
+//
+//  client, err := mailgun.NewHTTPClient(
+//    mailgun.WithClientTLS("/etc/egress/client.crt", "/etc/egress/client.key", "/etc/egress/ca.crt"),
+//  )
+func WithClientTLS(certFile, keyFile, caFile string) TransportOption {
+	return func(c *transportConfig) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return errors.Wrap(err, "while loading client certificate")
+		}
+		cfg := &tls.Config{}
+		if c.transport.TLSClientConfig != nil {
+			cfg = c.transport.TLSClientConfig.Clone()
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+		if caFile != "" {
+			pem, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				return errors.Wrap(err, "while reading CA certificates")
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no PEM certificates found in '%s'", caFile)
+			}
+			cfg.RootCAs = pool
+		}
+		c.transport.TLSClientConfig = cfg
+		return nil
+	}
+}
+
+// WithMinTLSVersion refuses connections negotiating a TLS version older than version, such as
+// tls.VersionTLS12 or tls.VersionTLS13. The TLS configuration set by WithTLSConfig() is kept, so
+// apply WithMinTLSVersion() after it.
+func WithMinTLSVersion(version uint16) TransportOption {
+	return func(c *transportConfig) error {
+		if version < tls.VersionTLS10 || version > tls.VersionTLS13 {
+			return fmt.Errorf("unknown TLS version 0x%04x", version)
+		}
+		cfg := cloneTLSConfig(c.transport.TLSClientConfig)
+		cfg.MinVersion = version
+		c.transport.TLSClientConfig = cfg
+		return nil
+	}
+}
+
+// WithSPKIPins pins the public keys the client accepts from the Mailgun API. Each pin is the
+// base64 encoded SHA-256 digest of a DER encoded SubjectPublicKeyInfo, as used by HPKP:
+//
+//  openssl s_client -connect api.mailgun.net:443 </dev/null | openssl x509 -pubkey -noout |
+//    openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+//
+// A connection is refused unless a certificate of its verified chain has one of the pinned keys,
+// so pin the keys of an intermediate or root certificate as well as a backup, or certificate
+// rotations by Mailgun will break the client. The certificate chain is still verified as usual.
+// The TLS configuration set by WithTLSConfig() is kept, so apply WithSPKIPins() after it.
+func WithSPKIPins(pins ...string) TransportOption {
+	return func(c *transportConfig) error {
+		if len(pins) == 0 {
+			return errors.New("at least one SPKI pin is required")
+		}
+		pinned := make(map[[sha256.Size]byte]bool, len(pins))
+		for _, pin := range pins {
+			b, err := base64.StdEncoding.DecodeString(pin)
+			if err != nil || len(b) != sha256.Size {
+				return fmt.Errorf("SPKI pin '%s' is not a base64 encoded SHA-256 digest", pin)
+			}
+			var digest [sha256.Size]byte
+			copy(digest[:], b)
+			pinned[digest] = true
+		}
+
+		cfg := cloneTLSConfig(c.transport.TLSClientConfig)
+		verify := cfg.VerifyPeerCertificate
+		cfg.VerifyPeerCertificate = func(raw [][]byte, chains [][]*x509.Certificate) error {
+			if verify != nil {
+				if err := verify(raw, chains); err != nil {
+					return err
+				}
+			}
+			for _, chain := range chains {
+				for _, cert := range chain {
+					if pinned[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+						return nil
+					}
+				}
+			}
+			return errors.New("no certificate of the server matches the pinned public keys")
+		}
+		c.transport.TLSClientConfig = cfg
+		return nil
+	}
+}
+
+func cloneTLSConfig(cfg *tls.Config) *tls.Config {
+	if cfg == nil {
+		return &tls.Config{}
+	}
+	return cfg.Clone()
+}
+
+// WithProxy sends all requests through the proxy at proxyURL, for example "http://proxy.corp:3128".
+// The scheme may be http, https or socks5.
+func WithProxy(proxyURL string) TransportOption {
+	return func(c *transportConfig) error {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return errors.Wrap(err, "while parsing proxy url")
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("proxy url '%s' must include a scheme and host", proxyURL)
+		}
+		c.transport.Proxy = http.ProxyURL(u)
+		return nil
+	}
+}
+
+// WithHTTP2 enables or disables HTTP/2 for connections to the Mailgun API.
+// HTTP/2 is attempted by default, including when a custom TLS config is set with WithTLSConfig().
+func WithHTTP2(enabled bool) TransportOption {
+	return func(c *transportConfig) error {
+		c.transport.ForceAttemptHTTP2 = enabled
+		if !enabled {
+			// A non-nil, empty map disables HTTP/2 in net/http
+			c.transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		} else {
+			c.transport.TLSNextProto = nil
+		}
+		return nil
+	}
+}
+
+// WithTransport uses rt to perform requests instead of a clone of http.DefaultTransport,
+// while keeping the timeout configured for the client. If rt is an *http.Transport, the client
+// uses a clone of it, which options following WithTransport() modify, leaving rt as it is;
+// otherwise those options have no effect.
+func WithTransport(rt http.RoundTripper) TransportOption {
+	return func(c *transportConfig) error {
+		if t, ok := rt.(*http.Transport); ok {
+			c.transport = t.Clone()
+			c.roundTripper = nil
+			return nil
+		}
+		c.roundTripper = rt
+		return nil
+	}
+}
@@ -0,0 +1,304 @@
+package mailgun_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	client, err := mailgun.NewHTTPClient(
+		mailgun.WithTimeout(10*time.Second),
+		mailgun.WithMaxIdleConns(50),
+		mailgun.WithMaxIdleConnsPerHost(25),
+		mailgun.WithMaxConnsPerHost(30),
+		mailgun.WithIdleConnTimeout(time.Minute),
+		mailgun.WithKeepAlive(time.Minute),
+		mailgun.WithTLSConfig(tlsConfig),
+	)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, client.Timeout, 10*time.Second)
+
+	transport, ok := client.Transport.(*http.Transport)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, transport.MaxIdleConns, 50)
+	ensure.DeepEqual(t, transport.MaxIdleConnsPerHost, 25)
+	ensure.DeepEqual(t, transport.MaxConnsPerHost, 30)
+	ensure.DeepEqual(t, transport.IdleConnTimeout, time.Minute)
+	ensure.True(t, transport.TLSClientConfig == tlsConfig)
+	// Defaults inherited from http.DefaultTransport remain
+	ensure.DeepEqual(t, transport.TLSHandshakeTimeout, 10*time.Second)
+	ensure.NotNil(t, transport.Proxy)
+}
+
+func TestNewHTTPClientDefaults(t *testing.T) {
+	client, err := mailgun.NewHTTPClient()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, client.Timeout, mailgun.DefaultTimeout)
+	ensure.False(t, client.Transport == http.DefaultTransport)
+}
+
+func TestConcurrentClientUse(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	client, err := mailgun.NewHTTPClient(mailgun.WithMaxIdleConnsPerHost(10))
+	ensure.Nil(t, err)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mg.SetClient(client)
+			mg.SetAPIBase(server.URL())
+			_, err := mg.GetDomain(ctx, testDomain)
+			ensure.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	client, err := mailgun.NewHTTPClient(mailgun.WithProxy("http://proxy.corp:3128"))
+	ensure.Nil(t, err)
+
+	req, _ := http.NewRequest("GET", "https://api.mailgun.net/v3/domains", nil)
+	proxy, err := client.Transport.(*http.Transport).Proxy(req)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, proxy.String(), "http://proxy.corp:3128")
+
+	_, err = mailgun.NewHTTPClient(mailgun.WithProxy("proxy.corp"))
+	ensure.NotNil(t, err)
+}
+
+func TestNewHTTPClientHTTP2(t *testing.T) {
+	client, err := mailgun.NewHTTPClient(mailgun.WithHTTP2(false))
+	ensure.Nil(t, err)
+	transport := client.Transport.(*http.Transport)
+	ensure.False(t, transport.ForceAttemptHTTP2)
+	ensure.NotNil(t, transport.TLSNextProto)
+	ensure.DeepEqual(t, len(transport.TLSNextProto), 0)
+}
+
+type countingTransport struct {
+	calls int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestConfigureTransport(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	mg.SetClient(&http.Client{Timeout: 5 * time.Second})
+
+	rt := &countingTransport{}
+	ensure.Nil(t, mg.ConfigureTransport(mailgun.WithTransport(rt)))
+	ensure.DeepEqual(t, mg.Client().Timeout, 5*time.Second)
+
+	_, err := mg.GetDomain(context.Background(), testDomain)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, rt.calls, 1)
+
+	// The default client is never modified
+	mg = mailgun.NewMailgun(testDomain, testKey)
+	ensure.Nil(t, mg.ConfigureTransport(mailgun.WithMaxIdleConnsPerHost(10)))
+	ensure.False(t, mg.Client() == http.DefaultClient)
+	ensure.DeepEqual(t, mg.Client().Timeout, mailgun.DefaultTimeout)
+	ensure.DeepEqual(t, http.DefaultClient.Transport, nil)
+}
+
+func TestWithTransportClone(t *testing.T) {
+	transport := &http.Transport{MaxIdleConns: 5}
+	client, err := mailgun.NewHTTPClient(
+		mailgun.WithTransport(transport),
+		mailgun.WithMaxIdleConns(50),
+		mailgun.WithMinTLSVersion(tls.VersionTLS12),
+	)
+	ensure.Nil(t, err)
+	cloned, ok := client.Transport.(*http.Transport)
+	ensure.True(t, ok)
+	ensure.False(t, cloned == transport)
+	ensure.DeepEqual(t, cloned.MaxIdleConns, 50)
+	ensure.DeepEqual(t, cloned.TLSClientConfig.MinVersion, uint16(tls.VersionTLS12))
+
+	// Neither the transport passed in nor http.DefaultTransport is modified
+	ensure.DeepEqual(t, transport.MaxIdleConns, 5)
+	ensure.True(t, transport.TLSClientConfig == nil || transport.TLSClientConfig.MinVersion == 0)
+	ensure.DeepEqual(t, http.DefaultTransport.(*http.Transport).MaxIdleConns, 100)
+}
+
+// writeCert writes a certificate for name, signed by parent or else by itself, and its key to dir
+// as PEM files, and returns it.
+func writeCert(t *testing.T, dir, name string, parent *tls.Certificate, template *x509.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ensure.Nil(t, err)
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	ensure.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	ensure.Nil(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	ensure.Nil(t, ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600))
+	ensure.Nil(t, ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600))
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	ensure.Nil(t, err)
+	cert.Leaf, err = x509.ParseCertificate(der)
+	ensure.Nil(t, err)
+	return cert
+}
+
+func TestWithClientTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mailgun-mtls")
+	ensure.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := writeCert(t, dir, "ca", nil, &x509.Certificate{IsCA: true, BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign})
+	server := writeCert(t, dir, "server", &ca, &x509.Certificate{IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}})
+	writeCert(t, dir, "client", &ca, &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{server}, ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	file := func(name string) string { return filepath.Join(dir, name) }
+	client, err := mailgun.NewHTTPClient(
+		mailgun.WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}),
+		mailgun.WithClientTLS(file("client.crt"), file("client.key"), file("ca.crt")),
+	)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, client.Transport.(*http.Transport).TLSClientConfig.MinVersion, uint16(tls.VersionTLS12))
+	resp, err := client.Get(srv.URL)
+	ensure.Nil(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, string(body), "client")
+
+	// Without the certificate the proxy turns the client away
+	client, err = mailgun.NewHTTPClient(mailgun.WithTLSConfig(&tls.Config{RootCAs: pool}))
+	ensure.Nil(t, err)
+	_, err = client.Get(srv.URL)
+	ensure.NotNil(t, err)
+
+	_, err = mailgun.NewHTTPClient(mailgun.WithClientTLS(file("client.crt"), file("client.key"), file("client.key")))
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "no PEM certificates")
+	_, err = mailgun.NewHTTPClient(mailgun.WithClientTLS(file("missing.crt"), file("client.key"), ""))
+	ensure.NotNil(t, err)
+}
+
+func TestWithSPKIPins(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	digest := sha256.Sum256(srv.Certificate().RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(digest[:])
+	other := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	client, err := mailgun.NewHTTPClient(
+		mailgun.WithTLSConfig(&tls.Config{RootCAs: pool}),
+		mailgun.WithSPKIPins(other, pin),
+	)
+	ensure.Nil(t, err)
+	resp, err := client.Get(srv.URL)
+	ensure.Nil(t, err)
+	resp.Body.Close()
+
+	client, err = mailgun.NewHTTPClient(
+		mailgun.WithTLSConfig(&tls.Config{RootCAs: pool}),
+		mailgun.WithSPKIPins(other),
+	)
+	ensure.Nil(t, err)
+	_, err = client.Get(srv.URL)
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "pinned public keys")
+
+	// Pins do not replace verifying the chain
+	client, err = mailgun.NewHTTPClient(mailgun.WithSPKIPins(pin))
+	ensure.Nil(t, err)
+	_, err = client.Get(srv.URL)
+	ensure.NotNil(t, err)
+
+	_, err = mailgun.NewHTTPClient(mailgun.WithSPKIPins("not a pin"))
+	ensure.NotNil(t, err)
+	_, err = mailgun.NewHTTPClient(mailgun.WithSPKIPins())
+	ensure.NotNil(t, err)
+}
+
+func TestWithMinTLSVersion(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	defer srv.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	client, err := mailgun.NewHTTPClient(
+		mailgun.WithTLSConfig(&tls.Config{RootCAs: pool}),
+		mailgun.WithMinTLSVersion(tls.VersionTLS12),
+	)
+	ensure.Nil(t, err)
+	ensure.True(t, client.Transport.(*http.Transport).TLSClientConfig.RootCAs == pool)
+	resp, err := client.Get(srv.URL)
+	ensure.Nil(t, err)
+	resp.Body.Close()
+
+	client, err = mailgun.NewHTTPClient(
+		mailgun.WithTLSConfig(&tls.Config{RootCAs: pool}),
+		mailgun.WithMinTLSVersion(tls.VersionTLS13),
+	)
+	ensure.Nil(t, err)
+	_, err = client.Get(srv.URL)
+	ensure.NotNil(t, err)
+
+	_, err = mailgun.NewHTTPClient(mailgun.WithMinTLSVersion(0x0200))
+	ensure.NotNil(t, err)
+}
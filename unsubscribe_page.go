@@ -0,0 +1,217 @@
+package mailgun
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ErrUnsubscribeToken is returned by `UnsubscribePage.ParseToken()` for tokens which were not
+// signed with the secret of the page, or are malformed.
+var ErrUnsubscribeToken = errors.New("unsubscribe token not valid")
+
+// UnsubscribePageData is what the templates of an UnsubscribePage are rendered with.
+type UnsubscribePageData struct {
+	Recipient string
+	// List is the address of the mailing list, or "" to unsubscribe from all mail of the domain.
+	List string
+	// Token is posted back by the confirmation form to confirm the unsubscribe.
+	Token string
+	// Error is set when the confirmed unsubscribe failed, in which case the confirmation page is
+	// shown again.
+	Error string
+}
+
+var defaultUnsubscribeConfirm = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Unsubscribe</title></head><body>
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<form method="post">
+<input type="hidden" name="token" value="{{.Token}}">
+<p>Unsubscribe {{.Recipient}} from {{if .List}}{{.List}}{{else}}all mail{{end}}?</p>
+<button type="submit">Unsubscribe</button>
+</form>
+</body></html>
+`))
+
+var defaultUnsubscribeDone = template.Must(template.New("done").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Unsubscribed</title></head><body>
+<p>{{.Recipient}} has been unsubscribed from {{if .List}}{{.List}}{{else}}all mail{{end}}.</p>
+</body></html>
+`))
+
+// UnsubscribePage serves the page the unsubscribe links of your messages point to. A GET
+// request shows a confirmation page, and unsubscribes nothing, so that link scanners following
+// the link do not unsubscribe the recipient; posting the confirmation form, or a one-click
+// unsubscribe as described in RFC 8058, unsubscribes them. Recipients of a mailing list are
+// marked as unsubscribed members of it, and other recipients are added to the unsubscribes of the
+// domain. Links carry a token signed with a secret of your own, which identifies the recipient
+// and the list, so the page cannot be used to unsubscribe anyone else.
+//
+//  page := mailgun.NewUnsubscribePage(mg, os.Getenv("UNSUBSCRIBE_SECRET"))
+//  http.Handle("/unsubscribe", page)
+//
+//  link := page.URL("https://example.com/unsubscribe", "joe@example.com", "news@lists.example.com")
+//  m.AddHeader("List-Unsubscribe", "<"+link+">")
+//  m.AddHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+//
+// An UnsubscribePage is safe for concurrent use.
+type UnsubscribePage struct {
+	mg     Mailgun
+	secret []byte
+
+	mu      sync.RWMutex
+	tag     string
+	confirm *template.Template
+	done    *template.Template
+}
+
+// NewUnsubscribePage returns a page which unsubscribes recipients through mg, and signs and
+// verifies its tokens with secret. Keep the secret private; changing it breaks the links of
+// messages already sent.
+func NewUnsubscribePage(mg Mailgun, secret string) *UnsubscribePage {
+	return &UnsubscribePage{
+		mg:      mg,
+		secret:  []byte(secret),
+		tag:     "*",
+		confirm: defaultUnsubscribeConfirm,
+		done:    defaultUnsubscribeDone,
+	}
+}
+
+// SetTag sets the tag recipients who were not sent a mailing list are unsubscribed from;
+// the default, "*", unsubscribes them from all mail of the domain.
+func (p *UnsubscribePage) SetTag(tag string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tag = tag
+}
+
+// SetTemplates replaces the confirmation page and the page shown once the recipient is
+// unsubscribed; nil keeps the default. Both are rendered with an UnsubscribePageData, and the
+// confirmation page must post its Token back to the page in a form field named "token".
+func (p *UnsubscribePage) SetTemplates(confirm, done *template.Template) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if confirm != nil {
+		p.confirm = confirm
+	}
+	if done != nil {
+		p.done = done
+	}
+}
+
+// Token returns the token identifying recipient and list, the address of a mailing list, or "" to
+// unsubscribe the recipient from all mail of the domain.
+func (p *UnsubscribePage) Token(recipient, list string) string {
+	return signToken(p.secret, []byte(recipient+"\n"+list))
+}
+
+// URL returns the link to the page served at base which unsubscribes recipient from list.
+func (p *UnsubscribePage) URL(base, recipient, list string) string {
+	return tokenURL(base, p.Token(recipient, list))
+}
+
+// ParseToken returns the recipient and list identified by a token returned by `Token()`, or
+// ErrUnsubscribeToken if it was not signed with the secret of the page.
+func (p *UnsubscribePage) ParseToken(token string) (recipient, list string, err error) {
+	payload, ok := verifyToken(p.secret, token)
+	if !ok {
+		return "", "", ErrUnsubscribeToken
+	}
+	fields := strings.SplitN(string(payload), "\n", 2)
+	if len(fields) != 2 || fields[0] == "" {
+		return "", "", ErrUnsubscribeToken
+	}
+	return fields[0], fields[1], nil
+}
+
+// signToken returns a token carrying payload, signed with secret.
+func signToken(secret, payload []byte) string {
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(tokenSignature(secret, payload))
+}
+
+// verifyToken returns the payload of a token returned by signToken, and false if it was not
+// signed with secret.
+func verifyToken(secret []byte, token string) ([]byte, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || subtle.ConstantTimeCompare(sig, tokenSignature(secret, payload)) != 1 {
+		return nil, false
+	}
+	return payload, true
+}
+
+func tokenSignature(secret, payload []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// tokenURL returns base with the token added to its query.
+func tokenURL(base, token string) string {
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(token)
+}
+
+// ServeHTTP implements http.Handler. Requests with a token which does not verify fail with
+// 400 Bad Request, and unsubscribes which fail show the confirmation page again with 502 Bad
+// Gateway, so one-click unsubscribes are retried by the mail client.
+func (p *UnsubscribePage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	token := r.FormValue("token")
+	recipient, list, err := p.ParseToken(token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p.mu.RLock()
+	tag, confirm, done := p.tag, p.confirm, p.done
+	p.mu.RUnlock()
+	data := UnsubscribePageData{Recipient: recipient, List: list, Token: token}
+	if r.Method == http.MethodGet {
+		p.render(w, confirm, http.StatusOK, data)
+		return
+	}
+
+	if list != "" {
+		_, err = p.mg.UpdateMember(r.Context(), recipient, list, Member{Subscribed: Unsubscribed})
+	} else {
+		err = p.mg.CreateUnsubscribe(r.Context(), recipient, tag)
+	}
+	if err != nil {
+		data.Error = "We could not unsubscribe you; please try again later."
+		p.render(w, confirm, http.StatusBadGateway, data)
+		return
+	}
+	p.render(w, done, http.StatusOK, data)
+}
+
+func (p *UnsubscribePage) render(w http.ResponseWriter, t *template.Template, status int, data UnsubscribePageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	t.Execute(w, data)
+}
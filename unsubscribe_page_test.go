@@ -0,0 +1,68 @@
+package mailgun_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestUnsubscribePage(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.Form.Encode())
+		w.Write([]byte(`{"member": {"address": "joe@example.com"}, "message": "ok"}`))
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+	page := mailgun.NewUnsubscribePage(mg, "unsubscribe-secret")
+
+	serve := func(method, link string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, link, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		page.ServeHTTP(w, req)
+		return w
+	}
+
+	link := page.URL("http://example.com/unsubscribe", "joe@example.com", "news@lists.example.com")
+	recipient, list, err := page.ParseToken(page.Token("joe@example.com", "news@lists.example.com"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, recipient, "joe@example.com")
+	ensure.DeepEqual(t, list, "news@lists.example.com")
+
+	// Following the link only shows the confirmation page
+	w := serve(http.MethodGet, link, nil)
+	ensure.DeepEqual(t, w.Code, http.StatusOK)
+	ensure.StringContains(t, w.Body.String(), "Unsubscribe joe@example.com from news@lists.example.com?")
+	ensure.StringContains(t, w.Body.String(), `name="token"`)
+	ensure.DeepEqual(t, len(requests), 0)
+
+	// Confirming, or a one-click unsubscribe, marks the member as unsubscribed
+	w = serve(http.MethodPost, link, url.Values{"List-Unsubscribe": {"One-Click"}})
+	ensure.DeepEqual(t, w.Code, http.StatusOK)
+	ensure.StringContains(t, w.Body.String(), "joe@example.com has been unsubscribed from news@lists.example.com.")
+	ensure.DeepEqual(t, requests, []string{"PUT /v3/lists/news@lists.example.com/members/joe@example.com subscribed=no"})
+
+	// Recipients without a list are unsubscribed from the domain
+	requests = nil
+	w = serve(http.MethodPost, "http://example.com/unsubscribe", url.Values{"token": {page.Token("jane@example.com", "")}})
+	ensure.DeepEqual(t, w.Code, http.StatusOK)
+	ensure.DeepEqual(t, requests, []string{"POST /v3/" + testDomain + "/unsubscribes address=jane%40example.com&tag=%2A"})
+
+	// Tokens signed with another secret are refused
+	other := mailgun.NewUnsubscribePage(mg, "other-secret")
+	requests = nil
+	w = serve(http.MethodPost, other.URL("http://example.com/unsubscribe", "joe@example.com", ""), nil)
+	ensure.DeepEqual(t, w.Code, http.StatusBadRequest)
+	w = serve(http.MethodGet, "http://example.com/unsubscribe?token=garbage", nil)
+	ensure.DeepEqual(t, w.Code, http.StatusBadRequest)
+	ensure.DeepEqual(t, len(requests), 0)
+}
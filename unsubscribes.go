@@ -2,7 +2,6 @@ package mailgun
 
 import (
 	"context"
-	"strconv"
 )
 
 type Unsubscribe struct {
@@ -19,14 +18,13 @@ type unsubscribesResponse struct {
 
 // Fetches the list of unsubscribes
 func (mg *MailgunImpl) ListUnsubscribes(opts *ListOptions) *UnsubscribesIterator {
-	r := newHTTPRequest(generateApiUrl(mg, unsubscribesEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrl(mg, unsubscribesEndpoint))
+	var limit int
 	if opts != nil {
-		if opts.Limit != 0 {
-			r.addParameter("limit", strconv.Itoa(opts.Limit))
-		}
+		r.addParameters(opts.Params)
+		limit = opts.Limit
 	}
+	mg.addPageLimit(r, limit, 0)
 	url, err := r.generateUrlWithParameters()
 	return &UnsubscribesIterator{
 		mg:                   mg,
@@ -125,18 +123,12 @@ func (ci *UnsubscribesIterator) Previous(ctx context.Context, items *[]Unsubscri
 }
 
 func (ci *UnsubscribesIterator) fetch(ctx context.Context, url string) error {
-	r := newHTTPRequest(url)
-	r.setClient(ci.mg.Client())
-	r.setBasicAuth(basicAuthUser, ci.mg.APIKey())
-
-	return getResponseFromJSON(ctx, r, &ci.unsubscribesResponse)
+	return fetchPage(ctx, ci.mg, url, &ci.unsubscribesResponse)
 }
 
 // Retreives a single unsubscribe record. Can be used to check if a given address is present in the list of unsubscribed users.
 func (mg *MailgunImpl) GetUnsubscribe(ctx context.Context, address string) (Unsubscribe, error) {
-	r := newHTTPRequest(generateApiUrlWithTarget(mg, unsubscribesEndpoint, address))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrlWithTarget(mg, unsubscribesEndpoint, address))
 
 	envelope := Unsubscribe{}
 	err := getResponseFromJSON(ctx, r, &envelope)
@@ -146,10 +138,8 @@ func (mg *MailgunImpl) GetUnsubscribe(ctx context.Context, address string) (Unsu
 
 // Unsubscribe adds an e-mail address to the domain's unsubscription table.
 func (mg *MailgunImpl) CreateUnsubscribe(ctx context.Context, address, tag string) error {
-	r := newHTTPRequest(generateApiUrl(mg, unsubscribesEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	p := newUrlEncodedPayload()
+	r := newAPIRequest(mg, generateApiUrl(mg, unsubscribesEndpoint))
+	p := newPayload()
 	p.addValue("address", address)
 	p.addValue("tag", tag)
 	_, err := makePostRequest(ctx, r, p)
@@ -160,9 +150,7 @@ func (mg *MailgunImpl) CreateUnsubscribe(ctx context.Context, address, tag strin
 // If passing in an ID (discoverable from, e.g., ListUnsubscribes()), the e-mail address associated
 // with the given ID will be removed.
 func (mg *MailgunImpl) DeleteUnsubscribe(ctx context.Context, address string) error {
-	r := newHTTPRequest(generateApiUrlWithTarget(mg, unsubscribesEndpoint, address))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrlWithTarget(mg, unsubscribesEndpoint, address))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
@@ -171,9 +159,7 @@ func (mg *MailgunImpl) DeleteUnsubscribe(ctx context.Context, address string) er
 // If passing in an ID (discoverable from, e.g., ListUnsubscribes()), the e-mail address associated
 // with the given ID will be removed.
 func (mg *MailgunImpl) DeleteUnsubscribeWithTag(ctx context.Context, a, t string) error {
-	r := newHTTPRequest(generateApiUrlWithTarget(mg, unsubscribesEndpoint, a))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateApiUrlWithTarget(mg, unsubscribesEndpoint, a))
 	r.addParameter("tag", t)
 	_, err := makeDeleteRequest(ctx, r)
 	return err
@@ -0,0 +1,81 @@
+package mailgun
+
+import (
+	"context"
+	"strconv"
+)
+
+// User roles in UserRole
+const (
+	UserRoleAdmin     = "admin"
+	UserRoleBasic     = "basic"
+	UserRoleBilling   = "billing"
+	UserRoleSupport   = "support"
+	UserRoleDeveloper = "developer"
+)
+
+// User is a person with access to the control panel of the account.
+type User struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	AccountID string `json:"account_id"`
+	Activated bool   `json:"activated"`
+	Disabled  bool   `json:"is_disabled"`
+	// IsMaster is set for the user who owns the account
+	IsMaster bool `json:"is_master"`
+}
+
+// ListUsersOptions filters the users returned by ListUsers().
+type ListUsersOptions struct {
+	// Return only users with this role, see UserRoleAdmin et al.
+	Role string
+	// The number of users requested per page, 100 by default.
+	Limit int
+}
+
+type userListResponse struct {
+	Total int    `json:"total"`
+	Users []User `json:"users"`
+}
+
+// ListUsers returns all users of the account, fetching as many pages as needed.
+func (mg *MailgunImpl) ListUsers(ctx context.Context, opts *ListUsersOptions) ([]User, error) {
+	limit := 100
+	var role string
+	if opts != nil {
+		if opts.Limit != 0 {
+			limit = opts.Limit
+		}
+		role = opts.Role
+	}
+
+	var users []User
+	for {
+		r := newAPIRequest(mg, generatePublicApiUrl(mg, usersEndpoint))
+		r.addParameter("limit", strconv.Itoa(limit))
+		r.addParameter("skip", strconv.Itoa(len(users)))
+		if role != "" {
+			r.addParameter("role", role)
+		}
+
+		var resp userListResponse
+		if err := getResponseFromJSON(ctx, r, &resp); err != nil {
+			return nil, err
+		}
+		users = append(users, resp.Users...)
+		if len(resp.Users) == 0 || len(users) >= resp.Total {
+			return users, nil
+		}
+	}
+}
+
+// GetUser returns a single user of the account.
+func (mg *MailgunImpl) GetUser(ctx context.Context, id string) (User, error) {
+	r := newAPIRequest(mg, generatePublicApiUrl(mg, usersEndpoint)+"/"+pathEscape(id))
+
+	var resp User
+	err := getResponseFromJSON(ctx, r, &resp)
+	return resp, err
+}
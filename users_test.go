@@ -0,0 +1,44 @@
+package mailgun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestListUsers(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	// A small page size fetches the users across several pages
+	users, err := mg.ListUsers(ctx, &mailgun.ListUsersOptions{Limit: 3})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(users), 4)
+	ensure.True(t, users[0].IsMaster)
+
+	users, err = mg.ListUsers(ctx, &mailgun.ListUsersOptions{Role: mailgun.UserRoleDeveloper})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(users), 2)
+	for _, user := range users {
+		ensure.DeepEqual(t, user.Role, mailgun.UserRoleDeveloper)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(server.URL())
+	ctx := context.Background()
+
+	users, err := mg.ListUsers(ctx, nil)
+	ensure.Nil(t, err)
+
+	user, err := mg.GetUser(ctx, users[1].ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, user, users[1])
+
+	_, err = mg.GetUser(ctx, "unknown")
+	ensure.DeepEqual(t, mailgun.GetStatusFromErr(err), 404)
+}
@@ -0,0 +1,174 @@
+package mailgun
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// OverflowPolicy selects what a WebhookChannel does with an event when its buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for room in the buffer until the webhook request is cancelled, and then
+	// fails the request so Mailgun delivers the event again later.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowReject fails the webhook request at once, so Mailgun delivers the event again later.
+	OverflowReject
+	// OverflowDropNewest acknowledges and drops the event.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest event in the buffer to make room for the event. A
+	// channel without a buffer has no event to drop, so it blocks as with OverflowBlock.
+	OverflowDropOldest
+)
+
+var (
+	// ErrWebhookChannelFull is returned by the handler of a WebhookChannel with OverflowReject when its buffer is full.
+	ErrWebhookChannelFull = errors.New("webhook channel is full")
+	// ErrWebhookChannelClosed is returned by the handler of a WebhookChannel which was closed.
+	ErrWebhookChannelClosed = errors.New("webhook channel is closed")
+)
+
+// webhookChannelEvents are the events a WebhookChannel receives unless it is given others.
+var webhookChannelEvents = []string{
+	events.EventAccepted, events.EventRejected, events.EventDelivered, events.EventFailed,
+	events.EventOpened, events.EventClicked, events.EventUnsubscribed, events.EventComplained,
+	events.EventStored,
+}
+
+// WebhookChannel delivers the events received by a WebhookDispatcher on a channel, so services can
+// consume them in a select loop rather than in webhook handlers. Events are verified and
+// deduplicated by the dispatcher, and buffered until they are received; what happens when the
+// buffer is full is set by its OverflowPolicy. Events dropped from a full buffer are acknowledged
+// to Mailgun, so they are lost; use OverflowBlock or OverflowReject to have Mailgun retry them.
+//
+//  c := mailgun.NewWebhookChannel(mailgun.NewWebhookDispatcher(signingKey), 1000, mailgun.OverflowBlock)
+//  http.Handle("/webhooks/mailgun", c)
+//  for {
+//    select {
+//    case e := <-c.Events():
+//      update(e)
+//    case <-ctx.Done():
+//      c.Close()
+//      return
+//    }
+//  }
+type WebhookChannel struct {
+	d      *WebhookDispatcher
+	events chan Event
+	policy OverflowPolicy
+	done   chan struct{}
+
+	mu      sync.RWMutex
+	closed  bool
+	senders sync.WaitGroup
+	dropped int
+}
+
+// NewWebhookChannel returns a channel of the events named in names, such as events.EventDelivered,
+// received by d, buffering up to size events. By default all events which carry delivery updates
+// are received. Handlers registered with d for the events are replaced.
+func NewWebhookChannel(d *WebhookDispatcher, size int, policy OverflowPolicy, names ...string) *WebhookChannel {
+	if policy == OverflowDropOldest && size <= 0 {
+		policy = OverflowBlock
+	}
+	c := &WebhookChannel{d: d, events: make(chan Event, size), policy: policy, done: make(chan struct{})}
+	if len(names) == 0 {
+		names = webhookChannelEvents
+	}
+	for _, name := range names {
+		d.On(name, c.handle)
+	}
+	return c
+}
+
+// Events returns the channel events are delivered on. It is closed by `Close()`.
+func (c *WebhookChannel) Events() <-chan Event {
+	return c.events
+}
+
+// Dropped returns the number of events dropped because the buffer was full.
+func (c *WebhookChannel) Dropped() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.dropped
+}
+
+// ServeHTTP implements http.Handler, as `WebhookDispatcher.ServeHTTP()` does.
+func (c *WebhookChannel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.d.ServeHTTP(w, r)
+}
+
+// Close stops delivering events, and closes the channel once the events being delivered are
+// buffered or refused. Webhook requests received afterwards fail, so Mailgun delivers their
+// events again later; events left in the buffer can still be received.
+func (c *WebhookChannel) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	close(c.done)
+	c.senders.Wait()
+	close(c.events)
+}
+
+// handle is the WebhookHandler of the events of the channel.
+func (c *WebhookChannel) handle(ctx context.Context, e Event) error {
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		return ErrWebhookChannelClosed
+	}
+	c.senders.Add(1)
+	c.mu.RUnlock()
+	defer c.senders.Done()
+
+	select {
+	case c.events <- e:
+		return nil
+	default:
+	}
+
+	switch c.policy {
+	case OverflowReject:
+		return ErrWebhookChannelFull
+	case OverflowDropNewest:
+		c.drop()
+		return nil
+	case OverflowDropOldest:
+		for {
+			select {
+			case c.events <- e:
+				return nil
+			default:
+			}
+			select {
+			case <-c.events:
+				c.drop()
+			default:
+			}
+		}
+	}
+
+	select {
+	case c.events <- e:
+		return nil
+	case <-c.done:
+		return ErrWebhookChannelClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *WebhookChannel) drop() {
+	c.mu.Lock()
+	c.dropped++
+	c.mu.Unlock()
+}
@@ -0,0 +1,75 @@
+package mailgun_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+func TestWebhookChannel(t *testing.T) {
+	const signingKey = "channel-signing-key"
+	send := func(c *mailgun.WebhookChannel, name, id string) int {
+		e := mailgun.EventNames[name]()
+		e.SetName(name)
+		e.SetID(id)
+		req, err := mailgun.NewSignedWebhookRequest("http://example.com/webhook", signingKey, e)
+		ensure.Nil(t, err)
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, req)
+		return w.Code
+	}
+	ids := func(c *mailgun.WebhookChannel) []string {
+		var ids []string
+		for e := range c.Events() {
+			ids = append(ids, e.GetID())
+		}
+		return ids
+	}
+
+	c := mailgun.NewWebhookChannel(mailgun.NewWebhookDispatcher(signingKey), 2, mailgun.OverflowReject)
+	ensure.DeepEqual(t, send(c, events.EventDelivered, "delivered-1"), http.StatusOK)
+	ensure.DeepEqual(t, send(c, events.EventFailed, "failed-1"), http.StatusOK)
+	ensure.DeepEqual(t, send(c, events.EventOpened, "opened-1"), http.StatusInternalServerError)
+	c.Close()
+	ensure.DeepEqual(t, send(c, events.EventOpened, "opened-1"), http.StatusInternalServerError)
+	ensure.DeepEqual(t, ids(c), []string{"delivered-1", "failed-1"})
+
+	c = mailgun.NewWebhookChannel(mailgun.NewWebhookDispatcher(signingKey), 2, mailgun.OverflowDropNewest)
+	for _, id := range []string{"delivered-1", "delivered-2", "delivered-3"} {
+		ensure.DeepEqual(t, send(c, events.EventDelivered, id), http.StatusOK)
+	}
+	c.Close()
+	ensure.DeepEqual(t, c.Dropped(), 1)
+	ensure.DeepEqual(t, ids(c), []string{"delivered-1", "delivered-2"})
+
+	c = mailgun.NewWebhookChannel(mailgun.NewWebhookDispatcher(signingKey), 2, mailgun.OverflowDropOldest, events.EventDelivered)
+	for _, id := range []string{"delivered-1", "delivered-2", "delivered-3"} {
+		ensure.DeepEqual(t, send(c, events.EventDelivered, id), http.StatusOK)
+	}
+	// Events the channel was not created for are acknowledged without a handler
+	ensure.DeepEqual(t, send(c, events.EventFailed, "failed-1"), http.StatusOK)
+	c.Close()
+	ensure.DeepEqual(t, c.Dropped(), 1)
+	ensure.DeepEqual(t, ids(c), []string{"delivered-2", "delivered-3"})
+
+	// Without a buffer there is nothing to drop, so the event waits for a receiver
+	c = mailgun.NewWebhookChannel(mailgun.NewWebhookDispatcher(signingKey), 0, mailgun.OverflowDropOldest)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	e := mailgun.EventNames[events.EventDelivered]()
+	e.SetName(events.EventDelivered)
+	e.SetID("delivered-1")
+	req, err := mailgun.NewSignedWebhookRequest("http://example.com/webhook", signingKey, e)
+	ensure.Nil(t, err)
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, req.WithContext(ctx))
+	ensure.DeepEqual(t, w.Code, http.StatusInternalServerError)
+	ensure.DeepEqual(t, c.Dropped(), 0)
+	c.Close()
+}
@@ -0,0 +1,248 @@
+package mailgun
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDedupeEntries is how many events the in-memory store of a WebhookDispatcher remembers.
+	DefaultDedupeEntries = 100000
+	// DefaultDedupeClaimTimeout is how long RedisDeduplicationStore considers an event in progress,
+	// so an event whose handler never finished, such as in a process which crashed, is handled again.
+	DefaultDedupeClaimTimeout = 5 * time.Minute
+)
+
+// DedupeStatus is what a DeduplicationStore knows of an event.
+type DedupeStatus int
+
+const (
+	// DedupeNew is an event which was not seen within the window, and is now claimed by the caller.
+	DedupeNew DedupeStatus = iota
+	// DedupeInProgress is an event being handled by another request.
+	DedupeInProgress
+	// DedupeHandled is an event which was handled within the window.
+	DedupeHandled
+)
+
+// DeduplicationStore remembers the events a WebhookDispatcher handled, so duplicate deliveries of
+// an event are dropped. MemoryDeduplicationStore, the default, only deduplicates within one
+// process; share a store such as RedisDeduplicationStore between the processes which receive
+// webhooks to deduplicate across them. Implementations must be safe for concurrent use.
+type DeduplicationStore interface {
+	// Claim marks the event identified by key as in progress, unless it is already in progress
+	// or was handled within window, and returns which of those the event was.
+	Claim(ctx context.Context, key string, window time.Duration) (DedupeStatus, error)
+	// Complete records that the event was handled, to be remembered for window.
+	Complete(ctx context.Context, key string, window time.Duration) error
+	// Release forgets the event after its handler failed, so the retry from Mailgun is handled again.
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryDeduplicationStore is a DeduplicationStore held in memory, which remembers up to a maximum
+// number of events, dropping those least recently seen first.
+type MemoryDeduplicationStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+}
+
+type dedupeEntry struct {
+	key string
+	// handledAt is zero while the event is in progress
+	handledAt time.Time
+}
+
+// NewMemoryDeduplicationStore returns a MemoryDeduplicationStore remembering up to maxEntries
+// events, or DefaultDedupeEntries if maxEntries is not positive. Events dropped to make room are
+// handled again if delivered again, so allow for the events received within the window.
+func NewMemoryDeduplicationStore(maxEntries int) *MemoryDeduplicationStore {
+	if maxEntries <= 0 {
+		maxEntries = DefaultDedupeEntries
+	}
+	return &MemoryDeduplicationStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Claim implements DeduplicationStore.
+func (m *MemoryDeduplicationStore) Claim(ctx context.Context, key string, window time.Duration) (DedupeStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	// Drop the events which expired from the end of the list
+	for el := m.order.Back(); el != nil; el = m.order.Back() {
+		e := el.Value.(*dedupeEntry)
+		if e.handledAt.IsZero() || now.Sub(e.handledAt) <= window {
+			break
+		}
+		m.remove(el)
+	}
+
+	if el, ok := m.entries[key]; ok {
+		e := el.Value.(*dedupeEntry)
+		switch {
+		case e.handledAt.IsZero():
+			return DedupeInProgress, nil
+		case now.Sub(e.handledAt) <= window:
+			m.order.MoveToFront(el)
+			return DedupeHandled, nil
+		}
+		m.remove(el)
+	}
+
+	m.add(&dedupeEntry{key: key})
+	return DedupeNew, nil
+}
+
+// Complete implements DeduplicationStore.
+func (m *MemoryDeduplicationStore) Complete(ctx context.Context, key string, window time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[key]; ok {
+		el.Value.(*dedupeEntry).handledAt = time.Now()
+		m.order.MoveToFront(el)
+		return nil
+	}
+	m.add(&dedupeEntry{key: key, handledAt: time.Now()})
+	return nil
+}
+
+// Release implements DeduplicationStore.
+func (m *MemoryDeduplicationStore) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[key]; ok {
+		m.remove(el)
+	}
+	return nil
+}
+
+// Len returns the number of events remembered, including those in progress.
+func (m *MemoryDeduplicationStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+// add adds the entry as the most recently seen, dropping the least recently seen if the store is full.
+func (m *MemoryDeduplicationStore) add(e *dedupeEntry) {
+	m.entries[e.key] = m.order.PushFront(e)
+	for m.order.Len() > m.maxEntries {
+		m.remove(m.order.Back())
+	}
+}
+
+func (m *MemoryDeduplicationStore) remove(el *list.Element) {
+	m.order.Remove(el)
+	delete(m.entries, el.Value.(*dedupeEntry).key)
+}
+
+// RedisClient is the subset of the commands of a Redis client used by RedisDeduplicationStore,
+// so the package does not depend on a Redis library. Adapt the client of yours to it; with
+// go-redis, for instance:
+//
+//  type goRedis struct{ *redis.Client }
+//
+//  func (c goRedis) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+//    return c.Client.SetNX(ctx, key, value, ttl).Result()
+//  }
+//
+//  func (c goRedis) Get(ctx context.Context, key string) (string, bool, error) {
+//    v, err := c.Client.Get(ctx, key).Result()
+//    if err == redis.Nil {
+//      return "", false, nil
+//    }
+//    return v, err == nil, err
+//  }
+//
+//  func (c goRedis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+//    return c.Client.Set(ctx, key, value, ttl).Err()
+//  }
+//
+//  func (c goRedis) Del(ctx context.Context, key string) error {
+//    return c.Client.Del(ctx, key).Err()
+//  }
+type RedisClient interface {
+	// SetNX sets key to value, expiring after ttl, unless key exists, and reports whether it did.
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// Get returns the value of key, with found false if it does not exist.
+	Get(ctx context.Context, key string) (value string, found bool, err error)
+	// Set sets key to value, expiring after ttl.
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// Del deletes key.
+	Del(ctx context.Context, key string) error
+}
+
+const (
+	redisDedupeInProgress = "in-progress"
+	redisDedupeHandled    = "handled"
+)
+
+// RedisDeduplicationStore is a DeduplicationStore kept in Redis, which deduplicates the webhooks
+// received by every process sharing it. It is a reference implementation: each event is a key
+// which Redis expires, set atomically with SETNX when the event is claimed.
+type RedisDeduplicationStore struct {
+	client       RedisClient
+	prefix       string
+	claimTimeout time.Duration
+}
+
+// NewRedisDeduplicationStore returns a RedisDeduplicationStore keeping events in keys named prefix
+// followed by the key of the event, such as "mailgun:webhook:".
+func NewRedisDeduplicationStore(client RedisClient, prefix string) *RedisDeduplicationStore {
+	return &RedisDeduplicationStore{
+		client:       client,
+		prefix:       prefix,
+		claimTimeout: DefaultDedupeClaimTimeout,
+	}
+}
+
+// SetClaimTimeout sets how long an event stays in progress, after which it is handled again if
+// delivered again; it must be longer than handlers take. Defaults to DefaultDedupeClaimTimeout.
+func (r *RedisDeduplicationStore) SetClaimTimeout(timeout time.Duration) {
+	r.claimTimeout = timeout
+}
+
+// Claim implements DeduplicationStore.
+func (r *RedisDeduplicationStore) Claim(ctx context.Context, key string, window time.Duration) (DedupeStatus, error) {
+	key = r.prefix + key
+	// The key may expire between SETNX and GET; try once more if it does
+	for i := 0; i < 2; i++ {
+		ok, err := r.client.SetNX(ctx, key, redisDedupeInProgress, r.claimTimeout)
+		if err != nil {
+			return DedupeNew, err
+		}
+		if ok {
+			return DedupeNew, nil
+		}
+		v, found, err := r.client.Get(ctx, key)
+		if err != nil {
+			return DedupeNew, err
+		}
+		if !found {
+			continue
+		}
+		if v == redisDedupeHandled {
+			return DedupeHandled, nil
+		}
+		return DedupeInProgress, nil
+	}
+	return DedupeInProgress, nil
+}
+
+// Complete implements DeduplicationStore.
+func (r *RedisDeduplicationStore) Complete(ctx context.Context, key string, window time.Duration) error {
+	return r.client.Set(ctx, r.prefix+key, redisDedupeHandled, window)
+}
+
+// Release implements DeduplicationStore.
+func (r *RedisDeduplicationStore) Release(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.prefix+key)
+}
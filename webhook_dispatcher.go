@@ -0,0 +1,403 @@
+package mailgun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// DefaultWebhookDedupeWindow is how long a WebhookDispatcher remembers handled events.
+// Mailgun retries failed webhook deliveries for up to 8 hours.
+const DefaultWebhookDedupeWindow = 24 * time.Hour
+
+const (
+	// DefaultWebhookMaxBodySize is the largest request body a WebhookDispatcher reads.
+	DefaultWebhookMaxBodySize = 1 << 20
+	// DefaultWebhookReplayWindow is how far the timestamp of a webhook signature may be from the
+	// time it is received. Mailgun signs each delivery, retries included, when it makes it.
+	DefaultWebhookReplayWindow = 15 * time.Minute
+)
+
+var (
+	// ErrWebhookSignature is returned by WebhookDispatcher.Dispatch() when the payload signature does not verify.
+	ErrWebhookSignature = errors.New("webhook signature not valid")
+	// ErrWebhookInProgress is returned by WebhookDispatcher.Dispatch() when the same event is already being handled.
+	ErrWebhookInProgress = errors.New("webhook event is already being handled")
+	// ErrWebhookExpired is returned by WebhookDispatcher.Dispatch() when the payload was signed outside the
+	// replay window, such as a request captured and sent again.
+	ErrWebhookExpired = errors.New("webhook signature timestamp is outside the replay window")
+
+	// errAlreadyHandled is never returned by Dispatch(); a duplicate delivery is acknowledged as a success.
+	errAlreadyHandled = errors.New("webhook event already handled")
+)
+
+// WebhookHandler handles an event delivered to a webhook. Returning an error tells Mailgun to
+// retry the delivery later.
+type WebhookHandler func(ctx context.Context, event Event) error
+
+type webhookKey struct{}
+
+// WebhookIdempotencyKey returns the key identifying the event being handled, from within a
+// WebhookHandler. Retried deliveries of the same event carry the same key, so handlers with side
+// effects outside the dispatcher's own dedupe window can use it to detect duplicates.
+func WebhookIdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(webhookKey{}).(string)
+	return key
+}
+
+// WebhookDispatcher receives webhook requests, verifies their signatures, parses the events and
+// routes each one to the handler registered for its event type. Events with no handler are
+// acknowledged and dropped. Events which were already handled successfully within the dedupe window
+// are acknowledged without calling the handler again, so Mailgun's retries are safe.
+//
+//  d := mailgun.NewWebhookDispatcher(os.Getenv("MG_WEBHOOK_SIGNING_KEY"))
+//  d.OnDelivered(func(ctx context.Context, e *events.Delivered) error {
+//    return db.MarkDelivered(e.Message.Headers.MessageID)
+//  })
+//  d.OnFailed(func(ctx context.Context, e *events.Failed) error {
+//    return db.MarkFailed(e.Message.Headers.MessageID, e.Reason)
+//  })
+//  http.Handle("/webhooks/mailgun", d)
+//
+// A WebhookDispatcher is safe for concurrent use.
+type WebhookDispatcher struct {
+	mu          sync.Mutex
+	signingKeys []string
+	handlers    map[string]WebhookHandler
+	window      time.Duration
+	store       DeduplicationStore
+	parser      EventParser
+	maxBodySize int64
+	replay      time.Duration
+}
+
+// NewWebhookDispatcher returns a dispatcher which verifies requests with signingKey,
+// the HTTP webhook signing key of your account. Requests signed with any of the otherKeys are
+// accepted as well; see `SetSigningKeys()`.
+func NewWebhookDispatcher(signingKey string, otherKeys ...string) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		signingKeys: append([]string{signingKey}, otherKeys...),
+		handlers:    make(map[string]WebhookHandler),
+		window:      DefaultWebhookDedupeWindow,
+		store:       NewMemoryDeduplicationStore(DefaultDedupeEntries),
+		maxBodySize: DefaultWebhookMaxBodySize,
+		replay:      DefaultWebhookReplayWindow,
+	}
+}
+
+// SetStrictParsing sets whether events are parsed in strict mode (see `EventParser`), failing
+// payloads with fields the events package does not model rather than ignoring them. `ServeHTTP()`
+// answers such payloads with a server error, so Mailgun delivers the event again later, and a
+// change Mailgun makes to its payloads holds events back until the application is updated, rather
+// than losing what changed.
+func (d *WebhookDispatcher) SetStrictParsing(strict bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.parser.Strict = strict
+}
+
+// SetSigningKeys replaces the keys requests are verified with; a request signed with any of them
+// is accepted. To rotate the signing key without dropping webhooks, accept the new key alongside
+// the old one as soon as `RegenerateWebhookSigningKey()` returns it, and remove the old key once
+// webhooks signed with it have stopped arriving.
+//
+//  newKey, err := mg.RegenerateWebhookSigningKey(ctx)
+//  d.SetSigningKeys(newKey, oldKey)
+//  // Later, once Mailgun has delivered the webhooks signed with the old key
+//  d.SetSigningKeys(newKey)
+func (d *WebhookDispatcher) SetSigningKeys(keys ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.signingKeys = append([]string(nil), keys...)
+}
+
+// SetDedupeWindow sets how long handled events are remembered. A window of zero disables deduplication.
+func (d *WebhookDispatcher) SetDedupeWindow(window time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.window = window
+}
+
+// SetMaxBodySize sets the largest request body `ServeHTTP()` reads; larger requests are answered
+// with 413 Request Entity Too Large. Defaults to DefaultWebhookMaxBodySize.
+func (d *WebhookDispatcher) SetMaxBodySize(n int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxBodySize = n
+}
+
+// SetReplayWindow sets how far the timestamp of a signature may be from the time the payload is
+// dispatched, so a signed request captured and sent again later is rejected with
+// ErrWebhookExpired. A window of zero disables the check. Defaults to DefaultWebhookReplayWindow.
+func (d *WebhookDispatcher) SetReplayWindow(window time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.replay = window
+}
+
+// SetDeduplicationStore sets where handled events are remembered. By default they are remembered
+// in memory, which only drops duplicates delivered to this dispatcher; share a store such as
+// RedisDeduplicationStore between the processes receiving webhooks to drop duplicates delivered to
+// any of them. Passing nil restores a MemoryDeduplicationStore.
+//
+//  d.SetDeduplicationStore(mailgun.NewRedisDeduplicationStore(goRedis{rdb}, "mailgun:webhook:"))
+func (d *WebhookDispatcher) SetDeduplicationStore(store DeduplicationStore) {
+	if store == nil {
+		store = NewMemoryDeduplicationStore(DefaultDedupeEntries)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.store = store
+}
+
+// On registers the handler for events named name, such as events.EventDelivered,
+// replacing any handler previously registered for it.
+func (d *WebhookDispatcher) On(name string, h WebhookHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[name] = h
+}
+
+// OnAccepted registers the handler for `accepted` events.
+func (d *WebhookDispatcher) OnAccepted(h func(context.Context, *events.Accepted) error) {
+	d.On(events.EventAccepted, func(ctx context.Context, e Event) error { return h(ctx, e.(*events.Accepted)) })
+}
+
+// OnRejected registers the handler for `rejected` events.
+func (d *WebhookDispatcher) OnRejected(h func(context.Context, *events.Rejected) error) {
+	d.On(events.EventRejected, func(ctx context.Context, e Event) error { return h(ctx, e.(*events.Rejected)) })
+}
+
+// OnDelivered registers the handler for `delivered` events.
+func (d *WebhookDispatcher) OnDelivered(h func(context.Context, *events.Delivered) error) {
+	d.On(events.EventDelivered, func(ctx context.Context, e Event) error { return h(ctx, e.(*events.Delivered)) })
+}
+
+// OnFailed registers the handler for `failed` events, both temporary and permanent.
+func (d *WebhookDispatcher) OnFailed(h func(context.Context, *events.Failed) error) {
+	d.On(events.EventFailed, func(ctx context.Context, e Event) error { return h(ctx, e.(*events.Failed)) })
+}
+
+// OnOpened registers the handler for `opened` events.
+func (d *WebhookDispatcher) OnOpened(h func(context.Context, *events.Opened) error) {
+	d.On(events.EventOpened, func(ctx context.Context, e Event) error { return h(ctx, e.(*events.Opened)) })
+}
+
+// OnClicked registers the handler for `clicked` events.
+func (d *WebhookDispatcher) OnClicked(h func(context.Context, *events.Clicked) error) {
+	d.On(events.EventClicked, func(ctx context.Context, e Event) error { return h(ctx, e.(*events.Clicked)) })
+}
+
+// OnUnsubscribed registers the handler for `unsubscribed` events.
+func (d *WebhookDispatcher) OnUnsubscribed(h func(context.Context, *events.Unsubscribed) error) {
+	d.On(events.EventUnsubscribed, func(ctx context.Context, e Event) error { return h(ctx, e.(*events.Unsubscribed)) })
+}
+
+// OnComplained registers the handler for `complained` events.
+func (d *WebhookDispatcher) OnComplained(h func(context.Context, *events.Complained) error) {
+	d.On(events.EventComplained, func(ctx context.Context, e Event) error { return h(ctx, e.(*events.Complained)) })
+}
+
+// OnStored registers the handler for `stored` events.
+func (d *WebhookDispatcher) OnStored(h func(context.Context, *events.Stored) error) {
+	d.On(events.EventStored, func(ctx context.Context, e Event) error { return h(ctx, e.(*events.Stored)) })
+}
+
+// ServeHTTP implements http.Handler. Requests which fail verification, were signed outside the
+// replay window or cannot be parsed are answered with 406 Not Acceptable, which tells Mailgun not
+// to retry them. Handler errors are answered with 500 Internal Server Error, as are errors of the
+// deduplication store, and events already being handled by another request with 409 Conflict, so
+// Mailgun retries them later. Methods other than POST are answered with 405 Method Not Allowed,
+// and bodies larger than the maximum set with `SetMaxBodySize()` with 413 Request Entity Too Large.
+func (d *WebhookDispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	d.mu.Lock()
+	max := d.maxBodySize
+	d.mu.Unlock()
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, max))
+	if err != nil {
+		if int64(len(body)) >= max {
+			http.Error(w, "webhook payload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var handlerErr *webhookHandlerError
+	var storeErr *webhookStoreError
+	var schemaErr *EventSchemaError
+	err = d.Dispatch(r.Context(), body)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case errors.Is(err, ErrWebhookInProgress):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.As(err, &handlerErr):
+		http.Error(w, "webhook handler failed", http.StatusInternalServerError)
+	case errors.As(err, &storeErr):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	case errors.As(err, &schemaErr):
+		// Mailgun retries deliveries which fail with server errors, but not with a 406
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+	}
+}
+
+// Dispatch verifies and parses a webhook payload, and calls the handler registered for its event.
+// It returns ErrWebhookSignature if the signature does not verify, ErrWebhookExpired if it was made
+// outside the replay window, ErrWebhookInProgress if the event is already being handled, or the
+// error returned by the handler.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context, body []byte) error {
+	var payload WebhookPayload
+	if err := jsoniter.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("failed to parse webhook payload: %s", err)
+	}
+
+	d.mu.Lock()
+	keys, replay := d.signingKeys, d.replay
+	d.mu.Unlock()
+	verified, err := verifyWebhookSignatureAny(keys, payload.Signature)
+	if err != nil || !verified {
+		return ErrWebhookSignature
+	}
+	if replay != 0 {
+		ts, err := strconv.ParseInt(payload.Signature.TimeStamp, 10, 64)
+		if err != nil {
+			return ErrWebhookSignature
+		}
+		if age := time.Since(time.Unix(ts, 0)); age > replay || age < -replay {
+			return ErrWebhookExpired
+		}
+	}
+
+	event, err := d.eventParser().ParseEvent(payload.EventData)
+	if err != nil {
+		return err
+	}
+	return d.dispatchEvent(ctx, event, payload.Signature.Token)
+}
+
+// DispatchEvent calls the handler registered for an event which was already parsed, such as one
+// read back from an archive, as `Dispatch()` does for the event of a webhook payload. Events with
+// no ID are not deduplicated.
+func (d *WebhookDispatcher) DispatchEvent(ctx context.Context, event Event) error {
+	return d.dispatchEvent(ctx, event, "")
+}
+
+// dispatchEvent calls the handler of the event. Events are deduplicated by their ID, or by
+// fallbackKey if they have none and it is not empty.
+func (d *WebhookDispatcher) dispatchEvent(ctx context.Context, event Event, fallbackKey string) error {
+	d.mu.Lock()
+	h := d.handlers[event.GetName()]
+	d.mu.Unlock()
+	if h == nil {
+		return nil
+	}
+
+	key := event.GetID()
+	if key == "" {
+		key = fallbackKey
+	}
+	if key == "" {
+		if err := h(ctx, event); err != nil {
+			return &webhookHandlerError{err: err}
+		}
+		return nil
+	}
+	store, window := d.dedupe()
+	if err := begin(ctx, store, window, key); err != nil {
+		if err == errAlreadyHandled {
+			return nil
+		}
+		return err
+	}
+
+	err := h(context.WithValue(ctx, webhookKey{}, key), event)
+	finish(ctx, store, window, key, err == nil)
+	if err != nil {
+		return &webhookHandlerError{err: err}
+	}
+	return nil
+}
+
+func (d *WebhookDispatcher) eventParser() EventParser {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.parser
+}
+
+// dedupe returns the store events are deduplicated with, and the window, or a nil store if
+// deduplication is disabled.
+func (d *WebhookDispatcher) dedupe() (DeduplicationStore, time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.window == 0 {
+		return nil, 0
+	}
+	return d.store, d.window
+}
+
+// begin records that the event identified by key is being handled. It returns ErrWebhookInProgress
+// if another request is handling the event, or errAlreadyHandled if it was handled within the window.
+func begin(ctx context.Context, store DeduplicationStore, window time.Duration, key string) error {
+	if store == nil {
+		return nil
+	}
+	status, err := store.Claim(ctx, key, window)
+	if err != nil {
+		return &webhookStoreError{err: err}
+	}
+	switch status {
+	case DedupeInProgress:
+		return ErrWebhookInProgress
+	case DedupeHandled:
+		return errAlreadyHandled
+	}
+	return nil
+}
+
+// finish records the outcome of handling the event identified by key. Failed events are forgotten
+// so the retry from Mailgun is handled again. Errors of the store are dropped, as the event was
+// handled already; the claim of an event the store failed to update expires on its own.
+func finish(ctx context.Context, store DeduplicationStore, window time.Duration, key string, handled bool) {
+	if store == nil {
+		return
+	}
+	if handled {
+		store.Complete(ctx, key, window)
+		return
+	}
+	store.Release(ctx, key)
+}
+
+type webhookHandlerError struct {
+	err error
+}
+
+func (e *webhookHandlerError) Error() string { return e.err.Error() }
+func (e *webhookHandlerError) Unwrap() error { return e.err }
+
+type webhookStoreError struct {
+	err error
+}
+
+func (e *webhookStoreError) Error() string {
+	return "while deduplicating webhook event: " + e.err.Error()
+}
+
+func (e *webhookStoreError) Unwrap() error { return e.err }
@@ -0,0 +1,261 @@
+package mailgun_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+func TestWebhookDispatcher(t *testing.T) {
+	const signingKey = "dispatcher-signing-key"
+	d := mailgun.NewWebhookDispatcher(signingKey)
+
+	var delivered, failed []string
+	fail := true
+	d.OnDelivered(func(ctx context.Context, e *events.Delivered) error {
+		ensure.DeepEqual(t, mailgun.WebhookIdempotencyKey(ctx), e.ID)
+		delivered = append(delivered, e.ID)
+		return nil
+	})
+	d.OnFailed(func(ctx context.Context, e *events.Failed) error {
+		failed = append(failed, e.ID)
+		if fail {
+			return errors.New("database unavailable")
+		}
+		return nil
+	})
+
+	send := func(key string, event mailgun.Event) int {
+		req, err := mailgun.NewSignedWebhookRequest("http://example.com/webhook", key, event)
+		ensure.Nil(t, err)
+		w := httptest.NewRecorder()
+		d.ServeHTTP(w, req)
+		return w.Code
+	}
+	newEvent := func(name, id string) mailgun.Event {
+		e := mailgun.EventNames[name]()
+		e.SetName(name)
+		e.SetID(id)
+		return e
+	}
+
+	// Retried deliveries of a handled event are acknowledged without calling the handler again
+	ensure.DeepEqual(t, send(signingKey, newEvent(events.EventDelivered, "delivered-1")), http.StatusOK)
+	ensure.DeepEqual(t, send(signingKey, newEvent(events.EventDelivered, "delivered-1")), http.StatusOK)
+	ensure.DeepEqual(t, delivered, []string{"delivered-1"})
+
+	// A failed handler is retried by Mailgun, and called again on the retry
+	ensure.DeepEqual(t, send(signingKey, newEvent(events.EventFailed, "failed-1")), http.StatusInternalServerError)
+	fail = false
+	ensure.DeepEqual(t, send(signingKey, newEvent(events.EventFailed, "failed-1")), http.StatusOK)
+	ensure.DeepEqual(t, failed, []string{"failed-1", "failed-1"})
+
+	// Events without a handler are acknowledged
+	ensure.DeepEqual(t, send(signingKey, newEvent(events.EventOpened, "opened-1")), http.StatusOK)
+
+	// Bad signatures are rejected, and not retried by Mailgun
+	ensure.DeepEqual(t, send("wrong-key", newEvent(events.EventDelivered, "delivered-2")), http.StatusNotAcceptable)
+	ensure.DeepEqual(t, delivered, []string{"delivered-1"})
+
+	err := d.Dispatch(context.Background(), []byte(`{"signature": {}, "event-data": {}}`))
+	ensure.True(t, errors.Is(err, mailgun.ErrWebhookSignature))
+
+	// Payloads signed outside the replay window are rejected, and not retried by Mailgun
+	old := mailgun.SignWebhook(signingKey, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10), "token")
+	body, err := json.Marshal(map[string]interface{}{
+		"signature":  old,
+		"event-data": json.RawMessage(`{"event": "delivered", "id": "delivered-3"}`),
+	})
+	ensure.Nil(t, err)
+	ensure.True(t, errors.Is(d.Dispatch(context.Background(), body), mailgun.ErrWebhookExpired))
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body)))
+	ensure.DeepEqual(t, w.Code, http.StatusNotAcceptable)
+	d.SetReplayWindow(0)
+	ensure.Nil(t, d.Dispatch(context.Background(), body))
+	ensure.DeepEqual(t, delivered, []string{"delivered-1", "delivered-3"})
+
+	// Only POST requests are accepted, with bodies up to the maximum size
+	w = httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/webhook", nil))
+	ensure.DeepEqual(t, w.Code, http.StatusMethodNotAllowed)
+	ensure.DeepEqual(t, w.Header().Get("Allow"), http.MethodPost)
+	d.SetMaxBodySize(16)
+	w = httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body)))
+	ensure.DeepEqual(t, w.Code, http.StatusRequestEntityTooLarge)
+}
+
+func TestWebhookDispatcherSigningKeys(t *testing.T) {
+	d := mailgun.NewWebhookDispatcher("old-key", "new-key")
+	d.SetDedupeWindow(0)
+	var handled int
+	d.OnDelivered(func(ctx context.Context, e *events.Delivered) error {
+		handled++
+		return nil
+	})
+
+	dispatch := func(key string) error {
+		event := new(events.Delivered)
+		event.SetName(events.EventDelivered)
+		event.SetID("delivered-1")
+		body, err := mailgun.SignWebhookPayload(key, event)
+		ensure.Nil(t, err)
+		return d.Dispatch(context.Background(), body)
+	}
+
+	ensure.Nil(t, dispatch("old-key"))
+	ensure.Nil(t, dispatch("new-key"))
+	ensure.DeepEqual(t, handled, 2)
+
+	// Once the old key is dropped, only the new key verifies
+	d.SetSigningKeys("new-key")
+	ensure.True(t, errors.Is(dispatch("old-key"), mailgun.ErrWebhookSignature))
+	ensure.Nil(t, dispatch("new-key"))
+	ensure.DeepEqual(t, handled, 3)
+}
+
+func TestMemoryDeduplicationStore(t *testing.T) {
+	ctx := context.Background()
+	store := mailgun.NewMemoryDeduplicationStore(2)
+
+	claim := func(key string, window time.Duration) mailgun.DedupeStatus {
+		status, err := store.Claim(ctx, key, window)
+		ensure.Nil(t, err)
+		return status
+	}
+	ensure.DeepEqual(t, claim("a", time.Hour), mailgun.DedupeNew)
+	ensure.DeepEqual(t, claim("a", time.Hour), mailgun.DedupeInProgress)
+	ensure.Nil(t, store.Complete(ctx, "a", time.Hour))
+	ensure.DeepEqual(t, claim("a", time.Hour), mailgun.DedupeHandled)
+
+	// Released events are claimed again
+	ensure.DeepEqual(t, claim("b", time.Hour), mailgun.DedupeNew)
+	ensure.Nil(t, store.Release(ctx, "b"))
+	ensure.DeepEqual(t, claim("b", time.Hour), mailgun.DedupeNew)
+	ensure.Nil(t, store.Complete(ctx, "b", time.Hour))
+
+	// The least recently seen event is dropped to make room
+	ensure.DeepEqual(t, claim("a", time.Hour), mailgun.DedupeHandled)
+	ensure.DeepEqual(t, claim("c", time.Hour), mailgun.DedupeNew)
+	ensure.DeepEqual(t, store.Len(), 2)
+	ensure.DeepEqual(t, claim("a", time.Hour), mailgun.DedupeHandled)
+	ensure.DeepEqual(t, claim("b", time.Hour), mailgun.DedupeNew)
+
+	// Events handled outside the window are claimed again
+	ensure.Nil(t, store.Complete(ctx, "b", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	ensure.DeepEqual(t, claim("b", time.Millisecond), mailgun.DedupeNew)
+}
+
+// fakeRedis implements mailgun.RedisClient with a map.
+type fakeRedis struct {
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{values: make(map[string]string), expires: make(map[string]time.Time)}
+}
+
+func (r *fakeRedis) get(key string) (string, bool) {
+	if at, ok := r.expires[key]; ok && time.Now().After(at) {
+		delete(r.values, key)
+		delete(r.expires, key)
+	}
+	v, ok := r.values[key]
+	return v, ok
+}
+
+func (r *fakeRedis) set(key, value string, ttl time.Duration) {
+	r.values[key] = value
+	delete(r.expires, key)
+	if ttl > 0 {
+		r.expires[key] = time.Now().Add(ttl)
+	}
+}
+
+func (r *fakeRedis) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.get(key); ok {
+		return false, nil
+	}
+	r.set(key, value, ttl)
+	return true, nil
+}
+
+func (r *fakeRedis) Get(ctx context.Context, key string) (string, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.get(key)
+	return v, ok, nil
+}
+
+func (r *fakeRedis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.set(key, value, ttl)
+	return nil
+}
+
+func (r *fakeRedis) Del(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.values, key)
+	delete(r.expires, key)
+	return nil
+}
+
+func TestRedisDeduplicationStore(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	store := mailgun.NewRedisDeduplicationStore(redis, "mailgun:webhook:")
+	store.SetClaimTimeout(time.Millisecond)
+
+	// Two dispatchers sharing the store drop the duplicates delivered to either
+	var handled []string
+	handler := func(ctx context.Context, e *events.Delivered) error {
+		handled = append(handled, e.ID)
+		return nil
+	}
+	first := mailgun.NewWebhookDispatcher("signing-key")
+	first.SetDeduplicationStore(store)
+	first.OnDelivered(handler)
+	second := mailgun.NewWebhookDispatcher("signing-key")
+	second.SetDeduplicationStore(store)
+	second.OnDelivered(handler)
+
+	event := new(events.Delivered)
+	event.SetName(events.EventDelivered)
+	event.SetID("delivered-1")
+	ensure.Nil(t, first.DispatchEvent(ctx, event))
+	ensure.Nil(t, second.DispatchEvent(ctx, event))
+	ensure.DeepEqual(t, handled, []string{"delivered-1"})
+	v, _, _ := redis.Get(ctx, "mailgun:webhook:delivered-1")
+	ensure.DeepEqual(t, v, "handled")
+
+	// Claims of events whose handler never finished expire
+	status, err := store.Claim(ctx, "delivered-2", time.Hour)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, status, mailgun.DedupeNew)
+	status, err = store.Claim(ctx, "delivered-2", time.Hour)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, status, mailgun.DedupeInProgress)
+	time.Sleep(5 * time.Millisecond)
+	status, err = store.Claim(ctx, "delivered-2", time.Hour)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, status, mailgun.DedupeNew)
+}
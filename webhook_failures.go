@@ -0,0 +1,100 @@
+package mailgun
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// WebhookFailure summarizes the failed HTTP deliveries to a single URL.
+type WebhookFailure struct {
+	// URL is the endpoint Mailgun could not deliver to.
+	URL string
+	// Count is the number of failed deliveries found.
+	Count int
+	// Temporary is the number of them Mailgun would try again.
+	Temporary int
+	// LastFailedAt, LastCode and LastMessage describe the most recent failure.
+	LastFailedAt time.Time
+	LastCode     int
+	LastMessage  string
+}
+
+// ListWebhookFailures scans the failed events of the domain for deliveries Mailgun made over
+// HTTP, such as routes which forward to a URL, and summarizes them by URL, so broken endpoints
+// can be detected. Mailgun does not expose a log of webhook delivery attempts, so failures of
+// webhooks for tracking events are only found when Mailgun records them as failed events.
+// opts may limit the time range scanned; the event filter is always "failed". If opts is nil,
+// the last 24 hours are scanned. Failures are returned with the most failed URL first.
+func (mg *MailgunImpl) ListWebhookFailures(ctx context.Context, opts *ListEventOptions) ([]WebhookFailure, error) {
+	var o ListEventOptions
+	if opts != nil {
+		o = *opts
+	} else {
+		o.Begin = time.Now().Add(-24 * time.Hour)
+	}
+	filter := map[string]string{"event": events.EventFailed}
+	for k, v := range o.Filter {
+		if k != "event" {
+			filter[k] = v
+		}
+	}
+	o.Filter = filter
+
+	byURL := make(map[string]*WebhookFailure)
+	it := mg.ListEvents(&o)
+	var page []Event
+	for it.Next(ctx, &page) {
+		for _, e := range page {
+			failed, ok := e.(*events.Failed)
+			if !ok || !isHTTPDelivery(failed) {
+				continue
+			}
+			f := byURL[failed.Recipient]
+			if f == nil {
+				f = &WebhookFailure{URL: failed.Recipient}
+				byURL[failed.Recipient] = f
+			}
+			f.Count++
+			if failed.Severity == "temporary" {
+				f.Temporary++
+			}
+			if at := failed.GetTimestamp(); at.After(f.LastFailedAt) {
+				f.LastFailedAt = at
+				f.LastCode = failed.DeliveryStatus.Code
+				f.LastMessage = failed.DeliveryStatus.Message
+				if f.LastMessage == "" {
+					f.LastMessage = failed.DeliveryStatus.Description
+				}
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	failures := make([]WebhookFailure, 0, len(byURL))
+	for _, f := range byURL {
+		failures = append(failures, *f)
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		if failures[i].Count != failures[j].Count {
+			return failures[i].Count > failures[j].Count
+		}
+		return failures[i].URL < failures[j].URL
+	})
+	return failures, nil
+}
+
+// isHTTPDelivery reports whether the event is for a delivery to a URL rather than a mailbox.
+func isHTTPDelivery(e *events.Failed) bool {
+	u, err := url.Parse(e.Recipient)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" || strings.EqualFold(e.Method, "http")
+}
@@ -0,0 +1,51 @@
+package mailgun_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+)
+
+func TestListWebhookFailures(t *testing.T) {
+	now := float64(time.Now().Unix())
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("page") == "next" {
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+			return
+		}
+		ensure.DeepEqual(t, r.FormValue("event"), "failed")
+		fmt.Fprintf(w, `{"items": [
+			{"event": "failed", "id": "1", "timestamp": %[1]f, "recipient": "https://hooks.example.com/inbound", "severity": "temporary",
+			 "delivery-status": {"code": 502, "message": "Bad Gateway"}},
+			{"event": "failed", "id": "2", "timestamp": %[2]f, "recipient": "https://hooks.example.com/inbound", "severity": "permanent",
+			 "delivery-status": {"code": 404, "message": "Not Found"}},
+			{"event": "failed", "id": "3", "timestamp": %[1]f, "recipient": "http://other.example.com/", "severity": "permanent",
+			 "delivery-status": {"code": 500, "description": "Internal Server Error"}},
+			{"event": "failed", "id": "4", "timestamp": %[1]f, "recipient": "user@example.com", "severity": "permanent"}
+		], "paging": {"next": "%[3]s/v3/%[4]s/events?page=next"}}`, now-60, now, srv.URL, testDomain)
+	}))
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetAPIBase(srv.URL + "/v3")
+
+	failures, err := mg.ListWebhookFailures(context.Background(), nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(failures), 2)
+
+	ensure.DeepEqual(t, failures[0].URL, "https://hooks.example.com/inbound")
+	ensure.DeepEqual(t, failures[0].Count, 2)
+	ensure.DeepEqual(t, failures[0].Temporary, 1)
+	ensure.DeepEqual(t, failures[0].LastCode, 404)
+	ensure.DeepEqual(t, failures[0].LastMessage, "Not Found")
+
+	ensure.DeepEqual(t, failures[1].URL, "http://other.example.com/")
+	ensure.DeepEqual(t, failures[1].LastMessage, "Internal Server Error")
+}
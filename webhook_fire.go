@@ -0,0 +1,127 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+// maxWebhookTestBody caps how much of the response to a test webhook is kept in WebhookTestResult.
+const maxWebhookTestBody = 4096
+
+// WebhookTestResult describes how a webhook URL answered a test event sent by `TestWebhook()`.
+type WebhookTestResult struct {
+	// Event is the test event which was sent.
+	Event      Event
+	StatusCode int
+	// Body holds the start of the response body, for logging.
+	Body    string
+	Latency time.Duration
+}
+
+// WebhookTestError is returned by `TestWebhook()` when the webhook URL answers with a status
+// other than 2xx. Mailgun retries webhooks answered with any such status but 406.
+type WebhookTestError struct {
+	URL    string
+	Result *WebhookTestResult
+}
+
+func (e *WebhookTestError) Error() string {
+	return fmt.Sprintf("webhook '%s' answered the test event with status %d", e.URL, e.Result.StatusCode)
+}
+
+// TestWebhook posts a test event for the webhook kind, such as "delivered" or "permanent_fail",
+// to url, as Mailgun would for mail sent from domain, so a freshly configured endpoint can be
+// checked end to end. The Mailgun API has no endpoint to fire a test webhook, so the payload is
+// built and signed locally, with the first key set with `SetWebhookSigningKeys()` or else the API
+// key; endpoints which verify signatures must use the same key. A status other than 2xx returns
+// a *WebhookTestError, along with the result.
+//
+//  res, err := mg.TestWebhook(ctx, "example.com", "delivered", "https://example.com/hooks")
+//  if err != nil {
+//    return err
+//  }
+//  log.Printf("webhook answered in %s", res.Latency)
+func (mg *MailgunImpl) TestWebhook(ctx context.Context, domain, kind, url string) (*WebhookTestResult, error) {
+	event, err := newWebhookTestEvent(domain, kind)
+	if err != nil {
+		return nil, err
+	}
+	req, err := NewSignedWebhookRequest(url, mg.webhookKeys()[0], event)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", mg.UserAgent())
+
+	start := time.Now()
+	resp, err := mg.Client().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxWebhookTestBody))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &WebhookTestResult{
+		Event:      event,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		Latency:    time.Since(start),
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return res, &WebhookTestError{URL: url, Result: res}
+	}
+	return res, nil
+}
+
+// newWebhookTestEvent returns the event Mailgun sends to webhooks of the kind.
+func newWebhookTestEvent(domain, kind string) (Event, error) {
+	const recipient = "alice@example.com"
+	message := events.Message{Headers: events.MessageHeaders{
+		To:        recipient,
+		From:      "test@" + domain,
+		Subject:   "Webhook test",
+		MessageID: randomString(16, "test-") + "@" + domain,
+	}}
+
+	var event Event
+	switch kind {
+	case "delivered":
+		event = &events.Delivered{Recipient: recipient, RecipientDomain: "example.com", Message: message}
+	case "permanent_fail":
+		event = &events.Failed{Recipient: recipient, RecipientDomain: "example.com", Message: message,
+			Severity: events.SeverityPermanent, Reason: events.ReasonBounce,
+			DeliveryStatus: events.DeliveryStatus{Code: 550, Message: "No such mailbox"}}
+	case "temporary_fail":
+		event = &events.Failed{Recipient: recipient, RecipientDomain: "example.com", Message: message,
+			Severity: events.SeverityTemporary, Reason: events.ReasonGeneric,
+			DeliveryStatus: events.DeliveryStatus{Code: 452, Message: "Mailbox full"}}
+	case "opened":
+		event = &events.Opened{Recipient: recipient, RecipientDomain: "example.com", Message: message}
+	case "clicked":
+		event = &events.Clicked{Recipient: recipient, RecipientDomain: "example.com", Message: message,
+			Url: "https://example.com"}
+	case "unsubscribed":
+		event = &events.Unsubscribed{Recipient: recipient, RecipientDomain: "example.com", Message: message}
+	case "complained":
+		event = &events.Complained{Recipient: recipient, Message: message}
+	default:
+		return nil, fmt.Errorf("unknown webhook kind '%s'", kind)
+	}
+
+	name := kind
+	if kind == "permanent_fail" || kind == "temporary_fail" {
+		name = events.EventFailed
+	}
+	event.SetName(name)
+	event.SetID(randomString(22, ""))
+	event.SetTimestamp(time.Now().UTC())
+	return event, nil
+}
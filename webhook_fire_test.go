@@ -0,0 +1,47 @@
+package mailgun_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
+)
+
+func TestTestWebhook(t *testing.T) {
+	const signingKey = "test-fire-signing-key"
+	d := mailgun.NewWebhookDispatcher(signingKey)
+	var failed []*events.Failed
+	d.OnFailed(func(ctx context.Context, e *events.Failed) error {
+		failed = append(failed, e)
+		return nil
+	})
+	srv := httptest.NewServer(d)
+	defer srv.Close()
+
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetWebhookSigningKeys(signingKey)
+	ctx := context.Background()
+
+	res, err := mg.TestWebhook(ctx, "example.com", "permanent_fail", srv.URL)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, res.StatusCode, http.StatusOK)
+	ensure.DeepEqual(t, len(failed), 1)
+	ensure.DeepEqual(t, failed[0].Severity, events.SeverityPermanent)
+	ensure.DeepEqual(t, failed[0].Message.Headers.From, "test@example.com")
+	ensure.DeepEqual(t, failed[0].ID, res.Event.GetID())
+
+	// The endpoint rejects signatures made with another key
+	mg.SetWebhookSigningKeys("other-key")
+	res, err = mg.TestWebhook(ctx, "example.com", "delivered", srv.URL)
+	werr, ok := err.(*mailgun.WebhookTestError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, werr.Result.StatusCode, http.StatusNotAcceptable)
+	ensure.DeepEqual(t, res.StatusCode, http.StatusNotAcceptable)
+
+	_, err = mg.TestWebhook(ctx, "example.com", "bogus", srv.URL)
+	ensure.NotNil(t, err)
+}
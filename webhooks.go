@@ -1,15 +1,20 @@
 package mailgun
 
 import (
+	"bytes"
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
+	jsoniter "github.com/json-iterator/go"
 	"github.com/yjimk/mailgun-go/v4/events"
 )
 
@@ -29,9 +34,11 @@ type WebHookResponse struct {
 // ListWebhooks returns the complete set of webhooks configured for your domain.
 // Note that a zero-length mapping is not an error.
 func (mg *MailgunImpl) ListWebhooks(ctx context.Context) (map[string][]string, error) {
-	r := newHTTPRequest(generateDomainApiUrl(mg, webhooksEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	return mg.listWebhooks(ctx, mg.Domain())
+}
+
+func (mg *MailgunImpl) listWebhooks(ctx context.Context, domain string) (map[string][]string, error) {
+	r := newAPIRequest(mg, generateDomainApiUrlWithDomain(mg, webhooksEndpoint, domain))
 
 	var body WebHooksListResponse
 	err := getResponseFromJSON(ctx, r, &body)
@@ -53,10 +60,8 @@ func (mg *MailgunImpl) ListWebhooks(ctx context.Context) (map[string][]string, e
 
 // CreateWebhook installs a new webhook for your domain.
 func (mg *MailgunImpl) CreateWebhook(ctx context.Context, kind string, urls []string) error {
-	r := newHTTPRequest(generateDomainApiUrl(mg, webhooksEndpoint))
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	p := newUrlEncodedPayload()
+	r := newAPIRequest(mg, generateDomainApiUrl(mg, webhooksEndpoint))
+	p := newPayload()
 	p.addValue("id", kind)
 	for _, url := range urls {
 		p.addValue("url", url)
@@ -67,18 +72,18 @@ func (mg *MailgunImpl) CreateWebhook(ctx context.Context, kind string, urls []st
 
 // DeleteWebhook removes the specified webhook from your domain's configuration.
 func (mg *MailgunImpl) DeleteWebhook(ctx context.Context, kind string) error {
-	r := newHTTPRequest(generateDomainApiUrl(mg, webhooksEndpoint) + "/" + kind)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	return mg.deleteWebhook(ctx, mg.Domain(), kind)
+}
+
+func (mg *MailgunImpl) deleteWebhook(ctx context.Context, domain, kind string) error {
+	r := newAPIRequest(mg, generateDomainApiUrlWithDomain(mg, webhooksEndpoint, domain)+"/"+pathEscape(kind))
 	_, err := makeDeleteRequest(ctx, r)
 	return err
 }
 
 // GetWebhook retrieves the currently assigned webhook URL associated with the provided type of webhook.
 func (mg *MailgunImpl) GetWebhook(ctx context.Context, kind string) ([]string, error) {
-	r := newHTTPRequest(generateDomainApiUrl(mg, webhooksEndpoint) + "/" + kind)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
+	r := newAPIRequest(mg, generateDomainApiUrl(mg, webhooksEndpoint)+"/"+pathEscape(kind))
 	var body WebHookResponse
 	if err := getResponseFromJSON(ctx, r, &body); err != nil {
 		return nil, err
@@ -95,10 +100,8 @@ func (mg *MailgunImpl) GetWebhook(ctx context.Context, kind string) ([]string, e
 
 // UpdateWebhook replaces one webhook setting for another.
 func (mg *MailgunImpl) UpdateWebhook(ctx context.Context, kind string, urls []string) error {
-	r := newHTTPRequest(generateDomainApiUrl(mg, webhooksEndpoint) + "/" + kind)
-	r.setClient(mg.Client())
-	r.setBasicAuth(basicAuthUser, mg.APIKey())
-	p := newUrlEncodedPayload()
+	r := newAPIRequest(mg, generateDomainApiUrl(mg, webhooksEndpoint)+"/"+pathEscape(kind))
+	p := newPayload()
 	for _, url := range urls {
 		p.addValue("url", url)
 	}
@@ -121,7 +124,44 @@ type WebhookPayload struct {
 
 // Use this method to parse the webhook signature given as JSON in the webhook response
 func (mg *MailgunImpl) VerifyWebhookSignature(sig Signature) (verified bool, err error) {
-	h := hmac.New(sha256.New, []byte(mg.APIKey()))
+	return verifyWebhookSignatureAny(mg.webhookKeys(), sig)
+}
+
+// SetWebhookSigningKeys sets the keys `VerifyWebhookSignature()` verifies signatures with; a
+// signature made with any of them verifies. Set both the current and the new key while rotating
+// the signing key with `RegenerateWebhookSigningKey()`, so webhooks signed with either are
+// accepted, then drop the old key once Mailgun signs with the new one. With no keys, the default,
+// signatures are verified with the API key of the client.
+//
+//  mg.SetWebhookSigningKeys(newKey, oldKey)
+func (mg *MailgunImpl) SetWebhookSigningKeys(keys ...string) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.webhookSigningKeys = append([]string(nil), keys...)
+}
+
+func (mg *MailgunImpl) webhookKeys() []string {
+	mg.mu.RLock()
+	defer mg.mu.RUnlock()
+	if len(mg.webhookSigningKeys) == 0 {
+		return []string{mg.apiKey}
+	}
+	return mg.webhookSigningKeys
+}
+
+// verifyWebhookSignatureAny reports whether sig was made with any of the signing keys.
+func verifyWebhookSignatureAny(signingKeys []string, sig Signature) (bool, error) {
+	for _, key := range signingKeys {
+		verified, err := verifyWebhookSignature(key, sig)
+		if err != nil || verified {
+			return verified, err
+		}
+	}
+	return false, nil
+}
+
+func verifyWebhookSignature(signingKey string, sig Signature) (bool, error) {
+	h := hmac.New(sha256.New, []byte(signingKey))
 	io.WriteString(h, sig.TimeStamp)
 	io.WriteString(h, sig.Token)
 
@@ -140,18 +180,65 @@ func (mg *MailgunImpl) VerifyWebhookSignature(sig Signature) (verified bool, err
 // Deprecated: Please use the VerifyWebhookSignature() to parse the latest
 // version of WebHooks from mailgun
 func (mg *MailgunImpl) VerifyWebhookRequest(req *http.Request) (verified bool, err error) {
-	h := hmac.New(sha256.New, []byte(mg.APIKey()))
-	io.WriteString(h, req.FormValue("timestamp"))
-	io.WriteString(h, req.FormValue("token"))
+	return verifyWebhookSignatureAny(mg.webhookKeys(), Signature{
+		TimeStamp: req.FormValue("timestamp"),
+		Token:     req.FormValue("token"),
+		Signature: req.FormValue("signature"),
+	})
+}
 
-	calculatedSignature := h.Sum(nil)
-	signature, err := hex.DecodeString(req.FormValue("signature"))
-	if err != nil {
-		return false, err
+// SignWebhook computes the signature Mailgun attaches to webhook requests
+// for the given timestamp and token, using signingKey.
+func SignWebhook(signingKey, timestamp, token string) Signature {
+	h := hmac.New(sha256.New, []byte(signingKey))
+	io.WriteString(h, timestamp)
+	io.WriteString(h, token)
+
+	return Signature{
+		TimeStamp: timestamp,
+		Token:     token,
+		Signature: hex.EncodeToString(h.Sum(nil)),
 	}
-	if len(calculatedSignature) != len(signature) {
-		return false, nil
+}
+
+// SignWebhookPayload returns the JSON body Mailgun would POST to a webhook URL for the event,
+// signed with signingKey. Use it to drive webhook handlers end-to-end from tests; the
+// signature passes VerifyWebhookSignature() on a client whose API key is signingKey.
+//
+//  event := new(events.Delivered)
+//  event.SetName(events.EventDelivered)
+//  event.SetID("delivered-id")
+//  body, err := mailgun.SignWebhookPayload("my-signing-key", event)
+func SignWebhookPayload(signingKey string, event Event) ([]byte, error) {
+	if event.GetTimestamp().Unix() == 0 {
+		event.SetTimestamp(time.Now().UTC())
+	}
+	data, err := jsoniter.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event '%s': %s", event.GetName(), err)
 	}
 
-	return subtle.ConstantTimeCompare(signature, calculatedSignature) == 1, nil
+	sig := SignWebhook(signingKey, strconv.FormatInt(time.Now().Unix(), 10), randomString(50, ""))
+	return json.Marshal(struct {
+		Signature Signature       `json:"signature"`
+		EventData json.RawMessage `json:"event-data"`
+	}{
+		Signature: sig,
+		EventData: data,
+	})
+}
+
+// NewSignedWebhookRequest returns a POST request to url carrying the payload
+// returned by SignWebhookPayload(), ready to hand to an http.Handler under test.
+func NewSignedWebhookRequest(url, signingKey string, event Event) (*http.Request, error) {
+	body, err := SignWebhookPayload(signingKey, event)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
 }
@@ -6,6 +6,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/facebookgo/ensure"
 	"github.com/yjimk/mailgun-go/v4"
+	"github.com/yjimk/mailgun-go/v4/events"
 )
 
 func TestGetWebhook(t *testing.T) {
@@ -97,6 +99,27 @@ func TestVerifyWebhookSignature(t *testing.T) {
 	}
 }
 
+func TestVerifyWebhookSignatureKeys(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+	mg.SetWebhookSigningKeys("new-key", "old-key")
+
+	for _, key := range []string{"new-key", "old-key"} {
+		verified, err := mg.VerifyWebhookSignature(mailgun.SignWebhook(key, "1420255392", "token"))
+		ensure.Nil(t, err)
+		ensure.True(t, verified)
+	}
+
+	// The API key is no longer used once signing keys are set
+	verified, err := mg.VerifyWebhookSignature(mailgun.SignWebhook(testKey, "1420255392", "token"))
+	ensure.Nil(t, err)
+	ensure.False(t, verified)
+
+	mg.SetWebhookSigningKeys()
+	verified, err = mg.VerifyWebhookSignature(mailgun.SignWebhook(testKey, "1420255392", "token"))
+	ensure.Nil(t, err)
+	ensure.True(t, verified)
+}
+
 func TestVerifyWebhookRequest_Form(t *testing.T) {
 	mg := mailgun.NewMailgun(testDomain, testKey)
 
@@ -129,6 +152,41 @@ func TestVerifyWebhookRequest_MultipartForm(t *testing.T) {
 	}
 }
 
+func TestSignWebhookPayload(t *testing.T) {
+	mg := mailgun.NewMailgun(testDomain, testKey)
+
+	event := new(events.Delivered)
+	event.SetName(events.EventDelivered)
+	event.SetID("delivered-id")
+	event.Recipient = "user@example.com"
+
+	req, err := mailgun.NewSignedWebhookRequest("http://localhost/webhook", mg.APIKey(), event)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, req.Header.Get("Content-Type"), "application/json")
+
+	// Decode as a webhook handler would
+	var payload mailgun.WebhookPayload
+	ensure.Nil(t, json.NewDecoder(req.Body).Decode(&payload))
+
+	verified, err := mg.VerifyWebhookSignature(payload.Signature)
+	ensure.Nil(t, err)
+	ensure.True(t, verified)
+
+	parsed, err := mailgun.ParseEvent(payload.EventData)
+	ensure.Nil(t, err)
+	delivered, ok := parsed.(*events.Delivered)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, delivered.GetID(), "delivered-id")
+	ensure.DeepEqual(t, delivered.Recipient, "user@example.com")
+	ensure.False(t, delivered.GetTimestamp().Unix() == 0)
+
+	// A different key does not verify
+	other := mailgun.NewMailgun(testDomain, "another-key")
+	verified, err = other.VerifyWebhookSignature(payload.Signature)
+	ensure.Nil(t, err)
+	ensure.False(t, verified)
+}
+
 func buildFormRequest(fields map[string]string) *http.Request {
 	values := url.Values{}
 